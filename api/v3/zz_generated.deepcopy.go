@@ -106,7 +106,7 @@ func (in *ClusterMasterSpec) DeepCopy() *ClusterMasterSpec {
 func (in *ClusterMasterStatus) DeepCopyInto(out *ClusterMasterStatus) {
 	*out = *in
 	in.SmartStore.DeepCopyInto(&out.SmartStore)
-	out.BundlePushTracker = in.BundlePushTracker
+	in.BundlePushTracker.DeepCopyInto(&out.BundlePushTracker)
 	if in.ResourceRevMap != nil {
 		in, out := &in.ResourceRevMap, &out.ResourceRevMap
 		*out = make(map[string]string, len(*in))
@@ -420,7 +420,7 @@ func (in *MonitoringConsoleSpec) DeepCopy() *MonitoringConsoleSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *MonitoringConsoleStatus) DeepCopyInto(out *MonitoringConsoleStatus) {
 	*out = *in
-	out.BundlePushTracker = in.BundlePushTracker
+	in.BundlePushTracker.DeepCopyInto(&out.BundlePushTracker)
 	if in.ResourceRevMap != nil {
 		in, out := &in.ResourceRevMap, &out.ResourceRevMap
 		*out = make(map[string]string, len(*in))
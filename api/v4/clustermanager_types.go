@@ -43,6 +43,57 @@ type ClusterManagerSpec struct {
 
 	// Splunk Enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// Failover of the sites of a multisite indexer cluster
+	SiteFailover SiteFailoverSpec `json:"siteFailover,omitempty"`
+
+	// Checks run before pushing the manager apps bundle to the peers
+	// +optional
+	BundlePushPolicy BundlePushPolicySpec `json:"bundlePushPolicy,omitempty"`
+
+	// Namespaces other than its own, whose indexer clusters are allowed to refer to the cluster manager. The
+	// idxc_secret of the cluster manager is shared with the indexer clusters of these namespaces only
+	// +optional
+	// +listType=set
+	AllowedPeerNamespaces []string `json:"allowedPeerNamespaces,omitempty"`
+}
+
+// SiteFailoverSpec defines the failover of the failed sites of a multisite indexer cluster to a surviving site
+type SiteFailoverSpec struct {
+	// Sites of the indexer cluster that are down. The failed sites are mapped to the target site, and failed back once
+	// removed from the list
+	// +listType=set
+	FailedSites []string `json:"failedSites,omitempty"`
+
+	// Surviving site the failed sites are mapped to, required when sites are failed
+	// +kubebuilder:validation:Pattern=`^site[1-9][0-9]*$`
+	TargetSite string `json:"targetSite,omitempty"`
+
+	// Disable the search affinity of the search heads referring to the cluster manager, while sites are failed over
+	DisableSearchAffinity bool `json:"disableSearchAffinity,omitempty"`
+
+	// Standby cluster manager, running in another site
+	Standby ClusterManagerStandbySpec `json:"standby,omitempty"`
+}
+
+// ClusterManagerStandbySpec defines a standby cluster manager, promoted when the site of the cluster manager fails
+type ClusterManagerStandbySpec struct {
+	// Run a standby cluster manager
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Site of the cluster manager. The standby cluster manager is promoted when this site fails
+	// +kubebuilder:validation:Pattern=`^site[1-9][0-9]*$`
+	ManagerSite string `json:"managerSite,omitempty"`
+
+	// Site of the standby cluster manager
+	// +kubebuilder:validation:Pattern=`^site[1-9][0-9]*$`
+	Site string `json:"site,omitempty"`
+
+	// Availability zone of the nodes the standby cluster manager is scheduled on
+	Zone string `json:"zone,omitempty"`
+
+	// Labels of the nodes the standby cluster manager is scheduled on
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
 }
 
 // ClusterManagerStatus defines the observed state of ClusterManager
@@ -70,6 +121,149 @@ type ClusterManagerStatus struct {
 
 	// Auxillary message describing CR status
 	Message string `json:"message"`
+
+	// Site failover status
+	SiteFailover SiteFailoverStatus `json:"siteFailover,omitempty"`
+
+	// progress of the volume expansion of the cluster manager pods, after an increase of their storage capacity
+	// +optional
+	VolumeResize []VolumeResizeStatus `json:"volumeResize,omitempty"`
+
+	// upgrade of the Splunk Enterprise version of the cluster manager, under the upgrade policy
+	// +optional
+	Upgrade UpgradeStatus `json:"upgrade,omitempty"`
+
+	// conditions of the custom resource, e.g. UpgradeFailed
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// History of the bundles pushed to the peers, the most recent last
+	// +optional
+	BundleHistory []BundleHistoryEntry `json:"bundleHistory,omitempty"`
+
+	// Rollback of the bundle requested with the bundle rollback annotation
+	// +optional
+	BundleRollback BundleRollbackStatus `json:"bundleRollback,omitempty"`
+}
+
+const (
+	// BundlePushTriggerSmartStore indicates the bundle was pushed for a SmartStore change
+	BundlePushTriggerSmartStore = "SmartStore"
+
+	// BundlePushTriggerAppFramework indicates the bundle was pushed for an App Framework change
+	BundlePushTriggerAppFramework = "AppFramework"
+
+	// BundlePushTriggerRollback indicates the bundle was restored by a rollback
+	BundlePushTriggerRollback = "Rollback"
+)
+
+// BundleHistoryEntry represents a bundle pushed to the peers
+type BundleHistoryEntry struct {
+	// ID of the bundle, as reported in the active bundle ID of the peers. Empty until the cluster manager reports the
+	// pushed bundle
+	BundleID string `json:"bundleId,omitempty"`
+
+	// Time when the bundle was pushed, in Unix epoch seconds
+	PushTime int64 `json:"pushTime"`
+
+	// Change which triggered the push: SmartStore, AppFramework or Rollback
+	Trigger string `json:"trigger"`
+
+	// Apps installed by the push, in <appSource>/<appName> format
+	Apps []string `json:"apps,omitempty"`
+}
+
+const (
+	// BundleRollbackInProgress indicates the peers are rolled back to the previous bundle
+	BundleRollbackInProgress = "InProgress"
+
+	// BundleRollbackComplete indicates all the peers are rolled back to the previous bundle
+	BundleRollbackComplete = "Complete"
+
+	// BundleRollbackFailed indicates the rollback failed
+	BundleRollbackFailed = "Failed"
+)
+
+// BundleRollbackStatus represents the rollback of the bundle to the previous bundle
+type BundleRollbackStatus struct {
+	// Value of the bundle rollback annotation handled last
+	Request string `json:"request,omitempty"`
+
+	// Phase of the rollback: InProgress, Complete or Failed
+	Phase string `json:"phase,omitempty"`
+
+	// ID of the bundle active before the rollback
+	FromBundleID string `json:"fromBundleId,omitempty"`
+
+	// ID of the previous bundle the peers are rolled back to
+	ToBundleID string `json:"toBundleId,omitempty"`
+
+	// Time when the rollback started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the rollback completed or failed, in Unix epoch seconds
+	CompletionTime int64 `json:"completionTime,omitempty"`
+
+	// Outcome of the rollback
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// SiteFailoverPhaseFailingOver indicates the failed sites are being failed over
+	SiteFailoverPhaseFailingOver = "FailingOver"
+
+	// SiteFailoverPhaseFailedOver indicates the failed sites are failed over to the target site
+	SiteFailoverPhaseFailedOver = "FailedOver"
+
+	// SiteFailoverPhaseFailingBack indicates the failed sites are being failed back
+	SiteFailoverPhaseFailingBack = "FailingBack"
+
+	// ActiveManagerPrimary indicates the primary cluster manager serves the indexer cluster
+	ActiveManagerPrimary = "primary"
+
+	// ActiveManagerStandby indicates the standby cluster manager serves the indexer cluster
+	ActiveManagerStandby = "standby"
+)
+
+// SiteFailoverStatus tracks the failover of the failed sites of a multisite indexer cluster
+type SiteFailoverStatus struct {
+	// Phase of the site failover, empty when no site is failed over
+	Phase string `json:"phase,omitempty"`
+
+	// Sites failed over to the target site
+	FailedSites []string `json:"failedSites,omitempty"`
+
+	// Site the failed sites are failed over to
+	TargetSite string `json:"targetSite,omitempty"`
+
+	// Site mappings applied on the active cluster manager
+	SiteMappings string `json:"siteMappings,omitempty"`
+
+	// Cluster manager serving the indexer cluster while a standby cluster manager runs: primary or standby
+	ActiveManager string `json:"activeManager,omitempty"`
+
+	// true if the search affinity of the search heads is disabled
+	SearchAffinityDisabled bool `json:"searchAffinityDisabled,omitempty"`
+
+	// Sites of the search heads before their search affinity was disabled, by pod
+	SearchHeadSites map[string]string `json:"searchHeadSites,omitempty"`
+
+	// Steps of the site failovers and failbacks, most recent last
+	Timeline []SiteFailoverEvent `json:"timeline,omitempty"`
+}
+
+// SiteFailoverEvent is a step of a site failover or failback
+type SiteFailoverEvent struct {
+	// Time of the step, in seconds since the epoch
+	Time int64 `json:"time"`
+
+	// Name of the step
+	Step string `json:"step"`
+
+	// Description of the step
+	Message string `json:"message,omitempty"`
 }
 
 // BundlePushInfo Indicates if bundle push required
@@ -77,6 +271,9 @@ type BundlePushInfo struct {
 	NeedToPushMasterApps  bool  `json:"needToPushMasterApps"` // NeedToPushMasterApps is an exception needed for dual support
 	NeedToPushManagerApps bool  `json:"needToPushManagerApps"`
 	LastCheckInterval     int64 `json:"lastCheckInterval"`
+
+	// Validation of the manager apps bundle before the push
+	Validation BundleValidationStatus `json:"validation,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
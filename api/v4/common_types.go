@@ -17,6 +17,7 @@ package v4
 
 import (
 	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 const (
@@ -26,6 +27,21 @@ const (
 
 	// TotalWorker concurrent workers to reconcile
 	TotalWorker int = 15
+
+	// MaintenanceWindowOverrideAnnotation when set to "true" on a CR, lets the App Framework install the apps
+	// and push the bundle outside of the maintenance windows
+	MaintenanceWindowOverrideAnnotation = "enterprise.splunk.com/maintenance-window-override"
+
+	// UpgradeApprovalAnnotation when set on a CR to the target Splunk version of an upgrade paused by the upgrade
+	// policy, approves the upgrade
+	UpgradeApprovalAnnotation = "enterprise.splunk.com/upgrade-approved"
+
+	// BundleRollbackAnnotation when set on a ClusterManager to a new value, e.g. the time of the request, rolls back the
+	// peers to the previous configuration bundle
+	BundleRollbackAnnotation = "enterprise.splunk.com/bundle-rollback"
+
+	// VolumeSnapshotSetLabel is set on the VolumeSnapshots of the pods of a CR to the name of their snapshot set
+	VolumeSnapshotSetLabel = "enterprise.splunk.com/snapshot-set"
 )
 
 // default all fields to being optional
@@ -201,7 +217,8 @@ type CommonSplunkSpec struct {
 	// ClusterManagerRef refers to a Splunk Enterprise indexer cluster managed by the operator within Kubernetes
 	ClusterManagerRef corev1.ObjectReference `json:"clusterManagerRef"`
 
-	// MonitoringConsoleRef refers to a Splunk Enterprise monitoring console managed by the operator within Kubernetes
+	// MonitoringConsoleRef refers to a Splunk Enterprise monitoring console managed by the operator within Kubernetes,
+	// which may live in another namespace when its namespace is set
 	MonitoringConsoleRef corev1.ObjectReference `json:"monitoringConsoleRef"`
 
 	// Mock to differentiate between UTs and actual reconcile
@@ -238,6 +255,31 @@ type CommonSplunkSpec struct {
 	// Sets imagePullSecrets if image is being pulled from a private registry.
 	// See https://kubernetes.io/docs/tasks/configure-pod-container/pull-image-private-registry/
 	ImagePullSecrets []corev1.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Policy applied to the upgrades of the Splunk Enterprise version
+	// +optional
+	UpgradePolicy UpgradePolicySpec `json:"upgradePolicy,omitempty"`
+
+	// TLS settings used by the operator to verify the certificates of the splunkd management port. Overrides the
+	// operator-wide Secret
+	// +optional
+	SplunkdTLS SplunkdTLSSpec `json:"splunkdTLS,omitempty"`
+}
+
+// SplunkdTLSSpec defines how the operator verifies the certificates of the splunkd management port
+type SplunkdTLSSpec struct {
+	// Name of a Secret in the namespace of the custom resource, with the PEM encoded CA bundle under the ca.crt key
+	// +optional
+	CABundleSecretRef string `json:"caBundleSecretRef,omitempty"`
+
+	// Server name expected in the certificates, e.g. SplunkServerDefaultCert. Defaults to the host name of the pods
+	// +optional
+	ServerName string `json:"serverName,omitempty"`
+
+	// Do not verify the certificates. Must be set explicitly to skip the verification when a CA bundle is configured
+	// for the operator
+	// +optional
+	InsecureSkipVerify bool `json:"insecureSkipVerify,omitempty"`
 }
 
 // StorageClassSpec defines storage class configuration
@@ -400,7 +442,7 @@ type EsDefaults struct {
 	SslEnablement string `json:"sslEnablement,omitempty"`
 }
 
-// AppSourceSpec defines list of App package (*.spl, *.tgz) locations on remote volumes
+// AppSourceSpec defines list of App package (*.spl, *.tgz, *.tar.gz, *.tar, *.zip or exploded app directory) locations on remote volumes
 type AppSourceSpec struct {
 	// Logical name for the set of apps placed in this location. Logical name must be unique to the appRepo
 	Name string `json:"name"`
@@ -409,6 +451,20 @@ type AppSourceSpec struct {
 	Location string `json:"location"`
 
 	AppSourceDefaultSpec `json:",inline"`
+
+	// Install order dependencies between the apps of this App source. These are honored in addition to the dependencies
+	// declared in the app.manifest of the app packages. Applicable only for local and premiumApps scopes
+	// +optional
+	AppDependencies []AppDependencySpec `json:"appDependencies,omitempty"`
+}
+
+// AppDependencySpec declares the apps that must be installed before a given app
+type AppDependencySpec struct {
+	// Name of the app. Can be either the app package name(e.g. app1.tgz), or the app name(e.g. Splunk_SA_CIM)
+	Name string `json:"name"`
+
+	// List of apps that must be installed before this app. Each entry can be either the app package name, or the app name
+	DependsOn []string `json:"dependsOn"`
 }
 
 // AppFrameworkSpec defines the application package remote store repository
@@ -446,6 +502,93 @@ type AppFrameworkSpec struct {
 
 	// Maximum number of apps that can be downloaded at same time
 	MaxConcurrentAppDownloads uint64 `json:"maxConcurrentAppDownloads,omitempty"`
+
+	// Policy for the static validation of app packages, done on the operator pod after the download
+	// +optional
+	AppPackagePolicy AppPackagePolicySpec `json:"appPackagePolicy,omitempty"`
+
+	// Maintenance windows for the app installs and the bundle push. When configured, the app packages are downloaded
+	// and copied to the pods at any time, but the installs and the bundle push wait for a maintenance window to open
+	// +optional
+	MaintenanceWindows []MaintenanceWindowSpec `json:"maintenanceWindows,omitempty"`
+}
+
+// MaintenanceWindowSpec defines a recurring maintenance window
+type MaintenanceWindowSpec struct {
+	// Start of the window in cron format: minute hour day-of-month month day-of-week, e.g. "0 2 * * 6" for every Saturday at 02:00
+	Schedule string `json:"schedule"`
+
+	// Length of the window in minutes
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=10080
+	DurationMinutes uint32 `json:"durationMinutes"`
+
+	// IANA time zone of the schedule, e.g. America/Los_Angeles. Defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// BundlePushPolicySpec defines the checks run before pushing the configuration bundle to the indexer cluster peers or
+// the search head cluster members
+type BundlePushPolicySpec struct {
+	// Push the bundle without validating it first
+	// +optional
+	SkipValidation bool `json:"skipValidation,omitempty"`
+
+	// Windows for the bundle pushes which require a rolling restart. When configured, a bundle push requiring a rolling
+	// restart waits for a window to open, the other bundle pushes are not held. As the search head cluster deployer can
+	// not tell it in advance, every search head cluster bundle push is considered to require a rolling restart
+	// +optional
+	RestartWindows []MaintenanceWindowSpec `json:"restartWindows,omitempty"`
+}
+
+// AppPackagePolicySpec defines the static validation of the app packages. By default, every app package must have a
+// single top folder with a parsable default/app.conf, must not have path traversal entries, links pointing outside
+// the app, or a local directory
+type AppPackagePolicySpec struct {
+	// Skip the static validation of the app packages
+	// +optional
+	Disabled bool `json:"disabled,omitempty"`
+
+	// Allow the app packages with a local directory
+	// +optional
+	AllowLocalDir bool `json:"allowLocalDir,omitempty"`
+
+	// Additional rules to forbid the app package content
+	// +optional
+	Rules []AppPackageRuleSpec `json:"rules,omitempty"`
+}
+
+// AppPackageRuleSpec defines a rule that forbids specific content in the app packages
+type AppPackageRuleSpec struct {
+	// Name of the rule, reported along with the violations
+	Name string `json:"name"`
+
+	// Kinds of the CR the rule applies to, e.g. SearchHeadCluster. Applies to all the kinds when empty
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+
+	// App sources the rule applies to. Applies to all the app sources when empty
+	// +optional
+	AppSources []string `json:"appSources,omitempty"`
+
+	// Forbidden paths relative to the app top folder, as shell file name patterns, e.g. bin/*.exe
+	// +optional
+	ForbiddenPaths []string `json:"forbiddenPaths,omitempty"`
+
+	// Forbidden conf file stanzas in the default directory of the app
+	// +optional
+	ForbiddenStanzas []AppConfStanzaSpec `json:"forbiddenStanzas,omitempty"`
+}
+
+// AppConfStanzaSpec identifies the stanzas of a conf file
+type AppConfStanzaSpec struct {
+	// Name of the conf file, e.g. inputs.conf
+	ConfFile string `json:"confFile"`
+
+	// Stanza name prefix, e.g. script:// matches all the scripted inputs. Matches all the stanzas when empty
+	// +optional
+	StanzaPrefix string `json:"stanzaPrefix,omitempty"`
 }
 
 // AppDeploymentInfo represents a single App deployment information
@@ -460,11 +603,23 @@ type AppDeploymentInfo struct {
 	RepoState        AppRepoState        `json:"repoState"`
 	DeployStatus     AppDeploymentStatus `json:"deployStatus"`
 
+	// PackageSize is the size of the app package on the operator pod. It differs from
+	// the Size for the apps converted to a gzipped tarball, and for the exploded apps
+	PackageSize uint64 `json:"packageSize,omitempty"`
+
 	// AppPackageTopFolder is the name of top folder when we untar the
 	// app archive, which is also assumed to be same as the name of the
 	// app after it is installed.
 	AppPackageTopFolder string `json:"appPackageTopFolder"`
 
+	// Dependencies is the list of apps declared in the dependencies section
+	// of the app.manifest, which must be installed before this app.
+	Dependencies []string `json:"dependencies,omitempty"`
+
+	// ValidationErrors lists the reasons the app package failed
+	// the static validation on the operator pod
+	ValidationErrors []string `json:"validationErrors,omitempty"`
+
 	// App phase info to track download, copy and install
 	PhaseInfo PhaseInfo `json:"phaseInfo,omitempty"`
 
@@ -500,6 +655,44 @@ type BundlePushTracker struct {
 
 	// defines the number of retries completed so far
 	RetryCount int32 `json:"retryCount,omitempty"`
+
+	// Validation of the bundle before the push
+	Validation BundleValidationStatus `json:"validation,omitempty"`
+}
+
+// BundleValidationPhase represents the phase of the bundle validation
+type BundleValidationPhase string
+
+const (
+	// BundleValidationPending indicates the bundle validation is in progress
+	BundleValidationPending BundleValidationPhase = "Pending"
+
+	// BundleValidationSuccess indicates the bundle passed the validation
+	BundleValidationSuccess BundleValidationPhase = "Success"
+
+	// BundleValidationFailure indicates the bundle failed the validation
+	BundleValidationFailure BundleValidationPhase = "Failure"
+)
+
+// BundleValidationStatus represents the validation of the configuration bundle before the push
+type BundleValidationStatus struct {
+	// Phase of the bundle validation
+	Phase BundleValidationPhase `json:"phase,omitempty"`
+
+	// Time when the bundle validation was requested, in Unix epoch seconds
+	LastValidationTime int64 `json:"lastValidationTime,omitempty"`
+
+	// Indicates the bundle push requires a rolling restart
+	RestartRequired bool `json:"restartRequired,omitempty"`
+
+	// Errors reported by the bundle validation
+	Errors []string `json:"errors,omitempty"`
+
+	// Indicates the bundle push is held, as it requires a rolling restart outside of the restart windows
+	PushHeld bool `json:"pushHeld,omitempty"`
+
+	// Time when the next restart window opens, in Unix epoch seconds
+	NextWindowStart int64 `json:"nextWindowStart,omitempty"`
 }
 
 const (
@@ -540,6 +733,24 @@ type AppDeploymentContext struct {
 
 	// Internal to the App framework. Used in case of CM(IDXC) and deployer(SHC)
 	BundlePushStatus BundlePushTracker `json:"bundlePushStatus,omitempty"`
+
+	// Represents the App Framework work held for the maintenance window
+	MaintenanceWindowStatus MaintenanceWindowStatus `json:"maintenanceWindowStatus,omitempty"`
+}
+
+// MaintenanceWindowStatus represents the App Framework work waiting for a maintenance window
+type MaintenanceWindowStatus struct {
+	// Indicates the app installs and the bundle push are held until the next maintenance window
+	Held bool `json:"held,omitempty"`
+
+	// Time when the next maintenance window opens, in Unix epoch seconds
+	NextWindowStart int64 `json:"nextWindowStart,omitempty"`
+
+	// Apps waiting for the maintenance window to install, in <appSource>/<appName> format
+	HeldApps []string `json:"heldApps,omitempty"`
+
+	// Indicates the bundle push is waiting for the maintenance window
+	BundlePushHeld bool `json:"bundlePushHeld,omitempty"`
 }
 
 // AppPhaseStatusType defines the Phase status
@@ -576,6 +787,8 @@ const (
 	AppPkgDownloadInProgress = 102
 	// AppPkgDownloadComplete indicates complete
 	AppPkgDownloadComplete = 103
+	// AppPkgValidationError indicates the downloaded app package failed the static validation
+	AppPkgValidationError = 198
 	// AppPkgDownloadError indicates error after retries
 	AppPkgDownloadError = 199
 )
@@ -600,6 +813,8 @@ const (
 	AppPkgInstallInProgress = 302
 	// AppPkgInstallComplete indicates complete
 	AppPkgInstallComplete = 303
+	// AppPkgInstallDependencyError indicates the app dependencies can not be satisfied(missing, failed or cyclic)
+	AppPkgInstallDependencyError = 397
 	// AppPkgMissingOnPodError indicates app pkg is not available on Pod for install
 	AppPkgMissingOnPodError = 398
 	// AppPkgInstallError indicates error after retries
@@ -617,3 +832,289 @@ const (
 	// StatefulSetScalingDown indicates sts is scaling down
 	StatefulSetScalingDown
 )
+
+// AutoscalingSpec defines the load metrics exported for the autoscaling of a cluster, and the guards applied
+// to the replicas changes
+type AutoscalingSpec struct {
+	// Export the load metrics of the cluster members to Prometheus, to be used by a HorizontalPodAutoscaler
+	// through a Prometheus adapter
+	// +optional
+	MetricsEnabled bool `json:"metricsEnabled,omitempty"`
+
+	// Minimum time between two replicas changes, e.g. 10m. A replicas change within the cooldown is held
+	// until the cooldown is over
+	// +optional
+	ScaleCooldown *metav1.Duration `json:"scaleCooldown,omitempty"`
+}
+
+// AutoscalingStatus tracks the replicas changes of a cluster
+type AutoscalingStatus struct {
+	// Time of the last replicas change, in seconds since epoch
+	LastScaleTime int64 `json:"lastScaleTime,omitempty"`
+
+	// Replicas of the scaling in progress
+	TargetReplicas int32 `json:"targetReplicas,omitempty"`
+
+	// Replicas change held by a cooldown, an in-flight bundle push, decommission or update
+	HeldReplicas int32 `json:"heldReplicas,omitempty"`
+
+	// Reason the replicas change is held
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// VolumeResizePhaseResizing indicates the volume of a PVC is being expanded
+	VolumeResizePhaseResizing = "Resizing"
+
+	// VolumeResizePhaseFileSystemResizePending indicates the volume of a PVC is expanded, and waits for the node
+	// to expand its file system
+	VolumeResizePhaseFileSystemResizePending = "FileSystemResizePending"
+
+	// VolumeResizePhaseResized indicates the volume and the file system of a PVC are expanded
+	VolumeResizePhaseResized = "Resized"
+
+	// VolumeResizePhaseNotExpandable indicates the storage class of a PVC does not allow volume expansion, and the
+	// increase of the storage capacity is skipped
+	VolumeResizePhaseNotExpandable = "NotExpandable"
+)
+
+// VolumeResizeStatus tracks the expansion of the volume of a pod, after an increase of its storage capacity
+type VolumeResizeStatus struct {
+	// Name of the pod
+	Pod string `json:"pod"`
+
+	// Name of the PVC being expanded
+	PVC string `json:"pvc"`
+
+	// Storage capacity requested for the PVC
+	RequestedCapacity string `json:"requestedCapacity"`
+
+	// Current storage capacity of the PVC
+	Capacity string `json:"capacity,omitempty"`
+
+	// Resizing, FileSystemResizePending, Resized or NotExpandable
+	Phase string `json:"phase"`
+}
+
+const (
+	// VolumeSnapshotQuiesceMaintenanceMode puts the indexer cluster in maintenance mode while the snapshots are taken
+	VolumeSnapshotQuiesceMaintenanceMode = "MaintenanceMode"
+
+	// VolumeSnapshotQuiesceRollHotBuckets rolls the hot buckets of the indexes of the pods before the snapshots are taken
+	VolumeSnapshotQuiesceRollHotBuckets = "RollHotBuckets"
+
+	// VolumeSnapshotQuiesceNone takes the snapshots without quiescing the pods
+	VolumeSnapshotQuiesceNone = "None"
+)
+
+// VolumeSnapshotSpec defines the scheduled CSI snapshots of the etc and var volumes of the pods
+type VolumeSnapshotSpec struct {
+	// Schedule of the snapshot sets, in the standard 5 field cron format. No snapshot is taken if empty
+	// +optional
+	Schedule string `json:"schedule,omitempty"`
+
+	// Time zone of the schedule, e.g. America/Los_Angeles. Defaults to UTC
+	// +optional
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// VolumeSnapshotClass of the snapshots. Defaults to the default VolumeSnapshotClass of the CSI driver
+	// +optional
+	VolumeSnapshotClassName string `json:"volumeSnapshotClassName,omitempty"`
+
+	// Quiescing of the pods while the snapshots are taken: MaintenanceMode, RollHotBuckets or None. Defaults to
+	// MaintenanceMode for an indexer cluster, and RollHotBuckets otherwise
+	// +optional
+	Quiesce string `json:"quiesce,omitempty"`
+
+	// Maximum number of snapshot sets kept. All the snapshot sets are kept if 0
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	MaxSnapshotSets int32 `json:"maxSnapshotSets,omitempty"`
+
+	// Snapshot set the volumes of the pods are provisioned from when the custom resource is created. The pods without
+	// a snapshot in the set are provisioned with empty volumes
+	// +optional
+	RestoreFrom string `json:"restoreFrom,omitempty"`
+}
+
+// VolumeSnapshotSetInfo represents the snapshots of the etc and var volumes of the pods, taken at the same time
+type VolumeSnapshotSetInfo struct {
+	// Name of the snapshot set, the prefix of the names of its VolumeSnapshots
+	Name string `json:"name"`
+
+	// Time when the snapshot set was taken, in Unix epoch seconds
+	Time int64 `json:"time"`
+
+	// Number of pods whose volumes are snapshotted
+	Pods int32 `json:"pods"`
+
+	// Volumes snapshotted on each pod, etc and var
+	Volumes []string `json:"volumes,omitempty"`
+}
+
+// VolumeSnapshotStatus tracks the snapshot sets of the volumes of the pods
+type VolumeSnapshotStatus struct {
+	// Phase of the last snapshot set: InProgress, Completed or Failed
+	Phase BackupPhase `json:"phase,omitempty"`
+
+	// Snapshot set in progress
+	CurrentSet *VolumeSnapshotSetInfo `json:"currentSet,omitempty"`
+
+	// Indicates if the pods are quiesced for the snapshot set in progress
+	Quiesced bool `json:"quiesced,omitempty"`
+
+	// Time when the last snapshot set started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the last snapshot set completed, in Unix epoch seconds
+	LastSnapshotTime int64 `json:"lastSnapshotTime,omitempty"`
+
+	// Time of the next scheduled snapshot set, in Unix epoch seconds
+	NextSnapshotTime int64 `json:"nextSnapshotTime,omitempty"`
+
+	// Snapshot sets kept, the latest last
+	SnapshotSets []VolumeSnapshotSetInfo `json:"snapshotSets,omitempty"`
+
+	// Snapshot set the volumes of the pods were provisioned from
+	RestoredFrom string `json:"restoredFrom,omitempty"`
+
+	// Auxillary message describing the last snapshot set
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradePolicySpec defines the checks applied before upgrading the Splunk Enterprise version of the instances
+type UpgradePolicySpec struct {
+	// Enable the upgrade policy. The upgrade is held until the version hop is supported, the preflight checks pass
+	// and, if required, the upgrade is approved
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// Supported version hops, in <from>:<to> format with major.minor versions, e.g. 9.0:9.1. Defaults to the
+	// upgrade paths supported by Splunk Enterprise. Patch upgrades within a major.minor version are always supported
+	// +optional
+	// +listType=set
+	SupportedHops []string `json:"supportedHops,omitempty"`
+
+	// Preflight checks run before the upgrade: ClusterHealth, ReplicationFactor, BundlePush and KVStore. Defaults to
+	// all the checks. The checks which do not apply to the instance are skipped
+	// +optional
+	// +listType=set
+	PreflightChecks []string `json:"preflightChecks,omitempty"`
+
+	// Pause the upgrade once the tiers the instance depends on are upgraded, until it is approved with the
+	// enterprise.splunk.com/upgrade-approved annotation set to the target version
+	// +optional
+	PauseForApproval bool `json:"pauseForApproval,omitempty"`
+
+	// Revert the instances to the last known good image when their pods are not ready with a new image within the
+	// health deadline. The rollback applies whether or not the upgrade policy is enabled
+	// +optional
+	AutoRollback bool `json:"autoRollback,omitempty"`
+
+	// Time allowed for the pods to be ready with a new image before the rollback, in seconds. Defaults to 1800
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	HealthDeadlineSeconds int32 `json:"healthDeadlineSeconds,omitempty"`
+}
+
+const (
+	// UpgradePreflightClusterHealth checks all the peers of the indexer cluster are up
+	UpgradePreflightClusterHealth = "ClusterHealth"
+
+	// UpgradePreflightReplicationFactor checks the replication and search factors of the indexer cluster are met
+	UpgradePreflightReplicationFactor = "ReplicationFactor"
+
+	// UpgradePreflightBundlePush checks no bundle push is pending on the cluster manager
+	UpgradePreflightBundlePush = "BundlePush"
+
+	// UpgradePreflightKVStore checks the KV store of the instance is ready
+	UpgradePreflightKVStore = "KVStore"
+)
+
+const (
+	// UpgradePhaseBlocked indicates the version hop of the upgrade is not supported
+	UpgradePhaseBlocked = "Blocked"
+
+	// UpgradePhaseWaiting indicates the upgrade waits for the tiers the instance depends on
+	UpgradePhaseWaiting = "Waiting"
+
+	// UpgradePhasePreflightFailed indicates a preflight check of the upgrade failed
+	UpgradePhasePreflightFailed = "PreflightFailed"
+
+	// UpgradePhaseAwaitingApproval indicates the upgrade is paused until approved
+	UpgradePhaseAwaitingApproval = "AwaitingApproval"
+
+	// UpgradePhaseUpgrading indicates the instances are being upgraded
+	UpgradePhaseUpgrading = "Upgrading"
+
+	// UpgradePhaseComplete indicates the instances are upgraded
+	UpgradePhaseComplete = "Complete"
+
+	// UpgradePhaseRolledBack indicates the instances are reverted to the last known good image, after a failed upgrade
+	UpgradePhaseRolledBack = "RolledBack"
+)
+
+const (
+	// LastKnownGoodImageAnnotation records the last image the instances were ready with
+	LastKnownGoodImageAnnotation = "enterprise.splunk.com/last-known-good-image"
+
+	// ConditionUpgradeFailed indicates the last upgrade of the instances failed its health checks
+	ConditionUpgradeFailed = "UpgradeFailed"
+)
+
+// UpgradeStatus tracks the upgrade of the Splunk Enterprise version of the instances
+type UpgradeStatus struct {
+	// Blocked, Waiting, PreflightFailed, AwaitingApproval, Upgrading, Complete or RolledBack
+	Phase string `json:"phase,omitempty"`
+
+	// Image the instances are upgraded from
+	SourceImage string `json:"sourceImage,omitempty"`
+
+	// Image the instances are upgraded to
+	TargetImage string `json:"targetImage,omitempty"`
+
+	// Splunk version the instances are upgraded from
+	SourceVersion string `json:"sourceVersion,omitempty"`
+
+	// Splunk version the instances are upgraded to
+	TargetVersion string `json:"targetVersion,omitempty"`
+
+	// Tiers upgraded in order, ending with the tier of the instance
+	Plan []UpgradeTierStatus `json:"plan,omitempty"`
+
+	// Results of the preflight checks
+	PreflightChecks []UpgradePreflightCheckStatus `json:"preflightChecks,omitempty"`
+
+	// Time when the upgrade started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the upgrade completed, in Unix epoch seconds
+	CompletionTime int64 `json:"completionTime,omitempty"`
+
+	// Auxillary message describing the upgrade
+	Message string `json:"message,omitempty"`
+}
+
+// UpgradeTierStatus tracks the upgrade of a tier of the deployment
+type UpgradeTierStatus struct {
+	// Kind of the tier, e.g. LicenseManager or ClusterManager
+	Tier string `json:"tier"`
+
+	// Name of the custom resource of the tier
+	Name string `json:"name"`
+
+	// Pending, Upgrading or Upgraded for the tiers the instance depends on, the upgrade phase for the instance
+	Phase string `json:"phase"`
+}
+
+// UpgradePreflightCheckStatus is the result of a preflight check of an upgrade
+type UpgradePreflightCheckStatus struct {
+	// Name of the check
+	Name string `json:"name"`
+
+	// Indicates the check passed
+	Passed bool `json:"passed"`
+
+	// Reason the check failed
+	Message string `json:"message,omitempty"`
+}
@@ -40,8 +40,130 @@ type IndexerClusterSpec struct {
 
 	// Number of search head pods; a search head cluster will be created if > 1
 	Replicas int32 `json:"replicas"`
+
+	// Strategy used to update the indexer cluster peers for image or spec changes
+	// +optional
+	UpdateStrategy IndexerClusterUpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// Handling of the peer decommission during scale down
+	// +optional
+	ScaleDown IndexerClusterScaleDownSpec `json:"scaleDown,omitempty"`
+
+	// Load metrics and scaling guards for the autoscaling of the indexer cluster
+	// +optional
+	Autoscaling AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// Sites of a multisite indexer cluster, with a StatefulSet per site. When set, replicas is ignored
+	// +listType=map
+	// +listMapKey=name
+	// +optional
+	Sites []IndexerClusterSiteSpec `json:"sites,omitempty"`
+
+	// Scheduled CSI snapshots of the etc and var volumes of the indexer pods. Not supported by a multisite indexer cluster with sites
+	// +optional
+	Snapshots VolumeSnapshotSpec `json:"snapshots,omitempty"`
+}
+
+// IndexerClusterSiteSpec defines the peers of a site of a multisite indexer cluster
+type IndexerClusterSiteSpec struct {
+	// Name of the site, as configured on the cluster manager, e.g. site1
+	// +kubebuilder:validation:Pattern=`^site[1-9][0-9]*$`
+	Name string `json:"name"`
+
+	// Number of peers of the site
+	// +kubebuilder:validation:Minimum:=1
+	Replicas int32 `json:"replicas"`
+
+	// Availability zone of the site peers, matched against the topology.kubernetes.io/zone label of the nodes
+	// +optional
+	Zone string `json:"zone,omitempty"`
+
+	// Labels of the nodes the site peers are scheduled on, in addition to the zone
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Resources of the site peers. Defaults to the resources of the indexer cluster
+	// +optional
+	Resources *corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+const (
+	// DecommissionTimeoutPolicyWait keeps waiting for the decommission after the timeout
+	DecommissionTimeoutPolicyWait = "wait"
+
+	// DecommissionTimeoutPolicyForceRemove takes the peer offline after the timeout, and removes it from the cluster once it is down,
+	// even if the decommission is not complete
+	DecommissionTimeoutPolicyForceRemove = "forceRemove"
+
+	// DecommissionTimeoutPolicyAbortAndRestore restarts the peer after the timeout, to bring it back to the cluster, and aborts the scale down
+	DecommissionTimeoutPolicyAbortAndRestore = "abortAndRestore"
+)
+
+// IndexerClusterScaleDownSpec defines the handling of the peer decommission during scale down
+type IndexerClusterScaleDownSpec struct {
+	// Time to wait for the decommission of a peer, e.g. 2h. When not set, the decommission is waited for indefinitely,
+	// and an unexpected peer status fails the reconcile
+	// +optional
+	DecommissionTimeout *metav1.Duration `json:"decommissionTimeout,omitempty"`
+
+	// Action once the decommission timeout is crossed. wait keeps waiting, forceRemove takes the peer offline and removes it even if the
+	// bucket fixups are not complete, and abortAndRestore brings the peer back and aborts the scale down, until the
+	// replicas are changed
+	// +kubebuilder:validation:Enum=wait;forceRemove;abortAndRestore
+	// +kubebuilder:default:=wait
+	// +optional
+	Policy string `json:"policy,omitempty"`
 }
 
+const (
+	// RecycleUpdateStrategy decommissions and recycles the peers one at a time
+	RecycleUpdateStrategy = "Recycle"
+
+	// RollingUpgradeUpdateStrategy recycles the peers in batches, using the rolling upgrade workflow of the cluster manager
+	RollingUpgradeUpdateStrategy = "RollingUpgrade"
+)
+
+// IndexerClusterUpdateStrategy defines how the indexer cluster peers are updated
+type IndexerClusterUpdateStrategy struct {
+	// Type of the update strategy. Recycle decommissions and recycles the peers one at a time. RollingUpgrade
+	// recycles the peers in batches through the rolling upgrade workflow of the cluster manager
+	// +kubebuilder:validation:Enum=Recycle;RollingUpgrade
+	// +kubebuilder:default:=Recycle
+	Type string `json:"type,omitempty"`
+
+	// Keep the data searchable while the peers are updated. When enabled, the peers are taken offline with
+	// the searchable rolling upgrade of the cluster manager. Otherwise, the cluster manager is put in
+	// maintenance mode, and the peers are restarted without taking them offline
+	// +optional
+	Searchable bool `json:"searchable,omitempty"`
+
+	// Percentage of the peers updated at a time. Defaults to 10
+	// +kubebuilder:validation:Minimum:=1
+	// +kubebuilder:validation:Maximum:=100
+	// +optional
+	PercentPeersToRestart int32 `json:"percentPeersToRestart,omitempty"`
+
+	// Update the sites one at a time. Applies to the multisite indexer clusters, with an IndexerCluster per site
+	// +optional
+	SiteBySite bool `json:"siteBySite,omitempty"`
+
+	// Order of the sites to update, when updating site by site. Sites not listed are updated last, in the
+	// alphabetical order
+	// +optional
+	SiteOrder []string `json:"siteOrder,omitempty"`
+}
+
+const (
+	// UpdatePhasePending indicates the peer update is waiting for the other sites
+	UpdatePhasePending = "Pending"
+
+	// UpdatePhaseInProgress indicates the peers are being updated
+	UpdatePhaseInProgress = "InProgress"
+
+	// UpdatePhaseComplete indicates all the peers are updated
+	UpdatePhaseComplete = "Complete"
+)
+
 // IndexerClusterMemberStatus is used to track the status of each indexer cluster peer.
 type IndexerClusterMemberStatus struct {
 	// Unique identifier or GUID for the peer
@@ -111,6 +233,190 @@ type IndexerClusterStatus struct {
 
 	// Auxillary message describing CR status
 	Message string `json:"message"`
+
+	// progress of the indexer cluster peer updates
+	// +optional
+	UpdateStatus IndexerClusterUpdateStatus `json:"updateStatus,omitempty"`
+
+	// progress of the peer decommission during scale down
+	// +optional
+	DecommissionStatus IndexerClusterDecommissionStatus `json:"decommissionStatus,omitempty"`
+
+	// replicas changes of the indexer cluster
+	// +optional
+	AutoscalingStatus AutoscalingStatus `json:"autoscalingStatus,omitempty"`
+
+	// status of each site of a multisite indexer cluster
+	// +optional
+	Sites []IndexerClusterSiteStatus `json:"sites,omitempty"`
+
+	// progress of the migration of the peer var volumes to a new storage class
+	// +optional
+	StorageMigration IndexerClusterStorageMigrationStatus `json:"storageMigration,omitempty"`
+
+	// progress of the volume expansion of the indexer pods, after an increase of their storage capacity
+	// +optional
+	VolumeResize []VolumeResizeStatus `json:"volumeResize,omitempty"`
+
+	// snapshot sets of the volumes of the indexer pods
+	// +optional
+	Snapshots VolumeSnapshotStatus `json:"snapshots,omitempty"`
+
+	// upgrade of the Splunk Enterprise version of the indexer cluster peers, under the upgrade policy
+	// +optional
+	Upgrade UpgradeStatus `json:"upgrade,omitempty"`
+
+	// conditions of the custom resource, e.g. UpgradeFailed
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+}
+
+// IndexerClusterSiteStatus defines the observed state of a site of a multisite indexer cluster
+type IndexerClusterSiteStatus struct {
+	// Name of the site
+	Name string `json:"name"`
+
+	// current phase of the site peers
+	Phase Phase `json:"phase"`
+
+	// desired number of peers of the site
+	Replicas int32 `json:"replicas"`
+
+	// current number of ready peers of the site
+	ReadyReplicas int32 `json:"readyReplicas"`
+
+	// number of peers of the site which are up on the cluster manager
+	UpPeers int32 `json:"upPeers"`
+
+	// number of buckets on the site, across all the peers and indexes
+	BucketCount int64 `json:"bucketCount"`
+
+	// Indicates when the idxc_secret has been changed for a peer of the site
+	// +optional
+	IndexerSecretChanged []bool `json:"indexerSecretChanged,omitempty"`
+
+	// progress of the site peer updates
+	// +optional
+	UpdateStatus IndexerClusterUpdateStatus `json:"updateStatus,omitempty"`
+
+	// progress of the site peer decommission during scale down
+	// +optional
+	DecommissionStatus IndexerClusterDecommissionStatus `json:"decommissionStatus,omitempty"`
+
+	// replicas changes of the site
+	// +optional
+	AutoscalingStatus AutoscalingStatus `json:"autoscalingStatus,omitempty"`
+
+	// progress of the migration of the site peer var volumes to a new storage class
+	// +optional
+	StorageMigration IndexerClusterStorageMigrationStatus `json:"storageMigration,omitempty"`
+}
+
+// IndexerClusterDecommissionStatus tracks the decommission of a peer during scale down
+type IndexerClusterDecommissionStatus struct {
+	// Name of the peer being decommissioned
+	Peer string `json:"peer,omitempty"`
+
+	// Time when the decommission started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time since the decommission started, in seconds
+	ElapsedSeconds int64 `json:"elapsedSeconds,omitempty"`
+
+	// Number of buckets pending replication or search factor fixup on the cluster manager
+	RemainingBucketFixups int64 `json:"remainingBucketFixups,omitempty"`
+
+	// Indicates the decommission timeout is crossed
+	TimedOut bool `json:"timedOut,omitempty"`
+
+	// Indicates the peer is taken offline by the forceRemove policy, to be removed once it is down
+	Offline bool `json:"offline,omitempty"`
+
+	// Replicas of the aborted scale down. The scale down is retried once the replicas are changed
+	AbortedReplicas int32 `json:"abortedReplicas,omitempty"`
+
+	// Auxillary message describing the decommission
+	Message string `json:"message,omitempty"`
+}
+
+const (
+	// StorageMigrationStepDecommissioning indicates the peer is decommissioned, to evacuate its data
+	StorageMigrationStepDecommissioning = "Decommissioning"
+
+	// StorageMigrationStepReplacingVolume indicates the var volume of the peer is recreated with the new storage class
+	StorageMigrationStepReplacingVolume = "ReplacingVolume"
+
+	// StorageMigrationStepRejoining indicates the peer is rejoining the cluster
+	StorageMigrationStepRejoining = "Rejoining"
+
+	// StorageMigrationStepReplicating indicates the replication and search factors are being met again
+	StorageMigrationStepReplicating = "Replicating"
+)
+
+// IndexerClusterStorageMigrationStatus tracks the migration of the peer var volumes to a new storage class, one
+// peer at a time
+type IndexerClusterStorageMigrationStatus struct {
+	// InProgress or Complete
+	Phase string `json:"phase,omitempty"`
+
+	// Storage class the var volumes are migrated to
+	StorageClassName string `json:"storageClassName,omitempty"`
+
+	// Peer being migrated
+	Peer string `json:"peer,omitempty"`
+
+	// Decommissioning, ReplacingVolume, Rejoining or Replicating
+	Step string `json:"step,omitempty"`
+
+	// Peers migrated to the storage class
+	MigratedPeers []string `json:"migratedPeers,omitempty"`
+
+	// Number of buckets pending replication or search factor fixup on the cluster manager
+	RemainingBucketFixups int64 `json:"remainingBucketFixups,omitempty"`
+
+	// Time when the migration started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the migration completed, in Unix epoch seconds
+	CompletionTime int64 `json:"completionTime,omitempty"`
+
+	// Auxillary message describing the migration
+	Message string `json:"message,omitempty"`
+}
+
+// IndexerClusterUpdateStatus tracks the progress of the indexer cluster peer updates
+type IndexerClusterUpdateStatus struct {
+	// Update strategy used for the current update
+	Strategy string `json:"strategy,omitempty"`
+
+	// Pending, InProgress or Complete
+	Phase string `json:"phase,omitempty"`
+
+	// StatefulSet revision the peers are updated to
+	Revision string `json:"revision,omitempty"`
+
+	// Indicates the peers are updated in the searchable mode
+	Searchable bool `json:"searchable,omitempty"`
+
+	// Number of the peers updated to the revision
+	UpdatedPeers int32 `json:"updatedPeers,omitempty"`
+
+	// Number of the peers
+	TotalPeers int32 `json:"totalPeers,omitempty"`
+
+	// Peers in the current batch
+	RestartingPeers []string `json:"restartingPeers,omitempty"`
+
+	// Time when the update started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the update completed, in Unix epoch seconds
+	CompletionTime int64 `json:"completionTime,omitempty"`
+
+	// Auxillary message describing the update
+	Message string `json:"message,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -40,6 +40,139 @@ type LicenseManagerSpec struct {
 
 	// Splunk enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// Licenses installed on the license manager, in addition to licenseUrl. Licenses of the same stack are stacked
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Licenses []LicenseSource `json:"licenses,omitempty"`
+
+	// License pools created on the license manager
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Pools []LicensePoolSpec `json:"pools,omitempty"`
+
+	// Number of days before the expiration of a license when a warning event is published. Defaults to 30
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	LicenseExpiryWarningDays int32 `json:"licenseExpiryWarningDays,omitempty"`
+}
+
+// LicenseSource defines the Secret or ConfigMap key holding a license file
+type LicenseSource struct {
+	// Name of the license, used to report its status
+	Name string `json:"name"`
+
+	// Key of a Secret with the license file
+	// +optional
+	SecretRef *corev1.SecretKeySelector `json:"secretRef,omitempty"`
+
+	// Key of a ConfigMap with the license file
+	// +optional
+	ConfigMapRef *corev1.ConfigMapKeySelector `json:"configMapRef,omitempty"`
+}
+
+// LicensePoolSpec defines a license pool of the license manager
+type LicensePoolSpec struct {
+	// Name of the pool
+	Name string `json:"name"`
+
+	// Stack of the pool. Defaults to enterprise
+	// +optional
+	StackID string `json:"stack,omitempty"`
+
+	// Daily indexing volume allocated to the pool, e.g. 100Gi, or MAX for the whole volume of the stack. Defaults
+	// to MAX
+	// +optional
+	Quota string `json:"quota,omitempty"`
+
+	// Description of the pool
+	// +optional
+	Description string `json:"description,omitempty"`
+
+	// Custom resources, in the namespace of the license manager, whose instances are assigned to the pool. Any
+	// license peer of the stack can use the pool when empty
+	// +optional
+	Members []LicensePoolMember `json:"members,omitempty"`
+}
+
+// LicensePoolMember references a custom resource whose instances are assigned to a license pool
+type LicensePoolMember struct {
+	// Kind of the custom resource
+	// +kubebuilder:validation:Enum=Standalone;IndexerCluster;ClusterManager;SearchHeadCluster;MonitoringConsole
+	Kind string `json:"kind"`
+
+	// Name of the custom resource
+	Name string `json:"name"`
+}
+
+// LicenseStatus defines the status of a license installed on the license manager
+type LicenseStatus struct {
+	// name of the license in spec.licenses, empty for the licenses not installed from spec.licenses
+	// +optional
+	Name string `json:"name,omitempty"`
+
+	// hash of the license on the license manager
+	Hash string `json:"hash"`
+
+	// digest of the license file, used to detect a change of the file
+	// +optional
+	Digest string `json:"digest,omitempty"`
+
+	// label of the license
+	Label string `json:"label,omitempty"`
+
+	// stack of the license
+	StackID string `json:"stack,omitempty"`
+
+	// status of the license, VALID or EXPIRED
+	Status string `json:"status,omitempty"`
+
+	// daily indexing volume of the license, in bytes
+	QuotaBytes int64 `json:"quotaBytes,omitempty"`
+
+	// expiration time of the license, in seconds since epoch
+	ExpirationTime int64 `json:"expirationTime,omitempty"`
+
+	// true when the license expires within the expiry warning days
+	Expiring bool `json:"expiring,omitempty"`
+}
+
+// LicensePoolStatus defines the daily usage of a license pool
+type LicensePoolStatus struct {
+	// name of the pool
+	Name string `json:"name"`
+
+	// stack of the pool
+	StackID string `json:"stack,omitempty"`
+
+	// true for the pools of spec.pools
+	Managed bool `json:"managed,omitempty"`
+
+	// daily indexing volume allocated to the pool, in bytes
+	QuotaBytes int64 `json:"quotaBytes,omitempty"`
+
+	// indexing volume used by the pool today, in bytes
+	UsedBytes int64 `json:"usedBytes,omitempty"`
+
+	// license peers assigned to the pool, or * for any peer of the stack
+	Peers []string `json:"peers,omitempty"`
+}
+
+// LicenseStackStatus defines the daily usage of a license stack
+type LicenseStackStatus struct {
+	// name of the stack
+	Name string `json:"name"`
+
+	// daily indexing volume of the stack, in bytes
+	QuotaBytes int64 `json:"quotaBytes,omitempty"`
+
+	// indexing volume used by the pools of the stack today, in bytes
+	UsedBytes int64 `json:"usedBytes,omitempty"`
+
+	// number of license violation warnings of the stack and its pools
+	Violations int32 `json:"violations,omitempty"`
 }
 
 // LicenseManagerStatus defines the observed state of a Splunk Enterprise license manager.
@@ -55,6 +188,36 @@ type LicenseManagerStatus struct {
 
 	// Auxillary message describing CR status
 	Message string `json:"message"`
+
+	// progress of the volume expansion of the license manager pods, after an increase of their storage capacity
+	// +optional
+	VolumeResize []VolumeResizeStatus `json:"volumeResize,omitempty"`
+
+	// upgrade of the Splunk Enterprise version of the license manager, under the upgrade policy
+	// +optional
+	Upgrade UpgradeStatus `json:"upgrade,omitempty"`
+
+	// licenses installed on the license manager
+	// +optional
+	Licenses []LicenseStatus `json:"licenses,omitempty"`
+
+	// daily usage of the license pools
+	// +optional
+	Pools []LicensePoolStatus `json:"pools,omitempty"`
+
+	// daily usage and violations of the license stacks
+	// +optional
+	Stacks []LicenseStackStatus `json:"stacks,omitempty"`
+
+	// time of the last check of the licenses, in seconds since epoch
+	// +optional
+	LicenseCheckTime int64 `json:"licenseCheckTime,omitempty"`
+
+	// conditions of the custom resource, e.g. UpgradeFailed
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
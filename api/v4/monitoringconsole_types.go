@@ -40,6 +40,75 @@ type MonitoringConsoleSpec struct {
 
 	// Splunk Enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// platform alerts enabled on the monitoring console, with their thresholds. Platform alerts removed from the list
+	// are disabled
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Alerts []MonitoringConsoleAlert `json:"alerts,omitempty"`
+
+	// forwarder monitoring of the monitoring console
+	// +optional
+	ForwarderMonitoring MonitoringConsoleForwarderMonitoring `json:"forwarderMonitoring,omitempty"`
+
+	// custom groups of the monitoring console, whose members are the instances of the Splunk custom resources selected
+	// by label
+	// +optional
+	// +listType=map
+	// +listMapKey=name
+	Groups []MonitoringConsoleGroup `json:"groups,omitempty"`
+}
+
+// MonitoringConsoleAlert defines a platform alert of the monitoring console
+type MonitoringConsoleAlert struct {
+	// name of the platform alert, e.g. Near Critical Disk Usage or Missing Forwarders
+	Name string `json:"name"`
+
+	// thresholds of the platform alert, set as the arguments of its search, e.g. disk_usage_threshold: "80"
+	// +optional
+	Thresholds map[string]string `json:"thresholds,omitempty"`
+}
+
+// MonitoringConsoleForwarderMonitoring defines the forwarder monitoring of the monitoring console
+type MonitoringConsoleForwarderMonitoring struct {
+	// enables the forwarder monitoring, which builds the forwarder asset table periodically
+	// +optional
+	Enabled bool `json:"enabled,omitempty"`
+
+	// cron schedule of the build of the forwarder asset table
+	// +optional
+	// +kubebuilder:default:="3,18,33,48 * * * *"
+	BuildSchedule string `json:"buildSchedule,omitempty"`
+
+	// selects the Standalone custom resources acting as heavy forwarders, which are grouped in the heavy_forwarders
+	// custom group
+	// +optional
+	HeavyForwarderSelector *metav1.LabelSelector `json:"heavyForwarderSelector,omitempty"`
+}
+
+// MonitoringConsoleGroup defines a custom group of the monitoring console
+type MonitoringConsoleGroup struct {
+	// name of the custom group
+	// +kubebuilder:validation:Pattern=`^[a-zA-Z0-9_-]+$`
+	Name string `json:"name"`
+
+	// selects the Splunk custom resources of the namespace whose instances are members of the group
+	Selector metav1.LabelSelector `json:"selector"`
+
+	// kinds of the selected custom resources, all the kinds when empty
+	// +optional
+	Kinds []string `json:"kinds,omitempty"`
+}
+
+// MonitoringConsoleGroupStatus defines the observed state of a custom group of the monitoring console
+type MonitoringConsoleGroupStatus struct {
+	// name of the custom group
+	Name string `json:"name"`
+
+	// distributed search peers in the group
+	// +optional
+	Members []string `json:"members,omitempty"`
 }
 
 // MonitoringConsoleStatus defines the observed state of MonitoringConsole
@@ -61,6 +130,32 @@ type MonitoringConsoleStatus struct {
 
 	// Auxillary message describing CR status
 	Message string `json:"message"`
+
+	// progress of the volume expansion of the monitoring console pods, after an increase of their storage capacity
+	// +optional
+	VolumeResize []VolumeResizeStatus `json:"volumeResize,omitempty"`
+
+	// upgrade of the Splunk Enterprise version of the monitoring console, under the upgrade policy
+	// +optional
+	Upgrade UpgradeStatus `json:"upgrade,omitempty"`
+
+	// platform alerts enabled by the operator
+	// +optional
+	Alerts []string `json:"alerts,omitempty"`
+
+	// true when the forwarder monitoring is enabled by the operator
+	// +optional
+	ForwarderMonitoring bool `json:"forwarderMonitoring,omitempty"`
+
+	// custom groups configured by the operator
+	// +optional
+	Groups []MonitoringConsoleGroupStatus `json:"groups,omitempty"`
+
+	// conditions of the custom resource, e.g. UpgradeFailed
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -44,6 +44,67 @@ type SearchHeadClusterSpec struct {
 
 	// Splunk Enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// Load metrics and scaling guards for the autoscaling of the search head cluster
+	// +optional
+	Autoscaling AutoscalingSpec `json:"autoscaling,omitempty"`
+
+	// Strategy used to restart and update the search head cluster members
+	// +optional
+	UpdateStrategy SearchHeadClusterUpdateStrategy `json:"updateStrategy,omitempty"`
+
+	// Checks run before pushing the deployer bundle to the members
+	// +optional
+	BundlePushPolicy BundlePushPolicySpec `json:"bundlePushPolicy,omitempty"`
+
+	// Scheduled CSI snapshots of the etc and var volumes of the search head pods
+	// +optional
+	Snapshots VolumeSnapshotSpec `json:"snapshots,omitempty"`
+}
+
+const (
+	// SearchHeadClusterUpdateDetention detains the members one at a time, and waits for their searches to drain
+	// before they are recycled
+	SearchHeadClusterUpdateDetention = "Detention"
+
+	// SearchHeadClusterUpdateRollingRestart coordinates the restarts and the updates of the members with the captain
+	SearchHeadClusterUpdateRollingRestart = "RollingRestart"
+)
+
+const (
+	// SearchHeadMemberTransferringCaptaincy indicates the captaincy is transferred away from the member
+	SearchHeadMemberTransferringCaptaincy = "TransferringCaptaincy"
+
+	// SearchHeadMemberDraining indicates the searches of the member are draining
+	SearchHeadMemberDraining = "Draining"
+
+	// SearchHeadMemberRestarting indicates the member is restarted or recycled
+	SearchHeadMemberRestarting = "Restarting"
+)
+
+// SearchHeadClusterUpdateStrategy defines how the search head cluster members are restarted and updated
+type SearchHeadClusterUpdateStrategy struct {
+	// Detention (default) recycles the members one at a time after detaining them. RollingRestart restarts the
+	// members with the rolling restart of the captain, when they require a restart, and transfers the captaincy
+	// before the captain is recycled
+	// +optional
+	// +kubebuilder:validation:Enum=Detention;RollingRestart
+	Type string `json:"type,omitempty"`
+
+	// Searchable rolling restart: the members keep running their searches, up to the drain timeout, before they
+	// are restarted. Without it, the members are restarted without waiting for their searches
+	// +optional
+	Searchable bool `json:"searchable,omitempty"`
+
+	// Member the captaincy is transferred to before the captain is restarted, e.g. splunk-example-search-head-0.
+	// Defaults to the first other member which is up
+	// +optional
+	CaptainTransferTarget string `json:"captainTransferTarget,omitempty"`
+
+	// Time allowed for the searches of a member to drain before it is restarted, in seconds. Defaults to 180
+	// +optional
+	// +kubebuilder:validation:Minimum=0
+	DrainTimeoutSeconds int32 `json:"drainTimeoutSeconds,omitempty"`
 }
 
 // SearchHeadClusterMemberStatus is used to track the status of each search head cluster member
@@ -65,6 +126,22 @@ type SearchHeadClusterMemberStatus struct {
 
 	// Number of currently running realtime searches.
 	ActiveRealtimeSearchCount int `json:"active_realtime_search_count"`
+
+	// Indicates the member needs a restart, e.g. to apply a configuration change
+	// +optional
+	RestartRequired bool `json:"restartRequired,omitempty"`
+
+	// Progress of the restart or update of the member: TransferringCaptaincy, Draining or Restarting
+	// +optional
+	UpdatePhase string `json:"updatePhase,omitempty"`
+
+	// Time when the searches of the member started to drain, in Unix epoch seconds
+	// +optional
+	DrainStartTime int64 `json:"drainStartTime,omitempty"`
+
+	// Elapsed time of the last drain of the searches of the member, in seconds
+	// +optional
+	DrainSeconds int64 `json:"drainSeconds,omitempty"`
 }
 
 // SearchHeadClusterStatus defines the observed state of a Splunk Enterprise search head cluster
@@ -99,6 +176,10 @@ type SearchHeadClusterStatus struct {
 	// true if the search head cluster is in maintenance mode
 	MaintenanceMode bool `json:"maintenanceMode"`
 
+	// true if the captain is restarting the search head cluster members
+	// +optional
+	RollingRestart bool `json:"rollingRestart,omitempty"`
+
 	// Indicates when the shc_secret has been changed for a peer
 	ShcSecretChanged []bool `json:"shcSecretChangedFlag"`
 
@@ -122,6 +203,32 @@ type SearchHeadClusterStatus struct {
 
 	// Auxillary message describing CR status
 	Message string `json:"message"`
+
+	// replicas changes of the search head cluster
+	// +optional
+	AutoscalingStatus AutoscalingStatus `json:"autoscalingStatus,omitempty"`
+
+	// progress of the volume expansion of the search head pods, after an increase of their storage capacity
+	// +optional
+	VolumeResize []VolumeResizeStatus `json:"volumeResize,omitempty"`
+
+	// progress of the volume expansion of the deployer pod, after an increase of its storage capacity
+	// +optional
+	DeployerVolumeResize []VolumeResizeStatus `json:"deployerVolumeResize,omitempty"`
+
+	// snapshot sets of the volumes of the search head pods
+	// +optional
+	Snapshots VolumeSnapshotStatus `json:"snapshots,omitempty"`
+
+	// upgrade of the Splunk Enterprise version of the search head cluster, under the upgrade policy
+	// +optional
+	Upgrade UpgradeStatus `json:"upgrade,omitempty"`
+
+	// conditions of the custom resource, e.g. UpgradeFailed
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // SearchHeadCluster is the Schema for a Splunk Enterprise search head cluster
@@ -0,0 +1,189 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v4
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// BackupPhase is the phase of a backup or a restore
+type BackupPhase string
+
+const (
+	// BackupPhasePending means the backup or the restore is waiting for its target or its schedule
+	BackupPhasePending BackupPhase = "Pending"
+
+	// BackupPhaseInProgress means the backup or the restore is running
+	BackupPhaseInProgress BackupPhase = "InProgress"
+
+	// BackupPhaseCompleted means the last backup or the restore completed
+	BackupPhaseCompleted BackupPhase = "Completed"
+
+	// BackupPhaseFailed means the last backup or the restore failed
+	BackupPhaseFailed BackupPhase = "Failed"
+)
+
+// BackupStorageSpec defines the remote storage volume of the backups
+type BackupStorageSpec struct {
+	// List of remote storage volumes
+	VolList []VolumeSpec `json:"volumes,omitempty"`
+
+	// Remote storage volume name the backups are stored on
+	VolName string `json:"volumeName,omitempty"`
+
+	// Location of the backups relative to the volume path
+	Location string `json:"location,omitempty"`
+}
+
+// BackupRetentionSpec defines how long the backups are kept on the remote storage
+type BackupRetentionSpec struct {
+	// Maximum number of backups kept. All the backups are kept if 0
+	MaxBackups int32 `json:"maxBackups,omitempty"`
+
+	// Maximum age of the backups kept, in days. The backups are kept regardless of their age if 0
+	MaxAgeDays int32 `json:"maxAgeDays,omitempty"`
+}
+
+// SplunkBackupSpec defines the desired state of the backups of a SearchHeadCluster or a Standalone
+type SplunkBackupSpec struct {
+	// SearchHeadCluster or Standalone backed up, in the namespace of the SplunkBackup
+	TargetRef corev1.ObjectReference `json:"targetRef"`
+
+	// Schedule of the backups, in the standard 5 field cron format. A single backup is taken if empty
+	Schedule string `json:"schedule,omitempty"`
+
+	// Time zone of the schedule, e.g. America/Los_Angeles. Defaults to UTC
+	TimeZone string `json:"timeZone,omitempty"`
+
+	// Remote storage the backups are uploaded to
+	BackupStorageSpec `json:",inline"`
+
+	// Retention of the backups taken by the SplunkBackup
+	Retention BackupRetentionSpec `json:"retention,omitempty"`
+}
+
+// BackupInfo represents a backup uploaded to the remote storage
+type BackupInfo struct {
+	// Name of the backup
+	Name string `json:"name"`
+
+	// Time when the backup was taken, in Unix epoch seconds
+	Time int64 `json:"time"`
+
+	// Path of the KV store archive, relative to the bucket
+	KVStoreArchive string `json:"kvStoreArchive,omitempty"`
+
+	// Path of the etc customizations archive, relative to the bucket
+	EtcArchive string `json:"etcArchive,omitempty"`
+}
+
+// SplunkBackupStatus defines the observed state of the backups of a SearchHeadCluster or a Standalone
+type SplunkBackupStatus struct {
+	// Phase of the last backup
+	Phase BackupPhase `json:"phase"`
+
+	// Name of the backup in progress
+	CurrentBackup string `json:"currentBackup,omitempty"`
+
+	// Pod the backup in progress is taken on
+	CurrentPod string `json:"currentPod,omitempty"`
+
+	// Time when the last backup started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the last backup completed, in Unix epoch seconds
+	LastBackupTime int64 `json:"lastBackupTime,omitempty"`
+
+	// Time of the next scheduled backup, in Unix epoch seconds
+	NextBackupTime int64 `json:"nextBackupTime,omitempty"`
+
+	// Backups kept on the remote storage, the latest last
+	Backups []BackupInfo `json:"backups,omitempty"`
+
+	// Auxillary message describing the last backup
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkBackup is the Schema for the KV store and etc backups of a SearchHeadCluster or a Standalone
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=splunkbackups,scope=Namespaced,shortName=splbackup
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of the last backup"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.targetRef.name",description="Custom resource backed up"
+// +kubebuilder:printcolumn:name="Last Backup",type="integer",JSONPath=".status.lastBackupTime",description="Time of the last backup"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of the backup resource"
+// +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message",description="Auxillary message describing CR status"
+// +kubebuilder:storageversion
+type SplunkBackup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SplunkBackupSpec   `json:"spec,omitempty"`
+	Status SplunkBackupStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SplunkBackupList contains a list of SplunkBackup
+type SplunkBackupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SplunkBackup `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SplunkBackup{}, &SplunkBackupList{})
+}
+
+// NewEvent creates a new event associated with the object and ready
+// to be published to the kubernetes API.
+func (backup *SplunkBackup) NewEvent(eventType, reason, message string) corev1.Event {
+	t := metav1.Now()
+	return corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: reason + "-",
+			Namespace:    backup.ObjectMeta.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "SplunkBackup",
+			Namespace:  backup.Namespace,
+			Name:       backup.Name,
+			UID:        backup.UID,
+			APIVersion: GroupVersion.String(),
+		},
+		Reason:  reason,
+		Message: message,
+		Source: corev1.EventSource{
+			Component: "splunk-backup-controller",
+		},
+		FirstTimestamp:      t,
+		LastTimestamp:       t,
+		Count:               1,
+		Type:                eventType,
+		ReportingController: "enterprise.splunk.com/backup-controller",
+	}
+}
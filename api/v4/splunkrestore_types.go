@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v4
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// default all fields to being optional
+// +kubebuilder:validation:Optional
+
+// NOTE: json tags are required.  Any new fields you add must have json tags for the fields to be serialized.
+// Add custom validation using kubebuilder tags: https://book-v1.book.kubebuilder.io/beyond_basics/generating_crd.html
+// see also https://book.kubebuilder.io/reference/markers/crd.html
+
+// SplunkRestoreSpec defines the desired state of the restore of a backup into a SearchHeadCluster or a Standalone
+type SplunkRestoreSpec struct {
+	// SearchHeadCluster or Standalone the backup is restored into, in the namespace of the SplunkRestore. The restore
+	// waits until the custom resource is created and ready
+	TargetRef corev1.ObjectReference `json:"targetRef"`
+
+	// Remote storage the backup is downloaded from
+	BackupStorageSpec `json:",inline"`
+
+	// Name of the backup to restore. Defaults to the latest backup of the location
+	BackupName string `json:"backupName,omitempty"`
+}
+
+// SplunkRestoreStatus defines the observed state of the restore of a backup
+type SplunkRestoreStatus struct {
+	// Phase of the restore
+	Phase BackupPhase `json:"phase"`
+
+	// Name of the backup restored
+	BackupName string `json:"backupName,omitempty"`
+
+	// Pod the KV store is restored on
+	RestorePod string `json:"restorePod,omitempty"`
+
+	// Time when the restore started, in Unix epoch seconds
+	StartTime int64 `json:"startTime,omitempty"`
+
+	// Time when the restore completed or failed, in Unix epoch seconds
+	CompletionTime int64 `json:"completionTime,omitempty"`
+
+	// Auxillary message describing the restore
+	Message string `json:"message,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// SplunkRestore is the Schema for the restore of a backup into a SearchHeadCluster or a Standalone
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+// +kubebuilder:resource:path=splunkrestores,scope=Namespaced,shortName=splrestore
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=".status.phase",description="Status of the restore"
+// +kubebuilder:printcolumn:name="Target",type="string",JSONPath=".spec.targetRef.name",description="Custom resource restored into"
+// +kubebuilder:printcolumn:name="Backup",type="string",JSONPath=".status.backupName",description="Backup restored"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Age of the restore resource"
+// +kubebuilder:printcolumn:name="Message",type="string",JSONPath=".status.message",description="Auxillary message describing CR status"
+// +kubebuilder:storageversion
+type SplunkRestore struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   SplunkRestoreSpec   `json:"spec,omitempty"`
+	Status SplunkRestoreStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// SplunkRestoreList contains a list of SplunkRestore
+type SplunkRestoreList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []SplunkRestore `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&SplunkRestore{}, &SplunkRestoreList{})
+}
+
+// NewEvent creates a new event associated with the object and ready
+// to be published to the kubernetes API.
+func (restore *SplunkRestore) NewEvent(eventType, reason, message string) corev1.Event {
+	t := metav1.Now()
+	return corev1.Event{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: reason + "-",
+			Namespace:    restore.ObjectMeta.Namespace,
+		},
+		InvolvedObject: corev1.ObjectReference{
+			Kind:       "SplunkRestore",
+			Namespace:  restore.Namespace,
+			Name:       restore.Name,
+			UID:        restore.UID,
+			APIVersion: GroupVersion.String(),
+		},
+		Reason:  reason,
+		Message: message,
+		Source: corev1.EventSource{
+			Component: "splunk-restore-controller",
+		},
+		FirstTimestamp:      t,
+		LastTimestamp:       t,
+		Count:               1,
+		Type:                eventType,
+		ReportingController: "enterprise.splunk.com/restore-controller",
+	}
+}
@@ -46,6 +46,10 @@ type StandaloneSpec struct {
 
 	// Splunk Enterprise App repository. Specifies remote App location and scope for Splunk App management
 	AppFrameworkConfig AppFrameworkSpec `json:"appRepo,omitempty"`
+
+	// Scheduled CSI snapshots of the etc and var volumes of the standalone pods
+	// +optional
+	Snapshots VolumeSnapshotSpec `json:"snapshots,omitempty"`
 }
 
 // StandaloneStatus defines the observed state of a Splunk Enterprise standalone instances.
@@ -76,6 +80,24 @@ type StandaloneStatus struct {
 
 	// Auxillary message describing CR status
 	Message string `json:"message"`
+
+	// progress of the volume expansion of the standalone pods, after an increase of their storage capacity
+	// +optional
+	VolumeResize []VolumeResizeStatus `json:"volumeResize,omitempty"`
+
+	// snapshot sets of the volumes of the standalone pods
+	// +optional
+	Snapshots VolumeSnapshotStatus `json:"snapshots,omitempty"`
+
+	// upgrade of the Splunk Enterprise version of the standalone instances, under the upgrade policy
+	// +optional
+	Upgrade UpgradeStatus `json:"upgrade,omitempty"`
+
+	// conditions of the custom resource, e.g. UpgradeFailed
+	// +optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -22,10 +22,46 @@ limitations under the License.
 package v4
 
 import (
-	"k8s.io/api/core/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppConfStanzaSpec) DeepCopyInto(out *AppConfStanzaSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppConfStanzaSpec.
+func (in *AppConfStanzaSpec) DeepCopy() *AppConfStanzaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppConfStanzaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppDependencySpec) DeepCopyInto(out *AppDependencySpec) {
+	*out = *in
+	if in.DependsOn != nil {
+		in, out := &in.DependsOn, &out.DependsOn
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDependencySpec.
+func (in *AppDependencySpec) DeepCopy() *AppDependencySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppDependencySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppDeploymentContext) DeepCopyInto(out *AppDeploymentContext) {
 	*out = *in
@@ -37,7 +73,8 @@ func (in *AppDeploymentContext) DeepCopyInto(out *AppDeploymentContext) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
-	out.BundlePushStatus = in.BundlePushStatus
+	in.BundlePushStatus.DeepCopyInto(&out.BundlePushStatus)
+	in.MaintenanceWindowStatus.DeepCopyInto(&out.MaintenanceWindowStatus)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppDeploymentContext.
@@ -53,6 +90,16 @@ func (in *AppDeploymentContext) DeepCopy() *AppDeploymentContext {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppDeploymentInfo) DeepCopyInto(out *AppDeploymentInfo) {
 	*out = *in
+	if in.Dependencies != nil {
+		in, out := &in.Dependencies, &out.Dependencies
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ValidationErrors != nil {
+		in, out := &in.ValidationErrors, &out.ValidationErrors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 	out.PhaseInfo = in.PhaseInfo
 	if in.AuxPhaseInfo != nil {
 		in, out := &in.AuxPhaseInfo, &out.AuxPhaseInfo
@@ -83,6 +130,14 @@ func (in *AppFrameworkSpec) DeepCopyInto(out *AppFrameworkSpec) {
 	if in.AppSources != nil {
 		in, out := &in.AppSources, &out.AppSources
 		*out = make([]AppSourceSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.AppPackagePolicy.DeepCopyInto(&out.AppPackagePolicy)
+	if in.MaintenanceWindows != nil {
+		in, out := &in.MaintenanceWindows, &out.MaintenanceWindows
+		*out = make([]MaintenanceWindowSpec, len(*in))
 		copy(*out, *in)
 	}
 }
@@ -97,6 +152,63 @@ func (in *AppFrameworkSpec) DeepCopy() *AppFrameworkSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppPackagePolicySpec) DeepCopyInto(out *AppPackagePolicySpec) {
+	*out = *in
+	if in.Rules != nil {
+		in, out := &in.Rules, &out.Rules
+		*out = make([]AppPackageRuleSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppPackagePolicySpec.
+func (in *AppPackagePolicySpec) DeepCopy() *AppPackagePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppPackagePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AppPackageRuleSpec) DeepCopyInto(out *AppPackageRuleSpec) {
+	*out = *in
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.AppSources != nil {
+		in, out := &in.AppSources, &out.AppSources
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForbiddenPaths != nil {
+		in, out := &in.ForbiddenPaths, &out.ForbiddenPaths
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ForbiddenStanzas != nil {
+		in, out := &in.ForbiddenStanzas, &out.ForbiddenStanzas
+		*out = make([]AppConfStanzaSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppPackageRuleSpec.
+func (in *AppPackageRuleSpec) DeepCopy() *AppPackageRuleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AppPackageRuleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppSourceDefaultSpec) DeepCopyInto(out *AppSourceDefaultSpec) {
 	*out = *in
@@ -117,6 +229,13 @@ func (in *AppSourceDefaultSpec) DeepCopy() *AppSourceDefaultSpec {
 func (in *AppSourceSpec) DeepCopyInto(out *AppSourceSpec) {
 	*out = *in
 	out.AppSourceDefaultSpec = in.AppSourceDefaultSpec
+	if in.AppDependencies != nil {
+		in, out := &in.AppDependencies, &out.AppDependencies
+		*out = make([]AppDependencySpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AppSourceSpec.
@@ -151,9 +270,115 @@ func (in *AppSrcDeployInfo) DeepCopy() *AppSrcDeployInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalingSpec) DeepCopyInto(out *AutoscalingSpec) {
+	*out = *in
+	if in.ScaleCooldown != nil {
+		in, out := &in.ScaleCooldown, &out.ScaleCooldown
+		*out = new(v1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingSpec.
+func (in *AutoscalingSpec) DeepCopy() *AutoscalingSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalingSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AutoscalingStatus) DeepCopyInto(out *AutoscalingStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AutoscalingStatus.
+func (in *AutoscalingStatus) DeepCopy() *AutoscalingStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(AutoscalingStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupInfo) DeepCopyInto(out *BackupInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupInfo.
+func (in *BackupInfo) DeepCopy() *BackupInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupRetentionSpec) DeepCopyInto(out *BackupRetentionSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupRetentionSpec.
+func (in *BackupRetentionSpec) DeepCopy() *BackupRetentionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupRetentionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BackupStorageSpec) DeepCopyInto(out *BackupStorageSpec) {
+	*out = *in
+	if in.VolList != nil {
+		in, out := &in.VolList, &out.VolList
+		*out = make([]VolumeSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BackupStorageSpec.
+func (in *BackupStorageSpec) DeepCopy() *BackupStorageSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BackupStorageSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleHistoryEntry) DeepCopyInto(out *BundleHistoryEntry) {
+	*out = *in
+	if in.Apps != nil {
+		in, out := &in.Apps, &out.Apps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleHistoryEntry.
+func (in *BundleHistoryEntry) DeepCopy() *BundleHistoryEntry {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleHistoryEntry)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BundlePushInfo) DeepCopyInto(out *BundlePushInfo) {
 	*out = *in
+	in.Validation.DeepCopyInto(&out.Validation)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundlePushInfo.
@@ -166,9 +391,30 @@ func (in *BundlePushInfo) DeepCopy() *BundlePushInfo {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundlePushPolicySpec) DeepCopyInto(out *BundlePushPolicySpec) {
+	*out = *in
+	if in.RestartWindows != nil {
+		in, out := &in.RestartWindows, &out.RestartWindows
+		*out = make([]MaintenanceWindowSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundlePushPolicySpec.
+func (in *BundlePushPolicySpec) DeepCopy() *BundlePushPolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BundlePushPolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BundlePushTracker) DeepCopyInto(out *BundlePushTracker) {
 	*out = *in
+	in.Validation.DeepCopyInto(&out.Validation)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundlePushTracker.
@@ -181,6 +427,41 @@ func (in *BundlePushTracker) DeepCopy() *BundlePushTracker {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleRollbackStatus) DeepCopyInto(out *BundleRollbackStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleRollbackStatus.
+func (in *BundleRollbackStatus) DeepCopy() *BundleRollbackStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleRollbackStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BundleValidationStatus) DeepCopyInto(out *BundleValidationStatus) {
+	*out = *in
+	if in.Errors != nil {
+		in, out := &in.Errors, &out.Errors
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BundleValidationStatus.
+func (in *BundleValidationStatus) DeepCopy() *BundleValidationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(BundleValidationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CacheManagerSpec) DeepCopyInto(out *CacheManagerSpec) {
 	*out = *in
@@ -262,6 +543,13 @@ func (in *ClusterManagerSpec) DeepCopyInto(out *ClusterManagerSpec) {
 	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
 	in.SmartStore.DeepCopyInto(&out.SmartStore)
 	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	in.SiteFailover.DeepCopyInto(&out.SiteFailover)
+	in.BundlePushPolicy.DeepCopyInto(&out.BundlePushPolicy)
+	if in.AllowedPeerNamespaces != nil {
+		in, out := &in.AllowedPeerNamespaces, &out.AllowedPeerNamespaces
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterManagerSpec.
@@ -274,11 +562,33 @@ func (in *ClusterManagerSpec) DeepCopy() *ClusterManagerSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ClusterManagerStandbySpec) DeepCopyInto(out *ClusterManagerStandbySpec) {
+	*out = *in
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterManagerStandbySpec.
+func (in *ClusterManagerStandbySpec) DeepCopy() *ClusterManagerStandbySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ClusterManagerStandbySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterManagerStatus) DeepCopyInto(out *ClusterManagerStatus) {
 	*out = *in
 	in.SmartStore.DeepCopyInto(&out.SmartStore)
-	out.BundlePushTracker = in.BundlePushTracker
+	in.BundlePushTracker.DeepCopyInto(&out.BundlePushTracker)
 	if in.ResourceRevMap != nil {
 		in, out := &in.ResourceRevMap, &out.ResourceRevMap
 		*out = make(map[string]string, len(*in))
@@ -287,6 +597,28 @@ func (in *ClusterManagerStatus) DeepCopyInto(out *ClusterManagerStatus) {
 		}
 	}
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	in.SiteFailover.DeepCopyInto(&out.SiteFailover)
+	if in.VolumeResize != nil {
+		in, out := &in.VolumeResize, &out.VolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.BundleHistory != nil {
+		in, out := &in.BundleHistory, &out.BundleHistory
+		*out = make([]BundleHistoryEntry, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.BundleRollback = in.BundleRollback
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterManagerStatus.
@@ -307,7 +639,7 @@ func (in *CommonSplunkSpec) DeepCopyInto(out *CommonSplunkSpec) {
 	out.VarVolumeStorageConfig = in.VarVolumeStorageConfig
 	if in.Volumes != nil {
 		in, out := &in.Volumes, &out.Volumes
-		*out = make([]v1.Volume, len(*in))
+		*out = make([]corev1.Volume, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -319,7 +651,7 @@ func (in *CommonSplunkSpec) DeepCopyInto(out *CommonSplunkSpec) {
 	out.MonitoringConsoleRef = in.MonitoringConsoleRef
 	if in.ExtraEnv != nil {
 		in, out := &in.ExtraEnv, &out.ExtraEnv
-		*out = make([]v1.EnvVar, len(*in))
+		*out = make([]corev1.EnvVar, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
@@ -341,9 +673,11 @@ func (in *CommonSplunkSpec) DeepCopyInto(out *CommonSplunkSpec) {
 	}
 	if in.ImagePullSecrets != nil {
 		in, out := &in.ImagePullSecrets, &out.ImagePullSecrets
-		*out = make([]v1.LocalObjectReference, len(*in))
+		*out = make([]corev1.LocalObjectReference, len(*in))
 		copy(*out, *in)
 	}
+	in.UpgradePolicy.DeepCopyInto(&out.UpgradePolicy)
+	out.SplunkdTLS = in.SplunkdTLS
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CommonSplunkSpec.
@@ -461,6 +795,21 @@ func (in *IndexerCluster) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterDecommissionStatus) DeepCopyInto(out *IndexerClusterDecommissionStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterDecommissionStatus.
+func (in *IndexerClusterDecommissionStatus) DeepCopy() *IndexerClusterDecommissionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterDecommissionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *IndexerClusterList) DeepCopyInto(out *IndexerClusterList) {
 	*out = *in
@@ -509,64 +858,231 @@ func (in *IndexerClusterMemberStatus) DeepCopy() *IndexerClusterMemberStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IndexerClusterSpec) DeepCopyInto(out *IndexerClusterSpec) {
+func (in *IndexerClusterScaleDownSpec) DeepCopyInto(out *IndexerClusterScaleDownSpec) {
 	*out = *in
-	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
+	if in.DecommissionTimeout != nil {
+		in, out := &in.DecommissionTimeout, &out.DecommissionTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSpec.
-func (in *IndexerClusterSpec) DeepCopy() *IndexerClusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterScaleDownSpec.
+func (in *IndexerClusterScaleDownSpec) DeepCopy() *IndexerClusterScaleDownSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(IndexerClusterSpec)
+	out := new(IndexerClusterScaleDownSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *IndexerClusterStatus) DeepCopyInto(out *IndexerClusterStatus) {
+func (in *IndexerClusterSiteSpec) DeepCopyInto(out *IndexerClusterSiteSpec) {
 	*out = *in
-	if in.IndexerSecretChanged != nil {
-		in, out := &in.IndexerSecretChanged, &out.IndexerSecretChanged
-		*out = make([]bool, len(*in))
-		copy(*out, *in)
-	}
-	if in.IdxcPasswordChangedSecrets != nil {
-		in, out := &in.IdxcPasswordChangedSecrets, &out.IdxcPasswordChangedSecrets
-		*out = make(map[string]bool, len(*in))
+	if in.NodeSelector != nil {
+		in, out := &in.NodeSelector, &out.NodeSelector
+		*out = make(map[string]string, len(*in))
 		for key, val := range *in {
 			(*out)[key] = val
 		}
 	}
-	if in.Peers != nil {
-		in, out := &in.Peers, &out.Peers
-		*out = make([]IndexerClusterMemberStatus, len(*in))
-		copy(*out, *in)
+	if in.Resources != nil {
+		in, out := &in.Resources, &out.Resources
+		*out = new(corev1.ResourceRequirements)
+		(*in).DeepCopyInto(*out)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterStatus.
-func (in *IndexerClusterStatus) DeepCopy() *IndexerClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSiteSpec.
+func (in *IndexerClusterSiteSpec) DeepCopy() *IndexerClusterSiteSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(IndexerClusterStatus)
+	out := new(IndexerClusterSiteSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *LicenseManager) DeepCopyInto(out *LicenseManager) {
+func (in *IndexerClusterSiteStatus) DeepCopyInto(out *IndexerClusterSiteStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
-}
-
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseManager.
-func (in *LicenseManager) DeepCopy() *LicenseManager {
+	if in.IndexerSecretChanged != nil {
+		in, out := &in.IndexerSecretChanged, &out.IndexerSecretChanged
+		*out = make([]bool, len(*in))
+		copy(*out, *in)
+	}
+	in.UpdateStatus.DeepCopyInto(&out.UpdateStatus)
+	out.DecommissionStatus = in.DecommissionStatus
+	out.AutoscalingStatus = in.AutoscalingStatus
+	in.StorageMigration.DeepCopyInto(&out.StorageMigration)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSiteStatus.
+func (in *IndexerClusterSiteStatus) DeepCopy() *IndexerClusterSiteStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterSiteStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterSpec) DeepCopyInto(out *IndexerClusterSpec) {
+	*out = *in
+	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
+	in.UpdateStrategy.DeepCopyInto(&out.UpdateStrategy)
+	in.ScaleDown.DeepCopyInto(&out.ScaleDown)
+	in.Autoscaling.DeepCopyInto(&out.Autoscaling)
+	if in.Sites != nil {
+		in, out := &in.Sites, &out.Sites
+		*out = make([]IndexerClusterSiteSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	out.Snapshots = in.Snapshots
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterSpec.
+func (in *IndexerClusterSpec) DeepCopy() *IndexerClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterStatus) DeepCopyInto(out *IndexerClusterStatus) {
+	*out = *in
+	if in.IndexerSecretChanged != nil {
+		in, out := &in.IndexerSecretChanged, &out.IndexerSecretChanged
+		*out = make([]bool, len(*in))
+		copy(*out, *in)
+	}
+	if in.IdxcPasswordChangedSecrets != nil {
+		in, out := &in.IdxcPasswordChangedSecrets, &out.IdxcPasswordChangedSecrets
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Peers != nil {
+		in, out := &in.Peers, &out.Peers
+		*out = make([]IndexerClusterMemberStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.UpdateStatus.DeepCopyInto(&out.UpdateStatus)
+	out.DecommissionStatus = in.DecommissionStatus
+	out.AutoscalingStatus = in.AutoscalingStatus
+	if in.Sites != nil {
+		in, out := &in.Sites, &out.Sites
+		*out = make([]IndexerClusterSiteStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.StorageMigration.DeepCopyInto(&out.StorageMigration)
+	if in.VolumeResize != nil {
+		in, out := &in.VolumeResize, &out.VolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.Snapshots.DeepCopyInto(&out.Snapshots)
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterStatus.
+func (in *IndexerClusterStatus) DeepCopy() *IndexerClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterStorageMigrationStatus) DeepCopyInto(out *IndexerClusterStorageMigrationStatus) {
+	*out = *in
+	if in.MigratedPeers != nil {
+		in, out := &in.MigratedPeers, &out.MigratedPeers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterStorageMigrationStatus.
+func (in *IndexerClusterStorageMigrationStatus) DeepCopy() *IndexerClusterStorageMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterStorageMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterUpdateStatus) DeepCopyInto(out *IndexerClusterUpdateStatus) {
+	*out = *in
+	if in.RestartingPeers != nil {
+		in, out := &in.RestartingPeers, &out.RestartingPeers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterUpdateStatus.
+func (in *IndexerClusterUpdateStatus) DeepCopy() *IndexerClusterUpdateStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterUpdateStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *IndexerClusterUpdateStrategy) DeepCopyInto(out *IndexerClusterUpdateStrategy) {
+	*out = *in
+	if in.SiteOrder != nil {
+		in, out := &in.SiteOrder, &out.SiteOrder
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new IndexerClusterUpdateStrategy.
+func (in *IndexerClusterUpdateStrategy) DeepCopy() *IndexerClusterUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(IndexerClusterUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicenseManager) DeepCopyInto(out *LicenseManager) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseManager.
+func (in *LicenseManager) DeepCopy() *LicenseManager {
 	if in == nil {
 		return nil
 	}
@@ -620,6 +1136,20 @@ func (in *LicenseManagerSpec) DeepCopyInto(out *LicenseManagerSpec) {
 	*out = *in
 	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
 	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	if in.Licenses != nil {
+		in, out := &in.Licenses, &out.Licenses
+		*out = make([]LicenseSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]LicensePoolSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseManagerSpec.
@@ -636,6 +1166,36 @@ func (in *LicenseManagerSpec) DeepCopy() *LicenseManagerSpec {
 func (in *LicenseManagerStatus) DeepCopyInto(out *LicenseManagerStatus) {
 	*out = *in
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	if in.VolumeResize != nil {
+		in, out := &in.VolumeResize, &out.VolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	if in.Licenses != nil {
+		in, out := &in.Licenses, &out.Licenses
+		*out = make([]LicenseStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Pools != nil {
+		in, out := &in.Pools, &out.Pools
+		*out = make([]LicensePoolStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Stacks != nil {
+		in, out := &in.Stacks, &out.Stacks
+		*out = make([]LicenseStackStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseManagerStatus.
@@ -649,153 +1209,716 @@ func (in *LicenseManagerStatus) DeepCopy() *LicenseManagerStatus {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MonitoringConsole) DeepCopyInto(out *MonitoringConsole) {
+func (in *LicensePoolMember) DeepCopyInto(out *LicensePoolMember) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	in.Spec.DeepCopyInto(&out.Spec)
-	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsole.
-func (in *MonitoringConsole) DeepCopy() *MonitoringConsole {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicensePoolMember.
+func (in *LicensePoolMember) DeepCopy() *LicensePoolMember {
 	if in == nil {
 		return nil
 	}
-	out := new(MonitoringConsole)
+	out := new(LicensePoolMember)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MonitoringConsole) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicensePoolSpec) DeepCopyInto(out *LicensePoolSpec) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]LicensePoolMember, len(*in))
+		copy(*out, *in)
 	}
-	return nil
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicensePoolSpec.
+func (in *LicensePoolSpec) DeepCopy() *LicensePoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LicensePoolSpec)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MonitoringConsoleList) DeepCopyInto(out *MonitoringConsoleList) {
+func (in *LicensePoolStatus) DeepCopyInto(out *LicensePoolStatus) {
 	*out = *in
-	out.TypeMeta = in.TypeMeta
-	in.ListMeta.DeepCopyInto(&out.ListMeta)
-	if in.Items != nil {
-		in, out := &in.Items, &out.Items
-		*out = make([]MonitoringConsole, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+	if in.Peers != nil {
+		in, out := &in.Peers, &out.Peers
+		*out = make([]string, len(*in))
+		copy(*out, *in)
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleList.
-func (in *MonitoringConsoleList) DeepCopy() *MonitoringConsoleList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicensePoolStatus.
+func (in *LicensePoolStatus) DeepCopy() *LicensePoolStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MonitoringConsoleList)
+	out := new(LicensePoolStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
-// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *MonitoringConsoleList) DeepCopyObject() runtime.Object {
-	if c := in.DeepCopy(); c != nil {
-		return c
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LicenseSource) DeepCopyInto(out *LicenseSource) {
+	*out = *in
+	if in.SecretRef != nil {
+		in, out := &in.SecretRef, &out.SecretRef
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
 	}
-	return nil
+	if in.ConfigMapRef != nil {
+		in, out := &in.ConfigMapRef, &out.ConfigMapRef
+		*out = new(corev1.ConfigMapKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseSource.
+func (in *LicenseSource) DeepCopy() *LicenseSource {
+	if in == nil {
+		return nil
+	}
+	out := new(LicenseSource)
+	in.DeepCopyInto(out)
+	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MonitoringConsoleSpec) DeepCopyInto(out *MonitoringConsoleSpec) {
+func (in *LicenseStackStatus) DeepCopyInto(out *LicenseStackStatus) {
 	*out = *in
-	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
-	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleSpec.
-func (in *MonitoringConsoleSpec) DeepCopy() *MonitoringConsoleSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseStackStatus.
+func (in *LicenseStackStatus) DeepCopy() *LicenseStackStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(MonitoringConsoleSpec)
+	out := new(LicenseStackStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *MonitoringConsoleStatus) DeepCopyInto(out *MonitoringConsoleStatus) {
+func (in *LicenseStatus) DeepCopyInto(out *LicenseStatus) {
 	*out = *in
-	out.BundlePushTracker = in.BundlePushTracker
-	if in.ResourceRevMap != nil {
-		in, out := &in.ResourceRevMap, &out.ResourceRevMap
-		*out = make(map[string]string, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LicenseStatus.
+func (in *LicenseStatus) DeepCopy() *LicenseStatus {
+	if in == nil {
+		return nil
 	}
-	in.AppContext.DeepCopyInto(&out.AppContext)
+	out := new(LicenseStatus)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleStatus.
-func (in *MonitoringConsoleStatus) DeepCopy() *MonitoringConsoleStatus {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MaintenanceWindowSpec) DeepCopyInto(out *MaintenanceWindowSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowSpec.
+func (in *MaintenanceWindowSpec) DeepCopy() *MaintenanceWindowSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(MonitoringConsoleStatus)
+	out := new(MaintenanceWindowSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PhaseInfo) DeepCopyInto(out *PhaseInfo) {
+func (in *MaintenanceWindowStatus) DeepCopyInto(out *MaintenanceWindowStatus) {
 	*out = *in
+	if in.HeldApps != nil {
+		in, out := &in.HeldApps, &out.HeldApps
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PhaseInfo.
-func (in *PhaseInfo) DeepCopy() *PhaseInfo {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MaintenanceWindowStatus.
+func (in *MaintenanceWindowStatus) DeepCopy() *MaintenanceWindowStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(PhaseInfo)
+	out := new(MaintenanceWindowStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *PremiumAppsProps) DeepCopyInto(out *PremiumAppsProps) {
+func (in *MonitoringConsole) DeepCopyInto(out *MonitoringConsole) {
 	*out = *in
-	out.EsDefaults = in.EsDefaults
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PremiumAppsProps.
-func (in *PremiumAppsProps) DeepCopy() *PremiumAppsProps {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsole.
+func (in *MonitoringConsole) DeepCopy() *MonitoringConsole {
 	if in == nil {
 		return nil
 	}
-	out := new(PremiumAppsProps)
+	out := new(MonitoringConsole)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MonitoringConsole) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleAlert) DeepCopyInto(out *MonitoringConsoleAlert) {
+	*out = *in
+	if in.Thresholds != nil {
+		in, out := &in.Thresholds, &out.Thresholds
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleAlert.
+func (in *MonitoringConsoleAlert) DeepCopy() *MonitoringConsoleAlert {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleAlert)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleForwarderMonitoring) DeepCopyInto(out *MonitoringConsoleForwarderMonitoring) {
+	*out = *in
+	if in.HeavyForwarderSelector != nil {
+		in, out := &in.HeavyForwarderSelector, &out.HeavyForwarderSelector
+		*out = new(v1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleForwarderMonitoring.
+func (in *MonitoringConsoleForwarderMonitoring) DeepCopy() *MonitoringConsoleForwarderMonitoring {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleForwarderMonitoring)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleGroup) DeepCopyInto(out *MonitoringConsoleGroup) {
+	*out = *in
+	in.Selector.DeepCopyInto(&out.Selector)
+	if in.Kinds != nil {
+		in, out := &in.Kinds, &out.Kinds
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleGroup.
+func (in *MonitoringConsoleGroup) DeepCopy() *MonitoringConsoleGroup {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleGroup)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleGroupStatus) DeepCopyInto(out *MonitoringConsoleGroupStatus) {
+	*out = *in
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleGroupStatus.
+func (in *MonitoringConsoleGroupStatus) DeepCopy() *MonitoringConsoleGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleList) DeepCopyInto(out *MonitoringConsoleList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]MonitoringConsole, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleList.
+func (in *MonitoringConsoleList) DeepCopy() *MonitoringConsoleList {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *MonitoringConsoleList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleSpec) DeepCopyInto(out *MonitoringConsoleSpec) {
+	*out = *in
+	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
+	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	if in.Alerts != nil {
+		in, out := &in.Alerts, &out.Alerts
+		*out = make([]MonitoringConsoleAlert, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.ForwarderMonitoring.DeepCopyInto(&out.ForwarderMonitoring)
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]MonitoringConsoleGroup, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleSpec.
+func (in *MonitoringConsoleSpec) DeepCopy() *MonitoringConsoleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MonitoringConsoleStatus) DeepCopyInto(out *MonitoringConsoleStatus) {
+	*out = *in
+	in.BundlePushTracker.DeepCopyInto(&out.BundlePushTracker)
+	if in.ResourceRevMap != nil {
+		in, out := &in.ResourceRevMap, &out.ResourceRevMap
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	in.AppContext.DeepCopyInto(&out.AppContext)
+	if in.VolumeResize != nil {
+		in, out := &in.VolumeResize, &out.VolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	if in.Alerts != nil {
+		in, out := &in.Alerts, &out.Alerts
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Groups != nil {
+		in, out := &in.Groups, &out.Groups
+		*out = make([]MonitoringConsoleGroupStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MonitoringConsoleStatus.
+func (in *MonitoringConsoleStatus) DeepCopy() *MonitoringConsoleStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(MonitoringConsoleStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PhaseInfo) DeepCopyInto(out *PhaseInfo) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PhaseInfo.
+func (in *PhaseInfo) DeepCopy() *PhaseInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(PhaseInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PremiumAppsProps) DeepCopyInto(out *PremiumAppsProps) {
+	*out = *in
+	out.EsDefaults = in.EsDefaults
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PremiumAppsProps.
+func (in *PremiumAppsProps) DeepCopy() *PremiumAppsProps {
+	if in == nil {
+		return nil
+	}
+	out := new(PremiumAppsProps)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Probe) DeepCopyInto(out *Probe) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probe.
+func (in *Probe) DeepCopy() *Probe {
+	if in == nil {
+		return nil
+	}
+	out := new(Probe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchHeadCluster) DeepCopyInto(out *SearchHeadCluster) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadCluster.
+func (in *SearchHeadCluster) DeepCopy() *SearchHeadCluster {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchHeadCluster)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SearchHeadCluster) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchHeadClusterList) DeepCopyInto(out *SearchHeadClusterList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SearchHeadCluster, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterList.
+func (in *SearchHeadClusterList) DeepCopy() *SearchHeadClusterList {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchHeadClusterList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SearchHeadClusterList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchHeadClusterMemberStatus) DeepCopyInto(out *SearchHeadClusterMemberStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterMemberStatus.
+func (in *SearchHeadClusterMemberStatus) DeepCopy() *SearchHeadClusterMemberStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchHeadClusterMemberStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchHeadClusterSpec) DeepCopyInto(out *SearchHeadClusterSpec) {
+	*out = *in
+	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
+	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	in.Autoscaling.DeepCopyInto(&out.Autoscaling)
+	out.UpdateStrategy = in.UpdateStrategy
+	in.BundlePushPolicy.DeepCopyInto(&out.BundlePushPolicy)
+	out.Snapshots = in.Snapshots
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterSpec.
+func (in *SearchHeadClusterSpec) DeepCopy() *SearchHeadClusterSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchHeadClusterSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchHeadClusterStatus) DeepCopyInto(out *SearchHeadClusterStatus) {
+	*out = *in
+	if in.ShcSecretChanged != nil {
+		in, out := &in.ShcSecretChanged, &out.ShcSecretChanged
+		*out = make([]bool, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdminSecretChanged != nil {
+		in, out := &in.AdminSecretChanged, &out.AdminSecretChanged
+		*out = make([]bool, len(*in))
+		copy(*out, *in)
+	}
+	if in.AdminPasswordChangedSecrets != nil {
+		in, out := &in.AdminPasswordChangedSecrets, &out.AdminPasswordChangedSecrets
+		*out = make(map[string]bool, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Members != nil {
+		in, out := &in.Members, &out.Members
+		*out = make([]SearchHeadClusterMemberStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.AppContext.DeepCopyInto(&out.AppContext)
+	out.AutoscalingStatus = in.AutoscalingStatus
+	if in.VolumeResize != nil {
+		in, out := &in.VolumeResize, &out.VolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.DeployerVolumeResize != nil {
+		in, out := &in.DeployerVolumeResize, &out.DeployerVolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.Snapshots.DeepCopyInto(&out.Snapshots)
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterStatus.
+func (in *SearchHeadClusterStatus) DeepCopy() *SearchHeadClusterStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchHeadClusterStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SearchHeadClusterUpdateStrategy) DeepCopyInto(out *SearchHeadClusterUpdateStrategy) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterUpdateStrategy.
+func (in *SearchHeadClusterUpdateStrategy) DeepCopy() *SearchHeadClusterUpdateStrategy {
+	if in == nil {
+		return nil
+	}
+	out := new(SearchHeadClusterUpdateStrategy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteFailoverEvent) DeepCopyInto(out *SiteFailoverEvent) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteFailoverEvent.
+func (in *SiteFailoverEvent) DeepCopy() *SiteFailoverEvent {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteFailoverEvent)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteFailoverSpec) DeepCopyInto(out *SiteFailoverSpec) {
+	*out = *in
+	if in.FailedSites != nil {
+		in, out := &in.FailedSites, &out.FailedSites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Standby.DeepCopyInto(&out.Standby)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteFailoverSpec.
+func (in *SiteFailoverSpec) DeepCopy() *SiteFailoverSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteFailoverSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SiteFailoverStatus) DeepCopyInto(out *SiteFailoverStatus) {
+	*out = *in
+	if in.FailedSites != nil {
+		in, out := &in.FailedSites, &out.FailedSites
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.SearchHeadSites != nil {
+		in, out := &in.SearchHeadSites, &out.SearchHeadSites
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Timeline != nil {
+		in, out := &in.Timeline, &out.Timeline
+		*out = make([]SiteFailoverEvent, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SiteFailoverStatus.
+func (in *SiteFailoverStatus) DeepCopy() *SiteFailoverStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(SiteFailoverStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SmartStoreSpec) DeepCopyInto(out *SmartStoreSpec) {
+	*out = *in
+	if in.VolList != nil {
+		in, out := &in.VolList, &out.VolList
+		*out = make([]VolumeSpec, len(*in))
+		copy(*out, *in)
+	}
+	if in.IndexList != nil {
+		in, out := &in.IndexList, &out.IndexList
+		*out = make([]IndexSpec, len(*in))
+		copy(*out, *in)
+	}
+	out.Defaults = in.Defaults
+	out.CacheManagerConf = in.CacheManagerConf
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmartStoreSpec.
+func (in *SmartStoreSpec) DeepCopy() *SmartStoreSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SmartStoreSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Probe) DeepCopyInto(out *Probe) {
+func (in *Spec) DeepCopyInto(out *Spec) {
 	*out = *in
+	in.Affinity.DeepCopyInto(&out.Affinity)
+	if in.Tolerations != nil {
+		in, out := &in.Tolerations, &out.Tolerations
+		*out = make([]corev1.Toleration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	in.Resources.DeepCopyInto(&out.Resources)
+	in.ServiceTemplate.DeepCopyInto(&out.ServiceTemplate)
+	if in.TopologySpreadConstraints != nil {
+		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
+		*out = make([]corev1.TopologySpreadConstraint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Probe.
-func (in *Probe) DeepCopy() *Probe {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Spec.
+func (in *Spec) DeepCopy() *Spec {
 	if in == nil {
 		return nil
 	}
-	out := new(Probe)
+	out := new(Spec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchHeadCluster) DeepCopyInto(out *SearchHeadCluster) {
+func (in *SplunkBackup) DeepCopyInto(out *SplunkBackup) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
@@ -803,18 +1926,18 @@ func (in *SearchHeadCluster) DeepCopyInto(out *SearchHeadCluster) {
 	in.Status.DeepCopyInto(&out.Status)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadCluster.
-func (in *SearchHeadCluster) DeepCopy() *SearchHeadCluster {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackup.
+func (in *SplunkBackup) DeepCopy() *SplunkBackup {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchHeadCluster)
+	out := new(SplunkBackup)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *SearchHeadCluster) DeepCopyObject() runtime.Object {
+func (in *SplunkBackup) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -822,31 +1945,31 @@ func (in *SearchHeadCluster) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchHeadClusterList) DeepCopyInto(out *SearchHeadClusterList) {
+func (in *SplunkBackupList) DeepCopyInto(out *SplunkBackupList) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ListMeta.DeepCopyInto(&out.ListMeta)
 	if in.Items != nil {
 		in, out := &in.Items, &out.Items
-		*out = make([]SearchHeadCluster, len(*in))
+		*out = make([]SplunkBackup, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterList.
-func (in *SearchHeadClusterList) DeepCopy() *SearchHeadClusterList {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupList.
+func (in *SplunkBackupList) DeepCopy() *SplunkBackupList {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchHeadClusterList)
+	out := new(SplunkBackupList)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
-func (in *SearchHeadClusterList) DeepCopyObject() runtime.Object {
+func (in *SplunkBackupList) DeepCopyObject() runtime.Object {
 	if c := in.DeepCopy(); c != nil {
 		return c
 	}
@@ -854,130 +1977,145 @@ func (in *SearchHeadClusterList) DeepCopyObject() runtime.Object {
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchHeadClusterMemberStatus) DeepCopyInto(out *SearchHeadClusterMemberStatus) {
+func (in *SplunkBackupSpec) DeepCopyInto(out *SplunkBackupSpec) {
 	*out = *in
+	out.TargetRef = in.TargetRef
+	in.BackupStorageSpec.DeepCopyInto(&out.BackupStorageSpec)
+	out.Retention = in.Retention
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterMemberStatus.
-func (in *SearchHeadClusterMemberStatus) DeepCopy() *SearchHeadClusterMemberStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupSpec.
+func (in *SplunkBackupSpec) DeepCopy() *SplunkBackupSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchHeadClusterMemberStatus)
+	out := new(SplunkBackupSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchHeadClusterSpec) DeepCopyInto(out *SearchHeadClusterSpec) {
+func (in *SplunkBackupStatus) DeepCopyInto(out *SplunkBackupStatus) {
 	*out = *in
-	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
-	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	if in.Backups != nil {
+		in, out := &in.Backups, &out.Backups
+		*out = make([]BackupInfo, len(*in))
+		copy(*out, *in)
+	}
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterSpec.
-func (in *SearchHeadClusterSpec) DeepCopy() *SearchHeadClusterSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkBackupStatus.
+func (in *SplunkBackupStatus) DeepCopy() *SplunkBackupStatus {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchHeadClusterSpec)
+	out := new(SplunkBackupStatus)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SearchHeadClusterStatus) DeepCopyInto(out *SearchHeadClusterStatus) {
+func (in *SplunkRestore) DeepCopyInto(out *SplunkRestore) {
 	*out = *in
-	if in.ShcSecretChanged != nil {
-		in, out := &in.ShcSecretChanged, &out.ShcSecretChanged
-		*out = make([]bool, len(*in))
-		copy(*out, *in)
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestore.
+func (in *SplunkRestore) DeepCopy() *SplunkRestore {
+	if in == nil {
+		return nil
 	}
-	if in.AdminSecretChanged != nil {
-		in, out := &in.AdminSecretChanged, &out.AdminSecretChanged
-		*out = make([]bool, len(*in))
-		copy(*out, *in)
+	out := new(SplunkRestore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SplunkRestore) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
 	}
-	if in.AdminPasswordChangedSecrets != nil {
-		in, out := &in.AdminPasswordChangedSecrets, &out.AdminPasswordChangedSecrets
-		*out = make(map[string]bool, len(*in))
-		for key, val := range *in {
-			(*out)[key] = val
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkRestoreList) DeepCopyInto(out *SplunkRestoreList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]SplunkRestore, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	if in.Members != nil {
-		in, out := &in.Members, &out.Members
-		*out = make([]SearchHeadClusterMemberStatus, len(*in))
-		copy(*out, *in)
-	}
-	in.AppContext.DeepCopyInto(&out.AppContext)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SearchHeadClusterStatus.
-func (in *SearchHeadClusterStatus) DeepCopy() *SearchHeadClusterStatus {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestoreList.
+func (in *SplunkRestoreList) DeepCopy() *SplunkRestoreList {
 	if in == nil {
 		return nil
 	}
-	out := new(SearchHeadClusterStatus)
+	out := new(SplunkRestoreList)
 	in.DeepCopyInto(out)
 	return out
 }
 
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *SplunkRestoreList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *SmartStoreSpec) DeepCopyInto(out *SmartStoreSpec) {
+func (in *SplunkRestoreSpec) DeepCopyInto(out *SplunkRestoreSpec) {
 	*out = *in
-	if in.VolList != nil {
-		in, out := &in.VolList, &out.VolList
-		*out = make([]VolumeSpec, len(*in))
-		copy(*out, *in)
-	}
-	if in.IndexList != nil {
-		in, out := &in.IndexList, &out.IndexList
-		*out = make([]IndexSpec, len(*in))
-		copy(*out, *in)
-	}
-	out.Defaults = in.Defaults
-	out.CacheManagerConf = in.CacheManagerConf
+	out.TargetRef = in.TargetRef
+	in.BackupStorageSpec.DeepCopyInto(&out.BackupStorageSpec)
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SmartStoreSpec.
-func (in *SmartStoreSpec) DeepCopy() *SmartStoreSpec {
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestoreSpec.
+func (in *SplunkRestoreSpec) DeepCopy() *SplunkRestoreSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(SmartStoreSpec)
+	out := new(SplunkRestoreSpec)
 	in.DeepCopyInto(out)
 	return out
 }
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
-func (in *Spec) DeepCopyInto(out *Spec) {
+func (in *SplunkRestoreStatus) DeepCopyInto(out *SplunkRestoreStatus) {
 	*out = *in
-	in.Affinity.DeepCopyInto(&out.Affinity)
-	if in.Tolerations != nil {
-		in, out := &in.Tolerations, &out.Tolerations
-		*out = make([]v1.Toleration, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
-	}
-	in.Resources.DeepCopyInto(&out.Resources)
-	in.ServiceTemplate.DeepCopyInto(&out.ServiceTemplate)
-	if in.TopologySpreadConstraints != nil {
-		in, out := &in.TopologySpreadConstraints, &out.TopologySpreadConstraints
-		*out = make([]v1.TopologySpreadConstraint, len(*in))
-		for i := range *in {
-			(*in)[i].DeepCopyInto(&(*out)[i])
-		}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkRestoreStatus.
+func (in *SplunkRestoreStatus) DeepCopy() *SplunkRestoreStatus {
+	if in == nil {
+		return nil
 	}
+	out := new(SplunkRestoreStatus)
+	in.DeepCopyInto(out)
+	return out
 }
 
-// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Spec.
-func (in *Spec) DeepCopy() *Spec {
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SplunkdTLSSpec) DeepCopyInto(out *SplunkdTLSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SplunkdTLSSpec.
+func (in *SplunkdTLSSpec) DeepCopy() *SplunkdTLSSpec {
 	if in == nil {
 		return nil
 	}
-	out := new(Spec)
+	out := new(SplunkdTLSSpec)
 	in.DeepCopyInto(out)
 	return out
 }
@@ -1047,6 +2185,7 @@ func (in *StandaloneSpec) DeepCopyInto(out *StandaloneSpec) {
 	in.CommonSplunkSpec.DeepCopyInto(&out.CommonSplunkSpec)
 	in.SmartStore.DeepCopyInto(&out.SmartStore)
 	in.AppFrameworkConfig.DeepCopyInto(&out.AppFrameworkConfig)
+	out.Snapshots = in.Snapshots
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandaloneSpec.
@@ -1071,6 +2210,20 @@ func (in *StandaloneStatus) DeepCopyInto(out *StandaloneStatus) {
 		}
 	}
 	in.AppContext.DeepCopyInto(&out.AppContext)
+	if in.VolumeResize != nil {
+		in, out := &in.VolumeResize, &out.VolumeResize
+		*out = make([]VolumeResizeStatus, len(*in))
+		copy(*out, *in)
+	}
+	in.Snapshots.DeepCopyInto(&out.Snapshots)
+	in.Upgrade.DeepCopyInto(&out.Upgrade)
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]v1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StandaloneStatus.
@@ -1098,6 +2251,86 @@ func (in *StorageClassSpec) DeepCopy() *StorageClassSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradePolicySpec) DeepCopyInto(out *UpgradePolicySpec) {
+	*out = *in
+	if in.SupportedHops != nil {
+		in, out := &in.SupportedHops, &out.SupportedHops
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PreflightChecks != nil {
+		in, out := &in.PreflightChecks, &out.PreflightChecks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradePolicySpec.
+func (in *UpgradePolicySpec) DeepCopy() *UpgradePolicySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradePolicySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradePreflightCheckStatus) DeepCopyInto(out *UpgradePreflightCheckStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradePreflightCheckStatus.
+func (in *UpgradePreflightCheckStatus) DeepCopy() *UpgradePreflightCheckStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradePreflightCheckStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeStatus) DeepCopyInto(out *UpgradeStatus) {
+	*out = *in
+	if in.Plan != nil {
+		in, out := &in.Plan, &out.Plan
+		*out = make([]UpgradeTierStatus, len(*in))
+		copy(*out, *in)
+	}
+	if in.PreflightChecks != nil {
+		in, out := &in.PreflightChecks, &out.PreflightChecks
+		*out = make([]UpgradePreflightCheckStatus, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeStatus.
+func (in *UpgradeStatus) DeepCopy() *UpgradeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *UpgradeTierStatus) DeepCopyInto(out *UpgradeTierStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new UpgradeTierStatus.
+func (in *UpgradeTierStatus) DeepCopy() *UpgradeTierStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(UpgradeTierStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeAndTypeSpec) DeepCopyInto(out *VolumeAndTypeSpec) {
 	*out = *in
@@ -1114,6 +2347,83 @@ func (in *VolumeAndTypeSpec) DeepCopy() *VolumeAndTypeSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeResizeStatus) DeepCopyInto(out *VolumeResizeStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeResizeStatus.
+func (in *VolumeResizeStatus) DeepCopy() *VolumeResizeStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeResizeStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotSetInfo) DeepCopyInto(out *VolumeSnapshotSetInfo) {
+	*out = *in
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotSetInfo.
+func (in *VolumeSnapshotSetInfo) DeepCopy() *VolumeSnapshotSetInfo {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotSetInfo)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotSpec) DeepCopyInto(out *VolumeSnapshotSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotSpec.
+func (in *VolumeSnapshotSpec) DeepCopy() *VolumeSnapshotSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *VolumeSnapshotStatus) DeepCopyInto(out *VolumeSnapshotStatus) {
+	*out = *in
+	if in.CurrentSet != nil {
+		in, out := &in.CurrentSet, &out.CurrentSet
+		*out = new(VolumeSnapshotSetInfo)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SnapshotSets != nil {
+		in, out := &in.SnapshotSets, &out.SnapshotSets
+		*out = make([]VolumeSnapshotSetInfo, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new VolumeSnapshotStatus.
+func (in *VolumeSnapshotStatus) DeepCopy() *VolumeSnapshotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(VolumeSnapshotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *VolumeSpec) DeepCopyInto(out *VolumeSpec) {
 	*out = *in
@@ -1,7 +1,13 @@
 package controllers
 
 import (
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	enterprise "github.com/splunk/splunk-operator/pkg/splunk/enterprise"
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
@@ -14,6 +20,10 @@ const (
 	labelMethodName      = "api"
 	labelModuleName      = "module"
 	labelResourceVersion = "resource_version"
+	labelInstanceType    = "instance_type"
+	labelHTTPMethod      = "method"
+	labelStatusCode      = "code"
+	labelReason          = "reason"
 )
 
 var reconcileCounters = prometheus.NewCounterVec(prometheus.CounterOpts{
@@ -36,6 +46,69 @@ var apiTotalTimeMetricEvents = prometheus.NewGaugeVec(prometheus.GaugeOpts{
 	Help: "The time it takes to complete each call in standalone (in milliseconds)",
 }, []string{labelNamespace, labelName, labelKind, labelModuleName, labelMethodName})
 
+var splunkdRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "splunk_operator_splunkd_request_duration_seconds",
+	Help:    "The latency of the REST API requests sent to splunkd",
+	Buckets: prometheus.DefBuckets,
+}, []string{labelInstanceType, labelHTTPMethod, labelStatusCode})
+
+var splunkdRequestErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "splunk_operator_splunkd_request_errors_total",
+	Help: "The number of REST API requests to splunkd which failed, were short-circuited or returned a server error",
+}, []string{labelInstanceType, labelHTTPMethod, labelReason})
+
+var splunkdTLSVerificationFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Name: "splunk_operator_splunkd_tls_verification_failures_total",
+	Help: "The number of REST API requests to splunkd which failed the verification of the certificate",
+}, []string{labelInstanceType, labelReason})
+
+// splunkdInstanceTypes are the instance types the REST API requests to splunkd are labelled with, the longest first
+var splunkdInstanceTypes = []enterprise.InstanceType{
+	enterprise.SplunkMonitoringConsole,
+	enterprise.SplunkClusterManager,
+	enterprise.SplunkClusterMaster,
+	enterprise.SplunkLicenseManager,
+	enterprise.SplunkLicenseMaster,
+	enterprise.SplunkStandalone,
+	enterprise.SplunkSearchHead,
+	enterprise.SplunkDeployer,
+	enterprise.SplunkIndexer,
+}
+
+// getSplunkdInstanceType returns the instance type of the pod or the service of a splunkd host, to label the
+// requests sent to splunkd with a bounded number of label values
+func getSplunkdInstanceType(host string) string {
+	name := strings.SplitN(host, ".", 2)[0]
+	name = strings.SplitN(name, ":", 2)[0]
+	name = strings.TrimSuffix(name, "-service")
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		if _, err := strconv.Atoi(name[i+1:]); err == nil {
+			name = name[:i]
+		}
+	}
+	for _, instanceType := range splunkdInstanceTypes {
+		if strings.HasSuffix(name, "-"+instanceType.ToString()) {
+			return instanceType.ToString()
+		}
+	}
+	return "other"
+}
+
+// observeSplunkdRequest records the latency and the errors of the REST API requests sent to splunkd
+func observeSplunkdRequest(host, method string, statusCode int, errorReason string, duration time.Duration) {
+	instanceType := getSplunkdInstanceType(host)
+	if errorReason != "" {
+		splunkdRequestErrors.WithLabelValues(instanceType, method, errorReason).Inc()
+	}
+	switch errorReason {
+	case splclient.TLSUnknownAuthority, splclient.TLSHostnameMismatch, splclient.TLSInvalidCertificate, splclient.TLSVerificationFailed:
+		splunkdTLSVerificationFailures.WithLabelValues(instanceType, errorReason).Inc()
+	}
+	if errorReason != splclient.RequestErrorCircuitOpen {
+		splunkdRequestDuration.WithLabelValues(instanceType, method, strconv.Itoa(statusCode)).Observe(duration.Seconds())
+	}
+}
+
 func getPrometheusLabels(request reconcile.Request, kind string) prometheus.Labels {
 	return prometheus.Labels{
 		labelNamespace: request.Namespace,
@@ -50,5 +123,9 @@ func init() {
 		reconcileErrorCounter,
 		actionFailureCounters,
 		apiTotalTimeMetricEvents,
+		splunkdRequestDuration,
+		splunkdRequestErrors,
+		splunkdTLSVerificationFailures,
 	)
+	splclient.SetRequestObserver(observeSplunkdRequest)
 }
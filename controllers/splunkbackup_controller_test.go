@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/splunk/splunk-operator/controllers/testutils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("SplunkBackup Controller", func() {
+
+	BeforeEach(func() {
+		time.Sleep(2 * time.Second)
+	})
+
+	AfterEach(func() {
+
+	})
+
+	Context("SplunkBackup Management", func() {
+
+		It("Get SplunkBackup custom resource should failed", func() {
+			namespace := "ns-splunk-bk-1"
+			ApplySplunkBackup = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkBackup) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}
+			nsSpecs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			Expect(k8sClient.Create(context.Background(), nsSpecs)).Should(Succeed())
+			// check when resource not found
+			_, err := GetSplunkBackup("test", nsSpecs.Name)
+			Expect(err.Error()).Should(Equal("splunkbackups.enterprise.splunk.com \"test\" not found"))
+			Expect(k8sClient.Delete(context.Background(), nsSpecs)).Should(Succeed())
+		})
+
+		It("Create SplunkBackup custom resource should succeeded", func() {
+			namespace := "ns-splunk-bk-2"
+			ApplySplunkBackup = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkBackup) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}
+			nsSpecs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			Expect(k8sClient.Create(context.Background(), nsSpecs)).Should(Succeed())
+			CreateSplunkBackup("test", nsSpecs.Name)
+			DeleteSplunkBackup("test", nsSpecs.Name)
+			Expect(k8sClient.Delete(context.Background(), nsSpecs)).Should(Succeed())
+		})
+
+		It("Cover Unused methods", func() {
+			namespace := "ns-splunk-bk-3"
+			ApplySplunkBackup = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkBackup) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}
+			nsSpecs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			Expect(k8sClient.Create(context.Background(), nsSpecs)).Should(Succeed())
+			ctx := context.TODO()
+			builder := fake.NewClientBuilder()
+			c := builder.Build()
+			instance := SplunkBackupReconciler{
+				Client: c,
+				Scheme: scheme.Scheme,
+			}
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test",
+					Namespace: namespace,
+				},
+			}
+			// reconcile for the first time err is resource not found
+			_, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			// create resource first and then reconcile
+			ssSpec := testutils.NewSplunkBackup("test", namespace, "stack1")
+			Expect(c.Create(ctx, ssSpec)).Should(Succeed())
+			_, err = instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+	})
+})
+
+func GetSplunkBackup(name string, namespace string) (*enterpriseApi.SplunkBackup, error) {
+	key := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	By("Expecting SplunkBackup custom resource to be created successfully")
+	ss := &enterpriseApi.SplunkBackup{}
+	err := k8sClient.Get(context.Background(), key, ss)
+	if err != nil {
+		return nil, err
+	}
+	return ss, err
+}
+
+func CreateSplunkBackup(name string, namespace string) *enterpriseApi.SplunkBackup {
+	key := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	ssSpec := testutils.NewSplunkBackup(name, namespace, "stack1")
+	Expect(k8sClient.Create(context.Background(), ssSpec)).Should(Succeed())
+	time.Sleep(2 * time.Second)
+
+	By("Expecting SplunkBackup custom resource to be created successfully")
+	ss := &enterpriseApi.SplunkBackup{}
+	Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), key, ss)
+		return true
+	}, timeout, interval).Should(BeTrue())
+
+	return ss
+}
+
+func DeleteSplunkBackup(name string, namespace string) {
+	key := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	By("Expecting SplunkBackup Deleted successfully")
+	Eventually(func() error {
+		ssys := &enterpriseApi.SplunkBackup{}
+		_ = k8sClient.Get(context.Background(), key, ssys)
+		err := k8sClient.Delete(context.Background(), ssys)
+		return err
+	}, timeout, interval).Should(Succeed())
+}
@@ -0,0 +1,138 @@
+package controllers
+
+import (
+	"context"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/splunk/splunk-operator/controllers/testutils"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+var _ = Describe("SplunkRestore Controller", func() {
+
+	BeforeEach(func() {
+		time.Sleep(2 * time.Second)
+	})
+
+	AfterEach(func() {
+
+	})
+
+	Context("SplunkRestore Management", func() {
+
+		It("Get SplunkRestore custom resource should failed", func() {
+			namespace := "ns-splunk-rs-1"
+			ApplySplunkRestore = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkRestore) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}
+			nsSpecs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			Expect(k8sClient.Create(context.Background(), nsSpecs)).Should(Succeed())
+			// check when resource not found
+			_, err := GetSplunkRestore("test", nsSpecs.Name)
+			Expect(err.Error()).Should(Equal("splunkrestores.enterprise.splunk.com \"test\" not found"))
+			Expect(k8sClient.Delete(context.Background(), nsSpecs)).Should(Succeed())
+		})
+
+		It("Create SplunkRestore custom resource should succeeded", func() {
+			namespace := "ns-splunk-rs-2"
+			ApplySplunkRestore = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkRestore) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}
+			nsSpecs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			Expect(k8sClient.Create(context.Background(), nsSpecs)).Should(Succeed())
+			CreateSplunkRestore("test", nsSpecs.Name)
+			DeleteSplunkRestore("test", nsSpecs.Name)
+			Expect(k8sClient.Delete(context.Background(), nsSpecs)).Should(Succeed())
+		})
+
+		It("Cover Unused methods", func() {
+			namespace := "ns-splunk-rs-3"
+			ApplySplunkRestore = func(ctx context.Context, client client.Client, instance *enterpriseApi.SplunkRestore) (reconcile.Result, error) {
+				return reconcile.Result{}, nil
+			}
+			nsSpecs := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+			Expect(k8sClient.Create(context.Background(), nsSpecs)).Should(Succeed())
+			ctx := context.TODO()
+			builder := fake.NewClientBuilder()
+			c := builder.Build()
+			instance := SplunkRestoreReconciler{
+				Client: c,
+				Scheme: scheme.Scheme,
+			}
+			request := reconcile.Request{
+				NamespacedName: types.NamespacedName{
+					Name:      "test",
+					Namespace: namespace,
+				},
+			}
+			// reconcile for the first time err is resource not found
+			_, err := instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+			// create resource first and then reconcile
+			ssSpec := testutils.NewSplunkRestore("test", namespace, "stack1")
+			Expect(c.Create(ctx, ssSpec)).Should(Succeed())
+			_, err = instance.Reconcile(ctx, request)
+			Expect(err).ToNot(HaveOccurred())
+		})
+
+	})
+})
+
+func GetSplunkRestore(name string, namespace string) (*enterpriseApi.SplunkRestore, error) {
+	key := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	By("Expecting SplunkRestore custom resource to be created successfully")
+	ss := &enterpriseApi.SplunkRestore{}
+	err := k8sClient.Get(context.Background(), key, ss)
+	if err != nil {
+		return nil, err
+	}
+	return ss, err
+}
+
+func CreateSplunkRestore(name string, namespace string) *enterpriseApi.SplunkRestore {
+	key := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+	ssSpec := testutils.NewSplunkRestore(name, namespace, "stack1")
+	Expect(k8sClient.Create(context.Background(), ssSpec)).Should(Succeed())
+	time.Sleep(2 * time.Second)
+
+	By("Expecting SplunkRestore custom resource to be created successfully")
+	ss := &enterpriseApi.SplunkRestore{}
+	Eventually(func() bool {
+		_ = k8sClient.Get(context.Background(), key, ss)
+		return true
+	}, timeout, interval).Should(BeTrue())
+
+	return ss
+}
+
+func DeleteSplunkRestore(name string, namespace string) {
+	key := types.NamespacedName{
+		Name:      name,
+		Namespace: namespace,
+	}
+
+	By("Expecting SplunkRestore Deleted successfully")
+	Eventually(func() error {
+		ssys := &enterpriseApi.SplunkRestore{}
+		_ = k8sClient.Get(context.Background(), key, ssys)
+		err := k8sClient.Delete(context.Background(), ssys)
+		return err
+	}, timeout, interval).Should(Succeed())
+}
@@ -282,3 +282,43 @@ func NewIndexerCluster(name, ns, image string) *enterpriseApi.IndexerCluster {
 	}
 	return ad
 }
+
+// NewSplunkBackup returns new SplunkBackup instance of a Standalone
+func NewSplunkBackup(name, ns, standaloneName string) *enterpriseApi.SplunkBackup {
+	return &enterpriseApi.SplunkBackup{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "enterprise.splunk.com/v4",
+			Kind:       "SplunkBackup",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: enterpriseApi.SplunkBackupSpec{
+			TargetRef: corev1.ObjectReference{
+				Kind: "Standalone",
+				Name: standaloneName,
+			},
+		},
+	}
+}
+
+// NewSplunkRestore returns new SplunkRestore instance into a Standalone
+func NewSplunkRestore(name, ns, standaloneName string) *enterpriseApi.SplunkRestore {
+	return &enterpriseApi.SplunkRestore{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "enterprise.splunk.com/v4",
+			Kind:       "SplunkRestore",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: enterpriseApi.SplunkRestoreSpec{
+			TargetRef: corev1.ObjectReference{
+				Kind: "Standalone",
+				Name: standaloneName,
+			},
+		},
+	}
+}
@@ -41,6 +41,7 @@ var _ RemoteDataClient = &AWSS3Client{}
 // SplunkAWSS3Client is an interface to AWS S3 client
 type SplunkAWSS3Client interface {
 	ListObjectsV2(options *s3.ListObjectsV2Input) (*s3.ListObjectsV2Output, error)
+	DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error)
 }
 
 // SplunkAWSDownloadClient is used to download the apps from remote storage
@@ -48,6 +49,11 @@ type SplunkAWSDownloadClient interface {
 	Download(w io.WriterAt, input *s3.GetObjectInput, options ...func(*s3manager.Downloader)) (n int64, err error)
 }
 
+// SplunkAWSUploadClient is used to upload the backups to remote storage
+type SplunkAWSUploadClient interface {
+	Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error)
+}
+
 // AWSS3Client is a client to implement S3 specific APIs
 type AWSS3Client struct {
 	Endpoint           string
@@ -59,6 +65,7 @@ type AWSS3Client struct {
 	StartAfter         string
 	Client             SplunkAWSS3Client
 	Downloader         SplunkAWSDownloadClient
+	Uploader           SplunkAWSUploadClient
 }
 
 var regionRegex = ".*.s3[-,.]([a-z]+-[a-z]+-[0-9]+)\\..*amazonaws.com"
@@ -172,6 +179,7 @@ func NewAWSS3Client(ctx context.Context, bucketName string, accessKeyID string,
 		return nil, fmt.Errorf("unable to get s3 client")
 	}
 	downloader := s3manager.NewDownloaderWithClient(cl.(*s3.S3))
+	uploader := s3manager.NewUploaderWithClient(cl.(*s3.S3))
 
 	return &AWSS3Client{
 		Region:             region,
@@ -182,6 +190,7 @@ func NewAWSS3Client(ctx context.Context, bucketName string, accessKeyID string,
 		StartAfter:         startAfter,
 		Client:             s3SplunkClient,
 		Downloader:         downloader,
+		Uploader:           uploader,
 	}, nil
 }
 
@@ -219,22 +228,32 @@ func (awsclient *AWSS3Client) GetAppsList(ctx context.Context) (RemoteDataListRe
 		Prefix:     aws.String(awsclient.Prefix),
 		StartAfter: aws.String(awsclient.StartAfter), // exclude the directory itself from listing
 		MaxKeys:    aws.Int64(4000),                  // return upto 4K keys from S3
-		Delimiter:  aws.String("/"),                  // limit the listing to 1 level only
+		// list all the levels, to get the objects of the exploded app directories
 	}
 
+	// S3 returns upto MaxKeys objects per call, so follow the continuation token till the listing is complete
 	client := awsclient.Client
-	resp, err := client.ListObjectsV2(options)
-	if err != nil {
-		scopedLog.Error(err, "Unable to list items in bucket", "AWS S3 Bucket", awsclient.BucketName, "endpoint", awsclient.Endpoint)
-		return remoteDataClientResponse, err
+	var contents []*s3.Object
+	for {
+		resp, err := client.ListObjectsV2(options)
+		if err != nil {
+			scopedLog.Error(err, "Unable to list items in bucket", "AWS S3 Bucket", awsclient.BucketName, "endpoint", awsclient.Endpoint)
+			return remoteDataClientResponse, err
+		}
+
+		contents = append(contents, resp.Contents...)
+		if resp.IsTruncated == nil || !*resp.IsTruncated || resp.NextContinuationToken == nil {
+			break
+		}
+		options.ContinuationToken = resp.NextContinuationToken
 	}
 
-	if resp.Contents == nil {
+	if contents == nil {
 		scopedLog.Info("empty objects list in bucket. No apps to install", "bucketName", awsclient.BucketName)
 		return remoteDataClientResponse, nil
 	}
 
-	tmp, err := json.Marshal(resp.Contents)
+	tmp, err := json.Marshal(contents)
 	if err != nil {
 		scopedLog.Error(err, "Failed to marshal s3 response", "AWS S3 Bucket", awsclient.BucketName)
 		return remoteDataClientResponse, err
@@ -280,3 +299,50 @@ func (awsclient *AWSS3Client) DownloadApp(ctx context.Context, downloadRequest R
 
 	return true, err
 }
+
+// UploadFile uploads a local file to remote storage
+func (awsclient *AWSS3Client) UploadFile(ctx context.Context, uploadRequest RemoteDataUploadRequest) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("UploadFile").WithValues("remoteFile", uploadRequest.RemoteFile, "localFile",
+		uploadRequest.LocalFile)
+
+	file, err := os.Open(uploadRequest.LocalFile)
+	if err != nil {
+		scopedLog.Error(err, "Unable to open local file")
+		return err
+	}
+	defer file.Close()
+
+	_, err = awsclient.Uploader.Upload(&s3manager.UploadInput{
+		Bucket: aws.String(awsclient.BucketName),
+		Key:    aws.String(uploadRequest.RemoteFile),
+		Body:   file,
+	})
+	if err != nil {
+		scopedLog.Error(err, "Unable to upload item", "RemoteFile", uploadRequest.RemoteFile)
+		return err
+	}
+
+	scopedLog.Info("File uploaded")
+
+	return nil
+}
+
+// DeleteFile deletes a file from remote storage
+func (awsclient *AWSS3Client) DeleteFile(ctx context.Context, remoteFile string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("DeleteFile").WithValues("remoteFile", remoteFile)
+
+	_, err := awsclient.Client.DeleteObject(&s3.DeleteObjectInput{
+		Bucket: aws.String(awsclient.BucketName),
+		Key:    aws.String(remoteFile),
+	})
+	if err != nil {
+		scopedLog.Error(err, "Unable to delete item", "AWS S3 Bucket", awsclient.BucketName)
+		return err
+	}
+
+	scopedLog.Info("File deleted")
+
+	return nil
+}
@@ -18,6 +18,7 @@ package client
 import (
 	"context"
 	"crypto/tls"
+	"fmt"
 	"net/http"
 	"os"
 	"testing"
@@ -353,6 +354,36 @@ func TestAWSGetAppsListShouldFail(t *testing.T) {
 	}
 }
 
+func TestAWSGetAppsListPagination(t *testing.T) {
+	ctx := context.TODO()
+
+	// more objects than a single ListObjectsV2 call returns
+	var objects []*spltest.MockRemoteDataObject
+	for i := 0; i < 4500; i++ {
+		key := fmt.Sprintf("apps/explodedApp/default/file%d.conf", i)
+		etag := fmt.Sprintf("etag%d", i)
+		objects = append(objects, &spltest.MockRemoteDataObject{Key: &key, Etag: &etag})
+	}
+
+	awsClient := &AWSS3Client{
+		BucketName: "bucket",
+		Prefix:     "apps/",
+		StartAfter: "apps/",
+		Client:     spltest.MockAWSS3Client{Objects: objects},
+	}
+
+	remoteDataClientResponse, err := awsClient.GetAppsList(ctx)
+	if err != nil {
+		t.Errorf("GetAppsList should not have returned error. error=%v", err)
+	}
+	if len(remoteDataClientResponse.Objects) != len(objects) {
+		t.Errorf("GetAppsList should list all the pages. want %d objects, got %d", len(objects), len(remoteDataClientResponse.Objects))
+	}
+	if *remoteDataClientResponse.Objects[len(objects)-1].Key != *objects[len(objects)-1].Key {
+		t.Errorf("GetAppsList returned the objects out of order")
+	}
+}
+
 func TestAWSDownloadAppShouldNotFail(t *testing.T) {
 	ctx := context.TODO()
 	appFrameworkRef := enterpriseApi.AppFrameworkSpec{
@@ -568,3 +599,44 @@ func TestAWSDownloadAppShouldFail(t *testing.T) {
 		t.Errorf("DownloadApp should have returned error since remoteFile name is empty")
 	}
 }
+
+func TestAWSUploadAndDeleteFile(t *testing.T) {
+	ctx := context.TODO()
+	awsClient := &AWSS3Client{
+		BucketName: "backups",
+		Client:     spltest.MockAWSS3Client{},
+		Uploader:   spltest.MockAWSUploadClient{},
+	}
+
+	localFile := t.TempDir() + "/kvstore.tar.gz"
+	uploadRequest := RemoteDataUploadRequest{
+		LocalFile:  localFile,
+		RemoteFile: "backups/kvstore.tar.gz",
+	}
+
+	// local file is missing
+	err := awsClient.UploadFile(ctx, uploadRequest)
+	if err == nil {
+		t.Errorf("UploadFile should fail for a missing local file")
+	}
+
+	err = os.WriteFile(localFile, []byte("kvstore"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create local file: %v", err)
+	}
+	err = awsClient.UploadFile(ctx, uploadRequest)
+	if err != nil {
+		t.Errorf("UploadFile should not fail: %v", err)
+	}
+
+	err = awsClient.DeleteFile(ctx, uploadRequest.RemoteFile)
+	if err != nil {
+		t.Errorf("DeleteFile should not fail: %v", err)
+	}
+
+	awsClient.Client = spltest.MockAWSS3ClientError{}
+	err = awsClient.DeleteFile(ctx, uploadRequest.RemoteFile)
+	if err == nil {
+		t.Errorf("DeleteFile should fail")
+	}
+}
@@ -525,6 +525,100 @@ func (client *AzureBlobClient) DownloadApp(ctx context.Context, downloadRequest
 	return true, err
 }
 
+// authorizeHTTPRequest sets up the http request with the required authentication, using the secrets if provided,
+// or IAM otherwise
+func (client *AzureBlobClient) authorizeHTTPRequest(ctx context.Context, httpRequest *http.Request) error {
+	if client.StorageAccountName != "" && client.SecretAccessKey != "" {
+		return updateAzureHTTPRequestHeaderWithSecrets(ctx, client, httpRequest)
+	}
+	return updateAzureHTTPRequestHeaderWithIAM(ctx, client, httpRequest)
+}
+
+// UploadFile uploads a local file to remote storage as a block blob
+func (client *AzureBlobClient) UploadFile(ctx context.Context, uploadRequest RemoteDataUploadRequest) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AzureBlob:UploadFile").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+		"uploadRequest", uploadRequest)
+
+	localFile, err := os.Open(uploadRequest.LocalFile)
+	if err != nil {
+		scopedLog.Error(err, "Unable to open local file")
+		return err
+	}
+	defer localFile.Close()
+
+	stat, err := localFile.Stat()
+	if err != nil {
+		scopedLog.Error(err, "Unable to get the size of local file")
+		return err
+	}
+
+	// Create a http request with the URL
+	httpRequest, err := http.NewRequest("PUT", fmt.Sprintf(azureBlobURL, client.Endpoint, client.BucketName, uploadRequest.RemoteFile), localFile)
+	if err != nil {
+		scopedLog.Error(err, "Azure Blob Failed to create request for upload URL")
+		return err
+	}
+	httpRequest.ContentLength = stat.Size()
+	httpRequest.Header.Set(headerContentLength, strconv.FormatInt(stat.Size(), 10))
+	httpRequest.Header.Set(headerXmsBlobType, azureBlobTypeBlockBlob)
+
+	err = client.authorizeHTTPRequest(ctx, httpRequest)
+	if err != nil {
+		scopedLog.Error(err, "Failed to get http request authenticated")
+		return err
+	}
+
+	httpResponse, err := client.HTTPClient.Do(httpRequest)
+	if err != nil {
+		scopedLog.Error(err, "Azure blob, unable to execute upload http request")
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusCreated {
+		return fmt.Errorf("unable to upload the blob %s, status code %d", uploadRequest.RemoteFile, httpResponse.StatusCode)
+	}
+
+	scopedLog.Info("File uploaded")
+
+	return nil
+}
+
+// DeleteFile deletes a blob from remote storage
+func (client *AzureBlobClient) DeleteFile(ctx context.Context, remoteFile string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("AzureBlob:DeleteFile").WithValues("Endpoint", client.Endpoint, "Bucket", client.BucketName,
+		"remoteFile", remoteFile)
+
+	httpRequest, err := http.NewRequest("DELETE", fmt.Sprintf(azureBlobURL, client.Endpoint, client.BucketName, remoteFile), nil)
+	if err != nil {
+		scopedLog.Error(err, "Azure Blob Failed to create request for delete URL")
+		return err
+	}
+
+	err = client.authorizeHTTPRequest(ctx, httpRequest)
+	if err != nil {
+		scopedLog.Error(err, "Failed to get http request authenticated")
+		return err
+	}
+
+	httpResponse, err := client.HTTPClient.Do(httpRequest)
+	if err != nil {
+		scopedLog.Error(err, "Azure blob, unable to execute delete http request")
+		return err
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode != http.StatusAccepted {
+		return fmt.Errorf("unable to delete the blob %s, status code %d", remoteFile, httpResponse.StatusCode)
+	}
+
+	scopedLog.Info("File deleted")
+
+	return nil
+}
+
 // RegisterAzureBlobClient will add the corresponding function pointer to the map
 func RegisterAzureBlobClient() {
 	wrapperObject := GetRemoteDataClientWrapper{GetRemoteDataClient: NewAzureBlobClient, GetInitFunc: InitAzureBlobClientWrapper}
@@ -1202,3 +1202,57 @@ func TestAzureBlobDownloadAppShouldFailInvalidIdentity(t *testing.T) {
 	}
 	mclient.RemoveHandlers()
 }
+
+func TestAzureBlobUploadAndDeleteFile(t *testing.T) {
+	ctx := context.TODO()
+	mclient := spltest.MockHTTPClient{}
+	azureBlobClient := &AzureBlobClient{
+		BucketName:         "backupscontainer",
+		StorageAccountName: "mystorageaccount",
+		SecretAccessKey:    "abcd",
+		Endpoint:           "https://mystorageaccount.blob.core.windows.net",
+		HTTPClient:         &mclient,
+	}
+
+	localFile := t.TempDir() + "/kvstore.tar.gz"
+	err := os.WriteFile(localFile, []byte("kvstore"), 0644)
+	if err != nil {
+		t.Fatalf("unable to create local file: %v", err)
+	}
+	uploadRequest := RemoteDataUploadRequest{
+		LocalFile:  localFile,
+		RemoteFile: "backups/kvstore.tar.gz",
+	}
+	blobURL := "https://mystorageaccount.blob.core.windows.net/backupscontainer/backups/kvstore.tar.gz"
+
+	// upload is not accepted
+	wantRequest, _ := http.NewRequest("PUT", blobURL, nil)
+	mclient.AddHandler(wantRequest, 403, "", nil)
+	err = azureBlobClient.UploadFile(ctx, uploadRequest)
+	if err == nil {
+		t.Errorf("UploadFile should fail when the upload is not accepted")
+	}
+
+	mclient.AddHandler(wantRequest, 201, "", nil)
+	err = azureBlobClient.UploadFile(ctx, uploadRequest)
+	if err != nil {
+		t.Errorf("UploadFile should not fail: %v", err)
+	}
+	gotRequest := mclient.GotRequests[len(mclient.GotRequests)-1]
+	if gotRequest.Header.Get("x-ms-blob-type") != "BlockBlob" || gotRequest.ContentLength != 7 || gotRequest.Header.Get("Authorization") == "" {
+		t.Errorf("UploadFile should put an authorized block blob, got headers %v", gotRequest.Header)
+	}
+
+	wantRequest, _ = http.NewRequest("DELETE", blobURL, nil)
+	mclient.AddHandler(wantRequest, 202, "", nil)
+	err = azureBlobClient.DeleteFile(ctx, uploadRequest.RemoteFile)
+	if err != nil {
+		t.Errorf("DeleteFile should not fail: %v", err)
+	}
+
+	mclient.AddHandler(wantRequest, 404, "", nil)
+	err = azureBlobClient.DeleteFile(ctx, uploadRequest.RemoteFile)
+	if err == nil {
+		t.Errorf("DeleteFile should fail when the blob is not deleted")
+	}
+}
@@ -21,10 +21,10 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"regexp"
 	"strconv"
 	"strings"
-	"time"
 
 	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
 )
@@ -48,21 +48,30 @@ type SplunkClient struct {
 
 	// HTTP client used to process requests
 	Client SplunkHTTPClient
+
+	// called when the certificate of the management interface fails the verification
+	TLSVerificationFailed func(err *TLSVerificationError)
 }
 
 // NewSplunkClient returns a new SplunkClient object initialized with a username and password.
+// The certificates of the management interface are not verified.
 func NewSplunkClient(managementURI, username, password string) *SplunkClient {
-	return &SplunkClient{
-		ManagementURI: managementURI,
-		Username:      username,
-		Password:      password,
-		Client: &http.Client{
-			Timeout: 5 * time.Second,
-			Transport: &http.Transport{
-				TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, // don't verify ssl certs
-			},
-		},
+	return NewSplunkClientWithTLSConfig(managementURI, username, password, &tls.Config{InsecureSkipVerify: true}) // don't verify ssl certs
+}
+
+// NewSplunkClientWithTLSConfig returns a new SplunkClient object initialized with a username and password, which
+// connects to the management interface with tlsConfig.
+func NewSplunkClientWithTLSConfig(managementURI, username, password string, tlsConfig *tls.Config) *SplunkClient {
+	return defaultSplunkClientFactory.NewSplunkClient(managementURI, username, password, tlsConfig)
+}
+
+// GetTLSConfig returns the TLS configuration used to connect to the management interface, or nil if the HTTP client
+// was not created by a SplunkClientFactory
+func (c *SplunkClient) GetTLSConfig() *tls.Config {
+	if client, ok := c.Client.(*splunkHTTPClient); ok {
+		return client.tlsConfig
 	}
+	return nil
 }
 
 // Do processes a Splunk REST API request and unmarshals response into obj, if not nil.
@@ -71,8 +80,16 @@ func (c *SplunkClient) Do(request *http.Request, expectedStatus []int, obj inter
 	request.SetBasicAuth(c.Username, c.Password)
 	response, err := c.Client.Do(request)
 	if err != nil {
+		if reason := getTLSVerificationFailureReason(err); reason != "" {
+			verificationErr := &TLSVerificationError{Host: request.URL.Host, Reason: reason, Err: err}
+			if c.TLSVerificationFailed != nil {
+				c.TLSVerificationFailed(verificationErr)
+			}
+			return verificationErr
+		}
 		return err
 	}
+	defer response.Body.Close()
 	//default set flag to false and the check response code
 	expectedStatusFlag := false
 	for i := 0; i < len(expectedStatus); i++ {
@@ -369,6 +386,31 @@ type ClusterBundleInfo struct {
 	Timestamp int64 `json:"timestamp"`
 }
 
+// ClusterValidatedBundleInfo represents the status of the last validated configuration bundle.
+type ClusterValidatedBundleInfo struct {
+	ClusterBundleInfo
+
+	// Indicates if the bundle passed the validation
+	IsValidBundle bool `json:"is_valid_bundle"`
+}
+
+// ClusterInvalidBundleInfo represents the configuration bundle which failed the validation.
+type ClusterInvalidBundleInfo struct {
+	ClusterBundleInfo
+
+	// Errors reported by the validation of the bundle on the cluster manager
+	ValidationErrors []string `json:"bundle_validation_errors_on_master"`
+}
+
+// ClusterApplyBundleStatus represents the status of the last configuration bundle push.
+type ClusterApplyBundleStatus struct {
+	// Status of the bundle push, e.g. None when no push is in progress
+	Status string `json:"status"`
+
+	// Provides information about the bundle which failed the validation
+	InvalidBundle ClusterInvalidBundleInfo `json:"invalid_bundle"`
+}
+
 // ClusterManagerInfo represents the status of the indexer cluster manager.
 // See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Finfo
 type ClusterManagerInfo struct {
@@ -397,6 +439,19 @@ type ClusterManagerInfo struct {
 	// In steady state, this is equal to active_bundle. If it is not equal, then pushing the latest bundle to all peers is in process (or needs to be started).
 	LatestBundle ClusterBundleInfo `json:"latest_bundle"`
 
+	// Provides information about the bundle active before the active bundle, which a rollback restores.
+	PreviousActiveBundle ClusterBundleInfo `json:"previous_active_bundle"`
+
+	// Provides information about the last validated bundle.
+	LastValidatedBundle ClusterValidatedBundleInfo `json:"last_validated_bundle"`
+
+	// Indicates if the push of the last validated bundle requires a rolling restart of the peers, when the validation
+	// checked it.
+	LastCheckRestartBundleResult bool `json:"last_check_restart_bundle_result"`
+
+	// Provides information about the last bundle push.
+	ApplyBundleStatus ClusterApplyBundleStatus `json:"apply_bundle_status"`
+
 	// Timestamp corresponding to the creation of the manager.
 	StartTime int64 `json:"start_time"`
 }
@@ -468,6 +523,74 @@ func (c *SplunkClient) GetIndexerClusterPeerInfo() (*IndexerClusterPeerInfo, err
 	return &apiResponse.Entry[0].Content, nil
 }
 
+// IndexerQueueInfo represents the fill of an indexing pipeline queue of a peer.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#server.2Fintrospection.2Fqueues
+type IndexerQueueInfo struct {
+	// Current size of the queue, in bytes
+	CurrentSizeBytes int64 `json:"current_size_bytes"`
+
+	// Maximum size of the queue, in bytes
+	MaxSizeBytes int64 `json:"max_size_bytes"`
+}
+
+// FillRatio returns the fill ratio of the queue, between 0 and 1
+func (q *IndexerQueueInfo) FillRatio() float64 {
+	if q.MaxSizeBytes <= 0 {
+		return 0
+	}
+	return float64(q.CurrentSizeBytes) / float64(q.MaxSizeBytes)
+}
+
+// GetIndexerQueueInfo queries a peer for the fill of the given indexing pipeline queue, e.g. indexqueue.
+// You can use this on any indexer.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#server.2Fintrospection.2Fqueues
+func (c *SplunkClient) GetIndexerQueueInfo(queue string) (*IndexerQueueInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content IndexerQueueInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := fmt.Sprintf("/services/server/introspection/queues/%s", queue)
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
+// IndexerThroughputInfo represents the indexing throughput of a peer.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#server.2Fintrospection.2Findexer
+type IndexerThroughputInfo struct {
+	// Average indexing throughput, in KB per second
+	AverageKBps float64 `json:"average_KBps"`
+
+	// Indexing status of the peer, e.g. normal or throttled
+	Status string `json:"status"`
+}
+
+// GetIndexerThroughputInfo queries a peer for its indexing throughput.
+// You can use this on any indexer.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#server.2Fintrospection.2Findexer
+func (c *SplunkClient) GetIndexerThroughputInfo() (*IndexerThroughputInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content IndexerThroughputInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/server/introspection/indexer"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
 // ClusterManagerPeerInfo represents the status of a indexer cluster peer (cluster manager endpoint).
 // See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fpeers
 type ClusterManagerPeerInfo struct {
@@ -640,6 +763,204 @@ func (c *SplunkClient) BundlePush(ignoreIdenticalBundle bool) error {
 	return c.Do(request, expectedStatus, nil)
 }
 
+// ValidateClusterManagerBundle validates the cluster manager apps bundle without pushing it to the peers. When
+// checkRestart is true, also checks if the push requires a rolling restart of the peers. The validation runs in the
+// background, its result is reported by GetClusterManagerInfo.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Updatepeerconfigurations#Validate_the_bundle_and_check_restart
+func (c *SplunkClient) ValidateClusterManagerBundle(checkRestart bool) error {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/default/validate_bundle")
+	reqBody := fmt.Sprintf("&check-restart=%t", checkRestart)
+
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+
+	return c.Do(request, expectedStatus, nil)
+}
+
+// RollbackClusterManagerBundle rolls back the peers to the previous active bundle of the cluster manager, which becomes
+// the active bundle.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Updatepeerconfigurations#Rollback_the_configuration_bundle
+func (c *SplunkClient) RollbackClusterManagerBundle() error {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/default/rollback")
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// SetClusterManagerMaintenanceMode enables or disables the maintenance mode of the indexer cluster, which halts the
+// bucket fixups and the rolling of the hot buckets of the peers.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fcontrol.2Fdefault.2Fmaintenance
+func (c *SplunkClient) SetClusterManagerMaintenanceMode(enable bool) error {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/default/maintenance")
+	reqBody := fmt.Sprintf("&mode=%t", enable)
+
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+
+	return c.Do(request, expectedStatus, nil)
+}
+
+// GetClusterManagerBucketFixups queries the cluster manager for the number of buckets pending fixup at the given
+// level, e.g. replication_factor or search_factor.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Ffixup
+func (c *SplunkClient) GetClusterManagerBucketFixups(level string) (int64, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name string `json:"name"`
+		} `json:"entry"`
+	}{}
+	endpoint := fmt.Sprintf("%s%s?count=0&output_mode=json&level=%s", c.ManagementURI, "/services/cluster/manager/fixup", level)
+	request, err := http.NewRequest("GET", endpoint, nil)
+	if err != nil {
+		return 0, err
+	}
+	expectedStatus := []int{200}
+	err = c.Do(request, expectedStatus, &apiResponse)
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(apiResponse.Entry)), nil
+}
+
+// ClusterManagerHealth represents the health of the indexer cluster, as reported by the cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fhealth
+type ClusterManagerHealth struct {
+	// Indicates if all the data is searchable.
+	AllDataIsSearchable string `json:"all_data_is_searchable"`
+
+	// Indicates if all the peers are up.
+	AllPeersAreUp string `json:"all_peers_are_up"`
+
+	// Indicates if the cluster is multisite.
+	Multisite string `json:"multisite"`
+
+	// Indicates if there are no bucket fixup tasks in progress.
+	NoFixupTasksInProgress string `json:"no_fixup_tasks_in_progress"`
+
+	// Indicates if the replication factor is met.
+	ReplicationFactorMet string `json:"replication_factor_met"`
+
+	// Indicates if the search factor is met.
+	SearchFactorMet string `json:"search_factor_met"`
+
+	// Indicates if the site replication factor is met, for a multisite cluster.
+	SiteReplicationFactorMet string `json:"site_replication_factor_met"`
+
+	// Indicates if the site search factor is met, for a multisite cluster.
+	SiteSearchFactorMet string `json:"site_search_factor_met"`
+}
+
+// GetClusterManagerHealth queries the cluster manager for the health of the indexer cluster.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fhealth
+func (c *SplunkClient) GetClusterManagerHealth() (*ClusterManagerHealth, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content ClusterManagerHealth `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/cluster/manager/health"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
+// ClusterRestartProgress represents the progress of a rolling restart or upgrade of the indexer cluster peers.
+type ClusterRestartProgress struct {
+	// Peers restarted
+	Done []string `json:"done"`
+
+	// Peers failed to restart
+	Failed []string `json:"failed"`
+
+	// Peers being restarted
+	InProgress []string `json:"in_progress"`
+
+	// Peers waiting to be restarted
+	ToBeRestarted []string `json:"to_be_restarted"`
+}
+
+// ClusterManagerStatus represents the status of a rolling restart or upgrade on the cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fstatus
+type ClusterManagerStatus struct {
+	// Indicates if the cluster manager is in a rolling restart or upgrade.
+	RollingRestartOrUpgrade bool `json:"rolling_restart_or_upgrade"`
+
+	// Indicates if the rolling restart or upgrade is searchable.
+	SearchableRolling bool `json:"searchable_rolling"`
+
+	// Indicates if the cluster is in maintenance mode.
+	MaintenanceMode bool `json:"maintenance_mode"`
+
+	// Progress of the rolling restart.
+	RestartProgress ClusterRestartProgress `json:"restart_progress"`
+}
+
+// GetClusterManagerStatus queries the cluster manager for the status of a rolling restart or upgrade.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTcluster#cluster.2Fmanager.2Fstatus
+func (c *SplunkClient) GetClusterManagerStatus() (*ClusterManagerStatus, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content ClusterManagerStatus `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/cluster/manager/status"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
+// InitRollingUpgrade puts the indexer cluster in the rolling upgrade mode, so that the peers can be taken offline
+// and upgraded while the data stays searchable.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Searchablerollingupgrade
+func (c *SplunkClient) InitRollingUpgrade() error {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/control/rolling_upgrade_init")
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// FinalizeRollingUpgrade takes the indexer cluster out of the rolling upgrade mode.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Searchablerollingupgrade
+func (c *SplunkClient) FinalizeRollingUpgrade() error {
+	endpoint := fmt.Sprintf("%s%s", c.ManagementURI, "/services/cluster/manager/control/control/rolling_upgrade_finalize")
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
 // MCServerRolesInfo is the struct for the server roles of the localhost, in this case SplunkMonitoringConsole
 type MCServerRolesInfo struct {
 	ServerRoles []string `json:"server_roles"`
@@ -649,6 +970,7 @@ type MCServerRolesInfo struct {
 type MCDistributedPeers struct {
 	ClusterLabel []string `json:"cluster_label"`
 	ServerRoles  []string `json:"server_roles"`
+	PeerName     string   `json:"peerName"`
 }
 
 // AutomateMCApplyChanges change the state of new indexers from "New" to "Configured" and add them in monitoring console asset table
@@ -879,11 +1201,154 @@ func (c *SplunkClient) UpdateMonitoringConsoleApp() error {
 	return err
 }
 
+// MCSavedSearch represents a saved search of the monitoring console app, e.g. a platform alert.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#saved.2Fsearches.2F.7Bname.7D
+type MCSavedSearch struct {
+	// Name of the saved search, e.g. DMC Alert - Near Critical Disk Usage
+	Name string
+
+	// Disabled is true if the saved search is not scheduled
+	Disabled bool
+
+	// Cron schedule of the saved search
+	CronSchedule string
+
+	// Arguments of the search of the saved search, without their args. prefix
+	Args map[string]string
+}
+
+// GetMonitoringConsoleSavedSearch queries a saved search of the monitoring console app.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#saved.2Fsearches.2F.7Bname.7D
+func (c *SplunkClient) GetMonitoringConsoleSavedSearch(name string) (*MCSavedSearch, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content map[string]interface{} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := fmt.Sprintf("/servicesNS/nobody/splunk_monitoring_console/saved/searches/%s", url.PathEscape(name))
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+
+	savedSearch := MCSavedSearch{Name: name, Args: map[string]string{}}
+	for key, value := range apiResponse.Entry[0].Content {
+		switch {
+		case key == "disabled":
+			savedSearch.Disabled = value == true || value == "1" || value == "true"
+		case key == "cron_schedule":
+			savedSearch.CronSchedule = fmt.Sprint(value)
+		case strings.HasPrefix(key, "args."):
+			savedSearch.Args[strings.TrimPrefix(key, "args.")] = fmt.Sprint(value)
+		}
+	}
+	return &savedSearch, nil
+}
+
+// UpdateMonitoringConsoleSavedSearch enables or disables a saved search of the monitoring console app, and sets its
+// cron schedule, if not empty, and the arguments of its search.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#saved.2Fsearches.2F.7Bname.7D
+func (c *SplunkClient) UpdateMonitoringConsoleSavedSearch(savedSearch MCSavedSearch) error {
+	endpoint := fmt.Sprintf("%s/servicesNS/nobody/splunk_monitoring_console/saved/searches/%s", c.ManagementURI, url.PathEscape(savedSearch.Name))
+	values := url.Values{"disabled": {"0"}}
+	if savedSearch.Disabled {
+		values.Set("disabled", "1")
+	}
+	if savedSearch.CronSchedule != "" {
+		values.Set("cron_schedule", savedSearch.CronSchedule)
+	}
+	for key, value := range savedSearch.Args {
+		values.Set("args."+key, value)
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200, 201}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// GetMonitoringConsoleDistributedPeers queries the distributed search peers of the monitoring console, indexed by name.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fpeers
+func (c *SplunkClient) GetMonitoringConsoleDistributedPeers() (map[string]MCDistributedPeers, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string             `json:"name"`
+			Content MCDistributedPeers `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/search/distributed/peers"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]MCDistributedPeers)
+	for _, e := range apiResponse.Entry {
+		peers[e.Name] = e.Content
+	}
+	return peers, nil
+}
+
+// GetDMCGroups queries the distributed search groups of the monitoring console, indexed by name, with their members.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fgroups
+func (c *SplunkClient) GetDMCGroups() (map[string][]string, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string `json:"name"`
+			Content struct {
+				Member []string `json:"member"`
+			} `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/search/distributed/groups"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make(map[string][]string)
+	for _, e := range apiResponse.Entry {
+		groups[e.Name] = e.Content.Member
+	}
+	return groups, nil
+}
+
+// CreateDMCGroup creates a distributed search group of the monitoring console with members.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fgroups
+func (c *SplunkClient) CreateDMCGroup(dmcGroupName string, members []string) error {
+	endpoint := fmt.Sprintf("%s/services/search/distributed/groups", c.ManagementURI)
+	values := url.Values{"name": {dmcGroupName}, "member": members, "default": {"false"}}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200, 201}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// DeleteDMCGroup deletes a distributed search group of the monitoring console.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsearch#search.2Fdistributed.2Fgroups.2F.7Bname.7D
+func (c *SplunkClient) DeleteDMCGroup(dmcGroupName string) error {
+	endpoint := fmt.Sprintf("%s/services/search/distributed/groups/%s", c.ManagementURI, url.PathEscape(dmcGroupName))
+	request, err := http.NewRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200, 404}
+	return c.Do(request, expectedStatus, nil)
+}
+
 // ClusterInfo is the struct for checking ClusterInfo
 type ClusterInfo struct {
 	MultiSite             string `json:"multisite"`
 	ReplicationFactor     int32  `json:"replication_factor"`
 	SiteReplicationFactor string `json:"site_replication_factor,omitempty"`
+	Site                  string `json:"site,omitempty"`
 }
 
 // GetClusterInfo queries the cluster about multi-site or single-site.
@@ -923,6 +1388,184 @@ func (c *SplunkClient) SetIdxcSecret(idxcSecret string) error {
 	return c.Do(request, expectedStatus, nil)
 }
 
+// SetClusterSiteMappings maps the origin buckets of decommissioned or failed sites to surviving sites, where
+// siteMappings is a comma separated list of <failed site>:<surviving site>. An empty list removes the mappings.
+// You can only use this on a cluster manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Decommissionasite
+func (c *SplunkClient) SetClusterSiteMappings(siteMappings string) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/config/config?site_mappings=%s", c.ManagementURI, url.QueryEscape(siteMappings))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// SetSearchHeadSite sets the site of a search head of a multisite indexer cluster, site0 disabling its search affinity.
+// The search head must be restarted for the site to take effect.
+// You can use this on any search head of an indexer cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Indexer/Multisitesearchaffinity
+func (c *SplunkClient) SetSearchHeadSite(site string) error {
+	endpoint := fmt.Sprintf("%s/services/cluster/config/config?site=%s", c.ManagementURI, url.QueryEscape(site))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// RollingRestartSearchHeadCluster initiates a rolling restart of the search head cluster members. With searchable,
+// the members keep running their searches while they are restarted, and the health of the cluster is checked before
+// the restart.
+// You can only use this on the captain of a search head cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Restartthesearchheadcluster
+func (c *SplunkClient) RollingRestartSearchHeadCluster(searchable bool) error {
+	endpoint := fmt.Sprintf("%s/services/shcluster/captain/control/default/restart", c.ManagementURI)
+	if searchable {
+		endpoint += "?searchable=true"
+	}
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// TransferSearchHeadCaptaincy transfers the captaincy of the search head cluster to the member with the management
+// URI mgmtURI, e.g. https://splunk-example-search-head-0.splunk-example-search-head-headless:8089.
+// You can use this on any member of a search head cluster.
+// See https://docs.splunk.com/Documentation/Splunk/latest/DistSearch/Transfercaptaincy
+func (c *SplunkClient) TransferSearchHeadCaptaincy(mgmtURI string) error {
+	endpoint := fmt.Sprintf("%s/services/shcluster/member/consensus/default/transfer_captaincy?mgmt_uri=%s", c.ManagementURI, url.QueryEscape(mgmtURI))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// KVStoreStatus represents the status of the KV store of a Splunk instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#kvstore.2Fstatus
+type KVStoreStatus struct {
+	Current struct {
+		// Status of the KV store, e.g. ready, starting or failed.
+		Status string `json:"status"`
+
+		// Replication status of the KV store member, e.g. KV store captain or Non-captain KV store member.
+		ReplicationStatus string `json:"replicationStatus"`
+
+		// Status of the KV store backup or restore, Ready when no backup or restore is running.
+		BackupRestoreStatus string `json:"backupRestoreStatus"`
+	} `json:"current"`
+}
+
+// GetKVStoreStatus queries the status of the KV store.
+// Can be used for any Splunk Instance with a KV store
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTkvstore#kvstore.2Fstatus
+func (c *SplunkClient) GetKVStoreStatus() (*KVStoreStatus, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content KVStoreStatus `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/kvstore/status"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+	if len(apiResponse.Entry) < 1 {
+		return nil, fmt.Errorf("invalid response from %s%s", c.ManagementURI, path)
+	}
+	return &apiResponse.Entry[0].Content, nil
+}
+
+// CreateKVStoreBackup starts a backup of the KV store to the archive <archiveName>.tar.gz in the kvstorebackup
+// directory of $SPLUNK_DB. Use GetKVStoreStatus to check the completion of the backup.
+// Can be used for any Splunk Instance with a KV store
+// See https://docs.splunk.com/Documentation/Splunk/latest/Admin/BackupKVstore
+func (c *SplunkClient) CreateKVStoreBackup(archiveName string) error {
+	endpoint := fmt.Sprintf("%s/services/kvstore/backup/create?archiveName=%s", c.ManagementURI, url.QueryEscape(archiveName))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// RestoreKVStoreBackup starts a restore of the KV store from the archive archiveName, e.g. backup.tar.gz, in the
+// kvstorebackup directory of $SPLUNK_DB. Use GetKVStoreStatus to check the completion of the restore.
+// On a search head cluster, use this on the captain, which replicates the restored KV store to the members.
+// See https://docs.splunk.com/Documentation/Splunk/latest/Admin/BackupKVstore
+func (c *SplunkClient) RestoreKVStoreBackup(archiveName string) error {
+	endpoint := fmt.Sprintf("%s/services/kvstore/backup/restore?archiveName=%s", c.ManagementURI, url.QueryEscape(archiveName))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// IndexInfo represents an index of a Splunk instance.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#data.2Findexes
+type IndexInfo struct {
+	// Name of the index
+	Name string `json:"-"`
+
+	// Indicates if the index is disabled
+	Disabled bool `json:"disabled"`
+
+	// Type of the index, event or metric
+	DataType string `json:"datatype"`
+}
+
+// GetIndexes queries the indexes of a Splunk instance, indexed by name.
+// Can be used for any Splunk Instance
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#data.2Findexes
+func (c *SplunkClient) GetIndexes() (map[string]IndexInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string    `json:"name"`
+			Content IndexInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/data/indexes"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make(map[string]IndexInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		indexes[e.Name] = e.Content
+	}
+	return indexes, nil
+}
+
+// RollHotBuckets rolls the hot buckets of an index to warm, so that the buckets on disk are consistent.
+// Can be used for any Splunk Instance
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTintrospect#data.2Findexes.2F.7Bname.7D.2Froll-hot-buckets
+func (c *SplunkClient) RollHotBuckets(index string) error {
+	endpoint := fmt.Sprintf("%s/services/data/indexes/%s/roll-hot-buckets", c.ManagementURI, url.PathEscape(index))
+	request, err := http.NewRequest("POST", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
 // RestartSplunk restarts specific Splunk instance
 // Can be used for any Splunk Instance
 // See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTsystem#server.2Fcontrol.2Frestart
@@ -935,3 +1578,297 @@ func (c *SplunkClient) RestartSplunk() error {
 	expectedStatus := []int{200}
 	return c.Do(request, expectedStatus, nil)
 }
+
+// LicenseInfo represents a license installed on a license manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Flicenses
+type LicenseInfo struct {
+	// Hash of the license, used to identify it
+	Hash string `json:"-"`
+
+	// Label of the license
+	Label string `json:"label"`
+
+	// Stack of the license, e.g. enterprise
+	StackID string `json:"stack_id"`
+
+	// Type of the license, e.g. enterprise
+	Type string `json:"type"`
+
+	// Status of the license, VALID or EXPIRED
+	Status string `json:"status"`
+
+	// Daily indexing volume of the license, in bytes
+	Quota int64 `json:"quota"`
+
+	// Expiration time of the license, in seconds since epoch
+	ExpirationTime int64 `json:"expiration_time"`
+}
+
+// GetLicenses queries the license manager for the installed licenses, indexed by hash.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Flicenses
+func (c *SplunkClient) GetLicenses() (map[string]LicenseInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string      `json:"name"`
+			Content LicenseInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/licenses"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	licenses := make(map[string]LicenseInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Hash = e.Name
+		licenses[e.Name] = e.Content
+	}
+	return licenses, nil
+}
+
+// AddLicense installs a license on the license manager, where payload is the content of the license file.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Flicenses
+func (c *SplunkClient) AddLicense(payload string) error {
+	endpoint := fmt.Sprintf("%s/services/licenser/licenses", c.ManagementURI)
+	reqBody := url.Values{"payload": {payload}}.Encode()
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200, 201}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// RemoveLicense removes a license from the license manager, where hash identifies the license.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Flicenses.2F.7Bname.7D
+func (c *SplunkClient) RemoveLicense(hash string) error {
+	endpoint := fmt.Sprintf("%s/services/licenser/licenses/%s", c.ManagementURI, url.PathEscape(hash))
+	request, err := http.NewRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// LicenseQuota is the quota of a license pool, as a number of bytes or MAX
+type LicenseQuota string
+
+// UnmarshalJSON reads a license quota from a JSON number or string
+func (q *LicenseQuota) UnmarshalJSON(data []byte) error {
+	var quota interface{}
+	err := json.Unmarshal(data, &quota)
+	if err != nil {
+		return err
+	}
+	switch value := quota.(type) {
+	case float64:
+		*q = LicenseQuota(strconv.FormatInt(int64(value), 10))
+	case string:
+		*q = LicenseQuota(value)
+	}
+	return nil
+}
+
+// LicensePoolInfo represents a license pool of a license manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools
+type LicensePoolInfo struct {
+	// Name of the pool
+	Name string `json:"-"`
+
+	// Stack of the pool, e.g. enterprise
+	StackID string `json:"stack_id"`
+
+	// Description of the pool
+	Description string `json:"description"`
+
+	// Daily indexing volume allocated to the pool, in bytes, or MAX for the whole volume of the stack
+	Quota LicenseQuota `json:"quota"`
+
+	// Indexing volume used by the pool today, in bytes
+	UsedBytes int64 `json:"used_bytes"`
+
+	// GUIDs of the license peers assigned to the pool, or * for any peer of the stack
+	Peers []string `json:"peers"`
+}
+
+// GetLicensePools queries the license manager for the license pools, indexed by name.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools
+func (c *SplunkClient) GetLicensePools() (map[string]LicensePoolInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string          `json:"name"`
+			Content LicensePoolInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/pools"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	pools := make(map[string]LicensePoolInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		pools[e.Name] = e.Content
+	}
+	return pools, nil
+}
+
+// SetLicensePool creates a license pool on the license manager, or updates the quota, description and peers of an
+// existing pool.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools
+func (c *SplunkClient) SetLicensePool(pool LicensePoolInfo, create bool) error {
+	endpoint := fmt.Sprintf("%s/services/licenser/pools/%s", c.ManagementURI, url.PathEscape(pool.Name))
+	values := url.Values{
+		"quota":       {string(pool.Quota)},
+		"description": {pool.Description},
+	}
+	if create {
+		endpoint = fmt.Sprintf("%s/services/licenser/pools", c.ManagementURI)
+		values.Set("name", pool.Name)
+		values.Set("stack_id", pool.StackID)
+	} else {
+		values.Set("append_peers", "false")
+	}
+	if len(pool.Peers) > 0 {
+		values.Set("peers", strings.Join(pool.Peers, ","))
+	}
+	request, err := http.NewRequest("POST", endpoint, strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200, 201}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// DeleteLicensePool deletes a license pool from the license manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpools.2F.7Bname.7D
+func (c *SplunkClient) DeleteLicensePool(name string) error {
+	endpoint := fmt.Sprintf("%s/services/licenser/pools/%s", c.ManagementURI, url.PathEscape(name))
+	request, err := http.NewRequest("DELETE", endpoint, nil)
+	if err != nil {
+		return err
+	}
+	expectedStatus := []int{200}
+	return c.Do(request, expectedStatus, nil)
+}
+
+// LicensePeerInfo represents a license peer of a license manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpeers
+type LicensePeerInfo struct {
+	// GUID of the peer
+	GUID string `json:"-"`
+
+	// Server name of the peer
+	Label string `json:"label"`
+
+	// Pools used by the peer
+	ActivePoolIDs []string `json:"active_pool_ids"`
+}
+
+// GetLicensePeers queries the license manager for its license peers, indexed by GUID.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fpeers
+func (c *SplunkClient) GetLicensePeers() (map[string]LicensePeerInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string          `json:"name"`
+			Content LicensePeerInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/peers"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	peers := make(map[string]LicensePeerInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.GUID = e.Name
+		peers[e.Name] = e.Content
+	}
+	return peers, nil
+}
+
+// LicenseStackInfo represents a license stack of a license manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fstacks
+type LicenseStackInfo struct {
+	// Name of the stack
+	Name string `json:"-"`
+
+	// Label of the stack
+	Label string `json:"label"`
+
+	// Daily indexing volume of the stack, in bytes
+	Quota int64 `json:"quota"`
+
+	// Type of the licenses of the stack
+	Type string `json:"type"`
+}
+
+// GetLicenseStacks queries the license manager for the license stacks, indexed by name.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fstacks
+func (c *SplunkClient) GetLicenseStacks() (map[string]LicenseStackInfo, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Name    string           `json:"name"`
+			Content LicenseStackInfo `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/stacks"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	stacks := make(map[string]LicenseStackInfo)
+	for _, e := range apiResponse.Entry {
+		e.Content.Name = e.Name
+		stacks[e.Name] = e.Content
+	}
+	return stacks, nil
+}
+
+// LicenseMessage represents a license warning of a license manager.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fmessages
+type LicenseMessage struct {
+	// Category of the message, e.g. pool_over_quota
+	Category string `json:"category"`
+
+	// Description of the message
+	Description string `json:"description"`
+
+	// Pool the message applies to
+	PoolID string `json:"pool_id"`
+
+	// Stack the message applies to
+	StackID string `json:"stack_id"`
+
+	// Severity of the message, e.g. WARN
+	Severity string `json:"severity"`
+
+	// Creation time of the message, in seconds since epoch
+	CreateTime int64 `json:"create_time"`
+}
+
+// GetLicenseMessages queries the license manager for its license warnings.
+// See https://docs.splunk.com/Documentation/Splunk/latest/RESTREF/RESTlicense#licenser.2Fmessages
+func (c *SplunkClient) GetLicenseMessages() ([]LicenseMessage, error) {
+	apiResponse := struct {
+		Entry []struct {
+			Content LicenseMessage `json:"content"`
+		} `json:"entry"`
+	}{}
+	path := "/services/licenser/messages"
+	err := c.Get(path, &apiResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []LicenseMessage
+	for _, e := range apiResponse.Entry {
+		messages = append(messages, e.Content)
+	}
+	return messages, nil
+}
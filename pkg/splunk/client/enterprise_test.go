@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -218,6 +219,201 @@ func TestBundlePush(t *testing.T) {
 	splunkClientErrorTester(t, test)
 }
 
+func TestValidateClusterManagerBundle(t *testing.T) {
+	body := strings.NewReader("&check-restart=true")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/default/validate_bundle", body)
+
+	test := func(c SplunkClient) error {
+		return c.ValidateClusterManagerBundle(true)
+	}
+	splunkClientTester(t, "TestValidateClusterManagerBundle", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestRollbackClusterManagerBundle(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/default/rollback", nil)
+	test := func(c SplunkClient) error {
+		return c.RollbackClusterManagerBundle()
+	}
+	splunkClientTester(t, "TestRollbackClusterManagerBundle", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestSetClusterManagerMaintenanceMode(t *testing.T) {
+	body := strings.NewReader("&mode=true")
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/default/maintenance", body)
+	test := func(c SplunkClient) error {
+		return c.SetClusterManagerMaintenanceMode(true)
+	}
+	splunkClientTester(t, "TestSetClusterManagerMaintenanceMode", 200, "", wantRequest, test)
+
+	body = strings.NewReader("&mode=false")
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/default/maintenance", body)
+	test = func(c SplunkClient) error {
+		return c.SetClusterManagerMaintenanceMode(false)
+	}
+	splunkClientTester(t, "TestSetClusterManagerMaintenanceMode", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestGetClusterManagerBucketFixups(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/manager/fixup?count=0&output_mode=json&level=replication_factor", nil)
+	test := func(c SplunkClient) error {
+		fixups, err := c.GetClusterManagerBucketFixups("replication_factor")
+		if err != nil {
+			return err
+		}
+		if fixups != 2 {
+			t.Errorf("fixups=%d; want 2", fixups)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"main~1~D39B1729-E2C5-4273-B9B2-534DA7C2F866","content":{"index":"main"}},{"name":"main~2~D39B1729-E2C5-4273-B9B2-534DA7C2F866","content":{"index":"main"}}]}`
+	splunkClientTester(t, "TestGetClusterManagerBucketFixups", 200, body, wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestGetClusterManagerStatus(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/manager/status?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		status, err := c.GetClusterManagerStatus()
+		if err != nil {
+			return err
+		}
+		if !status.RollingRestartOrUpgrade || !status.SearchableRolling {
+			t.Errorf("status should be in a searchable rolling upgrade. status: %v", status)
+		}
+		if len(status.RestartProgress.InProgress) != 1 || status.RestartProgress.InProgress[0] != "splunk-s1-indexer-0" {
+			t.Errorf("restart_progress.in_progress=%v; want [splunk-s1-indexer-0]", status.RestartProgress.InProgress)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"manager","content":{"maintenance_mode":false,"rolling_restart_or_upgrade":true,"searchable_rolling":true,"restart_progress":{"done":[],"failed":[],"in_progress":["splunk-s1-indexer-0"],"to_be_restarted":["splunk-s1-indexer-1"]}}}]}`
+	splunkClientTester(t, "TestGetClusterManagerStatus", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetClusterManagerStatus()
+		if err == nil {
+			t.Errorf("GetClusterManagerStatus returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetClusterManagerStatus", 200, `{"entry":[]}`, wantRequest, test)
+
+	// test error code
+	splunkClientTester(t, "TestGetClusterManagerStatus", 500, "", wantRequest, test)
+}
+
+func TestGetClusterManagerHealth(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/manager/health?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		health, err := c.GetClusterManagerHealth()
+		if err != nil {
+			return err
+		}
+		if health.AllPeersAreUp != "1" || health.ReplicationFactorMet != "1" || health.SearchFactorMet != "0" {
+			t.Errorf("unexpected cluster health %v", health)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"manager","content":{"all_data_is_searchable":"1","all_peers_are_up":"1","multisite":"0","no_fixup_tasks_in_progress":"0","replication_factor_met":"1","search_factor_met":"0"}}]}`
+	splunkClientTester(t, "TestGetClusterManagerHealth", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetClusterManagerHealth()
+		if err == nil {
+			t.Errorf("GetClusterManagerHealth returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetClusterManagerHealth", 200, `{"entry":[]}`, wantRequest, test)
+
+	// test error code
+	splunkClientTester(t, "TestGetClusterManagerHealth", 500, "", wantRequest, test)
+}
+
+func TestGetKVStoreStatus(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/kvstore/status?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		status, err := c.GetKVStoreStatus()
+		if err != nil {
+			return err
+		}
+		if status.Current.Status != "ready" || status.Current.ReplicationStatus != "KV store captain" || status.Current.BackupRestoreStatus != "Ready" {
+			t.Errorf("unexpected KV store status %v", status)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"status","content":{"current":{"status":"ready","replicationStatus":"KV store captain","backupRestoreStatus":"Ready"}}}]}`
+	splunkClientTester(t, "TestGetKVStoreStatus", 200, body, wantRequest, test)
+
+	// test error code
+	test = func(c SplunkClient) error {
+		_, err := c.GetKVStoreStatus()
+		if err == nil {
+			t.Errorf("GetKVStoreStatus returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetKVStoreStatus", 500, "", wantRequest, test)
+}
+
+func TestCreateKVStoreBackup(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/kvstore/backup/create?archiveName=example-20230102030405", nil)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.CreateKVStoreBackup("example-20230102030405")
+	}
+	splunkClientTester(t, "TestCreateKVStoreBackup", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestRestoreKVStoreBackup(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/kvstore/backup/restore?archiveName=example-20230102030405.tar.gz", nil)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.RestoreKVStoreBackup("example-20230102030405.tar.gz")
+	}
+	splunkClientTester(t, "TestRestoreKVStoreBackup", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestInitRollingUpgrade(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/control/rolling_upgrade_init", nil)
+	test := func(c SplunkClient) error {
+		return c.InitRollingUpgrade()
+	}
+	splunkClientTester(t, "TestInitRollingUpgrade", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestFinalizeRollingUpgrade(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/manager/control/control/rolling_upgrade_finalize", nil)
+	test := func(c SplunkClient) error {
+		return c.FinalizeRollingUpgrade()
+	}
+	splunkClientTester(t, "TestFinalizeRollingUpgrade", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
 func TestRemoveSearchHeadClusterMember(t *testing.T) {
 	// test for 200 response first (sent on first removal request)
 	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/member/consensus/default/remove_server?output_mode=json", nil)
@@ -285,6 +481,20 @@ func TestGetclusterManagerInfo(t *testing.T) {
 			Checksum:   "14310A4AABD23E85BBD4559C4A3B59F8",
 			Timestamp:  1583870198,
 		},
+		LastValidatedBundle: ClusterValidatedBundleInfo{
+			ClusterBundleInfo: ClusterBundleInfo{
+				BundlePath: "/opt/splunk/var/run/splunk/cluster/remote-bundle/0af7c0e95f313f7be3b0cb1d878df9a1-1583948640.bundle",
+				Checksum:   "14310A4AABD23E85BBD4559C4A3B59F8",
+				Timestamp:  1583948640,
+			},
+			IsValidBundle: true,
+		},
+		ApplyBundleStatus: ClusterApplyBundleStatus{
+			Status: "None",
+			InvalidBundle: ClusterInvalidBundleInfo{
+				ValidationErrors: []string{},
+			},
+		},
 		StartTime: 1583948636,
 	}
 	test := func(c SplunkClient) error {
@@ -292,7 +502,7 @@ func TestGetclusterManagerInfo(t *testing.T) {
 		if err != nil {
 			return err
 		}
-		if *gotInfo != wantInfo {
+		if !reflect.DeepEqual(*gotInfo, wantInfo) {
 			t.Errorf("info.Status=%v; want %v", *gotInfo, wantInfo)
 		}
 		return nil
@@ -346,6 +556,54 @@ func TestGetIndexerClusterPeerInfo(t *testing.T) {
 	splunkClientTester(t, "TestGetIndexerClusterPeerInfo", 500, "", wantRequest, test)
 }
 
+func TestGetIndexerQueueInfo(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/server/introspection/queues/indexqueue?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		queue, err := c.GetIndexerQueueInfo("indexqueue")
+		if err != nil {
+			return err
+		}
+		if queue.FillRatio() != 0.25 {
+			t.Errorf("queue.FillRatio()=%v; want 0.25", queue.FillRatio())
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"indexqueue","content":{"current_size":12,"current_size_bytes":131072,"largest_size":20,"max_size_bytes":524288}}]}`
+	splunkClientTester(t, "TestGetIndexerQueueInfo", 200, body, wantRequest, test)
+
+	// test body with no entries
+	test = func(c SplunkClient) error {
+		_, err := c.GetIndexerQueueInfo("indexqueue")
+		if err == nil {
+			t.Errorf("GetIndexerQueueInfo returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetIndexerQueueInfo", 200, `{"entry":[]}`, wantRequest, test)
+
+	// test error code
+	splunkClientTester(t, "TestGetIndexerQueueInfo", 500, "", wantRequest, test)
+}
+
+func TestGetIndexerThroughputInfo(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/server/introspection/indexer?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		throughput, err := c.GetIndexerThroughputInfo()
+		if err != nil {
+			return err
+		}
+		if throughput.AverageKBps != 1024.5 || throughput.Status != "normal" {
+			t.Errorf("unexpected throughput %v", throughput)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"indexer","content":{"average_KBps":1024.5,"reason":"","status":"normal"}}]}`
+	splunkClientTester(t, "TestGetIndexerThroughputInfo", 200, body, wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
 func TestGetClusterManagerPeers(t *testing.T) {
 	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/cluster/manager/peers?count=0&output_mode=json", nil)
 	var wantPeers = []struct {
@@ -494,6 +752,95 @@ func TestUpdateDMCClusteringLabelGroup(t *testing.T) {
 	splunkClientTester(t, "TestUpdateDMCClusteringLabelGroup", 201, "", wantRequest, test)
 }
 
+func TestGetMonitoringConsoleSavedSearch(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/servicesNS/nobody/splunk_monitoring_console/saved/searches/DMC%20Alert%20-%20Near%20Critical%20Disk%20Usage?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		savedSearch, err := c.GetMonitoringConsoleSavedSearch("DMC Alert - Near Critical Disk Usage")
+		if err != nil {
+			return err
+		}
+		if !savedSearch.Disabled || savedSearch.CronSchedule != "3,13,23,33,43,53 * * * *" || len(savedSearch.Args) != 1 || savedSearch.Args["disk_usage_threshold"] != "80" {
+			t.Errorf("unexpected saved search %v", savedSearch)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"DMC Alert - Near Critical Disk Usage","content":{"disabled":true,"cron_schedule":"3,13,23,33,43,53 * * * *","args.disk_usage_threshold":"80","search":"| rest"}}]}`
+	splunkClientTester(t, "TestGetMonitoringConsoleSavedSearch", 200, body, wantRequest, test)
+
+	// test missing saved search
+	test = func(c SplunkClient) error {
+		_, err := c.GetMonitoringConsoleSavedSearch("DMC Alert - Near Critical Disk Usage")
+		if err == nil {
+			t.Errorf("GetMonitoringConsoleSavedSearch returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetMonitoringConsoleSavedSearch", 404, "", wantRequest, test)
+}
+
+func TestUpdateMonitoringConsoleSavedSearch(t *testing.T) {
+	body := url.Values{"disabled": {"0"}, "cron_schedule": {"*/15 * * * *"}, "args.disk_usage_threshold": {"90"}}.Encode()
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/servicesNS/nobody/splunk_monitoring_console/saved/searches/DMC%20Alert%20-%20Near%20Critical%20Disk%20Usage", strings.NewReader(body))
+	test := func(c SplunkClient) error {
+		return c.UpdateMonitoringConsoleSavedSearch(MCSavedSearch{
+			Name:         "DMC Alert - Near Critical Disk Usage",
+			CronSchedule: "*/15 * * * *",
+			Args:         map[string]string{"disk_usage_threshold": "90"},
+		})
+	}
+	splunkClientTester(t, "TestUpdateMonitoringConsoleSavedSearch", 200, "", wantRequest, test)
+}
+
+func TestGetMonitoringConsoleDistributedPeers(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/search/distributed/peers?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		peers, err := c.GetMonitoringConsoleDistributedPeers()
+		if err != nil {
+			return err
+		}
+		peer, ok := peers["splunk-s1-standalone-0.splunk-s1-standalone-headless:8089"]
+		if len(peers) != 1 || !ok || peer.PeerName != "splunk-s1-standalone-0" || len(peer.ServerRoles) != 1 {
+			t.Errorf("unexpected peers %v", peers)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"splunk-s1-standalone-0.splunk-s1-standalone-headless:8089","content":{"peerName":"splunk-s1-standalone-0","server_roles":["indexer"],"cluster_label":[]}}]}`
+	splunkClientTester(t, "TestGetMonitoringConsoleDistributedPeers", 200, body, wantRequest, test)
+}
+
+func TestGetDMCGroups(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/search/distributed/groups?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		groups, err := c.GetDMCGroups()
+		if err != nil {
+			return err
+		}
+		if len(groups) != 2 || len(groups["dmc_group_indexer"]) != 2 || len(groups["dmc_customgroup_prod"]) != 0 {
+			t.Errorf("unexpected groups %v", groups)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"dmc_group_indexer","content":{"member":["localhost:localhost","splunk-s1-standalone-0:8089"]}},{"name":"dmc_customgroup_prod","content":{}}]}`
+	splunkClientTester(t, "TestGetDMCGroups", 200, body, wantRequest, test)
+}
+
+func TestCreateDMCGroup(t *testing.T) {
+	body := url.Values{"name": {"dmc_customgroup_prod"}, "member": {"peer1:8089", "peer2:8089"}, "default": {"false"}}.Encode()
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/search/distributed/groups", strings.NewReader(body))
+	test := func(c SplunkClient) error {
+		return c.CreateDMCGroup("dmc_customgroup_prod", []string{"peer1:8089", "peer2:8089"})
+	}
+	splunkClientTester(t, "TestCreateDMCGroup", 201, "", wantRequest, test)
+}
+
+func TestDeleteDMCGroup(t *testing.T) {
+	wantRequest, _ := http.NewRequest("DELETE", "https://localhost:8089/services/search/distributed/groups/dmc_customgroup_prod", nil)
+	test := func(c SplunkClient) error {
+		return c.DeleteDMCGroup("dmc_customgroup_prod")
+	}
+	splunkClientTester(t, "TestDeleteDMCGroup", 200, "", wantRequest, test)
+}
+
 func TestGetMonitoringconsoleAssetTable(t *testing.T) {
 	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/servicesNS/nobody/splunk_monitoring_console/saved/searches/DMC%20Asset%20-%20Build%20Full?count=0&output_mode=json", nil)
 	wantDispatchBuckets := int64(0)
@@ -642,6 +989,90 @@ func TestSetIdxcSecret(t *testing.T) {
 	splunkClientErrorTester(t, test)
 }
 
+func TestSetClusterSiteMappings(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/config/config?site_mappings=site2%3Asite1", nil)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.SetClusterSiteMappings("site2:site1")
+	}
+	splunkClientTester(t, "TestSetClusterSiteMappings", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestSetSearchHeadSite(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/cluster/config/config?site=site0", nil)
+	wantRequest.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	test := func(c SplunkClient) error {
+		return c.SetSearchHeadSite("site0")
+	}
+	splunkClientTester(t, "TestSetSearchHeadSite", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestRollingRestartSearchHeadCluster(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/captain/control/default/restart", nil)
+	test := func(c SplunkClient) error {
+		return c.RollingRestartSearchHeadCluster(false)
+	}
+	splunkClientTester(t, "TestRollingRestartSearchHeadCluster", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+
+	// Test searchable rolling restart
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/shcluster/captain/control/default/restart?searchable=true", nil)
+	test = func(c SplunkClient) error {
+		return c.RollingRestartSearchHeadCluster(true)
+	}
+	splunkClientTester(t, "TestRollingRestartSearchHeadCluster", 200, "", wantRequest, test)
+}
+
+func TestTransferSearchHeadCaptaincy(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/shcluster/member/consensus/default/transfer_captaincy?mgmt_uri=https%3A%2F%2Fsplunk-stack1-search-head-1%3A8089", nil)
+	test := func(c SplunkClient) error {
+		return c.TransferSearchHeadCaptaincy("https://splunk-stack1-search-head-1:8089")
+	}
+	splunkClientTester(t, "TestTransferSearchHeadCaptaincy", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestGetIndexes(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/data/indexes?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		indexes, err := c.GetIndexes()
+		if err != nil {
+			return err
+		}
+		if len(indexes) != 3 || indexes["main"].Disabled || !indexes["history"].Disabled || indexes["metrics"].DataType != "metric" || indexes["main"].Name != "main" {
+			t.Errorf("unexpected indexes %v", indexes)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"main","content":{"disabled":false,"datatype":"event"}},{"name":"history","content":{"disabled":true,"datatype":"event"}},
+		{"name":"metrics","content":{"disabled":false,"datatype":"metric"}}]}`
+	splunkClientTester(t, "TestGetIndexes", 200, body, wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
+func TestRollHotBuckets(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/data/indexes/main/roll-hot-buckets", nil)
+	test := func(c SplunkClient) error {
+		return c.RollHotBuckets("main")
+	}
+	splunkClientTester(t, "TestRollHotBuckets", 200, "", wantRequest, test)
+
+	// Negative testing
+	splunkClientErrorTester(t, test)
+}
+
 func TestRestartSplunk(t *testing.T) {
 	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/server/control/restart", nil)
 	test := func(c SplunkClient) error {
@@ -652,3 +1083,146 @@ func TestRestartSplunk(t *testing.T) {
 	// Test invalid http request
 	splunkClientErrorTester(t, test)
 }
+
+func TestGetLicenses(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/licenses?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		licenses, err := c.GetLicenses()
+		if err != nil {
+			return err
+		}
+		license, ok := licenses["ABCD1234"]
+		if len(licenses) != 1 || !ok || license.Hash != "ABCD1234" || license.StackID != "enterprise" || license.Quota != 107374182400 || license.ExpirationTime != 1893456000 {
+			t.Errorf("unexpected licenses %v", licenses)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"ABCD1234","content":{"label":"Splunk Enterprise","stack_id":"enterprise","type":"enterprise","status":"VALID","quota":107374182400,"expiration_time":1893456000}}]}`
+	splunkClientTester(t, "TestGetLicenses", 200, body, wantRequest, test)
+
+	// test error code
+	test = func(c SplunkClient) error {
+		_, err := c.GetLicenses()
+		if err == nil {
+			t.Errorf("GetLicenses returned nil; want error")
+		}
+		return nil
+	}
+	splunkClientTester(t, "TestGetLicenses", 500, "", wantRequest, test)
+}
+
+func TestAddLicense(t *testing.T) {
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/licenser/licenses", nil)
+	test := func(c SplunkClient) error {
+		return c.AddLicense("<license></license>")
+	}
+	splunkClientTester(t, "TestAddLicense", 201, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestRemoveLicense(t *testing.T) {
+	wantRequest, _ := http.NewRequest("DELETE", "https://localhost:8089/services/licenser/licenses/ABCD1234", nil)
+	test := func(c SplunkClient) error {
+		return c.RemoveLicense("ABCD1234")
+	}
+	splunkClientTester(t, "TestRemoveLicense", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestGetLicensePools(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/pools?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		pools, err := c.GetLicensePools()
+		if err != nil {
+			return err
+		}
+		if pools["auto_generated_pool_enterprise"].Quota != "MAX" || pools["prod"].Quota != "10737418240" || pools["prod"].UsedBytes != 1024 || len(pools["prod"].Peers) != 2 {
+			t.Errorf("unexpected pools %v", pools)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"auto_generated_pool_enterprise","content":{"stack_id":"enterprise","quota":"MAX","used_bytes":0,"peers":["*"]}},
+		{"name":"prod","content":{"stack_id":"enterprise","description":"production","quota":10737418240,"used_bytes":1024,"peers":["GUID1","GUID2"]}}]}`
+	splunkClientTester(t, "TestGetLicensePools", 200, body, wantRequest, test)
+}
+
+func TestSetLicensePool(t *testing.T) {
+	pool := LicensePoolInfo{Name: "prod", StackID: "enterprise", Quota: "MAX", Peers: []string{"GUID1", "GUID2"}}
+	wantRequest, _ := http.NewRequest("POST", "https://localhost:8089/services/licenser/pools", nil)
+	test := func(c SplunkClient) error {
+		return c.SetLicensePool(pool, true)
+	}
+	splunkClientTester(t, "TestSetLicensePool", 201, "", wantRequest, test)
+
+	wantRequest, _ = http.NewRequest("POST", "https://localhost:8089/services/licenser/pools/prod", nil)
+	test = func(c SplunkClient) error {
+		return c.SetLicensePool(pool, false)
+	}
+	splunkClientTester(t, "TestSetLicensePool", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestDeleteLicensePool(t *testing.T) {
+	wantRequest, _ := http.NewRequest("DELETE", "https://localhost:8089/services/licenser/pools/prod", nil)
+	test := func(c SplunkClient) error {
+		return c.DeleteLicensePool("prod")
+	}
+	splunkClientTester(t, "TestDeleteLicensePool", 200, "", wantRequest, test)
+
+	// Test invalid http request
+	splunkClientErrorTester(t, test)
+}
+
+func TestGetLicensePeers(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/peers?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		peers, err := c.GetLicensePeers()
+		if err != nil {
+			return err
+		}
+		if len(peers) != 1 || peers["GUID1"].GUID != "GUID1" || peers["GUID1"].Label != "splunk-stack1-standalone-0" {
+			t.Errorf("unexpected peers %v", peers)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"GUID1","content":{"label":"splunk-stack1-standalone-0","active_pool_ids":["auto_generated_pool_enterprise"]}}]}`
+	splunkClientTester(t, "TestGetLicensePeers", 200, body, wantRequest, test)
+}
+
+func TestGetLicenseStacks(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/stacks?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		stacks, err := c.GetLicenseStacks()
+		if err != nil {
+			return err
+		}
+		if len(stacks) != 1 || stacks["enterprise"].Quota != 107374182400 {
+			t.Errorf("unexpected stacks %v", stacks)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"enterprise","content":{"label":"Splunk Enterprise","quota":107374182400,"type":"enterprise"}}]}`
+	splunkClientTester(t, "TestGetLicenseStacks", 200, body, wantRequest, test)
+}
+
+func TestGetLicenseMessages(t *testing.T) {
+	wantRequest, _ := http.NewRequest("GET", "https://localhost:8089/services/licenser/messages?count=0&output_mode=json", nil)
+	test := func(c SplunkClient) error {
+		messages, err := c.GetLicenseMessages()
+		if err != nil {
+			return err
+		}
+		if len(messages) != 1 || messages[0].Category != "pool_over_quota" || messages[0].PoolID != "prod" {
+			t.Errorf("unexpected messages %v", messages)
+		}
+		return nil
+	}
+	body := `{"entry":[{"name":"1","content":{"category":"pool_over_quota","pool_id":"prod","stack_id":"enterprise","severity":"WARN","create_time":1700000000}}]}`
+	splunkClientTester(t, "TestGetLicenseMessages", 200, body, wantRequest, test)
+}
@@ -0,0 +1,347 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned when a request is not sent because the Splunk instance is known to be down
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+// reasons of the request errors reported to the request observer
+const (
+	// RequestErrorCircuitOpen is reported when the request is short-circuited
+	RequestErrorCircuitOpen = "CircuitOpen"
+
+	// RequestErrorTransport is reported when no response is received
+	RequestErrorTransport = "Transport"
+
+	// RequestErrorStatus is reported when the response has a server error status code
+	RequestErrorStatus = "ServerError"
+)
+
+// RequestObserver is called after each request sent to splunkd, with the status code of the response, or the reason
+// of the error when the request failed
+type RequestObserver func(host, method string, statusCode int, errorReason string, duration time.Duration)
+
+var requestObserver RequestObserver
+
+// SetRequestObserver sets the function called after each request sent by the SplunkClients of the factories
+func SetRequestObserver(observer RequestObserver) {
+	requestObserver = observer
+}
+
+// observeRequest reports a request to the request observer, if any
+func observeRequest(request *http.Request, statusCode int, errorReason string, duration time.Duration) {
+	if requestObserver != nil {
+		requestObserver(request.URL.Host, request.Method, statusCode, errorReason, duration)
+	}
+}
+
+// SplunkClientFactory creates SplunkClients which share their HTTP connections per management URI and credentials,
+// retry the idempotent requests with an exponential backoff, and stop sending requests to the Splunk instances which
+// are known to be down
+type SplunkClientFactory struct {
+	// maximum number of retries of the GET requests
+	MaxRetries int
+
+	// backoff before the first retry, doubled for each retry
+	RetryBackoff time.Duration
+
+	// maximum backoff between the retries
+	MaxRetryBackoff time.Duration
+
+	// maximum time of a request, including its retries
+	MaxRequestTime time.Duration
+
+	// time after which the HTTP client of a management URI and credentials which is not used any more is evicted
+	ClientIdleTTL time.Duration
+
+	// number of consecutive failures of a Splunk instance after which the requests are short-circuited
+	FailureThreshold int
+
+	// time during which the requests are short-circuited, before a request is sent to check the Splunk instance
+	OpenDuration time.Duration
+
+	mutex        sync.Mutex
+	clients      map[string]*splunkHTTPClient
+	breakers     map[string]*circuitBreaker
+	lastEviction time.Time
+}
+
+// NewSplunkClientFactory returns a new SplunkClientFactory with the default retry and circuit breaker settings
+func NewSplunkClientFactory() *SplunkClientFactory {
+	return &SplunkClientFactory{
+		MaxRetries:       3,
+		RetryBackoff:     250 * time.Millisecond,
+		MaxRetryBackoff:  2 * time.Second,
+		MaxRequestTime:   10 * time.Second,
+		ClientIdleTTL:    10 * time.Minute,
+		FailureThreshold: 5,
+		OpenDuration:     30 * time.Second,
+		clients:          map[string]*splunkHTTPClient{},
+		breakers:         map[string]*circuitBreaker{},
+	}
+}
+
+// defaultSplunkClientFactory is the factory used by NewSplunkClient and NewSplunkClientWithTLSConfig
+var defaultSplunkClientFactory = NewSplunkClientFactory()
+
+// NewSplunkClient returns a new SplunkClient object initialized with a username and password, which connects to the
+// management interface with tlsConfig. The HTTP connections are shared with the other clients of the factory with the
+// same management URI, credentials and TLS configuration
+func (f *SplunkClientFactory) NewSplunkClient(managementURI, username, password string, tlsConfig *tls.Config) *SplunkClient {
+	return &SplunkClient{
+		ManagementURI: managementURI,
+		Username:      username,
+		Password:      password,
+		Client:        f.getHTTPClient(managementURI, username, password, tlsConfig),
+	}
+}
+
+// getHTTPClient returns the pooled HTTP client of a management URI and credentials, which is replaced when the TLS
+// configuration changes
+func (f *SplunkClientFactory) getHTTPClient(managementURI, username, password string, tlsConfig *tls.Config) *splunkHTTPClient {
+	key := fmt.Sprintf("%s|%s|%x", managementURI, username, sha256.Sum256([]byte(password)))
+	host := managementURI
+	if u, err := url.Parse(managementURI); err == nil && u.Host != "" {
+		host = u.Host
+	}
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	now := time.Now()
+	f.evictIdleClients(now)
+	client, ok := f.clients[key]
+	if ok && isTLSConfigEqual(client.tlsConfig, tlsConfig) {
+		client.lastUsed = now
+		return client
+	}
+	if ok {
+		client.transport.CloseIdleConnections()
+	}
+
+	breaker, ok := f.breakers[host]
+	if !ok {
+		breaker = &circuitBreaker{threshold: f.FailureThreshold, openDuration: f.OpenDuration}
+		f.breakers[host] = breaker
+	}
+	transport := &http.Transport{
+		TLSClientConfig:     tlsConfig,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+	client = &splunkHTTPClient{
+		client:    &http.Client{Timeout: 5 * time.Second, Transport: transport},
+		transport: transport,
+		tlsConfig: tlsConfig,
+		host:      host,
+		breaker:   breaker,
+		factory:   f,
+		lastUsed:  now,
+	}
+	f.clients[key] = client
+	return client
+}
+
+// evictIdleClients evicts the HTTP clients which were not used within the idle TTL, e.g. after the deletion of a
+// Splunk instance or a change of its credentials, with the circuit breakers of the instances they connected to.
+// The factory mutex must be held
+func (f *SplunkClientFactory) evictIdleClients(now time.Time) {
+	if f.ClientIdleTTL <= 0 || now.Sub(f.lastEviction) < f.ClientIdleTTL/10 {
+		return
+	}
+	f.lastEviction = now
+
+	hosts := map[string]bool{}
+	for key, client := range f.clients {
+		if now.Sub(client.lastUsed) >= f.ClientIdleTTL {
+			client.transport.CloseIdleConnections()
+			delete(f.clients, key)
+			continue
+		}
+		hosts[client.host] = true
+	}
+	for host := range f.breakers {
+		if !hosts[host] {
+			delete(f.breakers, host)
+		}
+	}
+}
+
+// isTLSConfigEqual returns true if two TLS configurations verify the certificates the same way
+func isTLSConfigEqual(a, b *tls.Config) bool {
+	if a == b {
+		return true
+	}
+	if a == nil || b == nil {
+		return false
+	}
+	if a.InsecureSkipVerify != b.InsecureSkipVerify || a.ServerName != b.ServerName {
+		return false
+	}
+	if a.RootCAs == nil || b.RootCAs == nil {
+		return a.RootCAs == b.RootCAs
+	}
+	return a.RootCAs.Equal(b.RootCAs)
+}
+
+// splunkHTTPClient is the pooled HTTP client of a management URI and credentials
+type splunkHTTPClient struct {
+	client    *http.Client
+	transport *http.Transport
+	tlsConfig *tls.Config
+	host      string
+	breaker   *circuitBreaker
+	factory   *SplunkClientFactory
+
+	// last time the HTTP client was returned by the factory, guarded by the factory mutex
+	lastUsed time.Time
+}
+
+// cancelReadCloser releases the context of a request once its response body is closed
+type cancelReadCloser struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+// Close closes the response body and releases the context of the request
+func (r *cancelReadCloser) Close() error {
+	defer r.cancel()
+	return r.ReadCloser.Close()
+}
+
+// isRetryableStatus returns true if the status code is returned by splunkd while it is not available
+func isRetryableStatus(statusCode int) bool {
+	return statusCode == http.StatusBadGateway || statusCode == http.StatusServiceUnavailable || statusCode == http.StatusGatewayTimeout
+}
+
+// Do sends a request, unless the circuit breaker of the Splunk instance is open. GET requests are retried with an
+// exponential backoff when no response is received or splunkd is not available, within the maximum request time
+func (c *splunkHTTPClient) Do(request *http.Request) (*http.Response, error) {
+	cancel := context.CancelFunc(func() {})
+	if c.factory.MaxRequestTime > 0 {
+		var ctx context.Context
+		ctx, cancel = context.WithTimeout(request.Context(), c.factory.MaxRequestTime)
+		request = request.WithContext(ctx)
+	}
+	deadline, hasDeadline := request.Context().Deadline()
+
+	backoff := c.factory.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		if !c.breaker.allow() {
+			cancel()
+			observeRequest(request, 0, RequestErrorCircuitOpen, 0)
+			return nil, fmt.Errorf("%w for %s", ErrCircuitOpen, request.URL.Host)
+		}
+
+		start := time.Now()
+		response, err := c.client.Do(request)
+		duration := time.Since(start)
+		retryable := false
+		switch {
+		case err != nil:
+			reason := getTLSVerificationFailureReason(err)
+			if reason == "" {
+				// certificate verification failures do not mean the instance is down
+				reason = RequestErrorTransport
+				retryable = true
+			}
+			observeRequest(request, 0, reason, duration)
+		case isRetryableStatus(response.StatusCode):
+			observeRequest(request, response.StatusCode, RequestErrorStatus, duration)
+			retryable = true
+		default:
+			observeRequest(request, response.StatusCode, "", duration)
+		}
+		c.breaker.record(!retryable)
+
+		// no retry once the maximum request time would be exceeded
+		if !retryable || request.Method != http.MethodGet || attempt >= c.factory.MaxRetries || (hasDeadline && time.Now().Add(backoff).After(deadline)) {
+			if response == nil {
+				cancel()
+				return response, err
+			}
+			response.Body = &cancelReadCloser{ReadCloser: response.Body, cancel: cancel}
+			return response, err
+		}
+		if response != nil {
+			_, _ = io.Copy(io.Discard, response.Body)
+			response.Body.Close()
+		}
+		select {
+		case <-request.Context().Done():
+			cancel()
+			return nil, request.Context().Err()
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+		if backoff > c.factory.MaxRetryBackoff {
+			backoff = c.factory.MaxRetryBackoff
+		}
+	}
+}
+
+// circuitBreaker short-circuits the requests sent to a Splunk instance after consecutive failures. Once the open
+// duration is elapsed, a single request is sent to check the instance, and closes the circuit if it succeeds
+type circuitBreaker struct {
+	mutex        sync.Mutex
+	threshold    int
+	openDuration time.Duration
+	failures     int
+	openUntil    time.Time
+}
+
+// allow returns true if a request can be sent
+func (b *circuitBreaker) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.threshold <= 0 || b.failures < b.threshold {
+		return true
+	}
+	now := time.Now()
+	if now.Before(b.openUntil) {
+		return false
+	}
+	// single request to check the instance, the others are short-circuited until it completes
+	b.openUntil = now.Add(b.openDuration)
+	return true
+}
+
+// record records the outcome of a request
+func (b *circuitBreaker) record(success bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if success {
+		b.failures = 0
+		b.openUntil = time.Time{}
+		return
+	}
+	b.failures++
+	if b.failures >= b.threshold {
+		b.openUntil = time.Now().Add(b.openDuration)
+	}
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newTestSplunkClientFactory() *SplunkClientFactory {
+	f := NewSplunkClientFactory()
+	f.RetryBackoff = time.Millisecond
+	f.MaxRetryBackoff = 2 * time.Millisecond
+	f.OpenDuration = 50 * time.Millisecond
+	return f
+}
+
+func TestSplunkClientFactoryPooling(t *testing.T) {
+	f := newTestSplunkClientFactory()
+	insecure := &tls.Config{InsecureSkipVerify: true}
+
+	c1 := f.NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd", insecure)
+	c2 := f.NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd", &tls.Config{InsecureSkipVerify: true})
+	if c1.Client != c2.Client {
+		t.Errorf("clients with the same management URI and credentials should share their HTTP client")
+	}
+	c3 := f.NewSplunkClient("https://localhost:8089", "admin", "changed", insecure)
+	if c1.Client == c3.Client {
+		t.Errorf("clients with different credentials should not share their HTTP client")
+	}
+	c4 := f.NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd", &tls.Config{ServerName: "splunk"})
+	if c1.Client == c4.Client || c4.GetTLSConfig().ServerName != "splunk" {
+		t.Errorf("HTTP client should be replaced when the TLS configuration changes")
+	}
+	if c1.Client.(*splunkHTTPClient).breaker != c3.Client.(*splunkHTTPClient).breaker {
+		t.Errorf("clients of the same instance should share their circuit breaker")
+	}
+}
+
+func TestSplunkClientFactoryRetries(t *testing.T) {
+	var requests, failures int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var observed []string
+	SetRequestObserver(func(host, method string, statusCode int, errorReason string, duration time.Duration) {
+		observed = append(observed, errorReason)
+	})
+	defer SetRequestObserver(nil)
+
+	f := newTestSplunkClientFactory()
+	c := f.NewSplunkClient(server.URL, "admin", "p@ssw0rd", &tls.Config{InsecureSkipVerify: true})
+
+	// GET requests are retried while splunkd is not available
+	failures = 2
+	err := c.Get("/services/server/info", nil)
+	if err != nil || requests != 3 {
+		t.Errorf("Get() should be retried. err: %v, requests: %d", err, requests)
+	}
+	if len(observed) != 3 || observed[0] != RequestErrorStatus || observed[2] != "" {
+		t.Errorf("requests should be observed, got %v", observed)
+	}
+
+	// retries are limited
+	requests = 0
+	failures = 10
+	err = c.Get("/services/server/info", nil)
+	if err == nil || requests != int32(f.MaxRetries+1) {
+		t.Errorf("Get() should fail after the retries. err: %v, requests: %d", err, requests)
+	}
+
+	// other requests are not retried
+	requests = 0
+	failures = 1
+	err = c.RestartSplunk()
+	if err == nil || requests != 1 {
+		t.Errorf("POST requests should not be retried. err: %v, requests: %d", err, requests)
+	}
+}
+
+func TestSplunkClientFactoryCircuitBreaker(t *testing.T) {
+	var requests, failures int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if atomic.AddInt32(&failures, -1) >= 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	f := newTestSplunkClientFactory()
+	f.MaxRetries = 0
+	c := f.NewSplunkClient(server.URL, "admin", "p@ssw0rd", &tls.Config{InsecureSkipVerify: true})
+
+	// circuit opens after consecutive failures
+	failures = int32(f.FailureThreshold)
+	for i := 0; i < f.FailureThreshold; i++ {
+		_ = c.Get("/services/server/info", nil)
+	}
+	err := c.Get("/services/server/info", nil)
+	if !errors.Is(err, ErrCircuitOpen) || requests != int32(f.FailureThreshold) {
+		t.Errorf("requests should be short-circuited. err: %v, requests: %d", err, requests)
+	}
+
+	// other clients of the instance are short-circuited
+	other := f.NewSplunkClient(server.URL, "admin", "changed", &tls.Config{InsecureSkipVerify: true})
+	err = other.Get("/services/server/info", nil)
+	if !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("requests of the other clients should be short-circuited. err: %v", err)
+	}
+
+	// circuit closes once a request succeeds after the open duration
+	time.Sleep(f.OpenDuration)
+	err = c.Get("/services/server/info", nil)
+	if err != nil || requests != int32(f.FailureThreshold)+1 {
+		t.Errorf("request should be sent after the open duration. err: %v, requests: %d", err, requests)
+	}
+	err = c.Get("/services/server/info", nil)
+	if err != nil {
+		t.Errorf("circuit should be closed. err: %v", err)
+	}
+}
+
+func TestSplunkClientFactoryEviction(t *testing.T) {
+	f := newTestSplunkClientFactory()
+	f.ClientIdleTTL = 50 * time.Millisecond
+	insecure := &tls.Config{InsecureSkipVerify: true}
+
+	c1 := f.NewSplunkClient("https://splunk-stack1-indexer-0:8089", "admin", "p@ssw0rd", insecure)
+	time.Sleep(f.ClientIdleTTL)
+
+	// clients which are not used within the idle TTL are evicted, with the circuit breakers of their instances
+	c2 := f.NewSplunkClient("https://splunk-stack1-indexer-1:8089", "admin", "p@ssw0rd", insecure)
+	if len(f.clients) != 1 || len(f.breakers) != 1 || f.breakers["splunk-stack1-indexer-1:8089"] == nil {
+		t.Errorf("idle HTTP client should be evicted. clients: %d, breakers: %d", len(f.clients), len(f.breakers))
+	}
+	c3 := f.NewSplunkClient("https://splunk-stack1-indexer-0:8089", "admin", "p@ssw0rd", insecure)
+	if c1.Client == c3.Client {
+		t.Errorf("evicted HTTP client should be replaced")
+	}
+	c4 := f.NewSplunkClient("https://splunk-stack1-indexer-1:8089", "admin", "p@ssw0rd", insecure)
+	if c2.Client != c4.Client {
+		t.Errorf("HTTP client in use should not be evicted")
+	}
+}
+
+func TestSplunkClientFactoryMaxRequestTime(t *testing.T) {
+	var requests int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	f := newTestSplunkClientFactory()
+	f.MaxRetries = 100
+	f.RetryBackoff = 20 * time.Millisecond
+	f.MaxRetryBackoff = 20 * time.Millisecond
+	f.FailureThreshold = 0
+	f.MaxRequestTime = 100 * time.Millisecond
+	c := f.NewSplunkClient(server.URL, "admin", "p@ssw0rd", &tls.Config{InsecureSkipVerify: true})
+
+	// retries stop once the maximum request time would be exceeded
+	start := time.Now()
+	err := c.Get("/services/server/info", nil)
+	if err == nil || time.Since(start) > 2*f.MaxRequestTime || requests > 6 {
+		t.Errorf("Get() should fail within the maximum request time. err: %v, requests: %d, elapsed: %v", err, requests, time.Since(start))
+	}
+}
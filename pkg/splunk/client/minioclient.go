@@ -36,6 +36,8 @@ var _ RemoteDataClient = &MinioClient{}
 type SplunkMinioClient interface {
 	ListObjects(ctx context.Context, bucketName string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
 	FGetObject(ctx context.Context, bucketName string, remoteFileName string, localFileName string, opts minio.GetObjectOptions) error
+	FPutObject(ctx context.Context, bucketName string, objectName string, filePath string, opts minio.PutObjectOptions) (minio.UploadInfo, error)
+	RemoveObject(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error
 }
 
 // MinioClient is a client to implement S3 specific APIs
@@ -150,7 +152,7 @@ func (client *MinioClient) GetAppsList(ctx context.Context) (RemoteDataListRespo
 	opts := minio.ListObjectsOptions{
 		UseV1:     true,
 		Prefix:    client.Prefix,
-		Recursive: false,
+		Recursive: true, // list all the levels, to get the objects of the exploded app directories
 	}
 
 	// List all objects from a bucket-name with a matching prefix.
@@ -203,3 +205,40 @@ func (client *MinioClient) DownloadApp(ctx context.Context, downloadRequest Remo
 
 	return true, nil
 }
+
+// UploadFile uploads a local file to remote storage
+func (client *MinioClient) UploadFile(ctx context.Context, uploadRequest RemoteDataUploadRequest) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("UploadFile").WithValues("remoteFile", uploadRequest.RemoteFile,
+		"localFile", uploadRequest.LocalFile)
+
+	s3Client := client.Client
+
+	_, err := s3Client.FPutObject(ctx, client.BucketName, uploadRequest.RemoteFile, uploadRequest.LocalFile, minio.PutObjectOptions{})
+	if err != nil {
+		scopedLog.Error(err, "Unable to upload local file")
+		return err
+	}
+
+	scopedLog.Info("File uploaded")
+
+	return nil
+}
+
+// DeleteFile deletes a file from remote storage
+func (client *MinioClient) DeleteFile(ctx context.Context, remoteFile string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("DeleteFile").WithValues("remoteFile", remoteFile)
+
+	s3Client := client.Client
+
+	err := s3Client.RemoveObject(ctx, client.BucketName, remoteFile, minio.RemoveObjectOptions{})
+	if err != nil {
+		scopedLog.Error(err, "Unable to delete remote file")
+		return err
+	}
+
+	scopedLog.Info("File deleted")
+
+	return nil
+}
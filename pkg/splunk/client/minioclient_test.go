@@ -507,3 +507,35 @@ func TestMinioDownloadAppShouldFail(t *testing.T) {
 		t.Errorf("DownloadApp should have returned error since remoteFile name is empty")
 	}
 }
+
+func TestMinioUploadAndDeleteFile(t *testing.T) {
+	ctx := context.TODO()
+	minioClient := &MinioClient{
+		BucketName: "backups",
+		Client:     spltest.MockMinioS3Client{},
+	}
+
+	uploadRequest := RemoteDataUploadRequest{
+		LocalFile:  "/tmp/kvstore.tar.gz",
+		RemoteFile: "backups/kvstore.tar.gz",
+	}
+	err := minioClient.UploadFile(ctx, uploadRequest)
+	if err != nil {
+		t.Errorf("UploadFile should not fail: %v", err)
+	}
+
+	err = minioClient.UploadFile(ctx, RemoteDataUploadRequest{LocalFile: "/tmp/kvstore.tar.gz"})
+	if err == nil {
+		t.Errorf("UploadFile should fail for an empty remote file")
+	}
+
+	err = minioClient.DeleteFile(ctx, uploadRequest.RemoteFile)
+	if err != nil {
+		t.Errorf("DeleteFile should not fail: %v", err)
+	}
+
+	err = minioClient.DeleteFile(ctx, "")
+	if err == nil {
+		t.Errorf("DeleteFile should fail for an empty remote file")
+	}
+}
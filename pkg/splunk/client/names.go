@@ -25,6 +25,14 @@ const (
 	// For example : https://mystorageaccount.blob.core.windows.net/myappsbucket/standlone/myappsteamapp.tgz
 	azureBlobDownloadAppFetchURL = "%s/%s/%s"
 
+	// Azure URL for uploading or deleting a blob
+	// URL format is {azure_end_point}/{bucketName}/{pathToBlob}
+	// For example : https://mystorageaccount.blob.core.windows.net/mybackupsbucket/backups/kvstore.tar.gz
+	azureBlobURL = "%s/%s/%s"
+
+	// Blob type of the uploaded blobs
+	azureBlobTypeBlockBlob = "BlockBlob"
+
 	// Header strings
 	headerAuthorization      = "Authorization"
 	headerCacheControl       = "Cache-Control"
@@ -41,6 +49,7 @@ const (
 	headerIfUnmodifiedSince  = "If-Unmodified-Since"
 	headerRange              = "Range"
 	headerUserAgent          = "User-Agent"
+	headerXmsBlobType        = "x-ms-blob-type"
 	headerXmsDate            = "x-ms-date"
 	headerXmsVersion         = "x-ms-version"
 
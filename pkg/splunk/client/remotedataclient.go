@@ -60,8 +60,16 @@ type RemoteDataDownloadRequest struct {
 	Etag       string // unique tag of the object
 }
 
+// RemoteDataUploadRequest struct specifies the local file path
+// and the remote data file path where the local file should be written
+type RemoteDataUploadRequest struct {
+	LocalFile  string // file path of the data to upload
+	RemoteFile string // file name with path relative to the bucket
+}
+
 // RemoteDataClient is an interface to provide
-// listing and downloading of app packages from remote data storage
+// listing and downloading of app packages from remote data storage,
+// as well as uploading and deleting the backups
 type RemoteDataClient interface {
 
 	// Get the list of App packages
@@ -69,6 +77,12 @@ type RemoteDataClient interface {
 
 	// Download a given app package as per the inputs provided in the `RemoteDataClientRequest`
 	DownloadApp(context.Context, RemoteDataDownloadRequest) (bool /* return pass/fail */, error)
+
+	// Upload a given local file as per the inputs provided in the `RemoteDataUploadRequest`
+	UploadFile(context.Context, RemoteDataUploadRequest) error
+
+	// Delete a given file, with path relative to the bucket
+	DeleteFile(context.Context, string) error
 }
 
 // GetRemoteDataClientWrapper is a wrapper around init function pointers
@@ -0,0 +1,97 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+// reasons of the certificate verification failures
+const (
+	// TLSUnknownAuthority is reported when the certificate is not signed by the CA bundle
+	TLSUnknownAuthority = "UnknownAuthority"
+
+	// TLSHostnameMismatch is reported when the certificate is not valid for the server name
+	TLSHostnameMismatch = "HostnameMismatch"
+
+	// TLSInvalidCertificate is reported when the certificate is expired or can not be used by a server
+	TLSInvalidCertificate = "InvalidCertificate"
+
+	// TLSVerificationFailed is reported for the other certificate verification failures
+	TLSVerificationFailed = "VerificationFailed"
+)
+
+// TLSVerificationError is returned when the certificate of the management interface fails the verification
+type TLSVerificationError struct {
+	// host of the management interface
+	Host string
+
+	// reason of the failure, e.g. UnknownAuthority
+	Reason string
+
+	// error returned by the HTTP client
+	Err error
+}
+
+// Error returns the message of the TLS verification error
+func (e *TLSVerificationError) Error() string {
+	return fmt.Sprintf("certificate verification of %s failed (%s): %v", e.Host, e.Reason, e.Err)
+}
+
+// Unwrap returns the error returned by the HTTP client
+func (e *TLSVerificationError) Unwrap() error {
+	return e.Err
+}
+
+// NewTLSConfig returns the TLS configuration used to connect to the management interface. The certificates are
+// verified with the PEM encoded CA bundle, and the server name if not empty, unless insecureSkipVerify is true
+func NewTLSConfig(caBundle []byte, serverName string, insecureSkipVerify bool) (*tls.Config, error) {
+	if insecureSkipVerify {
+		return &tls.Config{InsecureSkipVerify: true}, nil
+	}
+	rootCAs := x509.NewCertPool()
+	if !rootCAs.AppendCertsFromPEM(caBundle) {
+		return nil, fmt.Errorf("no valid PEM certificate found in the CA bundle")
+	}
+	return &tls.Config{
+		RootCAs:    rootCAs,
+		ServerName: serverName,
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
+
+// getTLSVerificationFailureReason returns the reason of a certificate verification failure, or an empty string if
+// err is not a certificate verification failure
+func getTLSVerificationFailureReason(err error) string {
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var verificationErr *tls.CertificateVerificationError
+	switch {
+	case errors.As(err, &unknownAuthorityErr):
+		return TLSUnknownAuthority
+	case errors.As(err, &hostnameErr):
+		return TLSHostnameMismatch
+	case errors.As(err, &certificateInvalidErr):
+		return TLSInvalidCertificate
+	case errors.As(err, &verificationErr):
+		return TLSVerificationFailed
+	}
+	return ""
+}
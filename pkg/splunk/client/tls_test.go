@@ -0,0 +1,101 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	tlsConfig, err := NewTLSConfig(nil, "", true)
+	if err != nil || !tlsConfig.InsecureSkipVerify {
+		t.Errorf("NewTLSConfig() should skip the verification. err: %v", err)
+	}
+
+	_, err = NewTLSConfig([]byte("not a certificate"), "", false)
+	if err == nil {
+		t.Errorf("NewTLSConfig() should fail with an invalid CA bundle")
+	}
+}
+
+func TestSplunkClientTLSVerification(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	test := func(caBundle []byte, serverName string, wantReason string) {
+		tlsConfig, err := NewTLSConfig(caBundle, serverName, false)
+		if err != nil {
+			t.Fatalf("NewTLSConfig() returned error: %v", err)
+		}
+		c := NewSplunkClientWithTLSConfig(server.URL, "admin", "p@ssw0rd", tlsConfig)
+		var failed *TLSVerificationError
+		c.TLSVerificationFailed = func(err *TLSVerificationError) {
+			failed = err
+		}
+		err = c.Get("/services/server/info", nil)
+		if wantReason == "" {
+			if err != nil || failed != nil {
+				t.Errorf("Get() should verify the certificate. err: %v", err)
+			}
+			return
+		}
+		var verificationErr *TLSVerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Reason != wantReason {
+			t.Errorf("Get() should fail the verification with %s. err: %v", wantReason, err)
+		}
+		if failed == nil || failed.Reason != wantReason {
+			t.Errorf("TLSVerificationFailed should be called with %s. got: %v", wantReason, failed)
+		}
+	}
+
+	test(caBundle, "", "")
+	test(caBundle, "example.com", "")
+	test(caBundle, "splunk.example.org", TLSHostnameMismatch)
+
+	// certificate of another authority
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey() returned error: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "other-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("CreateCertificate() returned error: %v", err)
+	}
+	test(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), "", TLSUnknownAuthority)
+}
@@ -34,11 +34,14 @@ import (
 )
 
 // DefaultStatefulSetPodManager is a simple StatefulSetPodManager that does nothing
-type DefaultStatefulSetPodManager struct{}
+type DefaultStatefulSetPodManager struct {
+	// VolumeResize receives the progress of the volume expansion of the pods, if not nil
+	VolumeResize *[]enterpriseApi.VolumeResizeStatus
+}
 
 // Update for DefaultStatefulSetPodManager handles all updates for a statefulset of standard pods
 func (mgr *DefaultStatefulSetPodManager) Update(ctx context.Context, client splcommon.ControllerClient, statefulSet *appsv1.StatefulSet, desiredReplicas int32) (enterpriseApi.Phase, error) {
-	phase, err := ApplyStatefulSet(ctx, client, statefulSet)
+	phase, err := ApplyStatefulSetWithVolumeResize(ctx, client, statefulSet, mgr.VolumeResize)
 	if err == nil && phase == enterpriseApi.PhaseReady {
 		phase, err = UpdateStatefulSetPods(ctx, client, statefulSet, mgr, desiredReplicas)
 	}
@@ -62,6 +65,13 @@ func (mgr *DefaultStatefulSetPodManager) FinishRecycle(ctx context.Context, n in
 
 // ApplyStatefulSet creates or updates a Kubernetes StatefulSet
 func ApplyStatefulSet(ctx context.Context, c splcommon.ControllerClient, revised *appsv1.StatefulSet) (enterpriseApi.Phase, error) {
+	return ApplyStatefulSetWithVolumeResize(ctx, c, revised, nil)
+}
+
+// ApplyStatefulSetWithVolumeResize creates or updates a Kubernetes StatefulSet, expanding the PVCs of its pods when
+// the capacity of its volume claim templates is increased. The progress of the expansion is returned in volumeResize,
+// if not nil
+func ApplyStatefulSetWithVolumeResize(ctx context.Context, c splcommon.ControllerClient, revised *appsv1.StatefulSet, volumeResize *[]enterpriseApi.VolumeResizeStatus) (enterpriseApi.Phase, error) {
 	namespacedName := types.NamespacedName{Namespace: revised.GetNamespace(), Name: revised.GetName()}
 	var current appsv1.StatefulSet
 
@@ -79,11 +89,30 @@ func ApplyStatefulSet(ctx context.Context, c splcommon.ControllerClient, revised
 
 		// no StatefulSet exists -> just create a new one
 		err = splutil.CreateResource(ctx, c, revised)
+		if err == nil && volumeResize != nil {
+			*volumeResize = nil
+		}
 		return enterpriseApi.PhasePending, err
 	}
 
 	// found an existing StatefulSet
 
+	// wait for the StatefulSet deleted after a volume expansion to be gone, before recreating it
+	if current.GetDeletionTimestamp() != nil {
+		*revised = current
+		return enterpriseApi.PhaseUpdating, nil
+	}
+
+	// expand the PVCs of the pods, if the capacity of the volume claim templates is increased
+	resizeStatus, resizing, err := resizeStatefulSetVolumes(ctx, c, &current, revised)
+	if volumeResize != nil {
+		*volumeResize = resizeStatus
+	}
+	if err != nil || resizing {
+		*revised = current
+		return enterpriseApi.PhaseUpdating, err
+	}
+
 	// check for changes in Pod template
 	hasUpdates := MergePodUpdates(ctx, &current.Spec.Template, &revised.Spec.Template, current.GetObjectMeta().GetName())
 	*revised = current // caller expects that object passed represents latest state
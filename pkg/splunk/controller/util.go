@@ -331,6 +331,15 @@ func MergeServiceSpecUpdates(ctx context.Context, current *corev1.ServiceSpec, r
 		result = true
 	}
 
+	// check for changes in Selector
+	if len(revised.Selector) > 0 && !reflect.DeepEqual(current.Selector, revised.Selector) {
+		scopedLog.Info("Service Selector differs",
+			"current", current.Selector,
+			"revised", revised.Selector)
+		current.Selector = revised.Selector
+		result = true
+	}
+
 	return result
 }
 
@@ -263,6 +263,12 @@ func TestMergeServiceSpecUpdates(t *testing.T) {
 	matcher = func() bool { return current.Type == revised.Type }
 	svcUpdateTester("Service Type changed")
 
+	// Selector change
+	current.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-stack1-cluster-manager"}
+	revised.Selector = map[string]string{"app.kubernetes.io/instance": "splunk-stack1-cluster-manager-standby"}
+	matcher = func() bool { return reflect.DeepEqual(current.Selector, revised.Selector) }
+	svcUpdateTester("Service Selector changed")
+
 	current.ExternalName = "splunk.example.com"
 	revised.ExternalName = "splunk2.example.com"
 	matcher = func() bool { return current.ExternalName == revised.ExternalName }
@@ -0,0 +1,208 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// resizeStatefulSetVolumes expands the PVCs of the StatefulSet pods when the storage capacity of the revised volume
+// claim templates is increased. The volume claim templates being immutable, the StatefulSet is deleted without its
+// pods once all the PVCs are expanded, to be recreated with the revised templates. The increase is skipped when a PVC
+// can not be expanded. It returns the progress of the expansion, and true while the expansion or the deletion is in
+// progress.
+func resizeStatefulSetVolumes(ctx context.Context, c splcommon.ControllerClient, current *appsv1.StatefulSet, revised *appsv1.StatefulSet) ([]enterpriseApi.VolumeResizeStatus, bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("resizeStatefulSetVolumes").WithValues(
+		"name", current.GetObjectMeta().GetName(),
+		"namespace", current.GetObjectMeta().GetNamespace())
+
+	var resizeStatus []enterpriseApi.VolumeResizeStatus
+	expanded := false
+	resizing := false
+	for i := range revised.Spec.VolumeClaimTemplates {
+		revisedClaim := &revised.Spec.VolumeClaimTemplates[i]
+		for j := range current.Spec.VolumeClaimTemplates {
+			currentClaim := &current.Spec.VolumeClaimTemplates[j]
+			if currentClaim.GetName() != revisedClaim.GetName() {
+				continue
+			}
+
+			capacity := revisedClaim.Spec.Resources.Requests[corev1.ResourceStorage]
+			currentCapacity := currentClaim.Spec.Resources.Requests[corev1.ResourceStorage]
+			switch capacity.Cmp(currentCapacity) {
+			case 1:
+				scopedLog.Info("Expanding volumes", "volume", revisedClaim.GetName(), "capacity", capacity.String())
+				claimStatus, claimResizing, err := resizeVolumeClaims(ctx, c, current, revisedClaim.GetName(), capacity)
+				resizeStatus = append(resizeStatus, claimStatus...)
+				if err != nil {
+					return resizeStatus, true, err
+				}
+				expanded = true
+				resizing = resizing || claimResizing
+			case -1:
+				scopedLog.Info("Ignoring decrease of the volume capacity, which is not supported", "volume", revisedClaim.GetName(),
+					"capacity", currentCapacity.String(), "requested", capacity.String())
+			}
+		}
+	}
+
+	if !expanded || resizing {
+		return resizeStatus, resizing, nil
+	}
+
+	// keep the current volume claim templates, when the storage class of a PVC does not allow its expansion
+	for _, status := range resizeStatus {
+		if status.Phase == enterpriseApi.VolumeResizePhaseNotExpandable {
+			scopedLog.Info("Skipping the volume expansion, which is not allowed for a PVC", "pvc", status.PVC)
+			return resizeStatus, false, nil
+		}
+	}
+
+	// all the PVCs are expanded: recreate the StatefulSet with the revised volume claim templates, keeping the pods
+	scopedLog.Info("Deleting StatefulSet to recreate it with the expanded volume claim templates")
+	err := c.Delete(ctx, current, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return resizeStatus, true, err
+	}
+	return resizeStatus, true, nil
+}
+
+// resizeVolumeClaims expands the PVCs created from a volume claim template for the StatefulSet pods. It returns the
+// progress of the expansion, and true while a PVC is not expanded yet.
+func resizeVolumeClaims(ctx context.Context, c splcommon.ControllerClient, statefulSet *appsv1.StatefulSet, claimName string, capacity resource.Quantity) ([]enterpriseApi.VolumeResizeStatus, bool, error) {
+	replicas := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		replicas = *statefulSet.Spec.Replicas
+	}
+
+	var resizeStatus []enterpriseApi.VolumeResizeStatus
+	resizing := false
+	for n := int32(0); n < replicas; n++ {
+		podName := fmt.Sprintf("%s-%d", statefulSet.GetName(), n)
+		namespacedName := types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: fmt.Sprintf("%s-%s", claimName, podName)}
+		var pvc corev1.PersistentVolumeClaim
+		err := c.Get(ctx, namespacedName, &pvc)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				// PVC is created with the pod, from the revised volume claim template
+				continue
+			}
+			return resizeStatus, true, err
+		}
+
+		requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if requested.Cmp(capacity) < 0 {
+			var expandable bool
+			expandable, err = checkVolumeExpansion(ctx, c, &pvc)
+			if err != nil {
+				return resizeStatus, true, err
+			}
+			if !expandable {
+				status := getVolumeResizeStatus(&pvc, podName, capacity)
+				status.Phase = enterpriseApi.VolumeResizePhaseNotExpandable
+				resizeStatus = append(resizeStatus, status)
+				continue
+			}
+			if pvc.Spec.Resources.Requests == nil {
+				pvc.Spec.Resources.Requests = corev1.ResourceList{}
+			}
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = capacity
+			err = splutil.UpdateResource(ctx, c, &pvc)
+			if err != nil {
+				return resizeStatus, true, err
+			}
+		}
+
+		status := getVolumeResizeStatus(&pvc, podName, capacity)
+		if status.Phase == enterpriseApi.VolumeResizePhaseResizing {
+			resizing = true
+		}
+		resizeStatus = append(resizeStatus, status)
+	}
+
+	return resizeStatus, resizing, nil
+}
+
+// checkVolumeExpansion returns false if the storage class of a PVC does not allow volume expansion. Without access
+// to the storage class, the PVC is expanded anyway and the API server rejects the expansion if not allowed.
+func checkVolumeExpansion(ctx context.Context, c splcommon.ControllerClient, pvc *corev1.PersistentVolumeClaim) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkVolumeExpansion").WithValues("pvc", pvc.GetName(), "namespace", pvc.GetNamespace())
+
+	if pvc.Spec.StorageClassName == nil || *pvc.Spec.StorageClassName == "" {
+		scopedLog.Info("PVC can not be expanded without a storage class")
+		return false, nil
+	}
+
+	var storageClass storagev1.StorageClass
+	err := c.Get(ctx, types.NamespacedName{Name: *pvc.Spec.StorageClassName}, &storageClass)
+	if err != nil {
+		if k8serrors.IsForbidden(err) {
+			return true, nil
+		}
+		return false, err
+	}
+
+	if storageClass.AllowVolumeExpansion == nil || !*storageClass.AllowVolumeExpansion {
+		scopedLog.Info("Storage class does not allow volume expansion", "storageClass", storageClass.GetName())
+		return false, nil
+	}
+	return true, nil
+}
+
+// getVolumeResizeStatus returns the progress of the expansion of a PVC
+func getVolumeResizeStatus(pvc *corev1.PersistentVolumeClaim, podName string, capacity resource.Quantity) enterpriseApi.VolumeResizeStatus {
+	status := enterpriseApi.VolumeResizeStatus{
+		Pod:               podName,
+		PVC:               pvc.GetName(),
+		RequestedCapacity: capacity.String(),
+		Phase:             enterpriseApi.VolumeResizePhaseResizing,
+	}
+
+	currentCapacity, ok := pvc.Status.Capacity[corev1.ResourceStorage]
+	if ok {
+		status.Capacity = currentCapacity.String()
+	}
+
+	for _, condition := range pvc.Status.Conditions {
+		if condition.Type == corev1.PersistentVolumeClaimFileSystemResizePending && condition.Status == corev1.ConditionTrue {
+			// the volume is expanded, and its file system is expanded by the node once the pod is (re)started
+			status.Phase = enterpriseApi.VolumeResizePhaseFileSystemResizePending
+			return status
+		}
+	}
+
+	if ok && currentCapacity.Cmp(capacity) >= 0 {
+		status.Phase = enterpriseApi.VolumeResizePhaseResized
+	}
+	return status
+}
@@ -0,0 +1,194 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func getVolumeResizeTestStatefulSet(capacity string) *appsv1.StatefulSet {
+	replicas := int32(2)
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-indexer",
+			Namespace: "test",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "pvc-var"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						Resources: corev1.ResourceRequirements{
+							Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse(capacity)},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func getVolumeResizeTestPVC(n int, storageClassName string) *corev1.PersistentVolumeClaim {
+	return &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("pvc-var-splunk-stack1-indexer-%d", n),
+			Namespace: "test",
+		},
+		Spec: corev1.PersistentVolumeClaimSpec{
+			StorageClassName: &storageClassName,
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("100Gi")},
+			},
+		},
+		Status: corev1.PersistentVolumeClaimStatus{
+			Capacity: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("100Gi")},
+		},
+	}
+}
+
+func TestApplyStatefulSetWithVolumeResize(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	allowVolumeExpansion := true
+	c.AddObject(&storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	})
+	c.AddObject(getVolumeResizeTestStatefulSet("100Gi"))
+	c.AddObject(getVolumeResizeTestPVC(0, "standard"))
+	c.AddObject(getVolumeResizeTestPVC(1, "standard"))
+
+	// capacity increase expands the PVCs
+	var volumeResize []enterpriseApi.VolumeResizeStatus
+	phase, err := ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseUpdating {
+		t.Errorf("ApplyStatefulSetWithVolumeResize() returned %v, %v; want %v", phase, err, enterpriseApi.PhaseUpdating)
+	}
+	if len(volumeResize) != 2 || volumeResize[1].Pod != "splunk-stack1-indexer-1" || volumeResize[1].RequestedCapacity != "200Gi" ||
+		volumeResize[1].Capacity != "100Gi" || volumeResize[1].Phase != enterpriseApi.VolumeResizePhaseResizing {
+		t.Errorf("unexpected volume resize status: %v", volumeResize)
+	}
+	var pvc corev1.PersistentVolumeClaim
+	_ = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-var-splunk-stack1-indexer-0"}, &pvc)
+	requested := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+	if requested.String() != "200Gi" {
+		t.Errorf("PVC should be expanded to 200Gi, got %s", requested.String())
+	}
+
+	// statefulset is kept until all the PVCs are expanded
+	pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("200Gi")
+	_ = c.Update(ctx, &pvc)
+	phase, err = ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseUpdating || volumeResize[0].Phase != enterpriseApi.VolumeResizePhaseResized || len(c.Calls["Delete"]) != 0 {
+		t.Errorf("statefulset should not be deleted yet. phase: %v, err: %v, status: %v", phase, err, volumeResize)
+	}
+
+	// statefulset is deleted, without its pods, once the PVCs are expanded
+	_ = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-var-splunk-stack1-indexer-1"}, &pvc)
+	pvc.Status.Conditions = []corev1.PersistentVolumeClaimCondition{
+		{Type: corev1.PersistentVolumeClaimFileSystemResizePending, Status: corev1.ConditionTrue},
+	}
+	_ = c.Update(ctx, &pvc)
+	phase, err = ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseUpdating || volumeResize[1].Phase != enterpriseApi.VolumeResizePhaseFileSystemResizePending {
+		t.Errorf("ApplyStatefulSetWithVolumeResize() returned %v, %v, %v", phase, err, volumeResize)
+	}
+	if len(c.Calls["Delete"]) != 1 {
+		t.Errorf("statefulset should be deleted")
+	}
+
+	// statefulset is recreated with the expanded volume claim templates
+	phase, err = ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhasePending || volumeResize != nil {
+		t.Errorf("statefulset should be recreated. phase: %v, err: %v, status: %v", phase, err, volumeResize)
+	}
+	var statefulSet appsv1.StatefulSet
+	_ = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-indexer"}, &statefulSet)
+	capacity := statefulSet.Spec.VolumeClaimTemplates[0].Spec.Resources.Requests[corev1.ResourceStorage]
+	if capacity.String() != "200Gi" {
+		t.Errorf("statefulset should be recreated with 200Gi volumes, got %s", capacity.String())
+	}
+
+	// capacity decrease is ignored
+	phase, err = ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("50Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseReady || len(volumeResize) != 0 {
+		t.Errorf("capacity decrease should be ignored. phase: %v, err: %v, status: %v", phase, err, volumeResize)
+	}
+}
+
+func TestApplyStatefulSetWithVolumeResizeNotAllowed(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	c.AddObject(&storagev1.StorageClass{ObjectMeta: metav1.ObjectMeta{Name: "standard"}})
+	c.AddObject(getVolumeResizeTestStatefulSet("100Gi"))
+	c.AddObject(getVolumeResizeTestPVC(0, "standard"))
+	c.AddObject(getVolumeResizeTestPVC(1, ""))
+
+	// storage class does not allow volume expansion: the increase is skipped and reported in the status
+	var volumeResize []enterpriseApi.VolumeResizeStatus
+	phase, err := ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseReady {
+		t.Errorf("ApplyStatefulSetWithVolumeResize() returned %v, %v; want %v", phase, err, enterpriseApi.PhaseReady)
+	}
+	if len(volumeResize) != 2 || volumeResize[0].Phase != enterpriseApi.VolumeResizePhaseNotExpandable ||
+		volumeResize[1].Phase != enterpriseApi.VolumeResizePhaseNotExpandable {
+		t.Errorf("volume expansion should not be allowed by the storage class. status: %v", volumeResize)
+	}
+	if len(c.Calls["Update"]) != 0 {
+		t.Errorf("PVCs should not be expanded")
+	}
+
+	// PVC without a storage class can not be expanded
+	allowVolumeExpansion := true
+	c.AddObject(&storagev1.StorageClass{
+		ObjectMeta:           metav1.ObjectMeta{Name: "standard"},
+		AllowVolumeExpansion: &allowVolumeExpansion,
+	})
+	phase, err = ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseUpdating {
+		t.Errorf("ApplyStatefulSetWithVolumeResize() returned %v, %v; want %v", phase, err, enterpriseApi.PhaseUpdating)
+	}
+	if len(volumeResize) != 2 || volumeResize[0].Phase != enterpriseApi.VolumeResizePhaseResizing ||
+		volumeResize[1].Phase != enterpriseApi.VolumeResizePhaseNotExpandable {
+		t.Errorf("volume expansion should not be allowed without a storage class. status: %v", volumeResize)
+	}
+
+	// statefulset keeps its volume claim templates, once the expandable PVCs are expanded
+	var pvc corev1.PersistentVolumeClaim
+	_ = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-var-splunk-stack1-indexer-0"}, &pvc)
+	pvc.Status.Capacity[corev1.ResourceStorage] = resource.MustParse("200Gi")
+	_ = c.Update(ctx, &pvc)
+	phase, err = ApplyStatefulSetWithVolumeResize(ctx, c, getVolumeResizeTestStatefulSet("200Gi"), &volumeResize)
+	if err != nil || phase != enterpriseApi.PhaseReady || volumeResize[0].Phase != enterpriseApi.VolumeResizePhaseResized {
+		t.Errorf("ApplyStatefulSetWithVolumeResize() returned %v, %v, %v", phase, err, volumeResize)
+	}
+	if len(c.Calls["Delete"]) != 0 {
+		t.Errorf("statefulset should not be deleted")
+	}
+}
@@ -36,20 +36,21 @@ import (
 )
 
 var appPhaseInfoStatuses = map[enterpriseApi.AppPhaseStatusType]bool{
-	enterpriseApi.AppPkgDownloadPending:     true,
-	enterpriseApi.AppPkgDownloadInProgress:  true,
-	enterpriseApi.AppPkgDownloadComplete:    true,
-	enterpriseApi.AppPkgDownloadError:       true,
-	enterpriseApi.AppPkgPodCopyPending:      true,
-	enterpriseApi.AppPkgPodCopyInProgress:   true,
-	enterpriseApi.AppPkgPodCopyComplete:     true,
-	enterpriseApi.AppPkgMissingFromOperator: true,
-	enterpriseApi.AppPkgPodCopyError:        true,
-	enterpriseApi.AppPkgInstallPending:      true,
-	enterpriseApi.AppPkgInstallInProgress:   true,
-	enterpriseApi.AppPkgInstallComplete:     true,
-	enterpriseApi.AppPkgMissingOnPodError:   true,
-	enterpriseApi.AppPkgInstallError:        true,
+	enterpriseApi.AppPkgDownloadPending:        true,
+	enterpriseApi.AppPkgDownloadInProgress:     true,
+	enterpriseApi.AppPkgDownloadComplete:       true,
+	enterpriseApi.AppPkgDownloadError:          true,
+	enterpriseApi.AppPkgPodCopyPending:         true,
+	enterpriseApi.AppPkgPodCopyInProgress:      true,
+	enterpriseApi.AppPkgPodCopyComplete:        true,
+	enterpriseApi.AppPkgMissingFromOperator:    true,
+	enterpriseApi.AppPkgPodCopyError:           true,
+	enterpriseApi.AppPkgInstallPending:         true,
+	enterpriseApi.AppPkgInstallInProgress:      true,
+	enterpriseApi.AppPkgInstallComplete:        true,
+	enterpriseApi.AppPkgInstallDependencyError: true,
+	enterpriseApi.AppPkgMissingOnPodError:      true,
+	enterpriseApi.AppPkgInstallError:           true,
 }
 
 // isFanOutApplicableToCR confirms if a given CR needs fanOut support
@@ -493,19 +494,49 @@ func (downloadWorker *PipelineWorker) download(ctx context.Context, pplnPhase *P
 		return
 	}
 
-	// download the app from remote storage
-	err = remoteDataClientMgr.DownloadApp(ctx, remoteFile, localFile, appDeployInfo.ObjectHash)
+	// download the app from remote storage through the app package cache, so that the same app package is
+	// downloaded only once across the CRs. Exploded apps are packaged on the fly, and the other app package
+	// formats are converted to a gzipped tarball
+	cacheKey := getAppPackageCacheKey(remoteDataClientMgr.vol, remoteFile, appDeployInfo.ObjectHash)
+	err = fetchAppPackage(ctx, cacheKey, localFile, appDeployInfo.Size, func(path string) error {
+		if isExplodedApp(appName) {
+			return downloadExplodedApp(ctx, remoteDataClientMgr, remoteFile+"/", path, appDeployInfo.ObjectHash)
+		}
+
+		err := remoteDataClientMgr.DownloadApp(ctx, remoteFile, path, appDeployInfo.ObjectHash)
+		if err == nil {
+			err = convertAppPackage(ctx, path, appName)
+		}
+		return err
+	})
 	if err != nil {
 		scopedLog.Error(err, "unable to download app", "appName", appName)
 
 		// remove the local file
-		err = os.RemoveAll(localFile)
-		if err != nil {
-			scopedLog.Error(err, "unable to remove local file from operator")
+		rmErr := os.RemoveAll(localFile)
+		if rmErr != nil {
+			scopedLog.Error(rmErr, "unable to remove local file from operator")
 		}
 
-		// increment the retry count and mark this app as download pending
-		updatePplnWorkerPhaseInfo(ctx, appDeployInfo, appDeployInfo.PhaseInfo.FailCount+1, enterpriseApi.AppPkgDownloadPending)
+		// no storage is not a download failure, so just retry later
+		failCount := appDeployInfo.PhaseInfo.FailCount + 1
+		if errors.Is(err, errInsufficientStorage) {
+			failCount = appDeployInfo.PhaseInfo.FailCount
+		}
+
+		// mark this app as download pending
+		updatePplnWorkerPhaseInfo(ctx, appDeployInfo, failCount, enterpriseApi.AppPkgDownloadPending)
+		return
+	}
+
+	// record the size of the app package, so that it is not downloaded again on the next reconcile
+	if fileInfo, err := os.Stat(localFile); err == nil {
+		appDeployInfo.PackageSize = uint64(fileInfo.Size())
+	}
+
+	// validate the app package, and get the app name and dependencies from it
+	if !validateAppPackage(ctx, downloadWorker, localFile) {
+		updatePplnWorkerPhaseInfo(ctx, appDeployInfo, 0, enterpriseApi.AppPkgValidationError)
 		return
 	}
 
@@ -541,20 +572,26 @@ downloadWork:
 				if isAppAlreadyDownloaded(ctx, downloadWorker) {
 					scopedLog.Info("app is already downloaded on operator pod, hence skipping it.", "appSrcName", downloadWorker.appSrcName, "appName", downloadWorker.appDeployInfo.AppName)
 					// update the state to be download complete
-					updatePplnWorkerPhaseInfo(ctx, downloadWorker.appDeployInfo, 0, enterpriseApi.AppPkgDownloadComplete)
+					if validateAppPackage(ctx, downloadWorker, getAppPackageLocalPath(ctx, downloadWorker)) {
+						updatePplnWorkerPhaseInfo(ctx, downloadWorker.appDeployInfo, 0, enterpriseApi.AppPkgDownloadComplete)
+					} else {
+						updatePplnWorkerPhaseInfo(ctx, downloadWorker.appDeployInfo, 0, enterpriseApi.AppPkgValidationError)
+					}
 					<-downloadWorkersRunPool
 					continue
 				}
 
-				// do not proceed if we dont have enough disk space to download this app
-
-				err := reserveStorage(downloadWorker.appDeployInfo.Size)
-				if err != nil {
-					scopedLog.Error(err, "insufficient storage for the app pkg download. appSrcName: %s, app name: %s, app size: %d Bytes", downloadWorker.appSrcName, downloadWorker.appDeployInfo.AppName, downloadWorker.appDeployInfo.Size)
-					// setting isActive to false here so that downloadPhaseManager can take care of it.
-					downloadWorker.isActive = false
-					<-downloadWorkersRunPool
-					continue
+				// do not proceed if we dont have enough disk space to download this app. The app package cache
+				// reserves the storage by itself, evicting the unused app packages as needed
+				if !isAppPackageCacheEnabled() {
+					err := reserveStorage(downloadWorker.appDeployInfo.Size)
+					if err != nil {
+						scopedLog.Error(err, "insufficient storage for the app pkg download. appSrcName: %s, app name: %s, app size: %d Bytes", downloadWorker.appSrcName, downloadWorker.appDeployInfo.AppName, downloadWorker.appDeployInfo.Size)
+						// setting isActive to false here so that downloadPhaseManager can take care of it.
+						downloadWorker.isActive = false
+						<-downloadWorkersRunPool
+						continue
+					}
 				}
 
 				// increment the count in worker waitgroup
@@ -656,6 +693,10 @@ downloadPhase:
 
 					downloadWorker.appDeployInfo.PhaseInfo.Status = enterpriseApi.AppPkgDownloadError
 					ppln.deleteWorkerFromPipelinePhase(ctx, phaseInfo.Phase, downloadWorker)
+				} else if phaseInfo.Status == enterpriseApi.AppPkgValidationError && !downloadWorker.isActive {
+					// app package is not installed, until it is fixed on the remote storage
+					deleteAppPkgFromOperator(ctx, downloadWorker)
+					ppln.deleteWorkerFromPipelinePhase(ctx, phaseInfo.Phase, downloadWorker)
 				} else if isPhaseStatusComplete(phaseInfo) {
 					ppln.transitionWorkerPhase(ctx, downloadWorker, enterpriseApi.PhaseDownload, enterpriseApi.PhasePodCopy)
 				} else if checkIfWorkerIsEligibleForRun(ctx, downloadWorker, phaseInfo, enterpriseApi.AppPkgDownloadComplete) {
@@ -718,6 +759,8 @@ func installApp(rctx context.Context, localCtx *localScopePlaybookContext, cr sp
 		return fmt.Errorf("app pkg missing on Pod. app pkg path: %s", appPkgPathOnPod)
 	}
 
+	// top folder is normally set while validating the app package on the operator pod,
+	// fallback to the Splunk pod when the app package could not be inspected
 	if worker.appDeployInfo.AppPackageTopFolder == "" {
 		appTopFolder, err := getAppTopFolderFromPackage(rctx, cr, appPkgPathOnPod, localCtx.podExecClient)
 		if err != nil {
@@ -1152,6 +1195,11 @@ func needToRunClusterScopedPlaybook(afwPipeline *AppInstallPipeline) bool {
 		return false
 	}
 
+	// Bundle push waits for the maintenance window, but the status of an in progress bundle push is still checked
+	if afwPipeline.installHeld && afwPipeline.appDeployContext.BundlePushStatus.BundlePushStage == enterpriseApi.BundlePushPending {
+		return false
+	}
+
 	// Its already time to yield the current reconcile
 	if afwPipeline.afwEntryTime+int64(afwPipeline.appDeployContext.AppFrameworkConfig.SchedulerYieldInterval) < time.Now().Unix() {
 		return false
@@ -1257,6 +1305,127 @@ installHandler:
 	scopedLog.Info("All the workers finished")
 }
 
+// isAppReferredBy confirms if the app dependency reference points to the given app. An app can be referred
+// either by the app package name(app1.tgz), app package name without the extension(app1) or the app name
+func isAppReferredBy(appDeployInfo *enterpriseApi.AppDeploymentInfo, ref string) bool {
+	if appDeployInfo.AppName == ref || appDeployInfo.AppPackageTopFolder == ref {
+		return true
+	}
+
+	return strings.TrimSuffix(appDeployInfo.AppName, getAppPackageExtension(appDeployInfo.AppName)) == ref
+}
+
+// getAppDependencies returns the list of apps that the given app depends on, as declared in the app.manifest
+// of the app package and in the app source spec
+func getAppDependencies(appSrcSpec *enterpriseApi.AppSourceSpec, appDeployInfo *enterpriseApi.AppDeploymentInfo) []string {
+	dependencies := append([]string{}, appDeployInfo.Dependencies...)
+	if appSrcSpec == nil {
+		return dependencies
+	}
+
+	for _, appDependency := range appSrcSpec.AppDependencies {
+		if isAppReferredBy(appDeployInfo, appDependency.Name) {
+			dependencies = append(dependencies, appDependency.DependsOn...)
+		}
+	}
+
+	return dependencies
+}
+
+// getAppDependency returns the app from the app source, that the dependency reference points to
+func getAppDependency(deployInfoList []enterpriseApi.AppDeploymentInfo, ref string) *enterpriseApi.AppDeploymentInfo {
+	for i := range deployInfoList {
+		if deployInfoList[i].RepoState == enterpriseApi.RepoStateActive && isAppReferredBy(&deployInfoList[i], ref) {
+			return &deployInfoList[i]
+		}
+	}
+
+	return nil
+}
+
+// isAppInstallCompleteOnPod confirms if the app is installed on a given pod
+func isAppInstallCompleteOnPod(appDeployInfo *enterpriseApi.AppDeploymentInfo, podID int, fanOut bool) bool {
+	if appDeployInfo.PhaseInfo.Phase == enterpriseApi.PhaseInstall && appDeployInfo.PhaseInfo.Status == enterpriseApi.AppPkgInstallComplete {
+		return true
+	}
+
+	if fanOut && podID < len(appDeployInfo.AuxPhaseInfo) {
+		return appDeployInfo.AuxPhaseInfo[podID].Phase == enterpriseApi.PhaseInstall && appDeployInfo.AuxPhaseInfo[podID].Status == enterpriseApi.AppPkgInstallComplete
+	}
+
+	return false
+}
+
+// isAppInstallFailedOnPod confirms if the app can no longer be installed on a given pod
+func isAppInstallFailedOnPod(appDeployInfo *enterpriseApi.AppDeploymentInfo, podID int, fanOut bool) bool {
+	phaseInfo := &appDeployInfo.PhaseInfo
+	if fanOut && phaseInfo.Phase != enterpriseApi.PhaseDownload && podID < len(appDeployInfo.AuxPhaseInfo) {
+		phaseInfo = &appDeployInfo.AuxPhaseInfo[podID]
+	}
+
+	switch phaseInfo.Status {
+	case enterpriseApi.AppPkgDownloadError, enterpriseApi.AppPkgValidationError, enterpriseApi.AppPkgPodCopyError, enterpriseApi.AppPkgInstallError, enterpriseApi.AppPkgInstallDependencyError:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkAppDependenciesForInstall confirms if all the dependencies of the app are installed on the target pod of
+// the install worker. Returns an error when the dependencies can never be satisfied, i.e. a dependency is missing
+// in the app source, failed to install, or is part of a dependency cycle
+func (ppln *AppInstallPipeline) checkAppDependenciesForInstall(ctx context.Context, installWorker *PipelineWorker) (bool, error) {
+	appSrcSpec, _ := getAppSrcSpec(installWorker.afwConfig.AppSources, installWorker.appSrcName)
+	dependencies := getAppDependencies(appSrcSpec, installWorker.appDeployInfo)
+	if len(dependencies) == 0 {
+		return true, nil
+	}
+
+	podID, err := getOrdinalValFromPodName(installWorker.targetPodName)
+	if err != nil {
+		return false, err
+	}
+
+	deployInfoList := ppln.appDeployContext.AppsSrcDeployStatus[installWorker.appSrcName].AppDeploymentInfoList
+
+	// walk the dependency graph, to catch the cycles along with the missing apps
+	visited := map[*enterpriseApi.AppDeploymentInfo]bool{installWorker.appDeployInfo: true}
+	pending := []*enterpriseApi.AppDeploymentInfo{installWorker.appDeployInfo}
+	for len(pending) > 0 {
+		appDeployInfo := pending[0]
+		pending = pending[1:]
+
+		for _, ref := range getAppDependencies(appSrcSpec, appDeployInfo) {
+			dependency := getAppDependency(deployInfoList, ref)
+			if dependency == nil {
+				return false, fmt.Errorf("app %s depends on %s, which is not present in the app source %s", appDeployInfo.AppName, ref, installWorker.appSrcName)
+			}
+
+			if dependency == installWorker.appDeployInfo {
+				return false, fmt.Errorf("app %s has a cyclic dependency through the app %s", installWorker.appDeployInfo.AppName, appDeployInfo.AppName)
+			}
+
+			if !visited[dependency] {
+				visited[dependency] = true
+				pending = append(pending, dependency)
+			}
+		}
+	}
+
+	for _, ref := range dependencies {
+		dependency := getAppDependency(deployInfoList, ref)
+		if isAppInstallFailedOnPod(dependency, podID, installWorker.fanOut) {
+			return false, fmt.Errorf("app %s depends on %s, which failed to install", installWorker.appDeployInfo.AppName, dependency.AppName)
+		}
+
+		if !isAppInstallCompleteOnPod(dependency, podID, installWorker.fanOut) {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
 // installPhaseManager creates install phase manager for the afw installation pipeline
 func (ppln *AppInstallPipeline) installPhaseManager(ctx context.Context) {
 	reqLogger := log.FromContext(ctx)
@@ -1316,20 +1485,30 @@ installPhase:
 					ppln.deleteWorkerFromPipelinePhase(ctx, phaseInfo.Phase, installWorker)
 				} else if phaseInfo.Status == enterpriseApi.AppPkgMissingOnPodError {
 					ppln.transitionWorkerPhase(ctx, installWorker, enterpriseApi.PhaseInstall, enterpriseApi.PhasePodCopy)
-				} else if checkIfWorkerIsEligibleForRun(ctx, installWorker, phaseInfo, enterpriseApi.AppPkgInstallComplete) &&
-					getInstallSlotForPod(ctx, podInstallTracker, installWorker.targetPodName) {
-					installWorker.waiter = &pplnPhase.workerWaiter
-					select {
-					case pplnPhase.msgChannel <- installWorker:
-						scopedLog.Info("Install worker got a run slot", "name", installWorker.cr.GetName(), "namespace", installWorker.cr.GetNamespace(), "pod name", installWorker.targetPodName, "App name", installWorker.appDeployInfo.AppName, "digest", installWorker.appDeployInfo.ObjectHash)
-
-						// Always set the isActive in Phase manager itself, to avoid any delay in the install handler, otherwise it can
-						// cause running the same playbook multiple times.
-						installWorker.isActive = true
-
-					default:
-						freeInstallSlotForPod(ctx, podInstallTracker, installWorker.targetPodName)
-						installWorker.waiter = nil
+				} else if ppln.installHeld {
+					// Installs wait for the maintenance window, the worker stays in the queue and is reported in the status
+					continue
+				} else if checkIfWorkerIsEligibleForRun(ctx, installWorker, phaseInfo, enterpriseApi.AppPkgInstallComplete) {
+					// Apps are installed on a pod only after all of their dependencies are installed on the same pod
+					dependenciesInstalled, err := ppln.checkAppDependenciesForInstall(ctx, installWorker)
+					if err != nil {
+						scopedLog.Error(err, "unable to satisfy the app dependencies", "name", installWorker.cr.GetName(), "namespace", installWorker.cr.GetNamespace(), "pod name", installWorker.targetPodName, "App name", installWorker.appDeployInfo.AppName)
+						phaseInfo.Status = enterpriseApi.AppPkgInstallDependencyError
+						ppln.deleteWorkerFromPipelinePhase(ctx, phaseInfo.Phase, installWorker)
+					} else if dependenciesInstalled && getInstallSlotForPod(ctx, podInstallTracker, installWorker.targetPodName) {
+						installWorker.waiter = &pplnPhase.workerWaiter
+						select {
+						case pplnPhase.msgChannel <- installWorker:
+							scopedLog.Info("Install worker got a run slot", "name", installWorker.cr.GetName(), "namespace", installWorker.cr.GetNamespace(), "pod name", installWorker.targetPodName, "App name", installWorker.appDeployInfo.AppName, "digest", installWorker.appDeployInfo.ObjectHash)
+
+							// Always set the isActive in Phase manager itself, to avoid any delay in the install handler, otherwise it can
+							// cause running the same playbook multiple times.
+							installWorker.isActive = true
+
+						default:
+							freeInstallSlotForPod(ctx, podInstallTracker, installWorker.targetPodName)
+							installWorker.waiter = nil
+						}
 					}
 				}
 			}
@@ -1407,6 +1586,15 @@ func (ppln *AppInstallPipeline) isPipelineEmpty() bool {
 	return true
 }
 
+// isPipelineWaitingForMaintenanceWindow confirms if the only pending work in the pipeline is held for the maintenance window
+func (ppln *AppInstallPipeline) isPipelineWaitingForMaintenanceWindow() bool {
+	if !ppln.installHeld || ppln.pplnPhases == nil {
+		return false
+	}
+
+	return len(ppln.pplnPhases[enterpriseApi.PhaseDownload].q) == 0 && len(ppln.pplnPhases[enterpriseApi.PhasePodCopy].q) == 0
+}
+
 // isAppInstallationCompleteOnAllReplicas confirms if an app package is installed on all the Standalone Pods or not
 func isAppInstallationCompleteOnAllReplicas(auxPhaseInfo []enterpriseApi.PhaseInfo) bool {
 	for _, phaseInfo := range auxPhaseInfo {
@@ -1469,6 +1657,10 @@ func deleteAppPkgFromOperator(ctx context.Context, worker *PipelineWorker) {
 	scopedLog := reqLogger.WithName("deleteAppPkgFromOperator").WithValues("name", worker.cr.GetName(), "namespace", worker.cr.GetNamespace(), "app pkg", worker.appDeployInfo.AppName)
 
 	appPkgLocalPath := getAppPackageLocalPath(ctx, worker)
+
+	// storage of a cached app package is released only when it is evicted from the cache
+	cached := releaseAppPackage(ctx, appPkgLocalPath)
+
 	err := os.Remove(appPkgLocalPath)
 	if err != nil {
 		// Issue is local, so just log an error msg and return
@@ -1478,7 +1670,10 @@ func deleteAppPkgFromOperator(ctx context.Context, worker *PipelineWorker) {
 	}
 
 	scopedLog.Info("Deleted app package from the operator", "App package path", appPkgLocalPath)
-	releaseStorage(worker.appDeployInfo.Size)
+
+	if !cached {
+		releaseStorage(worker.appDeployInfo.Size)
+	}
 }
 
 func afwGetReleventStatefulsetByKind(ctx context.Context, cr splcommon.MetaObject, client splcommon.ControllerClient) *appsv1.StatefulSet {
@@ -1665,6 +1860,45 @@ func (shcPlaybookContext *SHCPlaybookContext) isBundlePushComplete(ctx context.C
 	return true, nil
 }
 
+// validateBundle checks the syntax of the configuration files of the deployer bundle before the push, and reports the
+// errors in the bundle validation status
+func (shcPlaybookContext *SHCPlaybookContext) validateBundle(ctx context.Context) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("shcPlaybookContext.validateBundle()").WithValues(
+		"cr", shcPlaybookContext.cr.GetName())
+
+	validation := &shcPlaybookContext.afwPipeline.appDeployContext.BundlePushStatus.Validation
+	validation.LastValidationTime = time.Now().Unix()
+
+	streamOptions := splutil.NewStreamOptionsObject(shcValidateBundleCmdStr)
+	stdOut, stdErr, err := shcPlaybookContext.podExecClient.RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		validation.Phase = enterpriseApi.BundleValidationFailure
+		validation.Errors = []string{fmt.Sprintf("bundle validation command failed. stderr: %s, err: %v", stdErr, err)}
+		return fmt.Errorf("error while validating SHC Bundle. stdout: %s, stderr: %s, err: %v", stdOut, stdErr, err)
+	}
+
+	// btool reports the configuration files without a spec file as well, which are not errors
+	var validationErrors []string
+	for _, line := range strings.Split(stdOut+stdErr, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Invalid key") || strings.HasPrefix(line, "Possible typo") {
+			validationErrors = append(validationErrors, line)
+		}
+	}
+
+	if len(validationErrors) > 0 {
+		validation.Phase = enterpriseApi.BundleValidationFailure
+		validation.Errors = validationErrors
+		return fmt.Errorf("SHC Bundle failed the validation: %s", strings.Join(validationErrors, "; "))
+	}
+
+	scopedLog.Info("SHC Bundle passed the validation")
+	validation.Phase = enterpriseApi.BundleValidationSuccess
+	validation.Errors = nil
+	return nil
+}
+
 // triggerBundlePush triggers the bundle push operation for SHC
 func (shcPlaybookContext *SHCPlaybookContext) triggerBundlePush(ctx context.Context) error {
 	reqLogger := log.FromContext(ctx)
@@ -1797,6 +2031,16 @@ func (shcPlaybookContext *SHCPlaybookContext) runPlaybook(ctx context.Context) e
 			scopedLog.Info("SHC Bundle Push is still in progress, will check back again")
 		}
 	case enterpriseApi.BundlePushPending:
+		// the deployer can not tell if the push requires a rolling restart, so always consider it does
+		validation := &appDeployContext.BundlePushStatus.Validation
+		validation.RestartRequired = true
+		held, nextWindowStart := isBundlePushHeldForRestartWindow(ctx, cr, &cr.Spec.BundlePushPolicy, validation.RestartRequired)
+		setBundlePushHeld(validation, held, nextWindowStart)
+		if held {
+			scopedLog.Info("SHC Bundle Push may require a rolling restart of the members, waiting for the restart window", "nextWindowStart", validation.NextWindowStart)
+			return nil
+		}
+
 		// run the command to apply cluster bundle
 		scopedLog.Info("running command to apply SHC Bundle")
 
@@ -1807,6 +2051,14 @@ func (shcPlaybookContext *SHCPlaybookContext) runPlaybook(ctx context.Context) e
 			return err
 		}
 
+		if !cr.Spec.BundlePushPolicy.SkipValidation {
+			err = shcPlaybookContext.validateBundle(ctx)
+			if err != nil {
+				scopedLog.Error(err, "SHC Bundle failed the validation")
+				return err
+			}
+		}
+
 		err = shcPlaybookContext.triggerBundlePush(ctx)
 		if err != nil {
 			scopedLog.Error(err, "failed to apply SHC Bundle")
@@ -1964,6 +2216,26 @@ func (idxcPlaybookContext *IdxcPlaybookContext) runPlaybook(ctx context.Context)
 			return err
 		}
 
+		// Validate the bundle first, so that an invalid bundle is not pushed to the peers, and hold a push requiring
+		// a rolling restart of the peers for the restart window
+		if cm, ok := idxcPlaybookContext.cr.(*enterpriseApi.ClusterManager); ok {
+			validation := &appDeployContext.BundlePushStatus.Validation
+			if !cm.Spec.BundlePushPolicy.SkipValidation {
+				validated, err := validateManagerAppsBundle(ctx, idxcPlaybookContext.client, cm, validation)
+				if !validated {
+					scopedLog.Error(err, "IndexerCluster Bundle is not validated")
+					return err
+				}
+			}
+
+			held, nextWindowStart := isBundlePushHeldForRestartWindow(ctx, cm, &cm.Spec.BundlePushPolicy, validation.RestartRequired)
+			setBundlePushHeld(validation, held, nextWindowStart)
+			if held {
+				scopedLog.Info("IndexerCluster Bundle Push requires a rolling restart of the peers, waiting for the restart window", "nextWindowStart", validation.NextWindowStart)
+				return nil
+			}
+		}
+
 		// run the command to apply cluster bundle
 		scopedLog.Info("running command to apply IndexerCluster Bundle")
 		err = idxcPlaybookContext.triggerBundlePush(ctx)
@@ -1972,6 +2244,11 @@ func (idxcPlaybookContext *IdxcPlaybookContext) runPlaybook(ctx context.Context)
 			return err
 		}
 
+		// record the bundle push in the bundle history of the cluster manager
+		if cm, ok := idxcPlaybookContext.cr.(*enterpriseApi.ClusterManager); ok {
+			addBundleHistoryEntry(cm, enterpriseApi.BundlePushTriggerAppFramework, getClusterScopedAppsPendingInstall(ctx, appDeployContext))
+		}
+
 		// set the state to bundle push in progress
 		setBundlePushState(ctx, idxcPlaybookContext.afwPipeline, enterpriseApi.BundlePushInProgress)
 
@@ -2102,6 +2379,11 @@ func isPhaseInfoEligibleForSchedulerEntry(ctx context.Context, appSrcName string
 		return false
 	}
 
+	// if an app package failed the validation, do not schedule a worker until the app package is modified
+	if phaseInfo.Phase == enterpriseApi.PhaseDownload && phaseInfo.Status == enterpriseApi.AppPkgValidationError {
+		return false
+	}
+
 	// if an app is already install complete, do not schedule a worker
 	if phaseInfo.Phase == enterpriseApi.PhaseInstall && phaseInfo.Status == enterpriseApi.AppPkgInstallComplete {
 		return false
@@ -2120,6 +2402,55 @@ func isPhaseInfoEligibleForSchedulerEntry(ctx context.Context, appSrcName string
 	return true
 }
 
+// getAppInstallOrder returns the indexes of the app deployment info list sorted in the dependency order, so that
+// the pipeline workers are queued in the same order as they are expected to be installed. Apps with the missing or
+// cyclic dependencies are placed at the end, and are reported during the install phase
+func getAppInstallOrder(appSrcSpec *enterpriseApi.AppSourceSpec, deployInfoList []enterpriseApi.AppDeploymentInfo) []int {
+	inDegree := make([]int, len(deployInfoList))
+	dependents := make(map[*enterpriseApi.AppDeploymentInfo][]int)
+	for i := range deployInfoList {
+		for _, ref := range getAppDependencies(appSrcSpec, &deployInfoList[i]) {
+			dependency := getAppDependency(deployInfoList, ref)
+			if dependency == nil || dependency == &deployInfoList[i] {
+				continue
+			}
+			dependents[dependency] = append(dependents[dependency], i)
+			inDegree[i]++
+		}
+	}
+
+	order := make([]int, 0, len(deployInfoList))
+	scheduled := make([]bool, len(deployInfoList))
+	for len(order) < len(deployInfoList) {
+		// pick the first app that has no pending dependencies, to keep the order stable
+		next := -1
+		for i := range deployInfoList {
+			if !scheduled[i] && inDegree[i] == 0 {
+				next = i
+				break
+			}
+		}
+
+		// rest of the apps are part of a dependency cycle
+		if next == -1 {
+			for i := range deployInfoList {
+				if !scheduled[i] {
+					order = append(order, i)
+				}
+			}
+			break
+		}
+
+		scheduled[next] = true
+		order = append(order, next)
+		for _, dependent := range dependents[&deployInfoList[next]] {
+			inDegree[dependent]--
+		}
+	}
+
+	return order
+}
+
 // afwSchedulerEntry Starts the scheduler Pipeline with the required phases
 func afwSchedulerEntry(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appDeployContext *enterpriseApi.AppDeploymentContext, appFrameworkConfig *enterpriseApi.AppFrameworkSpec) (bool, error) {
 	reqLogger := log.FromContext(ctx)
@@ -2139,6 +2470,13 @@ func afwSchedulerEntry(ctx context.Context, client splcommon.ControllerClient, c
 
 	afwPipeline := initAppInstallPipeline(ctx, appDeployContext, client, cr)
 
+	// Download and pod copy phases proceed anytime, while the installs and the bundle push wait for a maintenance window
+	var nextWindowStart time.Time
+	afwPipeline.installHeld, nextWindowStart = isWorkHeldForMaintenanceWindow(ctx, cr, appFrameworkConfig)
+	if afwPipeline.installHeld {
+		scopedLog.Info("App installs and bundle push are held until the next maintenance window", "next window start", nextWindowStart)
+	}
+
 	// Start the download phase manager
 	afwPipeline.phaseWaiter.Add(1)
 	go afwPipeline.downloadPhaseManager(ctx)
@@ -2170,7 +2508,9 @@ func afwSchedulerEntry(ctx context.Context, client splcommon.ControllerClient, c
 			break
 		}
 
-		for i := range deployInfoList {
+		// queue the workers in the dependency order of the apps
+		appSrcSpec, _ := getAppSrcSpec(appFrameworkConfig.AppSources, appSrcName)
+		for _, i := range getAppInstallOrder(appSrcSpec, deployInfoList) {
 			// Ignore any apps if there is no pending work
 			if !isPhaseInfoEligibleForSchedulerEntry(ctx, appSrcName, &deployInfoList[i].PhaseInfo, appFrameworkConfig) {
 				continue
@@ -2195,6 +2535,9 @@ func afwSchedulerEntry(ctx context.Context, client splcommon.ControllerClient, c
 	// Finally mark if all the App framework is complete
 	checkAndUpdateAppFrameworkProgressFlag(afwPipeline)
 
+	// Report the work held for the maintenance window
+	updateMaintenanceWindowStatus(afwPipeline, nextWindowStart)
+
 	return needToRevisitAppFramework(afwPipeline), nil
 }
 
@@ -2211,7 +2554,7 @@ yieldScheduler:
 			scopedLog.Info("Yielding from AFW scheduler", "time elapsed", time.Now().Unix()-ppln.afwEntryTime)
 			break yieldScheduler
 		default:
-			if ppln.isPipelineEmpty() {
+			if ppln.isPipelineEmpty() || ppln.isPipelineWaitingForMaintenanceWindow() {
 				break yieldScheduler
 			}
 		}
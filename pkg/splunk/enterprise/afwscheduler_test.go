@@ -20,6 +20,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"sync"
 	"testing"
@@ -1203,6 +1204,13 @@ func TestIsPhaseInfoEligibleForSchedulerEntry(t *testing.T) {
 	if !isPhaseInfoEligibleForSchedulerEntry(ctx, afwConfig.AppSources[1].Name, phaseInfo, afwConfig) {
 		t.Errorf("Cluster scope: If the pod copy is not complete, should be eligible to run")
 	}
+
+	// app package failed the validation, should not be eligible to run
+	phaseInfo.Phase = enterpriseApi.PhaseDownload
+	phaseInfo.Status = enterpriseApi.AppPkgValidationError
+	if isPhaseInfoEligibleForSchedulerEntry(ctx, afwConfig.AppSources[0].Name, phaseInfo, afwConfig) {
+		t.Errorf("When the app package failed the validation, should not be eligible to run")
+	}
 }
 
 func TestGetPhaseInfoByPhaseType(t *testing.T) {
@@ -1426,6 +1434,8 @@ func TestPipelineWorkerDownloadShouldPass(t *testing.T) {
 				PhaseMaxRetries:           3,
 				AppsRepoPollInterval:      60,
 				MaxConcurrentAppDownloads: 5,
+				// dummy app packages are not valid apps
+				AppPackagePolicy: enterpriseApi.AppPackagePolicySpec{Disabled: true},
 				VolList: []enterpriseApi.VolumeSpec{
 					{
 						Name:      "test_volume",
@@ -2281,6 +2291,8 @@ func TestIDXCRunPlaybook(t *testing.T) {
 			Namespace: "test",
 		},
 	}
+	// bundle validation is covered by TestIDXCRunPlaybookBundlePushPolicy
+	cr.Spec.BundlePushPolicy.SkipValidation = true
 
 	c := spltest.NewMockClient()
 	var appDeployContext *enterpriseApi.AppDeploymentContext = &enterpriseApi.AppDeploymentContext{
@@ -2672,6 +2684,7 @@ func TestSHCRunPlaybook(t *testing.T) {
 
 	podExecCommands := []string{
 		fmt.Sprintf(cmdSetFilePermissionsToRW, shcAppsLocationOnDeployer),
+		shcValidateBundleCmdStr,
 		"/opt/splunk/bin/splunk apply shcluster-bundle",
 		fmt.Sprintf("cat %s", shcBundlePushStatusCheckFile),
 		fmt.Sprintf("rm %s", shcBundlePushStatusCheckFile),
@@ -2683,6 +2696,11 @@ func TestSHCRunPlaybook(t *testing.T) {
 			StdOut: "",
 			StdErr: "",
 		},
+		// this is for validating the bundle
+		{
+			StdOut: "No spec file for: /opt/splunk/etc/shcluster/apps/app1/default/app1.conf\n",
+			StdErr: "",
+		},
 		// this is for issuing bundle push command
 		{
 			StdOut: shcBundlePushCompleteStr,
@@ -2729,7 +2747,7 @@ func TestSHCRunPlaybook(t *testing.T) {
 	}
 
 	// Test4: valid scenario where bundle push state moves from Pending -> In Progress
-	mockPodExecReturnContexts[1].Err = nil
+	mockPodExecReturnContexts[2].Err = nil
 	err = playbookContext.runPlaybook(ctx)
 	if err != nil {
 		t.Errorf("runPlaybook() should not have returned error, err=%v", err)
@@ -2743,7 +2761,7 @@ func TestSHCRunPlaybook(t *testing.T) {
 
 	// Test6: Invalid scenario where checking the status file returned error but removing it is successful
 	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
-	mockPodExecReturnContexts[3].StdErr = ""
+	mockPodExecReturnContexts[4].StdErr = ""
 	err = playbookContext.runPlaybook(ctx)
 	if err == nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending {
 		t.Errorf("runPlaybook() should have returned error or wrong bundle push state, err=%v, bundle push state=%s", err, bundlePushStateAsStr(ctx, getBundlePushState(afwPipeline)))
@@ -2751,14 +2769,14 @@ func TestSHCRunPlaybook(t *testing.T) {
 
 	// Test7: Bundle push is still in progress since stdOut = ""
 	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
-	mockPodExecReturnContexts[2].StdErr = ""
+	mockPodExecReturnContexts[3].StdErr = ""
 	err = playbookContext.runPlaybook(ctx)
 	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushInProgress {
 		t.Errorf("runPlaybook() should not have returned error or wrong bundle push state, err=%v, bundle push state=%s", err, bundlePushStateAsStr(ctx, getBundlePushState(afwPipeline)))
 	}
 
 	// Test8: Bundle push is still in progress since stdOut != shcBundlePushCompleteStr
-	mockPodExecReturnContexts[2].StdOut = "Error while deploying apps"
+	mockPodExecReturnContexts[3].StdOut = "Error while deploying apps"
 	err = playbookContext.runPlaybook(ctx)
 	if err == nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending {
 		t.Errorf("runPlaybook() should have returned error or wrong bundle push state, err=%v, bundle push state=%s", err, bundlePushStateAsStr(ctx, getBundlePushState(afwPipeline)))
@@ -2766,8 +2784,8 @@ func TestSHCRunPlaybook(t *testing.T) {
 
 	// Test9: SHC status file should have the desired SHC bundle push complete message in it now. But removing the status file
 	// will have an error hence we won't mark the whole bundle push state as complete yet.
-	mockPodExecReturnContexts[2].StdOut = shcBundlePushCompleteStr
-	mockPodExecReturnContexts[3].StdErr = "some dummy error"
+	mockPodExecReturnContexts[3].StdOut = shcBundlePushCompleteStr
+	mockPodExecReturnContexts[4].StdErr = "some dummy error"
 	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
 	err = playbookContext.runPlaybook(ctx)
 	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushInProgress {
@@ -2775,7 +2793,7 @@ func TestSHCRunPlaybook(t *testing.T) {
 	}
 
 	// Test10: now removing the bundle push status file should return success and hence the bundle push should be complete now.
-	mockPodExecReturnContexts[3].StdErr = ""
+	mockPodExecReturnContexts[4].StdErr = ""
 	err = playbookContext.runPlaybook(ctx)
 	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushComplete {
 		t.Errorf("runPlaybook() should not have returned error or wrong bundle push state, err=%v,  got bundle push state=%s, expected shc bundle push state=Bundle Push Complete", err, bundlePushStateAsStr(ctx, getBundlePushState(afwPipeline)))
@@ -2791,6 +2809,187 @@ func TestSHCRunPlaybook(t *testing.T) {
 	mockPodExecClient.CheckPodExecCommands(t, "shcPlayBookContext.runPlayBook")
 }
 
+func TestSHCRunPlaybookBundlePushPolicy(t *testing.T) {
+	ctx := context.TODO()
+	cr := &enterpriseApi.SearchHeadCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "SearchHeadCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Status.Phase = enterpriseApi.PhaseReady
+
+	c := spltest.NewMockClient()
+	appDeployContext := &enterpriseApi.AppDeploymentContext{}
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
+	afwPipeline := initAppInstallPipeline(ctx, appDeployContext, c, cr)
+
+	podExecCommands := []string{
+		fmt.Sprintf(cmdSetFilePermissionsToRW, shcAppsLocationOnDeployer),
+		shcValidateBundleCmdStr,
+		"/opt/splunk/bin/splunk apply shcluster-bundle",
+	}
+	mockPodExecReturnContexts := []*spltest.MockPodExecReturnContext{
+		{},
+		{
+			StdOut: "No spec file for: /opt/splunk/etc/shcluster/apps/app1/default/app1.conf\nInvalid key in stanza [default] in /opt/splunk/etc/shcluster/apps/app1/default/props.conf, line 2: SHOULD_LINEMERG (value: false)\n",
+		},
+		{},
+	}
+	mockPodExecClient := &spltest.MockPodExecClient{Cr: cr}
+	mockPodExecClient.AddMockPodExecReturnContexts(ctx, podExecCommands, mockPodExecReturnContexts...)
+	playbookContext := getClusterScopePlaybookContext(ctx, c, cr, afwPipeline, getApplicablePodNameForAppFramework(cr, 0), cr.GetObjectKind().GroupVersionKind().Kind, mockPodExecClient)
+
+	// invalid bundle is not pushed
+	validation := &appDeployContext.BundlePushStatus.Validation
+	err := playbookContext.runPlaybook(ctx)
+	if err == nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending || validation.Phase != enterpriseApi.BundleValidationFailure || len(validation.Errors) != 1 || !validation.RestartRequired {
+		t.Errorf("runPlaybook() should not push an invalid bundle. err: %v, status: %v", err, *validation)
+	}
+
+	// bundle push is held outside the restart windows, window opens every Feb 29th at midnight
+	now := time.Now()
+	if now.Month() == time.February && now.Day() == 29 && now.Hour() == 0 && now.Minute() == 0 {
+		t.Skip("Restart window is open now")
+	}
+	cr.Spec.BundlePushPolicy.RestartWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 29 2 *", DurationMinutes: 1}}
+	mockPodExecReturnContexts[1].StdOut = ""
+	mockPodExecClient.GotCmdList = nil
+	err = playbookContext.runPlaybook(ctx)
+	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending || !validation.PushHeld || len(mockPodExecClient.GotCmdList) != 0 {
+		t.Errorf("runPlaybook() should hold the bundle push. err: %v, status: %v, commands: %v", err, *validation, mockPodExecClient.GotCmdList)
+	}
+
+	// valid bundle is pushed in the restart window
+	cr.Annotations = map[string]string{enterpriseApi.MaintenanceWindowOverrideAnnotation: "true"}
+	err = playbookContext.runPlaybook(ctx)
+	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushInProgress || validation.PushHeld || validation.Phase != enterpriseApi.BundleValidationSuccess || len(validation.Errors) != 0 {
+		t.Errorf("runPlaybook() should push the valid bundle. err: %v, status: %v", err, *validation)
+	}
+	mockPodExecClient.CheckPodExecCommands(t, "shcPlayBookContext.runPlayBook")
+
+	// validation is skipped
+	cr.Spec.BundlePushPolicy.SkipValidation = true
+	setBundlePushState(ctx, afwPipeline, enterpriseApi.BundlePushPending)
+	mockPodExecClient.GotCmdList = nil
+	err = playbookContext.runPlaybook(ctx)
+	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushInProgress {
+		t.Errorf("runPlaybook() should push the bundle without validation. err: %v", err)
+	}
+	for _, cmd := range mockPodExecClient.GotCmdList {
+		if cmd == shcValidateBundleCmdStr {
+			t.Errorf("runPlaybook() should not validate the bundle")
+		}
+	}
+}
+
+func TestIDXCRunPlaybookBundlePushPolicy(t *testing.T) {
+	ctx := context.TODO()
+	cr := &enterpriseApi.ClusterManager{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterManager",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	savedGetManagerAppsBundleClient := getManagerAppsBundleClient
+	defer func() { getManagerAppsBundleClient = savedGetManagerAppsBundleClient }()
+	getManagerAppsBundleClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (*splclient.SplunkClient, error) {
+		sc := splclient.NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd")
+		sc.Client = mockSplunkClient
+		return sc, nil
+	}
+	validateURL := "https://localhost:8089/services/cluster/manager/control/default/validate_bundle"
+	infoURL := "https://localhost:8089/services/cluster/manager/info?count=0&output_mode=json"
+	infoBody := func(timestamp int64, valid bool, restart bool, errors string) string {
+		return fmt.Sprintf(`{"entry":[{"content":{"last_validated_bundle":{"checksum":"14310A4AABD23E85BBD4559C4A3B59F8","is_valid_bundle":%t,"timestamp":%d},"last_check_restart_bundle_result":%t,"apply_bundle_status":{"invalid_bundle":{"bundle_validation_errors_on_master":[%s]},"status":"None"}}}]}`, valid, timestamp, restart, errors)
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "POST", URL: validateURL, Status: 200, Body: ""})
+
+	c := spltest.NewMockClient()
+	appDeployContext := &enterpriseApi.AppDeploymentContext{}
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
+	afwPipeline := initAppInstallPipeline(ctx, appDeployContext, c, cr)
+
+	podExecCommands := []string{
+		fmt.Sprintf(cmdSetFilePermissionsToRW, idxcAppsLocationOnClusterManager),
+		applyIdxcBundleCmdStr,
+	}
+	mockPodExecReturnContexts := []*spltest.MockPodExecReturnContext{
+		{},
+		{
+			StdErr: "OK\n",
+		},
+	}
+	mockPodExecClient := &spltest.MockPodExecClient{Cr: cr}
+	mockPodExecClient.AddMockPodExecReturnContexts(ctx, podExecCommands, mockPodExecReturnContexts...)
+	playbookContext := getClusterScopePlaybookContext(ctx, c, cr, afwPipeline, getApplicablePodNameForAppFramework(cr, 0), cr.GetObjectKind().GroupVersionKind().Kind, mockPodExecClient)
+	bundlePushed := func() bool {
+		for _, cmd := range mockPodExecClient.GotCmdList {
+			if cmd == applyIdxcBundleCmdStr {
+				return true
+			}
+		}
+		return false
+	}
+
+	// bundle push waits for the validation
+	validation := &appDeployContext.BundlePushStatus.Validation
+	err := playbookContext.runPlaybook(ctx)
+	if err == nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending || validation.Phase != enterpriseApi.BundleValidationPending || bundlePushed() {
+		t.Errorf("runPlaybook() should wait for the bundle validation. err: %v, status: %v", err, *validation)
+	}
+
+	// invalid bundle is not pushed
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: infoURL, Status: 200, Body: infoBody(validation.LastValidationTime, false, true, `"indexes.conf: invalid homePath"`)})
+	err = playbookContext.runPlaybook(ctx)
+	if err == nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending || validation.Phase != enterpriseApi.BundleValidationFailure || len(validation.Errors) != 1 || bundlePushed() {
+		t.Errorf("runPlaybook() should not push an invalid bundle. err: %v, status: %v", err, *validation)
+	}
+
+	// bundle push is held outside the restart windows, window opens every Feb 29th at midnight
+	now := time.Now()
+	if now.Month() == time.February && now.Day() == 29 && now.Hour() == 0 && now.Minute() == 0 {
+		t.Skip("Restart window is open now")
+	}
+	cr.Spec.BundlePushPolicy.RestartWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 29 2 *", DurationMinutes: 1}}
+	err = playbookContext.runPlaybook(ctx)
+	if err == nil || validation.Phase != enterpriseApi.BundleValidationPending || bundlePushed() {
+		t.Errorf("runPlaybook() should validate the bundle again after a failure. err: %v, status: %v", err, *validation)
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: infoURL, Status: 200, Body: infoBody(validation.LastValidationTime, true, true, "")})
+	err = playbookContext.runPlaybook(ctx)
+	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushPending || validation.Phase != enterpriseApi.BundleValidationSuccess || !validation.RestartRequired || !validation.PushHeld || bundlePushed() {
+		t.Errorf("runPlaybook() should hold the bundle push. err: %v, status: %v, commands: %v", err, *validation, mockPodExecClient.GotCmdList)
+	}
+
+	// valid bundle is pushed in the restart window, without validating it again
+	cr.Annotations = map[string]string{enterpriseApi.MaintenanceWindowOverrideAnnotation: "true"}
+	mockSplunkClient.GotRequests = nil
+	err = playbookContext.runPlaybook(ctx)
+	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushInProgress || validation.PushHeld || !bundlePushed() || len(mockSplunkClient.GotRequests) != 0 {
+		t.Errorf("runPlaybook() should push the valid bundle. err: %v, status: %v, requests: %d", err, *validation, len(mockSplunkClient.GotRequests))
+	}
+	mockPodExecClient.CheckPodExecCommands(t, "idxcPlayBookContext.runPlayBook")
+
+	// validation is skipped
+	cr.Spec.BundlePushPolicy.SkipValidation = true
+	*validation = enterpriseApi.BundleValidationStatus{}
+	setBundlePushState(ctx, afwPipeline, enterpriseApi.BundlePushPending)
+	mockPodExecClient.GotCmdList = nil
+	err = playbookContext.runPlaybook(ctx)
+	if err != nil || getBundlePushState(afwPipeline) != enterpriseApi.BundlePushInProgress || !bundlePushed() || len(mockSplunkClient.GotRequests) != 0 {
+		t.Errorf("runPlaybook() should push the bundle without validation. err: %v, requests: %d", err, len(mockSplunkClient.GotRequests))
+	}
+}
+
 func TestRunLocalScopedPlaybook(t *testing.T) {
 	ctx := context.TODO()
 	// Test for each phase can send the worker to down stream
@@ -3414,6 +3613,147 @@ func TestFreeInstallSlotForPod(t *testing.T) {
 	freeInstallSlotForPod(ctx, podInstallTracker, podName)
 }
 
+func TestGetAppInstallOrder(t *testing.T) {
+	appSrcSpec := &enterpriseApi.AppSourceSpec{
+		Name: "appSrc1",
+		AppDependencies: []enterpriseApi.AppDependencySpec{
+			{Name: "app1.tgz", DependsOn: []string{"app3"}},
+		},
+	}
+
+	deployInfoList := []enterpriseApi.AppDeploymentInfo{
+		{AppName: "app1.tgz", RepoState: enterpriseApi.RepoStateActive},
+		{AppName: "app2.spl", RepoState: enterpriseApi.RepoStateActive, Dependencies: []string{"app1_folder"}},
+		{AppName: "app3.tgz", RepoState: enterpriseApi.RepoStateActive},
+		{AppName: "app4.tgz", RepoState: enterpriseApi.RepoStateActive, AppPackageTopFolder: "app1_folder"},
+	}
+
+	// app1 waits for app3, app2 waits for app4(referred by the app name)
+	order := getAppInstallOrder(appSrcSpec, deployInfoList)
+	if !reflect.DeepEqual(order, []int{2, 0, 3, 1}) {
+		t.Errorf("Got wrong install order %v", order)
+	}
+
+	// without any dependencies, order should not change
+	order = getAppInstallOrder(nil, deployInfoList[2:])
+	if !reflect.DeepEqual(order, []int{0, 1}) {
+		t.Errorf("Got wrong install order %v", order)
+	}
+
+	// apps in a cycle should be placed at the end
+	deployInfoList[2].Dependencies = []string{"app1.tgz"}
+	order = getAppInstallOrder(appSrcSpec, deployInfoList)
+	if !reflect.DeepEqual(order, []int{3, 1, 0, 2}) {
+		t.Errorf("Got wrong install order %v", order)
+	}
+}
+
+func TestCheckAppDependenciesForInstall(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	appFrameworkConfig := &enterpriseApi.AppFrameworkSpec{
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{
+				Name: "appSrc1",
+				AppDependencies: []enterpriseApi.AppDependencySpec{
+					{Name: "app2.tgz", DependsOn: []string{"app1"}},
+				},
+			},
+		},
+	}
+
+	appDeployContext := &enterpriseApi.AppDeploymentContext{
+		AppsSrcDeployStatus: map[string]enterpriseApi.AppSrcDeployInfo{
+			"appSrc1": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{
+						AppName:      "app1.tgz",
+						RepoState:    enterpriseApi.RepoStateActive,
+						PhaseInfo:    enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallPending},
+						AuxPhaseInfo: []enterpriseApi.PhaseInfo{{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallPending}},
+					},
+					{
+						AppName:      "app2.tgz",
+						RepoState:    enterpriseApi.RepoStateActive,
+						PhaseInfo:    enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallPending},
+						AuxPhaseInfo: []enterpriseApi.PhaseInfo{{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallPending}},
+					},
+				},
+			},
+		},
+	}
+
+	ppln := &AppInstallPipeline{appDeployContext: appDeployContext}
+	deployInfoList := appDeployContext.AppsSrcDeployStatus["appSrc1"].AppDeploymentInfoList
+
+	worker := &PipelineWorker{
+		appSrcName:    "appSrc1",
+		appDeployInfo: &deployInfoList[1],
+		targetPodName: "splunk-stack1-standalone-0",
+		afwConfig:     appFrameworkConfig,
+		cr:            &cr,
+		fanOut:        true,
+	}
+
+	// app1 is not yet installed on the pod
+	installed, err := ppln.checkAppDependenciesForInstall(ctx, worker)
+	if installed || err != nil {
+		t.Errorf("app2 should wait for app1 to be installed. installed: %v, err: %v", installed, err)
+	}
+
+	// app without dependencies can be installed right away
+	installed, err = ppln.checkAppDependenciesForInstall(ctx, &PipelineWorker{appSrcName: "appSrc1", appDeployInfo: &deployInfoList[0], targetPodName: worker.targetPodName, afwConfig: appFrameworkConfig, cr: &cr})
+	if !installed || err != nil {
+		t.Errorf("app1 has no dependencies. installed: %v, err: %v", installed, err)
+	}
+
+	// app1 installed on the pod
+	deployInfoList[0].AuxPhaseInfo[0].Status = enterpriseApi.AppPkgInstallComplete
+	installed, err = ppln.checkAppDependenciesForInstall(ctx, worker)
+	if !installed || err != nil {
+		t.Errorf("app1 is installed on the pod. installed: %v, err: %v", installed, err)
+	}
+
+	// app1 failed to install
+	deployInfoList[0].AuxPhaseInfo[0].Status = enterpriseApi.AppPkgInstallError
+	_, err = ppln.checkAppDependenciesForInstall(ctx, worker)
+	if err == nil {
+		t.Errorf("Expected an error when the dependency failed to install")
+	}
+
+	// cyclic dependency
+	deployInfoList[0].AuxPhaseInfo[0].Status = enterpriseApi.AppPkgInstallPending
+	deployInfoList[0].Dependencies = []string{"app2"}
+	_, err = ppln.checkAppDependenciesForInstall(ctx, worker)
+	if err == nil || !strings.Contains(err.Error(), "cyclic dependency") {
+		t.Errorf("Expected an error for the cyclic dependency. err: %v", err)
+	}
+
+	// dependency missing from the app source
+	deployInfoList[0].Dependencies = nil
+	deployInfoList[0].RepoState = enterpriseApi.RepoStateDeleted
+	_, err = ppln.checkAppDependenciesForInstall(ctx, worker)
+	if err == nil || !strings.Contains(err.Error(), "not present in the app source") {
+		t.Errorf("Expected an error for the missing dependency. err: %v", err)
+	}
+
+	// invalid pod name
+	worker.targetPodName = "invalidPodName"
+	_, err = ppln.checkAppDependenciesForInstall(ctx, worker)
+	if err == nil {
+		t.Errorf("Expected an error for invalid pod name")
+	}
+}
+
 func TestIsPendingClusterScopeWork(t *testing.T) {
 	cr := &enterpriseApi.ClusterManager{
 		TypeMeta: metav1.TypeMeta{
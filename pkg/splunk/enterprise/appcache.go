@@ -0,0 +1,523 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// errInsufficientStorage is returned when there is no storage for the app package, even after evicting the cache
+var errInsufficientStorage = errors.New("insufficient storage for the app package")
+
+var appPackageCacheHits = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "splunk_operator_app_package_cache_hits_total",
+	Help: "The number of app package downloads served from the operator app package cache",
+})
+
+var appPackageCacheMisses = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "splunk_operator_app_package_cache_misses_total",
+	Help: "The number of app package downloads from the remote storage",
+})
+
+var appPackageCacheEvictions = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "splunk_operator_app_package_cache_evictions_total",
+	Help: "The number of app packages evicted from the operator app package cache",
+})
+
+var appPackageCacheHitRatio = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "splunk_operator_app_package_cache_hit_ratio",
+	Help: "The ratio of app package downloads served from the operator app package cache",
+})
+
+var appPackageCacheSize = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "splunk_operator_app_package_cache_size_bytes",
+	Help: "The size of the app packages in the operator app package cache",
+})
+
+var appPackageCacheEntries = prometheus.NewGauge(prometheus.GaugeOpts{
+	Name: "splunk_operator_app_package_cache_entries",
+	Help: "The number of app packages in the operator app package cache",
+})
+
+func init() {
+	metrics.Registry.MustRegister(
+		appPackageCacheHits,
+		appPackageCacheMisses,
+		appPackageCacheEvictions,
+		appPackageCacheHitRatio,
+		appPackageCacheSize,
+		appPackageCacheEntries,
+	)
+}
+
+// appPackageCacheEntry represents an app package in the cache
+type appPackageCacheEntry struct {
+	// path of the app package in the cache directory
+	path string
+
+	// size of the app package on the operator storage
+	size uint64
+
+	// local paths of the app packages of the CRs, linked to the cache entry
+	holders map[string]bool
+
+	// storage reserved for the holders with a copy of the app package, when the hard link is not supported
+	copies map[string]uint64
+
+	// used for the LRU eviction
+	lastUsed time.Time
+
+	// indicates the app package is loaded from the operator storage, so the local paths linked to it by an earlier
+	// run of the operator are not in the holders
+	restored bool
+
+	// closed once the app package download is finished, successfully or not
+	ready chan struct{}
+
+	// download error, if any
+	err error
+}
+
+// appPackageCache is a content addressed cache of the app packages downloaded on the operator pod. The app packages
+// of the CRs are hard links to the cache entries, so that an app package referred by multiple CRs is downloaded
+// and stored only once
+type appPackageCache struct {
+	// mutex to serialize the access to the cache
+	mutex sync.Mutex
+
+	// cache entries keyed by the digest of the remote object
+	entries map[string]*appPackageCacheEntry
+
+	// indicates the existing cache entries are loaded from the operator storage
+	loaded bool
+
+	hits   uint64
+	misses uint64
+}
+
+// initAppPackageCache initializes the app package cache
+func initAppPackageCache() {
+	operatorResourceTracker.appPackageCache = &appPackageCache{
+		entries: make(map[string]*appPackageCacheEntry),
+	}
+}
+
+// getAppPackageCacheDir returns the directory of the app package cache on the operator pod
+func getAppPackageCacheDir() string {
+	return filepath.Join(splcommon.AppDownloadVolume, "appPackageCache")
+}
+
+// getAppPackageCacheKey returns the cache key of the remote object, which is the digest of the remote storage
+// endpoint, bucket, object key and the ETag
+func getAppPackageCacheKey(vol *enterpriseApi.VolumeSpec, remoteKey, etag string) string {
+	var provider, endpoint, bucket string
+	if vol != nil {
+		provider, endpoint, bucket = vol.Provider, vol.Endpoint, strings.Split(vol.Path, "/")[0]
+	}
+	id := strings.Join([]string{provider, endpoint, bucket, remoteKey, strings.Trim(etag, "\"")}, "\x00")
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(id)))
+}
+
+// getLinkCount returns the number of hard links to the file
+func getLinkCount(fi os.FileInfo) uint64 {
+	if stat, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return uint64(stat.Nlink)
+	}
+	return 1
+}
+
+// load adds the app packages left in the cache directory by an earlier run of the operator. Must be called with
+// the cache mutex held
+func (cache *appPackageCache) load(ctx context.Context) {
+	if cache.loaded {
+		return
+	}
+	cache.loaded = true
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("appPackageCache.load")
+
+	files, err := os.ReadDir(getAppPackageCacheDir())
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			scopedLog.Error(err, "unable to read the app package cache directory")
+		}
+		return
+	}
+
+	for _, f := range files {
+		// skip the partially downloaded app packages
+		if f.IsDir() || strings.Contains(f.Name(), ".") {
+			continue
+		}
+
+		fi, err := f.Info()
+		if err != nil {
+			continue
+		}
+
+		ready := make(chan struct{})
+		close(ready)
+		cache.entries[f.Name()] = &appPackageCacheEntry{
+			path:     filepath.Join(getAppPackageCacheDir(), f.Name()),
+			size:     uint64(fi.Size()),
+			holders:  make(map[string]bool),
+			copies:   make(map[string]uint64),
+			lastUsed: fi.ModTime(),
+			ready:    ready,
+			restored: true,
+		}
+	}
+
+	scopedLog.Info("Loaded the app package cache", "entries", len(cache.entries))
+}
+
+// updateMetrics updates the cache metrics. Must be called with the cache mutex held
+func (cache *appPackageCache) updateMetrics() {
+	var size uint64
+	for _, entry := range cache.entries {
+		size += entry.size
+	}
+
+	appPackageCacheSize.Set(float64(size))
+	appPackageCacheEntries.Set(float64(len(cache.entries)))
+	if cache.hits+cache.misses > 0 {
+		appPackageCacheHitRatio.Set(float64(cache.hits) / float64(cache.hits+cache.misses))
+	}
+}
+
+// evictLRU evicts the least recently used app package, which is not referred by any CR. Returns false, if there
+// is nothing to evict
+func (cache *appPackageCache) evictLRU(ctx context.Context) bool {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("appPackageCache.evictLRU")
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	var lruKey string
+	var lruEntry *appPackageCacheEntry
+	for key, entry := range cache.entries {
+		select {
+		case <-entry.ready:
+		default:
+			// download in progress
+			continue
+		}
+
+		if len(entry.holders) > 0 {
+			continue
+		}
+
+		// app package can still be linked to a CR, after an operator restart
+		if fi, err := os.Stat(entry.path); err == nil && getLinkCount(fi) > 1 {
+			continue
+		}
+
+		if lruEntry == nil || entry.lastUsed.Before(lruEntry.lastUsed) {
+			lruKey, lruEntry = key, entry
+		}
+	}
+
+	if lruEntry == nil {
+		return false
+	}
+
+	delete(cache.entries, lruKey)
+	err := os.Remove(lruEntry.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		scopedLog.Error(err, "unable to remove the app package from the cache", "path", lruEntry.path)
+	}
+	releaseStorage(lruEntry.size)
+	appPackageCacheEvictions.Inc()
+	cache.updateMetrics()
+
+	scopedLog.Info("Evicted the app package from the cache", "path", lruEntry.path, "size", lruEntry.size)
+	return true
+}
+
+// reserve reserves the storage for an app package, evicting the least recently used app packages as needed
+func (cache *appPackageCache) reserve(ctx context.Context, size uint64) error {
+	for {
+		err := reserveStorage(size)
+		if err == nil {
+			return nil
+		}
+
+		if !cache.evictLRU(ctx) {
+			return fmt.Errorf("%w. error: %v", errInsufficientStorage, err)
+		}
+	}
+}
+
+// linkFile is a func pointer, so that the copy fallback can be tested
+var linkFile = os.Link
+
+// link links the cached app package to the local path of the CR. If the hard link is not supported, the app package
+// is copied, and the storage is reserved for the copy till it is released
+func (cache *appPackageCache) link(ctx context.Context, key string, entry *appPackageCacheEntry, localPath string) error {
+	err := func() error {
+		cache.mutex.Lock()
+		defer cache.mutex.Unlock()
+
+		// app package could have been evicted after the download, before it is linked
+		if cache.entries[key] != entry {
+			return fmt.Errorf("app package %s is evicted from the cache", key)
+		}
+
+		entry.holders[localPath] = true
+		entry.lastUsed = time.Now()
+
+		// an earlier copy at the local path is replaced below
+		if size, ok := entry.copies[localPath]; ok {
+			delete(entry.copies, localPath)
+			releaseStorage(size)
+		}
+		return nil
+	}()
+	if err != nil {
+		return err
+	}
+
+	os.Remove(localPath)
+	err = linkFile(entry.path, localPath)
+	if err != nil {
+		// fallback to a copy, if the hard link is not supported
+		err = cache.copy(ctx, entry, localPath)
+	}
+
+	if err != nil {
+		cache.release(ctx, localPath)
+	}
+	return err
+}
+
+// copy copies the cached app package to the local path of the CR, reserving the storage for the copy
+func (cache *appPackageCache) copy(ctx context.Context, entry *appPackageCacheEntry, localPath string) error {
+	err := cache.reserve(ctx, entry.size)
+	if err != nil {
+		return err
+	}
+
+	err = copyFile(entry.path, localPath)
+	if err != nil {
+		os.Remove(localPath)
+		releaseStorage(entry.size)
+		return err
+	}
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	entry.copies[localPath] = entry.size
+	return nil
+}
+
+// fetch gets the app package with the given key to the local path of the CR. If the app package is not in the
+// cache, it is downloaded to the cache with the download function. Concurrent fetches of the same app package
+// wait for a single download
+func (cache *appPackageCache) fetch(ctx context.Context, key string, localPath string, estimatedSize uint64, download func(cachePath string) error) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("appPackageCache.fetch").WithValues("key", key, "localPath", localPath)
+
+	cache.mutex.Lock()
+	cache.load(ctx)
+	entry, ok := cache.entries[key]
+	if !ok {
+		entry = &appPackageCacheEntry{
+			path:    filepath.Join(getAppPackageCacheDir(), key),
+			holders: make(map[string]bool),
+			copies:  make(map[string]uint64),
+			ready:   make(chan struct{}),
+		}
+		cache.entries[key] = entry
+		cache.misses++
+		appPackageCacheMisses.Inc()
+	}
+	cache.mutex.Unlock()
+
+	if ok {
+		<-entry.ready
+		if entry.err != nil {
+			return entry.err
+		}
+
+		func() {
+			cache.mutex.Lock()
+			defer cache.mutex.Unlock()
+			cache.hits++
+			appPackageCacheHits.Inc()
+			cache.updateMetrics()
+		}()
+
+		scopedLog.Info("App package found in the cache")
+		return cache.link(ctx, key, entry, localPath)
+	}
+
+	err := cache.download(ctx, entry, estimatedSize, download)
+
+	cache.mutex.Lock()
+	if err != nil {
+		delete(cache.entries, key)
+		entry.err = err
+	}
+	entry.lastUsed = time.Now()
+	close(entry.ready)
+	cache.updateMetrics()
+	cache.mutex.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	scopedLog.Info("App package downloaded to the cache", "size", entry.size)
+	return cache.link(ctx, key, entry, localPath)
+}
+
+// download downloads the app package to the cache entry, and accounts its storage
+func (cache *appPackageCache) download(ctx context.Context, entry *appPackageCacheEntry, estimatedSize uint64, download func(cachePath string) error) error {
+	err := createAppDownloadDir(ctx, getAppPackageCacheDir())
+	if err != nil {
+		return err
+	}
+
+	err = cache.reserve(ctx, estimatedSize)
+	if err != nil {
+		return err
+	}
+
+	err = download(entry.path)
+	if err != nil {
+		os.Remove(entry.path)
+		releaseStorage(estimatedSize)
+		return err
+	}
+
+	fi, err := os.Stat(entry.path)
+	if err != nil {
+		releaseStorage(estimatedSize)
+		return err
+	}
+
+	// size on the remote storage is just an estimate, as the app package may be converted
+	entry.size = uint64(fi.Size())
+	if entry.size > estimatedSize {
+		// the app package is already on the disk, so make room for the next downloads
+		err = cache.reserve(ctx, entry.size-estimatedSize)
+		if err != nil {
+			log.FromContext(ctx).Error(err, "unable to account the app package storage", "path", entry.path)
+		}
+	} else {
+		releaseStorage(estimatedSize - entry.size)
+	}
+
+	return nil
+}
+
+// release removes the reference of the CR local path from the cache, and releases the storage of its copy, if any.
+// Returns false, if the local path does not refer to any cached app package. Must be called before the local path
+// is removed
+func (cache *appPackageCache) release(ctx context.Context, localPath string) bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.load(ctx)
+
+	for _, entry := range cache.entries {
+		if entry.holders[localPath] {
+			delete(entry.holders, localPath)
+			if size, ok := entry.copies[localPath]; ok {
+				delete(entry.copies, localPath)
+				releaseStorage(size)
+			}
+			return true
+		}
+	}
+
+	// local path linked before an operator restart is not a holder, but is still a hard link to the cached app package
+	fi, err := os.Stat(localPath)
+	if err != nil {
+		return false
+	}
+	for _, entry := range cache.entries {
+		if !entry.restored {
+			continue
+		}
+		if entryFi, err := os.Stat(entry.path); err == nil && os.SameFile(fi, entryFi) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAppPackageCacheEnabled checks if the app package cache is initialized
+func isAppPackageCacheEnabled() bool {
+	return operatorResourceTracker != nil && operatorResourceTracker.appPackageCache != nil
+}
+
+// fetchAppPackage gets the app package to the local path of the CR through the app package cache. The app package
+// is downloaded directly to the local path, if the cache is not initialized
+func fetchAppPackage(ctx context.Context, key string, localPath string, estimatedSize uint64, download func(path string) error) error {
+	if !isAppPackageCacheEnabled() {
+		return download(localPath)
+	}
+
+	return operatorResourceTracker.appPackageCache.fetch(ctx, key, localPath, estimatedSize, download)
+}
+
+// releaseAppPackage releases the reference of the CR local path from the app package cache. Returns false, if the
+// app package is not from the cache. Must be called before the local path is removed
+func releaseAppPackage(ctx context.Context, localPath string) bool {
+	if !isAppPackageCacheEnabled() {
+		return false
+	}
+
+	return operatorResourceTracker.appPackageCache.release(ctx, localPath)
+}
+
+// copyFile copies the source file to the destination
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
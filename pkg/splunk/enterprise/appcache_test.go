@@ -0,0 +1,411 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+)
+
+// setupAppPackageCacheTest sets up the app package cache with the given available storage
+func setupAppPackageCacheTest(t *testing.T, availableDiskSpace uint64) string {
+	defaultVol := splcommon.AppDownloadVolume
+	defaultTracker := operatorResourceTracker
+
+	dir := t.TempDir()
+	splcommon.AppDownloadVolume = dir + "/"
+	operatorResourceTracker = &globalResourceTracker{
+		storage: &storageTracker{
+			availableDiskSpace: availableDiskSpace,
+		},
+	}
+	initAppPackageCache()
+
+	t.Cleanup(func() {
+		splcommon.AppDownloadVolume = defaultVol
+		operatorResourceTracker = defaultTracker
+	})
+
+	return dir
+}
+
+func writeTestAppPackage(size int) func(path string) error {
+	return func(path string) error {
+		return os.WriteFile(path, make([]byte, size), 0644)
+	}
+}
+
+func TestGetAppPackageCacheKey(t *testing.T) {
+	vol := &enterpriseApi.VolumeSpec{
+		Provider: "aws",
+		Endpoint: "https://s3-us-west-2.amazonaws.com",
+		Path:     "testbucket/operator",
+	}
+
+	key := getAppPackageCacheKey(vol, "adminApps/app1.tgz", "\"abcd\"")
+	if key != getAppPackageCacheKey(vol, "adminApps/app1.tgz", "abcd") {
+		t.Errorf("cache key should not depend on the ETag quotes")
+	}
+
+	if key == getAppPackageCacheKey(vol, "adminApps/app1.tgz", "efgh") {
+		t.Errorf("cache key should change with the ETag")
+	}
+
+	otherVol := *vol
+	otherVol.Path = "otherbucket/operator"
+	if key == getAppPackageCacheKey(&otherVol, "adminApps/app1.tgz", "abcd") {
+		t.Errorf("cache key should change with the bucket")
+	}
+
+	// volume path prefix is part of the object key, so it does not matter
+	otherVol = *vol
+	otherVol.Path = "testbucket"
+	if key != getAppPackageCacheKey(&otherVol, "adminApps/app1.tgz", "abcd") {
+		t.Errorf("cache key should only depend on the bucket of the volume")
+	}
+}
+
+func TestAppPackageCacheFetch(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 1024)
+	cache := operatorResourceTracker.appPackageCache
+
+	hits := testutil.ToFloat64(appPackageCacheHits)
+	misses := testutil.ToFloat64(appPackageCacheMisses)
+
+	var downloads int32
+	download := func(path string) error {
+		atomic.AddInt32(&downloads, 1)
+		return writeTestAppPackage(100)(path)
+	}
+
+	localPath1 := filepath.Join(dir, "app1_stdln1")
+	localPath2 := filepath.Join(dir, "app1_stdln2")
+
+	// first fetch downloads the app package
+	err := fetchAppPackage(ctx, "key1", localPath1, 200, download)
+	if err != nil {
+		t.Errorf("fetch should not have returned error. error: %v", err)
+	}
+
+	// second fetch should be served from the cache
+	err = fetchAppPackage(ctx, "key1", localPath2, 200, download)
+	if err != nil {
+		t.Errorf("fetch should not have returned error. error: %v", err)
+	}
+
+	if downloads != 1 {
+		t.Errorf("app package should be downloaded only once. downloads: %d", downloads)
+	}
+
+	for _, path := range []string{localPath1, localPath2} {
+		fi, err := os.Stat(path)
+		if err != nil || fi.Size() != 100 {
+			t.Errorf("app package should be available at %s", path)
+		}
+	}
+
+	entry := cache.entries["key1"]
+	if entry == nil || len(entry.holders) != 2 || entry.size != 100 {
+		t.Errorf("cache entry should be referred by both the CRs. entry: %v", entry)
+	}
+
+	// storage should be accounted for the actual app package size, only once
+	if operatorResourceTracker.storage.availableDiskSpace != 1024-100 {
+		t.Errorf("unexpected available disk space %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+
+	if testutil.ToFloat64(appPackageCacheHits) != hits+1 || testutil.ToFloat64(appPackageCacheMisses) != misses+1 {
+		t.Errorf("cache hits and misses should be incremented")
+	}
+
+	if testutil.ToFloat64(appPackageCacheHitRatio) != 0.5 {
+		t.Errorf("unexpected cache hit ratio %v", testutil.ToFloat64(appPackageCacheHitRatio))
+	}
+
+	// releasing the app package should not release the storage
+	if !releaseAppPackage(ctx, localPath1) || !releaseAppPackage(ctx, localPath2) {
+		t.Errorf("app packages should be released from the cache")
+	}
+
+	if releaseAppPackage(ctx, localPath1) {
+		t.Errorf("app package should not be released twice")
+	}
+
+	if len(entry.holders) != 0 || operatorResourceTracker.storage.availableDiskSpace != 1024-100 {
+		t.Errorf("released app package should stay in the cache")
+	}
+}
+
+func TestAppPackageCacheCopyFallback(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 250)
+	cache := operatorResourceTracker.appPackageCache
+
+	// hard links not supported on the operator storage
+	defaultLinkFile := linkFile
+	linkFile = func(oldname, newname string) error {
+		return errors.New("link not supported")
+	}
+	defer func() {
+		linkFile = defaultLinkFile
+	}()
+
+	localPath1 := filepath.Join(dir, "app1_stdln1")
+	localPath2 := filepath.Join(dir, "app1_stdln2")
+	localPath3 := filepath.Join(dir, "app1_stdln3")
+
+	err := fetchAppPackage(ctx, "key1", localPath1, 100, writeTestAppPackage(100))
+	if err != nil {
+		t.Errorf("fetch should not have returned error. error: %v", err)
+	}
+
+	fi, err := os.Stat(localPath1)
+	if err != nil || fi.Size() != 100 {
+		t.Errorf("app package should be copied to %s", localPath1)
+	}
+
+	// storage is reserved for the cache entry and the copy
+	if operatorResourceTracker.storage.availableDiskSpace != 250-200 {
+		t.Errorf("unexpected available disk space %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+
+	// copying the app package again to the same local path should not reserve the storage again
+	err = fetchAppPackage(ctx, "key1", localPath1, 100, writeTestAppPackage(100))
+	if err != nil || operatorResourceTracker.storage.availableDiskSpace != 250-200 {
+		t.Errorf("unexpected available disk space %d. error: %v", operatorResourceTracker.storage.availableDiskSpace, err)
+	}
+
+	// no storage for one more copy
+	err = fetchAppPackage(ctx, "key1", localPath2, 100, writeTestAppPackage(100))
+	if !errors.Is(err, errInsufficientStorage) {
+		t.Errorf("fetch should have failed with insufficient storage. error: %v", err)
+	}
+	if _, err := os.Stat(localPath2); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("app package should not be copied without the storage")
+	}
+	if len(cache.entries["key1"].holders) != 1 {
+		t.Errorf("failed copy should not refer to the cache entry")
+	}
+
+	// releasing the copy releases its storage
+	if !releaseAppPackage(ctx, localPath1) {
+		t.Errorf("app package should be released from the cache")
+	}
+	if operatorResourceTracker.storage.availableDiskSpace != 250-100 {
+		t.Errorf("unexpected available disk space %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+
+	err = fetchAppPackage(ctx, "key1", localPath3, 100, writeTestAppPackage(100))
+	if err != nil || operatorResourceTracker.storage.availableDiskSpace != 250-200 {
+		t.Errorf("unexpected available disk space %d. error: %v", operatorResourceTracker.storage.availableDiskSpace, err)
+	}
+}
+
+func TestAppPackageCacheConcurrentFetch(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 1024)
+
+	var downloads int32
+	release := make(chan struct{})
+	download := func(path string) error {
+		atomic.AddInt32(&downloads, 1)
+		<-release
+		return writeTestAppPackage(10)(path)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 5)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = fetchAppPackage(ctx, "key1", filepath.Join(dir, "app1_"+string(rune('a'+i))), 10, download)
+		}(i)
+	}
+
+	close(release)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("fetch %d should not have returned error. error: %v", i, err)
+		}
+	}
+
+	if downloads != 1 {
+		t.Errorf("concurrent fetches should wait for a single download. downloads: %d", downloads)
+	}
+}
+
+func TestAppPackageCacheFetchError(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 1024)
+	cache := operatorResourceTracker.appPackageCache
+
+	err := fetchAppPackage(ctx, "key1", filepath.Join(dir, "app1"), 100, func(path string) error {
+		return errors.New("download failed")
+	})
+	if err == nil {
+		t.Errorf("fetch should have returned error")
+	}
+
+	if _, ok := cache.entries["key1"]; ok {
+		t.Errorf("failed download should not be cached")
+	}
+
+	if operatorResourceTracker.storage.availableDiskSpace != 1024 {
+		t.Errorf("storage should be released for the failed download")
+	}
+
+	// next fetch should retry the download
+	err = fetchAppPackage(ctx, "key1", filepath.Join(dir, "app1"), 100, writeTestAppPackage(100))
+	if err != nil {
+		t.Errorf("fetch should not have returned error. error: %v", err)
+	}
+}
+
+func TestAppPackageCacheEviction(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 250)
+	cache := operatorResourceTracker.appPackageCache
+
+	evictions := testutil.ToFloat64(appPackageCacheEvictions)
+
+	localPath1 := filepath.Join(dir, "app1")
+	localPath2 := filepath.Join(dir, "app2")
+	localPath3 := filepath.Join(dir, "app3")
+
+	for key, path := range map[string]string{"key1": localPath1, "key2": localPath2} {
+		err := fetchAppPackage(ctx, key, path, 100, writeTestAppPackage(100))
+		if err != nil {
+			t.Errorf("fetch should not have returned error. error: %v", err)
+		}
+	}
+
+	// no storage to evict, as both the app packages are in use
+	err := fetchAppPackage(ctx, "key3", localPath3, 100, writeTestAppPackage(100))
+	if !errors.Is(err, errInsufficientStorage) {
+		t.Errorf("fetch should have returned insufficient storage error. error: %v", err)
+	}
+
+	// app package is still linked to the CR, so it can't be evicted
+	releaseAppPackage(ctx, localPath1)
+	err = fetchAppPackage(ctx, "key3", localPath3, 100, writeTestAppPackage(100))
+	if !errors.Is(err, errInsufficientStorage) {
+		t.Errorf("fetch should have returned insufficient storage error. error: %v", err)
+	}
+
+	// once the CR app package is deleted, the cached app package can be evicted
+	os.Remove(localPath1)
+	err = fetchAppPackage(ctx, "key3", localPath3, 100, writeTestAppPackage(100))
+	if err != nil {
+		t.Errorf("fetch should not have returned error. error: %v", err)
+	}
+
+	if _, ok := cache.entries["key1"]; ok {
+		t.Errorf("least recently used app package should have been evicted")
+	}
+
+	if _, err := os.Stat(filepath.Join(getAppPackageCacheDir(), "key1")); !os.IsNotExist(err) {
+		t.Errorf("evicted app package should be removed from the operator storage")
+	}
+
+	if operatorResourceTracker.storage.availableDiskSpace != 50 {
+		t.Errorf("unexpected available disk space %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+
+	if testutil.ToFloat64(appPackageCacheEvictions) != evictions+1 {
+		t.Errorf("cache evictions should be incremented")
+	}
+}
+
+func TestAppPackageCacheLoad(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 1024)
+
+	err := createAppDownloadDir(ctx, getAppPackageCacheDir())
+	if err != nil {
+		t.Errorf("unable to create the cache directory. error: %v", err)
+	}
+
+	// app package left by an earlier run of the operator, and a partial download
+	writeTestAppPackage(100)(filepath.Join(getAppPackageCacheDir(), "key1"))
+	writeTestAppPackage(100)(filepath.Join(getAppPackageCacheDir(), "key2.tmp"))
+
+	var downloads int32
+	err = fetchAppPackage(ctx, "key1", filepath.Join(dir, "app1"), 100, func(path string) error {
+		atomic.AddInt32(&downloads, 1)
+		return writeTestAppPackage(100)(path)
+	})
+	if err != nil {
+		t.Errorf("fetch should not have returned error. error: %v", err)
+	}
+
+	if downloads != 0 {
+		t.Errorf("app package should have been served from the cache")
+	}
+
+	if _, ok := operatorResourceTracker.appPackageCache.entries["key2.tmp"]; ok {
+		t.Errorf("partial downloads should not be loaded to the cache")
+	}
+}
+
+func TestAppPackageCacheReleaseAfterRestart(t *testing.T) {
+	ctx := context.TODO()
+	dir := setupAppPackageCacheTest(t, 1024)
+
+	err := createAppDownloadDir(ctx, getAppPackageCacheDir())
+	if err != nil {
+		t.Errorf("unable to create the cache directory. error: %v", err)
+	}
+
+	// app package linked by an earlier run of the operator, and an app package not from the cache
+	cachePath := filepath.Join(getAppPackageCacheDir(), "key1")
+	localPath1 := filepath.Join(dir, "app1")
+	localPath2 := filepath.Join(dir, "app2")
+	writeTestAppPackage(100)(cachePath)
+	if err = os.Link(cachePath, localPath1); err != nil {
+		t.Skipf("hard link not supported. error: %v", err)
+	}
+	writeTestAppPackage(100)(localPath2)
+
+	if !releaseAppPackage(ctx, localPath1) {
+		t.Errorf("app package linked before the restart should be released from the cache")
+	}
+	if releaseAppPackage(ctx, localPath2) {
+		t.Errorf("app package not from the cache should not be released from the cache")
+	}
+
+	// once the app package is deleted, the local path is not a link to the cached app package anymore
+	os.Remove(localPath1)
+	if releaseAppPackage(ctx, localPath1) {
+		t.Errorf("deleted app package should not be released from the cache")
+	}
+
+	if operatorResourceTracker.storage.availableDiskSpace != 1024 {
+		t.Errorf("releasing the app packages should not release the storage. available disk space %d", operatorResourceTracker.storage.availableDiskSpace)
+	}
+}
@@ -0,0 +1,587 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// appManifestFileName is the name of the app manifest file under the app top folder
+	appManifestFileName = "app.manifest"
+
+	// appConfFilePath is the path of the app.conf relative to the app top folder
+	appConfFilePath = "default/app.conf"
+
+	// maxAppConfFileSize is the max. size of a conf file read from the app package
+	maxAppConfFileSize = 1 << 20
+)
+
+// appPackageManifest represents the subset of the app.manifest used by the App Framework
+// see https://dev.splunk.com/enterprise/reference/packagingtoolkit/pkgtoolkitappmanifest/
+type appPackageManifest struct {
+	Info struct {
+		ID struct {
+			Name string `json:"name"`
+		} `json:"id"`
+	} `json:"info"`
+
+	// Dependencies is keyed by the name of the app this app depends on
+	Dependencies map[string]json.RawMessage `json:"dependencies"`
+}
+
+// appPackageInfo represents the details of an app package inspected on the operator pod
+type appPackageInfo struct {
+	// name of the top folder in the app package
+	topFolder string
+
+	// apps declared as dependencies in the app.manifest
+	dependencies []string
+
+	// app package policy violations
+	violations []string
+}
+
+// addViolation records a policy violation, ignoring the duplicates
+func (pkgInfo *appPackageInfo) addViolation(format string, args ...interface{}) {
+	violation := fmt.Sprintf(format, args...)
+	for _, v := range pkgInfo.violations {
+		if v == violation {
+			return
+		}
+	}
+	pkgInfo.violations = append(pkgInfo.violations, violation)
+}
+
+// isPathWithinDir confirms if the cleaned path is the given directory or is under it
+func isPathWithinDir(p, dir string) bool {
+	p = path.Clean(p)
+	return p == dir || strings.HasPrefix(p, dir+"/")
+}
+
+// hasParentDirRef confirms if the path has any parent directory(..) reference
+func hasParentDirRef(p string) bool {
+	for _, elem := range strings.Split(p, "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSplunkConf parses the content of a Splunk conf file, and returns the stanza names in the order of appearance
+func parseSplunkConf(data []byte) ([]string, error) {
+	var stanzas []string
+	continuation := false
+	for i, line := range strings.Split(strings.TrimPrefix(string(data), "\ufeff"), "\n") {
+		line = strings.TrimSpace(line)
+		if continuation {
+			continuation = strings.HasSuffix(line, "\\")
+			continue
+		}
+
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "["):
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("invalid stanza at line %d", i+1)
+			}
+			stanzas = append(stanzas, line[1:len(line)-1])
+		case strings.Contains(line, "="):
+			if strings.TrimSpace(strings.SplitN(line, "=", 2)[0]) == "" {
+				return nil, fmt.Errorf("missing key at line %d", i+1)
+			}
+			continuation = strings.HasSuffix(line, "\\")
+		default:
+			return nil, fmt.Errorf("invalid entry at line %d", i+1)
+		}
+	}
+
+	return stanzas, nil
+}
+
+// getAppPackageRules returns the app package rules applicable to the CR kind and the app source
+func getAppPackageRules(policy *enterpriseApi.AppPackagePolicySpec, kind, appSrcName string) []enterpriseApi.AppPackageRuleSpec {
+	var rules []enterpriseApi.AppPackageRuleSpec
+	for _, rule := range policy.Rules {
+		if len(rule.Kinds) > 0 && !isStringInList(kind, rule.Kinds) {
+			continue
+		}
+		if len(rule.AppSources) > 0 && !isStringInList(appSrcName, rule.AppSources) {
+			continue
+		}
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// isStringInList confirms if the string is present in the list
+func isStringInList(str string, list []string) bool {
+	for _, item := range list {
+		if item == str {
+			return true
+		}
+	}
+	return false
+}
+
+// inspectAppPackage inspects the app package(gzipped tarball) downloaded on the operator pod. It returns the top folder
+// name, the dependencies declared in the app.manifest and the violations of the app package policy
+func inspectAppPackage(ctx context.Context, appPkgLocalPath string, policy *enterpriseApi.AppPackagePolicySpec, rules []enterpriseApi.AppPackageRuleSpec) (*appPackageInfo, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("inspectAppPackage").WithValues("appPkgLocalPath", appPkgLocalPath)
+
+	f, err := os.Open(appPkgLocalPath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read app package %s. error: %v", appPkgLocalPath, err)
+	}
+	defer gzr.Close()
+
+	// conf files in the default directory, which are needed by the rules
+	confFiles := make(map[string][]byte)
+	for _, rule := range rules {
+		for _, stanza := range rule.ForbiddenStanzas {
+			confFiles[path.Join("default", stanza.ConfFile)] = nil
+		}
+	}
+	var appConf []byte
+
+	pkgInfo := &appPackageInfo{}
+	tr := tar.NewReader(gzr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read app package %s. error: %v", appPkgLocalPath, err)
+		}
+
+		if path.IsAbs(hdr.Name) || hasParentDirRef(hdr.Name) {
+			pkgInfo.addViolation("path traversal entry %s", hdr.Name)
+			continue
+		}
+
+		entry := strings.TrimPrefix(path.Clean(hdr.Name), "./")
+		if entry == "." {
+			continue
+		}
+
+		elems := strings.SplitN(entry, "/", 2)
+		if pkgInfo.topFolder == "" {
+			pkgInfo.topFolder = elems[0]
+		} else if elems[0] != pkgInfo.topFolder {
+			pkgInfo.addViolation("multiple top level entries %s, %s", pkgInfo.topFolder, elems[0])
+			continue
+		}
+
+		var relPath string
+		if len(elems) == 2 {
+			relPath = elems[1]
+		} else if hdr.Typeflag != tar.TypeDir {
+			pkgInfo.addViolation("entry %s is outside the app folder", entry)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeSymlink:
+			if path.IsAbs(hdr.Linkname) || !isPathWithinDir(path.Join(path.Dir(entry), hdr.Linkname), elems[0]) {
+				pkgInfo.addViolation("link %s points outside the app", entry)
+			}
+		case tar.TypeLink:
+			if path.IsAbs(hdr.Linkname) || !isPathWithinDir(strings.TrimPrefix(hdr.Linkname, "./"), elems[0]) {
+				pkgInfo.addViolation("link %s points outside the app", entry)
+			}
+		}
+
+		if !policy.AllowLocalDir && isPathWithinDir(relPath, "local") {
+			pkgInfo.addViolation("local directory is not allowed")
+		}
+
+		for _, rule := range rules {
+			for _, pattern := range rule.ForbiddenPaths {
+				if matched, _ := path.Match(pattern, relPath); matched {
+					pkgInfo.addViolation("rule %s: forbidden path %s", rule.Name, relPath)
+				}
+			}
+		}
+
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+
+		switch relPath {
+		case appManifestFileName:
+			var manifest appPackageManifest
+			err = json.NewDecoder(tr).Decode(&manifest)
+			if err != nil {
+				// app.manifest is optional, so do not fail the app package for a bad manifest
+				scopedLog.Error(err, "unable to parse app.manifest, ignoring the app dependencies")
+				continue
+			}
+
+			pkgInfo.dependencies = nil
+			for dependency := range manifest.Dependencies {
+				pkgInfo.dependencies = append(pkgInfo.dependencies, dependency)
+			}
+			sort.Strings(pkgInfo.dependencies)
+		case appConfFilePath:
+			appConf, err = io.ReadAll(io.LimitReader(tr, maxAppConfFileSize))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read app package %s. error: %v", appPkgLocalPath, err)
+			}
+		}
+
+		if _, ok := confFiles[relPath]; ok {
+			confFiles[relPath], err = io.ReadAll(io.LimitReader(tr, maxAppConfFileSize))
+			if err != nil {
+				return nil, fmt.Errorf("unable to read app package %s. error: %v", appPkgLocalPath, err)
+			}
+		}
+	}
+
+	if pkgInfo.topFolder == "" {
+		return nil, fmt.Errorf("empty app package %s", appPkgLocalPath)
+	}
+
+	if appConf == nil {
+		pkgInfo.addViolation("%s is missing", appConfFilePath)
+	} else if _, err = parseSplunkConf(appConf); err != nil {
+		pkgInfo.addViolation("%s is not parsable: %v", appConfFilePath, err)
+	}
+
+	for _, rule := range rules {
+		for _, forbiddenStanza := range rule.ForbiddenStanzas {
+			confFilePath := path.Join("default", forbiddenStanza.ConfFile)
+			if confFiles[confFilePath] == nil {
+				continue
+			}
+
+			stanzas, err := parseSplunkConf(confFiles[confFilePath])
+			if err != nil {
+				pkgInfo.addViolation("rule %s: %s is not parsable: %v", rule.Name, confFilePath, err)
+				continue
+			}
+
+			for _, stanza := range stanzas {
+				if strings.HasPrefix(stanza, forbiddenStanza.StanzaPrefix) {
+					pkgInfo.addViolation("rule %s: forbidden stanza [%s] in %s", rule.Name, stanza, confFilePath)
+				}
+			}
+		}
+	}
+
+	return pkgInfo, nil
+}
+
+// validateAppPackage inspects the app package downloaded on the operator pod, and updates the app deployment info
+// with the details of the app package. Returns false, if the app package violates the app package policy
+func validateAppPackage(ctx context.Context, worker *PipelineWorker, appPkgLocalPath string) bool {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("validateAppPackage").WithValues("appSrcName", worker.appSrcName, "appName", worker.appDeployInfo.AppName)
+
+	policy := &worker.afwConfig.AppPackagePolicy
+	kind := worker.cr.GetObjectKind().GroupVersionKind().Kind
+	rules := getAppPackageRules(policy, kind, worker.appSrcName)
+
+	pkgInfo, err := inspectAppPackage(ctx, appPkgLocalPath, policy, rules)
+	if err != nil {
+		if policy.Disabled {
+			// the install phase can still get the top folder from the Splunk pod
+			scopedLog.Error(err, "unable to inspect the app package")
+			return true
+		}
+		pkgInfo = &appPackageInfo{}
+		pkgInfo.addViolation("invalid app package: %v", err)
+	}
+
+	if policy.Disabled {
+		pkgInfo.violations = nil
+	}
+
+	worker.appDeployInfo.ValidationErrors = pkgInfo.violations
+	if len(pkgInfo.violations) > 0 {
+		scopedLog.Error(nil, "app package failed the validation", "violations", pkgInfo.violations)
+		eventPublisher, _ := newK8EventPublisher(worker.client, worker.cr)
+		eventPublisher.Warning(ctx, "validateAppPackage", fmt.Sprintf("app package %s from app source %s failed the validation: %s", worker.appDeployInfo.AppName, worker.appSrcName, strings.Join(pkgInfo.violations, "; ")))
+		return false
+	}
+
+	if worker.appDeployInfo.AppPackageTopFolder == "" {
+		worker.appDeployInfo.AppPackageTopFolder = pkgInfo.topFolder
+	}
+	worker.appDeployInfo.Dependencies = pkgInfo.dependencies
+
+	return true
+}
+
+// getExplodedAppEtag derives the ETag of an exploded app from the relative paths and the ETags of its objects
+func getExplodedAppEtag(dirKey string, objects []*splclient.RemoteObject) string {
+	entries := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		var etag string
+		if obj.Etag != nil {
+			etag = *obj.Etag
+		}
+		entries = append(entries, strings.TrimPrefix(*obj.Key, dirKey)+"\x00"+etag)
+	}
+	sort.Strings(entries)
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(strings.Join(entries, "\n"))))
+}
+
+// writeAppPackage writes a gzipped tarball to the given path, with the entries added by the write function. The
+// tarball is written to a temporary file first, so that a partially written app package is never left behind
+func writeAppPackage(appPkgLocalPath string, write func(tw *tar.Writer) error) error {
+	tmpPath := appPkgLocalPath + ".tmp"
+	f, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	err = write(tw)
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gzw.Close()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, appPkgLocalPath)
+}
+
+// convertZipToAppPackage converts the zip archive to a gzipped tarball
+func convertZipToAppPackage(zipPath, appPkgLocalPath string) error {
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+
+	return writeAppPackage(appPkgLocalPath, func(tw *tar.Writer) error {
+		for _, zf := range zr.File {
+			fi := zf.FileInfo()
+			hdr := &tar.Header{
+				Name:    zf.Name,
+				Mode:    int64(fi.Mode().Perm()),
+				ModTime: zf.Modified,
+			}
+
+			rc, err := zf.Open()
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case fi.IsDir():
+				hdr.Typeflag = tar.TypeDir
+			case fi.Mode()&os.ModeSymlink != 0:
+				// zip stores the link target as the content of the entry
+				target, err := io.ReadAll(io.LimitReader(rc, 4096))
+				if err != nil {
+					rc.Close()
+					return err
+				}
+				hdr.Typeflag = tar.TypeSymlink
+				hdr.Linkname = string(target)
+			default:
+				hdr.Typeflag = tar.TypeReg
+				hdr.Size = int64(zf.UncompressedSize64)
+			}
+
+			err = tw.WriteHeader(hdr)
+			if err == nil && hdr.Typeflag == tar.TypeReg {
+				_, err = io.Copy(tw, rc)
+			}
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// convertTarToAppPackage compresses the tarball
+func convertTarToAppPackage(tarPath, appPkgLocalPath string) error {
+	tf, err := os.Open(tarPath)
+	if err != nil {
+		return err
+	}
+	defer tf.Close()
+
+	return writeAppPackage(appPkgLocalPath, func(tw *tar.Writer) error {
+		tr := tar.NewReader(tf)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			err = tw.WriteHeader(hdr)
+			if err != nil {
+				return err
+			}
+			_, err = io.Copy(tw, tr)
+			if err != nil {
+				return err
+			}
+		}
+	})
+}
+
+// convertAppPackage converts the app package downloaded on the operator pod to a gzipped tarball, so that the rest of
+// the App Framework handles all the app package formats the same way. The app package keeps its name, and so the ETag
+func convertAppPackage(ctx context.Context, appPkgLocalPath, appName string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("convertAppPackage").WithValues("appPkgLocalPath", appPkgLocalPath, "appName", appName)
+
+	var err error
+	switch getAppPackageExtension(appName) {
+	case ".zip":
+		err = convertZipToAppPackage(appPkgLocalPath, appPkgLocalPath)
+	case ".tar":
+		err = convertTarToAppPackage(appPkgLocalPath, appPkgLocalPath)
+	default:
+		// already a gzipped tarball
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("unable to convert app package %s. error: %v", appName, err)
+	}
+
+	scopedLog.Info("Converted the app package to a gzipped tarball")
+	return nil
+}
+
+// downloadExplodedApp downloads all the objects of an exploded app directory, and packages them as a gzipped tarball
+// with the directory name as the top folder
+func downloadExplodedApp(ctx context.Context, remoteDataClientMgr RemoteDataClientManager, remoteDir, appPkgLocalPath, etag string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("downloadExplodedApp").WithValues("remoteDir", remoteDir, "appPkgLocalPath", appPkgLocalPath)
+
+	// list only the objects under the app directory, instead of the whole app source
+	topFolder := path.Base(remoteDir)
+	appDirClientMgr := remoteDataClientMgr
+	appDirClientMgr.location = path.Join(remoteDataClientMgr.location, topFolder)
+	remoteDataListResponse, err := appDirClientMgr.GetAppsList(ctx)
+	if err != nil {
+		return err
+	}
+
+	var objects []*splclient.RemoteObject
+	for _, obj := range remoteDataListResponse.Objects {
+		if strings.HasPrefix(*obj.Key, remoteDir) && !strings.HasSuffix(*obj.Key, "/") {
+			objects = append(objects, obj)
+		}
+	}
+	sort.Slice(objects, func(i, j int) bool { return *objects[i].Key < *objects[j].Key })
+
+	// the app is downloaded only if it is not modified after the listing
+	if len(objects) == 0 || getExplodedAppEtag(remoteDir, objects) != etag {
+		return fmt.Errorf("exploded app %s is modified on the remote storage", remoteDir)
+	}
+
+	objLocalPath := appPkgLocalPath + ".obj"
+	defer os.Remove(objLocalPath)
+
+	return writeAppPackage(appPkgLocalPath, func(tw *tar.Writer) error {
+		err := tw.WriteHeader(&tar.Header{Name: topFolder + "/", Typeflag: tar.TypeDir, Mode: 0755, ModTime: time.Now()})
+		if err != nil {
+			return err
+		}
+
+		for _, obj := range objects {
+			relPath := strings.TrimPrefix(*obj.Key, remoteDir)
+			if hasParentDirRef(relPath) {
+				return fmt.Errorf("invalid object %s in the exploded app", *obj.Key)
+			}
+
+			var objEtag string
+			if obj.Etag != nil {
+				objEtag = *obj.Etag
+			}
+			err = remoteDataClientMgr.DownloadApp(ctx, *obj.Key, objLocalPath, objEtag)
+			if err != nil {
+				return err
+			}
+
+			err = addFileToAppPackage(tw, objLocalPath, path.Join(topFolder, relPath))
+			if err != nil {
+				return err
+			}
+		}
+
+		scopedLog.Info("Packaged the exploded app", "objects", len(objects))
+		return nil
+	})
+}
+
+// addFileToAppPackage adds the local file to the tarball with the given name
+func addFileToAppPackage(tw *tar.Writer, localPath, name string) error {
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	err = tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: fi.Size(), ModTime: fi.ModTime()})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tw, f)
+	return err
+}
@@ -0,0 +1,493 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// createTestAppPackage creates a gzipped tarball with the given files, in the same order
+func createTestAppPackage(t *testing.T, pkgPath string, files [][2]string) {
+	f, err := os.Create(pkgPath)
+	if err != nil {
+		t.Fatalf("unable to create app package %s. error: %v", pkgPath, err)
+	}
+	defer f.Close()
+
+	gzw := gzip.NewWriter(f)
+	defer gzw.Close()
+	tw := tar.NewWriter(gzw)
+	defer tw.Close()
+
+	for _, file := range files {
+		hdr := &tar.Header{Name: file[0], Mode: 0644, Size: int64(len(file[1])), Typeflag: tar.TypeReg}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("unable to write tar header. error: %v", err)
+		}
+		if _, err := tw.Write([]byte(file[1])); err != nil {
+			t.Fatalf("unable to write tar entry. error: %v", err)
+		}
+	}
+}
+
+func TestParseSplunkConf(t *testing.T) {
+	stanzas, err := parseSplunkConf([]byte("\ufeff# comment\nglobal = 1\n[install]\nis_configured = 0\n\n[ui]\nlabel = My \\\n  App\n[script://./bin/run.sh]\n"))
+	if err != nil {
+		t.Errorf("unable to parse a valid conf. error: %v", err)
+	}
+	if !reflect.DeepEqual(stanzas, []string{"install", "ui", "script://./bin/run.sh"}) {
+		t.Errorf("Got wrong stanzas %v", stanzas)
+	}
+
+	_, err = parseSplunkConf([]byte("[install\nis_configured = 0\n"))
+	if err == nil {
+		t.Errorf("Expected an error for an invalid stanza")
+	}
+
+	_, err = parseSplunkConf([]byte("[install]\n= 0\n"))
+	if err == nil {
+		t.Errorf("Expected an error for a missing key")
+	}
+
+	_, err = parseSplunkConf([]byte("[install]\nis_configured\n"))
+	if err == nil {
+		t.Errorf("Expected an error for an invalid entry")
+	}
+}
+
+func TestGetAppPackageRules(t *testing.T) {
+	policy := &enterpriseApi.AppPackagePolicySpec{
+		Rules: []enterpriseApi.AppPackageRuleSpec{
+			{Name: "all"},
+			{Name: "shc", Kinds: []string{"SearchHeadCluster"}},
+			{Name: "securityApps", AppSources: []string{"securityApps"}},
+		},
+	}
+
+	var names []string
+	for _, rule := range getAppPackageRules(policy, "SearchHeadCluster", "adminApps") {
+		names = append(names, rule.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"all", "shc"}) {
+		t.Errorf("Got wrong rules %v", names)
+	}
+
+	names = nil
+	for _, rule := range getAppPackageRules(policy, "Standalone", "securityApps") {
+		names = append(names, rule.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"all", "securityApps"}) {
+		t.Errorf("Got wrong rules %v", names)
+	}
+}
+
+func TestInspectAppPackage(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	policy := &enterpriseApi.AppPackagePolicySpec{}
+
+	// valid app package with dependencies in app.manifest
+	pkgPath := filepath.Join(dir, "app1.tgz")
+	createTestAppPackage(t, pkgPath, [][2]string{
+		{"./app1/default/app.conf", "[launcher]\nversion = 1.0.0\n"},
+		{"./app1/app.manifest", `{"info": {"id": {"name": "app1"}}, "dependencies": {"Splunk_SA_CIM": {"version": ">=4.0.0"}, "Splunk_TA_base": null}}`},
+		{"./app1/bin/app.manifest", `{"dependencies": {"ignored": null}}`},
+	})
+
+	pkgInfo, err := inspectAppPackage(ctx, pkgPath, policy, nil)
+	if err != nil {
+		t.Errorf("unable to inspect app package. error: %v", err)
+	}
+	if pkgInfo.topFolder != "app1" || len(pkgInfo.violations) != 0 {
+		t.Errorf("Got wrong app package info %v", pkgInfo)
+	}
+	if !reflect.DeepEqual(pkgInfo.dependencies, []string{"Splunk_SA_CIM", "Splunk_TA_base"}) {
+		t.Errorf("Got wrong dependencies %v", pkgInfo.dependencies)
+	}
+
+	// malformed app.manifest is ignored, but missing app.conf is a violation
+	pkgPath = filepath.Join(dir, "app2.spl")
+	createTestAppPackage(t, pkgPath, [][2]string{
+		{"app2/app.manifest", "{"},
+	})
+
+	pkgInfo, err = inspectAppPackage(ctx, pkgPath, policy, nil)
+	if err != nil || pkgInfo.topFolder != "app2" || len(pkgInfo.dependencies) != 0 {
+		t.Errorf("malformed app.manifest should be ignored. pkgInfo: %v, error: %v", pkgInfo, err)
+	}
+	if !reflect.DeepEqual(pkgInfo.violations, []string{"default/app.conf is missing"}) {
+		t.Errorf("Got wrong violations %v", pkgInfo.violations)
+	}
+
+	// policy violations
+	pkgPath = filepath.Join(dir, "app3.tgz")
+	createTestAppPackage(t, pkgPath, [][2]string{
+		{"app3/default/app.conf", "[launcher\n"},
+		{"../etc/passwd", "root"},
+		{"app4/default/app.conf", "[launcher]\n"},
+		{"README", "readme"},
+		{"app3/local/inputs.conf", "[monitor:///var/log]\n"},
+		{"app3/local/app.conf", "[install]\n"},
+	})
+
+	pkgInfo, err = inspectAppPackage(ctx, pkgPath, policy, nil)
+	if err != nil {
+		t.Errorf("unable to inspect app package. error: %v", err)
+	}
+	expected := []string{
+		"path traversal entry ../etc/passwd",
+		"multiple top level entries app3, app4",
+		"multiple top level entries app3, README",
+		"local directory is not allowed",
+		"default/app.conf is not parsable: invalid stanza at line 1",
+	}
+	if !reflect.DeepEqual(pkgInfo.violations, expected) {
+		t.Errorf("Got wrong violations %v", pkgInfo.violations)
+	}
+
+	// local directory can be allowed
+	policy.AllowLocalDir = true
+	pkgInfo, _ = inspectAppPackage(ctx, pkgPath, policy, nil)
+	for _, violation := range pkgInfo.violations {
+		if violation == "local directory is not allowed" {
+			t.Errorf("local directory should be allowed")
+		}
+	}
+
+	// file as the top level entry
+	pkgPath = filepath.Join(dir, "app4.tgz")
+	createTestAppPackage(t, pkgPath, [][2]string{
+		{"app.conf", "[launcher]\n"},
+	})
+	pkgInfo, _ = inspectAppPackage(ctx, pkgPath, policy, nil)
+	if !reflect.DeepEqual(pkgInfo.violations, []string{"entry app.conf is outside the app folder", "default/app.conf is missing"}) {
+		t.Errorf("Got wrong violations %v", pkgInfo.violations)
+	}
+
+	// links pointing outside the app
+	pkgPath = filepath.Join(dir, "app5.tgz")
+	f, _ := os.Create(pkgPath)
+	gzw := gzip.NewWriter(f)
+	tw := tar.NewWriter(gzw)
+	tw.WriteHeader(&tar.Header{Name: "app5/", Typeflag: tar.TypeDir, Mode: 0755})
+	tw.WriteHeader(&tar.Header{Name: "app5/default/app.conf", Typeflag: tar.TypeReg, Mode: 0644})
+	tw.WriteHeader(&tar.Header{Name: "app5/bin/ok", Typeflag: tar.TypeSymlink, Linkname: "../default/app.conf"})
+	tw.WriteHeader(&tar.Header{Name: "app5/bin/passwd", Typeflag: tar.TypeSymlink, Linkname: "/etc/passwd"})
+	tw.WriteHeader(&tar.Header{Name: "app5/bin/up", Typeflag: tar.TypeSymlink, Linkname: "../../other"})
+	tw.WriteHeader(&tar.Header{Name: "app5/bin/hard", Typeflag: tar.TypeLink, Linkname: "other/file"})
+	tw.Close()
+	gzw.Close()
+	f.Close()
+
+	pkgInfo, err = inspectAppPackage(ctx, pkgPath, policy, nil)
+	if err != nil {
+		t.Errorf("unable to inspect app package. error: %v", err)
+	}
+	expected = []string{
+		"link app5/bin/passwd points outside the app",
+		"link app5/bin/up points outside the app",
+		"link app5/bin/hard points outside the app",
+	}
+	if !reflect.DeepEqual(pkgInfo.violations, expected) {
+		t.Errorf("Got wrong violations %v", pkgInfo.violations)
+	}
+
+	// rules for forbidden paths and stanzas
+	pkgPath = filepath.Join(dir, "app6.tgz")
+	createTestAppPackage(t, pkgPath, [][2]string{
+		{"app6/default/app.conf", "[launcher]\n"},
+		{"app6/default/inputs.conf", "[monitor:///var/log]\n[script://./bin/run.sh]\ninterval = 60\n"},
+		{"app6/bin/run.exe", "binary"},
+	})
+	rules := []enterpriseApi.AppPackageRuleSpec{
+		{Name: "no-scripted-inputs", ForbiddenStanzas: []enterpriseApi.AppConfStanzaSpec{{ConfFile: "inputs.conf", StanzaPrefix: "script://"}}},
+		{Name: "no-binaries", ForbiddenPaths: []string{"bin/*.exe"}},
+		{Name: "no-props", ForbiddenStanzas: []enterpriseApi.AppConfStanzaSpec{{ConfFile: "props.conf"}}},
+	}
+
+	pkgInfo, err = inspectAppPackage(ctx, pkgPath, policy, rules)
+	if err != nil {
+		t.Errorf("unable to inspect app package. error: %v", err)
+	}
+	expected = []string{
+		"rule no-binaries: forbidden path bin/run.exe",
+		"rule no-scripted-inputs: forbidden stanza [script://./bin/run.sh] in default/inputs.conf",
+	}
+	if !reflect.DeepEqual(pkgInfo.violations, expected) {
+		t.Errorf("Got wrong violations %v", pkgInfo.violations)
+	}
+
+	// not a gzipped tarball
+	pkgPath = filepath.Join(dir, "app7.tgz")
+	err = os.WriteFile(pkgPath, []byte("not an app"), 0644)
+	if err != nil {
+		t.Errorf("unable to create file. error: %v", err)
+	}
+	_, err = inspectAppPackage(ctx, pkgPath, policy, nil)
+	if err == nil {
+		t.Errorf("Expected an error for an invalid app package")
+	}
+
+	// missing app package
+	_, err = inspectAppPackage(ctx, filepath.Join(dir, "app8.tgz"), policy, nil)
+	if err == nil {
+		t.Errorf("Expected an error for a missing app package")
+	}
+}
+
+func TestValidateAppPackage(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	pkgPath := filepath.Join(dir, "app1.tgz")
+	createTestAppPackage(t, pkgPath, [][2]string{
+		{"app1/default/app.conf", "[launcher]\n"},
+		{"app1/app.manifest", `{"dependencies": {"Splunk_SA_CIM": null}}`},
+	})
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	worker := &PipelineWorker{
+		appSrcName:    "appSrc1",
+		appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"},
+		afwConfig:     &enterpriseApi.AppFrameworkSpec{},
+		cr:            &cr,
+	}
+
+	if !validateAppPackage(ctx, worker, pkgPath) {
+		t.Errorf("valid app package should pass the validation. errors: %v", worker.appDeployInfo.ValidationErrors)
+	}
+	if worker.appDeployInfo.AppPackageTopFolder != "app1" || !reflect.DeepEqual(worker.appDeployInfo.Dependencies, []string{"Splunk_SA_CIM"}) {
+		t.Errorf("app package info not updated. appDeployInfo: %v", worker.appDeployInfo)
+	}
+
+	// existing top folder should not be overwritten
+	worker.appDeployInfo.AppPackageTopFolder = "installedApp1"
+	validateAppPackage(ctx, worker, pkgPath)
+	if worker.appDeployInfo.AppPackageTopFolder != "installedApp1" {
+		t.Errorf("top folder should not be overwritten. Got %s", worker.appDeployInfo.AppPackageTopFolder)
+	}
+
+	// rule violation
+	worker.afwConfig.AppPackagePolicy.Rules = []enterpriseApi.AppPackageRuleSpec{
+		{Name: "no-manifest", Kinds: []string{"Standalone"}, ForbiddenPaths: []string{"app.manifest"}},
+	}
+	if validateAppPackage(ctx, worker, pkgPath) {
+		t.Errorf("app package should fail the validation")
+	}
+	if !reflect.DeepEqual(worker.appDeployInfo.ValidationErrors, []string{"rule no-manifest: forbidden path app.manifest"}) {
+		t.Errorf("Got wrong validation errors %v", worker.appDeployInfo.ValidationErrors)
+	}
+
+	// invalid app package
+	if validateAppPackage(ctx, worker, filepath.Join(dir, "missing.tgz")) {
+		t.Errorf("missing app package should fail the validation")
+	}
+
+	// validation disabled
+	worker.afwConfig.AppPackagePolicy.Disabled = true
+	if !validateAppPackage(ctx, worker, pkgPath) || len(worker.appDeployInfo.ValidationErrors) != 0 {
+		t.Errorf("app package should pass, when the validation is disabled")
+	}
+	if !validateAppPackage(ctx, worker, filepath.Join(dir, "missing.tgz")) {
+		t.Errorf("app package should pass, when the validation is disabled")
+	}
+}
+
+func TestConvertAppPackage(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+	policy := &enterpriseApi.AppPackagePolicySpec{}
+
+	// zip app package
+	zipPath := filepath.Join(dir, "app1.zip_abcd")
+	f, err := os.Create(zipPath)
+	if err != nil {
+		t.Fatalf("unable to create zip file. error: %v", err)
+	}
+	zw := zip.NewWriter(f)
+	for _, file := range [][2]string{{"app1/", ""}, {"app1/default/app.conf", "[launcher]\nversion = 1.0.0\n"}, {"app1/bin/run.sh", "echo"}} {
+		w, err := zw.Create(file[0])
+		if err != nil {
+			t.Fatalf("unable to create zip entry. error: %v", err)
+		}
+		w.Write([]byte(file[1]))
+	}
+	zw.Close()
+	f.Close()
+
+	err = convertAppPackage(ctx, zipPath, "app1.zip")
+	if err != nil {
+		t.Errorf("unable to convert zip app package. error: %v", err)
+	}
+	pkgInfo, err := inspectAppPackage(ctx, zipPath, policy, nil)
+	if err != nil || pkgInfo.topFolder != "app1" || len(pkgInfo.violations) != 0 {
+		t.Errorf("Got wrong app package info %v for the converted zip. error: %v", pkgInfo, err)
+	}
+
+	// tar app package
+	tgzPath := filepath.Join(dir, "app2.tgz")
+	createTestAppPackage(t, tgzPath, [][2]string{{"app2/default/app.conf", "[install]\nstate = enabled\n"}})
+	tarPath := filepath.Join(dir, "app2.tar_abcd")
+	src, _ := os.Open(tgzPath)
+	gzr, _ := gzip.NewReader(src)
+	dst, _ := os.Create(tarPath)
+	_, err = dst.ReadFrom(gzr)
+	if err != nil {
+		t.Fatalf("unable to create tar file. error: %v", err)
+	}
+	dst.Close()
+	src.Close()
+
+	err = convertAppPackage(ctx, tarPath, "app2.tar")
+	if err != nil {
+		t.Errorf("unable to convert tar app package. error: %v", err)
+	}
+	pkgInfo, err = inspectAppPackage(ctx, tarPath, policy, nil)
+	if err != nil || pkgInfo.topFolder != "app2" || len(pkgInfo.violations) != 0 {
+		t.Errorf("Got wrong app package info %v for the converted tar. error: %v", pkgInfo, err)
+	}
+
+	// gzipped tarballs are left as is
+	before, _ := os.ReadFile(tgzPath)
+	err = convertAppPackage(ctx, tgzPath, "app2.tar.gz")
+	after, _ := os.ReadFile(tgzPath)
+	if err != nil || !reflect.DeepEqual(before, after) {
+		t.Errorf("gzipped tarball should not be converted. error: %v", err)
+	}
+
+	// invalid zip
+	badPath := filepath.Join(dir, "app3.zip_abcd")
+	os.WriteFile(badPath, []byte("not a zip"), 0644)
+	err = convertAppPackage(ctx, badPath, "app3.zip")
+	if err == nil {
+		t.Errorf("Expected an error for the invalid zip app package")
+	}
+	if _, err = os.Stat(badPath + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary app package should be removed")
+	}
+}
+
+// testRemoteDataClient serves the remote objects from memory
+type testRemoteDataClient struct {
+	objects map[string]string
+}
+
+func (c *testRemoteDataClient) GetAppsList(ctx context.Context) (splclient.RemoteDataListResponse, error) {
+	var resp splclient.RemoteDataListResponse
+	for key, content := range c.objects {
+		key, etag := key, fmt.Sprintf("\"%x\"", len(content))
+		resp.Objects = append(resp.Objects, &splclient.RemoteObject{Key: &key, Etag: &etag})
+	}
+	return resp, nil
+}
+
+func (c *testRemoteDataClient) DownloadApp(ctx context.Context, req splclient.RemoteDataDownloadRequest) (bool, error) {
+	content, ok := c.objects[req.RemoteFile]
+	if !ok {
+		return false, fmt.Errorf("object %s not found", req.RemoteFile)
+	}
+	return true, os.WriteFile(req.LocalFile, []byte(content), 0644)
+}
+
+func (c *testRemoteDataClient) UploadFile(ctx context.Context, req splclient.RemoteDataUploadRequest) error {
+	content, err := os.ReadFile(req.LocalFile)
+	if err != nil {
+		return err
+	}
+	c.objects[req.RemoteFile] = string(content)
+	return nil
+}
+
+func (c *testRemoteDataClient) DeleteFile(ctx context.Context, remoteFile string) error {
+	delete(c.objects, remoteFile)
+	return nil
+}
+
+func TestDownloadExplodedApp(t *testing.T) {
+	ctx := context.TODO()
+	dir := t.TempDir()
+
+	remoteClient := &testRemoteDataClient{
+		objects: map[string]string{
+			"apps/app1/default/app.conf": "[launcher]\nversion = 1.0.0\n",
+			"apps/app1/bin/run.sh":       "echo",
+			"apps/app2.tgz":              "tgz",
+		},
+	}
+	var listedLocations []string
+	remoteDataClientMgr := RemoteDataClientManager{
+		location: "apps",
+		getRemoteDataClient: func(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appFrameworkRef *enterpriseApi.AppFrameworkSpec, vol *enterpriseApi.VolumeSpec, location string, fn splclient.GetInitFunc) (splclient.SplunkRemoteDataClient, error) {
+			listedLocations = append(listedLocations, location)
+			return splclient.SplunkRemoteDataClient{Client: remoteClient}, nil
+		},
+	}
+
+	listing, _ := remoteClient.GetAppsList(ctx)
+	packages := getAppPackagesFromRemoteListing("apps/", listing.Objects)
+	var etag string
+	for _, pkg := range packages {
+		if *pkg.Key == "apps/app1/" {
+			etag = *pkg.Etag
+		}
+	}
+	if etag == "" {
+		t.Fatalf("exploded app is not listed")
+	}
+
+	pkgPath := filepath.Join(dir, "app1_"+etag)
+	err := downloadExplodedApp(ctx, remoteDataClientMgr, "apps/app1/", pkgPath, etag)
+	if err != nil {
+		t.Fatalf("unable to download exploded app. error: %v", err)
+	}
+	pkgInfo, err := inspectAppPackage(ctx, pkgPath, &enterpriseApi.AppPackagePolicySpec{}, nil)
+	if err != nil || pkgInfo.topFolder != "app1" || len(pkgInfo.violations) != 0 {
+		t.Errorf("Got wrong app package info %v for the exploded app. error: %v", pkgInfo, err)
+	}
+
+	// only the app directory is listed
+	if len(listedLocations) == 0 || listedLocations[0] != "apps/app1" {
+		t.Errorf("Expected the listing of the app directory apps/app1, got %v", listedLocations)
+	}
+
+	// modified after the listing
+	remoteClient.objects["apps/app1/bin/run.sh"] = "echo modified"
+	err = downloadExplodedApp(ctx, remoteDataClientMgr, "apps/app1/", pkgPath, etag)
+	if err == nil {
+		t.Errorf("Expected an error for the exploded app modified after the listing")
+	}
+}
@@ -0,0 +1,303 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+// indexing pipeline queue used for the indexer load metrics
+const indexerLoadQueue = "indexqueue"
+
+var indexerPeerBucketCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_indexer_peer_bucket_count",
+	Help: "The number of buckets on the indexer cluster peer, across all indexes",
+}, []string{"namespace", "name", "peer"})
+
+var indexerPeerPrimaryCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_indexer_peer_primary_count",
+	Help: "The number of buckets for which the indexer cluster peer is primary",
+}, []string{"namespace", "name", "peer"})
+
+var indexerPeerQueueFillRatio = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_indexer_peer_indexing_queue_fill_ratio",
+	Help: "The fill ratio of the index queue of the indexer cluster peer, between 0 and 1",
+}, []string{"namespace", "name", "peer"})
+
+var indexerPeerIngestionKBps = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_indexer_peer_ingestion_kbps",
+	Help: "The average indexing throughput of the indexer cluster peer, in KB per second",
+}, []string{"namespace", "name", "peer"})
+
+var searchHeadMemberHistoricalSearches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_search_head_member_active_historical_searches",
+	Help: "The number of historical searches running on the search head cluster member",
+}, []string{"namespace", "name", "member"})
+
+var searchHeadMemberRealtimeSearches = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "splunk_operator_search_head_member_active_realtime_searches",
+	Help: "The number of realtime searches running on the search head cluster member",
+}, []string{"namespace", "name", "member"})
+
+func init() {
+	metrics.Registry.MustRegister(
+		indexerPeerBucketCount,
+		indexerPeerPrimaryCount,
+		indexerPeerQueueFillRatio,
+		indexerPeerIngestionKBps,
+		searchHeadMemberHistoricalSearches,
+		searchHeadMemberRealtimeSearches,
+	)
+}
+
+// indexerPeerLoad is the indexing load of an indexer cluster peer
+type indexerPeerLoad struct {
+	queueFillRatio float64
+	ingestionKBps  float64
+}
+
+// GetIndexerPeerLoadCall returns the indexing load of an indexer cluster peer
+var GetIndexerPeerLoadCall = func(ctx context.Context, mgr *indexerClusterPodManager, n int32) (*indexerPeerLoad, error) {
+	c := mgr.getClient(ctx, n)
+	queue, err := c.GetIndexerQueueInfo(indexerLoadQueue)
+	if err != nil {
+		return nil, err
+	}
+	throughput, err := c.GetIndexerThroughputInfo()
+	if err != nil {
+		return nil, err
+	}
+	return &indexerPeerLoad{
+		queueFillRatio: queue.FillRatio(),
+		ingestionKBps:  throughput.AverageKBps,
+	}, nil
+}
+
+// crMetricLabels returns the labels matching all the metrics of a CR
+func crMetricLabels(namespace, name string) prometheus.Labels {
+	return prometheus.Labels{"namespace": namespace, "name": name}
+}
+
+// deleteRemovedInstanceMetrics removes the series of the instances of a CR which are not reported anymore, e.g. the
+// peers removed by a scale down, while the series of the reported instances are updated in place
+func deleteRemovedInstanceMetrics(vec *prometheus.GaugeVec, namespace, name, instanceLabel string, reported map[string]bool) {
+	ch := make(chan prometheus.Metric)
+	go func() {
+		vec.Collect(ch)
+		close(ch)
+	}()
+
+	var removed []string
+	for metric := range ch {
+		m := &dto.Metric{}
+		if metric.Write(m) != nil {
+			continue
+		}
+		labels := make(map[string]string)
+		for _, label := range m.GetLabel() {
+			labels[label.GetName()] = label.GetValue()
+		}
+		if labels["namespace"] == namespace && labels["name"] == name && !reported[labels[instanceLabel]] {
+			removed = append(removed, labels[instanceLabel])
+		}
+	}
+
+	for _, instance := range removed {
+		vec.DeleteLabelValues(namespace, name, instance)
+	}
+}
+
+// deleteIndexerClusterMetrics removes the load metrics of an indexer cluster
+func deleteIndexerClusterMetrics(cr *enterpriseApi.IndexerCluster) {
+	labels := crMetricLabels(cr.GetNamespace(), cr.GetName())
+	indexerPeerBucketCount.DeletePartialMatch(labels)
+	indexerPeerPrimaryCount.DeletePartialMatch(labels)
+	indexerPeerQueueFillRatio.DeletePartialMatch(labels)
+	indexerPeerIngestionKBps.DeletePartialMatch(labels)
+}
+
+// deleteSearchHeadClusterMetrics removes the load metrics of a search head cluster
+func deleteSearchHeadClusterMetrics(cr *enterpriseApi.SearchHeadCluster) {
+	labels := crMetricLabels(cr.GetNamespace(), cr.GetName())
+	searchHeadMemberHistoricalSearches.DeletePartialMatch(labels)
+	searchHeadMemberRealtimeSearches.DeletePartialMatch(labels)
+}
+
+// updateAutoscalingMetrics exports the load metrics of the indexer cluster peers, if enabled
+func (mgr *indexerClusterPodManager) updateAutoscalingMetrics(ctx context.Context, peers map[string]splclient.ClusterManagerPeerInfo) {
+	if !mgr.cr.Spec.Autoscaling.MetricsEnabled {
+		deleteIndexerClusterMetrics(mgr.cr)
+		return
+	}
+
+	namespace := mgr.cr.GetNamespace()
+	name := mgr.cr.GetName()
+	bucketPeers := make(map[string]bool)
+	loadPeers := make(map[string]bool)
+	for n, peerStatus := range mgr.cr.Status.Peers {
+		peerInfo, ok := peers[peerStatus.Name]
+		if !ok {
+			continue
+		}
+		indexerPeerBucketCount.WithLabelValues(namespace, name, peerStatus.Name).Set(float64(peerInfo.BucketCount))
+		indexerPeerPrimaryCount.WithLabelValues(namespace, name, peerStatus.Name).Set(float64(peerInfo.PrimaryCount))
+		bucketPeers[peerStatus.Name] = true
+
+		if peerStatus.Status != "Up" {
+			continue
+		}
+		load, err := GetIndexerPeerLoadCall(ctx, mgr, int32(n))
+		if err != nil {
+			mgr.log.Error(err, "Unable to get the indexing load of the peer", "peerName", peerStatus.Name)
+			continue
+		}
+		indexerPeerQueueFillRatio.WithLabelValues(namespace, name, peerStatus.Name).Set(load.queueFillRatio)
+		indexerPeerIngestionKBps.WithLabelValues(namespace, name, peerStatus.Name).Set(load.ingestionKBps)
+		loadPeers[peerStatus.Name] = true
+	}
+
+	deleteRemovedInstanceMetrics(indexerPeerBucketCount, namespace, name, "peer", bucketPeers)
+	deleteRemovedInstanceMetrics(indexerPeerPrimaryCount, namespace, name, "peer", bucketPeers)
+	deleteRemovedInstanceMetrics(indexerPeerQueueFillRatio, namespace, name, "peer", loadPeers)
+	deleteRemovedInstanceMetrics(indexerPeerIngestionKBps, namespace, name, "peer", loadPeers)
+}
+
+// updateAutoscalingMetrics exports the search load of the search head cluster members, if enabled
+func (mgr *searchHeadClusterPodManager) updateAutoscalingMetrics() {
+	if !mgr.cr.Spec.Autoscaling.MetricsEnabled {
+		deleteSearchHeadClusterMetrics(mgr.cr)
+		return
+	}
+
+	namespace := mgr.cr.GetNamespace()
+	name := mgr.cr.GetName()
+	members := make(map[string]bool)
+	for _, memberStatus := range mgr.cr.Status.Members {
+		if !memberStatus.Registered {
+			continue
+		}
+		searchHeadMemberHistoricalSearches.WithLabelValues(namespace, name, memberStatus.Name).Set(float64(memberStatus.ActiveHistoricalSearchCount))
+		searchHeadMemberRealtimeSearches.WithLabelValues(namespace, name, memberStatus.Name).Set(float64(memberStatus.ActiveRealtimeSearchCount))
+		members[memberStatus.Name] = true
+	}
+
+	deleteRemovedInstanceMetrics(searchHeadMemberHistoricalSearches, namespace, name, "member", members)
+	deleteRemovedInstanceMetrics(searchHeadMemberRealtimeSearches, namespace, name, "member", members)
+}
+
+// isAutoscalingEnabled checks if the autoscaling spec is set
+func isAutoscalingEnabled(spec *enterpriseApi.AutoscalingSpec) bool {
+	return spec.MetricsEnabled || spec.ScaleCooldown != nil
+}
+
+// getGuardedReplicas returns the replicas to scale to. A new replicas change is held while the cluster is busy, i.e.
+// during a bundle push or an update, while a scaling with a decommission in flight is in progress, or within the
+// scale cooldown. Held changes are applied once the cluster is not busy anymore. The replicas changes are not
+// guarded without an autoscaling spec
+func getGuardedReplicas(spec *enterpriseApi.AutoscalingSpec, status *enterpriseApi.AutoscalingStatus, replicas int32, desiredReplicas int32, busy string, decommissioning string) int32 {
+	if !isAutoscalingEnabled(spec) {
+		*status = enterpriseApi.AutoscalingStatus{}
+		return desiredReplicas
+	}
+
+	// scaling is complete
+	if status.TargetReplicas == replicas {
+		status.TargetReplicas = 0
+	}
+
+	currentReplicas := replicas
+	if status.TargetReplicas != 0 {
+		currentReplicas = status.TargetReplicas
+	}
+
+	// no new replicas change
+	if desiredReplicas == currentReplicas {
+		status.HeldReplicas = 0
+		status.Message = ""
+		return desiredReplicas
+	}
+
+	reason := busy
+	if reason == "" && status.TargetReplicas != 0 {
+		reason = decommissioning
+	}
+	now := time.Now().Unix()
+	if reason == "" && spec.ScaleCooldown != nil && now-status.LastScaleTime < int64(spec.ScaleCooldown.Seconds()) {
+		reason = fmt.Sprintf("scale cooldown of %s since the last replicas change", spec.ScaleCooldown.Duration)
+	}
+	if reason != "" {
+		status.HeldReplicas = desiredReplicas
+		status.Message = fmt.Sprintf("replicas change to %d is held: %s", desiredReplicas, reason)
+		return currentReplicas
+	}
+
+	status.TargetReplicas = desiredReplicas
+	status.LastScaleTime = now
+	status.HeldReplicas = 0
+	status.Message = ""
+	return desiredReplicas
+}
+
+// getIndexerClusterBusyReason returns why a replicas change of the indexer cluster should be held, if any
+func (mgr *indexerClusterPodManager) getIndexerClusterBusyReason(clusterInfo *splclient.ClusterManagerInfo) string {
+	if clusterInfo.RollingRestart || clusterInfo.ActiveBundle.Checksum != clusterInfo.LatestBundle.Checksum {
+		return "bundle push is in progress on the cluster manager"
+	}
+	if mgr.cr.Status.UpdateStatus.Phase == enterpriseApi.UpdatePhaseInProgress {
+		return "peer update is in progress"
+	}
+	return ""
+}
+
+// getIndexerClusterDecommissionReason returns the peer decommission in flight, if any
+func getIndexerClusterDecommissionReason(cr *enterpriseApi.IndexerCluster) string {
+	if cr.Status.DecommissionStatus.Peer != "" {
+		return fmt.Sprintf("decommission of %s is in progress", cr.Status.DecommissionStatus.Peer)
+	}
+	for _, peer := range cr.Status.Peers {
+		if peer.Status == "Decommissioning" || peer.Status == "ReassigningPrimaries" {
+			return fmt.Sprintf("decommission of %s is in progress", peer.Name)
+		}
+	}
+	return ""
+}
+
+// getSearchHeadClusterBusyReason returns why a replicas change of the search head cluster should be held, if any
+func getSearchHeadClusterBusyReason(cr *enterpriseApi.SearchHeadCluster) string {
+	switch cr.Status.AppContext.BundlePushStatus.BundlePushStage {
+	case enterpriseApi.BundlePushPending, enterpriseApi.BundlePushInProgress:
+		return "bundle push is in progress on the deployer"
+	}
+	return ""
+}
+
+// getSearchHeadClusterDetentionReason returns the member removal in flight, if any
+func getSearchHeadClusterDetentionReason(cr *enterpriseApi.SearchHeadCluster) string {
+	for _, member := range cr.Status.Members {
+		if member.Status == "ManualDetention" {
+			return fmt.Sprintf("removal of %s is in progress", member.Name)
+		}
+	}
+	return ""
+}
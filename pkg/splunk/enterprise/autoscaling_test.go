@@ -0,0 +1,216 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetGuardedReplicas(t *testing.T) {
+	spec := &enterpriseApi.AutoscalingSpec{}
+	status := &enterpriseApi.AutoscalingStatus{HeldReplicas: 5}
+
+	// replicas changes are not guarded without an autoscaling spec
+	if getGuardedReplicas(spec, status, 3, 5, "bundle push is in progress", "") != 5 || *status != (enterpriseApi.AutoscalingStatus{}) {
+		t.Errorf("replicas change should not be held without an autoscaling spec. status: %v", status)
+	}
+
+	spec.MetricsEnabled = true
+
+	// no replicas change
+	if getGuardedReplicas(spec, status, 3, 3, "", "") != 3 || status.TargetReplicas != 0 {
+		t.Errorf("replicas should not change. status: %v", status)
+	}
+
+	// replicas change is held during a bundle push
+	if getGuardedReplicas(spec, status, 3, 5, "bundle push is in progress", "") != 3 || status.HeldReplicas != 5 || status.Message == "" {
+		t.Errorf("replicas change should be held. status: %v", status)
+	}
+
+	// held replicas change is applied once the bundle push is complete
+	if getGuardedReplicas(spec, status, 3, 5, "", "") != 5 || status.TargetReplicas != 5 || status.HeldReplicas != 0 || status.LastScaleTime == 0 {
+		t.Errorf("replicas change should be applied. status: %v", status)
+	}
+
+	// scaling in progress is not interrupted by a decommission in flight
+	if getGuardedReplicas(spec, status, 4, 5, "", "decommission of splunk-stack1-indexer-3 is in progress") != 5 {
+		t.Errorf("scaling in progress should go ahead. status: %v", status)
+	}
+
+	// new replicas change is held while a decommission is in flight
+	if getGuardedReplicas(spec, status, 4, 2, "", "decommission of splunk-stack1-indexer-3 is in progress") != 5 || status.HeldReplicas != 2 {
+		t.Errorf("replicas change should be held. status: %v", status)
+	}
+
+	// scaling is complete
+	if getGuardedReplicas(spec, status, 5, 5, "", "") != 5 || status.TargetReplicas != 0 || status.HeldReplicas != 0 {
+		t.Errorf("scaling should be complete. status: %v", status)
+	}
+
+	// replicas change is held within the cooldown
+	spec.MetricsEnabled = false
+	spec.ScaleCooldown = &metav1.Duration{Duration: 10 * time.Minute}
+	if getGuardedReplicas(spec, status, 5, 2, "", "") != 5 || status.HeldReplicas != 2 {
+		t.Errorf("replicas change should be held within the cooldown. status: %v", status)
+	}
+
+	// and applied after the cooldown
+	status.LastScaleTime -= 600
+	if getGuardedReplicas(spec, status, 5, 2, "", "") != 2 || status.TargetReplicas != 2 {
+		t.Errorf("replicas change should be applied after the cooldown. status: %v", status)
+	}
+}
+
+func TestIndexerClusterAutoscalingMetrics(t *testing.T) {
+	ctx := context.TODO()
+
+	savedGetIndexerPeerLoadCall := GetIndexerPeerLoadCall
+	defer func() { GetIndexerPeerLoadCall = savedGetIndexerPeerLoadCall }()
+	GetIndexerPeerLoadCall = func(ctx context.Context, mgr *indexerClusterPodManager, n int32) (*indexerPeerLoad, error) {
+		if n == 1 {
+			return nil, fmt.Errorf("peer is not reachable")
+		}
+		return &indexerPeerLoad{queueFillRatio: 0.5, ingestionKBps: 2048}, nil
+	}
+
+	mgr := getIndexerClusterPodManager("TestIndexerClusterAutoscalingMetrics", nil, &spltest.MockHTTPClient{}, 2)
+	mgr.cr.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{
+		{Name: "splunk-stack1-indexer-0", Status: "Up"},
+		{Name: "splunk-stack1-indexer-1", Status: "Up"},
+	}
+	peers := map[string]splclient.ClusterManagerPeerInfo{
+		"splunk-stack1-indexer-0": {BucketCount: 10, PrimaryCount: 5},
+		"splunk-stack1-indexer-1": {BucketCount: 20, PrimaryCount: 15},
+	}
+
+	// metrics are not exported unless enabled
+	mgr.updateAutoscalingMetrics(ctx, peers)
+	if testutil.CollectAndCount(indexerPeerBucketCount) != 0 {
+		t.Errorf("metrics should not be exported")
+	}
+
+	mgr.cr.Spec.Autoscaling.MetricsEnabled = true
+	mgr.updateAutoscalingMetrics(ctx, peers)
+	if testutil.ToFloat64(indexerPeerBucketCount.WithLabelValues("test", "stack1", "splunk-stack1-indexer-1")) != 20 ||
+		testutil.ToFloat64(indexerPeerPrimaryCount.WithLabelValues("test", "stack1", "splunk-stack1-indexer-0")) != 5 {
+		t.Errorf("unexpected bucket metrics")
+	}
+	if testutil.ToFloat64(indexerPeerQueueFillRatio.WithLabelValues("test", "stack1", "splunk-stack1-indexer-0")) != 0.5 ||
+		testutil.ToFloat64(indexerPeerIngestionKBps.WithLabelValues("test", "stack1", "splunk-stack1-indexer-0")) != 2048 {
+		t.Errorf("unexpected load metrics")
+	}
+	if testutil.CollectAndCount(indexerPeerQueueFillRatio) != 1 {
+		t.Errorf("load metrics of an unreachable peer should not be exported")
+	}
+
+	// metrics of another indexer cluster are left alone
+	indexerPeerBucketCount.WithLabelValues("test", "stack2", "splunk-stack2-indexer-0").Set(30)
+	defer indexerPeerBucketCount.DeleteLabelValues("test", "stack2", "splunk-stack2-indexer-0")
+
+	// peers removed by a scale down are not reported anymore, while the remaining peers are updated in place
+	mgr.cr.Status.Peers = mgr.cr.Status.Peers[:1]
+	peers["splunk-stack1-indexer-0"] = splclient.ClusterManagerPeerInfo{BucketCount: 12, PrimaryCount: 6}
+	mgr.updateAutoscalingMetrics(ctx, peers)
+	if testutil.CollectAndCount(indexerPeerBucketCount) != 2 || testutil.CollectAndCount(indexerPeerPrimaryCount) != 1 {
+		t.Errorf("metrics of the removed peers should be deleted")
+	}
+	if testutil.ToFloat64(indexerPeerBucketCount.WithLabelValues("test", "stack1", "splunk-stack1-indexer-0")) != 12 ||
+		testutil.ToFloat64(indexerPeerPrimaryCount.WithLabelValues("test", "stack1", "splunk-stack1-indexer-0")) != 6 {
+		t.Errorf("metrics of the remaining peers should be updated")
+	}
+	if testutil.ToFloat64(indexerPeerBucketCount.WithLabelValues("test", "stack2", "splunk-stack2-indexer-0")) != 30 {
+		t.Errorf("metrics of another indexer cluster should not be deleted")
+	}
+
+	deleteIndexerClusterMetrics(mgr.cr)
+	if testutil.CollectAndCount(indexerPeerBucketCount) != 1 || testutil.CollectAndCount(indexerPeerIngestionKBps) != 0 {
+		t.Errorf("metrics of the indexer cluster should be deleted")
+	}
+}
+
+func TestSearchHeadClusterAutoscalingMetrics(t *testing.T) {
+	cr := enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	cr.Spec.Autoscaling.MetricsEnabled = true
+	cr.Status.Members = []enterpriseApi.SearchHeadClusterMemberStatus{
+		{Name: "splunk-stack1-search-head-0", Registered: true, ActiveHistoricalSearchCount: 4, ActiveRealtimeSearchCount: 1},
+		{Name: "splunk-stack1-search-head-1"},
+	}
+	mgr := &searchHeadClusterPodManager{cr: &cr}
+
+	mgr.updateAutoscalingMetrics()
+	if testutil.ToFloat64(searchHeadMemberHistoricalSearches.WithLabelValues("test", "stack1", "splunk-stack1-search-head-0")) != 4 ||
+		testutil.ToFloat64(searchHeadMemberRealtimeSearches.WithLabelValues("test", "stack1", "splunk-stack1-search-head-0")) != 1 {
+		t.Errorf("unexpected search metrics")
+	}
+	if testutil.CollectAndCount(searchHeadMemberHistoricalSearches) != 1 {
+		t.Errorf("search metrics of an unregistered member should not be exported")
+	}
+
+	// members which are not registered anymore are not reported
+	cr.Status.Members[0].Registered = false
+	cr.Status.Members[1].Registered = true
+	mgr.updateAutoscalingMetrics()
+	if testutil.CollectAndCount(searchHeadMemberHistoricalSearches) != 1 ||
+		testutil.ToFloat64(searchHeadMemberHistoricalSearches.WithLabelValues("test", "stack1", "splunk-stack1-search-head-1")) != 0 {
+		t.Errorf("search metrics of the unregistered member should be deleted")
+	}
+
+	deleteSearchHeadClusterMetrics(&cr)
+	if testutil.CollectAndCount(searchHeadMemberRealtimeSearches) != 0 {
+		t.Errorf("metrics of the search head cluster should be deleted")
+	}
+}
+
+func TestClusterBusyReason(t *testing.T) {
+	mgr := getIndexerClusterPodManager("TestClusterBusyReason", nil, &spltest.MockHTTPClient{}, 1)
+	clusterInfo := &splclient.ClusterManagerInfo{}
+	if mgr.getIndexerClusterBusyReason(clusterInfo) != "" {
+		t.Errorf("indexer cluster should not be busy")
+	}
+	clusterInfo.LatestBundle.Checksum = "14310A4AABD23E85BBD4559C4A3B59F8"
+	if mgr.getIndexerClusterBusyReason(clusterInfo) == "" {
+		t.Errorf("indexer cluster should be busy with the bundle push")
+	}
+
+	mgr.cr.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{{Name: "splunk-stack1-indexer-0", Status: "ReassigningPrimaries"}}
+	if getIndexerClusterDecommissionReason(mgr.cr) == "" {
+		t.Errorf("decommission should be in flight")
+	}
+
+	cr := &enterpriseApi.SearchHeadCluster{}
+	cr.Status.AppContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
+	if getSearchHeadClusterBusyReason(cr) == "" {
+		t.Errorf("search head cluster should be busy with the bundle push")
+	}
+	cr.Status.Members = []enterpriseApi.SearchHeadClusterMemberStatus{{Name: "splunk-stack1-search-head-0", Status: "ManualDetention"}}
+	if getSearchHeadClusterDetentionReason(cr) == "" {
+		t.Errorf("member removal should be in flight")
+	}
+}
@@ -0,0 +1,182 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+)
+
+// maximum number of bundles kept in the bundle history
+const bundleHistoryLength = 20
+
+// max. time in seconds to wait for the peers to roll back to the previous bundle
+const bundleRollbackTimeout = 1800
+
+// addBundleHistoryEntry records a bundle push in the bundle history of the cluster manager. The ID of the bundle is
+// set once the cluster manager reports the pushed bundle
+func addBundleHistoryEntry(cr *enterpriseApi.ClusterManager, trigger string, apps []string) {
+	history := append(cr.Status.BundleHistory, enterpriseApi.BundleHistoryEntry{
+		PushTime: time.Now().Unix(),
+		Trigger:  trigger,
+		Apps:     apps,
+	})
+	if len(history) > bundleHistoryLength {
+		history = history[len(history)-bundleHistoryLength:]
+	}
+	cr.Status.BundleHistory = history
+}
+
+// getClusterScopedAppsPendingInstall returns the cluster scoped apps installed by the bundle push, in
+// <appSource>/<appName> format
+func getClusterScopedAppsPendingInstall(ctx context.Context, appDeployContext *enterpriseApi.AppDeploymentContext) []string {
+	apps := []string{}
+	for appSrcName, appSrcDeployInfo := range appDeployContext.AppsSrcDeployStatus {
+		if enterpriseApi.ScopeCluster != getAppSrcScope(ctx, &appDeployContext.AppFrameworkConfig, appSrcName) {
+			continue
+		}
+
+		for _, appDeployInfo := range appSrcDeployInfo.AppDeploymentInfoList {
+			if appDeployInfo.PhaseInfo.Phase == enterpriseApi.PhasePodCopy && appDeployInfo.PhaseInfo.Status == enterpriseApi.AppPkgPodCopyComplete {
+				apps = append(apps, appSrcName+"/"+appDeployInfo.AppName)
+			}
+		}
+	}
+	sort.Strings(apps)
+	return apps
+}
+
+// updateBundleHistory sets the ID of the bundle pushed last, as reported by the cluster manager. A push of a bundle
+// identical to the previous bundle is removed from the history
+func (mgr *clusterManagerPodManager) updateBundleHistory(ctx context.Context) error {
+	history := mgr.cr.Status.BundleHistory
+	if len(history) == 0 || history[len(history)-1].BundleID != "" {
+		return nil
+	}
+
+	info, err := mgr.getClusterManagerClient(mgr.cr).GetClusterManagerInfo()
+	if err != nil {
+		return err
+	}
+
+	bundleID := info.LatestBundle.Checksum
+	if len(history) > 1 && history[len(history)-2].BundleID == bundleID {
+		mgr.log.Info("Pushed bundle is identical to the previous bundle", "bundleID", bundleID)
+		mgr.cr.Status.BundleHistory = history[:len(history)-1]
+		return nil
+	}
+
+	mgr.log.Info("Recording the pushed bundle", "bundleID", bundleID, "trigger", history[len(history)-1].Trigger)
+	history[len(history)-1].BundleID = bundleID
+	return nil
+}
+
+// applyBundleRollback rolls back the peers to the previous bundle, when the bundle rollback annotation is set to a new
+// value, and tracks the rollback until all the peers report the previous bundle
+func (mgr *clusterManagerPodManager) applyBundleRollback(ctx context.Context, c splcommon.ControllerClient) error {
+	eventPublisher, _ := newK8EventPublisher(c, mgr.cr)
+	status := &mgr.cr.Status.BundleRollback
+
+	request := mgr.cr.GetAnnotations()[enterpriseApi.BundleRollbackAnnotation]
+	if request != "" && request != status.Request {
+		splunkClient := mgr.getClusterManagerClient(mgr.cr)
+		info, err := splunkClient.GetClusterManagerInfo()
+		if err != nil {
+			return err
+		}
+
+		*status = enterpriseApi.BundleRollbackStatus{
+			Request:      request,
+			FromBundleID: info.ActiveBundle.Checksum,
+			ToBundleID:   info.PreviousActiveBundle.Checksum,
+			StartTime:    time.Now().Unix(),
+		}
+		if status.ToBundleID == "" {
+			status.Phase = enterpriseApi.BundleRollbackFailed
+			status.CompletionTime = status.StartTime
+			status.Message = "no previous bundle to roll back to"
+			eventPublisher.Warning(ctx, "applyBundleRollback", fmt.Sprintf("bundle rollback failed: %s", status.Message))
+			return nil
+		}
+
+		// the request is handled again in the next reconcile, if the rollback call fails
+		err = splunkClient.RollbackClusterManagerBundle()
+		if err != nil {
+			status.Request = ""
+			return err
+		}
+
+		mgr.log.Info("Rolling back the peers to the previous bundle", "fromBundleID", status.FromBundleID, "toBundleID", status.ToBundleID)
+		status.Phase = enterpriseApi.BundleRollbackInProgress
+		status.Message = fmt.Sprintf("rolling back the peers from bundle %s to bundle %s", status.FromBundleID, status.ToBundleID)
+		return nil
+	}
+
+	if status.Phase != enterpriseApi.BundleRollbackInProgress {
+		return nil
+	}
+
+	pendingPeers, err := mgr.getPeersNotOnBundle(ctx, c, status.ToBundleID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().Unix()
+	if len(pendingPeers) == 0 {
+		status.Phase = enterpriseApi.BundleRollbackComplete
+		status.CompletionTime = now
+		status.Message = fmt.Sprintf("peers rolled back to bundle %s", status.ToBundleID)
+		mgr.log.Info("Bundle rollback complete", "bundleID", status.ToBundleID)
+
+		addBundleHistoryEntry(mgr.cr, enterpriseApi.BundlePushTriggerRollback, nil)
+		mgr.cr.Status.BundleHistory[len(mgr.cr.Status.BundleHistory)-1].BundleID = status.ToBundleID
+		return nil
+	}
+
+	if status.StartTime+bundleRollbackTimeout < now {
+		status.Phase = enterpriseApi.BundleRollbackFailed
+		status.CompletionTime = now
+		status.Message = fmt.Sprintf("timed out waiting for peers %v to roll back to bundle %s", pendingPeers, status.ToBundleID)
+		eventPublisher.Warning(ctx, "applyBundleRollback", fmt.Sprintf("bundle rollback failed: %s", status.Message))
+	}
+
+	return nil
+}
+
+// getPeersNotOnBundle returns the peers of the indexer clusters referring to the cluster manager, whose active bundle
+// is not the given bundle
+func (mgr *clusterManagerPodManager) getPeersNotOnBundle(ctx context.Context, c splcommon.ControllerClient, bundleID string) ([]string, error) {
+	indexerList, err := getClusterManagerIndexerClusterList(ctx, c, mgr.cr, mgr.cr.GetNamespace(), mgr.cr.GetName(), mgr.cr.Spec.AllowedPeerNamespaces)
+	if err != nil {
+		return nil, err
+	}
+
+	pendingPeers := []string{}
+	for _, idxc := range indexerList.Items {
+		for _, peer := range idxc.Status.Peers {
+			if peer.ActiveBundleID != bundleID {
+				pendingPeers = append(pendingPeers, peer.Name)
+			}
+		}
+	}
+	sort.Strings(pendingPeers)
+	return pendingPeers, nil
+}
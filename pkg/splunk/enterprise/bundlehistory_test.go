@@ -0,0 +1,186 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getBundleInfoHandler(active, latest, previous string) spltest.MockHTTPHandler {
+	return spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    siteFailoverManagerURI + "/services/cluster/manager/info?count=0&output_mode=json",
+		Status: 200,
+		Body:   fmt.Sprintf(`{"entry":[{"content":{"active_bundle":{"checksum":"%s"},"latest_bundle":{"checksum":"%s"},"previous_active_bundle":{"checksum":"%s"}}}]}`, active, latest, previous),
+	}
+}
+
+func TestAddBundleHistoryEntry(t *testing.T) {
+	cr := &enterpriseApi.ClusterManager{}
+	for i := 0; i < bundleHistoryLength+5; i++ {
+		addBundleHistoryEntry(cr, enterpriseApi.BundlePushTriggerSmartStore, nil)
+		cr.Status.BundleHistory[len(cr.Status.BundleHistory)-1].BundleID = fmt.Sprintf("B%d", i)
+	}
+	addBundleHistoryEntry(cr, enterpriseApi.BundlePushTriggerAppFramework, []string{"appSrc1/app1.tgz"})
+
+	history := cr.Status.BundleHistory
+	if len(history) != bundleHistoryLength {
+		t.Errorf("bundle history should be bounded to %d entries, got %d", bundleHistoryLength, len(history))
+	}
+	if history[0].BundleID != "B6" {
+		t.Errorf("oldest entries should be dropped, got first entry %s", history[0].BundleID)
+	}
+	last := history[len(history)-1]
+	if last.BundleID != "" || last.Trigger != enterpriseApi.BundlePushTriggerAppFramework || len(last.Apps) != 1 || last.PushTime == 0 {
+		t.Errorf("unexpected last bundle history entry %v", last)
+	}
+}
+
+func TestUpdateBundleHistory(t *testing.T) {
+	ctx := context.TODO()
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(getBundleInfoHandler("B2", "B2", "B1"))
+	mgr := getSiteFailoverPodManager("TestUpdateBundleHistory", mockSplunkClient)
+	cr := mgr.cr
+
+	// nothing to update without a pending entry
+	err := mgr.updateBundleHistory(ctx)
+	if err != nil || len(mockSplunkClient.GotRequests) != 0 {
+		t.Errorf("bundle history should not be updated. err: %v", err)
+	}
+
+	addBundleHistoryEntry(cr, enterpriseApi.BundlePushTriggerSmartStore, nil)
+	err = mgr.updateBundleHistory(ctx)
+	if err != nil || len(cr.Status.BundleHistory) != 1 || cr.Status.BundleHistory[0].BundleID != "B2" {
+		t.Errorf("pushed bundle should be recorded. err: %v, history: %v", err, cr.Status.BundleHistory)
+	}
+
+	// push of an identical bundle is removed from the history
+	addBundleHistoryEntry(cr, enterpriseApi.BundlePushTriggerAppFramework, nil)
+	err = mgr.updateBundleHistory(ctx)
+	if err != nil || len(cr.Status.BundleHistory) != 1 {
+		t.Errorf("identical bundle should not be recorded. err: %v, history: %v", err, cr.Status.BundleHistory)
+	}
+
+	// error fetching the cluster manager info
+	mgr = getSiteFailoverPodManager("TestUpdateBundleHistory", &spltest.MockHTTPClient{})
+	addBundleHistoryEntry(mgr.cr, enterpriseApi.BundlePushTriggerSmartStore, nil)
+	err = mgr.updateBundleHistory(ctx)
+	if err == nil {
+		t.Errorf("updateBundleHistory() should return error")
+	}
+}
+
+func TestApplyBundleRollback(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		getBundleInfoHandler("B2", "B2", "B1"),
+		spltest.MockHTTPHandler{Method: "POST", URL: siteFailoverManagerURI + "/services/cluster/manager/control/default/rollback", Status: 200},
+	)
+	mgr := getSiteFailoverPodManager("TestApplyBundleRollback", mockSplunkClient)
+	cr := mgr.cr
+	status := &cr.Status.BundleRollback
+
+	idxc := enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc", Namespace: "test"},
+	}
+	idxc.Spec.ClusterManagerRef.Name = "stack1"
+	idxc.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{
+		{Name: "splunk-idxc-indexer-0", ActiveBundleID: "B2"},
+		{Name: "splunk-idxc-indexer-1", ActiveBundleID: "B2"},
+	}
+	c.ListObj = &enterpriseApi.IndexerClusterList{Items: []enterpriseApi.IndexerCluster{idxc}}
+
+	// nothing to do without a rollback request
+	err := mgr.applyBundleRollback(ctx, c)
+	if err != nil || status.Phase != "" || len(mockSplunkClient.GotRequests) != 0 {
+		t.Errorf("bundle should not be rolled back. err: %v", err)
+	}
+
+	cr.ObjectMeta.Annotations = map[string]string{enterpriseApi.BundleRollbackAnnotation: "1"}
+	err = mgr.applyBundleRollback(ctx, c)
+	if err != nil || status.Phase != enterpriseApi.BundleRollbackInProgress || status.Request != "1" ||
+		status.FromBundleID != "B2" || status.ToBundleID != "B1" || len(mockSplunkClient.GotRequests) != 2 {
+		t.Errorf("bundle rollback should be in progress. err: %v, status: %v", err, status)
+	}
+
+	// rollback is in progress until all the peers report the previous bundle
+	idxc.Status.Peers[0].ActiveBundleID = "B1"
+	c.ListObj = &enterpriseApi.IndexerClusterList{Items: []enterpriseApi.IndexerCluster{idxc}}
+	err = mgr.applyBundleRollback(ctx, c)
+	if err != nil || status.Phase != enterpriseApi.BundleRollbackInProgress || len(mockSplunkClient.GotRequests) != 2 {
+		t.Errorf("bundle rollback should still be in progress. err: %v, status: %v", err, status)
+	}
+
+	// rollback times out
+	startTime := status.StartTime
+	status.StartTime -= bundleRollbackTimeout + 1
+	err = mgr.applyBundleRollback(ctx, c)
+	if err != nil || status.Phase != enterpriseApi.BundleRollbackFailed || status.Message == "" {
+		t.Errorf("bundle rollback should time out. err: %v, status: %v", err, status)
+	}
+
+	// rollback completes once all the peers report the previous bundle
+	status.Phase = enterpriseApi.BundleRollbackInProgress
+	status.StartTime = startTime
+	idxc.Status.Peers[1].ActiveBundleID = "B1"
+	c.ListObj = &enterpriseApi.IndexerClusterList{Items: []enterpriseApi.IndexerCluster{idxc}}
+	err = mgr.applyBundleRollback(ctx, c)
+	if err != nil || status.Phase != enterpriseApi.BundleRollbackComplete || status.CompletionTime == 0 {
+		t.Errorf("bundle rollback should be complete. err: %v, status: %v", err, status)
+	}
+	history := cr.Status.BundleHistory
+	if len(history) != 1 || history[0].BundleID != "B1" || history[0].Trigger != enterpriseApi.BundlePushTriggerRollback {
+		t.Errorf("bundle rollback should be recorded in the bundle history %v", history)
+	}
+
+	// the same request is not repeated
+	err = mgr.applyBundleRollback(ctx, c)
+	if err != nil || status.Phase != enterpriseApi.BundleRollbackComplete || len(mockSplunkClient.GotRequests) != 2 {
+		t.Errorf("bundle rollback should not be repeated. err: %v", err)
+	}
+
+	// rollback fails without a previous bundle
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(getBundleInfoHandler("B2", "B2", ""))
+	mgr = getSiteFailoverPodManager("TestApplyBundleRollback", mockSplunkClient)
+	mgr.cr.ObjectMeta.Annotations = map[string]string{enterpriseApi.BundleRollbackAnnotation: "1"}
+	err = mgr.applyBundleRollback(ctx, c)
+	if err != nil || mgr.cr.Status.BundleRollback.Phase != enterpriseApi.BundleRollbackFailed || len(mockSplunkClient.GotRequests) != 1 {
+		t.Errorf("bundle rollback should fail without a previous bundle. err: %v", err)
+	}
+
+	// failed rollback request is retried
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		getBundleInfoHandler("B2", "B2", "B1"),
+		spltest.MockHTTPHandler{Method: "POST", URL: siteFailoverManagerURI + "/services/cluster/manager/control/default/rollback", Status: 500},
+	)
+	mgr = getSiteFailoverPodManager("TestApplyBundleRollback", mockSplunkClient)
+	mgr.cr.ObjectMeta.Annotations = map[string]string{enterpriseApi.BundleRollbackAnnotation: "1"}
+	err = mgr.applyBundleRollback(ctx, c)
+	if err == nil || mgr.cr.Status.BundleRollback.Request != "" {
+		t.Errorf("failed bundle rollback should be retried. err: %v", err)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
+	"strings"
 	"time"
 
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
@@ -57,7 +58,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 
 	var err error
 	// Initialize phase
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 
 	// Update the CR Status
 	defer updateCRStatus(ctx, client, cr, &err)
@@ -90,6 +91,8 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 			// once the CM is in ready state otherwise, we keep retrying
 			cr.Status.BundlePushTracker.NeedToPushManagerApps = true
 			cr.Status.BundlePushTracker.LastCheckInterval = time.Now().Unix()
+			// the changed bundle needs to be validated again
+			cr.Status.BundlePushTracker.Validation = enterpriseApi.BundleValidationStatus{}
 		}
 
 		cr.Status.SmartStore = cr.Spec.SmartStore
@@ -129,8 +132,8 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			extraEnv, _ := VerifyCMisMultisiteCall(ctx, cr, namespaceScopedSecret)
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, extraEnv, false)
+			extraEnv, _ := VerifyCMisMultisiteCall(ctx, client, cr, namespaceScopedSecret)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), extraEnv, false)
 			if err != nil {
 				return result, err
 			}
@@ -166,8 +169,8 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 		return result, err
 	}
 
-	// create or update a regular service for the cluster manager
-	err = splctrl.ApplyService(ctx, client, getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, false))
+	// create or update a regular service for the active cluster manager
+	err = splctrl.ApplyService(ctx, client, getClusterManagerService(ctx, cr))
 	if err != nil {
 		return result, err
 	}
@@ -179,7 +182,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	//make changes to respective mc configmap when changing/removing mcRef from spec
-	extraEnv, err := VerifyCMisMultisiteCall(ctx, cr, namespaceScopedSecret)
+	extraEnv, err := VerifyCMisMultisiteCall(ctx, client, cr, namespaceScopedSecret)
 	err = validateMonitoringConsoleRef(ctx, client, statefulSet, extraEnv)
 	if err != nil {
 		return result, err
@@ -191,13 +194,47 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 		return result, err
 	}
 
-	clusterManagerManager := splctrl.DefaultStatefulSetPodManager{}
+	// revert the version upgrade when the pods are not ready within the health deadline
+	continueReconcile, err = applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// hold the version upgrade until the upgrade policy allows it
+	continueReconcile, err = applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, lastPhase, &cr.Status.Upgrade)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	clusterManagerManager := splctrl.DefaultStatefulSetPodManager{VolumeResize: &cr.Status.VolumeResize}
 	phase, err := clusterManagerManager.Update(ctx, client, statefulSet, 1)
 	if err != nil {
 		return result, err
 	}
 	cr.Status.Phase = phase
 
+	// create, update or remove the standby cluster manager
+	standbyPhase, err := applyClusterManagerStandby(ctx, client, cr)
+	if err != nil {
+		eventPublisher.Warning(ctx, "applyClusterManagerStandby", fmt.Sprintf("update of the standby cluster manager failed %s", err.Error()))
+		return result, err
+	}
+
+	// fail over or fail back the sites of the indexer cluster
+	newSplunkClient, err := getSplunkClientFunc(ctx, client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+	mgr := clusterManagerPodManager{log: scopedLog, cr: cr, secrets: namespaceScopedSecret, newSplunkClient: newSplunkClient}
+	err = mgr.applySiteFailover(ctx, client)
+	if err != nil {
+		eventPublisher.Warning(ctx, "applySiteFailover", fmt.Sprintf("site failover failed %s", err.Error()))
+		return result, err
+	}
+	if cr.Status.SiteFailover.ActiveManager == enterpriseApi.ActiveManagerStandby {
+		cr.Status.Phase = standbyPhase
+	}
+
 	// no need to requeue if everything is ready
 	if cr.Status.Phase == enterpriseApi.PhaseReady {
 		//upgrade fron automated MC to MC CRD
@@ -208,7 +245,7 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 		}
 		//Update MC configmap
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, extraEnv, true)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), extraEnv, true)
 			if err != nil {
 				return result, err
 			}
@@ -228,6 +265,18 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 			cr.Status.TelAppInstalled = true
 		}
 
+		// record the pushed bundles and roll back the peers to the previous bundle on request
+		err = mgr.updateBundleHistory(ctx)
+		if err != nil {
+			eventPublisher.Warning(ctx, "updateBundleHistory", fmt.Sprintf("update of the bundle history failed %s", err.Error()))
+			return result, err
+		}
+		err = mgr.applyBundleRollback(ctx, client)
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyBundleRollback", fmt.Sprintf("bundle rollback failed %s", err.Error()))
+			return result, err
+		}
+
 		// Manager apps bundle push requires multiple reconcile iterations in order to reflect the configMap on the CM pod.
 		// So keep PerformCmBundlePush() as the last call in this block of code, so that other functionalities are not blocked
 		err = PerformCmBundlePush(ctx, client, cr)
@@ -238,6 +287,25 @@ func ApplyClusterManager(ctx context.Context, client splcommon.ControllerClient,
 		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
 		result = *finalResult
 
+		// retry the bundle push held for the restart window once the window opens
+		validation := &cr.Status.BundlePushTracker.Validation
+		if cr.Status.BundlePushTracker.NeedToPushManagerApps && validation.PushHeld && validation.NextWindowStart != 0 {
+			requeueAfter := getWindowRequeueTime(validation.NextWindowStart, time.Now())
+			if !result.Requeue || result.RequeueAfter == 0 || requeueAfter < result.RequeueAfter {
+				result.Requeue = true
+				result.RequeueAfter = requeueAfter
+			}
+		}
+
+		// track the bundle rollback and the ID of the pushed bundle until the cluster manager reports them
+		history := cr.Status.BundleHistory
+		if cr.Status.BundleRollback.Phase == enterpriseApi.BundleRollbackInProgress || (len(history) > 0 && history[len(history)-1].BundleID == "") {
+			if !result.Requeue || result.RequeueAfter == 0 || result.RequeueAfter > 5*time.Second {
+				result.Requeue = true
+				result.RequeueAfter = 5 * time.Second
+			}
+		}
+
 		// trigger MonitoringConsole reconcile by changing the splunk/image-tag annotation
 		err = changeMonitoringConsoleAnnotations(ctx, client, cr)
 		if err != nil {
@@ -284,6 +352,16 @@ func validateClusterManagerSpec(ctx context.Context, c splcommon.ControllerClien
 		}
 	}
 
+	err := validateSiteFailoverSpec(cr)
+	if err != nil {
+		return err
+	}
+
+	err = validateBundlePushPolicy(&cr.Spec.BundlePushPolicy)
+	if err != nil {
+		return err
+	}
+
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
 
@@ -377,45 +455,133 @@ func PerformCmBundlePush(ctx context.Context, c splcommon.ControllerClient, cr *
 		return err
 	}
 
+	// Validate the bundle first, so that an invalid bundle is not pushed to the peers
+	validation := &cr.Status.BundlePushTracker.Validation
+	if !cr.Spec.BundlePushPolicy.SkipValidation {
+		validated, err := validateManagerAppsBundle(ctx, c, cr, validation)
+		if !validated {
+			return err
+		}
+	}
+
+	held, nextWindowStart := isBundlePushHeldForRestartWindow(ctx, cr, &cr.Spec.BundlePushPolicy, validation.RestartRequired)
+	setBundlePushHeld(validation, held, nextWindowStart)
+	if held {
+		scopedLog.Info("Bundle push requires a rolling restart of the peers, waiting for the restart window", "nextWindowStart", validation.NextWindowStart)
+		return nil
+	}
+
 	err = PushManagerAppsBundle(ctx, c, cr)
 	if err == nil {
 		scopedLog.Info("Bundle push success")
 		cr.Status.BundlePushTracker.NeedToPushManagerApps = false
+		addBundleHistoryEntry(cr, enterpriseApi.BundlePushTriggerSmartStore, nil)
 	}
 
 	//eventPublisher.Warning(ctx, "BundlePush", fmt.Sprintf("Bundle push failed %s", err.Error()))
 	return err
 }
 
+// validateManagerAppsBundle validates the manager apps bundle before the push, and captures in the validation status
+// if the push requires a rolling restart of the peers. As the cluster manager validates the bundle in the background,
+// the validation spans multiple reconciles, and returns true only once the bundle passed the validation
+func validateManagerAppsBundle(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager, validation *enterpriseApi.BundleValidationStatus) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("validateManagerAppsBundle").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+
+	if validation.Phase == enterpriseApi.BundleValidationSuccess {
+		return true, nil
+	}
+
+	splunkClient, err := getManagerAppsBundleClient(ctx, c, cr)
+	if err != nil {
+		return false, err
+	}
+
+	// a previous validation failure is retried, in case the bundle was fixed in the meantime
+	if validation.Phase != enterpriseApi.BundleValidationPending {
+		scopedLog.Info("Validating the manager apps bundle")
+		err = splunkClient.ValidateClusterManagerBundle(true)
+		if err != nil {
+			return false, fmt.Errorf("bundle validation request failed. Reason %v", err)
+		}
+		validation.Phase = enterpriseApi.BundleValidationPending
+		validation.LastValidationTime = time.Now().Unix()
+		return false, fmt.Errorf("waiting for the manager apps bundle validation")
+	}
+
+	info, err := splunkClient.GetClusterManagerInfo()
+	if err != nil {
+		return false, err
+	}
+
+	// the bundle validated before the request is stale
+	if info.LastValidatedBundle.Timestamp < validation.LastValidationTime {
+		if validation.LastValidationTime+bundleValidationTimeout < time.Now().Unix() {
+			validation.Phase = enterpriseApi.BundleValidationFailure
+			validation.Errors = []string{"timed out waiting for the bundle validation"}
+			eventPublisher.Warning(ctx, "validateManagerAppsBundle", "timed out waiting for the manager apps bundle validation")
+			return false, fmt.Errorf("timed out waiting for the manager apps bundle validation")
+		}
+		return false, fmt.Errorf("waiting for the manager apps bundle validation")
+	}
+
+	validation.RestartRequired = info.LastCheckRestartBundleResult
+	if !info.LastValidatedBundle.IsValidBundle {
+		validation.Phase = enterpriseApi.BundleValidationFailure
+		validation.Errors = info.ApplyBundleStatus.InvalidBundle.ValidationErrors
+		eventPublisher.Warning(ctx, "validateManagerAppsBundle", fmt.Sprintf("manager apps bundle failed the validation: %s", strings.Join(validation.Errors, "; ")))
+		return false, fmt.Errorf("manager apps bundle failed the validation: %s", strings.Join(validation.Errors, "; "))
+	}
+
+	scopedLog.Info("Manager apps bundle passed the validation", "restartRequired", validation.RestartRequired)
+	validation.Phase = enterpriseApi.BundleValidationSuccess
+	validation.Errors = nil
+	return true, nil
+}
+
 // PushManagerAppsBundle issues the REST command to for cluster manager bundle push
 func PushManagerAppsBundle(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) error {
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("PushManagerApps").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	splunkClient, err := getManagerAppsBundleClient(ctx, c, cr)
+	if err != nil {
+		return err
+	}
+
+	scopedLog.Info("Issuing REST call to push manager aps bundle")
+	return splunkClient.BundlePush(true)
+}
+
+// getManagerAppsBundleClient returns a SplunkClient for the cluster manager, to validate and push the manager apps bundle
+var getManagerAppsBundleClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (*splclient.SplunkClient, error) {
 	eventPublisher, _ := newK8EventPublisher(c, cr)
 
 	defaultSecretObjName := splcommon.GetNamespaceScopedSecretName(cr.GetNamespace())
 	defaultSecret, err := splutil.GetSecretByName(ctx, c, cr.GetNamespace(), cr.GetName(), defaultSecretObjName)
 	if err != nil {
 		eventPublisher.Warning(ctx, "PushManagerAppsBundle", fmt.Sprintf("Could not access default secret object to fetch admin password. Reason %v", err))
-		return fmt.Errorf("could not access default secret object to fetch admin password. Reason %v", err)
+		return nil, fmt.Errorf("could not access default secret object to fetch admin password. Reason %v", err)
 	}
 
 	//Get the admin password from the secret object
 	adminPwd, foundSecret := defaultSecret.Data["password"]
 	if !foundSecret {
 		eventPublisher.Warning(ctx, "PushManagerAppsBundle", "could not find admin password while trying to push the manager apps bundle")
-		return fmt.Errorf("could not find admin password while trying to push the manager apps bundle")
+		return nil, fmt.Errorf("could not find admin password while trying to push the manager apps bundle")
 	}
 
-	scopedLog.Info("Issuing REST call to push manager aps bundle")
-
 	managerIdxcName := cr.GetName()
 	fqdnName := splcommon.GetServiceFQDN(cr.GetNamespace(), GetSplunkServiceName(SplunkClusterManager, managerIdxcName, false))
 
 	// Get a Splunk client to execute the REST call
-	splunkClient := splclient.NewSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(adminPwd))
-
-	return splunkClient.BundlePush(true)
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return nil, err
+	}
+	return newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(adminPwd)), nil
 }
 
 // helper function to get the list of ClusterManager types in the current namespace
@@ -437,11 +603,15 @@ func getClusterManagerList(ctx context.Context, c splcommon.ControllerClient, cr
 }
 
 // VerifyCMisMultisite checks if its a multisite used also in mock
-var VerifyCMisMultisiteCall = func(ctx context.Context, cr *enterpriseApi.ClusterManager, namespaceScopedSecret *corev1.Secret) ([]corev1.EnvVar, error) {
+var VerifyCMisMultisiteCall = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager, namespaceScopedSecret *corev1.Secret) ([]corev1.EnvVar, error) {
 	var err error
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("Verify if Multisite Indexer Cluster").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
-	mgr := clusterManagerPodManager{log: scopedLog, cr: cr, secrets: namespaceScopedSecret, newSplunkClient: splclient.NewSplunkClient}
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return nil, err
+	}
+	mgr := clusterManagerPodManager{log: scopedLog, cr: cr, secrets: namespaceScopedSecret, newSplunkClient: newSplunkClient}
 	cm := mgr.getClusterManagerClient(cr)
 	clusterInfo, err := cm.GetClusterInfo(false)
 	if err != nil {
@@ -759,6 +759,91 @@ func TestPerformCmBundlePush(t *testing.T) {
 	}
 }
 
+func TestValidateManagerAppsBundle(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.ClusterManager{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterManager",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	c := spltest.NewMockClient()
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	savedGetManagerAppsBundleClient := getManagerAppsBundleClient
+	defer func() { getManagerAppsBundleClient = savedGetManagerAppsBundleClient }()
+	getManagerAppsBundleClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (*splclient.SplunkClient, error) {
+		sc := splclient.NewSplunkClient("https://localhost:8089", "admin", "p@ssw0rd")
+		sc.Client = mockSplunkClient
+		return sc, nil
+	}
+	infoURL := "https://localhost:8089/services/cluster/manager/info?count=0&output_mode=json"
+	infoBody := func(timestamp int64, valid bool, restart bool, errors string) string {
+		return fmt.Sprintf(`{"entry":[{"content":{"last_validated_bundle":{"checksum":"14310A4AABD23E85BBD4559C4A3B59F8","is_valid_bundle":%t,"timestamp":%d},"last_check_restart_bundle_result":%t,"apply_bundle_status":{"invalid_bundle":{"bundle_validation_errors_on_master":[%s]},"status":"None"}}}]}`, valid, timestamp, restart, errors)
+	}
+
+	// validation request fails
+	validated, err := validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if validated || err == nil || cr.Status.BundlePushTracker.Validation.Phase != "" {
+		t.Errorf("validateManagerAppsBundle() should fail when the validation request fails. validated: %t, err: %v", validated, err)
+	}
+
+	// validation is requested
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "POST", URL: "https://localhost:8089/services/cluster/manager/control/default/validate_bundle", Status: 200, Body: ""})
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	validation := &cr.Status.BundlePushTracker.Validation
+	if validated || err == nil || validation.Phase != enterpriseApi.BundleValidationPending || validation.LastValidationTime == 0 {
+		t.Errorf("validateManagerAppsBundle() should wait for the validation. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+
+	// cluster manager still reports the previous validation
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: infoURL, Status: 200, Body: infoBody(validation.LastValidationTime-10, true, false, "")})
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if validated || err == nil || validation.Phase != enterpriseApi.BundleValidationPending {
+		t.Errorf("validateManagerAppsBundle() should wait for the validation. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+
+	// validation times out
+	validation.LastValidationTime -= bundleValidationTimeout + 10
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: infoURL, Status: 200, Body: infoBody(validation.LastValidationTime-10, true, false, "")})
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if validated || err == nil || validation.Phase != enterpriseApi.BundleValidationFailure || len(validation.Errors) != 1 {
+		t.Errorf("validateManagerAppsBundle() should time out. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+
+	// invalid bundle, validation is requested again after a failure
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if validated || err == nil || validation.Phase != enterpriseApi.BundleValidationPending {
+		t.Errorf("validateManagerAppsBundle() should request the validation again. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: infoURL, Status: 200, Body: infoBody(validation.LastValidationTime, false, false, `"indexes.conf: invalid homePath"`)})
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if validated || err == nil || !strings.Contains(err.Error(), "invalid homePath") || validation.Phase != enterpriseApi.BundleValidationFailure || len(validation.Errors) != 1 || validation.Errors[0] != "indexes.conf: invalid homePath" {
+		t.Errorf("validateManagerAppsBundle() should report the validation errors. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+
+	// valid bundle requiring a restart
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if validated || err == nil || validation.Phase != enterpriseApi.BundleValidationPending || len(validation.Errors) != 1 {
+		t.Errorf("validateManagerAppsBundle() should keep the errors until the validation completes. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "GET", URL: infoURL, Status: 200, Body: infoBody(validation.LastValidationTime+1, true, true, "")})
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if !validated || err != nil || validation.Phase != enterpriseApi.BundleValidationSuccess || !validation.RestartRequired || len(validation.Errors) != 0 {
+		t.Errorf("validateManagerAppsBundle() should succeed. validated: %t, err: %v, status: %v", validated, err, *validation)
+	}
+
+	// validated bundle is not validated again
+	mockSplunkClient.GotRequests = nil
+	validated, err = validateManagerAppsBundle(ctx, c, &cr, &cr.Status.BundlePushTracker.Validation)
+	if !validated || err != nil || len(mockSplunkClient.GotRequests) != 0 {
+		t.Errorf("validateManagerAppsBundle() should not validate the bundle again. validated: %t, err: %v, requests: %d", validated, err, len(mockSplunkClient.GotRequests))
+	}
+}
+
 func TestPushManagerAppsBundle(t *testing.T) {
 
 	ctx := context.TODO()
@@ -1592,7 +1677,7 @@ func TestChangeClusterManagerAnnotations(t *testing.T) {
 		debug.PrintStack()
 	}
 
-	VerifyCMisMultisiteCall = func(ctx context.Context, cr *enterpriseApi.ClusterManager, namespaceScopedSecret *corev1.Secret) ([]corev1.EnvVar, error) {
+	VerifyCMisMultisiteCall = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.ClusterManager, namespaceScopedSecret *corev1.Secret) ([]corev1.EnvVar, error) {
 		extraEnv := getClusterManagerExtraEnv(cr, &cr.Spec.CommonSplunkSpec)
 		return extraEnv, err
 	}
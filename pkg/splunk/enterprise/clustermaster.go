@@ -128,8 +128,8 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			extraEnv, _ := VerifyCMasterisMultisite(ctx, cr, namespaceScopedSecret)
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, extraEnv, false)
+			extraEnv, _ := VerifyCMasterisMultisite(ctx, client, cr, namespaceScopedSecret)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), extraEnv, false)
 			if err != nil {
 				return result, err
 			}
@@ -177,7 +177,7 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	//make changes to respective mc configmap when changing/removing mcRef from spec
-	extraEnv, err := VerifyCMasterisMultisite(ctx, cr, namespaceScopedSecret)
+	extraEnv, err := VerifyCMasterisMultisite(ctx, client, cr, namespaceScopedSecret)
 	err = validateMonitoringConsoleRef(ctx, client, statefulSet, extraEnv)
 	if err != nil {
 		return result, err
@@ -200,7 +200,7 @@ func ApplyClusterMaster(ctx context.Context, client splcommon.ControllerClient,
 		}
 		//Update MC configmap
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, extraEnv, true)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), extraEnv, true)
 			if err != nil {
 				return result, err
 			}
@@ -399,7 +399,11 @@ func PushMasterAppsBundle(ctx context.Context, c splcommon.ControllerClient, cr
 	fqdnName := splcommon.GetServiceFQDN(cr.GetNamespace(), GetSplunkServiceName(SplunkClusterMaster, managerIdxcName, false))
 
 	// Get a Splunk client to execute the REST call
-	splunkClient := splclient.NewSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(adminPwd))
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return err
+	}
+	splunkClient := newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(adminPwd))
 
 	return splunkClient.BundlePush(true)
 }
@@ -423,11 +427,15 @@ func getClusterMasterList(ctx context.Context, c splcommon.ControllerClient, cr
 }
 
 // VerifyCMasterisMultisite checks if its a multisite
-func VerifyCMasterisMultisite(ctx context.Context, cr *enterpriseApiV3.ClusterMaster, namespaceScopedSecret *corev1.Secret) ([]corev1.EnvVar, error) {
+func VerifyCMasterisMultisite(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApiV3.ClusterMaster, namespaceScopedSecret *corev1.Secret) ([]corev1.EnvVar, error) {
 	var err error
 	reqLogger := log.FromContext(ctx)
 	scopedLog := reqLogger.WithName("Verify if Multisite Indexer Cluster").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
-	mgr := clusterMasterPodManager{log: scopedLog, cr: cr, secrets: namespaceScopedSecret, newSplunkClient: splclient.NewSplunkClient}
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return nil, err
+	}
+	mgr := clusterMasterPodManager{log: scopedLog, cr: cr, secrets: namespaceScopedSecret, newSplunkClient: newSplunkClient}
 	cm := mgr.getClusterMasterClient(cr)
 	clusterInfo, err := cm.GetClusterInfo(false)
 	if err != nil {
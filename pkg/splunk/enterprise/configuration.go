@@ -20,9 +20,12 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
 	"reflect"
 	"strconv"
+	"strings"
+	"time"
 
 	orderedmap "github.com/wk8/go-ordered-map/v2"
 	appsv1 "k8s.io/api/apps/v1"
@@ -916,6 +919,9 @@ func updateSplunkPodTemplateWithConfig(ctx context.Context, client splcommon.Con
 				Namespace: cr.GetNamespace(),
 				Name:      spec.ClusterManagerRef.Name,
 			}
+			if spec.ClusterManagerRef.Namespace != "" {
+				namespacedName.Namespace = spec.ClusterManagerRef.Namespace
+			}
 			managerIdxCluster := &enterpriseApi.ClusterManager{}
 			err := client.Get(ctx, namespacedName, managerIdxCluster)
 			if err != nil {
@@ -926,6 +932,8 @@ func updateSplunkPodTemplateWithConfig(ctx context.Context, client splcommon.Con
 				licenseManagerURL := GetSplunkServiceName(SplunkLicenseManager, managerIdxCluster.Spec.LicenseManagerRef.Name, false)
 				if managerIdxCluster.Spec.LicenseManagerRef.Namespace != "" {
 					licenseManagerURL = splcommon.GetServiceFQDN(managerIdxCluster.Spec.LicenseManagerRef.Namespace, licenseManagerURL)
+				} else if namespacedName.Namespace != cr.GetNamespace() {
+					licenseManagerURL = splcommon.GetServiceFQDN(namespacedName.Namespace, licenseManagerURL)
 				}
 				env = append(env, corev1.EnvVar{
 					Name:  splcommon.LicenseManagerURL,
@@ -935,6 +943,8 @@ func updateSplunkPodTemplateWithConfig(ctx context.Context, client splcommon.Con
 				licenseMasterURL := GetSplunkServiceName(SplunkLicenseMaster, managerIdxCluster.Spec.LicenseMasterRef.Name, false)
 				if managerIdxCluster.Spec.LicenseMasterRef.Namespace != "" {
 					licenseMasterURL = splcommon.GetServiceFQDN(managerIdxCluster.Spec.LicenseMasterRef.Namespace, licenseMasterURL)
+				} else if namespacedName.Namespace != cr.GetNamespace() {
+					licenseMasterURL = splcommon.GetServiceFQDN(namespacedName.Namespace, licenseMasterURL)
 				}
 				env = append(env, corev1.EnvVar{
 					Name:  splcommon.LicenseManagerURL,
@@ -992,7 +1002,7 @@ func updateSplunkPodTemplateWithConfig(ctx context.Context, client splcommon.Con
 	if spec.MonitoringConsoleRef.Name != "" {
 		extraEnv = append(extraEnv, corev1.EnvVar{
 			Name:  "SPLUNK_MONITORING_CONSOLE_REF",
-			Value: spec.MonitoringConsoleRef.Name,
+			Value: getMonitoringConsoleRef(cr.GetNamespace(), spec.MonitoringConsoleRef),
 		})
 	}
 
@@ -1487,6 +1497,11 @@ func validateSplunkAppSources(appFramework *enterpriseApi.AppFrameworkSpec, loca
 			scope = appFramework.Defaults.Scope
 		}
 
+		err := validateAppDependencies(appSrc, scope)
+		if err != nil {
+			return err
+		}
+
 		if _, ok := duplicateAppSourceStorageChecker[scope][vol+appSrc.Location]; ok {
 			return fmt.Errorf("duplicate App Source configured for Volume: %s, and Location: %s combo. Remove the duplicate entry and reapply the configuration", vol, appSrc.Location)
 		}
@@ -1512,6 +1527,35 @@ func validateSplunkAppSources(appFramework *enterpriseApi.AppFrameworkSpec, loca
 	return nil
 }
 
+// validateAppDependencies validates the app dependencies configured for an App source
+func validateAppDependencies(appSrc enterpriseApi.AppSourceSpec, scope string) error {
+	if len(appSrc.AppDependencies) == 0 {
+		return nil
+	}
+
+	if !canAppScopeHaveInstallWorker(scope) {
+		return fmt.Errorf("app dependencies are not supported for App Source: %s with scope %s. Valid scopes are %s or %s", appSrc.Name, scope, enterpriseApi.ScopeLocal, enterpriseApi.ScopePremiumApps)
+	}
+
+	for i, appDependency := range appSrc.AppDependencies {
+		if appDependency.Name == "" {
+			return fmt.Errorf("app name is missing for app dependency at: %d, App Source: %s", i, appSrc.Name)
+		}
+
+		if len(appDependency.DependsOn) == 0 {
+			return fmt.Errorf("dependsOn is missing for app: %s, App Source: %s", appDependency.Name, appSrc.Name)
+		}
+
+		for _, dependency := range appDependency.DependsOn {
+			if dependency == "" || dependency == appDependency.Name {
+				return fmt.Errorf("invalid dependency %q for app: %s, App Source: %s", dependency, appDependency.Name, appSrc.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
 // validatePremiumAppsInputs validates premium app source spec
 func validatePremiumAppsInputs(appSrc enterpriseApi.AppSourceSpec, crKind string) error {
 
@@ -1592,6 +1636,16 @@ func ValidateAppFrameworkSpec(ctx context.Context, appFramework *enterpriseApi.A
 	}
 
 	err = validateSplunkAppSources(appFramework, localScope, crKind)
+	if err != nil {
+		return err
+	}
+
+	err = validateAppPackagePolicy(&appFramework.AppPackagePolicy)
+	if err != nil {
+		return err
+	}
+
+	err = validateMaintenanceWindows(appFramework.MaintenanceWindows)
 	if err == nil {
 		scopedLog.Info("App framework configuration is valid")
 	}
@@ -1599,6 +1653,67 @@ func ValidateAppFrameworkSpec(ctx context.Context, appFramework *enterpriseApi.A
 	return err
 }
 
+// validateAppPackagePolicy validates the app package policy in App Framework spec
+func validateAppPackagePolicy(policy *enterpriseApi.AppPackagePolicySpec) error {
+	duplicateRuleChecker := make(map[string]bool)
+
+	for i, rule := range policy.Rules {
+		if rule.Name == "" {
+			return fmt.Errorf("app package rule name is missing for rule at: %d", i)
+		}
+
+		if _, ok := duplicateRuleChecker[rule.Name]; ok {
+			return fmt.Errorf("multiple app package rules with the name %s is not allowed", rule.Name)
+		}
+		duplicateRuleChecker[rule.Name] = true
+
+		if len(rule.ForbiddenPaths) == 0 && len(rule.ForbiddenStanzas) == 0 {
+			return fmt.Errorf("app package rule %s should have either forbiddenPaths or forbiddenStanzas", rule.Name)
+		}
+
+		for _, pattern := range rule.ForbiddenPaths {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return fmt.Errorf("invalid forbidden path %s for app package rule %s. error: %v", pattern, rule.Name, err)
+			}
+		}
+
+		for _, stanza := range rule.ForbiddenStanzas {
+			if stanza.ConfFile == "" || strings.Contains(stanza.ConfFile, "/") {
+				return fmt.Errorf("invalid conf file name %q for app package rule %s", stanza.ConfFile, rule.Name)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateMaintenanceWindows validates the maintenance windows in App Framework spec
+func validateMaintenanceWindows(windows []enterpriseApi.MaintenanceWindowSpec) error {
+	for i := range windows {
+		window, err := parseMaintenanceWindow(&windows[i])
+		if err != nil {
+			return fmt.Errorf("invalid maintenance window at: %d. error: %v", i, err)
+		}
+
+		// a schedule like Feb 31st would hold the work forever
+		if window.nextStart(time.Now()).IsZero() {
+			return fmt.Errorf("invalid maintenance window at: %d. error: schedule %q does not fire within %d years", i, windows[i].Schedule, maxMaintenanceWindowLookAheadYears)
+		}
+	}
+
+	return nil
+}
+
+// validateBundlePushPolicy validates the restart windows of the bundle push policy
+func validateBundlePushPolicy(policy *enterpriseApi.BundlePushPolicySpec) error {
+	err := validateMaintenanceWindows(policy.RestartWindows)
+	if err != nil {
+		return fmt.Errorf("invalid bundlePushPolicy restartWindows. %v", err)
+	}
+
+	return nil
+}
+
 // validateRemoteVolumeSpec validates the Remote storage volume spec
 func validateRemoteVolumeSpec(ctx context.Context, volList []enterpriseApi.VolumeSpec, isAppFramework bool) error {
 
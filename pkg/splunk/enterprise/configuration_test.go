@@ -666,6 +666,108 @@ func TestValidatePremiumAppsInputs(t *testing.T) {
 	}
 }
 
+func TestValidateAppDependencies(t *testing.T) {
+	appSrcSpec := enterpriseApi.AppSourceSpec{
+		Name: "appSrc1",
+		AppDependencies: []enterpriseApi.AppDependencySpec{
+			{Name: "app2.tgz", DependsOn: []string{"Splunk_SA_CIM"}},
+		},
+	}
+
+	// Valid case
+	err := validateAppDependencies(appSrcSpec, enterpriseApi.ScopeLocal)
+	if err != nil {
+		t.Errorf("Should pass, valid config. error: %v", err)
+	}
+
+	// cluster scoped apps are not installed by install workers
+	err = validateAppDependencies(appSrcSpec, enterpriseApi.ScopeCluster)
+	if err == nil {
+		t.Errorf("Expected to see an error for app dependencies with cluster scope")
+	}
+
+	// app name is mandatory
+	appSrcSpec.AppDependencies[0].Name = ""
+	err = validateAppDependencies(appSrcSpec, enterpriseApi.ScopeLocal)
+	if err == nil {
+		t.Errorf("Expected to see an error for missing app name")
+	}
+
+	// empty dependsOn list
+	appSrcSpec.AppDependencies[0].Name = "app2.tgz"
+	appSrcSpec.AppDependencies[0].DependsOn = nil
+	err = validateAppDependencies(appSrcSpec, enterpriseApi.ScopeLocal)
+	if err == nil {
+		t.Errorf("Expected to see an error for missing dependsOn")
+	}
+
+	// app can not depend on itself
+	appSrcSpec.AppDependencies[0].DependsOn = []string{"app2.tgz"}
+	err = validateAppDependencies(appSrcSpec, enterpriseApi.ScopeLocal)
+	if err == nil {
+		t.Errorf("Expected to see an error for self dependency")
+	}
+}
+
+func TestValidateAppPackagePolicy(t *testing.T) {
+	policy := enterpriseApi.AppPackagePolicySpec{
+		Rules: []enterpriseApi.AppPackageRuleSpec{
+			{
+				Name:             "no-scripted-inputs",
+				Kinds:            []string{"SearchHeadCluster"},
+				ForbiddenStanzas: []enterpriseApi.AppConfStanzaSpec{{ConfFile: "inputs.conf", StanzaPrefix: "script://"}},
+			},
+			{
+				Name:           "no-binaries",
+				ForbiddenPaths: []string{"bin/*.exe"},
+			},
+		},
+	}
+
+	// Valid case
+	err := validateAppPackagePolicy(&policy)
+	if err != nil {
+		t.Errorf("Should pass, valid config. error: %v", err)
+	}
+
+	// duplicate rule name
+	policy.Rules[1].Name = "no-scripted-inputs"
+	err = validateAppPackagePolicy(&policy)
+	if err == nil {
+		t.Errorf("Expected to see an error for duplicate rule names")
+	}
+
+	// missing rule name
+	policy.Rules[1].Name = ""
+	err = validateAppPackagePolicy(&policy)
+	if err == nil {
+		t.Errorf("Expected to see an error for missing rule name")
+	}
+
+	// invalid pattern
+	policy.Rules[1].Name = "no-binaries"
+	policy.Rules[1].ForbiddenPaths = []string{"bin/["}
+	err = validateAppPackagePolicy(&policy)
+	if err == nil {
+		t.Errorf("Expected to see an error for invalid forbidden path")
+	}
+
+	// rule without any forbidden content
+	policy.Rules[1].ForbiddenPaths = nil
+	err = validateAppPackagePolicy(&policy)
+	if err == nil {
+		t.Errorf("Expected to see an error for a rule without forbidden content")
+	}
+
+	// invalid conf file
+	policy.Rules = policy.Rules[:1]
+	policy.Rules[0].ForbiddenStanzas[0].ConfFile = "../inputs.conf"
+	err = validateAppPackagePolicy(&policy)
+	if err == nil {
+		t.Errorf("Expected to see an error for invalid conf file")
+	}
+}
+
 func TestValidateAppFrameworkSpec(t *testing.T) {
 	var err error
 	ctx := context.TODO()
@@ -1752,3 +1854,54 @@ func TestValidateLivenessProbe(t *testing.T) {
 		t.Errorf("Unexpected error when less than deault values passed for livenessProbe InitialDelaySeconds %d, TimeoutSeconds %d, PeriodSeconds %d. Error %s", livenessProbe.InitialDelaySeconds, livenessProbe.TimeoutSeconds, livenessProbe.PeriodSeconds, err)
 	}
 }
+
+func TestValidateMaintenanceWindows(t *testing.T) {
+	windows := []enterpriseApi.MaintenanceWindowSpec{
+		{Schedule: "0 1 * * 6", DurationMinutes: 120, TimeZone: "Europe/Berlin"},
+		{Schedule: "30 22 1-7 * *", DurationMinutes: 60},
+	}
+
+	// Valid case
+	err := validateMaintenanceWindows(windows)
+	if err != nil {
+		t.Errorf("Should pass, valid config. error: %v", err)
+	}
+
+	// invalid schedule
+	windows[1].Schedule = "30 25 * * *"
+	err = validateMaintenanceWindows(windows)
+	if err == nil {
+		t.Errorf("Expected to see an error for invalid schedule")
+	}
+
+	// invalid time zone
+	windows[1].Schedule = "30 22 * * *"
+	windows[1].TimeZone = "Mars/Olympus"
+	err = validateMaintenanceWindows(windows)
+	if err == nil {
+		t.Errorf("Expected to see an error for invalid time zone")
+	}
+
+	// missing duration
+	windows[1].TimeZone = ""
+	windows[1].DurationMinutes = 0
+	err = validateMaintenanceWindows(windows)
+	if err == nil {
+		t.Errorf("Expected to see an error for missing duration")
+	}
+
+	// schedule which never fires
+	windows[1].DurationMinutes = 60
+	windows[1].Schedule = "0 0 31 2 *"
+	err = validateMaintenanceWindows(windows)
+	if err == nil {
+		t.Errorf("Expected to see an error for a schedule which never fires")
+	}
+
+	// leap day
+	windows[1].Schedule = "0 0 29 2 *"
+	err = validateMaintenanceWindows(windows)
+	if err != nil {
+		t.Errorf("Should pass, leap day schedule. error: %v", err)
+	}
+}
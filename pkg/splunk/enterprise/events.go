@@ -70,6 +70,10 @@ func (k *K8EventPublisher) publishEvent(ctx context.Context, eventType, reason,
 		event = v.NewEvent(eventType, reason, message)
 	case *enterpriseApi.SearchHeadCluster:
 		event = v.NewEvent(eventType, reason, message)
+	case *enterpriseApi.SplunkBackup:
+		event = v.NewEvent(eventType, reason, message)
+	case *enterpriseApi.SplunkRestore:
+		event = v.NewEvent(eventType, reason, message)
 	default:
 		return
 	}
@@ -23,6 +23,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
 
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
 	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
 	splctrl "github.com/splunk/splunk-operator/pkg/splunk/controller"
 )
@@ -50,6 +51,12 @@ func DeleteSplunkPvc(ctx context.Context, cr splcommon.MetaObject, c splcommon.C
 		components = append(components, "search-head", "deployer")
 	case "IndexerCluster":
 		components = append(components, "indexer")
+		// the PVCs of the sites of a multisite indexer cluster are named after the site
+		if idxc, ok := cr.(*enterpriseApi.IndexerCluster); ok {
+			for _, site := range idxc.Spec.Sites {
+				components = append(components, fmt.Sprintf("%s-indexer", site.Name))
+			}
+		}
 	case "ClusterManager":
 		components = append(components, "cluster-manager")
 	case "ClusterMaster":
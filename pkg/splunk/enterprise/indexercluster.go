@@ -16,6 +16,7 @@
 package enterprise
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -35,6 +36,8 @@ import (
 	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	rclient "sigs.k8s.io/controller-runtime/pkg/client"
@@ -60,7 +63,7 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 
 	var err error
 	// Initialize phase
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 
 	// Update the CR Status
 	defer updateCRStatus(ctx, client, cr, &err)
@@ -87,6 +90,14 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 		cr.Status.IdxcPasswordChangedSecrets = make(map[string]bool)
 	}
 
+	// share the idxc_secret of a cluster manager located in another namespace
+	err = applyClusterManagerIdxcSecret(ctx, client, cr)
+	if err != nil {
+		scopedLog.Error(err, "propagation of the idxc_secret of the cluster manager failed", "error", err.Error())
+		eventPublisher.Warning(ctx, "applyClusterManagerIdxcSecret", fmt.Sprintf("propagation of the idxc_secret of the cluster manager failed with error %s", err.Error()))
+		return result, err
+	}
+
 	// create or update general config resources
 	namespaceScopedSecret, err := ApplySplunkConfig(ctx, client, cr, cr.Spec.CommonSplunkSpec, SplunkIndexer)
 	if err != nil {
@@ -96,7 +107,7 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 	}
 
 	namespacedName := types.NamespacedName{
-		Namespace: cr.GetNamespace(),
+		Namespace: getClusterManagerNamespace(cr),
 		Name:      cr.Spec.ClusterManagerRef.Name,
 	}
 	managerIdxCluster := &enterpriseApi.ClusterManager{}
@@ -114,7 +125,11 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 		cr.Status.ClusterManagerPhase = enterpriseApi.PhaseError
 	}
 
-	mgr := newIndexerClusterPodManager(scopedLog, cr, namespaceScopedSecret, splclient.NewSplunkClient)
+	newSplunkClient, err := getSplunkClientFunc(ctx, client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+	mgr := newIndexerClusterPodManager(scopedLog, cr, namespaceScopedSecret, newSplunkClient)
 	// Check if we have configured enough number(<= RF) of replicas
 	if mgr.cr.Status.ClusterManagerPhase == enterpriseApi.PhaseReady {
 		err = VerifyRFPeers(ctx, mgr, client)
@@ -126,6 +141,7 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
+		deleteIndexerClusterMetrics(cr)
 		DeleteOwnerReferencesForResources(ctx, client, cr, nil, SplunkIndexer)
 		terminating, err := splctrl.CheckForDeletion(ctx, cr, client)
 		if terminating && err != nil { // don't bother if no error, since it will just be removed immmediately after
@@ -140,97 +156,150 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 		return result, err
 	}
 	// create or update a headless service for indexer cluster
-	err = splctrl.ApplyService(ctx, client, getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, true))
+	err = splctrl.ApplyService(ctx, client, getIndexerClusterService(ctx, cr, true))
 	if err != nil {
 		eventPublisher.Warning(ctx, "ApplyService", fmt.Sprintf("create/update headless service for indexer cluster failed %s", err.Error()))
 		return result, err
 	}
 
 	// create or update a regular service for indexer cluster (ingestion)
-	err = splctrl.ApplyService(ctx, client, getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, false))
+	err = splctrl.ApplyService(ctx, client, getIndexerClusterService(ctx, cr, false))
 	if err != nil {
 		eventPublisher.Warning(ctx, "ApplyService", fmt.Sprintf("create/update service for indexer cluster failed %s", err.Error()))
 		return result, err
 	}
 
-	// create or update statefulset for the indexers
-	statefulSet, err := getIndexerStatefulSet(ctx, client, cr)
-	if err != nil {
-		eventPublisher.Warning(ctx, "getIndexerStatefulSet", fmt.Sprintf("get indexer stateful set failed %s", err.Error()))
-		return result, err
-	}
-
-	// Note:
-	// This is a temporary fix for CSPL-1880. Splunk enterprise 9.0.0 fails when we migrate from 8.2.6.
-	// Splunk 9.0.0 bundle push uses encryption while transferring data. If any of the
-	// splunk instances were not able to support this option, then cluster manager fails to transfer, this leads
-	// to splunkd restart at the peer level. For more information refer
-	// https://splunk.atlassian.net/browse/SPL-223386?jql=text%20~%20%22The%20downloaded%20bundle%20checksum%20doesn%27t%20match%20the%20activeBundleChecksum%22
-	// On Operator side we have set statefulset update strategy to OnDelete, so pods need to be
-	// deleted by operator manually.  Before deleting the pod, operator controller code tries to decommission
-	// the splunk instance, but splunkd is not running due to above splunk enterprise 9.0.0 issue. So controller
-	// fail and returns. This goes on in a loop and we always try the same pod instance and rest of the replicas
-	// are still in older version
-	// As a temporary fix for 9.0.0 , if the image version do not  match with pod image version we delete the
-	// splunk statefulset for indexer
+	// snapshots of the volumes of a single site indexer cluster, taken in maintenance mode of the cluster manager
+	snapshotTarget := &volumeSnapshotTarget{
+		cr:                      cr,
+		commonSpec:              &cr.Spec.CommonSplunkSpec,
+		spec:                    &cr.Spec.Snapshots,
+		status:                  &cr.Status.Snapshots,
+		instanceType:            SplunkIndexer,
+		replicas:                cr.Spec.Replicas,
+		getClusterManagerClient: mgr.getClusterManagerClient,
+	}
+
+	// upgrade gates of the indexer cluster, for a single site and for all the sites
+	applyUpgradeGates := func() (bool, error) {
+		// check if the IndexerCluster is ready for version upgrade
+		cr.Kind = "IndexerCluster"
+		continueReconcile, err := UpgradePathValidation(ctx, client, cr, cr.Spec.CommonSplunkSpec, &mgr)
+		if err != nil || !continueReconcile {
+			return false, err
+		}
 
-	var phase enterpriseApi.Phase
-	versionUpgrade := false
-	// get all the pods in the namespace
-	statefulsetPods := &corev1.PodList{}
-	opts := []rclient.ListOption{
-		rclient.InNamespace(cr.GetNamespace()),
-	}
+		// revert the version upgrade when the pods are not ready within the health deadline
+		continueReconcile, err = applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+		if err != nil || !continueReconcile {
+			return false, err
+		}
 
-	err = client.List(ctx, statefulsetPods, opts...)
-	if err != nil {
-		return result, nil
+		// hold the version upgrade until the upgrade policy allows it
+		return applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, lastPhase, &cr.Status.Upgrade)
 	}
 
-	// filter the pods which are owned by statefulset
-	for _, v := range statefulsetPods.Items {
-		for _, owner := range v.GetOwnerReferences() {
-			if owner.UID == statefulSet.UID {
-				// get the pod image name
-				if imageUpdatedTo9(v.Spec.Containers[0].Image, cr.Spec.Image) {
-					// image do not match that means its image upgrade
-					versionUpgrade = true
-					break
-				}
-			}
+	var phase enterpriseApi.Phase
+	if len(cr.Spec.Sites) > 0 {
+		var continueReconcile bool
+		continueReconcile, err = applyUpgradeGates()
+		if err != nil || !continueReconcile {
+			return result, err
 		}
-	}
-
-	// check if the IndexerCluster is ready for version upgrade
-	cr.Kind = "IndexerCluster"
-	continueReconcile, err := UpgradePathValidation(ctx, client, cr, cr.Spec.CommonSplunkSpec, &mgr)
-	if err != nil || !continueReconcile {
-		return result, err
-	}
 
-	// check if version upgrade is set
-	if !versionUpgrade {
-		phase, err = mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
+		// create or update a statefulset per site for a multisite indexer cluster
+		phase, err = applyIndexerClusterSites(ctx, client, cr, namespaceScopedSecret, scopedLog)
 		if err != nil {
-			eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("update statefulset failed %s", err.Error()))
+			eventPublisher.Warning(ctx, "applyIndexerClusterSites", fmt.Sprintf("update indexer cluster sites failed %s", err.Error()))
 			return result, err
 		}
 	} else {
-		// Delete the statefulset and recreate new one
-		err = client.Delete(ctx, statefulSet)
+		// create or update statefulset for the indexers
+		var statefulSet *appsv1.StatefulSet
+		statefulSet, err = getIndexerStatefulSet(ctx, client, cr)
 		if err != nil {
-			eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("version mismatch for indexer cluster and indexer container, delete statefulset failed. Error=%s", err.Error()))
-			eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("%s-%s, %s-%s", "indexer-image", cr.Spec.Image, "container-image", statefulSet.Spec.Template.Spec.Containers[0].Image))
+			eventPublisher.Warning(ctx, "getIndexerStatefulSet", fmt.Sprintf("get indexer stateful set failed %s", err.Error()))
 			return result, err
 		}
-		time.Sleep(1 * time.Second)
-		// since we are creating new statefulset, setting resourceVersion to ""
-		statefulSet.ResourceVersion = ""
-		phase, err = mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
+
+		// Note:
+		// This is a temporary fix for CSPL-1880. Splunk enterprise 9.0.0 fails when we migrate from 8.2.6.
+		// Splunk 9.0.0 bundle push uses encryption while transferring data. If any of the
+		// splunk instances were not able to support this option, then cluster manager fails to transfer, this leads
+		// to splunkd restart at the peer level. For more information refer
+		// https://splunk.atlassian.net/browse/SPL-223386?jql=text%20~%20%22The%20downloaded%20bundle%20checksum%20doesn%27t%20match%20the%20activeBundleChecksum%22
+		// On Operator side we have set statefulset update strategy to OnDelete, so pods need to be
+		// deleted by operator manually.  Before deleting the pod, operator controller code tries to decommission
+		// the splunk instance, but splunkd is not running due to above splunk enterprise 9.0.0 issue. So controller
+		// fail and returns. This goes on in a loop and we always try the same pod instance and rest of the replicas
+		// are still in older version
+		// As a temporary fix for 9.0.0 , if the image version do not  match with pod image version we delete the
+		// splunk statefulset for indexer
+
+		versionUpgrade := false
+		// get all the pods in the namespace
+		statefulsetPods := &corev1.PodList{}
+		opts := []rclient.ListOption{
+			rclient.InNamespace(cr.GetNamespace()),
+		}
+
+		err = client.List(ctx, statefulsetPods, opts...)
 		if err != nil {
-			eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("update statefulset failed %s", err.Error()))
+			return result, nil
+		}
+
+		// filter the pods which are owned by statefulset
+		for _, v := range statefulsetPods.Items {
+			for _, owner := range v.GetOwnerReferences() {
+				if owner.UID == statefulSet.UID {
+					// get the pod image name
+					if imageUpdatedTo9(v.Spec.Containers[0].Image, cr.Spec.Image) {
+						// image do not match that means its image upgrade
+						versionUpgrade = true
+						break
+					}
+				}
+			}
+		}
+
+		var continueReconcile bool
+		continueReconcile, err = applyUpgradeGates()
+		if err != nil || !continueReconcile {
 			return result, err
 		}
+
+		// provision the volumes of a new indexer cluster from a snapshot set. Snapshots are not supported by an
+		// indexer cluster with sites
+		err = applyVolumeSnapshotRestore(ctx, client, snapshotTarget, statefulSet)
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyVolumeSnapshotRestore", fmt.Sprintf("provision volumes from snapshot set failed %s", err.Error()))
+			return result, err
+		}
+
+		// check if version upgrade is set
+		if !versionUpgrade {
+			phase, err = mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
+			if err != nil {
+				eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("update statefulset failed %s", err.Error()))
+				return result, err
+			}
+		} else {
+			// Delete the statefulset and recreate new one
+			err = client.Delete(ctx, statefulSet)
+			if err != nil {
+				eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("version mismatch for indexer cluster and indexer container, delete statefulset failed. Error=%s", err.Error()))
+				eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("%s-%s, %s-%s", "indexer-image", cr.Spec.Image, "container-image", statefulSet.Spec.Template.Spec.Containers[0].Image))
+				return result, err
+			}
+			time.Sleep(1 * time.Second)
+			// since we are creating new statefulset, setting resourceVersion to ""
+			statefulSet.ResourceVersion = ""
+			phase, err = mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
+			if err != nil {
+				eventPublisher.Warning(ctx, "UpdateManager", fmt.Sprintf("update statefulset failed %s", err.Error()))
+				return result, err
+			}
+		}
 	}
 	cr.Status.Phase = phase
 
@@ -244,18 +313,21 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 			return result, err
 		}
 		if cmMonitoringConsoleConfigRef != "" {
-			namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: GetSplunkStatefulsetName(SplunkMonitoringConsole, cmMonitoringConsoleConfigRef)}
+			mcNamespace, mcName := parseMonitoringConsoleRef(cr.GetNamespace(), cmMonitoringConsoleConfigRef)
+			namespacedName := types.NamespacedName{Namespace: mcNamespace, Name: GetSplunkStatefulsetName(SplunkMonitoringConsole, mcName)}
 			_, err := splctrl.GetStatefulSetByName(ctx, client, namespacedName)
 			//if MC pod already exists
 			if err == nil {
-				c := mgr.getMonitoringConsoleClient(cr, cmMonitoringConsoleConfigRef)
-				err := c.AutomateMCApplyChanges()
+				c, err := mgr.getMonitoringConsoleClient(ctx, client, cr, cmMonitoringConsoleConfigRef)
+				if err == nil {
+					err = c.AutomateMCApplyChanges()
+				}
 				if err != nil {
 					eventPublisher.Warning(ctx, "AutomateMCApplyChanges", fmt.Sprintf("get monitoring console client failed %s", err.Error()))
 					return result, err
 				}
 			}
-			if len(cr.Spec.MonitoringConsoleRef.Name) > 0 && (cr.Spec.MonitoringConsoleRef.Name != cmMonitoringConsoleConfigRef) {
+			if len(cr.Spec.MonitoringConsoleRef.Name) > 0 && (getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef) != cmMonitoringConsoleConfigRef) {
 				scopedLog.Info("Indexer Cluster CR should not specify monitoringConsoleRef and if specified, should be similar to cluster manager spec")
 			}
 		}
@@ -267,7 +339,7 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 				return result, errors.New("empty cluster manager reference")
 			}
 			cmPodName := fmt.Sprintf("splunk-%s-cluster-manager-%s", managerIdxcName, "0")
-			podExecClient := splutil.GetPodExecClient(client, cr, cmPodName)
+			podExecClient := getClusterManagerPodExecClient(client, cr, cmPodName)
 			// Disable maintenance mode
 			err = SetClusterMaintenanceMode(ctx, client, cr, false, cmPodName, podExecClient)
 			if err != nil {
@@ -280,19 +352,32 @@ func ApplyIndexerClusterManager(ctx context.Context, client splcommon.Controller
 		cr.Status.IndexerSecretChanged = []bool{}
 		cr.Status.NamespaceSecretResourceVersion = namespaceScopedSecret.ObjectMeta.ResourceVersion
 		cr.Status.IdxcPasswordChangedSecrets = make(map[string]bool)
+		for i := range cr.Status.Sites {
+			cr.Status.Sites[i].IndexerSecretChanged = nil
+		}
 
 		result.Requeue = false
-		// Set indexer cluster CR as owner reference for clustermanager
-		scopedLog.Info("Setting indexer cluster as owner for cluster manager")
-		if len(cr.Spec.ClusterManagerRef.Name) > 0 {
-			namespacedName = types.NamespacedName{Namespace: cr.GetNamespace(), Name: GetSplunkStatefulsetName(SplunkClusterManager, cr.Spec.ClusterManagerRef.Name)}
+		// Set indexer cluster CR as owner reference for clustermanager, owner references can't cross namespaces
+		if getClusterManagerNamespace(cr) == cr.GetNamespace() {
+			scopedLog.Info("Setting indexer cluster as owner for cluster manager")
+			if len(cr.Spec.ClusterManagerRef.Name) > 0 {
+				namespacedName = types.NamespacedName{Namespace: cr.GetNamespace(), Name: GetSplunkStatefulsetName(SplunkClusterManager, cr.Spec.ClusterManagerRef.Name)}
+			}
+			err = splctrl.SetStatefulSetOwnerRef(ctx, client, cr, namespacedName)
+			if err != nil {
+				eventPublisher.Warning(ctx, "SetStatefulSetOwnerRef", fmt.Sprintf("set stateful set owner reference failed %s", err.Error()))
+				result.Requeue = true
+				return result, err
+			}
 		}
-		err = splctrl.SetStatefulSetOwnerRef(ctx, client, cr, namespacedName)
+
+		// take the scheduled snapshot sets of the volumes
+		requeueAfter, err := applyVolumeSnapshots(ctx, client, snapshotTarget, time.Now())
 		if err != nil {
-			eventPublisher.Warning(ctx, "SetStatefulSetOwnerRef", fmt.Sprintf("set stateful set owner reference failed %s", err.Error()))
-			result.Requeue = true
+			eventPublisher.Warning(ctx, "applyVolumeSnapshots", fmt.Sprintf("apply volume snapshots failed %s", err.Error()))
 			return result, err
 		}
+		setVolumeSnapshotRequeueTime(&result, requeueAfter)
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
@@ -322,7 +407,7 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 	}
 
 	// updates status after function completes
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 	cr.Status.ClusterMasterPhase = enterpriseApi.PhaseError
 	cr.Status.Replicas = cr.Spec.Replicas
 	cr.Status.Selector = fmt.Sprintf("app.kubernetes.io/instance=splunk-%s-indexer", cr.GetName())
@@ -365,7 +450,11 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 		cr.Status.ClusterMasterPhase = enterpriseApi.PhaseError
 	}
 
-	mgr := newIndexerClusterPodManager(scopedLog, cr, namespaceScopedSecret, splclient.NewSplunkClient)
+	newSplunkClient, err := getSplunkClientFunc(ctx, client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+	mgr := newIndexerClusterPodManager(scopedLog, cr, namespaceScopedSecret, newSplunkClient)
 	// Check if we have configured enough number(<= RF) of replicas
 	if mgr.cr.Status.ClusterMasterPhase == enterpriseApi.PhaseReady {
 		err = VerifyRFPeers(ctx, mgr, client)
@@ -460,6 +549,34 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 		return result, err
 	}
 
+	// revert the version upgrade when the pods are not ready within the health deadline
+	continueReconcile, err = applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// hold the version upgrade until the upgrade policy allows it
+	continueReconcile, err = applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, lastPhase, &cr.Status.Upgrade)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// provision the volumes of a new indexer cluster from a snapshot set
+	snapshotTarget := &volumeSnapshotTarget{
+		cr:                      cr,
+		commonSpec:              &cr.Spec.CommonSplunkSpec,
+		spec:                    &cr.Spec.Snapshots,
+		status:                  &cr.Status.Snapshots,
+		instanceType:            SplunkIndexer,
+		replicas:                cr.Spec.Replicas,
+		getClusterManagerClient: mgr.getClusterManagerClient,
+	}
+	err = applyVolumeSnapshotRestore(ctx, client, snapshotTarget, statefulSet)
+	if err != nil {
+		eventPublisher.Warning(ctx, "applyVolumeSnapshotRestore", fmt.Sprintf("provision volumes from snapshot set failed %s", err.Error()))
+		return result, err
+	}
+
 	// check if version upgrade is set
 	if !versionUpgrade {
 		phase, err = mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
@@ -496,18 +613,21 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 			return result, err
 		}
 		if cmMonitoringConsoleConfigRef != "" {
-			namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: GetSplunkStatefulsetName(SplunkMonitoringConsole, cmMonitoringConsoleConfigRef)}
+			mcNamespace, mcName := parseMonitoringConsoleRef(cr.GetNamespace(), cmMonitoringConsoleConfigRef)
+			namespacedName := types.NamespacedName{Namespace: mcNamespace, Name: GetSplunkStatefulsetName(SplunkMonitoringConsole, mcName)}
 			_, err := splctrl.GetStatefulSetByName(ctx, client, namespacedName)
 			//if MC pod already exists
 			if err == nil {
-				c := mgr.getMonitoringConsoleClient(cr, cmMonitoringConsoleConfigRef)
-				err := c.AutomateMCApplyChanges()
+				c, err := mgr.getMonitoringConsoleClient(ctx, client, cr, cmMonitoringConsoleConfigRef)
+				if err == nil {
+					err = c.AutomateMCApplyChanges()
+				}
 				if err != nil {
 					eventPublisher.Warning(ctx, "AutomateMCApplyChanges", fmt.Sprintf("get monitoring console client failed %s", err.Error()))
 					return result, err
 				}
 			}
-			if len(cr.Spec.MonitoringConsoleRef.Name) > 0 && (cr.Spec.MonitoringConsoleRef.Name != cmMonitoringConsoleConfigRef) {
+			if len(cr.Spec.MonitoringConsoleRef.Name) > 0 && (getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef) != cmMonitoringConsoleConfigRef) {
 				scopedLog.Info("Indexer Cluster CR should not specify monitoringConsoleRef and if specified, should be similar to cluster master spec")
 			}
 		}
@@ -519,7 +639,7 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 				return result, errors.New("empty cluster master reference")
 			}
 			cmPodName := fmt.Sprintf("splunk-%s-cluster-master-%s", managerIdxcName, "0")
-			podExecClient := splutil.GetPodExecClient(client, cr, cmPodName)
+			podExecClient := getClusterManagerPodExecClient(client, cr, cmPodName)
 			// Disable maintenance mode
 			err = SetClusterMaintenanceMode(ctx, client, cr, false, cmPodName, podExecClient)
 			if err != nil {
@@ -543,6 +663,14 @@ func ApplyIndexerCluster(ctx context.Context, client splcommon.ControllerClient,
 			result.Requeue = true
 			return result, err
 		}
+
+		// take the scheduled snapshot sets of the volumes
+		requeueAfter, err := applyVolumeSnapshots(ctx, client, snapshotTarget, time.Now())
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyVolumeSnapshots", fmt.Sprintf("apply volume snapshots failed %s", err.Error()))
+			return result, err
+		}
+		setVolumeSnapshotRequeueTime(&result, requeueAfter)
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
@@ -564,6 +692,12 @@ type indexerClusterPodManager struct {
 	cr              *enterpriseApi.IndexerCluster
 	secrets         *corev1.Secret
 	newSplunkClient func(managementURI, username, password string) *splclient.SplunkClient
+
+	// reason a replicas change is held, as reported by the cluster manager
+	busyReason string
+
+	// site whose storage migration is in progress, which holds the storage migration of the other sites
+	storageMigrationBlocker string
 }
 
 // newIndexerClusterPodManager function to create pod manager this is added to write unit test case
@@ -577,15 +711,25 @@ var newIndexerClusterPodManager = func(log logr.Logger, cr *enterpriseApi.Indexe
 }
 
 // getMonitoringConsoleClient for indexerClusterPodManager returns a SplunkClient for monitoring console
-func (mgr *indexerClusterPodManager) getMonitoringConsoleClient(cr *enterpriseApi.IndexerCluster, cmMonitoringConsoleConfigRef string) *splclient.SplunkClient {
-	fqdnName := splcommon.GetServiceFQDN(cr.GetNamespace(), GetSplunkServiceName(SplunkMonitoringConsole, cmMonitoringConsoleConfigRef, false))
-	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(mgr.secrets.Data["password"]))
+func (mgr *indexerClusterPodManager) getMonitoringConsoleClient(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster, cmMonitoringConsoleConfigRef string) (*splclient.SplunkClient, error) {
+	mcNamespace, mcName := parseMonitoringConsoleRef(cr.GetNamespace(), cmMonitoringConsoleConfigRef)
+	fqdnName := splcommon.GetServiceFQDN(mcNamespace, GetSplunkServiceName(SplunkMonitoringConsole, mcName, false))
+	secrets := mgr.secrets
+	if mcNamespace != cr.GetNamespace() {
+		// a monitoring console in another namespace uses the admin password of its own namespace
+		var err error
+		secrets, err = splutil.GetNamespaceScopedSecret(ctx, c, mcNamespace)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", string(secrets.Data["password"])), nil
 }
 
 // SetClusterMaintenanceMode enables/disables cluster maintenance mode
 func SetClusterMaintenanceMode(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster, enable bool, cmPodName string, podExecClient splutil.PodExecClientImpl) error {
 	// Retrieve admin password from Pod
-	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, cmPodName, cr.GetNamespace(), "password")
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, cmPodName, getClusterManagerNamespace(cr), "password")
 	if err != nil {
 		return err
 	}
@@ -771,17 +915,23 @@ func (mgr *indexerClusterPodManager) Update(ctx context.Context, c splcommon.Con
 	}
 	// update statefulset, if necessary
 	if mgr.cr.Status.ClusterManagerPhase == enterpriseApi.PhaseReady || mgr.cr.Status.ClusterMasterPhase == enterpriseApi.PhaseReady {
-		_, err = splctrl.ApplyStatefulSet(ctx, mgr.c, statefulSet)
+		phase, err := splctrl.ApplyStatefulSetWithVolumeResize(ctx, mgr.c, statefulSet, &mgr.cr.Status.VolumeResize)
 		if err != nil {
 			return enterpriseApi.PhaseError, err
 		}
+
+		// wait for the expansion of the peer volumes and the recreation of the statefulset
+		if phase == enterpriseApi.PhaseUpdating && len(mgr.cr.Status.VolumeResize) > 0 {
+			mgr.log.Info("Waiting for the expansion of the peer volumes")
+			return phase, nil
+		}
 	} else {
 		mgr.log.Info("Cluster Manager is not ready yet", "reason ", err)
 	}
 
 	// Get the podExecClient with empty targetPodName.
 	// This will be set inside ApplyIdxcSecret
-	podExecClient := splutil.GetPodExecClient(mgr.c, mgr.cr, "")
+	podExecClient := getClusterManagerPodExecClient(mgr.c, mgr.cr, "")
 	// Check if a recycle of idxc pods is necessary(due to idxc_secret mismatch with CM)
 	err = ApplyIdxcSecret(ctx, mgr, desiredReplicas, podExecClient)
 	if err != nil {
@@ -795,6 +945,32 @@ func (mgr *indexerClusterPodManager) Update(ctx context.Context, c splcommon.Con
 		return enterpriseApi.PhasePending, nil
 	}
 
+	// hold the replicas changes during a bundle push, a decommission or an update, and within the scale cooldown
+	desiredReplicas = getGuardedReplicas(&mgr.cr.Spec.Autoscaling, &mgr.cr.Status.AutoscalingStatus, *statefulSet.Spec.Replicas, desiredReplicas, mgr.busyReason, getIndexerClusterDecommissionReason(mgr.cr))
+
+	// do not retry an aborted scale down, until the replicas are changed
+	desiredReplicas = getScaleDownReplicas(mgr.cr, *statefulSet.Spec.Replicas, desiredReplicas)
+
+	// a decommission is not tracked anymore, once the scale down is reverted
+	decommissionStatus := &mgr.cr.Status.DecommissionStatus
+	if decommissionStatus.Peer != "" && desiredReplicas >= *statefulSet.Spec.Replicas {
+		*decommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{AbortedReplicas: decommissionStatus.AbortedReplicas}
+	}
+
+	// migrate the peer var volumes to a new storage class, one peer at a time
+	phase, handled, err := mgr.migrateStorage(ctx, statefulSet, desiredReplicas)
+	if handled || err != nil {
+		return phase, err
+	}
+
+	// update the peers in batches through the rolling upgrade of the cluster manager
+	if isRollingUpgradeStrategy(mgr.cr) {
+		phase, handled, err := mgr.rollingUpgrade(ctx, statefulSet, desiredReplicas)
+		if handled || err != nil {
+			return phase, err
+		}
+	}
+
 	// manage scaling and updates
 	return splctrl.UpdateStatefulSetPods(ctx, c, statefulSet, mgr, desiredReplicas)
 }
@@ -803,16 +979,19 @@ func (mgr *indexerClusterPodManager) Update(ctx context.Context, c splcommon.Con
 func (mgr *indexerClusterPodManager) PrepareScaleDown(ctx context.Context, n int32) (bool, error) {
 	// first, decommission indexer peer with enforceCounts=true; this will rebalance buckets across other peers
 	complete, err := mgr.decommission(ctx, n, true)
-	if err != nil {
-		return false, err
-	}
-	if !complete {
-		return false, nil
+	if err != nil || !complete {
+		// handle a decommission which takes too long, or is stuck in an unexpected state
+		return false, mgr.checkDecommissionTimeout(ctx, n, err)
 	}
 
 	// next, remove the peer
 	c := mgr.getClusterManagerClient(ctx)
-	return true, c.RemoveIndexerClusterPeer(mgr.cr.Status.Peers[n].ID)
+	err = c.RemoveIndexerClusterPeer(mgr.cr.Status.Peers[n].ID)
+	if err != nil {
+		return false, err
+	}
+	mgr.cr.Status.DecommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{}
+	return true, nil
 }
 
 // PrepareRecycle for indexerClusterPodManager prepares indexer pod to be recycled for updates; it returns true when ready
@@ -912,11 +1091,12 @@ func (mgr *indexerClusterPodManager) getClusterManagerClient(ctx context.Context
 	}
 
 	// Get Fully Qualified Domain Name
-	fqdnName := splcommon.GetServiceFQDN(mgr.cr.GetNamespace(), GetSplunkServiceName(cm, managerIdxcName, false))
+	cmNamespace := getClusterManagerNamespace(mgr.cr)
+	fqdnName := splcommon.GetServiceFQDN(cmNamespace, GetSplunkServiceName(cm, managerIdxcName, false))
 
 	// Retrieve admin password for Pod
 	podName := fmt.Sprintf("splunk-%s-%s-%s", managerIdxcName, cm, "0")
-	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, mgr.c, podName, mgr.cr.GetNamespace(), "password")
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, mgr.c, podName, cmNamespace, "password")
 	if err != nil {
 		scopedLog.Error(err, "Couldn't retrieve the admin password from pod")
 	}
@@ -946,6 +1126,11 @@ func (mgr *indexerClusterPodManager) verifyRFPeers(ctx context.Context, c splcom
 	if err != nil {
 		return fmt.Errorf("could not get cluster info from cluster manager")
 	}
+	// for the sites of a multisite indexer cluster, check site_replication_factor per site
+	if len(mgr.cr.Spec.Sites) > 0 {
+		return mgr.verifySiteRFPeers(clusterInfo)
+	}
+
 	var replicationFactor int32
 	// if it is a multisite indexer cluster, check site_replication_factor
 	if clusterInfo.MultiSite == "true" {
@@ -992,6 +1177,7 @@ func (mgr *indexerClusterPodManager) updateStatus(ctx context.Context, statefulS
 	mgr.cr.Status.IndexingReady = clusterInfo.IndexingReady
 	mgr.cr.Status.ServiceReady = clusterInfo.ServiceReady
 	mgr.cr.Status.MaintenanceMode = clusterInfo.MaintenanceMode
+	mgr.busyReason = mgr.getIndexerClusterBusyReason(clusterInfo)
 
 	// get peer information from cluster manager
 	peers, err := GetClusterManagerPeersCall(ctx, mgr)
@@ -1023,6 +1209,9 @@ func (mgr *indexerClusterPodManager) updateStatus(ctx context.Context, statefulS
 		mgr.cr.Status.Peers = mgr.cr.Status.Peers[:statefulSet.Status.Replicas]
 	}
 
+	// export the load metrics of the peers
+	mgr.updateAutoscalingMetrics(ctx, peers)
+
 	return nil
 }
 
@@ -1049,10 +1238,35 @@ func validateIndexerClusterSpec(ctx context.Context, c splcommon.ControllerClien
 		return fmt.Errorf("IndexerCluster spec should refer to ClusterManager via clusterManagerRef")
 	}
 
-	// Multisite / multipart clusters: can't reference a cluster manager located in another namespace because of Service and Secret limitations
-	if len(cr.Spec.ClusterManagerRef.Namespace) > 0 && cr.Spec.ClusterManagerRef.Namespace != cr.GetNamespace() ||
-		len(cr.Spec.ClusterMasterRef.Namespace) > 0 && cr.Spec.ClusterMasterRef.Namespace != cr.GetNamespace() {
-		return fmt.Errorf("multisite cluster does not support cluster manager to be located in a different namespace")
+	// Multisite / multipart clusters: only a ClusterManager can be located in another namespace, whose Service is
+	// reached through its FQDN and whose idxc_secret is shared with the namespace of the indexer cluster
+	if len(cr.Spec.ClusterManagerRef.Name) == 0 && len(cr.Spec.ClusterMasterRef.Namespace) > 0 && cr.Spec.ClusterMasterRef.Namespace != cr.GetNamespace() {
+		return fmt.Errorf("multisite cluster does not support cluster master to be located in a different namespace, use clusterManagerRef")
+	}
+	err := validateClusterManagerPeerNamespace(ctx, c, cr)
+	if err != nil {
+		return err
+	}
+
+	// Multisite indexer cluster with a StatefulSet per site
+	if len(cr.Spec.Sites) > 0 {
+		err := validateIndexerClusterSites(cr)
+		if err != nil {
+			return err
+		}
+		if cr.Spec.Snapshots.Schedule != "" || cr.Spec.Snapshots.RestoreFrom != "" {
+			return fmt.Errorf("snapshots are not supported by an indexer cluster with sites")
+		}
+	}
+
+	err = validateIndexerClusterSiteNames(ctx, c, cr)
+	if err != nil {
+		return err
+	}
+
+	err = validateVolumeSnapshotSpec(&cr.Spec.Snapshots, &cr.Spec.CommonSplunkSpec, SplunkIndexer)
+	if err != nil {
+		return err
 	}
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
@@ -1073,6 +1287,146 @@ func getIndexerClusterList(ctx context.Context, c splcommon.ControllerClient, cr
 	return objectList, nil
 }
 
+// getClusterManagerNamespace returns the namespace of the cluster manager of an indexer cluster,
+// which can be located in another namespace than the indexer cluster
+func getClusterManagerNamespace(cr *enterpriseApi.IndexerCluster) string {
+	if len(cr.Spec.ClusterManagerRef.Name) > 0 && len(cr.Spec.ClusterManagerRef.Namespace) > 0 {
+		return cr.Spec.ClusterManagerRef.Namespace
+	} else if len(cr.Spec.ClusterManagerRef.Name) == 0 && len(cr.Spec.ClusterMasterRef.Namespace) > 0 {
+		return cr.Spec.ClusterMasterRef.Namespace
+	}
+	return cr.GetNamespace()
+}
+
+// getClusterManagerPodExecClient returns the client running commands on the pod cmPodName of the cluster manager
+// of an indexer cluster, in the namespace of the cluster manager
+func getClusterManagerPodExecClient(c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster, cmPodName string) *splutil.PodExecClient {
+	cmNamespace := getClusterManagerNamespace(cr)
+	if cmNamespace == cr.GetNamespace() {
+		return splutil.GetPodExecClient(c, cr, cmPodName)
+	}
+	cm := &enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      cr.Spec.ClusterManagerRef.Name,
+			Namespace: cmNamespace,
+		},
+	}
+	return splutil.GetPodExecClient(c, cm, cmPodName)
+}
+
+// getClusterManagerIndexerClusterList returns the indexer clusters referring to the cluster manager name located in
+// namespace. The sites of a multisite indexer cluster can be located in the peerNamespaces allowed by the cluster
+// manager, which are listed along with its namespace
+func getClusterManagerIndexerClusterList(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, namespace string, name string, peerNamespaces []string) (enterpriseApi.IndexerClusterList, error) {
+	indexerList := enterpriseApi.IndexerClusterList{}
+	listed := map[string]bool{}
+	for _, listNamespace := range append([]string{namespace}, peerNamespaces...) {
+		if listed[listNamespace] {
+			continue
+		}
+		listed[listNamespace] = true
+
+		objectList, err := getIndexerClusterList(ctx, c, cr, []client.ListOption{client.InNamespace(listNamespace)})
+		if err != nil {
+			return indexerList, err
+		}
+		for _, item := range objectList.Items {
+			if item.Spec.ClusterManagerRef.Name == name && getClusterManagerNamespace(&item) == namespace {
+				indexerList.Items = append(indexerList.Items, item)
+			}
+		}
+	}
+	return indexerList, nil
+}
+
+// getClusterManagerPeerNamespaces returns the namespaces allowed by the cluster manager of an indexer cluster
+// to host its peers, besides its own namespace
+func getClusterManagerPeerNamespaces(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) ([]string, error) {
+	if len(cr.Spec.ClusterManagerRef.Name) == 0 {
+		return nil, nil
+	}
+	cm := &enterpriseApi.ClusterManager{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: getClusterManagerNamespace(cr), Name: cr.Spec.ClusterManagerRef.Name}, cm)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return cm.Spec.AllowedPeerNamespaces, nil
+}
+
+// validateClusterManagerPeerNamespace checks that a cluster manager located in another namespace allows the namespace
+// of the indexer cluster to host its peers
+func validateClusterManagerPeerNamespace(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) error {
+	cmNamespace := getClusterManagerNamespace(cr)
+	if cmNamespace == cr.GetNamespace() {
+		return nil
+	}
+
+	peerNamespaces, err := getClusterManagerPeerNamespaces(ctx, c, cr)
+	if err != nil {
+		return err
+	}
+	for _, namespace := range peerNamespaces {
+		if namespace == cr.GetNamespace() {
+			return nil
+		}
+	}
+	return fmt.Errorf("cluster manager %s/%s does not allow the peers of namespace %s, add it to the allowedPeerNamespaces of the cluster manager", cmNamespace, cr.Spec.ClusterManagerRef.Name, cr.GetNamespace())
+}
+
+// applyClusterManagerIdxcSecret shares the idxc_secret of the namespace scoped secret of a cluster manager located in
+// another namespace with the namespace scoped secret of the indexer cluster, so that its peers can join the cluster.
+// A change of the idxc_secret is then rolled out to the peers as any change of the namespace scoped secret. The
+// idxc_secret of an existing namespace scoped secret is not overwritten, unless it was shared by the cluster manager
+func applyClusterManagerIdxcSecret(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) error {
+	cmNamespace := getClusterManagerNamespace(cr)
+	if cmNamespace == cr.GetNamespace() {
+		return nil
+	}
+
+	err := validateClusterManagerPeerNamespace(ctx, c, cr)
+	if err != nil {
+		return err
+	}
+
+	cmSecret, err := splutil.GetNamespaceScopedSecret(ctx, c, cmNamespace)
+	if err != nil {
+		return err
+	}
+	idxcSecret, ok := cmSecret.Data[splcommon.IdxcSecret]
+	if !ok {
+		return fmt.Errorf(splcommon.SecretTokenNotRetrievable, splcommon.IdxcSecret)
+	}
+
+	namespaceScopedSecret, err := splutil.GetNamespaceScopedSecret(ctx, c, cr.GetNamespace())
+	if err != nil {
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+		namespaceScopedSecret, err = splutil.ApplyNamespaceScopedSecretObject(ctx, c, cr.GetNamespace())
+		if err != nil {
+			return err
+		}
+	} else if namespaceScopedSecret.GetAnnotations()[idxcSecretSourceAnnotation] != cmNamespace {
+		if bytes.Equal(namespaceScopedSecret.Data[splcommon.IdxcSecret], idxcSecret) {
+			return nil
+		}
+		return fmt.Errorf("refusing to overwrite the %s of the namespace scoped secret of namespace %s, which differs from the one of cluster manager %s/%s", splcommon.IdxcSecret, cr.GetNamespace(), cmNamespace, cr.Spec.ClusterManagerRef.Name)
+	}
+
+	if bytes.Equal(namespaceScopedSecret.Data[splcommon.IdxcSecret], idxcSecret) && namespaceScopedSecret.GetAnnotations()[idxcSecretSourceAnnotation] == cmNamespace {
+		return nil
+	}
+	namespaceScopedSecret.Data[splcommon.IdxcSecret] = idxcSecret
+	if namespaceScopedSecret.Annotations == nil {
+		namespaceScopedSecret.Annotations = map[string]string{}
+	}
+	namespaceScopedSecret.Annotations[idxcSecretSourceAnnotation] = cmNamespace
+	return splutil.UpdateResource(ctx, c, namespaceScopedSecret)
+}
+
 // RetrieveCMSpec finds monitoringConsole ref from cm spec
 func RetrieveCMSpec(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) (string, error) {
 	var monitoringConsoleRef string = ""
@@ -1082,15 +1436,21 @@ func RetrieveCMSpec(ctx context.Context, client splcommon.ControllerClient, cr *
 		var cmCR enterpriseApiV3.ClusterMaster
 		err := client.Get(ctx, namespacedName, &cmCR)
 		if err == nil {
-			monitoringConsoleRef = cmCR.Spec.MonitoringConsoleRef.Name
+			monitoringConsoleRef = getMonitoringConsoleRef(cmCR.GetNamespace(), cmCR.Spec.MonitoringConsoleRef)
 			return monitoringConsoleRef, err
 		}
 	} else if len(cr.Spec.ClusterManagerRef.Name) > 0 && len(cr.Spec.ClusterMasterRef.Name) == 0 {
-		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: cr.Spec.ClusterManagerRef.Name}
+		namespacedName := types.NamespacedName{Namespace: getClusterManagerNamespace(cr), Name: cr.Spec.ClusterManagerRef.Name}
 		var cmCR enterpriseApi.ClusterManager
 		err := client.Get(ctx, namespacedName, &cmCR)
 		if err == nil {
-			monitoringConsoleRef = cmCR.Spec.MonitoringConsoleRef.Name
+			// the monitoring console of a cluster manager located in another namespace is referred from the
+			// namespace of the cluster manager
+			ref := cmCR.Spec.MonitoringConsoleRef
+			if ref.Namespace == "" {
+				ref.Namespace = cmCR.GetNamespace()
+			}
+			monitoringConsoleRef = getMonitoringConsoleRef(cr.GetNamespace(), ref)
 			return monitoringConsoleRef, err
 		}
 	}
@@ -1102,7 +1462,7 @@ func getIndexerClusterSortedSiteList(ctx context.Context, c splcommon.Controller
 
 	namespaceList := enterpriseApi.IndexerClusterList{}
 	for _, v := range indexerList.Items {
-		if v.Spec.ClusterManagerRef == ref {
+		if v.Spec.ClusterManagerRef.Name == ref.Name {
 			namespaceList.Items = append(namespaceList.Items, v)
 		}
 	}
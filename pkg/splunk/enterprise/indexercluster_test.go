@@ -22,6 +22,7 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime/debug"
 	"strings"
 	"testing"
@@ -320,7 +321,38 @@ func TestGetMonitoringConsoleClient(t *testing.T) {
 			return c
 		},
 	}
-	mgr.getMonitoringConsoleClient(&current, "cManager")
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	mc, err := mgr.getMonitoringConsoleClient(ctx, c, &current, "cManager")
+	if err != nil {
+		t.Errorf("getMonitoringConsoleClient should not have returned error; err=%v", err)
+	}
+	if mc.ManagementURI != "https://splunk-cManager-monitoring-console-service.test.svc.cluster.local:8089" || mc.Password != "123" {
+		t.Errorf("getMonitoringConsoleClient returned unexpected client %s", mc.ManagementURI)
+	}
+
+	// a monitoring console in another namespace uses the secret of its namespace
+	_, err = mgr.getMonitoringConsoleClient(ctx, c, &current, "platform/mc1")
+	if err == nil {
+		t.Errorf("getMonitoringConsoleClient should have returned error without namespace scoped secret")
+	}
+	platformSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      splcommon.GetNamespaceScopedSecretName("platform"),
+			Namespace: "platform",
+		},
+		Data: map[string][]byte{
+			"password": []byte("456"),
+		},
+	}
+	c.AddObject(platformSecret)
+	mc, err = mgr.getMonitoringConsoleClient(ctx, c, &current, "platform/mc1")
+	if err != nil {
+		t.Errorf("getMonitoringConsoleClient should not have returned error; err=%v", err)
+	}
+	if mc.ManagementURI != "https://splunk-mc1-monitoring-console-service.platform.svc.cluster.local:8089" || mc.Password != "456" {
+		t.Errorf("getMonitoringConsoleClient returned unexpected client %s", mc.ManagementURI)
+	}
 }
 
 func TestGetClusterManagerClient(t *testing.T) {
@@ -711,6 +743,15 @@ func indexerClusterPodManagerUpdateTester(t *testing.T, method string, mockHandl
 }
 
 func TestIndexerClusterPodManager(t *testing.T) {
+	// bucket fixups of a decommission are covered by TestCheckDecommissionTimeout
+	savedGetClusterManagerBucketFixupsCall := GetClusterManagerBucketFixupsCall
+	defer func() {
+		GetClusterManagerBucketFixupsCall = savedGetClusterManagerBucketFixupsCall
+	}()
+	GetClusterManagerBucketFixupsCall = func(ctx context.Context, mgr *indexerClusterPodManager) (int64, error) {
+		return 0, nil
+	}
+
 	var replicas int32 = 1
 	statefulSet := &appsv1.StatefulSet{
 		ObjectMeta: metav1.ObjectMeta{
@@ -1365,7 +1406,22 @@ func TestGetIndexerStatefulSet(t *testing.T) {
 
 	cr.Spec.ClusterManagerRef.Namespace = "other"
 	if err := validateIndexerClusterSpec(ctx, c, &cr); err == nil {
-		t.Errorf("validateIndexerClusterSpec() error expected on multisite IndexerCluster referencing a cluster manager located in a different namespace")
+		t.Errorf("validateIndexerClusterSpec() error expected on IndexerCluster referencing a cluster manager located in a different namespace, which does not allow its namespace")
+	}
+	c.AddObject(&enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "manager1", Namespace: "other"},
+		Spec:       enterpriseApi.ClusterManagerSpec{AllowedPeerNamespaces: []string{"test"}},
+	})
+	if err := validateIndexerClusterSpec(ctx, c, &cr); err != nil {
+		t.Errorf("validateIndexerClusterSpec() should not have returned error on IndexerCluster referencing a cluster manager located in a different namespace; err=%v", err)
+	}
+	test(strings.ReplaceAll(`{"kind":"StatefulSet","apiVersion":"apps/v1","metadata":{"name":"splunk-stack1-indexer","namespace":"test","creationTimestamp":null,"ownerReferences":[{"apiVersion":"","kind":"","name":"stack1","uid":"","controller":true}]},"spec":{"replicas":1,"selector":{"matchLabels":{"app.kubernetes.io/component":"indexer","app.kubernetes.io/instance":"splunk-stack1-indexer","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"indexer","app.kubernetes.io/part-of":"splunk-manager1-indexer"}},"template":{"metadata":{"creationTimestamp":null,"labels":{"app.kubernetes.io/component":"indexer","app.kubernetes.io/instance":"splunk-stack1-indexer","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"indexer","app.kubernetes.io/part-of":"splunk-manager1-indexer"},"annotations":{"traffic.sidecar.istio.io/excludeOutboundPorts":"8089,8191,9997","traffic.sidecar.istio.io/includeInboundPorts":"8000,8088"}},"spec":{"volumes":[{"name":"splunk-test-probe-configmap","configMap":{"name":"splunk-test-probe-configmap","defaultMode":365}},{"name":"mnt-splunk-secrets","secret":{"secretName":"splunk-stack1-indexer-secret-v1","defaultMode":420}}],"containers":[{"name":"splunk","image":"splunk/splunk","ports":[{"name":"http-splunkweb","containerPort":8000,"protocol":"TCP"},{"name":"http-hec","containerPort":8088,"protocol":"TCP"},{"name":"https-splunkd","containerPort":8089,"protocol":"TCP"},{"name":"tcp-s2s","containerPort":9997,"protocol":"TCP"},{"name":"user-defined","containerPort":32000,"protocol":"UDP"}],"env":[{"name":"TEST_ENV_VAR","value":"test_value"},{"name":"SPLUNK_CLUSTER_MASTER_URL","value":"splunk-manager1-cluster-manager-service"},{"name":"SPLUNK_HOME","value":"/opt/splunk"},{"name":"SPLUNK_START_ARGS","value":"--accept-license"},{"name":"SPLUNK_DEFAULTS_URL","value":"/mnt/splunk-secrets/default.yml"},{"name":"SPLUNK_HOME_OWNERSHIP_ENFORCEMENT","value":"false"},{"name":"SPLUNK_ROLE","value":"splunk_indexer"},{"name":"SPLUNK_DECLARATIVE_ADMIN_PASSWORD","value":"true"},{"name":"SPLUNK_OPERATOR_K8_LIVENESS_DRIVER_FILE_PATH","value":"/tmp/splunk_operator_k8s/probes/k8_liveness_driver.sh"}],"resources":{"limits":{"cpu":"4","memory":"8Gi"},"requests":{"cpu":"100m","memory":"512Mi"}},"volumeMounts":[{"name":"pvc-etc","mountPath":"/opt/splunk/etc"},{"name":"pvc-var","mountPath":"/opt/splunk/var"},{"name":"splunk-test-probe-configmap","mountPath":"/mnt/probes"},{"name":"mnt-splunk-secrets","mountPath":"/mnt/splunk-secrets"}],"livenessProbe":{"exec":{"command":["/mnt/probes/livenessProbe.sh"]},"initialDelaySeconds":30,"timeoutSeconds":30,"periodSeconds":30,"failureThreshold":3},"readinessProbe":{"exec":{"command":["/mnt/probes/readinessProbe.sh"]},"initialDelaySeconds":10,"timeoutSeconds":5,"periodSeconds":5,"failureThreshold":3},"startupProbe":{"exec":{"command":["/mnt/probes/startupProbe.sh"]},"initialDelaySeconds":40,"timeoutSeconds":30,"periodSeconds":30,"failureThreshold":12},"imagePullPolicy":"IfNotPresent","securityContext":{"capabilities":{"add":["NET_BIND_SERVICE"],"drop":["ALL"]},"privileged":false,"runAsUser":41812,"runAsNonRoot":true,"allowPrivilegeEscalation":false,"seccompProfile":{"type":"RuntimeDefault"}}}],"serviceAccountName":"defaults","securityContext":{"runAsUser":41812,"runAsNonRoot":true,"fsGroup":41812,"fsGroupChangePolicy": "OnRootMismatch"},"affinity":{"podAntiAffinity":{"preferredDuringSchedulingIgnoredDuringExecution":[{"weight":100,"podAffinityTerm":{"labelSelector":{"matchExpressions":[{"key":"app.kubernetes.io/instance","operator":"In","values":["splunk-stack1-indexer"]}]},"topologyKey":"kubernetes.io/hostname"}}]}},"schedulerName":"default-scheduler"}},"volumeClaimTemplates":[{"metadata":{"name":"pvc-etc","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"indexer","app.kubernetes.io/instance":"splunk-stack1-indexer","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"indexer","app.kubernetes.io/part-of":"splunk-manager1-indexer"}},"spec":{"accessModes":["ReadWriteOnce"],"resources":{"requests":{"storage":"10Gi"}}},"status":{}},{"metadata":{"name":"pvc-var","namespace":"test","creationTimestamp":null,"labels":{"app.kubernetes.io/component":"indexer","app.kubernetes.io/instance":"splunk-stack1-indexer","app.kubernetes.io/managed-by":"splunk-operator","app.kubernetes.io/name":"indexer","app.kubernetes.io/part-of":"splunk-manager1-indexer"}},"spec":{"accessModes":["ReadWriteOnce"],"resources":{"requests":{"storage":"100Gi"}}},"status":{}}],"serviceName":"splunk-stack1-indexer-headless","podManagementPolicy":"Parallel","updateStrategy":{"type":"OnDelete"}},"status":{"replicas":0,"availableReplicas":0}}`,
+		`"value":"splunk-manager1-cluster-manager-service"`, `"value":"splunk-manager1-cluster-manager-service.other.svc.cluster.local"`))
+
+	cr.Spec.ClusterManagerRef = corev1.ObjectReference{}
+	cr.Spec.ClusterMasterRef = corev1.ObjectReference{Name: "manager1", Namespace: "other"}
+	if err := validateIndexerClusterSpec(ctx, c, &cr); err == nil {
+		t.Errorf("validateIndexerClusterSpec() error expected on IndexerCluster referencing a cluster master located in a different namespace")
 	}
 }
 
@@ -1923,3 +1979,180 @@ func TestImageUpdatedTo9(t *testing.T) {
 		t.Errorf("Should not have detected an upgrade from 8 to 9, there is no version")
 	}
 }
+
+func TestApplyClusterManagerIdxcSecret(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "site1",
+			Namespace: "tenant-a",
+		},
+		Spec: enterpriseApi.IndexerClusterSpec{
+			CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				ClusterManagerRef: corev1.ObjectReference{Name: "cm"},
+			},
+		},
+	}
+
+	// nothing to share with a cluster manager of the same namespace
+	err := applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err != nil || len(c.Calls["Get"]) != 0 {
+		t.Errorf("applyClusterManagerIdxcSecret should not have looked up any secret; err=%v", err)
+	}
+
+	// the cluster manager must allow the namespace of the indexer cluster
+	cr.Spec.ClusterManagerRef.Namespace = "platform"
+	cm := &enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "platform"},
+		Spec:       enterpriseApi.ClusterManagerSpec{AllowedPeerNamespaces: []string{"tenant-b"}},
+	}
+	c.AddObject(cm)
+	err = applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err == nil || !strings.Contains(err.Error(), "allowedPeerNamespaces") {
+		t.Errorf("applyClusterManagerIdxcSecret should have returned error for a namespace not allowed by the cluster manager; err=%v", err)
+	}
+	if err := validateIndexerClusterSpec(ctx, c, &cr); err == nil || !strings.Contains(err.Error(), "allowedPeerNamespaces") {
+		t.Errorf("validateIndexerClusterSpec should have returned error for a namespace not allowed by the cluster manager; err=%v", err)
+	}
+	cm.Spec.AllowedPeerNamespaces = []string{"tenant-a", "tenant-b"}
+
+	// the namespace scoped secret of the cluster manager doesn't exist yet
+	err = applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err == nil {
+		t.Errorf("applyClusterManagerIdxcSecret should have returned error without the secret of the cluster manager")
+	}
+
+	cmSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      splcommon.GetNamespaceScopedSecretName("platform"),
+			Namespace: "platform",
+		},
+		Data: map[string][]byte{
+			splcommon.IdxcSecret: []byte("shared"),
+		},
+	}
+	c.AddObject(cmSecret)
+	err = applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err != nil {
+		t.Errorf("applyClusterManagerIdxcSecret should not have returned error; err=%v", err)
+	}
+	secret, err := splutil.GetNamespaceScopedSecret(ctx, c, "tenant-a")
+	if err != nil {
+		t.Errorf("namespace scoped secret of the indexer cluster should have been created; err=%v", err)
+	} else if string(secret.Data[splcommon.IdxcSecret]) != "shared" || len(secret.Data["password"]) == 0 {
+		t.Errorf("idxc_secret of the cluster manager should have been shared, got %s", secret.Data[splcommon.IdxcSecret])
+	}
+
+	// a change of the idxc_secret of the cluster manager is shared again
+	cmSecret.Data[splcommon.IdxcSecret] = []byte("rotated")
+	err = applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err != nil {
+		t.Errorf("applyClusterManagerIdxcSecret should not have returned error; err=%v", err)
+	}
+	secret, _ = splutil.GetNamespaceScopedSecret(ctx, c, "tenant-a")
+	if string(secret.Data[splcommon.IdxcSecret]) != "rotated" {
+		t.Errorf("rotated idxc_secret of the cluster manager should have been shared, got %s", secret.Data[splcommon.IdxcSecret])
+	}
+
+	// a different idxc_secret of an existing namespace scoped secret is not overwritten
+	tenantSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      splcommon.GetNamespaceScopedSecretName("tenant-b"),
+			Namespace: "tenant-b",
+		},
+		Data: map[string][]byte{
+			splcommon.IdxcSecret: []byte("tenant"),
+		},
+	}
+	c.AddObject(tenantSecret)
+	cr.Namespace = "tenant-b"
+	err = applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err == nil || !strings.Contains(err.Error(), "refusing to overwrite") {
+		t.Errorf("applyClusterManagerIdxcSecret should have refused to overwrite the idxc_secret of namespace tenant-b; err=%v", err)
+	}
+	secret, _ = splutil.GetNamespaceScopedSecret(ctx, c, "tenant-b")
+	if string(secret.Data[splcommon.IdxcSecret]) != "tenant" {
+		t.Errorf("idxc_secret of namespace tenant-b should not have been overwritten, got %s", secret.Data[splcommon.IdxcSecret])
+	}
+
+	// an existing namespace scoped secret with the same idxc_secret is kept as is
+	tenantSecret.Data[splcommon.IdxcSecret] = []byte("rotated")
+	err = applyClusterManagerIdxcSecret(ctx, c, &cr)
+	if err != nil {
+		t.Errorf("applyClusterManagerIdxcSecret should not have returned error; err=%v", err)
+	}
+}
+
+func TestGetClusterManagerIndexerClusterList(t *testing.T) {
+	ctx := context.TODO()
+	newIndexerCluster := func(name, namespace string, ref corev1.ObjectReference) enterpriseApi.IndexerCluster {
+		return enterpriseApi.IndexerCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+			Spec: enterpriseApi.IndexerClusterSpec{
+				CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{ClusterManagerRef: ref},
+			},
+		}
+	}
+	site1 := newIndexerCluster("site1", "platform", corev1.ObjectReference{Name: "cm"})
+	site2 := newIndexerCluster("site", "tenant-a", corev1.ObjectReference{Name: "cm", Namespace: "platform"})
+	site3 := newIndexerCluster("site", "tenant-b", corev1.ObjectReference{Name: "cm", Namespace: "platform"})
+	other := newIndexerCluster("site", "tenant-c", corev1.ObjectReference{Name: "cm"})
+
+	cm := &enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm", Namespace: "platform"},
+		Spec:       enterpriseApi.ClusterManagerSpec{AllowedPeerNamespaces: []string{"tenant-a"}},
+	}
+
+	// the indexer clusters are listed in the namespace of the cluster manager, and the namespaces it allows only
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	fakeClient := fake.NewClientBuilder().WithObjects(cm, &site1, &site2, &site3, &other).Build()
+	getNames := func(items []enterpriseApi.IndexerCluster) []string {
+		var names []string
+		for _, item := range items {
+			names = append(names, item.GetNamespace()+"/"+item.GetName())
+		}
+		return names
+	}
+	indexerList, err := getClusterManagerIndexerClusterList(ctx, fakeClient, &site1, "platform", "cm", cm.Spec.AllowedPeerNamespaces)
+	if err != nil {
+		t.Errorf("getClusterManagerIndexerClusterList should not have returned error; err=%v", err)
+	}
+	if names := getNames(indexerList.Items); !reflect.DeepEqual(names, []string{"platform/site1", "tenant-a/site"}) {
+		t.Errorf("getClusterManagerIndexerClusterList returned %v", names)
+	}
+
+	mgr := &indexerClusterPodManager{c: fakeClient, cr: &site2}
+	otherSites, err := mgr.getOtherSites(ctx)
+	if err != nil {
+		t.Errorf("getOtherSites should not have returned error; err=%v", err)
+	}
+	if names := getNames(otherSites); !reflect.DeepEqual(names, []string{"platform/site1", "tenant-a/site"}) {
+		t.Errorf("getOtherSites returned %v", names)
+	}
+
+	// the indexer clusters are listed in the namespace of the cluster manager only, by default
+	c := spltest.NewMockClient()
+	_, _ = getClusterManagerIndexerClusterList(ctx, c, &site1, "platform", "cm", nil)
+	if len(c.Calls["List"]) != 1 || !reflect.DeepEqual(c.Calls["List"][0].ListOpts, []client.ListOption{client.InNamespace("platform")}) {
+		t.Errorf("indexer clusters should be listed in the namespace of the cluster manager, got %v", c.Calls["List"])
+	}
+
+	if !isSameClusterManager(&site1, &site2) || isSameClusterManager(&site2, &other) {
+		t.Errorf("isSameClusterManager should compare the namespaces of the cluster managers")
+	}
+	if isSameIndexerCluster(&site2, &site3) || !isSameIndexerCluster(&site2, &site2) {
+		t.Errorf("isSameIndexerCluster should compare the namespaces of the indexer clusters")
+	}
+
+	// the sites share the cluster manager through its FQDN
+	statefulSet, err := getIndexerSiteStatefulSet(ctx, c, &site2, &site2, "site2")
+	if err != nil {
+		t.Errorf("getIndexerSiteStatefulSet should not have returned error; err=%v", err)
+	}
+	for _, env := range statefulSet.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == "SPLUNK_MULTISITE_MASTER" && env.Value != "splunk-cm-cluster-manager-service.platform.svc.cluster.local" {
+			t.Errorf("SPLUNK_MULTISITE_MASTER is %s", env.Value)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+)
+
+// bucket fixup levels, which must be complete for the decommission of a peer
+var decommissionFixupLevels = []string{"replication_factor", "search_factor"}
+
+// GetClusterManagerBucketFixupsCall returns the number of buckets pending fixup on the cluster manager
+var GetClusterManagerBucketFixupsCall = func(ctx context.Context, mgr *indexerClusterPodManager) (int64, error) {
+	c := mgr.getClusterManagerClient(ctx)
+	var fixups int64
+	for _, level := range decommissionFixupLevels {
+		count, err := c.GetClusterManagerBucketFixups(level)
+		if err != nil {
+			return 0, err
+		}
+		fixups += count
+	}
+	return fixups, nil
+}
+
+// restorePeerCall brings a decommissioning peer back to the cluster, by restarting splunk on it
+var restorePeerCall = func(ctx context.Context, mgr *indexerClusterPodManager, n int32) error {
+	podExecClient := splutil.GetPodExecClient(mgr.c, mgr.cr, getApplicablePodNameForK8Probes(mgr.cr, n))
+	err := setProbeLevelOnSplunkPod(ctx, podExecClient, livenessProbeLevelDefault)
+	if err != nil {
+		mgr.log.Info("Unable to restore the liveness probe level", "peerName", GetSplunkStatefulsetPodName(SplunkIndexer, mgr.cr.GetName(), n))
+	}
+
+	c := mgr.getClient(ctx, n)
+	return c.RestartSplunk()
+}
+
+// offlinePeerCall takes a decommissioning peer offline, by stopping splunk on it
+var offlinePeerCall = func(ctx context.Context, mgr *indexerClusterPodManager, n int32) error {
+	podExecClient := splutil.GetPodExecClient(mgr.c, mgr.cr, getApplicablePodNameForK8Probes(mgr.cr, n))
+	streamOptions := splutil.NewStreamOptionsObject(offlinePeerCmdStr)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to take the peer offline. stdout: %s, stdErr: %s, err: %s", stdOut, stdErr, err)
+	}
+	return nil
+}
+
+// getScaleDownReplicas returns the replicas to scale down to. The replicas of an aborted scale down are not
+// retried, until they are changed
+func getScaleDownReplicas(cr *enterpriseApi.IndexerCluster, replicas int32, desiredReplicas int32) int32 {
+	decommissionStatus := &cr.Status.DecommissionStatus
+	if decommissionStatus.AbortedReplicas == 0 {
+		return desiredReplicas
+	}
+
+	if desiredReplicas == decommissionStatus.AbortedReplicas && desiredReplicas < replicas {
+		return replicas
+	}
+
+	// replicas are changed, so the scale down can be retried
+	decommissionStatus.AbortedReplicas = 0
+	return desiredReplicas
+}
+
+// checkDecommissionTimeout tracks a decommission which is not complete yet in the status, and applies the
+// decommission timeout policy once the timeout is crossed
+func (mgr *indexerClusterPodManager) checkDecommissionTimeout(ctx context.Context, n int32, decommissionErr error) error {
+	scaleDown := mgr.cr.Spec.ScaleDown
+	peerName := GetSplunkStatefulsetPodName(SplunkIndexer, mgr.cr.GetName(), n)
+	decommissionStatus := &mgr.cr.Status.DecommissionStatus
+	now := time.Now().Unix()
+	if decommissionStatus.Peer != peerName || decommissionStatus.StartTime == 0 {
+		*decommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{
+			Peer:            peerName,
+			StartTime:       now,
+			AbortedReplicas: decommissionStatus.AbortedReplicas,
+		}
+	}
+	decommissionStatus.ElapsedSeconds = now - decommissionStatus.StartTime
+
+	fixups, err := GetClusterManagerBucketFixupsCall(ctx, mgr)
+	if err != nil {
+		mgr.log.Error(err, "Unable to get the bucket fixups from the cluster manager", "peerName", peerName)
+	} else {
+		decommissionStatus.RemainingBucketFixups = fixups
+	}
+
+	decommissionStatus.Message = fmt.Sprintf("waiting for the decommission of %s", peerName)
+	if decommissionErr != nil {
+		mgr.log.Error(decommissionErr, "Decommission is in an unexpected state", "peerName", peerName)
+		decommissionStatus.Message = fmt.Sprintf("decommission of %s is in an unexpected state. error: %v", peerName, decommissionErr)
+	}
+
+	// an unexpected peer status is tolerated only until the timeout, if any
+	if scaleDown.DecommissionTimeout == nil {
+		return decommissionErr
+	}
+
+	if decommissionStatus.ElapsedSeconds < int64(scaleDown.DecommissionTimeout.Seconds()) {
+		return nil
+	}
+
+	eventPublisher, _ := newK8EventPublisher(mgr.c, mgr.cr)
+	if !decommissionStatus.TimedOut {
+		decommissionStatus.TimedOut = true
+		eventPublisher.Warning(ctx, "DecommissionTimeout", fmt.Sprintf("decommission of %s did not complete in %s, %d bucket fixups remaining, applying the %s policy", peerName, scaleDown.DecommissionTimeout.Duration, decommissionStatus.RemainingBucketFixups, getDecommissionTimeoutPolicy(mgr.cr)))
+	}
+
+	switch getDecommissionTimeoutPolicy(mgr.cr) {
+	case enterpriseApi.DecommissionTimeoutPolicyForceRemove:
+		// the cluster manager removes only the peers which are down, so the peer is taken offline first, and is
+		// removed once the cluster manager reports it down
+		if !decommissionStatus.Offline {
+			mgr.log.Info("Decommission timed out, taking the peer offline", "peerName", peerName, "remainingBucketFixups", decommissionStatus.RemainingBucketFixups)
+			err = offlinePeerCall(ctx, mgr, n)
+			if err != nil {
+				return err
+			}
+			decommissionStatus.Offline = true
+			eventPublisher.Warning(ctx, "DecommissionForceRemove", fmt.Sprintf("taking %s offline to remove it with %d bucket fixups remaining", peerName, decommissionStatus.RemainingBucketFixups))
+		}
+		decommissionStatus.Message = fmt.Sprintf("decommission of %s timed out, waiting for the peer to be down to remove it", peerName)
+		return nil
+
+	case enterpriseApi.DecommissionTimeoutPolicyAbortAndRestore:
+		mgr.log.Info("Decommission timed out, restoring the peer", "peerName", peerName)
+		err = restorePeerCall(ctx, mgr, n)
+		if err != nil {
+			return err
+		}
+		eventPublisher.Warning(ctx, "DecommissionAborted", fmt.Sprintf("scale down to %d replicas is aborted, and %s is restored", mgr.cr.Spec.Replicas, peerName))
+		*decommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{
+			AbortedReplicas: mgr.cr.Spec.Replicas,
+			Message:         fmt.Sprintf("scale down to %d replicas is aborted, as the decommission of %s timed out", mgr.cr.Spec.Replicas, peerName),
+		}
+		return nil
+	}
+
+	decommissionStatus.Message = fmt.Sprintf("decommission of %s timed out, still waiting", peerName)
+	return nil
+}
+
+// getDecommissionTimeoutPolicy returns the decommission timeout policy
+func getDecommissionTimeoutPolicy(cr *enterpriseApi.IndexerCluster) string {
+	if cr.Spec.ScaleDown.Policy == "" {
+		return enterpriseApi.DecommissionTimeoutPolicyWait
+	}
+	return cr.Spec.ScaleDown.Policy
+}
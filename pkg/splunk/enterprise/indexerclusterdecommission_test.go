@@ -0,0 +1,218 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetScaleDownReplicas(t *testing.T) {
+	cr := &enterpriseApi.IndexerCluster{}
+	if getScaleDownReplicas(cr, 3, 2) != 2 {
+		t.Errorf("scale down should go ahead")
+	}
+
+	cr.Status.DecommissionStatus.AbortedReplicas = 2
+	if getScaleDownReplicas(cr, 3, 2) != 3 {
+		t.Errorf("aborted scale down should not be retried")
+	}
+
+	if getScaleDownReplicas(cr, 3, 1) != 1 || cr.Status.DecommissionStatus.AbortedReplicas != 0 {
+		t.Errorf("scale down should be retried, once the replicas are changed")
+	}
+}
+
+func TestCheckDecommissionTimeout(t *testing.T) {
+	ctx := context.TODO()
+
+	savedGetClusterManagerBucketFixupsCall := GetClusterManagerBucketFixupsCall
+	savedRestorePeerCall := restorePeerCall
+	savedOfflinePeerCall := offlinePeerCall
+	defer func() {
+		GetClusterManagerBucketFixupsCall = savedGetClusterManagerBucketFixupsCall
+		restorePeerCall = savedRestorePeerCall
+		offlinePeerCall = savedOfflinePeerCall
+	}()
+	GetClusterManagerBucketFixupsCall = func(ctx context.Context, mgr *indexerClusterPodManager) (int64, error) {
+		return 5, nil
+	}
+	var restoredPeers []int32
+	restorePeerCall = func(ctx context.Context, mgr *indexerClusterPodManager, n int32) error {
+		restoredPeers = append(restoredPeers, n)
+		return nil
+	}
+	var offlinePeers []int32
+	offlinePeerCall = func(ctx context.Context, mgr *indexerClusterPodManager, n int32) error {
+		offlinePeers = append(offlinePeers, n)
+		return nil
+	}
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := getIndexerClusterPodManager("TestCheckDecommissionTimeout", nil, mockSplunkClient, 2)
+	c := spltest.NewMockClient()
+	mgr.c = c
+	mgr.cr.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{
+		{Name: "splunk-stack1-indexer-0", Status: "Up"},
+		{Name: "splunk-stack1-indexer-1", Status: "Decommissioning"},
+	}
+
+	// no timeout, so an unexpected peer status is an error
+	err := mgr.checkDecommissionTimeout(ctx, 1, fmt.Errorf("Status=Unknown"))
+	if err == nil {
+		t.Errorf("unexpected peer status should fail without a decommission timeout")
+	}
+
+	// decommission progress is reported without a decommission timeout as well
+	ready, err := mgr.PrepareScaleDown(ctx, 1)
+	if err != nil || ready {
+		t.Errorf("decommission should be in progress. err: %v", err)
+	}
+	decommissionStatus := mgr.cr.Status.DecommissionStatus
+	if decommissionStatus.Peer != "splunk-stack1-indexer-1" || decommissionStatus.StartTime == 0 || decommissionStatus.RemainingBucketFixups != 5 || decommissionStatus.TimedOut {
+		t.Errorf("unexpected decommission status without a timeout %v", decommissionStatus)
+	}
+	mgr.cr.Status.DecommissionStatus.StartTime -= 2 * 3600
+	_, _ = mgr.PrepareScaleDown(ctx, 1)
+	if mgr.cr.Status.DecommissionStatus.ElapsedSeconds < 2*3600 || mgr.cr.Status.DecommissionStatus.TimedOut {
+		t.Errorf("elapsed decommission time should be reported without a timeout. status: %v", mgr.cr.Status.DecommissionStatus)
+	}
+	mgr.cr.Status.DecommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{}
+
+	// decommission is tracked until the timeout
+	mgr.cr.Spec.ScaleDown.DecommissionTimeout = &metav1.Duration{Duration: time.Hour}
+	ready, err = mgr.PrepareScaleDown(ctx, 1)
+	if err != nil || ready {
+		t.Errorf("decommission should be in progress. err: %v", err)
+	}
+	decommissionStatus = mgr.cr.Status.DecommissionStatus
+	if decommissionStatus.Peer != "splunk-stack1-indexer-1" || decommissionStatus.StartTime == 0 || decommissionStatus.RemainingBucketFixups != 5 || decommissionStatus.TimedOut {
+		t.Errorf("unexpected decommission status %v", decommissionStatus)
+	}
+
+	// unexpected peer status is tolerated until the timeout
+	err = mgr.checkDecommissionTimeout(ctx, 1, fmt.Errorf("Status=Unknown"))
+	if err != nil {
+		t.Errorf("unexpected peer status should be tolerated until the timeout. err: %v", err)
+	}
+
+	// wait policy keeps waiting after the timeout
+	mgr.cr.Status.DecommissionStatus.StartTime -= 2 * 3600
+	ready, err = mgr.PrepareScaleDown(ctx, 1)
+	if err != nil || ready {
+		t.Errorf("decommission should still be waited for. err: %v", err)
+	}
+	if !mgr.cr.Status.DecommissionStatus.TimedOut || mgr.cr.Status.DecommissionStatus.ElapsedSeconds < 2*3600 {
+		t.Errorf("decommission should be timed out. status: %v", mgr.cr.Status.DecommissionStatus)
+	}
+	if len(c.Calls["Create"]) != 1 {
+		t.Errorf("timeout event should be published once")
+	}
+
+	// abortAndRestore brings the peer back, and aborts the scale down
+	mgr.cr.Spec.ScaleDown.Policy = enterpriseApi.DecommissionTimeoutPolicyAbortAndRestore
+	ready, err = mgr.PrepareScaleDown(ctx, 1)
+	if err != nil || ready {
+		t.Errorf("scale down should be aborted. err: %v", err)
+	}
+	if len(restoredPeers) != 1 || restoredPeers[0] != 1 {
+		t.Errorf("peer should be restored. restored: %v", restoredPeers)
+	}
+	if mgr.cr.Status.DecommissionStatus.AbortedReplicas != mgr.cr.Spec.Replicas || mgr.cr.Status.DecommissionStatus.Peer != "" {
+		t.Errorf("unexpected decommission status %v", mgr.cr.Status.DecommissionStatus)
+	}
+
+	// forceRemove takes the peer offline after the timeout, as the cluster manager rejects the removal of a peer
+	// which is not down
+	mgr.cr.Spec.ScaleDown.Policy = enterpriseApi.DecommissionTimeoutPolicyForceRemove
+	mgr.cr.Status.Peers[1].ID = "D39B1729-E2C5-4273-B9B2-534DA7C2F866"
+	removePeerHandler := spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-manager1-cluster-manager-service.test.svc.cluster.local:8089/services/cluster/manager/control/control/remove_peers?peers=D39B1729-E2C5-4273-B9B2-534DA7C2F866",
+		Status: 400,
+		Err:    nil,
+		Body:   `{"messages":[{"type":"ERROR","text":"peer is not down"}]}`,
+	}
+	mockSplunkClient.AddHandlers(removePeerHandler)
+	mgr.cr.Status.DecommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{
+		Peer:      "splunk-stack1-indexer-1",
+		StartTime: time.Now().Unix() - 2*3600,
+	}
+	requests := len(mockSplunkClient.GotRequests)
+	for i := 0; i < 2; i++ {
+		ready, err = mgr.PrepareScaleDown(ctx, 1)
+		if err != nil || ready {
+			t.Errorf("peer should not be removed until it is down. err: %v", err)
+		}
+	}
+	if len(offlinePeers) != 1 || offlinePeers[0] != 1 || !mgr.cr.Status.DecommissionStatus.Offline {
+		t.Errorf("peer should be taken offline once. offline: %v, status: %v", offlinePeers, mgr.cr.Status.DecommissionStatus)
+	}
+	if len(mockSplunkClient.GotRequests) != requests {
+		t.Errorf("removal of a peer which is not down should not be requested, got %d requests", len(mockSplunkClient.GotRequests)-requests)
+	}
+
+	// the peer is removed once the cluster manager reports it down
+	mgr.cr.Status.Peers[1].Status = "Down"
+	removePeerHandler.Status = 200
+	removePeerHandler.Body = ``
+	mockSplunkClient.AddHandlers(removePeerHandler)
+	ready, err = mgr.PrepareScaleDown(ctx, 1)
+	if err != nil || !ready {
+		t.Errorf("peer should be removed. err: %v", err)
+	}
+	if mgr.cr.Status.DecommissionStatus.Peer != "" {
+		t.Errorf("decommission status should be reset after the scale down")
+	}
+}
+
+func TestPrepareScaleDownRemovePeerRejected(t *testing.T) {
+	ctx := context.TODO()
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := getIndexerClusterPodManager("TestPrepareScaleDownRemovePeerRejected", nil, mockSplunkClient, 2)
+	mgr.c = spltest.NewMockClient()
+	mgr.cr.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{
+		{Name: "splunk-stack1-indexer-0", Status: "Up"},
+		{ID: "D39B1729-E2C5-4273-B9B2-534DA7C2F866", Name: "splunk-stack1-indexer-1", Status: "GracefulShutdown"},
+	}
+	mgr.cr.Status.DecommissionStatus = enterpriseApi.IndexerClusterDecommissionStatus{
+		Peer:      "splunk-stack1-indexer-1",
+		StartTime: time.Now().Unix(),
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-manager1-cluster-manager-service.test.svc.cluster.local:8089/services/cluster/manager/control/control/remove_peers?peers=D39B1729-E2C5-4273-B9B2-534DA7C2F866",
+		Status: 400,
+		Err:    nil,
+		Body:   ``,
+	})
+
+	// the decommission status is kept when the removal fails, so the timeout is not restarted
+	ready, err := mgr.PrepareScaleDown(ctx, 1)
+	if err == nil || ready {
+		t.Errorf("rejected peer removal should fail. err: %v", err)
+	}
+	if mgr.cr.Status.DecommissionStatus.Peer != "splunk-stack1-indexer-1" || mgr.cr.Status.DecommissionStatus.StartTime == 0 {
+		t.Errorf("decommission status should be kept after a rejected removal. status: %v", mgr.cr.Status.DecommissionStatus)
+	}
+}
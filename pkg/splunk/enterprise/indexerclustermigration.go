@@ -0,0 +1,249 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// getVolumeClaimTemplateStorageClassName returns the storage class name of a volume claim template of a StatefulSet
+func getVolumeClaimTemplateStorageClassName(statefulSet *appsv1.StatefulSet, claimName string) string {
+	for _, claim := range statefulSet.Spec.VolumeClaimTemplates {
+		if claim.GetName() == claimName && claim.Spec.StorageClassName != nil {
+			return *claim.Spec.StorageClassName
+		}
+	}
+	return ""
+}
+
+// getPeerVolumeClaim returns the PVC of a peer created from a volume claim template, or nil if not found
+func (mgr *indexerClusterPodManager) getPeerVolumeClaim(ctx context.Context, statefulSet *appsv1.StatefulSet, claimName string, n int32) (*corev1.PersistentVolumeClaim, error) {
+	namespacedName := types.NamespacedName{
+		Namespace: statefulSet.GetNamespace(),
+		Name:      fmt.Sprintf("%s-%s-%d", claimName, statefulSet.GetName(), n),
+	}
+	var pvc corev1.PersistentVolumeClaim
+	err := mgr.c.Get(ctx, namespacedName, &pvc)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &pvc, nil
+}
+
+// isVolumeClaimMigrated checks if a PVC uses the storage class, and is not being deleted
+func isVolumeClaimMigrated(pvc *corev1.PersistentVolumeClaim, storageClassName string) bool {
+	return pvc != nil && pvc.GetDeletionTimestamp() == nil && pvc.Spec.StorageClassName != nil && *pvc.Spec.StorageClassName == storageClassName
+}
+
+// migrateStorage migrates the var volumes of the peers to the storage class of the spec, one peer at a time. Each
+// peer is decommissioned to evacuate its data, its var volume is recreated with the new storage class, and the
+// migration waits for the peer to rejoin the cluster and for the replication and search factors to be met before
+// moving to the next peer. Returns false when there is nothing to migrate, in which case the regular scaling and
+// pod updates apply
+func (mgr *indexerClusterPodManager) migrateStorage(ctx context.Context, statefulSet *appsv1.StatefulSet, desiredReplicas int32) (enterpriseApi.Phase, bool, error) {
+	migrationStatus := &mgr.cr.Status.StorageMigration
+	varVolumeStorageConfig := mgr.cr.Spec.VarVolumeStorageConfig
+	storageClassName := varVolumeStorageConfig.StorageClassName
+	if storageClassName == "" || varVolumeStorageConfig.EphemeralStorage {
+		return enterpriseApi.PhaseReady, false, nil
+	}
+
+	claimName := fmt.Sprintf(splcommon.PvcNamePrefix, splcommon.VarVolumeStorage)
+	templateStorageClassName := getVolumeClaimTemplateStorageClassName(statefulSet, claimName)
+	replicas := *statefulSet.Spec.Replicas
+	eventPublisher, _ := newK8EventPublisher(mgr.c, mgr.cr)
+
+	if migrationStatus.Phase != enterpriseApi.UpdatePhaseInProgress || migrationStatus.StorageClassName != storageClassName {
+		if templateStorageClassName == storageClassName {
+			return enterpriseApi.PhaseReady, false, nil
+		}
+
+		// scaling and updates go first
+		if replicas != desiredReplicas || statefulSet.Status.ReadyReplicas != replicas || mgr.cr.Status.UpdateStatus.Phase == enterpriseApi.UpdatePhaseInProgress {
+			return enterpriseApi.PhaseReady, false, nil
+		}
+
+		// only one site at a time
+		if mgr.storageMigrationBlocker != "" {
+			migrationStatus.Message = fmt.Sprintf("waiting for the storage migration of the site %s", mgr.storageMigrationBlocker)
+			mgr.log.Info("Waiting for the storage migration of another site", "site", mgr.storageMigrationBlocker)
+			return enterpriseApi.PhaseReady, false, nil
+		}
+
+		mgr.log.Info("Starting the storage migration of the peers", "storageClassName", storageClassName)
+		eventPublisher.Normal(ctx, "StorageMigration", fmt.Sprintf("migrating the var volumes of the peers to the storage class %s", storageClassName))
+		*migrationStatus = enterpriseApi.IndexerClusterStorageMigrationStatus{
+			Phase:            enterpriseApi.UpdatePhaseInProgress,
+			StorageClassName: storageClassName,
+			StartTime:        time.Now().Unix(),
+		}
+	}
+
+	// the volume claim templates being immutable, recreate the StatefulSet with the new storage class, keeping the
+	// pods and their PVCs
+	if templateStorageClassName != storageClassName {
+		if statefulSet.GetDeletionTimestamp() == nil {
+			mgr.log.Info("Deleting StatefulSet to recreate it with the new storage class", "storageClassName", storageClassName)
+			err := mgr.c.Delete(ctx, statefulSet, client.PropagationPolicy(metav1.DeletePropagationOrphan))
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return enterpriseApi.PhaseError, true, err
+			}
+		}
+		migrationStatus.Message = fmt.Sprintf("recreating the statefulset with the storage class %s", storageClassName)
+		return enterpriseApi.PhaseUpdating, true, nil
+	}
+
+	// continue with the peer being migrated, or pick the next one
+	n := int32(-1)
+	if migrationStatus.Peer != "" {
+		ordinal, err := getPeerOrdinal(migrationStatus.Peer)
+		if err == nil && ordinal < replicas {
+			n = ordinal
+		}
+	}
+	if n < 0 {
+		for i := int32(0); i < replicas; i++ {
+			pvc, err := mgr.getPeerVolumeClaim(ctx, statefulSet, claimName, i)
+			if err != nil {
+				return enterpriseApi.PhaseError, true, err
+			}
+			if pvc != nil && !isVolumeClaimMigrated(pvc, storageClassName) {
+				n = i
+				break
+			}
+		}
+
+		// all the peers are migrated
+		if n < 0 {
+			mgr.log.Info("Finished the storage migration of the peers", "storageClassName", storageClassName)
+			eventPublisher.Normal(ctx, "StorageMigration", fmt.Sprintf("var volumes of the peers are migrated to the storage class %s", storageClassName))
+			migrationStatus.Phase = enterpriseApi.UpdatePhaseComplete
+			migrationStatus.CompletionTime = time.Now().Unix()
+			migrationStatus.Peer = ""
+			migrationStatus.Step = ""
+			migrationStatus.RemainingBucketFixups = 0
+			migrationStatus.Message = ""
+			return enterpriseApi.PhaseReady, false, nil
+		}
+
+		migrationStatus.Peer = GetSplunkStatefulsetPodName(SplunkIndexer, mgr.cr.GetName(), n)
+		migrationStatus.Step = enterpriseApi.StorageMigrationStepDecommissioning
+	}
+
+	return mgr.migratePeerStorage(ctx, statefulSet, claimName, n)
+}
+
+// migratePeerStorage moves a peer through the steps of the storage migration
+func (mgr *indexerClusterPodManager) migratePeerStorage(ctx context.Context, statefulSet *appsv1.StatefulSet, claimName string, n int32) (enterpriseApi.Phase, bool, error) {
+	migrationStatus := &mgr.cr.Status.StorageMigration
+	peerName := migrationStatus.Peer
+	if n >= int32(len(mgr.cr.Status.Peers)) {
+		mgr.log.Info("Waiting for the peer status", "peerName", peerName)
+		return enterpriseApi.PhaseUpdating, true, nil
+	}
+
+	// evacuate the data of the peer, with enforceCounts=true like a scale down
+	if migrationStatus.Step == enterpriseApi.StorageMigrationStepDecommissioning {
+		migrationStatus.Message = fmt.Sprintf("decommissioning %s", peerName)
+		complete, err := mgr.decommission(ctx, n, true)
+		if err != nil {
+			mgr.log.Error(err, "Unable to decommission the peer", "peerName", peerName)
+			return enterpriseApi.PhaseError, true, err
+		}
+		if !complete {
+			return enterpriseApi.PhaseUpdating, true, nil
+		}
+		migrationStatus.Step = enterpriseApi.StorageMigrationStepReplacingVolume
+	}
+
+	// delete the PVC and the pod of the peer, so that the StatefulSet recreates them from the volume claim template
+	// with the new storage class
+	if migrationStatus.Step == enterpriseApi.StorageMigrationStepReplacingVolume {
+		migrationStatus.Message = fmt.Sprintf("replacing the var volume of %s", peerName)
+		pvc, err := mgr.getPeerVolumeClaim(ctx, statefulSet, claimName, n)
+		if err != nil {
+			return enterpriseApi.PhaseError, true, err
+		}
+		if !isVolumeClaimMigrated(pvc, migrationStatus.StorageClassName) {
+			if pvc != nil && pvc.GetDeletionTimestamp() == nil {
+				mgr.log.Info("Deleting PVC of the peer", "peerName", peerName, "pvc", pvc.GetName())
+				err = mgr.c.Delete(ctx, pvc)
+				if err != nil && !k8serrors.IsNotFound(err) {
+					return enterpriseApi.PhaseError, true, err
+				}
+			}
+
+			var pod corev1.Pod
+			err = mgr.c.Get(ctx, types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: peerName}, &pod)
+			if err == nil && pod.GetDeletionTimestamp() == nil {
+				mgr.log.Info("Deleting Pod of the peer", "peerName", peerName)
+				err = mgr.c.Delete(ctx, &pod)
+			}
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return enterpriseApi.PhaseError, true, err
+			}
+			return enterpriseApi.PhaseUpdating, true, nil
+		}
+		migrationStatus.Step = enterpriseApi.StorageMigrationStepRejoining
+	}
+
+	// wait for the peer to rejoin the cluster
+	if migrationStatus.Step == enterpriseApi.StorageMigrationStepRejoining {
+		migrationStatus.Message = fmt.Sprintf("waiting for %s to rejoin the cluster", peerName)
+		var pod corev1.Pod
+		err := mgr.c.Get(ctx, types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: peerName}, &pod)
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return enterpriseApi.PhaseError, true, err
+		}
+		if err != nil || !isPodReady(&pod) || mgr.cr.Status.Peers[n].Status != "Up" {
+			return enterpriseApi.PhaseUpdating, true, nil
+		}
+		migrationStatus.Step = enterpriseApi.StorageMigrationStepReplicating
+	}
+
+	// wait for the replication and search factors to be met
+	migrationStatus.Message = fmt.Sprintf("waiting for the replication and search factors to be met after the migration of %s", peerName)
+	fixups, err := GetClusterManagerBucketFixupsCall(ctx, mgr)
+	if err != nil {
+		mgr.log.Error(err, "Unable to get the bucket fixups from the cluster manager", "peerName", peerName)
+		return enterpriseApi.PhaseUpdating, true, nil
+	}
+	migrationStatus.RemainingBucketFixups = fixups
+	if fixups > 0 {
+		return enterpriseApi.PhaseUpdating, true, nil
+	}
+
+	mgr.log.Info("Peer is migrated to the storage class", "peerName", peerName, "storageClassName", migrationStatus.StorageClassName)
+	migrationStatus.MigratedPeers = append(migrationStatus.MigratedPeers, peerName)
+	migrationStatus.Peer = ""
+	migrationStatus.Step = ""
+	migrationStatus.Message = ""
+	return enterpriseApi.PhaseUpdating, true, nil
+}
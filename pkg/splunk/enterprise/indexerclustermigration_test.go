@@ -0,0 +1,229 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestIndexerClusterStorageMigration(t *testing.T) {
+	ctx := context.TODO()
+	var replicas int32 = 2
+
+	newStatefulSet := func(storageClassName string) *appsv1.StatefulSet {
+		return &appsv1.StatefulSet{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      "splunk-stack1-indexer",
+				Namespace: "test",
+			},
+			Spec: appsv1.StatefulSetSpec{
+				Replicas: &replicas,
+				VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+					{
+						ObjectMeta: metav1.ObjectMeta{Name: "pvc-var"},
+						Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+					},
+				},
+			},
+			Status: appsv1.StatefulSetStatus{
+				Replicas:      replicas,
+				ReadyReplicas: replicas,
+			},
+		}
+	}
+	newPVC := func(n int32, storageClassName string) *corev1.PersistentVolumeClaim {
+		return &corev1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("pvc-var-splunk-stack1-indexer-%d", n),
+				Namespace: "test",
+			},
+			Spec: corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+		}
+	}
+	newPod := func(n int32) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("splunk-stack1-indexer-%d", n),
+				Namespace: "test",
+			},
+			Status: corev1.PodStatus{
+				Phase:             corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+			},
+		}
+	}
+
+	var fixups int64
+	savedGetClusterManagerBucketFixupsCall := GetClusterManagerBucketFixupsCall
+	defer func() { GetClusterManagerBucketFixupsCall = savedGetClusterManagerBucketFixupsCall }()
+	GetClusterManagerBucketFixupsCall = func(ctx context.Context, mgr *indexerClusterPodManager) (int64, error) {
+		return fixups, nil
+	}
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-indexer-0.splunk-stack1-indexer-headless.test.svc.cluster.local:8089/services/cluster/peer/control/control/decommission?enforce_counts=1",
+		Status: 200,
+		Body:   ``,
+	})
+	mgr := getIndexerClusterPodManager("TestIndexerClusterStorageMigration", nil, mockSplunkClient, replicas)
+	for n := int32(0); n < replicas; n++ {
+		mgr.cr.Status.Peers = append(mgr.cr.Status.Peers, enterpriseApi.IndexerClusterMemberStatus{
+			Name:   fmt.Sprintf("splunk-stack1-indexer-%d", n),
+			Status: "Up",
+		})
+	}
+
+	c := spltest.NewMockClient()
+	mgr.c = c
+	for n := int32(0); n < replicas; n++ {
+		c.AddObject(newPod(n))
+		c.AddObject(newPVC(n, "gp2"))
+	}
+	c.AddObject(newStatefulSet("gp2"))
+
+	// nothing to migrate without a storage class
+	_, handled, err := mgr.migrateStorage(ctx, newStatefulSet("gp2"), replicas)
+	if err != nil || handled {
+		t.Errorf("storage migration should not start. err: %v", err)
+	}
+
+	// scaling goes first
+	mgr.cr.Spec.VarVolumeStorageConfig.StorageClassName = "gp3"
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp2"), 3)
+	if err != nil || handled {
+		t.Errorf("storage migration should wait for the scaling. err: %v", err)
+	}
+
+	// migration is started by recreating the statefulset with the new storage class
+	phase, handled, err := mgr.migrateStorage(ctx, newStatefulSet("gp2"), replicas)
+	if err != nil || !handled || phase != enterpriseApi.PhaseUpdating {
+		t.Errorf("storage migration should be in progress. phase: %s, err: %v", phase, err)
+	}
+	migrationStatus := &mgr.cr.Status.StorageMigration
+	if migrationStatus.Phase != enterpriseApi.UpdatePhaseInProgress || migrationStatus.StorageClassName != "gp3" || migrationStatus.StartTime == 0 {
+		t.Errorf("unexpected storage migration status %v", migrationStatus)
+	}
+	var statefulSet appsv1.StatefulSet
+	if c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-indexer"}, &statefulSet) == nil {
+		t.Errorf("statefulset should be deleted")
+	}
+
+	// first peer is decommissioned
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || !handled || migrationStatus.Peer != "splunk-stack1-indexer-0" || migrationStatus.Step != enterpriseApi.StorageMigrationStepDecommissioning {
+		t.Errorf("first peer should be decommissioned. err: %v, status: %v", err, migrationStatus)
+	}
+	mockSplunkClient.CheckRequests(t, "TestIndexerClusterStorageMigration")
+
+	// PVC and pod of the decommissioned peer are deleted
+	mgr.cr.Status.Peers[0].Status = "Down"
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || !handled || migrationStatus.Step != enterpriseApi.StorageMigrationStepReplacingVolume {
+		t.Errorf("volume of the peer should be replaced. err: %v, status: %v", err, migrationStatus)
+	}
+	var pvc corev1.PersistentVolumeClaim
+	var pod corev1.Pod
+	if c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-var-splunk-stack1-indexer-0"}, &pvc) == nil ||
+		c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-indexer-0"}, &pod) == nil {
+		t.Errorf("PVC and pod of the peer should be deleted")
+	}
+	if c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-var-splunk-stack1-indexer-1"}, &pvc) != nil {
+		t.Errorf("PVC of the other peer should be kept")
+	}
+
+	// peer rejoins the cluster with the new volume
+	c.AddObject(newPVC(0, "gp3"))
+	c.AddObject(newPod(0))
+	fixups = 3
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || !handled || migrationStatus.Step != enterpriseApi.StorageMigrationStepRejoining {
+		t.Errorf("migration should wait for the peer to rejoin. err: %v, status: %v", err, migrationStatus)
+	}
+	mgr.cr.Status.Peers[0].Status = "Up"
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || !handled || migrationStatus.Step != enterpriseApi.StorageMigrationStepReplicating || migrationStatus.RemainingBucketFixups != 3 {
+		t.Errorf("migration should wait for the replication and search factors. err: %v, status: %v", err, migrationStatus)
+	}
+
+	// next peer is picked once the replication and search factors are met
+	fixups = 0
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || !handled || migrationStatus.Peer != "" || len(migrationStatus.MigratedPeers) != 1 {
+		t.Errorf("first peer should be migrated. err: %v, status: %v", err, migrationStatus)
+	}
+	mgr.cr.Status.Peers[1].Status = "GracefulShutdown"
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || !handled || migrationStatus.Peer != "splunk-stack1-indexer-1" || migrationStatus.Step != enterpriseApi.StorageMigrationStepReplacingVolume {
+		t.Errorf("second peer should be migrated. err: %v, status: %v", err, migrationStatus)
+	}
+
+	// migration is complete
+	c.AddObject(newPVC(1, "gp3"))
+	c.AddObject(newPod(1))
+	mgr.cr.Status.Peers[1].Status = "Up"
+	for i := 0; i < 2; i++ {
+		_, _, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+		if err != nil {
+			t.Errorf("migrateStorage() returned error %v", err)
+		}
+	}
+	if migrationStatus.Phase != enterpriseApi.UpdatePhaseComplete || migrationStatus.CompletionTime == 0 || len(migrationStatus.MigratedPeers) != 2 {
+		t.Errorf("storage migration should be complete. status: %v", migrationStatus)
+	}
+	_, handled, err = mgr.migrateStorage(ctx, newStatefulSet("gp3"), replicas)
+	if err != nil || handled {
+		t.Errorf("nothing should be left to migrate. err: %v", err)
+	}
+}
+
+func TestIndexerClusterStorageMigrationBlocked(t *testing.T) {
+	ctx := context.TODO()
+	var replicas int32 = 1
+	storageClassName := "gp2"
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-indexer", Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "pvc-var"},
+					Spec:       corev1.PersistentVolumeClaimSpec{StorageClassName: &storageClassName},
+				},
+			},
+		},
+		Status: appsv1.StatefulSetStatus{Replicas: replicas, ReadyReplicas: replicas},
+	}
+
+	mgr := getIndexerClusterPodManager("TestIndexerClusterStorageMigrationBlocked", nil, &spltest.MockHTTPClient{}, replicas)
+	mgr.c = spltest.NewMockClient()
+	mgr.cr.Spec.VarVolumeStorageConfig.StorageClassName = "gp3"
+	mgr.storageMigrationBlocker = "site1"
+	_, handled, err := mgr.migrateStorage(ctx, statefulSet, replicas)
+	if err != nil || handled || mgr.cr.Status.StorageMigration.Phase != "" || mgr.cr.Status.StorageMigration.Message == "" {
+		t.Errorf("storage migration should wait for the other site. err: %v, status: %v", err, mgr.cr.Status.StorageMigration)
+	}
+}
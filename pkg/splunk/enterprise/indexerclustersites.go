@@ -0,0 +1,411 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/go-logr/logr"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splctrl "github.com/splunk/splunk-operator/pkg/splunk/controller"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// indexerClusterSiteNameRegex matches the names of the resources of an indexer cluster site, with the name of the
+// indexer cluster and of the site
+var indexerClusterSiteNameRegex = regexp.MustCompile(`^(.+)-(site[1-9][0-9]*)$`)
+
+// getIndexerClusterSiteName returns the name of the resources of an indexer cluster site
+func getIndexerClusterSiteName(identifier string, site string) string {
+	return fmt.Sprintf("%s-%s", identifier, site)
+}
+
+// getIndexerClusterImageCR returns the indexer cluster whose statefulset runs the image of the indexers. The sites
+// of a multisite indexer cluster run the same image, in a statefulset per site
+func getIndexerClusterImageCR(cr *enterpriseApi.IndexerCluster) *enterpriseApi.IndexerCluster {
+	if len(cr.Spec.Sites) == 0 {
+		return cr
+	}
+	return getIndexerClusterSite(cr, &cr.Spec.Sites[0])
+}
+
+// getIndexerClusterSite returns the indexer cluster of a site, with the spec and the state of the site. It
+// shares the UID of the indexer cluster, while the resources of the site are named after it
+func getIndexerClusterSite(cr *enterpriseApi.IndexerCluster, site *enterpriseApi.IndexerClusterSiteSpec) *enterpriseApi.IndexerCluster {
+	siteCR := cr.DeepCopy()
+	siteCR.ObjectMeta.Name = getIndexerClusterSiteName(cr.GetName(), site.Name)
+	siteCR.Spec.Sites = nil
+	siteCR.Spec.Replicas = site.Replicas
+	if site.Resources != nil {
+		siteCR.Spec.Resources = *site.Resources
+	}
+	addSiteNodeAffinity(&siteCR.Spec.Affinity, site.Zone, site.NodeSelector)
+
+	// peers of the site, in the order of the pods
+	peerPrefix := GetSplunkStatefulsetName(SplunkIndexer, siteCR.GetName()) + "-"
+	siteCR.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{}
+	for _, peer := range cr.Status.Peers {
+		if strings.HasPrefix(peer.Name, peerPrefix) {
+			siteCR.Status.Peers = append(siteCR.Status.Peers, peer)
+		}
+	}
+	siteCR.Status.VolumeResize = nil
+	for _, volumeResize := range cr.Status.VolumeResize {
+		if strings.HasPrefix(volumeResize.Pod, peerPrefix) {
+			siteCR.Status.VolumeResize = append(siteCR.Status.VolumeResize, volumeResize)
+		}
+	}
+
+	siteStatus := getIndexerClusterSiteStatus(cr, site.Name)
+	siteCR.Status.IndexerSecretChanged = siteStatus.IndexerSecretChanged
+	if siteCR.Status.IndexerSecretChanged == nil {
+		siteCR.Status.IndexerSecretChanged = []bool{}
+	}
+	siteCR.Status.UpdateStatus = siteStatus.UpdateStatus
+	siteCR.Status.DecommissionStatus = siteStatus.DecommissionStatus
+	siteCR.Status.AutoscalingStatus = siteStatus.AutoscalingStatus
+	siteCR.Status.StorageMigration = siteStatus.StorageMigration
+	siteCR.Status.Sites = nil
+	return siteCR
+}
+
+// getIndexerClusterSiteStatus returns the last known status of an indexer cluster site
+func getIndexerClusterSiteStatus(cr *enterpriseApi.IndexerCluster, site string) enterpriseApi.IndexerClusterSiteStatus {
+	for _, siteStatus := range cr.Status.Sites {
+		if siteStatus.Name == site {
+			return siteStatus
+		}
+	}
+	return enterpriseApi.IndexerClusterSiteStatus{Name: site}
+}
+
+// addSiteNodeAffinity restricts the nodes of the pods of a site to the zone and node selector of the site
+func addSiteNodeAffinity(affinity *corev1.Affinity, zone string, nodeSelector map[string]string) {
+	var requirements []corev1.NodeSelectorRequirement
+	if zone != "" {
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      corev1.LabelTopologyZone,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{zone},
+		})
+	}
+
+	// sort the node selector, so that the statefulset is not updated on each reconcile
+	keys := make([]string, 0, len(nodeSelector))
+	for key := range nodeSelector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		requirements = append(requirements, corev1.NodeSelectorRequirement{
+			Key:      key,
+			Operator: corev1.NodeSelectorOpIn,
+			Values:   []string{nodeSelector[key]},
+		})
+	}
+
+	if len(requirements) == 0 {
+		return
+	}
+	if affinity.NodeAffinity == nil {
+		affinity.NodeAffinity = &corev1.NodeAffinity{}
+	}
+	if affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{}
+	}
+	required := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution
+	if len(required.NodeSelectorTerms) == 0 {
+		required.NodeSelectorTerms = []corev1.NodeSelectorTerm{{}}
+	}
+
+	// node selector terms are ORed, so the site requirements are added to each of them
+	for i := range required.NodeSelectorTerms {
+		required.NodeSelectorTerms[i].MatchExpressions = append(required.NodeSelectorTerms[i].MatchExpressions, requirements...)
+	}
+}
+
+// setIndexerClusterSiteOwner makes the indexer cluster the owner of a resource of one of its sites
+func setIndexerClusterSiteOwner(obj metav1.Object, cr *enterpriseApi.IndexerCluster) {
+	ownerRefs := []metav1.OwnerReference{}
+	for _, ownerRef := range obj.GetOwnerReferences() {
+		if ownerRef.UID != cr.GetUID() {
+			ownerRefs = append(ownerRefs, ownerRef)
+		}
+	}
+	obj.SetOwnerReferences(append(ownerRefs, splcommon.AsOwner(cr, true)))
+}
+
+// getIndexerClusterService returns a service of the indexer cluster. The services of a multisite indexer cluster
+// select the peers of all its sites
+func getIndexerClusterService(ctx context.Context, cr *enterpriseApi.IndexerCluster, isHeadless bool) *corev1.Service {
+	service := getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkIndexer, isHeadless)
+	if len(cr.Spec.Sites) > 0 {
+		delete(service.Spec.Selector, splcommon.GetLabelTypes()["instance"])
+		service.Spec.Selector[indexerClusterSiteLabel] = cr.GetName()
+	}
+	return service
+}
+
+// getIndexerSiteStatefulSet returns a Kubernetes StatefulSet object for the peers of an indexer cluster site
+func getIndexerSiteStatefulSet(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster, siteCR *enterpriseApi.IndexerCluster, site string) (*appsv1.StatefulSet, error) {
+	clusterManagerURL := GetSplunkServiceName(SplunkClusterManager, cr.Spec.ClusterManagerRef.Name, false)
+	if cr.Spec.ClusterManagerRef.Namespace != "" {
+		clusterManagerURL = splcommon.GetServiceFQDN(cr.Spec.ClusterManagerRef.Namespace, clusterManagerURL)
+	}
+	extraEnv := []corev1.EnvVar{
+		{Name: "SPLUNK_SITE", Value: site},
+		{Name: "SPLUNK_MULTISITE_MASTER", Value: clusterManagerURL},
+	}
+	statefulSet, err := getSplunkStatefulSet(ctx, client, siteCR, &siteCR.Spec.CommonSplunkSpec, SplunkIndexer, siteCR.Spec.Replicas, extraEnv)
+	if err != nil {
+		return nil, err
+	}
+	setIndexerClusterSiteOwner(statefulSet, cr)
+
+	// the instance label of the site peers is the one of their site, so the services of the indexer cluster select
+	// them with the name of the indexer cluster
+	statefulSet.Spec.Template.ObjectMeta.Labels[indexerClusterSiteLabel] = cr.GetName()
+	return statefulSet, nil
+}
+
+// applyIndexerClusterSites reconciles the sites of a multisite indexer cluster, with a StatefulSet per site, and
+// aggregates the status of the site peers
+func applyIndexerClusterSites(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster, namespaceScopedSecret *corev1.Secret, log logr.Logger) (enterpriseApi.Phase, error) {
+	phase := enterpriseApi.PhaseReady
+	peers := []enterpriseApi.IndexerClusterMemberStatus{}
+	indexerSecretChanged := []bool{}
+	var volumeResize []enterpriseApi.VolumeResizeStatus
+	siteStatuses := []enterpriseApi.IndexerClusterSiteStatus{}
+	var replicas, readyReplicas int32
+
+	// the storage of the sites is migrated one site at a time
+	migratingSite := ""
+	for _, siteStatus := range cr.Status.Sites {
+		if siteStatus.StorageMigration.Phase == enterpriseApi.UpdatePhaseInProgress {
+			migratingSite = siteStatus.Name
+			break
+		}
+	}
+
+	newSplunkClient, err := getSplunkClientFunc(ctx, client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return enterpriseApi.PhaseError, err
+	}
+
+	for i := range cr.Spec.Sites {
+		site := &cr.Spec.Sites[i]
+		siteCR := getIndexerClusterSite(cr, site)
+
+		// create or update a headless service for the site peers
+		service := getSplunkService(ctx, siteCR, &siteCR.Spec.CommonSplunkSpec, SplunkIndexer, true)
+		setIndexerClusterSiteOwner(service, cr)
+		err := splctrl.ApplyService(ctx, client, service)
+		if err != nil {
+			return enterpriseApi.PhaseError, err
+		}
+
+		// create or update statefulset for the site peers
+		statefulSet, err := getIndexerSiteStatefulSet(ctx, client, cr, siteCR, site.Name)
+		if err != nil {
+			return enterpriseApi.PhaseError, err
+		}
+
+		mgr := newIndexerClusterPodManager(log.WithValues("site", site.Name), siteCR, namespaceScopedSecret, newSplunkClient)
+		if migratingSite != site.Name {
+			mgr.storageMigrationBlocker = migratingSite
+		}
+		sitePhase, err := mgr.Update(ctx, client, statefulSet, siteCR.Spec.Replicas)
+		if err != nil {
+			return enterpriseApi.PhaseError, fmt.Errorf("update of site %s failed: %w", site.Name, err)
+		}
+		if migratingSite == "" && siteCR.Status.StorageMigration.Phase == enterpriseApi.UpdatePhaseInProgress {
+			migratingSite = site.Name
+		}
+
+		siteStatus := enterpriseApi.IndexerClusterSiteStatus{
+			Name:                 site.Name,
+			Phase:                sitePhase,
+			Replicas:             site.Replicas,
+			ReadyReplicas:        siteCR.Status.ReadyReplicas,
+			IndexerSecretChanged: siteCR.Status.IndexerSecretChanged,
+			UpdateStatus:         siteCR.Status.UpdateStatus,
+			DecommissionStatus:   siteCR.Status.DecommissionStatus,
+			AutoscalingStatus:    siteCR.Status.AutoscalingStatus,
+			StorageMigration:     siteCR.Status.StorageMigration,
+		}
+		for _, peer := range siteCR.Status.Peers {
+			if peer.Status == "Up" {
+				siteStatus.UpPeers++
+			}
+			siteStatus.BucketCount += peer.BucketCount
+		}
+		siteStatuses = append(siteStatuses, siteStatus)
+		peers = append(peers, siteCR.Status.Peers...)
+		indexerSecretChanged = append(indexerSecretChanged, siteCR.Status.IndexerSecretChanged...)
+		volumeResize = append(volumeResize, siteCR.Status.VolumeResize...)
+		replicas += site.Replicas
+		readyReplicas += siteCR.Status.ReadyReplicas
+
+		// the cluster manager state is the same for all the sites
+		cr.Status.Initialized = siteCR.Status.Initialized
+		cr.Status.IndexingReady = siteCR.Status.IndexingReady
+		cr.Status.ServiceReady = siteCR.Status.ServiceReady
+		cr.Status.MaintenanceMode = siteCR.Status.MaintenanceMode
+		cr.Status.NamespaceSecretResourceVersion = siteCR.Status.NamespaceSecretResourceVersion
+		for secret, changed := range siteCR.Status.IdxcPasswordChangedSecrets {
+			cr.Status.IdxcPasswordChangedSecrets[secret] = changed
+		}
+
+		phase = getIndexerClusterSitesPhase(phase, sitePhase)
+	}
+
+	cr.Status.Sites = siteStatuses
+	cr.Status.Peers = peers
+	cr.Status.IndexerSecretChanged = indexerSecretChanged
+	cr.Status.VolumeResize = volumeResize
+	cr.Status.Replicas = replicas
+	cr.Status.ReadyReplicas = readyReplicas
+	return phase, nil
+}
+
+// getIndexerClusterSitesPhase returns the phase of a multisite indexer cluster, which is ready once all its sites are
+func getIndexerClusterSitesPhase(phase enterpriseApi.Phase, sitePhase enterpriseApi.Phase) enterpriseApi.Phase {
+	if phase == enterpriseApi.PhaseError || sitePhase == enterpriseApi.PhaseError {
+		return enterpriseApi.PhaseError
+	}
+	if phase == enterpriseApi.PhaseReady {
+		return sitePhase
+	}
+	return phase
+}
+
+// validateIndexerClusterSites checks the sites of a multisite indexer cluster
+func validateIndexerClusterSites(cr *enterpriseApi.IndexerCluster) error {
+	if len(cr.Spec.ClusterMasterRef.Name) > 0 && len(cr.Spec.ClusterManagerRef.Name) == 0 {
+		return fmt.Errorf("IndexerCluster sites require a clusterManagerRef")
+	}
+
+	// the peers of an indexer cluster without sites are not moved to a site
+	if len(cr.Status.Sites) == 0 && len(cr.Status.Peers) > 0 {
+		return fmt.Errorf("sites can not be added to an existing IndexerCluster")
+	}
+
+	sites := make(map[string]bool)
+	for _, site := range cr.Spec.Sites {
+		if sites[site.Name] {
+			return fmt.Errorf("site %s is defined more than once", site.Name)
+		}
+		sites[site.Name] = true
+	}
+
+	// the peers of a removed site would not be decommissioned
+	for _, siteStatus := range cr.Status.Sites {
+		if !sites[siteStatus.Name] {
+			return fmt.Errorf("site %s can not be removed from the IndexerCluster", siteStatus.Name)
+		}
+	}
+	return nil
+}
+
+// validateIndexerClusterSiteNames checks that the resources of the sites of an indexer cluster are not the ones of
+// another IndexerCluster, and that the resources of an indexer cluster are not the ones of a site of another one
+func validateIndexerClusterSiteNames(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.IndexerCluster) error {
+	for _, site := range cr.Spec.Sites {
+		siteName := getIndexerClusterSiteName(cr.GetName(), site.Name)
+		err := c.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: siteName}, &enterpriseApi.IndexerCluster{})
+		if err == nil {
+			return fmt.Errorf("site %s conflicts with the IndexerCluster %s, rename one of them", site.Name, siteName)
+		}
+		if !k8serrors.IsNotFound(err) {
+			return err
+		}
+	}
+
+	match := indexerClusterSiteNameRegex.FindStringSubmatch(cr.GetName())
+	if match == nil {
+		return nil
+	}
+	other := &enterpriseApi.IndexerCluster{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: cr.GetNamespace(), Name: match[1]}, other)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	for _, site := range other.Spec.Sites {
+		if site.Name == match[2] {
+			return fmt.Errorf("IndexerCluster conflicts with the site %s of the IndexerCluster %s, rename one of them", site.Name, match[1])
+		}
+	}
+	return nil
+}
+
+// getSiteRepFactor parses the site_replication_factor of the cluster manager, e.g. origin:2,site1:1,total:3
+func getSiteRepFactor(siteRepFactor string) map[string]int32 {
+	factors := make(map[string]int32)
+	for _, factor := range strings.Split(siteRepFactor, ",") {
+		kv := strings.SplitN(strings.TrimSpace(factor), ":", 2)
+		if len(kv) != 2 {
+			continue
+		}
+		count, err := strconv.ParseInt(strings.TrimSpace(kv[1]), 10, 32)
+		if err != nil {
+			continue
+		}
+		factors[strings.TrimSpace(kv[0])] = int32(count)
+	}
+	return factors
+}
+
+// verifySiteRFPeers verifies the replicas of each site against the site_replication_factor of the cluster
+// manager. If the replicas of a site are less than its RF number of peers, then they are set to it
+func (mgr *indexerClusterPodManager) verifySiteRFPeers(clusterInfo *splclient.ClusterInfo) error {
+	if clusterInfo.MultiSite != "true" {
+		return fmt.Errorf("IndexerCluster sites require a multisite cluster manager")
+	}
+
+	siteRepFactor := getSiteRepFactor(clusterInfo.SiteReplicationFactor)
+	var replicas int32
+	for i := range mgr.cr.Spec.Sites {
+		site := &mgr.cr.Spec.Sites[i]
+		replicationFactor := siteRepFactor["origin"]
+		if siteRepFactor[site.Name] > replicationFactor {
+			replicationFactor = siteRepFactor[site.Name]
+		}
+		if site.Replicas < replicationFactor {
+			mgr.log.Info("Changing number of site replicas as it is less than RF number of peers", "site", site.Name, "replicas", site.Replicas)
+			site.Replicas = replicationFactor
+		}
+		replicas += site.Replicas
+	}
+
+	if replicas < siteRepFactor["total"] {
+		return fmt.Errorf("IndexerCluster sites have %d peers, less than the total site_replication_factor %d", replicas, siteRepFactor["total"])
+	}
+	return nil
+}
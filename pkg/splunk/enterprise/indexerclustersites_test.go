@@ -0,0 +1,395 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/go-logr/logr"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func getIndexerClusterSitesTestCR() *enterpriseApi.IndexerCluster {
+	cr := &enterpriseApi.IndexerCluster{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "IndexerCluster",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+			UID:       "idxc-uid",
+		},
+	}
+	cr.Spec.ClusterManagerRef.Name = "manager1"
+	cr.Spec.Sites = []enterpriseApi.IndexerClusterSiteSpec{
+		{Name: "site1", Replicas: 3, Zone: "us-west-2a"},
+		{Name: "site2", Replicas: 2, Zone: "us-west-2b", NodeSelector: map[string]string{"pool": "indexers", "disk": "ssd"}},
+	}
+	return cr
+}
+
+func TestGetIndexerClusterSite(t *testing.T) {
+	cr := getIndexerClusterSitesTestCR()
+	cr.Spec.Sites[1].Resources = &corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("8")},
+	}
+	cr.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{
+		{Name: "splunk-stack1-site1-indexer-0"},
+		{Name: "splunk-stack1-site2-indexer-0"},
+		{Name: "splunk-stack1-site2-indexer-1"},
+	}
+	cr.Status.Sites = []enterpriseApi.IndexerClusterSiteStatus{
+		{Name: "site2", DecommissionStatus: enterpriseApi.IndexerClusterDecommissionStatus{Peer: "splunk-stack1-site2-indexer-1"}},
+	}
+
+	siteCR := getIndexerClusterSite(cr, &cr.Spec.Sites[1])
+	if siteCR.GetName() != "stack1-site2" || siteCR.GetUID() != cr.GetUID() || siteCR.Spec.Replicas != 2 || len(siteCR.Spec.Sites) != 0 {
+		t.Errorf("unexpected site indexer cluster %s %v", siteCR.GetName(), siteCR.Spec)
+	}
+	if siteCR.Spec.Resources.Limits.Cpu().String() != "8" {
+		t.Errorf("site resources should be applied")
+	}
+	if len(siteCR.Status.Peers) != 2 || siteCR.Status.Peers[1].Name != "splunk-stack1-site2-indexer-1" {
+		t.Errorf("site peers should be filtered. peers: %v", siteCR.Status.Peers)
+	}
+	if siteCR.Status.DecommissionStatus.Peer != "splunk-stack1-site2-indexer-1" {
+		t.Errorf("site decommission status should be restored")
+	}
+
+	// indexer cluster is not modified
+	if cr.Spec.Affinity.NodeAffinity != nil || len(cr.Status.Peers) != 3 {
+		t.Errorf("indexer cluster should not be modified")
+	}
+}
+
+func TestAddSiteNodeAffinity(t *testing.T) {
+	site := &enterpriseApi.IndexerClusterSiteSpec{Name: "site2", Zone: "us-west-2b", NodeSelector: map[string]string{"pool": "indexers", "disk": "ssd"}}
+	want := []corev1.NodeSelectorRequirement{
+		{Key: corev1.LabelTopologyZone, Operator: corev1.NodeSelectorOpIn, Values: []string{"us-west-2b"}},
+		{Key: "disk", Operator: corev1.NodeSelectorOpIn, Values: []string{"ssd"}},
+		{Key: "pool", Operator: corev1.NodeSelectorOpIn, Values: []string{"indexers"}},
+	}
+
+	affinity := corev1.Affinity{}
+	addSiteNodeAffinity(&affinity, site.Zone, site.NodeSelector)
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || !reflect.DeepEqual(terms[0].MatchExpressions, want) {
+		t.Errorf("unexpected node affinity %v", terms)
+	}
+
+	// site requirements are added to each node selector term
+	userTerm := corev1.NodeSelectorRequirement{Key: "arch", Operator: corev1.NodeSelectorOpIn, Values: []string{"amd64"}}
+	affinity = corev1.Affinity{
+		NodeAffinity: &corev1.NodeAffinity{
+			RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+				NodeSelectorTerms: []corev1.NodeSelectorTerm{
+					{MatchExpressions: []corev1.NodeSelectorRequirement{userTerm}},
+					{MatchExpressions: []corev1.NodeSelectorRequirement{userTerm}},
+				},
+			},
+		},
+	}
+	addSiteNodeAffinity(&affinity, site.Zone, site.NodeSelector)
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		if !reflect.DeepEqual(term.MatchExpressions, append([]corev1.NodeSelectorRequirement{userTerm}, want...)) {
+			t.Errorf("unexpected node selector term %v", term)
+		}
+	}
+
+	// nothing to add for a site without zone and node selector
+	affinity = corev1.Affinity{}
+	addSiteNodeAffinity(&affinity, "", nil)
+	if affinity.NodeAffinity != nil {
+		t.Errorf("node affinity should not be set")
+	}
+}
+
+func TestGetIndexerSiteStatefulSet(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	_, err := splutil.ApplyNamespaceScopedSecretObject(ctx, c, "test")
+	if err != nil {
+		t.Errorf("Failed to create namespace scoped object")
+	}
+
+	cr := getIndexerClusterSitesTestCR()
+	siteCR := getIndexerClusterSite(cr, &cr.Spec.Sites[0])
+	statefulSet, err := getIndexerSiteStatefulSet(ctx, c, cr, siteCR, "site1")
+	if err != nil {
+		t.Errorf("getIndexerSiteStatefulSet() returned error: %v", err)
+	}
+
+	if statefulSet.GetName() != "splunk-stack1-site1-indexer" || *statefulSet.Spec.Replicas != 3 || statefulSet.Spec.ServiceName != "splunk-stack1-site1-indexer-headless" {
+		t.Errorf("unexpected statefulset %s", statefulSet.GetName())
+	}
+
+	ownerRefs := statefulSet.GetOwnerReferences()
+	if len(ownerRefs) != 1 || ownerRefs[0].Name != "stack1" || ownerRefs[0].UID != cr.GetUID() {
+		t.Errorf("indexer cluster should own the site statefulset. owners: %v", ownerRefs)
+	}
+
+	env := make(map[string]string)
+	for _, e := range statefulSet.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["SPLUNK_SITE"] != "site1" || env["SPLUNK_MULTISITE_MASTER"] != "splunk-manager1-cluster-manager-service" {
+		t.Errorf("unexpected site env %v", env)
+	}
+
+	if statefulSet.Spec.Template.ObjectMeta.Labels[indexerClusterSiteLabel] != "stack1" {
+		t.Errorf("site peers should be labeled with the indexer cluster. labels: %v", statefulSet.Spec.Template.ObjectMeta.Labels)
+	}
+
+	terms := statefulSet.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || terms[0].MatchExpressions[0].Values[0] != "us-west-2a" {
+		t.Errorf("site peers should be scheduled in the site zone")
+	}
+}
+
+func TestGetIndexerClusterService(t *testing.T) {
+	ctx := context.TODO()
+	cr := getIndexerClusterSitesTestCR()
+	instanceLabel := splcommon.GetLabelTypes()["instance"]
+
+	service := getIndexerClusterService(ctx, cr, false)
+	if _, ok := service.Spec.Selector[instanceLabel]; ok || service.Spec.Selector[indexerClusterSiteLabel] != "stack1" {
+		t.Errorf("service of a multisite indexer cluster should select the peers of all its sites only. selector: %v", service.Spec.Selector)
+	}
+
+	cr.Spec.Sites = nil
+	service = getIndexerClusterService(ctx, cr, false)
+	if _, ok := service.Spec.Selector[indexerClusterSiteLabel]; ok || service.Spec.Selector[instanceLabel] != "splunk-stack1-indexer" {
+		t.Errorf("unexpected service selector %v", service.Spec.Selector)
+	}
+}
+
+func TestApplyIndexerClusterSites(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	namespaceScopedSecret, err := splutil.ApplyNamespaceScopedSecretObject(ctx, c, "test")
+	if err != nil {
+		t.Errorf("Failed to create namespace scoped object")
+	}
+
+	cr := getIndexerClusterSitesTestCR()
+	cr.Status.IdxcPasswordChangedSecrets = make(map[string]bool)
+	cr.Status.ClusterManagerPhase = enterpriseApi.PhasePending
+
+	phase, err := applyIndexerClusterSites(ctx, c, cr, namespaceScopedSecret, logr.Discard())
+	if err != nil || phase != enterpriseApi.PhasePending {
+		t.Errorf("sites should be pending, until the cluster manager is ready. phase: %s, err: %v", phase, err)
+	}
+
+	for _, site := range []string{"site1", "site2"} {
+		service := corev1.Service{}
+		err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-" + site + "-indexer-headless"}, &service)
+		if err != nil {
+			t.Errorf("headless service of %s should be created. err: %v", site, err)
+		}
+	}
+
+	if len(cr.Status.Sites) != 2 || cr.Status.Sites[1].Name != "site2" || cr.Status.Sites[1].Replicas != 2 || cr.Status.Sites[1].Phase != enterpriseApi.PhasePending {
+		t.Errorf("unexpected site status %v", cr.Status.Sites)
+	}
+	if cr.Status.Replicas != 5 || len(cr.Status.Peers) != 0 {
+		t.Errorf("unexpected indexer cluster status %v", cr.Status)
+	}
+
+	// site statefulsets are created once the cluster manager is ready
+	cr.Status.ClusterManagerPhase = enterpriseApi.PhaseReady
+	savedGetClusterManagerInfoCall := GetClusterManagerInfoCall
+	defer func() { GetClusterManagerInfoCall = savedGetClusterManagerInfoCall }()
+	GetClusterManagerInfoCall = func(ctx context.Context, mgr *indexerClusterPodManager) (*splclient.ClusterManagerInfo, error) {
+		return &splclient.ClusterManagerInfo{}, nil
+	}
+	_, err = applyIndexerClusterSites(ctx, c, cr, namespaceScopedSecret, logr.Discard())
+	if err != nil {
+		t.Errorf("applyIndexerClusterSites() returned error: %v", err)
+	}
+	for _, site := range []string{"site1", "site2"} {
+		statefulSet := appsv1.StatefulSet{}
+		err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-" + site + "-indexer"}, &statefulSet)
+		if err != nil {
+			t.Errorf("statefulset of %s should be created. err: %v", site, err)
+		}
+	}
+}
+
+func TestApplyIndexerClusterManagerSitesUpgradePath(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := getIndexerClusterSitesTestCR()
+	cr.Spec.Image = "splunk/splunk:9.1.0"
+
+	// the sites are not created, until the cluster manager is reachable for the version upgrade
+	savedGetClusterInfoCall := GetClusterInfoCall
+	defer func() { GetClusterInfoCall = savedGetClusterInfoCall }()
+	GetClusterInfoCall = func(ctx context.Context, mgr *indexerClusterPodManager, mockCall bool) (*splclient.ClusterInfo, error) {
+		return nil, fmt.Errorf("cluster manager unreachable")
+	}
+	_, err := ApplyIndexerClusterManager(ctx, c, cr)
+	if err == nil || err.Error() != "could not get cluster info from cluster manager" {
+		t.Errorf("ApplyIndexerClusterManager() should halt on the upgrade path validation. err: %v", err)
+	}
+	for _, site := range []string{"site1", "site2"} {
+		statefulSet := appsv1.StatefulSet{}
+		err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-" + site + "-indexer"}, &statefulSet)
+		if err == nil {
+			t.Errorf("statefulset of %s should not be created", site)
+		}
+	}
+}
+
+func TestApplyIndexerClusterManagerSitesUpgradePolicy(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := getIndexerClusterSitesTestCR()
+	cr.Spec.Image = "splunk/splunk:9.1.2"
+	cr.Spec.UpgradePolicy.Enabled = true
+
+	savedGetClusterInfoCall := GetClusterInfoCall
+	defer func() { GetClusterInfoCall = savedGetClusterInfoCall }()
+	GetClusterInfoCall = func(ctx context.Context, mgr *indexerClusterPodManager, mockCall bool) (*splclient.ClusterInfo, error) {
+		return &splclient.ClusterInfo{MultiSite: "false"}, nil
+	}
+
+	// the version hop is validated against the image of the site statefulsets
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-site1-indexer", "splunk/splunk:8.1.14"))
+	_, err := ApplyIndexerClusterManager(ctx, c, cr)
+	if err != nil || cr.Status.Upgrade.Phase != enterpriseApi.UpgradePhaseBlocked || cr.Status.Upgrade.SourceVersion != "8.1.14" {
+		t.Errorf("upgrade of the sites should be blocked. err: %v, status: %v", err, cr.Status.Upgrade)
+	}
+	if len(cr.Status.Sites) != 0 {
+		t.Errorf("sites should not be updated while the upgrade is blocked. status: %v", cr.Status.Sites)
+	}
+}
+
+func TestGetIndexerClusterSitesPhase(t *testing.T) {
+	if getIndexerClusterSitesPhase(enterpriseApi.PhaseReady, enterpriseApi.PhaseReady) != enterpriseApi.PhaseReady {
+		t.Errorf("indexer cluster should be ready")
+	}
+	if getIndexerClusterSitesPhase(enterpriseApi.PhaseReady, enterpriseApi.PhaseScalingUp) != enterpriseApi.PhaseScalingUp {
+		t.Errorf("indexer cluster should be scaling up")
+	}
+	if getIndexerClusterSitesPhase(enterpriseApi.PhasePending, enterpriseApi.PhaseError) != enterpriseApi.PhaseError {
+		t.Errorf("indexer cluster should be in error")
+	}
+	if getIndexerClusterSitesPhase(enterpriseApi.PhasePending, enterpriseApi.PhaseReady) != enterpriseApi.PhasePending {
+		t.Errorf("indexer cluster should be pending")
+	}
+}
+
+func TestValidateIndexerClusterSites(t *testing.T) {
+	cr := getIndexerClusterSitesTestCR()
+	if err := validateIndexerClusterSites(cr); err != nil {
+		t.Errorf("validateIndexerClusterSites() returned error: %v", err)
+	}
+
+	cr.Spec.Sites = append(cr.Spec.Sites, enterpriseApi.IndexerClusterSiteSpec{Name: "site1", Replicas: 1})
+	if err := validateIndexerClusterSites(cr); err == nil {
+		t.Errorf("duplicate site should not be valid")
+	}
+
+	cr = getIndexerClusterSitesTestCR()
+	cr.Status.Sites = []enterpriseApi.IndexerClusterSiteStatus{{Name: "site1"}, {Name: "site3"}}
+	if err := validateIndexerClusterSites(cr); err == nil {
+		t.Errorf("removed site should not be valid")
+	}
+
+	cr = getIndexerClusterSitesTestCR()
+	cr.Status.Peers = []enterpriseApi.IndexerClusterMemberStatus{{Name: "splunk-stack1-indexer-0"}}
+	if err := validateIndexerClusterSites(cr); err == nil {
+		t.Errorf("sites should not be added to an existing indexer cluster")
+	}
+
+	cr = getIndexerClusterSitesTestCR()
+	cr.Spec.ClusterManagerRef.Name = ""
+	cr.Spec.ClusterMasterRef.Name = "master1"
+	if err := validateIndexerClusterSites(cr); err == nil {
+		t.Errorf("sites should require a cluster manager")
+	}
+}
+
+func TestValidateIndexerClusterSiteNames(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := getIndexerClusterSitesTestCR()
+	if err := validateIndexerClusterSiteNames(ctx, c, cr); err != nil {
+		t.Errorf("validateIndexerClusterSiteNames() returned error: %v", err)
+	}
+
+	// site named after another indexer cluster
+	other := &enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1-site2", Namespace: "test"},
+	}
+	c.AddObject(other)
+	if err := validateIndexerClusterSiteNames(ctx, c, cr); err == nil {
+		t.Errorf("site conflicting with another indexer cluster should not be valid")
+	}
+
+	// indexer cluster named after the site of another indexer cluster
+	c = spltest.NewMockClient()
+	c.AddObject(cr)
+	if err := validateIndexerClusterSiteNames(ctx, c, other); err == nil {
+		t.Errorf("indexer cluster conflicting with a site of another indexer cluster should not be valid")
+	}
+	other.Name = "stack1-site3"
+	if err := validateIndexerClusterSiteNames(ctx, c, other); err != nil {
+		t.Errorf("validateIndexerClusterSiteNames() returned error: %v", err)
+	}
+}
+
+func TestVerifySiteRFPeers(t *testing.T) {
+	factors := getSiteRepFactor("origin:2, site1:3,total:5")
+	if !reflect.DeepEqual(factors, map[string]int32{"origin": 2, "site1": 3, "total": 5}) {
+		t.Errorf("unexpected site replication factor %v", factors)
+	}
+
+	cr := getIndexerClusterSitesTestCR()
+	cr.Spec.Sites[0].Replicas = 1
+	cr.Spec.Sites[1].Replicas = 1
+	mgr := &indexerClusterPodManager{log: logr.Discard(), cr: cr}
+
+	err := mgr.verifySiteRFPeers(&splclient.ClusterInfo{MultiSite: "true", SiteReplicationFactor: "origin:2,site1:3,total:5"})
+	if err != nil {
+		t.Errorf("verifySiteRFPeers() returned error: %v", err)
+	}
+	if cr.Spec.Sites[0].Replicas != 3 || cr.Spec.Sites[1].Replicas != 2 {
+		t.Errorf("site replicas should be set to the RF number of peers. sites: %v", cr.Spec.Sites)
+	}
+
+	err = mgr.verifySiteRFPeers(&splclient.ClusterInfo{MultiSite: "true", SiteReplicationFactor: "origin:2,total:6"})
+	if err == nil {
+		t.Errorf("sites with less peers than the total site replication factor should not be valid")
+	}
+
+	err = mgr.verifySiteRFPeers(&splclient.ClusterInfo{MultiSite: "false", ReplicationFactor: 3})
+	if err == nil {
+		t.Errorf("sites should require a multisite cluster manager")
+	}
+}
@@ -0,0 +1,385 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// defaultPercentPeersToRestart is the percentage of the peers updated at a time, when not specified
+const defaultPercentPeersToRestart = 10
+
+// isRollingUpgradeStrategy checks if the peers are updated through the rolling upgrade of the cluster manager. An
+// update in progress is completed with the same strategy, even if the strategy is changed meanwhile
+func isRollingUpgradeStrategy(cr *enterpriseApi.IndexerCluster) bool {
+	if cr.Status.UpdateStatus.Phase == enterpriseApi.UpdatePhaseInProgress {
+		return cr.Status.UpdateStatus.Strategy == enterpriseApi.RollingUpgradeUpdateStrategy
+	}
+	return cr.Spec.UpdateStrategy.Type == enterpriseApi.RollingUpgradeUpdateStrategy
+}
+
+// getRollingUpgradeBatchSize returns the number of peers updated at a time
+func getRollingUpgradeBatchSize(cr *enterpriseApi.IndexerCluster, replicas int32) int32 {
+	percent := cr.Spec.UpdateStrategy.PercentPeersToRestart
+	if percent <= 0 || percent > 100 {
+		percent = defaultPercentPeersToRestart
+	}
+
+	batchSize := replicas * percent / 100
+	if batchSize < 1 {
+		batchSize = 1
+	}
+	return batchSize
+}
+
+// getPeerOrdinal returns the ordinal of the peer pod from its name
+func getPeerOrdinal(peerName string) (int32, error) {
+	n, err := strconv.ParseInt(peerName[strings.LastIndex(peerName, "-")+1:], 10, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid peer name %s", peerName)
+	}
+	return int32(n), nil
+}
+
+// getSiteUpdateRank returns the position of the site in the site update order. Sites not listed go last
+func getSiteUpdateRank(siteOrder []string, site string) int {
+	for i, s := range siteOrder {
+		if s == site {
+			return i
+		}
+	}
+	return len(siteOrder)
+}
+
+// isSameClusterManager checks if both the indexer clusters refer to the same cluster manager
+func isSameClusterManager(cr, other *enterpriseApi.IndexerCluster) bool {
+	return cr.Spec.ClusterManagerRef.Name == other.Spec.ClusterManagerRef.Name &&
+		cr.Spec.ClusterMasterRef.Name == other.Spec.ClusterMasterRef.Name &&
+		getClusterManagerNamespace(cr) == getClusterManagerNamespace(other)
+}
+
+// isSameIndexerCluster checks if both the indexer clusters are the same custom resource
+func isSameIndexerCluster(cr, other *enterpriseApi.IndexerCluster) bool {
+	return cr.GetName() == other.GetName() && cr.GetNamespace() == other.GetNamespace()
+}
+
+// getSiteUpdateBlocker returns the indexer cluster of another site, which must be updated before this one. Returns
+// nil, if the update can go ahead
+func getSiteUpdateBlocker(cr *enterpriseApi.IndexerCluster, site string, indexerList []enterpriseApi.IndexerCluster, getSite func(*enterpriseApi.IndexerCluster) string) *enterpriseApi.IndexerCluster {
+	if !cr.Spec.UpdateStrategy.SiteBySite {
+		return nil
+	}
+
+	siteOrder := cr.Spec.UpdateStrategy.SiteOrder
+	rank := getSiteUpdateRank(siteOrder, site)
+	for i := range indexerList {
+		other := &indexerList[i]
+		if isSameIndexerCluster(cr, other) || !isSameClusterManager(cr, other) {
+			continue
+		}
+
+		switch other.Status.UpdateStatus.Phase {
+		case enterpriseApi.UpdatePhaseInProgress:
+			// only one site at a time
+			return other
+
+		case enterpriseApi.UpdatePhasePending:
+			// sites waiting for their turn go in the site order, then in the alphabetical order
+			otherSite := getSite(other)
+			otherRank := getSiteUpdateRank(siteOrder, otherSite)
+			if otherRank < rank || (otherRank == rank && (otherSite < site || (otherSite == site && other.GetName() < cr.GetName()))) {
+				return other
+			}
+		}
+	}
+
+	return nil
+}
+
+// isAnyOtherSiteUpdating checks if another indexer cluster of the same cluster manager is still updating its peers
+func isAnyOtherSiteUpdating(cr *enterpriseApi.IndexerCluster, indexerList []enterpriseApi.IndexerCluster) bool {
+	for i := range indexerList {
+		other := &indexerList[i]
+		if isSameIndexerCluster(cr, other) || !isSameClusterManager(cr, other) {
+			continue
+		}
+
+		if other.Status.UpdateStatus.Phase == enterpriseApi.UpdatePhaseInProgress || other.Status.UpdateStatus.Phase == enterpriseApi.UpdatePhasePending {
+			return true
+		}
+	}
+	return false
+}
+
+// getOtherSites returns the indexer clusters referring to the same cluster manager, whose sites can be located in the
+// namespaces allowed by the cluster manager
+func (mgr *indexerClusterPodManager) getOtherSites(ctx context.Context) ([]enterpriseApi.IndexerCluster, error) {
+	peerNamespaces, err := getClusterManagerPeerNamespaces(ctx, mgr.c, mgr.cr)
+	if err != nil {
+		return nil, err
+	}
+	indexerList, err := getClusterManagerIndexerClusterList(ctx, mgr.c, mgr.cr, getClusterManagerNamespace(mgr.cr), mgr.cr.Spec.ClusterManagerRef.Name, peerNamespaces)
+	if err != nil {
+		return nil, err
+	}
+	return indexerList.Items, nil
+}
+
+// getClusterManagerPodName returns the name of the cluster manager pod
+func getClusterManagerPodName(cr *enterpriseApi.IndexerCluster) (string, error) {
+	if len(cr.Spec.ClusterManagerRef.Name) > 0 {
+		return fmt.Sprintf("splunk-%s-cluster-manager-%s", cr.Spec.ClusterManagerRef.Name, "0"), nil
+	} else if len(cr.Spec.ClusterMasterRef.Name) > 0 {
+		return fmt.Sprintf("splunk-%s-cluster-master-%s", cr.Spec.ClusterMasterRef.Name, "0"), nil
+	}
+	return "", fmt.Errorf("empty cluster manager reference")
+}
+
+// setRollingUpgradeMode prepares the cluster manager for the peer updates, or restores it once the peers are
+// updated. In the searchable mode, the cluster is put in the rolling upgrade mode, so that the peers can be taken
+// offline without affecting the searches. Otherwise, the cluster is put in the maintenance mode, to avoid the
+// bucket fixups while the peers are restarted
+var setRollingUpgradeMode = func(ctx context.Context, mgr *indexerClusterPodManager, searchable bool, enable bool) error {
+	if searchable {
+		cm := mgr.getClusterManagerClient(ctx)
+		if !enable {
+			return cm.FinalizeRollingUpgrade()
+		}
+
+		// the rolling upgrade could have been started by the indexer cluster of another site
+		status, err := cm.GetClusterManagerStatus()
+		if err != nil {
+			return err
+		}
+		if status.RollingRestartOrUpgrade {
+			return nil
+		}
+		return cm.InitRollingUpgrade()
+	}
+
+	cmPodName, err := getClusterManagerPodName(mgr.cr)
+	if err != nil {
+		return err
+	}
+	podExecClient := getClusterManagerPodExecClient(mgr.c, mgr.cr, cmPodName)
+	return SetClusterMaintenanceMode(ctx, mgr.c, mgr.cr, enable, cmPodName, podExecClient)
+}
+
+// rollingUpgrade updates the peers with pending updates in batches, through the rolling upgrade of the cluster
+// manager. Returns false when there is nothing to do for the rolling upgrade, in which case the regular scaling
+// and pod updates apply
+func (mgr *indexerClusterPodManager) rollingUpgrade(ctx context.Context, statefulSet *appsv1.StatefulSet, desiredReplicas int32) (enterpriseApi.Phase, bool, error) {
+	updateStatus := &mgr.cr.Status.UpdateStatus
+	replicas := *statefulSet.Spec.Replicas
+	updateRevision := statefulSet.Status.UpdateRevision
+
+	// scaling goes first, unless an update is already in progress
+	if updateStatus.Phase != enterpriseApi.UpdatePhaseInProgress && (replicas != desiredReplicas || statefulSet.Status.ReadyReplicas != replicas) {
+		return enterpriseApi.PhaseReady, false, nil
+	}
+
+	// find the pods with pending updates, starting with the highest ordinal like the regular pod updates
+	pods := make(map[int32]*corev1.Pod)
+	var outdated []int32
+	var updatedPeers int32
+	for n := replicas - 1; n >= 0; n-- {
+		podName := fmt.Sprintf("%s-%d", statefulSet.GetName(), n)
+		namespacedName := types.NamespacedName{Namespace: statefulSet.GetNamespace(), Name: podName}
+		var pod corev1.Pod
+		err := mgr.c.Get(ctx, namespacedName, &pod)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				// pod is being recreated
+				continue
+			}
+			return enterpriseApi.PhaseError, true, err
+		}
+		pods[n] = &pod
+
+		if updateRevision != "" && updateRevision != pod.GetLabels()["controller-revision-hash"] {
+			outdated = append(outdated, n)
+		} else if isPodReady(&pod) {
+			updatedPeers++
+		}
+	}
+
+	if updateStatus.Phase != enterpriseApi.UpdatePhaseInProgress {
+		if len(outdated) == 0 {
+			if updateStatus.Phase == enterpriseApi.UpdatePhasePending {
+				updateStatus.Phase = ""
+				updateStatus.Message = ""
+			}
+			return enterpriseApi.PhaseReady, false, nil
+		}
+
+		// wait for the indexer clusters of the other sites, when updating site by site
+		if mgr.cr.Spec.UpdateStrategy.SiteBySite {
+			indexerList, err := mgr.getOtherSites(ctx)
+			if err != nil {
+				return enterpriseApi.PhaseError, true, err
+			}
+			blocker := getSiteUpdateBlocker(mgr.cr, getSiteName(ctx, mgr.c, mgr.cr), indexerList, func(cr *enterpriseApi.IndexerCluster) string {
+				return getSiteName(ctx, mgr.c, cr)
+			})
+			if blocker != nil {
+				updateStatus.Phase = enterpriseApi.UpdatePhasePending
+				updateStatus.Message = fmt.Sprintf("waiting for the indexer cluster %s to update its peers", blocker.GetName())
+				mgr.log.Info("Waiting for the other sites to update", "indexerCluster", blocker.GetName())
+				return enterpriseApi.PhaseUpdating, true, nil
+			}
+		}
+
+		searchable := mgr.cr.Spec.UpdateStrategy.Searchable
+		err := setRollingUpgradeMode(ctx, mgr, searchable, true)
+		if err != nil {
+			mgr.log.Error(err, "Unable to start the rolling upgrade", "searchable", searchable)
+			return enterpriseApi.PhaseError, true, err
+		}
+
+		mgr.log.Info("Started the rolling upgrade of the peers", "searchable", searchable, "revision", updateRevision)
+		*updateStatus = enterpriseApi.IndexerClusterUpdateStatus{
+			Strategy:   enterpriseApi.RollingUpgradeUpdateStrategy,
+			Phase:      enterpriseApi.UpdatePhaseInProgress,
+			Searchable: searchable,
+			StartTime:  time.Now().Unix(),
+		}
+	}
+
+	updateStatus.Revision = updateRevision
+	updateStatus.TotalPeers = replicas
+	updateStatus.UpdatedPeers = updatedPeers
+
+	// drop the peers of the current batch, which are updated and back up
+	var batch []int32
+	for _, peerName := range updateStatus.RestartingPeers {
+		n, err := getPeerOrdinal(peerName)
+		if err != nil || n >= replicas {
+			continue
+		}
+
+		pod, ok := pods[n]
+		if ok && pod.GetLabels()["controller-revision-hash"] == updateRevision && isPodReady(pod) &&
+			n < int32(len(mgr.cr.Status.Peers)) && mgr.cr.Status.Peers[n].Status == "Up" {
+			mgr.log.Info("Peer is updated", "peerName", peerName)
+			continue
+		}
+		batch = append(batch, n)
+	}
+
+	// start the next batch
+	if len(batch) == 0 {
+		batchSize := getRollingUpgradeBatchSize(mgr.cr, replicas)
+		for _, n := range outdated {
+			if int32(len(batch)) == batchSize {
+				break
+			}
+			batch = append(batch, n)
+		}
+	}
+
+	updateStatus.RestartingPeers = nil
+	for _, n := range batch {
+		updateStatus.RestartingPeers = append(updateStatus.RestartingPeers, GetSplunkStatefulsetPodName(SplunkIndexer, mgr.cr.GetName(), n))
+	}
+
+	// all the peers are updated
+	if len(batch) == 0 {
+		if len(pods) != int(replicas) || updatedPeers != replicas {
+			// wait for the last pods to come up
+			return enterpriseApi.PhaseUpdating, true, nil
+		}
+
+		// another site may still need the rolling upgrade mode
+		if updateStatus.Searchable {
+			indexerList, err := mgr.getOtherSites(ctx)
+			if err != nil {
+				return enterpriseApi.PhaseError, true, err
+			}
+			if isAnyOtherSiteUpdating(mgr.cr, indexerList) {
+				mgr.log.Info("Leaving the rolling upgrade to the other sites")
+			} else {
+				err = setRollingUpgradeMode(ctx, mgr, true, false)
+			}
+			if err != nil {
+				return enterpriseApi.PhaseError, true, err
+			}
+		} else {
+			err := setRollingUpgradeMode(ctx, mgr, false, false)
+			if err != nil {
+				return enterpriseApi.PhaseError, true, err
+			}
+		}
+
+		mgr.log.Info("Finished the rolling upgrade of the peers", "revision", updateRevision)
+		updateStatus.Phase = enterpriseApi.UpdatePhaseComplete
+		updateStatus.CompletionTime = time.Now().Unix()
+		updateStatus.Message = ""
+
+		// let the regular pod updates complete the recycle
+		return enterpriseApi.PhaseReady, false, nil
+	}
+
+	updateStatus.Message = fmt.Sprintf("updating peers %s", strings.Join(updateStatus.RestartingPeers, ","))
+
+	// take the outdated peers of the batch offline, and recycle their pods
+	for _, n := range batch {
+		pod, ok := pods[n]
+		if !ok || pod.GetDeletionTimestamp() != nil || pod.GetLabels()["controller-revision-hash"] == updateRevision {
+			// wait for the pod to be recreated and the peer to join the cluster
+			continue
+		}
+
+		if updateStatus.Searchable {
+			ready, err := mgr.decommission(ctx, n, false)
+			if err != nil {
+				mgr.log.Error(err, "Unable to take the peer offline", "podName", pod.GetName())
+				return enterpriseApi.PhaseError, true, err
+			}
+			if !ready {
+				continue
+			}
+		}
+
+		// deleting pod will cause StatefulSet controller to create a new one with latest template
+		mgr.log.Info("Recycling Pod for rolling upgrade", "podName", pod.GetName(),
+			"statefulSetRevision", updateRevision,
+			"podRevision", pod.GetLabels()["controller-revision-hash"])
+		preconditions := client.Preconditions{UID: &pod.ObjectMeta.UID, ResourceVersion: &pod.ObjectMeta.ResourceVersion}
+		err := mgr.c.Delete(context.Background(), pod, preconditions)
+		if err != nil {
+			mgr.log.Error(err, "Unable to delete Pod", "podName", pod.GetName())
+			return enterpriseApi.PhaseError, true, err
+		}
+	}
+
+	return enterpriseApi.PhaseUpdating, true, nil
+}
+
+// isPodReady checks if the pod is running, and its splunk container is ready
+func isPodReady(pod *corev1.Pod) bool {
+	return pod.Status.Phase == corev1.PodRunning && len(pod.Status.ContainerStatuses) > 0 && pod.Status.ContainerStatuses[0].Ready
+}
@@ -0,0 +1,277 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestGetRollingUpgradeBatchSize(t *testing.T) {
+	cr := &enterpriseApi.IndexerCluster{}
+
+	if getRollingUpgradeBatchSize(cr, 30) != 3 {
+		t.Errorf("batch size should default to 10 percent of the peers")
+	}
+
+	if getRollingUpgradeBatchSize(cr, 3) != 1 {
+		t.Errorf("batch size should be at least one peer")
+	}
+
+	cr.Spec.UpdateStrategy.PercentPeersToRestart = 50
+	if getRollingUpgradeBatchSize(cr, 5) != 2 {
+		t.Errorf("batch size should be 50 percent of the peers")
+	}
+
+	cr.Spec.UpdateStrategy.PercentPeersToRestart = 100
+	if getRollingUpgradeBatchSize(cr, 5) != 5 {
+		t.Errorf("batch size should be all the peers")
+	}
+}
+
+func TestIsRollingUpgradeStrategy(t *testing.T) {
+	cr := &enterpriseApi.IndexerCluster{}
+	if isRollingUpgradeStrategy(cr) {
+		t.Errorf("peers should be recycled by default")
+	}
+
+	cr.Spec.UpdateStrategy.Type = enterpriseApi.RollingUpgradeUpdateStrategy
+	if !isRollingUpgradeStrategy(cr) {
+		t.Errorf("peers should be updated with the rolling upgrade")
+	}
+
+	// update in progress completes with its own strategy
+	cr.Spec.UpdateStrategy.Type = enterpriseApi.RecycleUpdateStrategy
+	cr.Status.UpdateStatus.Phase = enterpriseApi.UpdatePhaseInProgress
+	cr.Status.UpdateStatus.Strategy = enterpriseApi.RollingUpgradeUpdateStrategy
+	if !isRollingUpgradeStrategy(cr) {
+		t.Errorf("rolling upgrade in progress should be completed")
+	}
+}
+
+func TestGetSiteUpdateBlocker(t *testing.T) {
+	newIndexerCluster := func(name, site, phase string) enterpriseApi.IndexerCluster {
+		cr := enterpriseApi.IndexerCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		}
+		cr.Spec.ClusterManagerRef.Name = "cm"
+		cr.Spec.Defaults = fmt.Sprintf("splunk:\n  site: %s\n", site)
+		cr.Status.UpdateStatus.Phase = phase
+		return cr
+	}
+	getSite := func(cr *enterpriseApi.IndexerCluster) string {
+		return getSiteName(context.TODO(), nil, cr)
+	}
+
+	cr := newIndexerCluster("idxc-site2", "site2", "")
+	cr.Spec.UpdateStrategy.SiteBySite = true
+
+	// no other site is updating
+	indexerList := []enterpriseApi.IndexerCluster{cr, newIndexerCluster("idxc-site1", "site1", enterpriseApi.UpdatePhaseComplete)}
+	if blocker := getSiteUpdateBlocker(&cr, "site2", indexerList, getSite); blocker != nil {
+		t.Errorf("update should not be blocked by %s", blocker.GetName())
+	}
+
+	// only one site at a time
+	indexerList[1].Status.UpdateStatus.Phase = enterpriseApi.UpdatePhaseInProgress
+	if blocker := getSiteUpdateBlocker(&cr, "site2", indexerList, getSite); blocker == nil || blocker.GetName() != "idxc-site1" {
+		t.Errorf("update should be blocked by the site in progress")
+	}
+
+	// pending sites go in the alphabetical order
+	indexerList[1].Status.UpdateStatus.Phase = enterpriseApi.UpdatePhasePending
+	if blocker := getSiteUpdateBlocker(&cr, "site2", indexerList, getSite); blocker == nil {
+		t.Errorf("update should be blocked by the pending site1")
+	}
+
+	// pending sites go in the site order
+	cr.Spec.UpdateStrategy.SiteOrder = []string{"site2", "site1"}
+	if blocker := getSiteUpdateBlocker(&cr, "site2", indexerList, getSite); blocker != nil {
+		t.Errorf("site2 should be updated before site1")
+	}
+
+	// indexer clusters of other cluster managers don't matter
+	cr.Spec.UpdateStrategy.SiteOrder = nil
+	indexerList[1].Spec.ClusterManagerRef.Name = "othercm"
+	if blocker := getSiteUpdateBlocker(&cr, "site2", indexerList, getSite); blocker != nil {
+		t.Errorf("update should not be blocked by another cluster manager")
+	}
+
+	// not updating site by site
+	indexerList[1].Spec.ClusterManagerRef.Name = "cm"
+	cr.Spec.UpdateStrategy.SiteBySite = false
+	if blocker := getSiteUpdateBlocker(&cr, "site2", indexerList, getSite); blocker != nil {
+		t.Errorf("update should not be blocked, when not updating site by site")
+	}
+
+	if !isAnyOtherSiteUpdating(&cr, indexerList) {
+		t.Errorf("site1 should be updating")
+	}
+}
+
+func TestIndexerClusterRollingUpgrade(t *testing.T) {
+	ctx := context.TODO()
+	var replicas int32 = 3
+
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "splunk-stack1-indexer",
+			Namespace: "test",
+		},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+		},
+		Status: appsv1.StatefulSetStatus{
+			Replicas:       replicas,
+			ReadyReplicas:  replicas,
+			UpdateRevision: "v1",
+		},
+	}
+
+	newPod := func(n int32, revision string) *corev1.Pod {
+		return &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("splunk-stack1-indexer-%d", n),
+				Namespace: "test",
+				Labels: map[string]string{
+					"controller-revision-hash": revision,
+				},
+			},
+			Status: corev1.PodStatus{
+				Phase: corev1.PodRunning,
+				ContainerStatuses: []corev1.ContainerStatus{
+					{Ready: true},
+				},
+			},
+		}
+	}
+
+	var modeCalls []string
+	savedSetRollingUpgradeMode := setRollingUpgradeMode
+	defer func() { setRollingUpgradeMode = savedSetRollingUpgradeMode }()
+	setRollingUpgradeMode = func(ctx context.Context, mgr *indexerClusterPodManager, searchable bool, enable bool) error {
+		modeCalls = append(modeCalls, fmt.Sprintf("searchable=%t,enable=%t", searchable, enable))
+		return nil
+	}
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := getIndexerClusterPodManager("TestIndexerClusterRollingUpgrade", nil, mockSplunkClient, replicas)
+	mgr.cr.Spec.UpdateStrategy.Type = enterpriseApi.RollingUpgradeUpdateStrategy
+	mgr.cr.Spec.UpdateStrategy.PercentPeersToRestart = 50
+	for n := int32(0); n < replicas; n++ {
+		mgr.cr.Status.Peers = append(mgr.cr.Status.Peers, enterpriseApi.IndexerClusterMemberStatus{
+			Name:   fmt.Sprintf("splunk-stack1-indexer-%d", n),
+			Status: "Up",
+		})
+	}
+
+	c := spltest.NewMockClient()
+	mgr.c = c
+	for n := int32(0); n < replicas; n++ {
+		c.AddObject(newPod(n, "v0"))
+	}
+
+	isPodDeleted := func(n int32) bool {
+		var pod corev1.Pod
+		err := c.Get(ctx, types.NamespacedName{Namespace: "test", Name: fmt.Sprintf("splunk-stack1-indexer-%d", n)}, &pod)
+		return err != nil
+	}
+
+	// scaling goes first
+	_, handled, err := mgr.rollingUpgrade(ctx, statefulSet, 4)
+	if err != nil || handled {
+		t.Errorf("rolling upgrade should wait for the scaling. err: %v", err)
+	}
+
+	// first batch is started, and the highest ordinal pod is recycled in the maintenance mode
+	phase, handled, err := mgr.rollingUpgrade(ctx, statefulSet, replicas)
+	if err != nil || !handled || phase != enterpriseApi.PhaseUpdating {
+		t.Errorf("rolling upgrade should be in progress. phase: %s, err: %v", phase, err)
+	}
+	updateStatus := mgr.cr.Status.UpdateStatus
+	if updateStatus.Phase != enterpriseApi.UpdatePhaseInProgress || updateStatus.Revision != "v1" || updateStatus.TotalPeers != replicas {
+		t.Errorf("unexpected update status %v", updateStatus)
+	}
+	if len(updateStatus.RestartingPeers) != 1 || updateStatus.RestartingPeers[0] != "splunk-stack1-indexer-2" {
+		t.Errorf("unexpected batch %v", updateStatus.RestartingPeers)
+	}
+	if len(modeCalls) != 1 || modeCalls[0] != "searchable=false,enable=true" {
+		t.Errorf("cluster should be put in the maintenance mode. calls: %v", modeCalls)
+	}
+	if !isPodDeleted(2) || isPodDeleted(1) {
+		t.Errorf("only the pod of the batch should be recycled")
+	}
+
+	// wait for the recycled pod to come up
+	_, handled, err = mgr.rollingUpgrade(ctx, statefulSet, replicas)
+	if err != nil || !handled || len(mgr.cr.Status.UpdateStatus.RestartingPeers) != 1 || isPodDeleted(1) {
+		t.Errorf("rolling upgrade should wait for the batch. err: %v", err)
+	}
+
+	// next batch, in the searchable mode of the update in progress
+	mgr.cr.Status.UpdateStatus.Searchable = true
+	c.AddObject(newPod(2, "v1"))
+	mgr.cr.Status.Peers[1].Status = "Down"
+	_, handled, err = mgr.rollingUpgrade(ctx, statefulSet, replicas)
+	if err != nil || !handled {
+		t.Errorf("rolling upgrade should be in progress. err: %v", err)
+	}
+	if mgr.cr.Status.UpdateStatus.RestartingPeers[0] != "splunk-stack1-indexer-1" || mgr.cr.Status.UpdateStatus.UpdatedPeers != 1 {
+		t.Errorf("unexpected update status %v", mgr.cr.Status.UpdateStatus)
+	}
+	if !isPodDeleted(1) {
+		t.Errorf("pod of the offline peer should be recycled")
+	}
+
+	// last batch
+	c.AddObject(newPod(1, "v1"))
+	mgr.cr.Status.Peers[1].Status = "Up"
+	mgr.cr.Status.Peers[0].Status = "GracefulShutdown"
+	_, handled, err = mgr.rollingUpgrade(ctx, statefulSet, replicas)
+	if err != nil || !handled || !isPodDeleted(0) {
+		t.Errorf("last pod should be recycled. err: %v", err)
+	}
+
+	// all the peers are updated, and no other site is updating
+	c.ListObj = &enterpriseApi.IndexerClusterList{Items: []enterpriseApi.IndexerCluster{*mgr.cr}}
+	c.AddObject(newPod(0, "v1"))
+	mgr.cr.Status.Peers[0].Status = "Up"
+	_, handled, err = mgr.rollingUpgrade(ctx, statefulSet, replicas)
+	if err != nil || handled {
+		t.Errorf("rolling upgrade should be complete. err: %v", err)
+	}
+	updateStatus = mgr.cr.Status.UpdateStatus
+	if updateStatus.Phase != enterpriseApi.UpdatePhaseComplete || updateStatus.UpdatedPeers != replicas || updateStatus.CompletionTime == 0 || len(updateStatus.RestartingPeers) != 0 {
+		t.Errorf("unexpected update status %v", updateStatus)
+	}
+	if len(modeCalls) != 2 || modeCalls[1] != "searchable=true,enable=false" {
+		t.Errorf("rolling upgrade should be finalized. calls: %v", modeCalls)
+	}
+
+	// nothing to update
+	_, handled, err = mgr.rollingUpgrade(ctx, statefulSet, replicas)
+	if err != nil || handled || len(modeCalls) != 2 {
+		t.Errorf("rolling upgrade should not start without pending updates. err: %v", err)
+	}
+}
@@ -50,7 +50,7 @@ func ApplyLicenseManager(ctx context.Context, client splcommon.ControllerClient,
 
 	var err error
 	// Initialize phase
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 
 	// Update the CR Status
 	defer updateCRStatus(ctx, client, cr, &err)
@@ -92,7 +92,7 @@ func ApplyLicenseManager(ctx context.Context, client splcommon.ControllerClient,
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, getLicenseManagerURL(cr, &cr.Spec.CommonSplunkSpec), false)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), getLicenseManagerURL(cr, &cr.Spec.CommonSplunkSpec), false)
 			if err != nil {
 				return result, err
 			}
@@ -139,7 +139,19 @@ func ApplyLicenseManager(ctx context.Context, client splcommon.ControllerClient,
 		return result, err
 	}
 
-	mgr := splctrl.DefaultStatefulSetPodManager{}
+	// revert the version upgrade when the pods are not ready within the health deadline
+	continueReconcile, err := applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkLicenseManager, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// hold the version upgrade until the upgrade policy allows it
+	continueReconcile, err = applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkLicenseManager, lastPhase, &cr.Status.Upgrade)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	mgr := splctrl.DefaultStatefulSetPodManager{VolumeResize: &cr.Status.VolumeResize}
 	phase, err := mgr.Update(ctx, client, statefulSet, 1)
 	if err != nil {
 		return result, err
@@ -155,7 +167,7 @@ func ApplyLicenseManager(ctx context.Context, client splcommon.ControllerClient,
 			scopedLog.Error(err, "Error in deleting automated monitoring console resource")
 		}
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, getLicenseManagerURL(cr, &cr.Spec.CommonSplunkSpec), true)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), getLicenseManagerURL(cr, &cr.Spec.CommonSplunkSpec), true)
 			if err != nil {
 				return result, err
 			}
@@ -181,6 +193,23 @@ func ApplyLicenseManager(ctx context.Context, client splcommon.ControllerClient,
 		if err != nil {
 			return result, err
 		}
+
+		// install the licenses, apply the license pools and report the license usage
+		var restarted bool
+		restarted, err = applyLicenseManagerLicenses(ctx, client, cr)
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyLicenseManagerLicenses", fmt.Sprintf("license configuration failed %s", err.Error()))
+			return result, err
+		}
+		if restarted {
+			return result, nil
+		}
+
+		// check the license usage periodically
+		if isLicenseManagerLicensesConfigured(cr) && (!result.Requeue || result.RequeueAfter > licenseCheckInterval*time.Second) {
+			result.Requeue = true
+			result.RequeueAfter = licenseCheckInterval * time.Second
+		}
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
@@ -214,6 +243,11 @@ func validateLicenseManagerSpec(ctx context.Context, c splcommon.ControllerClien
 		}
 	}
 
+	err := validateLicenseManagerLicenses(&cr.Spec)
+	if err != nil {
+		return err
+	}
+
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
 
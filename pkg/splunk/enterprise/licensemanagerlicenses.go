@@ -0,0 +1,521 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// defaultLicenseExpiryWarningDays is the number of days before the expiration of a license when a warning event is published
+	defaultLicenseExpiryWarningDays = 30
+
+	// licenseCheckInterval is the interval of the checks of the license usage, in seconds
+	licenseCheckInterval = 300
+
+	// defaultLicenseStack is the stack of the license pools without a stack
+	defaultLicenseStack = "enterprise"
+
+	// licenseQuotaMax is the quota of the license pools allocated the whole volume of their stack
+	licenseQuotaMax = "MAX"
+
+	// licenseStatusExpired is the status of the expired licenses
+	licenseStatusExpired = "EXPIRED"
+)
+
+// licenseViolationCategories are the categories of the license messages counted as violations
+var licenseViolationCategories = []string{"license_window", "pool_over_quota", "stack_over_quota"}
+
+// getLicenseManagerSplunkClient returns a SplunkClient for the license manager pod
+var getLicenseManagerSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.LicenseManager) (*splclient.SplunkClient, error) {
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return nil, err
+	}
+	podName := GetSplunkStatefulsetPodName(SplunkLicenseManager, cr.GetName(), 0)
+	fqdnName := splcommon.GetServiceFQDN(cr.GetNamespace(), fmt.Sprintf("%s.%s", podName, GetSplunkServiceName(SplunkLicenseManager, cr.GetName(), true)))
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, podName, cr.GetNamespace(), "password")
+	if err != nil {
+		return nil, err
+	}
+	return newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", adminPwd), nil
+}
+
+// validateLicenseManagerLicenses checks the licenses and the license pools of a LicenseManagerSpec
+func validateLicenseManagerLicenses(spec *enterpriseApi.LicenseManagerSpec) error {
+	for i := range spec.Licenses {
+		source := &spec.Licenses[i]
+		if (source.SecretRef == nil) == (source.ConfigMapRef == nil) {
+			return fmt.Errorf("license %s must have either a secretRef or a configMapRef", source.Name)
+		}
+	}
+	for i := range spec.Pools {
+		pool := &spec.Pools[i]
+		_, err := getLicensePoolQuota(pool.Quota)
+		if err != nil {
+			return fmt.Errorf("license pool %s: %w", pool.Name, err)
+		}
+	}
+	return nil
+}
+
+// getLicensePoolStackID returns the stack of a license pool, which defaults to the enterprise stack
+func getLicensePoolStackID(pool *enterpriseApi.LicensePoolSpec) string {
+	if pool.StackID == "" {
+		return defaultLicenseStack
+	}
+	return pool.StackID
+}
+
+// isLicenseManagerLicensesConfigured checks if the licenses or the license pools of a LicenseManager are managed by
+// the operator. The ones removed from the spec are still managed, till they are removed from the license manager
+func isLicenseManagerLicensesConfigured(cr *enterpriseApi.LicenseManager) bool {
+	if len(cr.Spec.Licenses) > 0 || len(cr.Spec.Pools) > 0 {
+		return true
+	}
+	for _, license := range cr.Status.Licenses {
+		if license.Name != "" {
+			return true
+		}
+	}
+	for _, pool := range cr.Status.Pools {
+		if pool.Managed {
+			return true
+		}
+	}
+	return false
+}
+
+// getLicensePoolQuota returns the quota of a license pool as a number of bytes, or MAX
+func getLicensePoolQuota(quota string) (string, error) {
+	if quota == "" || quota == licenseQuotaMax {
+		return licenseQuotaMax, nil
+	}
+	q, err := resource.ParseQuantity(quota)
+	if err != nil || q.Value() <= 0 {
+		return "", fmt.Errorf("invalid quota %s, must be MAX or a positive quantity", quota)
+	}
+	return strconv.FormatInt(q.Value(), 10), nil
+}
+
+// getLicenseSourceContent returns the content of a license file of spec.licenses
+func getLicenseSourceContent(ctx context.Context, c splcommon.ControllerClient, namespace string, source *enterpriseApi.LicenseSource) (string, error) {
+	if source.SecretRef != nil {
+		secret := &corev1.Secret{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: source.SecretRef.Name}, secret)
+		if err != nil {
+			return "", err
+		}
+		content, ok := secret.Data[source.SecretRef.Key]
+		if !ok {
+			return "", fmt.Errorf("key %s of license %s not found in secret %s", source.SecretRef.Key, source.Name, source.SecretRef.Name)
+		}
+		return string(content), nil
+	}
+	if source.ConfigMapRef != nil {
+		configMap := &corev1.ConfigMap{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: source.ConfigMapRef.Name}, configMap)
+		if err != nil {
+			return "", err
+		}
+		content, ok := configMap.Data[source.ConfigMapRef.Key]
+		if !ok {
+			return "", fmt.Errorf("key %s of license %s not found in configmap %s", source.ConfigMapRef.Key, source.Name, source.ConfigMapRef.Name)
+		}
+		return content, nil
+	}
+	return "", fmt.Errorf("license %s has no secretRef or configMapRef", source.Name)
+}
+
+// getLicensePoolPeers returns the GUIDs of the license peers which are instances of the members of a license pool,
+// or * when the pool has no members
+func getLicensePoolPeers(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.LicenseManager, pool *enterpriseApi.LicensePoolSpec, peers map[string]splclient.LicensePeerInfo) ([]string, error) {
+	if len(pool.Members) == 0 {
+		return []string{"*"}, nil
+	}
+
+	var prefixes []string
+	for _, member := range pool.Members {
+		memberPrefixes, err := getCustomResourcePodPrefixes(ctx, c, cr.GetNamespace(), member.Kind, member.Name)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, memberPrefixes...)
+	}
+
+	guids := []string{}
+	for guid, peer := range peers {
+		if isCustomResourcePod(peer.Label, prefixes) {
+			guids = append(guids, guid)
+		}
+	}
+	sort.Strings(guids)
+	return guids, nil
+}
+
+// removeLicenses removes licenses from the license manager, and from the installed licenses
+func removeLicenses(lm *splclient.SplunkClient, licenses []enterpriseApi.LicenseStatus, installed map[string]splclient.LicenseInfo) error {
+	for _, license := range licenses {
+		if _, ok := installed[license.Hash]; !ok {
+			continue
+		}
+		err := lm.RemoveLicense(license.Hash)
+		if err != nil {
+			return fmt.Errorf("unable to remove license %s: %w", license.Name, err)
+		}
+		delete(installed, license.Hash)
+	}
+	return nil
+}
+
+// installLicenses installs the new and changed licenses of spec.licenses on the license manager, and removes the
+// licenses which are not in spec.licenses anymore. Returns the installed licenses, the licenses of spec.licenses
+// indexed by hash, and true if the licenses changed
+func installLicenses(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.LicenseManager, lm *splclient.SplunkClient, eventPublisher *K8EventPublisher) (map[string]splclient.LicenseInfo, map[string]enterpriseApi.LicenseStatus, bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("installLicenses").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	installed, err := lm.GetLicenses()
+	if err != nil {
+		return nil, nil, false, err
+	}
+	previous := map[string][]enterpriseApi.LicenseStatus{}
+	for _, license := range cr.Status.Licenses {
+		if license.Name != "" {
+			previous[license.Name] = append(previous[license.Name], license)
+		}
+	}
+
+	managed := map[string]enterpriseApi.LicenseStatus{}
+	changed := false
+	for i := range cr.Spec.Licenses {
+		source := &cr.Spec.Licenses[i]
+		content, err := getLicenseSourceContent(ctx, c, cr.GetNamespace(), source)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		digest := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+
+		licenses := previous[source.Name]
+		delete(previous, source.Name)
+		current := len(licenses) > 0
+		for _, license := range licenses {
+			if _, ok := installed[license.Hash]; !ok || license.Digest != digest {
+				current = false
+			}
+		}
+		if current {
+			for _, license := range licenses {
+				managed[license.Hash] = license
+			}
+			continue
+		}
+
+		// license file is new or changed
+		err = removeLicenses(lm, licenses, installed)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		scopedLog.Info("Installing license", "license", source.Name)
+		err = lm.AddLicense(content)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("unable to install license %s: %w", source.Name, err)
+		}
+		before := installed
+		installed, err = lm.GetLicenses()
+		if err != nil {
+			return nil, nil, false, err
+		}
+		for hash := range installed {
+			if _, ok := before[hash]; !ok {
+				managed[hash] = enterpriseApi.LicenseStatus{Name: source.Name, Hash: hash, Digest: digest}
+			}
+		}
+		eventPublisher.Normal(ctx, "LicenseInstalled", fmt.Sprintf("license %s installed", source.Name))
+		changed = true
+	}
+
+	// licenses removed from the spec
+	for name, licenses := range previous {
+		scopedLog.Info("Removing license", "license", name)
+		err = removeLicenses(lm, licenses, installed)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		eventPublisher.Normal(ctx, "LicenseRemoved", fmt.Sprintf("license %s removed", name))
+		changed = true
+	}
+	return installed, managed, changed, nil
+}
+
+// setLicenseStatus reports the installed licenses in the status, and publishes a warning event when a license is
+// about to expire or expired
+func setLicenseStatus(ctx context.Context, cr *enterpriseApi.LicenseManager, installed map[string]splclient.LicenseInfo, managed map[string]enterpriseApi.LicenseStatus, eventPublisher *K8EventPublisher) {
+	warningDays := int64(cr.Spec.LicenseExpiryWarningDays)
+	if warningDays == 0 {
+		warningDays = defaultLicenseExpiryWarningDays
+	}
+	previous := map[string]enterpriseApi.LicenseStatus{}
+	for _, license := range cr.Status.Licenses {
+		previous[license.Hash] = license
+	}
+
+	var hashes []string
+	for hash := range installed {
+		hashes = append(hashes, hash)
+	}
+	sort.Strings(hashes)
+
+	now := time.Now().Unix()
+	licenses := []enterpriseApi.LicenseStatus{}
+	for _, hash := range hashes {
+		info := installed[hash]
+		license := managed[hash]
+		license.Hash = hash
+		license.Label = info.Label
+		license.StackID = info.StackID
+		license.Status = info.Status
+		license.QuotaBytes = info.Quota
+		license.ExpirationTime = info.ExpirationTime
+		license.Expiring = info.Status != licenseStatusExpired && info.ExpirationTime > 0 && info.ExpirationTime-now < warningDays*24*3600
+
+		name := info.Label
+		if license.Name != "" {
+			name = license.Name
+		}
+		last, ok := previous[hash]
+		expiration := time.Unix(info.ExpirationTime, 0).UTC().Format(time.RFC3339)
+		if license.Expiring && (!ok || !last.Expiring) {
+			eventPublisher.Warning(ctx, "LicenseExpiring", fmt.Sprintf("license %s of stack %s expires on %s", name, info.StackID, expiration))
+		}
+		if info.Status == licenseStatusExpired && (!ok || last.Status != licenseStatusExpired) {
+			eventPublisher.Warning(ctx, "LicenseExpired", fmt.Sprintf("license %s of stack %s expired on %s", name, info.StackID, expiration))
+		}
+		licenses = append(licenses, license)
+	}
+	cr.Status.Licenses = licenses
+}
+
+// applyLicensePools creates, updates and deletes the license pools of spec.pools on the license manager. Returns the
+// license pools of the license manager
+func applyLicensePools(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.LicenseManager, lm *splclient.SplunkClient, eventPublisher *K8EventPublisher) (map[string]splclient.LicensePoolInfo, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyLicensePools").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	pools, err := lm.GetLicensePools()
+	if err != nil {
+		return nil, err
+	}
+
+	var peers map[string]splclient.LicensePeerInfo
+	managed := map[string]bool{}
+	changed := false
+	for i := range cr.Spec.Pools {
+		pool := &cr.Spec.Pools[i]
+		managed[pool.Name] = true
+		quota, err := getLicensePoolQuota(pool.Quota)
+		if err != nil {
+			return nil, err
+		}
+		if len(pool.Members) > 0 && peers == nil {
+			peers, err = lm.GetLicensePeers()
+			if err != nil {
+				return nil, err
+			}
+		}
+		want := splclient.LicensePoolInfo{
+			Name:        pool.Name,
+			StackID:     getLicensePoolStackID(pool),
+			Description: pool.Description,
+			Quota:       splclient.LicenseQuota(quota),
+		}
+		want.Peers, err = getLicensePoolPeers(ctx, c, cr, pool, peers)
+		if err != nil {
+			return nil, err
+		}
+
+		current, exists := pools[pool.Name]
+		if exists && current.StackID != want.StackID {
+			// stack of a pool can not be changed
+			scopedLog.Info("Deleting license pool to change its stack", "pool", pool.Name, "stack", want.StackID)
+			err = lm.DeleteLicensePool(pool.Name)
+			if err != nil {
+				return nil, err
+			}
+			exists = false
+		}
+		if exists && current.Quota == want.Quota && current.Description == want.Description && (len(want.Peers) == 0 || isSameStringSet(current.Peers, want.Peers)) {
+			continue
+		}
+		scopedLog.Info("Applying license pool", "pool", pool.Name, "quota", quota, "peers", want.Peers)
+		err = lm.SetLicensePool(want, !exists)
+		if err != nil {
+			return nil, fmt.Errorf("unable to apply license pool %s: %w", pool.Name, err)
+		}
+		eventPublisher.Normal(ctx, "LicensePoolApplied", fmt.Sprintf("license pool %s applied with quota %s", pool.Name, quota))
+		changed = true
+	}
+
+	// pools removed from the spec
+	for _, pool := range cr.Status.Pools {
+		if _, ok := pools[pool.Name]; !ok || !pool.Managed || managed[pool.Name] {
+			continue
+		}
+		scopedLog.Info("Deleting license pool", "pool", pool.Name)
+		err = lm.DeleteLicensePool(pool.Name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to delete license pool %s: %w", pool.Name, err)
+		}
+		eventPublisher.Normal(ctx, "LicensePoolDeleted", fmt.Sprintf("license pool %s deleted", pool.Name))
+		changed = true
+	}
+
+	if changed {
+		return lm.GetLicensePools()
+	}
+	return pools, nil
+}
+
+// setLicenseUsageStatus reports the daily usage of the license pools and stacks in the status, along with the
+// violations of the stacks, and publishes a warning event when the violations of a stack increase
+func setLicenseUsageStatus(ctx context.Context, cr *enterpriseApi.LicenseManager, pools map[string]splclient.LicensePoolInfo, stacks map[string]splclient.LicenseStackInfo, messages []splclient.LicenseMessage, eventPublisher *K8EventPublisher) {
+	managed := map[string]bool{}
+	for _, pool := range cr.Spec.Pools {
+		managed[pool.Name] = true
+	}
+
+	var poolNames []string
+	for name := range pools {
+		poolNames = append(poolNames, name)
+	}
+	sort.Strings(poolNames)
+	poolStatuses := []enterpriseApi.LicensePoolStatus{}
+	usedBytes := map[string]int64{}
+	for _, name := range poolNames {
+		pool := pools[name]
+		quota, err := strconv.ParseInt(string(pool.Quota), 10, 64)
+		if err != nil {
+			// MAX
+			quota = stacks[pool.StackID].Quota
+		}
+		poolStatuses = append(poolStatuses, enterpriseApi.LicensePoolStatus{
+			Name:       name,
+			StackID:    pool.StackID,
+			Managed:    managed[name],
+			QuotaBytes: quota,
+			UsedBytes:  pool.UsedBytes,
+			Peers:      pool.Peers,
+		})
+		usedBytes[pool.StackID] += pool.UsedBytes
+	}
+	cr.Status.Pools = poolStatuses
+
+	violations := map[string]int32{}
+	for _, message := range messages {
+		if !isStringInList(message.Category, licenseViolationCategories) {
+			continue
+		}
+		stackID := message.StackID
+		if stackID == "" {
+			stackID = pools[message.PoolID].StackID
+		}
+		violations[stackID]++
+	}
+
+	previous := map[string]int32{}
+	for _, stack := range cr.Status.Stacks {
+		previous[stack.Name] = stack.Violations
+	}
+	var stackNames []string
+	for name := range stacks {
+		stackNames = append(stackNames, name)
+	}
+	sort.Strings(stackNames)
+	stackStatuses := []enterpriseApi.LicenseStackStatus{}
+	for _, name := range stackNames {
+		if violations[name] > previous[name] {
+			eventPublisher.Warning(ctx, "LicenseViolation", fmt.Sprintf("license stack %s has %d violations, %d bytes used of %d today", name, violations[name], usedBytes[name], stacks[name].Quota))
+		}
+		stackStatuses = append(stackStatuses, enterpriseApi.LicenseStackStatus{
+			Name:       name,
+			QuotaBytes: stacks[name].Quota,
+			UsedBytes:  usedBytes[name],
+			Violations: violations[name],
+		})
+	}
+	cr.Status.Stacks = stackStatuses
+}
+
+// applyLicenseManagerLicenses installs the licenses and applies the license pools of the spec on the license manager,
+// and reports the licenses, the daily usage of the pools and stacks and the license violations in the status. The
+// license manager is restarted when its licenses change, and the license usage is reported on a best effort basis.
+// Returns true if the license manager is restarted
+func applyLicenseManagerLicenses(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.LicenseManager) (bool, error) {
+	if !isLicenseManagerLicensesConfigured(cr) {
+		return false, nil
+	}
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyLicenseManagerLicenses").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+	lm, err := getLicenseManagerSplunkClient(ctx, c, cr)
+	if err != nil {
+		return false, err
+	}
+
+	installed, managed, changed, err := installLicenses(ctx, c, cr, lm, eventPublisher)
+	if err != nil {
+		return false, err
+	}
+	setLicenseStatus(ctx, cr, installed, managed, eventPublisher)
+	if changed {
+		// licenses are applied once the license manager restarts
+		eventPublisher.Normal(ctx, "LicenseManagerRestart", "restarting the license manager to apply the licenses")
+		return true, lm.RestartSplunk()
+	}
+
+	pools, err := applyLicensePools(ctx, c, cr, lm, eventPublisher)
+	if err != nil {
+		return false, err
+	}
+
+	// license usage is not required to apply the licenses, so it does not fail the reconcile
+	var messages []splclient.LicenseMessage
+	stacks, err := lm.GetLicenseStacks()
+	if err == nil {
+		messages, err = lm.GetLicenseMessages()
+	}
+	if err != nil {
+		scopedLog.Error(err, "unable to get the license usage")
+		return false, nil
+	}
+	setLicenseUsageStatus(ctx, cr, pools, stacks, messages, eventPublisher)
+	cr.Status.LicenseCheckTime = time.Now().Unix()
+	return false, nil
+}
@@ -0,0 +1,359 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeLicenser is a fake licenser REST API of a license manager
+type fakeLicenser struct {
+	licenses map[string]splclient.LicenseInfo
+	pools    map[string]splclient.LicensePoolInfo
+	peers    map[string]splclient.LicensePeerInfo
+	stacks   map[string]splclient.LicenseStackInfo
+	messages []splclient.LicenseMessage
+	requests []string
+
+	// endpoint failing the requests, if any
+	failing string
+}
+
+func newFakeLicenser() *fakeLicenser {
+	return &fakeLicenser{
+		licenses: map[string]splclient.LicenseInfo{},
+		pools: map[string]splclient.LicensePoolInfo{
+			"auto_generated_pool_enterprise": {StackID: "enterprise", Quota: "MAX", UsedBytes: 1024, Peers: []string{"*"}},
+		},
+		peers: map[string]splclient.LicensePeerInfo{
+			"GUID1": {Label: "splunk-stack1-standalone-0"},
+			"GUID2": {Label: "splunk-stack1-standalone-10"},
+			"GUID3": {Label: "splunk-idxc1-site1-indexer-0"},
+			"GUID4": {Label: "splunk-stack1-standalone-extra-0"},
+		},
+		stacks: map[string]splclient.LicenseStackInfo{
+			"enterprise": {Quota: 10 * 1024 * 1024 * 1024},
+		},
+	}
+}
+
+// entries returns the JSON response of a licenser endpoint
+func (f *fakeLicenser) entries(items interface{}) string {
+	type entry struct {
+		Name    string      `json:"name"`
+		Content interface{} `json:"content"`
+	}
+	response := struct {
+		Entry []entry `json:"entry"`
+	}{Entry: []entry{}}
+	switch items := items.(type) {
+	case map[string]splclient.LicenseInfo:
+		for name, content := range items {
+			response.Entry = append(response.Entry, entry{Name: name, Content: content})
+		}
+	case map[string]splclient.LicensePoolInfo:
+		for name, content := range items {
+			response.Entry = append(response.Entry, entry{Name: name, Content: content})
+		}
+	case map[string]splclient.LicensePeerInfo:
+		for name, content := range items {
+			response.Entry = append(response.Entry, entry{Name: name, Content: content})
+		}
+	case map[string]splclient.LicenseStackInfo:
+		for name, content := range items {
+			response.Entry = append(response.Entry, entry{Name: name, Content: content})
+		}
+	case []splclient.LicenseMessage:
+		for n, content := range items {
+			response.Entry = append(response.Entry, entry{Name: fmt.Sprint(n), Content: content})
+		}
+	}
+	body, _ := json.Marshal(response)
+	return string(body)
+}
+
+// Do serves the requests of the licenser endpoints
+func (f *fakeLicenser) Do(request *http.Request) (*http.Response, error) {
+	path := strings.TrimPrefix(request.URL.Path, "/services/licenser/")
+	f.requests = append(f.requests, fmt.Sprintf("%s %s", request.Method, path))
+	values := url.Values{}
+	if request.Body != nil {
+		body, _ := io.ReadAll(request.Body)
+		values, _ = url.ParseQuery(string(body))
+	}
+
+	status, body := 200, ""
+	switch {
+	case path == f.failing:
+		status = 503
+	case request.Method == "GET" && path == "licenses":
+		body = f.entries(f.licenses)
+	case request.Method == "GET" && path == "pools":
+		body = f.entries(f.pools)
+	case request.Method == "GET" && path == "peers":
+		body = f.entries(f.peers)
+	case request.Method == "GET" && path == "stacks":
+		body = f.entries(f.stacks)
+	case request.Method == "GET" && path == "messages":
+		body = f.entries(f.messages)
+	case request.Method == "POST" && path == "licenses":
+		hash := fmt.Sprintf("%X", sha256.Sum256([]byte(values.Get("payload"))))[:16]
+		f.licenses[hash] = splclient.LicenseInfo{Label: values.Get("payload"), StackID: "enterprise", Status: "VALID", Quota: 1024, ExpirationTime: time.Now().Add(365 * 24 * time.Hour).Unix()}
+		status = 201
+	case request.Method == "DELETE" && strings.HasPrefix(path, "licenses/"):
+		delete(f.licenses, strings.TrimPrefix(path, "licenses/"))
+	case request.Method == "POST" && path == "pools":
+		f.pools[values.Get("name")] = splclient.LicensePoolInfo{StackID: values.Get("stack_id"), Description: values.Get("description"), Quota: splclient.LicenseQuota(values.Get("quota")), Peers: strings.Split(values.Get("peers"), ",")}
+		status = 201
+	case request.Method == "POST" && strings.HasPrefix(path, "pools/"):
+		name := strings.TrimPrefix(path, "pools/")
+		pool := f.pools[name]
+		pool.Description = values.Get("description")
+		pool.Quota = splclient.LicenseQuota(values.Get("quota"))
+		pool.Peers = strings.Split(values.Get("peers"), ",")
+		f.pools[name] = pool
+	case request.Method == "DELETE" && strings.HasPrefix(path, "pools/"):
+		delete(f.pools, strings.TrimPrefix(path, "pools/"))
+	case request.Method == "POST" && request.URL.Path == "/services/server/control/restart":
+	default:
+		status = 404
+	}
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body))}, nil
+}
+
+// mockLicenseManagerSplunkClient serves the licenser of the license managers with a fakeLicenser until the end of a test
+func mockLicenseManagerSplunkClient(t *testing.T, licenser *fakeLicenser) {
+	savedGetLicenseManagerSplunkClient := getLicenseManagerSplunkClient
+	t.Cleanup(func() { getLicenseManagerSplunkClient = savedGetLicenseManagerSplunkClient })
+	getLicenseManagerSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.LicenseManager) (*splclient.SplunkClient, error) {
+		sc := splclient.NewSplunkClient(fmt.Sprintf("https://splunk-%s-license-manager-0:8089", cr.GetName()), "admin", "p@ssw0rd")
+		sc.Client = licenser
+		return sc, nil
+	}
+}
+
+// popRequests returns the requests which changed the licenser, and resets the requests
+func (f *fakeLicenser) popRequests() []string {
+	var requests []string
+	for _, request := range f.requests {
+		if !strings.HasPrefix(request, "GET ") {
+			requests = append(requests, request)
+		}
+	}
+	f.requests = nil
+	return requests
+}
+
+func TestValidateLicenseManagerLicenses(t *testing.T) {
+	spec := enterpriseApi.LicenseManagerSpec{
+		Licenses: []enterpriseApi.LicenseSource{{Name: "enterprise"}},
+	}
+	if validateLicenseManagerLicenses(&spec) == nil {
+		t.Errorf("license without secretRef or configMapRef should be rejected")
+	}
+	spec.Licenses[0].SecretRef = &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "licenses"}, Key: "enterprise.lic"}
+	spec.Licenses[0].ConfigMapRef = &corev1.ConfigMapKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "licenses"}, Key: "enterprise.lic"}
+	if validateLicenseManagerLicenses(&spec) == nil {
+		t.Errorf("license with both a secretRef and a configMapRef should be rejected")
+	}
+	spec.Licenses[0].ConfigMapRef = nil
+
+	spec.Pools = []enterpriseApi.LicensePoolSpec{{Name: "prod", Quota: "100G"}}
+	if err := validateLicenseManagerLicenses(&spec); err != nil || spec.Pools[0].StackID != "" {
+		t.Errorf("validateLicenseManagerLicenses() should accept the spec without changing it. err: %v, stack: %s", err, spec.Pools[0].StackID)
+	}
+	if stackID := getLicensePoolStackID(&spec.Pools[0]); stackID != defaultLicenseStack {
+		t.Errorf("license pool without a stack should be in the default stack, got %s", stackID)
+	}
+	spec.Pools[0].Quota = "lots"
+	if validateLicenseManagerLicenses(&spec) == nil {
+		t.Errorf("invalid pool quota should be rejected")
+	}
+}
+
+func TestGetLicensePoolQuota(t *testing.T) {
+	tests := map[string]string{"": "MAX", "MAX": "MAX", "1Gi": "1073741824", "100G": "100000000000"}
+	for quota, want := range tests {
+		got, err := getLicensePoolQuota(quota)
+		if err != nil || got != want {
+			t.Errorf("getLicensePoolQuota(%s) = %s, %v; want %s", quota, got, err, want)
+		}
+	}
+	for _, quota := range []string{"0", "-1G", "lots"} {
+		if _, err := getLicensePoolQuota(quota); err == nil {
+			t.Errorf("getLicensePoolQuota(%s) should fail", quota)
+		}
+	}
+}
+
+func TestApplyLicenseManagerLicenses(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	licenser := newFakeLicenser()
+	mockLicenseManagerSplunkClient(t, licenser)
+
+	cr := enterpriseApi.LicenseManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+
+	// nothing is applied without licenses and license pools
+	restarted, err := applyLicenseManagerLicenses(ctx, c, &cr)
+	if err != nil || restarted || len(licenser.requests) != 0 || cr.Status.LicenseCheckTime != 0 {
+		t.Errorf("applyLicenseManagerLicenses() should not apply anything. err: %v, requests: %v", err, licenser.requests)
+	}
+
+	cr.Spec = enterpriseApi.LicenseManagerSpec{
+		Licenses: []enterpriseApi.LicenseSource{
+			{Name: "enterprise", SecretRef: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "licenses"}, Key: "enterprise.lic"}},
+		},
+		Pools: []enterpriseApi.LicensePoolSpec{
+			{Name: "prod", Quota: "1Gi", Members: []enterpriseApi.LicensePoolMember{{Kind: "Standalone", Name: "stack1"}, {Kind: "IndexerCluster", Name: "idxc1"}}},
+		},
+	}
+
+	// license source is missing
+	_, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if err == nil {
+		t.Errorf("applyLicenseManagerLicenses() should fail when the license secret is missing")
+	}
+
+	// license is installed and the license manager restarted
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "licenses", Namespace: "test"},
+		Data:       map[string][]byte{"enterprise.lic": []byte("license-v1")},
+	}
+	c.AddObject(secret)
+	restarted, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if err != nil || !restarted {
+		t.Errorf("license manager should be restarted to apply the license. err: %v", err)
+	}
+	if got := licenser.popRequests(); len(got) != 2 || got[0] != "POST licenses" || !strings.HasSuffix(got[1], "/server/control/restart") {
+		t.Errorf("unexpected requests %v", got)
+	}
+	if len(cr.Status.Licenses) != 1 || cr.Status.Licenses[0].Name != "enterprise" || cr.Status.Licenses[0].Digest == "" || cr.Status.Licenses[0].Expiring {
+		t.Errorf("unexpected license status %v", cr.Status.Licenses)
+	}
+
+	// pool is created once the licenses are installed, with the pods of the members
+	c.AddObject(&enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc1", Namespace: "test"},
+		Spec:       enterpriseApi.IndexerClusterSpec{Sites: []enterpriseApi.IndexerClusterSiteSpec{{Name: "site1", Replicas: 1}}},
+	})
+	restarted, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if err != nil || restarted {
+		t.Errorf("license manager should not be restarted. err: %v", err)
+	}
+	if got := licenser.popRequests(); len(got) != 1 || got[0] != "POST pools" {
+		t.Errorf("unexpected requests %v", got)
+	}
+	if pool := licenser.pools["prod"]; !isSameStringSet(pool.Peers, []string{"GUID1", "GUID2", "GUID3"}) || pool.StackID != defaultLicenseStack {
+		t.Errorf("unexpected pool %v", pool)
+	}
+	if len(cr.Status.Pools) != 2 || cr.Status.Pools[0].Name != "auto_generated_pool_enterprise" || cr.Status.Pools[0].Managed ||
+		cr.Status.Pools[0].QuotaBytes != 10*1024*1024*1024 || cr.Status.Pools[1].Name != "prod" || !cr.Status.Pools[1].Managed || cr.Status.Pools[1].QuotaBytes != 1024*1024*1024 {
+		t.Errorf("unexpected pool status %v", cr.Status.Pools)
+	}
+	if len(cr.Status.Stacks) != 1 || cr.Status.Stacks[0].UsedBytes != 1024 || cr.Status.Stacks[0].Violations != 0 || cr.Status.LicenseCheckTime == 0 {
+		t.Errorf("unexpected stack status %v", cr.Status.Stacks)
+	}
+
+	// nothing changes
+	_, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if got := licenser.popRequests(); err != nil || len(got) != 0 {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+
+	// license usage is not reported when it is not available
+	licenser.failing = "messages"
+	cr.Status.LicenseCheckTime = 0
+	restarted, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if err != nil || restarted || cr.Status.LicenseCheckTime != 0 || len(cr.Status.Stacks) != 1 {
+		t.Errorf("license usage should be reported on a best effort basis. err: %v, status: %v", err, cr.Status.Stacks)
+	}
+	licenser.failing = ""
+
+	// pool quota is updated, and violations are reported
+	cr.Spec.Pools[0].Quota = "2Gi"
+	licenser.messages = []splclient.LicenseMessage{{Category: "pool_over_quota", PoolID: "prod"}, {Category: "orphan_peer"}}
+	_, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if got := licenser.popRequests(); err != nil || len(got) != 1 || got[0] != "POST pools/prod" {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+	if cr.Status.Pools[1].QuotaBytes != 2*1024*1024*1024 || cr.Status.Stacks[0].Violations != 1 {
+		t.Errorf("unexpected status %v %v", cr.Status.Pools, cr.Status.Stacks)
+	}
+
+	// changed license replaces the installed license
+	oldHash := cr.Status.Licenses[0].Hash
+	secret.Data["enterprise.lic"] = []byte("license-v2")
+	c.AddObject(secret)
+	restarted, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if got := licenser.popRequests(); err != nil || !restarted || len(got) != 3 || got[0] != "DELETE licenses/"+oldHash || got[1] != "POST licenses" {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+	if len(cr.Status.Licenses) != 1 || cr.Status.Licenses[0].Hash == oldHash || cr.Status.Licenses[0].Name != "enterprise" {
+		t.Errorf("unexpected license status %v", cr.Status.Licenses)
+	}
+
+	// expiring license is reported
+	hash := cr.Status.Licenses[0].Hash
+	license := licenser.licenses[hash]
+	license.ExpirationTime = time.Now().Add(7 * 24 * time.Hour).Unix()
+	licenser.licenses[hash] = license
+	_, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if err != nil || !cr.Status.Licenses[0].Expiring {
+		t.Errorf("license should be expiring. err: %v, status: %v", err, cr.Status.Licenses)
+	}
+	licenser.popRequests()
+
+	// pools and licenses removed from the spec are deleted
+	cr.Spec.Pools = nil
+	_, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if got := licenser.popRequests(); err != nil || len(got) != 1 || got[0] != "DELETE pools/prod" {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+	cr.Spec.Licenses = nil
+	restarted, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if got := licenser.popRequests(); err != nil || !restarted || len(got) != 2 || got[0] != "DELETE licenses/"+hash {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+	if len(cr.Status.Licenses) != 0 {
+		t.Errorf("unexpected license status %v", cr.Status.Licenses)
+	}
+
+	// nothing is applied once the licenses and the license pools are removed
+	licenser.requests = nil
+	restarted, err = applyLicenseManagerLicenses(ctx, c, &cr)
+	if err != nil || restarted || len(licenser.requests) != 0 {
+		t.Errorf("applyLicenseManagerLicenses() should not apply anything. err: %v, requests: %v", err, licenser.requests)
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	// embed the time zone database, as the operator image may not have one
+	_ "time/tzdata"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// max. number of years to look ahead for the next maintenance window, enough for a leap day
+	maxMaintenanceWindowLookAheadYears = 8
+)
+
+// cronSchedule represents a parsed cron schedule, each field is a bitmap of the allowed values
+type cronSchedule struct {
+	minutes     uint64
+	hours       uint64
+	daysOfMonth uint64
+	months      uint64
+	daysOfWeek  uint64
+
+	// as per cron, when both day fields are restricted, either of them can match
+	daysOfMonthRestricted bool
+	daysOfWeekRestricted  bool
+}
+
+// maintenanceWindow is the parsed form of the MaintenanceWindowSpec
+type maintenanceWindow struct {
+	schedule *cronSchedule
+	duration time.Duration
+	location *time.Location
+}
+
+// parseCronField parses a single cron field, which is a comma separated list of values(5), ranges(1-5),
+// wildcard(*) with an optional step(*/10, 1-30/5)
+func parseCronField(field string, min, max int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step in %s", part)
+			}
+			part = part[:idx]
+		}
+
+		var lo, hi int
+		if part == "*" {
+			lo, hi = min, max
+		} else if idx := strings.Index(part, "-"); idx >= 0 {
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(part[:idx])
+			hi, err2 = strconv.Atoi(part[idx+1:])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %s", part)
+			}
+		} else {
+			var err error
+			lo, err = strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %s", part)
+			}
+			hi = lo
+			if step > 1 {
+				hi = max
+			}
+		}
+
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%s is out of range %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+// parseCronSchedule parses the standard 5 field cron schedule: minute hour day-of-month month day-of-week
+func parseCronSchedule(schedule string) (*cronSchedule, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q, expected 5 fields: minute hour day-of-month month day-of-week", schedule)
+	}
+
+	limits := [][2]int{{0, 59}, {0, 23}, {1, 31}, {1, 12}, {0, 7}}
+	bits := make([]uint64, len(fields))
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, limits[i][0], limits[i][1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q. error: %v", schedule, err)
+		}
+	}
+
+	// both 0 and 7 represent Sunday
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	return &cronSchedule{
+		minutes:               bits[0],
+		hours:                 bits[1],
+		daysOfMonth:           bits[2],
+		months:                bits[3],
+		daysOfWeek:            bits[4],
+		daysOfMonthRestricted: !strings.HasPrefix(fields[2], "*"),
+		daysOfWeekRestricted:  !strings.HasPrefix(fields[4], "*"),
+	}, nil
+}
+
+// matches confirms if the schedule fires at the given time, with a minute granularity
+func (sched *cronSchedule) matches(t time.Time) bool {
+	if sched.minutes&(1<<uint(t.Minute())) == 0 || sched.hours&(1<<uint(t.Hour())) == 0 || sched.months&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	return sched.matchesDay(t)
+}
+
+// matchesDay confirms if the schedule fires on the day of the given time
+func (sched *cronSchedule) matchesDay(t time.Time) bool {
+	domMatch := sched.daysOfMonth&(1<<uint(t.Day())) != 0
+	dowMatch := sched.daysOfWeek&(1<<uint(t.Weekday())) != 0
+	if sched.daysOfMonthRestricted && sched.daysOfWeekRestricted {
+		return domMatch || dowMatch
+	}
+
+	return domMatch && dowMatch
+}
+
+// next returns the first time the schedule fires at or after the given time, in the location of the given time.
+// The fields are advanced from the month down to the minute, resetting the lower fields whenever a field is advanced.
+// Returns the zero time if the schedule does not fire within the look ahead
+func (sched *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Truncate(time.Minute)
+	yearLimit := t.Year() + maxMaintenanceWindowLookAheadYears
+
+	// once a field is advanced, the lower fields start from their first value
+	advanced := false
+wrap:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+
+	for sched.months&(1<<uint(t.Month())) == 0 {
+		if !advanced {
+			advanced = true
+			t = time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 1, 0)
+		if t.Month() == time.January {
+			goto wrap
+		}
+	}
+
+	for !sched.matchesDay(t) {
+		if !advanced {
+			advanced = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		}
+		t = t.AddDate(0, 0, 1)
+		if t.Day() == 1 {
+			goto wrap
+		}
+	}
+
+	for sched.hours&(1<<uint(t.Hour())) == 0 {
+		if !advanced {
+			advanced = true
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, loc)
+		}
+		t = t.Add(time.Hour)
+		if t.Hour() == 0 {
+			goto wrap
+		}
+	}
+
+	for sched.minutes&(1<<uint(t.Minute())) == 0 {
+		advanced = true
+		t = t.Add(time.Minute)
+		if t.Minute() == 0 {
+			goto wrap
+		}
+	}
+
+	return t
+}
+
+// parseMaintenanceWindow validates and parses the maintenance window spec
+func parseMaintenanceWindow(spec *enterpriseApi.MaintenanceWindowSpec) (*maintenanceWindow, error) {
+	schedule, err := parseCronSchedule(spec.Schedule)
+	if err != nil {
+		return nil, err
+	}
+
+	if spec.DurationMinutes == 0 || time.Duration(spec.DurationMinutes)*time.Minute > 7*24*time.Hour {
+		return nil, fmt.Errorf("invalid durationMinutes %d for schedule %q, should be between 1 and 10080", spec.DurationMinutes, spec.Schedule)
+	}
+
+	location := time.UTC
+	if spec.TimeZone != "" {
+		location, err = time.LoadLocation(spec.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timeZone %s for schedule %q. error: %v", spec.TimeZone, spec.Schedule, err)
+		}
+	}
+
+	return &maintenanceWindow{
+		schedule: schedule,
+		duration: time.Duration(spec.DurationMinutes) * time.Minute,
+		location: location,
+	}, nil
+}
+
+// isOpen confirms if the maintenance window is open at the given time, i.e. the window opened within its duration
+func (window *maintenanceWindow) isOpen(now time.Time) bool {
+	t := now.In(window.location).Truncate(time.Minute)
+	start := window.schedule.next(t.Add(time.Minute - window.duration))
+	return !start.IsZero() && !start.After(t)
+}
+
+// nextStart returns the time the maintenance window opens next, after the given time
+func (window *maintenanceWindow) nextStart(now time.Time) time.Time {
+	return window.schedule.next(now.In(window.location).Truncate(time.Minute).Add(time.Minute))
+}
+
+// getMaintenanceWindowState confirms if any of the maintenance windows is open at the given time. Otherwise,
+// returns the time the next window opens. No maintenance windows means the work is never held
+func getMaintenanceWindowState(windows []enterpriseApi.MaintenanceWindowSpec, now time.Time) (bool, time.Time, error) {
+	var nextStart time.Time
+	for i := range windows {
+		window, err := parseMaintenanceWindow(&windows[i])
+		if err != nil {
+			return false, nextStart, err
+		}
+
+		if window.isOpen(now) {
+			return true, time.Time{}, nil
+		}
+
+		start := window.nextStart(now)
+		if !start.IsZero() && (nextStart.IsZero() || start.Before(nextStart)) {
+			nextStart = start
+		}
+	}
+
+	return len(windows) == 0, nextStart, nil
+}
+
+// isMaintenanceWindowOverridden confirms if the CR is annotated to ignore the maintenance windows
+func isMaintenanceWindowOverridden(cr splcommon.MetaObject) bool {
+	return cr.GetAnnotations()[enterpriseApi.MaintenanceWindowOverrideAnnotation] == "true"
+}
+
+// isWorkHeldForMaintenanceWindow confirms if the app installs and the bundle push should wait for a maintenance
+// window to open, and returns the time the next window opens
+func isWorkHeldForMaintenanceWindow(ctx context.Context, cr splcommon.MetaObject, afwConfig *enterpriseApi.AppFrameworkSpec) (bool, time.Time) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("isWorkHeldForMaintenanceWindow").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	if len(afwConfig.MaintenanceWindows) == 0 {
+		return false, time.Time{}
+	}
+
+	if isMaintenanceWindowOverridden(cr) {
+		scopedLog.Info("maintenance windows are overridden by the annotation", "annotation", enterpriseApi.MaintenanceWindowOverrideAnnotation)
+		return false, time.Time{}
+	}
+
+	open, nextStart, err := getMaintenanceWindowState(afwConfig.MaintenanceWindows, time.Now())
+	if err != nil {
+		// spec validation should have caught it, so do not block the work for an invalid config
+		scopedLog.Error(err, "unable to evaluate the maintenance windows")
+		return false, time.Time{}
+	}
+
+	return !open, nextStart
+}
+
+// isBundlePushHeldForRestartWindow confirms if a bundle push should wait for a restart window to open, as it requires a
+// rolling restart, and returns the time the next window opens
+func isBundlePushHeldForRestartWindow(ctx context.Context, cr splcommon.MetaObject, policy *enterpriseApi.BundlePushPolicySpec, restartRequired bool) (bool, time.Time) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("isBundlePushHeldForRestartWindow").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	if !restartRequired || len(policy.RestartWindows) == 0 {
+		return false, time.Time{}
+	}
+
+	if isMaintenanceWindowOverridden(cr) {
+		scopedLog.Info("restart windows are overridden by the annotation", "annotation", enterpriseApi.MaintenanceWindowOverrideAnnotation)
+		return false, time.Time{}
+	}
+
+	open, nextStart, err := getMaintenanceWindowState(policy.RestartWindows, time.Now())
+	if err != nil {
+		// spec validation should have caught it, so do not block the bundle push for an invalid config
+		scopedLog.Error(err, "unable to evaluate the restart windows")
+		return false, time.Time{}
+	}
+
+	return !open, nextStart
+}
+
+// setBundlePushHeld reports the bundle push held for the restart window in the bundle validation status
+func setBundlePushHeld(status *enterpriseApi.BundleValidationStatus, held bool, nextWindowStart time.Time) {
+	status.PushHeld = held
+	status.NextWindowStart = 0
+	if held && !nextWindowStart.IsZero() {
+		status.NextWindowStart = nextWindowStart.Unix()
+	}
+}
+
+// updateMaintenanceWindowStatus reports the app installs and the bundle push held for the maintenance window
+func updateMaintenanceWindowStatus(afwPipeline *AppInstallPipeline, nextWindowStart time.Time) {
+	status := &afwPipeline.appDeployContext.MaintenanceWindowStatus
+	*status = enterpriseApi.MaintenanceWindowStatus{}
+	if !afwPipeline.installHeld {
+		return
+	}
+
+	status.Held = true
+	if !nextWindowStart.IsZero() {
+		status.NextWindowStart = nextWindowStart.Unix()
+	}
+
+	heldApps := make(map[string]bool)
+	for _, worker := range afwPipeline.pplnPhases[enterpriseApi.PhaseInstall].q {
+		heldApps[worker.appSrcName+"/"+worker.appDeployInfo.AppName] = true
+	}
+	for app := range heldApps {
+		status.HeldApps = append(status.HeldApps, app)
+	}
+	sort.Strings(status.HeldApps)
+
+	status.BundlePushHeld = isPendingClusterScopeWork(afwPipeline) && afwPipeline.appDeployContext.BundlePushStatus.BundlePushStage == enterpriseApi.BundlePushPending
+}
+
+// isAppFrameworkWaitingForMaintenanceWindow confirms if all the pending app framework work is held for the maintenance
+// window, so that the reconcile can be deferred until the window opens
+func isAppFrameworkWaitingForMaintenanceWindow(appDeployContext *enterpriseApi.AppDeploymentContext) bool {
+	if !appDeployContext.MaintenanceWindowStatus.Held || appDeployContext.MaintenanceWindowStatus.NextWindowStart == 0 {
+		return false
+	}
+
+	if appDeployContext.BundlePushStatus.BundlePushStage == enterpriseApi.BundlePushInProgress {
+		return false
+	}
+
+	for _, appSrcDeployInfo := range appDeployContext.AppsSrcDeployStatus {
+		for _, appDeployInfo := range appSrcDeployInfo.AppDeploymentInfoList {
+			if appDeployInfo.RepoState != enterpriseApi.RepoStateActive {
+				continue
+			}
+
+			phaseInfo := appDeployInfo.PhaseInfo
+			switch phaseInfo.Phase {
+			case enterpriseApi.PhaseDownload:
+				if phaseInfo.Status != enterpriseApi.AppPkgDownloadError && phaseInfo.Status != enterpriseApi.AppPkgValidationError {
+					return false
+				}
+			case enterpriseApi.PhasePodCopy:
+				if phaseInfo.Status != enterpriseApi.AppPkgPodCopyComplete && phaseInfo.Status != enterpriseApi.AppPkgPodCopyError {
+					return false
+				}
+			}
+
+			for _, auxPhaseInfo := range appDeployInfo.AuxPhaseInfo {
+				if auxPhaseInfo.Phase == enterpriseApi.PhasePodCopy && auxPhaseInfo.Status != enterpriseApi.AppPkgPodCopyComplete && auxPhaseInfo.Status != enterpriseApi.AppPkgPodCopyError {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+// getMaintenanceWindowRequeueTime returns the time until the next maintenance window opens
+func getMaintenanceWindowRequeueTime(appDeployContext *enterpriseApi.AppDeploymentContext, now time.Time) time.Duration {
+	return getWindowRequeueTime(appDeployContext.MaintenanceWindowStatus.NextWindowStart, now)
+}
+
+// getWindowRequeueTime returns the time until the window opens at nextWindowStart, in Unix epoch seconds
+func getWindowRequeueTime(nextWindowStart int64, now time.Time) time.Duration {
+	requeueAfter := time.Unix(nextWindowStart, 0).Sub(now)
+	if requeueAfter < time.Second*5 {
+		requeueAfter = time.Second * 5
+	}
+	return requeueAfter
+}
@@ -0,0 +1,466 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestParseCronSchedule(t *testing.T) {
+	sched, err := parseCronSchedule("*/15 1-3,22 * * 0-5/2")
+	if err != nil {
+		t.Fatalf("Should pass, valid schedule. error: %v", err)
+	}
+
+	if sched.minutes != 1|1<<15|1<<30|1<<45 {
+		t.Errorf("Unexpected minutes %b", sched.minutes)
+	}
+	if sched.hours != 1<<1|1<<2|1<<3|1<<22 {
+		t.Errorf("Unexpected hours %b", sched.hours)
+	}
+	if sched.daysOfWeek != 1|1<<2|1<<4 {
+		t.Errorf("Unexpected days of week %b", sched.daysOfWeek)
+	}
+	if sched.daysOfMonthRestricted || !sched.daysOfWeekRestricted {
+		t.Errorf("Unexpected day restrictions, day of month: %t, day of week: %t", sched.daysOfMonthRestricted, sched.daysOfWeekRestricted)
+	}
+
+	// 7 is also Sunday
+	sched, err = parseCronSchedule("0 0 * * 7")
+	if err != nil || sched.daysOfWeek != 1|1<<7 {
+		t.Errorf("Sunday should be allowed as 7. error: %v", err)
+	}
+
+	invalidSchedules := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"5-1 * * * *",
+		"*/0 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+	}
+	for _, schedule := range invalidSchedules {
+		_, err = parseCronSchedule(schedule)
+		if err == nil {
+			t.Errorf("Expected an error for invalid schedule %q", schedule)
+		}
+	}
+}
+
+func TestCronScheduleMatches(t *testing.T) {
+	// 2022-08-01 is a Monday
+	monday := time.Date(2022, 8, 1, 2, 30, 0, 0, time.UTC)
+
+	sched, _ := parseCronSchedule("30 2 * * 1")
+	if !sched.matches(monday) {
+		t.Errorf("Schedule should match on Monday 02:30")
+	}
+	if sched.matches(monday.Add(time.Minute)) || sched.matches(monday.AddDate(0, 0, 1)) {
+		t.Errorf("Schedule should match only on Monday 02:30")
+	}
+
+	// either of the restricted day fields can match
+	sched, _ = parseCronSchedule("30 2 15 * 1")
+	if !sched.matches(monday) || !sched.matches(monday.AddDate(0, 0, 14)) {
+		t.Errorf("Schedule should match on Monday and on the 15th")
+	}
+	if sched.matches(monday.AddDate(0, 0, 1)) {
+		t.Errorf("Schedule should not match on Tuesday 2nd")
+	}
+
+	// only the day of month is restricted
+	sched, _ = parseCronSchedule("30 2 1 * *")
+	if !sched.matches(monday) || sched.matches(monday.AddDate(0, 0, 7)) {
+		t.Errorf("Schedule should match only on the 1st")
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	newYork, _ := time.LoadLocation("America/New_York")
+	from := time.Date(2022, 8, 1, 2, 31, 0, 0, newYork)
+
+	// the next time matches the first matching minute found minute by minute
+	for _, schedule := range []string{"30 2 * * 1", "*/15 9-17 * * 1-5", "0 0 1 */3 *", "30 2 15 * 1", "59 23 31 12 *"} {
+		sched, _ := parseCronSchedule(schedule)
+		want := from
+		for !sched.matches(want) {
+			want = want.Add(time.Minute)
+		}
+		if got := sched.next(from); !got.Equal(want) {
+			t.Errorf("Unexpected next time for %q. got %v, want %v", schedule, got, want)
+		}
+	}
+
+	// a leap day more than a year ahead
+	sched, _ := parseCronSchedule("0 0 29 2 *")
+	if got, want := sched.next(from), time.Date(2024, 2, 29, 0, 0, 0, 0, newYork); !got.Equal(want) {
+		t.Errorf("Unexpected next leap day. got %v, want %v", got, want)
+	}
+
+	// 02:30 does not exist on the day New York moves to the summer time
+	sched, _ = parseCronSchedule("30 2 * * *")
+	got := sched.next(time.Date(2023, 3, 12, 0, 0, 0, 0, newYork))
+	if got.Before(time.Date(2023, 3, 12, 3, 0, 0, 0, newYork)) || got.After(time.Date(2023, 3, 13, 2, 30, 0, 0, newYork)) {
+		t.Errorf("Unexpected next time across the daylight saving time change %v", got)
+	}
+}
+
+func TestParseMaintenanceWindow(t *testing.T) {
+	spec := enterpriseApi.MaintenanceWindowSpec{
+		Schedule:        "0 1 * * 6",
+		DurationMinutes: 120,
+		TimeZone:        "America/Los_Angeles",
+	}
+
+	window, err := parseMaintenanceWindow(&spec)
+	if err != nil {
+		t.Fatalf("Should pass, valid maintenance window. error: %v", err)
+	}
+	if window.duration != 2*time.Hour || window.location.String() != "America/Los_Angeles" {
+		t.Errorf("Unexpected maintenance window, duration: %v, location: %v", window.duration, window.location)
+	}
+
+	// UTC is the default time zone
+	spec.TimeZone = ""
+	window, err = parseMaintenanceWindow(&spec)
+	if err != nil || window.location != time.UTC {
+		t.Errorf("UTC should be the default time zone. error: %v", err)
+	}
+
+	spec.TimeZone = "Invalid/Zone"
+	_, err = parseMaintenanceWindow(&spec)
+	if err == nil {
+		t.Errorf("Expected an error for the invalid time zone")
+	}
+
+	spec.TimeZone = ""
+	spec.DurationMinutes = 0
+	_, err = parseMaintenanceWindow(&spec)
+	if err == nil {
+		t.Errorf("Expected an error for the zero duration")
+	}
+
+	spec.DurationMinutes = 10081
+	_, err = parseMaintenanceWindow(&spec)
+	if err == nil {
+		t.Errorf("Expected an error for the duration longer than a week")
+	}
+
+	spec.DurationMinutes = 60
+	spec.Schedule = "0 1 * *"
+	_, err = parseMaintenanceWindow(&spec)
+	if err == nil {
+		t.Errorf("Expected an error for the invalid schedule")
+	}
+}
+
+func TestMaintenanceWindowIsOpen(t *testing.T) {
+	// Saturdays 01:00 to 03:00 in New York
+	window, err := parseMaintenanceWindow(&enterpriseApi.MaintenanceWindowSpec{
+		Schedule:        "0 1 * * 6",
+		DurationMinutes: 120,
+		TimeZone:        "America/New_York",
+	})
+	if err != nil {
+		t.Fatalf("Should pass, valid maintenance window. error: %v", err)
+	}
+
+	// 2022-08-06 is a Saturday, and New York is UTC-4 during the summer
+	start := time.Date(2022, 8, 6, 5, 0, 0, 0, time.UTC)
+	if window.isOpen(start.Add(-time.Second)) {
+		t.Errorf("Window should not be open before the start")
+	}
+	if !window.isOpen(start) || !window.isOpen(start.Add(119*time.Minute)) {
+		t.Errorf("Window should be open during the window")
+	}
+	if window.isOpen(start.Add(2 * time.Hour)) {
+		t.Errorf("Window should be closed after the duration")
+	}
+
+	next := window.nextStart(start.Add(2 * time.Hour))
+	if !next.Equal(start.AddDate(0, 0, 7)) {
+		t.Errorf("Unexpected next window start %v", next)
+	}
+
+	next = window.nextStart(start.Add(-time.Hour))
+	if !next.Equal(start) {
+		t.Errorf("Unexpected next window start %v", next)
+	}
+
+	// window spanning the midnight
+	window, _ = parseMaintenanceWindow(&enterpriseApi.MaintenanceWindowSpec{
+		Schedule:        "30 23 * * *",
+		DurationMinutes: 60,
+	})
+	if !window.isOpen(time.Date(2022, 8, 7, 0, 15, 0, 0, time.UTC)) {
+		t.Errorf("Window should be open after the midnight")
+	}
+	if window.isOpen(time.Date(2022, 8, 7, 0, 30, 0, 0, time.UTC)) {
+		t.Errorf("Window should be closed after the duration")
+	}
+
+	// Feb 30th never occurs
+	window, _ = parseMaintenanceWindow(&enterpriseApi.MaintenanceWindowSpec{
+		Schedule:        "0 0 30 2 *",
+		DurationMinutes: 60,
+	})
+	if !window.nextStart(start).IsZero() {
+		t.Errorf("Window should never open")
+	}
+}
+
+func TestGetMaintenanceWindowState(t *testing.T) {
+	now := time.Date(2022, 8, 1, 12, 0, 0, 0, time.UTC)
+
+	// no maintenance windows
+	open, _, err := getMaintenanceWindowState(nil, now)
+	if !open || err != nil {
+		t.Errorf("Should be open without the maintenance windows. error: %v", err)
+	}
+
+	windows := []enterpriseApi.MaintenanceWindowSpec{
+		{Schedule: "0 20 * * *", DurationMinutes: 60},
+		{Schedule: "0 14 * * *", DurationMinutes: 60},
+	}
+	open, nextStart, err := getMaintenanceWindowState(windows, now)
+	if open || err != nil {
+		t.Errorf("Should be closed. error: %v", err)
+	}
+	if !nextStart.Equal(time.Date(2022, 8, 1, 14, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected next window start %v", nextStart)
+	}
+
+	open, _, err = getMaintenanceWindowState(windows, nextStart.Add(30*time.Minute))
+	if !open || err != nil {
+		t.Errorf("Should be open. error: %v", err)
+	}
+
+	windows = append(windows, enterpriseApi.MaintenanceWindowSpec{Schedule: "invalid"})
+	_, _, err = getMaintenanceWindowState(windows, now)
+	if err == nil {
+		t.Errorf("Expected an error for the invalid maintenance window")
+	}
+}
+
+func TestIsWorkHeldForMaintenanceWindow(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	afwConfig := enterpriseApi.AppFrameworkSpec{}
+	held, _ := isWorkHeldForMaintenanceWindow(ctx, &cr, &afwConfig)
+	if held {
+		t.Errorf("Work should not be held without the maintenance windows")
+	}
+
+	// window opens every Feb 29th at midnight, so it is closed most of the time
+	afwConfig.MaintenanceWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 29 2 *", DurationMinutes: 1}}
+	now := time.Now()
+	if now.Month() == time.February && now.Day() == 29 && now.Hour() == 0 && now.Minute() == 0 {
+		t.Skip("Maintenance window is open now")
+	}
+
+	held, nextStart := isWorkHeldForMaintenanceWindow(ctx, &cr, &afwConfig)
+	if !held {
+		t.Errorf("Work should be held outside the maintenance window")
+	}
+	if !nextStart.IsZero() && (nextStart.Month() != time.February || nextStart.Day() != 29) {
+		t.Errorf("Unexpected next window start %v", nextStart)
+	}
+
+	// emergency override
+	cr.Annotations = map[string]string{enterpriseApi.MaintenanceWindowOverrideAnnotation: "true"}
+	held, _ = isWorkHeldForMaintenanceWindow(ctx, &cr, &afwConfig)
+	if held {
+		t.Errorf("Work should not be held with the override annotation")
+	}
+
+	// invalid windows do not hold the work
+	cr.Annotations = nil
+	afwConfig.MaintenanceWindows[0].Schedule = "invalid"
+	held, _ = isWorkHeldForMaintenanceWindow(ctx, &cr, &afwConfig)
+	if held {
+		t.Errorf("Work should not be held for invalid maintenance windows")
+	}
+}
+
+func TestIsBundlePushHeldForRestartWindow(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	// window opens every Feb 29th at midnight, so it is closed most of the time
+	policy := enterpriseApi.BundlePushPolicySpec{}
+	now := time.Now()
+	if now.Month() == time.February && now.Day() == 29 && now.Hour() == 0 && now.Minute() == 0 {
+		t.Skip("Restart window is open now")
+	}
+
+	held, _ := isBundlePushHeldForRestartWindow(ctx, &cr, &policy, true)
+	if held {
+		t.Errorf("Bundle push should not be held without the restart windows")
+	}
+
+	policy.RestartWindows = []enterpriseApi.MaintenanceWindowSpec{{Schedule: "0 0 29 2 *", DurationMinutes: 1}}
+	held, _ = isBundlePushHeldForRestartWindow(ctx, &cr, &policy, false)
+	if held {
+		t.Errorf("Bundle push should not be held when it does not require a restart")
+	}
+
+	held, nextStart := isBundlePushHeldForRestartWindow(ctx, &cr, &policy, true)
+	if !held {
+		t.Errorf("Bundle push requiring a restart should be held outside the restart window")
+	}
+
+	status := enterpriseApi.BundleValidationStatus{}
+	setBundlePushHeld(&status, held, nextStart)
+	if !status.PushHeld || (!nextStart.IsZero() && status.NextWindowStart != nextStart.Unix()) {
+		t.Errorf("Unexpected bundle validation status %v", status)
+	}
+	setBundlePushHeld(&status, false, nextStart)
+	if status.PushHeld || status.NextWindowStart != 0 {
+		t.Errorf("Unexpected bundle validation status %v", status)
+	}
+
+	// emergency override
+	cr.Annotations = map[string]string{enterpriseApi.MaintenanceWindowOverrideAnnotation: "true"}
+	held, _ = isBundlePushHeldForRestartWindow(ctx, &cr, &policy, true)
+	if held {
+		t.Errorf("Bundle push should not be held with the override annotation")
+	}
+}
+
+func TestUpdateMaintenanceWindowStatus(t *testing.T) {
+	cr := enterpriseApi.ClusterManager{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterManager",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+
+	appDeployContext := &enterpriseApi.AppDeploymentContext{}
+	ppln := &AppInstallPipeline{
+		pplnPhases:       map[enterpriseApi.AppPhaseType]*PipelinePhase{enterpriseApi.PhaseInstall: {}},
+		appDeployContext: appDeployContext,
+		cr:               &cr,
+	}
+	ppln.pplnPhases[enterpriseApi.PhaseInstall].q = []*PipelineWorker{
+		{appSrcName: "appSrc1", appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app2.tgz"}},
+		{appSrcName: "appSrc1", appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"}},
+		{appSrcName: "appSrc1", appDeployInfo: &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz"}},
+	}
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
+
+	// not held
+	updateMaintenanceWindowStatus(ppln, time.Time{})
+	if !reflect.DeepEqual(appDeployContext.MaintenanceWindowStatus, enterpriseApi.MaintenanceWindowStatus{}) {
+		t.Errorf("Status should be empty when the work is not held, got: %+v", appDeployContext.MaintenanceWindowStatus)
+	}
+
+	ppln.installHeld = true
+	nextStart := time.Unix(1660000000, 0)
+	updateMaintenanceWindowStatus(ppln, nextStart)
+	expected := enterpriseApi.MaintenanceWindowStatus{
+		Held:            true,
+		NextWindowStart: 1660000000,
+		HeldApps:        []string{"appSrc1/app1.tgz", "appSrc1/app2.tgz"},
+		BundlePushHeld:  true,
+	}
+	if !reflect.DeepEqual(appDeployContext.MaintenanceWindowStatus, expected) {
+		t.Errorf("Expected status: %+v, got: %+v", expected, appDeployContext.MaintenanceWindowStatus)
+	}
+
+	// pending bundle push is not run, but an in progress bundle push is still tracked
+	if needToRunClusterScopedPlaybook(ppln) {
+		t.Errorf("Bundle push should be held for the maintenance window")
+	}
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
+	ppln.afwEntryTime = time.Now().Unix()
+	appDeployContext.AppFrameworkConfig.SchedulerYieldInterval = 60
+	if !needToRunClusterScopedPlaybook(ppln) {
+		t.Errorf("Bundle push in progress should be tracked during the hold")
+	}
+}
+
+func TestIsAppFrameworkWaitingForMaintenanceWindow(t *testing.T) {
+	appDeployContext := &enterpriseApi.AppDeploymentContext{
+		AppsSrcDeployStatus: map[string]enterpriseApi.AppSrcDeployInfo{
+			"appSrc1": {
+				AppDeploymentInfoList: []enterpriseApi.AppDeploymentInfo{
+					{
+						AppName:   "app1.tgz",
+						RepoState: enterpriseApi.RepoStateActive,
+						PhaseInfo: enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseInstall, Status: enterpriseApi.AppPkgInstallPending},
+					},
+					{
+						AppName:   "app2.tgz",
+						RepoState: enterpriseApi.RepoStateActive,
+						PhaseInfo: enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhaseDownload, Status: enterpriseApi.AppPkgDownloadPending},
+					},
+				},
+			},
+		},
+	}
+
+	if isAppFrameworkWaitingForMaintenanceWindow(appDeployContext) {
+		t.Errorf("Should not wait when the work is not held")
+	}
+
+	appDeployContext.MaintenanceWindowStatus = enterpriseApi.MaintenanceWindowStatus{Held: true, NextWindowStart: 1660000000}
+	if isAppFrameworkWaitingForMaintenanceWindow(appDeployContext) {
+		t.Errorf("Should not wait when a download is pending")
+	}
+
+	appDeployContext.AppsSrcDeployStatus["appSrc1"].AppDeploymentInfoList[1].PhaseInfo = enterpriseApi.PhaseInfo{Phase: enterpriseApi.PhasePodCopy, Status: enterpriseApi.AppPkgPodCopyComplete}
+	if !isAppFrameworkWaitingForMaintenanceWindow(appDeployContext) {
+		t.Errorf("Should wait when only the installs are pending")
+	}
+
+	now := time.Unix(1660000000-3600, 0)
+	if getMaintenanceWindowRequeueTime(appDeployContext, now) != time.Hour {
+		t.Errorf("Should requeue when the window opens")
+	}
+	if getMaintenanceWindowRequeueTime(appDeployContext, now.Add(2*time.Hour)) != time.Second*5 {
+		t.Errorf("Should requeue after 5 seconds, once the window start is passed")
+	}
+
+	appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushInProgress
+	if isAppFrameworkWaitingForMaintenanceWindow(appDeployContext) {
+		t.Errorf("Should not wait when a bundle push is in progress")
+	}
+}
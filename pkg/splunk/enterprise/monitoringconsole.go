@@ -19,7 +19,7 @@ import (
 	"context"
 	"fmt"
 	"reflect"
-	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -58,7 +58,7 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 
 	var err error
 	// Initialize phase
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 
 	// Update the CR Status
 	defer updateCRStatus(ctx, client, cr, &err)
@@ -147,7 +147,19 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 		return result, err
 	}
 
-	mgr := splctrl.DefaultStatefulSetPodManager{}
+	// revert the version upgrade when the pods are not ready within the health deadline
+	continueReconcile, err = applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkMonitoringConsole, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// hold the version upgrade until the upgrade policy allows it
+	continueReconcile, err = applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkMonitoringConsole, lastPhase, &cr.Status.Upgrade)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	mgr := splctrl.DefaultStatefulSetPodManager{VolumeResize: &cr.Status.VolumeResize}
 	phase, err := mgr.Update(ctx, client, statefulSet, 1)
 	if err != nil {
 		eventPublisher.Warning(ctx, "getMonitoringConsoleStatefulSet", fmt.Sprintf("update to default statefuleset pod manager failed %s", err.Error()))
@@ -160,6 +172,20 @@ func ApplyMonitoringConsole(ctx context.Context, client splcommon.ControllerClie
 		finalResult := handleAppFrameworkActivity(ctx, client, cr, &cr.Status.AppContext, &cr.Spec.AppFrameworkConfig)
 		result = *finalResult
 
+		// enable the platform alerts and the forwarder monitoring, and apply the custom groups
+		if isMonitoringConsoleConfigured(cr) {
+			err = applyMonitoringConsoleConfig(ctx, client, cr)
+			if err != nil {
+				eventPublisher.Warning(ctx, "applyMonitoringConsoleConfig", fmt.Sprintf("monitoring console configuration failed %s", err.Error()))
+				return result, err
+			}
+
+			// members of the custom groups change with the custom resources
+			if !result.Requeue || result.RequeueAfter > monitoringConsoleConfigInterval*time.Second {
+				result.Requeue = true
+				result.RequeueAfter = monitoringConsoleConfigInterval * time.Second
+			}
+		}
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
@@ -226,6 +252,10 @@ func validateMonitoringConsoleSpec(ctx context.Context, c splcommon.ControllerCl
 			return err
 		}
 	}
+	err := validateMonitoringConsoleConfig(&cr.Spec)
+	if err != nil {
+		return err
+	}
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
 
@@ -234,8 +264,15 @@ func ApplyMonitoringConsoleEnvConfigMap(ctx context.Context, client splcommon.Co
 
 	var current corev1.ConfigMap
 
-	configMap := GetSplunkMonitoringconsoleConfigMapName(monitoringConsoleRef, SplunkMonitoringConsole)
-	namespacedName := types.NamespacedName{Namespace: namespace, Name: configMap}
+	// the monitoring console may live in another namespace, in which case the URLs published
+	// by the custom resource are qualified with its namespace to be reachable from there
+	mcNamespace, mcName := parseMonitoringConsoleRef(namespace, monitoringConsoleRef)
+	if mcNamespace != namespace {
+		newURLs = getQualifiedMonitoringConsoleURLs(namespace, newURLs)
+	}
+
+	configMap := GetSplunkMonitoringconsoleConfigMapName(mcName, SplunkMonitoringConsole)
+	namespacedName := types.NamespacedName{Namespace: mcNamespace, Name: configMap}
 	err := client.Get(ctx, namespacedName, &current)
 
 	if err == nil {
@@ -263,12 +300,18 @@ func ApplyMonitoringConsoleEnvConfigMap(ctx context.Context, client splcommon.Co
 		return nil, err
 	}
 
+	// nothing to clean up for a monitoring console in another namespace, whose namespace
+	// may be terminating, so the deletion of the custom resource is not held by it
+	if !addNewURLs && mcNamespace != namespace {
+		return nil, nil
+	}
+
 	// case when resource not found
 	//If no configMap and deletion of CR is requested then create a empty configMap
 	current = corev1.ConfigMap{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      configMap,
-			Namespace: namespace,
+			Namespace: mcNamespace,
 		},
 		Data: make(map[string]string),
 	}
@@ -282,7 +325,7 @@ func ApplyMonitoringConsoleEnvConfigMap(ctx context.Context, client splcommon.Co
 
 	current.ObjectMeta = metav1.ObjectMeta{
 		Name:      configMap,
-		Namespace: namespace,
+		Namespace: mcNamespace,
 	}
 
 	err = splutil.CreateResource(ctx, client, &current)
@@ -293,77 +336,129 @@ func ApplyMonitoringConsoleEnvConfigMap(ctx context.Context, client splcommon.Co
 	return &current, nil
 }
 
+// getMonitoringConsoleRef returns the monitoring console reference of a custom resource in namespace,
+// as <namespace>/<name> when the monitoring console lives in another namespace
+func getMonitoringConsoleRef(namespace string, ref corev1.ObjectReference) string {
+	if ref.Name == "" || ref.Namespace == "" || ref.Namespace == namespace {
+		return ref.Name
+	}
+	return fmt.Sprintf("%s/%s", ref.Namespace, ref.Name)
+}
+
+// parseMonitoringConsoleRef returns the namespace and name of the monitoring console a reference
+// returned by getMonitoringConsoleRef points to
+func parseMonitoringConsoleRef(namespace string, monitoringConsoleRef string) (string, string) {
+	if i := strings.Index(monitoringConsoleRef, "/"); i >= 0 {
+		return monitoringConsoleRef[:i], monitoringConsoleRef[i+1:]
+	}
+	return namespace, monitoringConsoleRef
+}
+
+// getQualifiedMonitoringConsoleURLs qualifies the service names of namespace published to a
+// monitoring console with their fully qualified domain name
+func getQualifiedMonitoringConsoleURLs(namespace string, newURLs []corev1.EnvVar) []corev1.EnvVar {
+	qualified := make([]corev1.EnvVar, 0, len(newURLs))
+	for _, url := range newURLs {
+		// SPLUNK_SITE holds the site of a multisite cluster manager, not an URL
+		if url.Name != "SPLUNK_SITE" && url.Value != "" {
+			urls := strings.Split(url.Value, ",")
+			for i := range urls {
+				if !strings.Contains(urls[i], ".") {
+					urls[i] = splcommon.GetServiceFQDN(namespace, urls[i])
+				}
+			}
+			url.Value = strings.Join(urls, ",")
+		}
+		qualified = append(qualified, url)
+	}
+	return qualified
+}
+
+// containsMonitoringConsoleURL checks if url is one of the entries of urls
+func containsMonitoringConsoleURL(urls []string, url string) bool {
+	for _, entry := range urls {
+		if entry == url {
+			return true
+		}
+	}
+	return false
+}
+
+// getMonitoringConsoleURLPattern returns the URL of a statefulset pod without its ordinal, which is
+// the same for every replica of the statefulset
+func getMonitoringConsoleURLPattern(url string) string {
+	host, domain, _ := strings.Cut(url, ".")
+	i := strings.LastIndex(host, "-")
+	if i < 0 {
+		return url
+	}
+	if _, err := strconv.Atoi(host[i+1:]); err != nil {
+		return url
+	}
+	return fmt.Sprintf("%s.%s", host[:i], domain)
+}
+
 // AddURLsConfigMap for adding new server peers to the monitoring console or scaling up
 func AddURLsConfigMap(revised *corev1.ConfigMap, crName string, newURLs []corev1.EnvVar) {
 	for _, url := range newURLs {
 		_, ok := revised.Data[url.Name]
 		if !ok {
 			revised.Data[url.Name] = url.Value
-		} else {
-			newInsURLs := strings.Split(url.Value, ",")
-			//1. Find number of URLs, that crname,  present in the current configmap
-			var crURLs string
-			for _, newURL := range newInsURLs {
-				if strings.Contains(revised.Data[url.Name], newURL) {
-					if crURLs == "" {
-						crURLs = newURL
-					} else {
-						str := []string{crURLs, newURL}
-						crURLs = strings.Join(str, ",")
-					}
-				}
-			}
-			//2. if length of both same then just reconcile
-			if len(crURLs) == len(url.Value) {
-				//reconcile
-				break
-			} else if len(crURLs) < len(url.Value) { //3. incoming URLs are more than current scaling up
-				//scaling UP
-				for _, newEntry := range newInsURLs {
-					if !strings.Contains(revised.Data[url.Name], newEntry) {
-						str := []string{revised.Data[url.Name], newEntry}
-						revised.Data[url.Name] = strings.Join(str, ",")
-					}
-				}
-			} else { //4. incoming URLs are less than current then scaling down
-				//scaling DOWN pods
-				DeleteURLsConfigMap(revised, crName, newURLs, false)
+			continue
+		}
+		newInsURLs := strings.Split(url.Value, ",")
+		currentURLs := strings.Split(revised.Data[url.Name], ",")
+
+		//1. scaling UP, add the URLs missing from the current configmap
+		for _, newEntry := range newInsURLs {
+			if newEntry != "" && !containsMonitoringConsoleURL(currentURLs, newEntry) {
+				str := []string{revised.Data[url.Name], newEntry}
+				revised.Data[url.Name] = strings.Join(str, ",")
 			}
 		}
+
+		//2. scaling DOWN pods, remove the URLs of the replicas no longer present
+		DeleteURLsConfigMap(revised, crName, []corev1.EnvVar{url}, false)
 	}
 }
 
-// DeleteURLsConfigMap for deleting server peers to the monitoring console or scaling down
+// DeleteURLsConfigMap for deleting server peers to the monitoring console or scaling down. Entries are matched
+// exactly, so that the entries of custom resources of other namespaces sharing the configMap are left untouched
 func DeleteURLsConfigMap(revised *corev1.ConfigMap, crName string, newURLs []corev1.EnvVar, deleteCR bool) {
 	for _, url := range newURLs {
-		currentURLs := strings.Split(revised.Data[url.Name], ",")
-		sort.Strings(currentURLs)
-		for _, curr := range currentURLs {
-			//scale DOWN
-			if strings.Contains(curr, crName) && !strings.Contains(url.Value, curr) && !deleteCR {
-				revised.Data[url.Name] = strings.ReplaceAll(revised.Data[url.Name], curr, "")
-			} else if strings.Contains(curr, crName) && deleteCR {
-				revised.Data[url.Name] = strings.ReplaceAll(revised.Data[url.Name], url.Value, "")
-			}
-			//if deleting "SPLUNK_MULTISITE_MASTER" delete "SPLUNK_SITE"
-			if url.Name == "SPLUNK_SITE" && deleteCR {
+		//if deleting "SPLUNK_MULTISITE_MASTER" delete "SPLUNK_SITE"
+		if url.Name == "SPLUNK_SITE" {
+			if deleteCR {
 				delete(revised.Data, "SPLUNK_SITE")
 			}
-			if strings.HasPrefix(revised.Data[url.Name], ",") {
-				str := revised.Data[url.Name]
-				revised.Data[url.Name] = strings.TrimPrefix(str, ",")
-			}
-			if strings.HasSuffix(revised.Data[url.Name], ",") {
-				str := revised.Data[url.Name]
-				revised.Data[url.Name] = strings.TrimSuffix(str, ",")
-			}
-			if strings.Contains(revised.Data[url.Name], ",,") {
-				str := revised.Data[url.Name]
-				revised.Data[url.Name] = strings.ReplaceAll(str, ",,", ",")
+			continue
+		}
+		newInsURLs := strings.Split(url.Value, ",")
+		patterns := make(map[string]bool)
+		for _, newEntry := range newInsURLs {
+			patterns[getMonitoringConsoleURLPattern(newEntry)] = true
+		}
+		var remaining []string
+		for _, curr := range strings.Split(revised.Data[url.Name], ",") {
+			if curr == "" {
+				continue
 			}
-			if revised.Data[url.Name] == "" {
-				delete(revised.Data, url.Name)
+			if strings.Contains(curr, crName) {
+				inNewURLs := containsMonitoringConsoleURL(newInsURLs, curr)
+				if deleteCR && inNewURLs {
+					continue
+				}
+				//scale DOWN
+				if !deleteCR && !inNewURLs && patterns[getMonitoringConsoleURLPattern(curr)] {
+					continue
+				}
 			}
+			remaining = append(remaining, curr)
+		}
+		if len(remaining) == 0 {
+			delete(revised.Data, url.Name)
+		} else {
+			revised.Data[url.Name] = strings.Join(remaining, ",")
 		}
 	}
 }
@@ -378,9 +473,10 @@ func changeMonitoringConsoleAnnotations(ctx context.Context, client splcommon.Co
 	monitoringConsoleInstance := &enterpriseApi.MonitoringConsole{}
 	if len(cr.Spec.MonitoringConsoleRef.Name) > 0 {
 		// if the ClusterManager holds the MonitoringConsoleRef
+		mcNamespace, mcName := parseMonitoringConsoleRef(cr.GetNamespace(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef))
 		namespacedName := types.NamespacedName{
-			Namespace: cr.GetNamespace(),
-			Name:      cr.Spec.MonitoringConsoleRef.Name,
+			Namespace: mcNamespace,
+			Name:      mcName,
 		}
 		err := client.Get(ctx, namespacedName, monitoringConsoleInstance)
 		if err != nil {
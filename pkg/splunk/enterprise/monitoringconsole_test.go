@@ -344,6 +344,83 @@ func TestApplyMonitoringConsoleEnvConfigMap(t *testing.T) {
 
 }
 
+func TestApplyMonitoringConsoleEnvConfigMapCrossNamespace(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	ref := corev1.ObjectReference{Name: "mc", Namespace: "platform"}
+	if got := getMonitoringConsoleRef("tenant-a", ref); got != "platform/mc" {
+		t.Errorf("getMonitoringConsoleRef returned %s, want platform/mc", got)
+	}
+	if got := getMonitoringConsoleRef("platform", ref); got != "mc" {
+		t.Errorf("getMonitoringConsoleRef returned %s, want mc", got)
+	}
+
+	// standalones of the same name in two tenant namespaces and a cluster manager next to the monitoring console
+	tenantA := getStandaloneExtraEnv(&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "tenant-a"}}, 2)
+	tenantB := getStandaloneExtraEnv(&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "tenant-b"}}, 1)
+	cmEnv := []corev1.EnvVar{{Name: splcommon.ClusterManagerURL, Value: GetSplunkServiceName(SplunkClusterManager, "s1", false)}}
+	remoteCMEnv := []corev1.EnvVar{{Name: splcommon.ClusterManagerURL, Value: GetSplunkServiceName(SplunkClusterManager, "s1", false)}, {Name: "SPLUNK_SITE", Value: "site0"}}
+
+	apply := func(namespace string, mcRef string, env []corev1.EnvVar, add bool) {
+		_, err := ApplyMonitoringConsoleEnvConfigMap(ctx, c, namespace, "s1", mcRef, env, add)
+		if err != nil {
+			t.Errorf("ApplyMonitoringConsoleEnvConfigMap should not have returned error; err=%v", err)
+		}
+	}
+	getData := func() map[string]string {
+		var configMap corev1.ConfigMap
+		err := c.Get(ctx, types.NamespacedName{Namespace: "platform", Name: "splunk-mc-monitoring-console"}, &configMap)
+		if err != nil {
+			t.Errorf("monitoring console configMap should exist in the namespace of the monitoring console; err=%v", err)
+		}
+		return configMap.Data
+	}
+
+	apply("tenant-a", "platform/mc", tenantA, true)
+	apply("tenant-b", "platform/mc", tenantB, true)
+	apply("platform", "mc", cmEnv, true)
+	apply("tenant-b", "platform/mc", remoteCMEnv, true)
+	data := getData()
+	want := tenantA[0].Value + "," + tenantB[0].Value
+	if data["SPLUNK_STANDALONE_URL"] != want {
+		t.Errorf("SPLUNK_STANDALONE_URL is %s, want %s", data["SPLUNK_STANDALONE_URL"], want)
+	}
+	wantCM := "splunk-s1-cluster-manager-service,splunk-s1-cluster-manager-service.tenant-b.svc.cluster.local"
+	if data[splcommon.ClusterManagerURL] != wantCM || data["SPLUNK_SITE"] != "site0" {
+		t.Errorf("cluster manager URLs are %s, want %s", data[splcommon.ClusterManagerURL], wantCM)
+	}
+
+	// scaling down the standalone of tenant-a keeps the one of tenant-b
+	scaledDown := getStandaloneExtraEnv(&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "tenant-a"}}, 1)
+	apply("tenant-a", "platform/mc", scaledDown, true)
+	want = scaledDown[0].Value + "," + tenantB[0].Value
+	if data = getData(); data["SPLUNK_STANDALONE_URL"] != want {
+		t.Errorf("SPLUNK_STANDALONE_URL is %s, want %s", data["SPLUNK_STANDALONE_URL"], want)
+	}
+
+	// deleting the cluster manager of the monitoring console namespace keeps the one of tenant-b
+	apply("platform", "mc", cmEnv, false)
+	if data = getData(); data[splcommon.ClusterManagerURL] != "splunk-s1-cluster-manager-service.tenant-b.svc.cluster.local" {
+		t.Errorf("cluster manager URLs are %s", data[splcommon.ClusterManagerURL])
+	}
+
+	// deleting the custom resources of the tenants removes their entries
+	apply("tenant-a", "platform/mc", scaledDown, false)
+	apply("tenant-b", "platform/mc", tenantB, false)
+	apply("tenant-b", "platform/mc", remoteCMEnv, false)
+	if data = getData(); len(data) != 0 {
+		t.Errorf("monitoring console configMap should be empty, got %v", data)
+	}
+
+	// deleting a reference to a monitoring console without configMap in another namespace creates nothing
+	c = spltest.NewMockClient()
+	apply("tenant-a", "platform/mc", tenantA, false)
+	if len(c.Calls["Create"]) != 0 {
+		t.Errorf("no configMap should have been created in the namespace of the monitoring console")
+	}
+}
+
 func TestGetMonitoringConsoleStatefulSet(t *testing.T) {
 
 	ctx := context.TODO()
@@ -0,0 +1,422 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// monitoringConsoleAlertPrefix is the prefix of the saved searches of the platform alerts
+	monitoringConsoleAlertPrefix = "DMC Alert - "
+
+	// monitoringConsoleForwarderSearch is the saved search which builds the forwarder asset table
+	monitoringConsoleForwarderSearch = "DMC Forwarder - Build Asset Table"
+
+	// defaultForwarderBuildSchedule is the default cron schedule of the build of the forwarder asset table
+	defaultForwarderBuildSchedule = "3,18,33,48 * * * *"
+
+	// monitoringConsoleCustomGroupPrefix is the prefix of the distributed search groups of the custom groups
+	monitoringConsoleCustomGroupPrefix = "dmc_customgroup_"
+
+	// heavyForwardersGroup is the custom group of the Standalone custom resources acting as heavy forwarders
+	heavyForwardersGroup = "heavy_forwarders"
+
+	// monitoringConsoleConfigInterval is the interval of the updates of the custom groups, in seconds
+	monitoringConsoleConfigInterval = 300
+)
+
+// monitoringConsoleGroupKinds are the kinds of the custom resources which can be members of the custom groups
+var monitoringConsoleGroupKinds = []string{"Standalone", "IndexerCluster", "SearchHeadCluster", "ClusterManager", "LicenseManager"}
+
+// getMonitoringConsoleSplunkClient returns a SplunkClient for the monitoring console pod
+var getMonitoringConsoleSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole) (*splclient.SplunkClient, error) {
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return nil, err
+	}
+	podName := GetSplunkStatefulsetPodName(SplunkMonitoringConsole, cr.GetName(), 0)
+	fqdnName := splcommon.GetServiceFQDN(cr.GetNamespace(), fmt.Sprintf("%s.%s", podName, GetSplunkServiceName(SplunkMonitoringConsole, cr.GetName(), true)))
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, podName, cr.GetNamespace(), "password")
+	if err != nil {
+		return nil, err
+	}
+	return newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", adminPwd), nil
+}
+
+// getMonitoringConsoleAlertName returns the name of the saved search of a platform alert
+func getMonitoringConsoleAlertName(name string) string {
+	if strings.HasPrefix(name, monitoringConsoleAlertPrefix) {
+		return name
+	}
+	return monitoringConsoleAlertPrefix + name
+}
+
+// getMonitoringConsoleGroups returns the custom groups of a MonitoringConsoleSpec, including the heavy forwarders
+func getMonitoringConsoleGroups(spec *enterpriseApi.MonitoringConsoleSpec) []enterpriseApi.MonitoringConsoleGroup {
+	groups := append([]enterpriseApi.MonitoringConsoleGroup{}, spec.Groups...)
+	if spec.ForwarderMonitoring.Enabled && spec.ForwarderMonitoring.HeavyForwarderSelector != nil {
+		groups = append(groups, enterpriseApi.MonitoringConsoleGroup{
+			Name:     heavyForwardersGroup,
+			Selector: *spec.ForwarderMonitoring.HeavyForwarderSelector,
+			Kinds:    []string{"Standalone"},
+		})
+	}
+	return groups
+}
+
+// isMonitoringConsoleConfigured returns true if the monitoring console has platform alerts, forwarder monitoring or
+// custom groups to reconcile
+func isMonitoringConsoleConfigured(cr *enterpriseApi.MonitoringConsole) bool {
+	return len(cr.Spec.Alerts) > 0 || cr.Spec.ForwarderMonitoring.Enabled || len(cr.Spec.Groups) > 0 ||
+		len(cr.Status.Alerts) > 0 || cr.Status.ForwarderMonitoring || len(cr.Status.Groups) > 0
+}
+
+// validateMonitoringConsoleConfig checks the platform alerts, forwarder monitoring and custom groups of a
+// MonitoringConsoleSpec
+func validateMonitoringConsoleConfig(spec *enterpriseApi.MonitoringConsoleSpec) error {
+	if spec.ForwarderMonitoring.Enabled && spec.ForwarderMonitoring.BuildSchedule == "" {
+		spec.ForwarderMonitoring.BuildSchedule = defaultForwarderBuildSchedule
+	}
+	for _, group := range getMonitoringConsoleGroups(spec) {
+		_, err := metav1.LabelSelectorAsSelector(&group.Selector)
+		if err != nil {
+			return fmt.Errorf("invalid selector of the custom group %s: %w", group.Name, err)
+		}
+		for _, kind := range group.Kinds {
+			if !isStringInList(kind, monitoringConsoleGroupKinds) {
+				return fmt.Errorf("unsupported kind %s of the custom group %s, must be one of %s", kind, group.Name, strings.Join(monitoringConsoleGroupKinds, ", "))
+			}
+		}
+	}
+	for _, group := range spec.Groups {
+		if group.Name == heavyForwardersGroup && spec.ForwarderMonitoring.HeavyForwarderSelector != nil {
+			return fmt.Errorf("custom group %s is reserved for the heavy forwarders", heavyForwardersGroup)
+		}
+	}
+	return nil
+}
+
+// getSelectedCustomResources returns the custom resources of a kind selected by a label selector, in the namespace of
+// the monitoring console and in the other namespaces, where the custom resources must reference the monitoring console
+func getSelectedCustomResources(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole, kind string, selector labels.Selector) ([]types.NamespacedName, error) {
+	var list client.ObjectList
+	switch kind {
+	case "Standalone":
+		list = &enterpriseApi.StandaloneList{}
+	case "IndexerCluster":
+		list = &enterpriseApi.IndexerClusterList{}
+	case "SearchHeadCluster":
+		list = &enterpriseApi.SearchHeadClusterList{}
+	case "ClusterManager":
+		list = &enterpriseApi.ClusterManagerList{}
+	case "LicenseManager":
+		list = &enterpriseApi.LicenseManagerList{}
+	default:
+		return nil, fmt.Errorf("unsupported kind %s", kind)
+	}
+	err := c.List(ctx, list, client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, err
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return nil, err
+	}
+	var selected []types.NamespacedName
+	for _, item := range items {
+		object, ok := item.(client.Object)
+		if !ok {
+			continue
+		}
+		if object.GetNamespace() != cr.GetNamespace() {
+			monitoringConsoleRef, err := getCustomResourceMonitoringConsoleRef(ctx, c, object)
+			if err != nil {
+				return nil, err
+			}
+			mcNamespace, mcName := parseMonitoringConsoleRef(object.GetNamespace(), monitoringConsoleRef)
+			if mcNamespace != cr.GetNamespace() || mcName != cr.GetName() {
+				continue
+			}
+		}
+		selected = append(selected, types.NamespacedName{Namespace: object.GetNamespace(), Name: object.GetName()})
+	}
+	return selected, nil
+}
+
+// getCustomResourceMonitoringConsoleRef returns the monitoring console reference of a custom resource, which is the
+// reference of its cluster manager for an indexer cluster
+func getCustomResourceMonitoringConsoleRef(ctx context.Context, c splcommon.ControllerClient, object client.Object) (string, error) {
+	switch cr := object.(type) {
+	case *enterpriseApi.IndexerCluster:
+		return RetrieveCMSpec(ctx, c, cr)
+	case *enterpriseApi.Standalone:
+		return getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), nil
+	case *enterpriseApi.SearchHeadCluster:
+		return getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), nil
+	case *enterpriseApi.ClusterManager:
+		return getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), nil
+	case *enterpriseApi.LicenseManager:
+		return getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), nil
+	}
+	return "", nil
+}
+
+// getDistributedPeerPod returns the namespace and the name of the pod of a distributed search peer of a monitoring
+// console. The peers of other namespaces are published with the fully qualified domain name of their pod, i.e.
+// <pod>.<service>.<namespace>.svc.cluster.local
+func getDistributedPeerPod(mcNamespace string, peerURI string) (string, string) {
+	host := strings.SplitN(peerURI, ":", 2)[0]
+	hostLabels := strings.Split(host, ".")
+	if len(hostLabels) >= 3 {
+		return hostLabels[2], hostLabels[0]
+	}
+	return mcNamespace, hostLabels[0]
+}
+
+// getMonitoringConsoleGroupMembers returns the distributed search peers which are instances of the custom resources
+// selected by a custom group, in all the namespaces monitored by the monitoring console
+func getMonitoringConsoleGroupMembers(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole, group *enterpriseApi.MonitoringConsoleGroup, peers map[string]splclient.MCDistributedPeers) ([]string, error) {
+	selector, err := metav1.LabelSelectorAsSelector(&group.Selector)
+	if err != nil {
+		return nil, err
+	}
+	kinds := group.Kinds
+	if len(kinds) == 0 {
+		kinds = monitoringConsoleGroupKinds
+	}
+
+	// pod prefixes of the selected custom resources, per namespace
+	prefixes := map[string][]string{}
+	for _, kind := range kinds {
+		selected, err := getSelectedCustomResources(ctx, c, cr, kind, selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, namespacedName := range selected {
+			crPrefixes, err := getCustomResourcePodPrefixes(ctx, c, namespacedName.Namespace, kind, namespacedName.Name)
+			if err != nil {
+				return nil, err
+			}
+			prefixes[namespacedName.Namespace] = append(prefixes[namespacedName.Namespace], crPrefixes...)
+		}
+	}
+
+	members := []string{}
+	for name := range peers {
+		namespace, podName := getDistributedPeerPod(cr.GetNamespace(), name)
+		if isCustomResourcePod(podName, prefixes[namespace]) {
+			members = append(members, name)
+		}
+	}
+	sort.Strings(members)
+	return members, nil
+}
+
+// applyMonitoringConsoleAlerts enables the platform alerts of the spec with their thresholds, and disables the
+// platform alerts removed from the spec
+func applyMonitoringConsoleAlerts(ctx context.Context, cr *enterpriseApi.MonitoringConsole, mc *splclient.SplunkClient, eventPublisher *K8EventPublisher) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyMonitoringConsoleAlerts").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	var enabled []string
+	for _, alert := range cr.Spec.Alerts {
+		name := getMonitoringConsoleAlertName(alert.Name)
+		enabled = append(enabled, name)
+		savedSearch, err := mc.GetMonitoringConsoleSavedSearch(name)
+		if err != nil {
+			return fmt.Errorf("unable to get platform alert %s: %w", alert.Name, err)
+		}
+		changed := savedSearch.Disabled
+		for key, value := range alert.Thresholds {
+			if savedSearch.Args[key] != value {
+				changed = true
+			}
+		}
+		if !changed {
+			continue
+		}
+		scopedLog.Info("Enabling platform alert", "alert", name, "thresholds", alert.Thresholds)
+		err = mc.UpdateMonitoringConsoleSavedSearch(splclient.MCSavedSearch{Name: name, Args: alert.Thresholds})
+		if err != nil {
+			return fmt.Errorf("unable to enable platform alert %s: %w", alert.Name, err)
+		}
+		eventPublisher.Normal(ctx, "PlatformAlertEnabled", fmt.Sprintf("platform alert %s enabled", alert.Name))
+	}
+
+	// platform alerts removed from the spec
+	for _, name := range cr.Status.Alerts {
+		if isStringInList(name, enabled) {
+			continue
+		}
+		scopedLog.Info("Disabling platform alert", "alert", name)
+		err := mc.UpdateMonitoringConsoleSavedSearch(splclient.MCSavedSearch{Name: name, Disabled: true})
+		if err != nil {
+			return fmt.Errorf("unable to disable platform alert %s: %w", name, err)
+		}
+		eventPublisher.Normal(ctx, "PlatformAlertDisabled", fmt.Sprintf("platform alert %s disabled", strings.TrimPrefix(name, monitoringConsoleAlertPrefix)))
+	}
+	cr.Status.Alerts = enabled
+	return nil
+}
+
+// applyMonitoringConsoleForwarderMonitoring enables or disables the build of the forwarder asset table
+func applyMonitoringConsoleForwarderMonitoring(ctx context.Context, cr *enterpriseApi.MonitoringConsole, mc *splclient.SplunkClient, eventPublisher *K8EventPublisher) error {
+	spec := &cr.Spec.ForwarderMonitoring
+	if !spec.Enabled {
+		if cr.Status.ForwarderMonitoring {
+			err := mc.UpdateMonitoringConsoleSavedSearch(splclient.MCSavedSearch{Name: monitoringConsoleForwarderSearch, Disabled: true})
+			if err != nil {
+				return fmt.Errorf("unable to disable the forwarder monitoring: %w", err)
+			}
+			eventPublisher.Normal(ctx, "ForwarderMonitoringDisabled", "forwarder monitoring disabled")
+			cr.Status.ForwarderMonitoring = false
+		}
+		return nil
+	}
+
+	savedSearch, err := mc.GetMonitoringConsoleSavedSearch(monitoringConsoleForwarderSearch)
+	if err != nil {
+		return fmt.Errorf("unable to get the forwarder monitoring: %w", err)
+	}
+	if savedSearch.Disabled || savedSearch.CronSchedule != spec.BuildSchedule {
+		err = mc.UpdateMonitoringConsoleSavedSearch(splclient.MCSavedSearch{Name: monitoringConsoleForwarderSearch, CronSchedule: spec.BuildSchedule})
+		if err != nil {
+			return fmt.Errorf("unable to enable the forwarder monitoring: %w", err)
+		}
+		eventPublisher.Normal(ctx, "ForwarderMonitoringEnabled", fmt.Sprintf("forwarder monitoring enabled with schedule %s", spec.BuildSchedule))
+	}
+	cr.Status.ForwarderMonitoring = true
+	return nil
+}
+
+// applyMonitoringConsoleGroups creates and updates the distributed search groups of the custom groups, and deletes
+// the groups removed from the spec. Returns true if a group changed
+func applyMonitoringConsoleGroups(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole, mc *splclient.SplunkClient, eventPublisher *K8EventPublisher) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyMonitoringConsoleGroups").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	groups := getMonitoringConsoleGroups(&cr.Spec)
+	if len(groups) == 0 && len(cr.Status.Groups) == 0 {
+		return false, nil
+	}
+	peers, err := mc.GetMonitoringConsoleDistributedPeers()
+	if err != nil {
+		return false, err
+	}
+	current, err := mc.GetDMCGroups()
+	if err != nil {
+		return false, err
+	}
+
+	changed := false
+	statuses := []enterpriseApi.MonitoringConsoleGroupStatus{}
+	names := map[string]bool{}
+	for i := range groups {
+		group := &groups[i]
+		names[group.Name] = true
+		members, err := getMonitoringConsoleGroupMembers(ctx, c, cr, group, peers)
+		if err != nil {
+			return false, err
+		}
+		statuses = append(statuses, enterpriseApi.MonitoringConsoleGroupStatus{Name: group.Name, Members: members})
+
+		dmcGroupName := monitoringConsoleCustomGroupPrefix + group.Name
+		existing, exists := current[dmcGroupName]
+		switch {
+		case exists && isSameStringSet(existing, members):
+			continue
+		case len(members) == 0:
+			// distributed search groups can not be empty
+			if !exists {
+				continue
+			}
+			err = mc.DeleteDMCGroup(dmcGroupName)
+		case exists:
+			err = mc.UpdateDMCGroups(dmcGroupName, url.Values{"member": members, "default": {"false"}}.Encode())
+		default:
+			err = mc.CreateDMCGroup(dmcGroupName, members)
+		}
+		if err != nil {
+			return false, fmt.Errorf("unable to apply custom group %s: %w", group.Name, err)
+		}
+		scopedLog.Info("Applied custom group", "group", group.Name, "members", members)
+		eventPublisher.Normal(ctx, "CustomGroupApplied", fmt.Sprintf("custom group %s applied with %d members", group.Name, len(members)))
+		changed = true
+	}
+
+	// custom groups removed from the spec
+	for _, group := range cr.Status.Groups {
+		dmcGroupName := monitoringConsoleCustomGroupPrefix + group.Name
+		if _, exists := current[dmcGroupName]; names[group.Name] || !exists {
+			continue
+		}
+		err = mc.DeleteDMCGroup(dmcGroupName)
+		if err != nil {
+			return false, fmt.Errorf("unable to delete custom group %s: %w", group.Name, err)
+		}
+		scopedLog.Info("Deleted custom group", "group", group.Name)
+		eventPublisher.Normal(ctx, "CustomGroupDeleted", fmt.Sprintf("custom group %s deleted", group.Name))
+		changed = true
+	}
+	cr.Status.Groups = statuses
+	return changed, nil
+}
+
+// applyMonitoringConsoleConfig enables the platform alerts and the forwarder monitoring of the spec on the
+// monitoring console, and applies its custom groups. The asset table is rebuilt when a custom group changes
+func applyMonitoringConsoleConfig(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole) error {
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+	mc, err := getMonitoringConsoleSplunkClient(ctx, c, cr)
+	if err != nil {
+		return err
+	}
+
+	err = applyMonitoringConsoleAlerts(ctx, cr, mc, eventPublisher)
+	if err != nil {
+		return err
+	}
+	err = applyMonitoringConsoleForwarderMonitoring(ctx, cr, mc, eventPublisher)
+	if err != nil {
+		return err
+	}
+	changed, err := applyMonitoringConsoleGroups(ctx, c, cr, mc, eventPublisher)
+	if err != nil || !changed {
+		return err
+	}
+
+	// custom groups are shown once the asset table is rebuilt
+	assetTable, err := mc.GetMonitoringconsoleAssetTable()
+	if err != nil {
+		return err
+	}
+	return mc.PostMonitoringConsoleAssetTable(assetTable)
+}
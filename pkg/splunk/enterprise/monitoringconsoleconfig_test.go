@@ -0,0 +1,317 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+// fakeMonitoringConsole is a fake REST API of a monitoring console
+type fakeMonitoringConsole struct {
+	savedSearches map[string]map[string]interface{}
+	peers         map[string]splclient.MCDistributedPeers
+	groups        map[string][]string
+	requests      []string
+}
+
+func newFakeMonitoringConsole() *fakeMonitoringConsole {
+	return &fakeMonitoringConsole{
+		savedSearches: map[string]map[string]interface{}{
+			"DMC Alert - Near Critical Disk Usage": {"disabled": true, "args.disk_usage_threshold": "80"},
+			"DMC Alert - Missing Forwarders":       {"disabled": true},
+			monitoringConsoleForwarderSearch:       {"disabled": true, "cron_schedule": "3,18,33,48 * * * *"},
+			"DMC Asset - Build Full":               {"dispatch.auto_cancel": "0", "dispatch.buckets": 300},
+		},
+		peers: map[string]splclient.MCDistributedPeers{
+			"splunk-hf1-standalone-0.splunk-hf1-standalone-headless:8089":           {PeerName: "splunk-hf1-standalone-0"},
+			"splunk-s2-standalone-0.splunk-s2-standalone-headless:8089":             {PeerName: "splunk-s2-standalone-0"},
+			"splunk-idxc1-site1-indexer-0.splunk-idxc1-site1-indexer-headless:8089": {PeerName: "splunk-idxc1-site1-indexer-0"},
+		},
+		groups: map[string][]string{
+			"dmc_group_indexer": {"localhost:localhost"},
+		},
+	}
+}
+
+// Do serves the requests of the monitoring console endpoints
+func (f *fakeMonitoringConsole) Do(request *http.Request) (*http.Response, error) {
+	path, _ := url.PathUnescape(request.URL.EscapedPath())
+	f.requests = append(f.requests, fmt.Sprintf("%s %s", request.Method, path))
+	values := url.Values{}
+	if request.Body != nil {
+		body, _ := io.ReadAll(request.Body)
+		values, _ = url.ParseQuery(strings.TrimPrefix(string(body), "&"))
+	}
+
+	type entry struct {
+		Name    string      `json:"name"`
+		Content interface{} `json:"content"`
+	}
+	var entries []entry
+	status := 200
+	savedSearchPrefix := "/servicesNS/nobody/splunk_monitoring_console/saved/searches/"
+	switch {
+	case request.Method == "GET" && strings.HasPrefix(path, savedSearchPrefix):
+		name := strings.TrimPrefix(path, savedSearchPrefix)
+		content, ok := f.savedSearches[name]
+		if !ok {
+			status = 404
+		}
+		entries = append(entries, entry{Name: name, Content: content})
+	case request.Method == "POST" && strings.HasSuffix(path, "/dispatch"):
+		status = 201
+	case request.Method == "POST" && strings.HasPrefix(path, savedSearchPrefix):
+		content := f.savedSearches[strings.TrimPrefix(path, savedSearchPrefix)]
+		for key := range values {
+			content[key] = values.Get(key)
+		}
+		content["disabled"] = values.Get("disabled") == "1"
+	case request.Method == "GET" && path == "/services/search/distributed/peers":
+		for name, peer := range f.peers {
+			entries = append(entries, entry{Name: name, Content: peer})
+		}
+	case request.Method == "GET" && path == "/services/search/distributed/groups":
+		for name, members := range f.groups {
+			entries = append(entries, entry{Name: name, Content: map[string][]string{"member": members}})
+		}
+	case request.Method == "POST" && path == "/services/search/distributed/groups":
+		f.groups[values.Get("name")] = values["member"]
+		status = 201
+	case request.Method == "POST" && strings.HasSuffix(path, "/edit"):
+		f.groups[strings.TrimSuffix(strings.TrimPrefix(path, "/services/search/distributed/groups/"), "/edit")] = values["member"]
+	case request.Method == "DELETE" && strings.HasPrefix(path, "/services/search/distributed/groups/"):
+		delete(f.groups, strings.TrimPrefix(path, "/services/search/distributed/groups/"))
+	default:
+		status = 404
+	}
+	body, _ := json.Marshal(map[string]interface{}{"entry": entries})
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(string(body)))}, nil
+}
+
+// popRequests returns the requests which changed the monitoring console, and resets the requests
+func (f *fakeMonitoringConsole) popRequests() []string {
+	var requests []string
+	for _, request := range f.requests {
+		if !strings.HasPrefix(request, "GET ") {
+			requests = append(requests, request)
+		}
+	}
+	f.requests = nil
+	return requests
+}
+
+func TestValidateMonitoringConsoleConfig(t *testing.T) {
+	spec := enterpriseApi.MonitoringConsoleSpec{
+		ForwarderMonitoring: enterpriseApi.MonitoringConsoleForwarderMonitoring{Enabled: true},
+	}
+	if err := validateMonitoringConsoleConfig(&spec); err != nil || spec.ForwarderMonitoring.BuildSchedule != defaultForwarderBuildSchedule {
+		t.Errorf("validateMonitoringConsoleConfig() should default the build schedule. err: %v", err)
+	}
+
+	spec.Groups = []enterpriseApi.MonitoringConsoleGroup{{Name: "prod", Kinds: []string{"Forwarder"}}}
+	if validateMonitoringConsoleConfig(&spec) == nil {
+		t.Errorf("unsupported kind should be rejected")
+	}
+	spec.Groups[0].Kinds = nil
+	spec.Groups[0].Selector.MatchExpressions = []metav1.LabelSelectorRequirement{{Key: "env", Operator: "Unknown"}}
+	if validateMonitoringConsoleConfig(&spec) == nil {
+		t.Errorf("invalid selector should be rejected")
+	}
+	spec.Groups[0] = enterpriseApi.MonitoringConsoleGroup{Name: heavyForwardersGroup}
+	spec.ForwarderMonitoring.HeavyForwarderSelector = &metav1.LabelSelector{}
+	if validateMonitoringConsoleConfig(&spec) == nil {
+		t.Errorf("heavy forwarders group should be reserved")
+	}
+}
+
+func TestApplyMonitoringConsoleConfig(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+	mc := newFakeMonitoringConsole()
+	savedGetMonitoringConsoleSplunkClient := getMonitoringConsoleSplunkClient
+	defer func() { getMonitoringConsoleSplunkClient = savedGetMonitoringConsoleSplunkClient }()
+	getMonitoringConsoleSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.MonitoringConsole) (*splclient.SplunkClient, error) {
+		sc := splclient.NewSplunkClient("https://splunk-mc1-monitoring-console-0:8089", "admin", "p@ssw0rd")
+		sc.Client = mc
+		return sc, nil
+	}
+
+	for _, object := range []splcommon.MetaObject{
+		&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "hf1", Namespace: "test", Labels: map[string]string{"role": "heavy-forwarder"}}},
+		&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "s2", Namespace: "test", Labels: map[string]string{"env": "prod"}}},
+		&enterpriseApi.IndexerCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "idxc1", Namespace: "test", Labels: map[string]string{"env": "prod"}},
+			Spec:       enterpriseApi.IndexerClusterSpec{Sites: []enterpriseApi.IndexerClusterSiteSpec{{Name: "site1", Replicas: 1}}},
+		},
+	} {
+		if err := c.Create(ctx, object); err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	cr := enterpriseApi.MonitoringConsole{
+		ObjectMeta: metav1.ObjectMeta{Name: "mc1", Namespace: "test"},
+		Spec: enterpriseApi.MonitoringConsoleSpec{
+			Alerts: []enterpriseApi.MonitoringConsoleAlert{
+				{Name: "Near Critical Disk Usage", Thresholds: map[string]string{"disk_usage_threshold": "90"}},
+				{Name: "DMC Alert - Missing Forwarders"},
+			},
+			ForwarderMonitoring: enterpriseApi.MonitoringConsoleForwarderMonitoring{
+				Enabled:                true,
+				HeavyForwarderSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"role": "heavy-forwarder"}},
+			},
+			Groups: []enterpriseApi.MonitoringConsoleGroup{
+				{Name: "prod", Selector: metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+			},
+		},
+	}
+	if err := validateMonitoringConsoleConfig(&cr.Spec); err != nil {
+		t.Errorf("validateMonitoringConsoleConfig() returned error: %v", err)
+	}
+	if !isMonitoringConsoleConfigured(&cr) {
+		t.Errorf("monitoring console should be configured")
+	}
+
+	// alerts, forwarder monitoring and groups are applied
+	err := applyMonitoringConsoleConfig(ctx, c, &cr)
+	if err != nil {
+		t.Errorf("applyMonitoringConsoleConfig() returned error: %v", err)
+	}
+	if got := mc.popRequests(); len(got) != 6 || !strings.HasSuffix(got[5], "/dispatch") {
+		t.Errorf("unexpected requests %v", got)
+	}
+	if alert := mc.savedSearches["DMC Alert - Near Critical Disk Usage"]; alert["disabled"] != false || alert["args.disk_usage_threshold"] != "90" {
+		t.Errorf("platform alert should be enabled with its threshold, got %v", alert)
+	}
+	if mc.savedSearches[monitoringConsoleForwarderSearch]["disabled"] != false || !cr.Status.ForwarderMonitoring {
+		t.Errorf("forwarder monitoring should be enabled")
+	}
+	if hf := mc.groups["dmc_customgroup_heavy_forwarders"]; len(hf) != 1 || !strings.HasPrefix(hf[0], "splunk-hf1-standalone-0") {
+		t.Errorf("unexpected heavy forwarders group %v", hf)
+	}
+	if prod := mc.groups["dmc_customgroup_prod"]; len(prod) != 2 {
+		t.Errorf("unexpected prod group %v", prod)
+	}
+	if len(cr.Status.Alerts) != 2 || len(cr.Status.Groups) != 2 || cr.Status.Groups[0].Name != "prod" || len(cr.Status.Groups[1].Members) != 1 {
+		t.Errorf("unexpected status %v", cr.Status)
+	}
+
+	// nothing changes
+	err = applyMonitoringConsoleConfig(ctx, c, &cr)
+	if got := mc.popRequests(); err != nil || len(got) != 0 {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+
+	// group members follow the pods
+	mc.peers["splunk-hf1-standalone-1.splunk-hf1-standalone-headless:8089"] = splclient.MCDistributedPeers{PeerName: "splunk-hf1-standalone-1"}
+	err = applyMonitoringConsoleConfig(ctx, c, &cr)
+	if got := mc.popRequests(); err != nil || len(got) != 2 || got[0] != "POST /services/search/distributed/groups/dmc_customgroup_heavy_forwarders/edit" {
+		t.Errorf("unexpected requests %v, err: %v", got, err)
+	}
+
+	// removed alerts, forwarder monitoring and groups are disabled
+	cr.Spec.Alerts = cr.Spec.Alerts[:1]
+	cr.Spec.ForwarderMonitoring = enterpriseApi.MonitoringConsoleForwarderMonitoring{}
+	cr.Spec.Groups = nil
+	err = applyMonitoringConsoleConfig(ctx, c, &cr)
+	if err != nil {
+		t.Errorf("applyMonitoringConsoleConfig() returned error: %v", err)
+	}
+	if mc.savedSearches["DMC Alert - Missing Forwarders"]["disabled"] != true || mc.savedSearches[monitoringConsoleForwarderSearch]["disabled"] != true {
+		t.Errorf("platform alert and forwarder monitoring should be disabled")
+	}
+	if len(mc.groups) != 1 || len(cr.Status.Groups) != 0 || len(cr.Status.Alerts) != 1 || cr.Status.ForwarderMonitoring {
+		t.Errorf("unexpected groups %v and status %v", mc.groups, cr.Status)
+	}
+
+	// unknown platform alert
+	cr.Spec.Alerts = []enterpriseApi.MonitoringConsoleAlert{{Name: "Unknown"}}
+	if applyMonitoringConsoleConfig(ctx, c, &cr) == nil {
+		t.Errorf("applyMonitoringConsoleConfig() should fail with an unknown platform alert")
+	}
+}
+
+func TestGetMonitoringConsoleGroupMembers(t *testing.T) {
+	ctx := context.TODO()
+	utilruntime.Must(enterpriseApi.AddToScheme(clientgoscheme.Scheme))
+	c := fake.NewClientBuilder().Build()
+
+	prod := map[string]string{"env": "prod"}
+	for _, object := range []splcommon.MetaObject{
+		&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "test", Labels: prod}},
+		&enterpriseApi.Standalone{
+			ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "tenant-a", Labels: prod},
+			Spec: enterpriseApi.StandaloneSpec{CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				MonitoringConsoleRef: corev1.ObjectReference{Name: "mc1", Namespace: "test"},
+			}},
+		},
+		&enterpriseApi.Standalone{ObjectMeta: metav1.ObjectMeta{Name: "s1", Namespace: "tenant-b", Labels: prod}},
+		&enterpriseApi.ClusterManager{
+			ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "tenant-a"},
+			Spec: enterpriseApi.ClusterManagerSpec{CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				MonitoringConsoleRef: corev1.ObjectReference{Name: "mc1", Namespace: "test"},
+			}},
+		},
+		&enterpriseApi.IndexerCluster{
+			ObjectMeta: metav1.ObjectMeta{Name: "idxc1", Namespace: "tenant-a", Labels: prod},
+			Spec: enterpriseApi.IndexerClusterSpec{CommonSplunkSpec: enterpriseApi.CommonSplunkSpec{
+				ClusterManagerRef: corev1.ObjectReference{Name: "cm1"},
+			}},
+		},
+	} {
+		if err := c.Create(ctx, object); err != nil {
+			t.Fatalf("Create() returned error: %v", err)
+		}
+	}
+
+	// peers of the custom resources of the same name are told apart by their namespace
+	peers := map[string]splclient.MCDistributedPeers{
+		"splunk-s1-standalone-0.splunk-s1-standalone-headless:8089":                                        {PeerName: "splunk-s1-standalone-0"},
+		"splunk-s1-standalone-0.splunk-s1-standalone-headless.tenant-a.svc.cluster.local:8089":             {PeerName: "splunk-s1-standalone-0"},
+		"splunk-s1-standalone-0.splunk-s1-standalone-headless.tenant-b.svc.cluster.local:8089":             {PeerName: "splunk-s1-standalone-0"},
+		"splunk-idxc1-indexer-0.splunk-idxc1-indexer-headless.tenant-a.svc.cluster.local:8089":             {PeerName: "splunk-idxc1-indexer-0"},
+		"splunk-cm1-cluster-manager-0.splunk-cm1-cluster-manager-headless.tenant-a.svc.cluster.local:8089": {PeerName: "splunk-cm1-cluster-manager-0"},
+	}
+	cr := enterpriseApi.MonitoringConsole{ObjectMeta: metav1.ObjectMeta{Name: "mc1", Namespace: "test"}}
+	group := enterpriseApi.MonitoringConsoleGroup{Name: "prod", Selector: metav1.LabelSelector{MatchLabels: prod}}
+	members, err := getMonitoringConsoleGroupMembers(ctx, c, &cr, &group, peers)
+	want := []string{
+		"splunk-idxc1-indexer-0.splunk-idxc1-indexer-headless.tenant-a.svc.cluster.local:8089",
+		"splunk-s1-standalone-0.splunk-s1-standalone-headless.tenant-a.svc.cluster.local:8089",
+		"splunk-s1-standalone-0.splunk-s1-standalone-headless:8089",
+	}
+	if err != nil || !reflect.DeepEqual(members, want) {
+		t.Errorf("getMonitoringConsoleGroupMembers() returned %v, %v; want %v", members, err, want)
+	}
+}
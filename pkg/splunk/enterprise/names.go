@@ -109,8 +109,23 @@ const (
 
 	shcBundlePushStatusCheckFile = "/operator-staging/appframework/.shcluster_bundle_status.txt"
 
+	// checks the syntax of the configuration files of the deployer bundle
+	shcValidateBundleCmdStr = "/opt/splunk/bin/splunk btool check --dir=/opt/splunk/etc/shcluster/apps"
+
+	// max. time in seconds to wait for the cluster manager to validate the manager apps bundle
+	bundleValidationTimeout = 600
+
 	applyIdxcBundleCmdStr = "/opt/splunk/bin/splunk apply cluster-bundle -auth admin:`cat /mnt/splunk-secrets/password` --skip-validation --answer-yes"
 
+	// namespace of the cluster manager whose idxc_secret is shared with the namespace scoped secret
+	idxcSecretSourceAnnotation = "enterprise.splunk.com/idxc-secret-source"
+
+	// name of the multisite indexer cluster of the peers of its sites, selected by the services of the indexer cluster
+	indexerClusterSiteLabel = "enterprise.splunk.com/indexer-cluster"
+
+	// takes an indexer cluster peer offline in the background, as it waits for the primaries to be reassigned
+	offlinePeerCmdStr = "/opt/splunk/bin/splunk offline -auth admin:`cat /mnt/splunk-secrets/password` &> /dev/null &"
+
 	idxcShowClusterBundleStatusStr = "/opt/splunk/bin/splunk show cluster-bundle-status -auth admin:`cat /mnt/splunk-secrets/password`"
 
 	idxcBundleAlreadyPresentStr = "No new bundle will be pushed. The cluster manager and peers already have this bundle"
@@ -122,6 +137,19 @@ const (
 	// command to append FS permissions to +rw-rw-
 	cmdSetFilePermissionsToRW = "chmod +660 -R %s"
 
+	// directory of the KV store backup archives on the Splunk pods
+	kvStoreBackupDir = "/opt/splunk/var/lib/splunk/kvstorebackup"
+
+	// archives the etc customizations backed up with the KV store. etc/system/local and etc/auth are left out, as
+	// they are managed by the operator
+	createEtcBackupCmdStr = "mkdir -p %s && tar -czf %s -C /opt/splunk etc/apps etc/users"
+
+	// extracts the etc customizations of a backup over the etc of the Splunk pod
+	extractEtcBackupCmdStr = "tar -xzf %s -C /opt/splunk && rm -f %s"
+
+	// removes the backup archives from the Splunk pod
+	removeBackupFilesCmdStr = "rm -f %s"
+
 	// command for init container on a standalone
 	commandForStandaloneSmartstore = "mkdir -p /opt/splk/etc/apps/splunk-operator/local && ln -sfn  /mnt/splunk-operator/local/indexes.conf /opt/splk/etc/apps/splunk-operator/local/indexes.conf && ln -sfn  /mnt/splunk-operator/local/server.conf /opt/splk/etc/apps/splunk-operator/local/server.conf"
 
@@ -53,7 +53,7 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 
 	var err error
 	// Initialize phase
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 
 	// Update the CR Status
 	defer updateCRStatus(ctx, client, cr, &err)
@@ -111,8 +111,9 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
+		deleteSearchHeadClusterMetrics(cr)
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, getSearchHeadEnv(cr), false)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), getSearchHeadEnv(cr), false)
 			if err != nil {
 				return result, err
 			}
@@ -171,7 +172,19 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 		return result, err
 	}
 
-	deployerManager := splctrl.DefaultStatefulSetPodManager{}
+	// revert the version upgrade when the pods are not ready within the health deadline
+	continueReconcile, err = applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkSearchHead, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// hold the version upgrade until the upgrade policy allows it
+	continueReconcile, err = applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkSearchHead, lastPhase, &cr.Status.Upgrade)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	deployerManager := splctrl.DefaultStatefulSetPodManager{VolumeResize: &cr.Status.DeployerVolumeResize}
 	phase, err := deployerManager.Update(ctx, client, statefulSet, 1)
 	if err != nil {
 		return result, err
@@ -190,7 +203,25 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 		return result, err
 	}
 
-	mgr := newSearchHeadClusterPodManager(client, scopedLog, cr, namespaceScopedSecret, splclient.NewSplunkClient)
+	// provision the volumes of a new search head cluster from a snapshot set
+	snapshotTarget := &volumeSnapshotTarget{
+		cr:           cr,
+		commonSpec:   &cr.Spec.CommonSplunkSpec,
+		spec:         &cr.Spec.Snapshots,
+		status:       &cr.Status.Snapshots,
+		instanceType: SplunkSearchHead,
+		replicas:     cr.Spec.Replicas,
+	}
+	err = applyVolumeSnapshotRestore(ctx, client, snapshotTarget, statefulSet)
+	if err != nil {
+		return result, err
+	}
+
+	newSplunkClient, err := getSplunkClientFunc(ctx, client, cr, &cr.Spec.CommonSplunkSpec)
+	if err != nil {
+		return result, err
+	}
+	mgr := newSearchHeadClusterPodManager(client, scopedLog, cr, namespaceScopedSecret, newSplunkClient)
 	phase, err = mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
 	if err != nil {
 		return result, err
@@ -211,7 +242,7 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 			scopedLog.Error(err, "Error in deleting automated monitoring console resource")
 		}
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, getSearchHeadEnv(cr), true)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), getSearchHeadEnv(cr), true)
 			if err != nil {
 				return result, err
 			}
@@ -238,6 +269,13 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 		if finalResult != nil {
 			result = *finalResult
 		}
+
+		// take the scheduled snapshot sets of the volumes
+		requeueAfter, err := applyVolumeSnapshots(ctx, client, snapshotTarget, time.Now())
+		if err != nil {
+			return result, err
+		}
+		setVolumeSnapshotRequeueTime(&result, requeueAfter)
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
@@ -248,6 +286,9 @@ func ApplySearchHeadCluster(ctx context.Context, client splcommon.ControllerClie
 	return result, nil
 }
 
+// defaultSearchHeadDrainTimeout is the time allowed for the searches of a member to drain before it is restarted, in seconds
+const defaultSearchHeadDrainTimeout = 180
+
 // searchHeadClusterPodManager is used to manage the pods within a search head cluster
 type searchHeadClusterPodManager struct {
 	c               splcommon.ControllerClient
@@ -438,11 +479,17 @@ func (mgr *searchHeadClusterPodManager) Update(ctx context.Context, c splcommon.
 	}
 
 	// update statefulset, if necessary
-	_, err := splctrl.ApplyStatefulSet(ctx, mgr.c, statefulSet)
+	phase, err := splctrl.ApplyStatefulSetWithVolumeResize(ctx, mgr.c, statefulSet, &mgr.cr.Status.VolumeResize)
 	if err != nil {
 		return enterpriseApi.PhaseError, err
 	}
 
+	// wait for the expansion of the search head volumes and the recreation of the statefulset
+	if phase == enterpriseApi.PhaseUpdating && len(mgr.cr.Status.VolumeResize) > 0 {
+		mgr.log.Info("Waiting for the expansion of the search head volumes")
+		return phase, nil
+	}
+
 	// for now pass the targetPodName as empty since we are going to fill it in ApplyShcSecret
 	podExecClient := splutil.GetPodExecClient(mgr.c, mgr.cr, "")
 
@@ -459,8 +506,17 @@ func (mgr *searchHeadClusterPodManager) Update(ctx context.Context, c splcommon.
 		return enterpriseApi.PhasePending, nil
 	}
 
+	// hold the replicas changes during a bundle push or a member removal, and within the scale cooldown
+	desiredReplicas = getGuardedReplicas(&mgr.cr.Spec.Autoscaling, &mgr.cr.Status.AutoscalingStatus, *statefulSet.Spec.Replicas, desiredReplicas, getSearchHeadClusterBusyReason(mgr.cr), getSearchHeadClusterDetentionReason(mgr.cr))
+
 	// manage scaling and updates
-	return splctrl.UpdateStatefulSetPods(ctx, mgr.c, statefulSet, mgr, desiredReplicas)
+	phase, err = splctrl.UpdateStatefulSetPods(ctx, mgr.c, statefulSet, mgr, desiredReplicas)
+	if err != nil || phase != enterpriseApi.PhaseReady {
+		return phase, err
+	}
+
+	// restart the members which require it
+	return mgr.rollingRestart(ctx)
 }
 
 // PrepareScaleDown for searchHeadClusterPodManager prepares search head pod to be removed via scale down event; it returns true when ready
@@ -487,8 +543,24 @@ func (mgr *searchHeadClusterPodManager) PrepareScaleDown(ctx context.Context, n
 // PrepareRecycle for searchHeadClusterPodManager prepares search head pod to be recycled for updates; it returns true when ready
 func (mgr *searchHeadClusterPodManager) PrepareRecycle(ctx context.Context, n int32) (bool, error) {
 	memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
+	member := &mgr.cr.Status.Members[n]
+	strategy := &mgr.cr.Spec.UpdateStrategy
+
+	if strategy.Type == enterpriseApi.SearchHeadClusterUpdateRollingRestart {
+		// transfer the captaincy before the captain is recycled
+		transferred, err := mgr.transferCaptaincy(ctx, n)
+		if err != nil || !transferred {
+			return false, err
+		}
 
-	switch mgr.cr.Status.Members[n].Status {
+		// members are restarted without waiting for their searches, unless searchable
+		if !strategy.Searchable && member.Status == "Up" {
+			member.UpdatePhase = enterpriseApi.SearchHeadMemberRestarting
+			return true, nil
+		}
+	}
+
+	switch member.Status {
 	case "Up":
 		// Detain search head
 		mgr.log.Info("Detaining search head cluster member", "memberName", memberName)
@@ -502,16 +574,29 @@ func (mgr *searchHeadClusterPodManager) PrepareRecycle(ctx context.Context, n in
 			mgr.log.Info("Setting Probe level failed. Probably, the Pod is already down", "memberName", memberName)
 		}
 
+		member.UpdatePhase = enterpriseApi.SearchHeadMemberDraining
+		member.DrainStartTime = time.Now().Unix()
+		member.DrainSeconds = 0
 		return false, c.SetSearchHeadDetention(true)
 
 	case "ManualDetention":
 		// Wait until active searches have drained
-		searchesComplete := mgr.cr.Status.Members[n].ActiveHistoricalSearchCount+mgr.cr.Status.Members[n].ActiveRealtimeSearchCount == 0
+		if member.DrainStartTime == 0 {
+			member.DrainStartTime = time.Now().Unix()
+		}
+		member.DrainSeconds = time.Now().Unix() - member.DrainStartTime
+		searchesComplete := member.ActiveHistoricalSearchCount+member.ActiveRealtimeSearchCount == 0
 		if searchesComplete {
-			mgr.log.Info("Detention complete", "memberName", memberName)
+			mgr.log.Info("Detention complete", "memberName", memberName, "drainSeconds", member.DrainSeconds)
+		} else if strategy.Type == enterpriseApi.SearchHeadClusterUpdateRollingRestart && member.DrainSeconds >= getSearchHeadDrainTimeout(strategy) {
+			mgr.log.Info("Drain timeout reached, restarting with active searches", "memberName", memberName, "drainSeconds", member.DrainSeconds)
+			searchesComplete = true
 		} else {
 			mgr.log.Info("Waiting for active searches to complete", "memberName", memberName)
 		}
+		if searchesComplete {
+			member.UpdatePhase = enterpriseApi.SearchHeadMemberRestarting
+		}
 		return searchesComplete, nil
 
 	case "": // this can happen after the member has already been recycled and we're just waiting for state to update
@@ -530,6 +615,8 @@ func (mgr *searchHeadClusterPodManager) FinishRecycle(ctx context.Context, n int
 	switch mgr.cr.Status.Members[n].Status {
 	case "Up":
 		// not in detention
+		mgr.cr.Status.Members[n].UpdatePhase = ""
+		mgr.cr.Status.Members[n].DrainStartTime = 0
 		return true, nil
 
 	case "ManualDetention":
@@ -543,6 +630,118 @@ func (mgr *searchHeadClusterPodManager) FinishRecycle(ctx context.Context, n int
 	return false, fmt.Errorf("Status=%s", mgr.cr.Status.Members[n].Status)
 }
 
+// getSearchHeadDrainTimeout returns the time allowed for the searches of a member to drain, in seconds
+func getSearchHeadDrainTimeout(strategy *enterpriseApi.SearchHeadClusterUpdateStrategy) int64 {
+	if strategy.DrainTimeoutSeconds > 0 {
+		return int64(strategy.DrainTimeoutSeconds)
+	}
+	return defaultSearchHeadDrainTimeout
+}
+
+// getCaptainTransferTarget for searchHeadClusterPodManager returns the member the captaincy is transferred to, away
+// from the member n: the designated member if it is up, or else the first other member which is up
+func (mgr *searchHeadClusterPodManager) getCaptainTransferTarget(n int32) int32 {
+	target := int32(-1)
+	for i, member := range mgr.cr.Status.Members {
+		if int32(i) == n || member.Status != "Up" {
+			continue
+		}
+		if member.Name == mgr.cr.Spec.UpdateStrategy.CaptainTransferTarget {
+			return int32(i)
+		}
+		if target < 0 {
+			target = int32(i)
+		}
+	}
+	return target
+}
+
+// transferCaptaincy for searchHeadClusterPodManager transfers the captaincy away from the member n; it returns true
+// when the member is not the captain
+func (mgr *searchHeadClusterPodManager) transferCaptaincy(ctx context.Context, n int32) (bool, error) {
+	memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
+	if mgr.cr.Status.Captain != memberName {
+		return true, nil
+	}
+
+	target := mgr.getCaptainTransferTarget(n)
+	if target < 0 {
+		// a single member keeps the captaincy
+		if len(mgr.cr.Status.Members) < 2 {
+			return true, nil
+		}
+		mgr.log.Info("Waiting for a member to transfer the captaincy to", "memberName", memberName)
+		return false, nil
+	}
+
+	targetName := mgr.cr.Status.Members[target].Name
+	mgr.log.Info("Transferring search head captaincy", "memberName", memberName, "target", targetName)
+	mgr.cr.Status.Members[n].UpdatePhase = enterpriseApi.SearchHeadMemberTransferringCaptaincy
+	return false, mgr.getClient(ctx, n).TransferSearchHeadCaptaincy(mgr.getMemberURI(target))
+}
+
+// rollingRestart for searchHeadClusterPodManager restarts the members which require a restart with the rolling
+// restart of the captain, under the RollingRestart update strategy. The captaincy is first transferred to the
+// designated member, if any, which then coordinates the rolling restart
+func (mgr *searchHeadClusterPodManager) rollingRestart(ctx context.Context) (enterpriseApi.Phase, error) {
+	strategy := &mgr.cr.Spec.UpdateStrategy
+	if strategy.Type != enterpriseApi.SearchHeadClusterUpdateRollingRestart {
+		return enterpriseApi.PhaseReady, nil
+	}
+
+	captain := int32(-1)
+	restartRequired := false
+	for i := range mgr.cr.Status.Members {
+		member := &mgr.cr.Status.Members[i]
+		if member.Name == mgr.cr.Status.Captain {
+			captain = int32(i)
+		}
+		if member.RestartRequired {
+			restartRequired = true
+		} else if member.UpdatePhase == enterpriseApi.SearchHeadMemberRestarting && member.Status == "Up" {
+			// member restarted
+			member.UpdatePhase = ""
+		}
+	}
+	if mgr.cr.Status.RollingRestart {
+		mgr.log.Info("Waiting for the rolling restart of the search head cluster")
+		return enterpriseApi.PhaseUpdating, nil
+	}
+	if !restartRequired {
+		return enterpriseApi.PhaseReady, nil
+	}
+	if captain < 0 {
+		mgr.log.Info("Waiting for the search head captain to restart the members")
+		return enterpriseApi.PhaseUpdating, nil
+	}
+
+	// transfer the captaincy to the designated member first
+	if strategy.CaptainTransferTarget != "" && strategy.CaptainTransferTarget != mgr.cr.Status.Captain {
+		target := mgr.getCaptainTransferTarget(captain)
+		if target >= 0 && mgr.cr.Status.Members[target].Name == strategy.CaptainTransferTarget {
+			mgr.log.Info("Transferring search head captaincy before the rolling restart", "target", strategy.CaptainTransferTarget)
+			mgr.cr.Status.Members[captain].UpdatePhase = enterpriseApi.SearchHeadMemberTransferringCaptaincy
+			return enterpriseApi.PhaseUpdating, mgr.getClient(ctx, captain).TransferSearchHeadCaptaincy(mgr.getMemberURI(target))
+		}
+	}
+
+	mgr.log.Info("Starting the rolling restart of the search head cluster", "searchable", strategy.Searchable)
+	for i := range mgr.cr.Status.Members {
+		if mgr.cr.Status.Members[i].RestartRequired || int32(i) == captain {
+			mgr.cr.Status.Members[i].UpdatePhase = enterpriseApi.SearchHeadMemberRestarting
+		}
+	}
+	return enterpriseApi.PhaseUpdating, mgr.getClient(ctx, captain).RollingRestartSearchHeadCluster(strategy.Searchable)
+}
+
+// getMemberURI for searchHeadClusterPodManager returns the management URI of the member n
+func (mgr *searchHeadClusterPodManager) getMemberURI(n int32) string {
+	memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
+	fqdnName := splcommon.GetServiceFQDN(mgr.cr.GetNamespace(),
+		fmt.Sprintf("%s.%s", memberName, GetSplunkServiceName(SplunkSearchHead, mgr.cr.GetName(), true)))
+	return fmt.Sprintf("https://%s:8089", fqdnName)
+}
+
 // getClient for searchHeadClusterPodManager returns a SplunkClient for the member n
 func (mgr *searchHeadClusterPodManager) getClient(ctx context.Context, n int32) *splclient.SplunkClient {
 	reqLogger := log.FromContext(ctx)
@@ -551,17 +750,13 @@ func (mgr *searchHeadClusterPodManager) getClient(ctx context.Context, n int32)
 	// Get Pod Name
 	memberName := GetSplunkStatefulsetPodName(SplunkSearchHead, mgr.cr.GetName(), n)
 
-	// Get Fully Qualified Domain Name
-	fqdnName := splcommon.GetServiceFQDN(mgr.cr.GetNamespace(),
-		fmt.Sprintf("%s.%s", memberName, GetSplunkServiceName(SplunkSearchHead, mgr.cr.GetName(), true)))
-
 	// Retrieve admin password from Pod
 	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, mgr.c, memberName, mgr.cr.GetNamespace(), "password")
 	if err != nil {
 		scopedLog.Error(err, "Couldn't retrieve the admin password from Pod")
 	}
 
-	return mgr.newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", adminPwd)
+	return mgr.newSplunkClient(mgr.getMemberURI(n), "admin", adminPwd)
 }
 
 // GetSearchHeadClusterMemberInfo used in mocking this function
@@ -581,6 +776,7 @@ func (mgr *searchHeadClusterPodManager) updateStatus(ctx context.Context, statef
 	// populate members status using REST API to get search head cluster member info
 	mgr.cr.Status.Captain = ""
 	mgr.cr.Status.CaptainReady = false
+	mgr.cr.Status.RollingRestart = false
 	mgr.cr.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
 	if mgr.cr.Status.ReadyReplicas == 0 {
 		return nil
@@ -597,6 +793,7 @@ func (mgr *searchHeadClusterPodManager) updateStatus(ctx context.Context, statef
 			memberStatus.Registered = memberInfo.Registered
 			memberStatus.ActiveHistoricalSearchCount = memberInfo.ActiveHistoricalSearchCount
 			memberStatus.ActiveRealtimeSearchCount = memberInfo.ActiveRealtimeSearchCount
+			memberStatus.RestartRequired = memberInfo.RestartState != "" && memberInfo.RestartState != "NoRestart"
 		} else {
 			mgr.log.Error(err, "Unable to retrieve search head cluster member info", "memberName", memberName)
 		}
@@ -610,6 +807,7 @@ func (mgr *searchHeadClusterPodManager) updateStatus(ctx context.Context, statef
 				mgr.cr.Status.Initialized = captainInfo.Initialized
 				mgr.cr.Status.MinPeersJoined = captainInfo.MinPeersJoined
 				mgr.cr.Status.MaintenanceMode = captainInfo.MaintenanceMode
+				mgr.cr.Status.RollingRestart = captainInfo.RollingRestart
 				gotCaptainInfo = true
 			} else {
 				mgr.log.Error(err, "Unable to retrieve captain info", "memberName", memberName)
@@ -617,6 +815,10 @@ func (mgr *searchHeadClusterPodManager) updateStatus(ctx context.Context, statef
 		}
 
 		if n < int32(len(mgr.cr.Status.Members)) {
+			// keep the progress of the restart or update of the member
+			memberStatus.UpdatePhase = mgr.cr.Status.Members[n].UpdatePhase
+			memberStatus.DrainStartTime = mgr.cr.Status.Members[n].DrainStartTime
+			memberStatus.DrainSeconds = mgr.cr.Status.Members[n].DrainSeconds
 			mgr.cr.Status.Members[n] = memberStatus
 		} else {
 			mgr.cr.Status.Members = append(mgr.cr.Status.Members, memberStatus)
@@ -628,6 +830,9 @@ func (mgr *searchHeadClusterPodManager) updateStatus(ctx context.Context, statef
 		mgr.cr.Status.Members = mgr.cr.Status.Members[:statefulSet.Status.Replicas]
 	}
 
+	// export the search load of the members
+	mgr.updateAutoscalingMetrics()
+
 	return nil
 }
 
@@ -672,6 +877,16 @@ func validateSearchHeadClusterSpec(ctx context.Context, c splcommon.ControllerCl
 		}
 	}
 
+	err := validateBundlePushPolicy(&cr.Spec.BundlePushPolicy)
+	if err != nil {
+		return err
+	}
+
+	err = validateVolumeSnapshotSpec(&cr.Spec.Snapshots, &cr.Spec.CommonSplunkSpec, SplunkSearchHead)
+	if err != nil {
+		return err
+	}
+
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
 
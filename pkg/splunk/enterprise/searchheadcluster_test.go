@@ -20,6 +20,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"runtime/debug"
@@ -397,6 +398,116 @@ func TestSearchHeadClusterPodManager(t *testing.T) {
 
 }
 
+func TestSearchHeadClusterRollingRestart(t *testing.T) {
+	ctx := context.TODO()
+	cr := enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	cr.Spec.UpdateStrategy = enterpriseApi.SearchHeadClusterUpdateStrategy{
+		Type:                  enterpriseApi.SearchHeadClusterUpdateRollingRestart,
+		CaptainTransferTarget: "splunk-stack1-search-head-0",
+	}
+	for n := int32(0); n < 3; n++ {
+		cr.Status.Members = append(cr.Status.Members, enterpriseApi.SearchHeadClusterMemberStatus{
+			Name:   GetSplunkStatefulsetPodName(SplunkSearchHead, "stack1", n),
+			Status: "Up",
+		})
+	}
+	cr.Status.Captain = "splunk-stack1-search-head-2"
+
+	memberURI := func(n int) string {
+		return fmt.Sprintf("https://splunk-stack1-search-head-%d.splunk-stack1-search-head-headless.test.svc.cluster.local:8089", n)
+	}
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mgr := &searchHeadClusterPodManager{
+		c:   spltest.NewMockClient(),
+		log: logt.WithName("TestSearchHeadClusterRollingRestart"),
+		cr:  &cr,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+
+	// captaincy is transferred to the designated member before the captain is recycled
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    memberURI(2) + "/services/shcluster/member/consensus/default/transfer_captaincy?mgmt_uri=" + url.QueryEscape(memberURI(0)),
+		Status: 200,
+	})
+	ready, err := mgr.PrepareRecycle(ctx, 2)
+	if err != nil || ready || cr.Status.Members[2].UpdatePhase != enterpriseApi.SearchHeadMemberTransferringCaptaincy {
+		t.Errorf("captaincy should be transferred. ready: %t, err: %v, member: %v", ready, err, cr.Status.Members[2])
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterRollingRestart")
+
+	// former captain is restarted without waiting for its searches
+	cr.Status.Captain = "splunk-stack1-search-head-0"
+	ready, err = mgr.PrepareRecycle(ctx, 2)
+	if err != nil || !ready || cr.Status.Members[2].UpdatePhase != enterpriseApi.SearchHeadMemberRestarting {
+		t.Errorf("member should be restarted. ready: %t, err: %v, member: %v", ready, err, cr.Status.Members[2])
+	}
+	ready, err = mgr.FinishRecycle(ctx, 2)
+	if err != nil || !ready || cr.Status.Members[2].UpdatePhase != "" {
+		t.Errorf("member should be updated. ready: %t, err: %v, member: %v", ready, err, cr.Status.Members[2])
+	}
+
+	// searchable restart drains the searches up to the drain timeout
+	cr.Spec.UpdateStrategy.Searchable = true
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    memberURI(1) + "/services/shcluster/member/control/control/set_manual_detention?manual_detention=on",
+		Status: 200,
+	})
+	ready, err = mgr.PrepareRecycle(ctx, 1)
+	if err != nil || ready || cr.Status.Members[1].UpdatePhase != enterpriseApi.SearchHeadMemberDraining || cr.Status.Members[1].DrainStartTime == 0 {
+		t.Errorf("member should be draining. ready: %t, err: %v, member: %v", ready, err, cr.Status.Members[1])
+	}
+	cr.Status.Members[1].Status = "ManualDetention"
+	cr.Status.Members[1].ActiveHistoricalSearchCount = 1
+	ready, err = mgr.PrepareRecycle(ctx, 1)
+	if err != nil || ready {
+		t.Errorf("member should wait for its searches. ready: %t, err: %v", ready, err)
+	}
+	cr.Status.Members[1].DrainStartTime -= 200
+	ready, err = mgr.PrepareRecycle(ctx, 1)
+	if err != nil || !ready || cr.Status.Members[1].DrainSeconds < 200 || cr.Status.Members[1].UpdatePhase != enterpriseApi.SearchHeadMemberRestarting {
+		t.Errorf("member should be restarted after the drain timeout. ready: %t, err: %v, member: %v", ready, err, cr.Status.Members[1])
+	}
+	cr.Status.Members[1].Status = "Up"
+	cr.Status.Members[1].ActiveHistoricalSearchCount = 0
+
+	// members which require a restart are restarted by the captain
+	phase, err := mgr.rollingRestart(ctx)
+	if err != nil || phase != enterpriseApi.PhaseReady {
+		t.Errorf("rollingRestart() returned %v, %v; want %v", phase, err, enterpriseApi.PhaseReady)
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    memberURI(0) + "/services/shcluster/captain/control/default/restart?searchable=true",
+		Status: 200,
+	})
+	cr.Status.Members[1].RestartRequired = true
+	phase, err = mgr.rollingRestart(ctx)
+	if err != nil || phase != enterpriseApi.PhaseUpdating || cr.Status.Members[1].UpdatePhase != enterpriseApi.SearchHeadMemberRestarting {
+		t.Errorf("rolling restart should be started. phase: %v, err: %v, members: %v", phase, err, cr.Status.Members)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterRollingRestart")
+	cr.Status.RollingRestart = true
+	phase, err = mgr.rollingRestart(ctx)
+	if err != nil || phase != enterpriseApi.PhaseUpdating {
+		t.Errorf("rolling restart should be in progress. phase: %v, err: %v", phase, err)
+	}
+	cr.Status.RollingRestart = false
+	cr.Status.Members[1].RestartRequired = false
+	phase, err = mgr.rollingRestart(ctx)
+	if err != nil || phase != enterpriseApi.PhaseReady || cr.Status.Members[1].UpdatePhase != "" {
+		t.Errorf("rolling restart should be complete. phase: %v, err: %v, members: %v", phase, err, cr.Status.Members)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSearchHeadClusterRollingRestart")
+}
+
 func TestApplyShcSecret(t *testing.T) {
 	ctx := context.TODO()
 	method := "ApplyShcSecret"
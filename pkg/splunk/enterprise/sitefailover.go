@@ -0,0 +1,474 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splctrl "github.com/splunk/splunk-operator/pkg/splunk/controller"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// maximum number of steps kept in the site failover timeline
+const siteFailoverTimelineLength = 50
+
+// site of the search heads without search affinity
+const searchAffinityDisabledSite = "site0"
+
+// validateSiteFailoverSpec checks validity of the site failover of a cluster manager
+func validateSiteFailoverSpec(cr *enterpriseApi.ClusterManager) error {
+	spec := &cr.Spec.SiteFailover
+	if len(spec.FailedSites) > 0 {
+		if spec.TargetSite == "" {
+			return fmt.Errorf("siteFailover.targetSite is required to fail over sites %v", spec.FailedSites)
+		}
+		for _, site := range spec.FailedSites {
+			if site == spec.TargetSite {
+				return fmt.Errorf("siteFailover.targetSite %s is a failed site", spec.TargetSite)
+			}
+		}
+	}
+
+	standby := &spec.Standby
+	if standby.Enabled {
+		if standby.ManagerSite == "" || standby.Site == "" {
+			return fmt.Errorf("siteFailover.standby.managerSite and siteFailover.standby.site are required to run a standby cluster manager")
+		}
+		if standby.ManagerSite == standby.Site {
+			return fmt.Errorf("standby cluster manager must run in another site than %s", standby.ManagerSite)
+		}
+	} else if cr.Status.SiteFailover.ActiveManager == enterpriseApi.ActiveManagerStandby {
+		return fmt.Errorf("standby cluster manager can not be disabled while it is active")
+	}
+	return nil
+}
+
+// getClusterManagerStandbyName returns the name of the standby cluster manager StatefulSet
+func getClusterManagerStandbyName(cr *enterpriseApi.ClusterManager) string {
+	return GetSplunkStatefulsetName(SplunkClusterManager, cr.GetName()) + "-standby"
+}
+
+// getClusterManagerService returns the service of the cluster manager, selecting the active cluster manager
+func getClusterManagerService(ctx context.Context, cr *enterpriseApi.ClusterManager) *corev1.Service {
+	service := getSplunkService(ctx, cr, &cr.Spec.CommonSplunkSpec, SplunkClusterManager, false)
+	if cr.Status.SiteFailover.ActiveManager == enterpriseApi.ActiveManagerStandby {
+		service.Spec.Selector[splcommon.GetLabelTypes()["instance"]] = getClusterManagerStandbyName(cr)
+	}
+	return service
+}
+
+// getClusterManagerStandbyStatefulSet returns a Kubernetes StatefulSet object for the standby cluster manager. It
+// is a copy of the cluster manager StatefulSet, scheduled in the site of the standby cluster manager
+func getClusterManagerStandbyStatefulSet(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (*appsv1.StatefulSet, error) {
+	ss, err := getClusterManagerStatefulSet(ctx, client, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	standby := &cr.Spec.SiteFailover.Standby
+	name := getClusterManagerStandbyName(cr)
+	statefulSet := &appsv1.StatefulSet{
+		TypeMeta: ss.TypeMeta,
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            name,
+			Namespace:       cr.GetNamespace(),
+			Labels:          make(map[string]string),
+			Annotations:     make(map[string]string),
+			OwnerReferences: ss.GetOwnerReferences(),
+		},
+		Spec: *ss.Spec.DeepCopy(),
+	}
+	for k, v := range ss.GetLabels() {
+		statefulSet.ObjectMeta.Labels[k] = v
+	}
+	for k, v := range ss.GetAnnotations() {
+		statefulSet.ObjectMeta.Annotations[k] = v
+	}
+
+	// the standby cluster manager is not selected by the cluster manager service until promoted
+	instanceLabel := splcommon.GetLabelTypes()["instance"]
+	statefulSet.ObjectMeta.Labels[instanceLabel] = name
+	statefulSet.Spec.Selector.MatchLabels[instanceLabel] = name
+	statefulSet.Spec.Template.ObjectMeta.Labels[instanceLabel] = name
+
+	podSpec := &statefulSet.Spec.Template.Spec
+	if podSpec.Affinity == nil {
+		podSpec.Affinity = &corev1.Affinity{}
+	}
+	addSiteNodeAffinity(podSpec.Affinity, standby.Zone, standby.NodeSelector)
+	for i := range podSpec.Containers {
+		podSpec.Containers[i].Env = append(podSpec.Containers[i].Env, corev1.EnvVar{Name: "SPLUNK_SITE", Value: standby.Site})
+	}
+	return statefulSet, nil
+}
+
+// applyClusterManagerStandby creates or updates the standby cluster manager if enabled, or removes it otherwise
+func applyClusterManagerStandby(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.ClusterManager) (enterpriseApi.Phase, error) {
+	status := &cr.Status.SiteFailover
+	if !cr.Spec.SiteFailover.Standby.Enabled {
+		// the active manager is only tracked while a standby cluster manager runs
+		if status.ActiveManager == "" {
+			return enterpriseApi.PhaseReady, nil
+		}
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: getClusterManagerStandbyName(cr)}
+		statefulSet := &appsv1.StatefulSet{}
+		err := client.Get(ctx, namespacedName, statefulSet)
+		if err == nil {
+			err = splutil.DeleteResource(ctx, client, statefulSet)
+		}
+		if err != nil && !k8serrors.IsNotFound(err) {
+			return enterpriseApi.PhaseError, err
+		}
+		status.ActiveManager = ""
+		return enterpriseApi.PhaseReady, nil
+	}
+
+	if status.ActiveManager == "" {
+		status.ActiveManager = enterpriseApi.ActiveManagerPrimary
+	}
+
+	statefulSet, err := getClusterManagerStandbyStatefulSet(ctx, client, cr)
+	if err != nil {
+		return enterpriseApi.PhaseError, err
+	}
+	standbyManager := splctrl.DefaultStatefulSetPodManager{}
+	return standbyManager.Update(ctx, client, statefulSet, 1)
+}
+
+// getSiteMappings returns the site mappings of the failed sites to the target site
+func getSiteMappings(failedSites []string, targetSite string) string {
+	mappings := make([]string, 0, len(failedSites))
+	for _, site := range failedSites {
+		mappings = append(mappings, fmt.Sprintf("%s:%s", site, targetSite))
+	}
+	return strings.Join(mappings, ",")
+}
+
+// addSiteFailoverEvent records a step of the site failover in the timeline
+func (mgr *clusterManagerPodManager) addSiteFailoverEvent(step string, message string) {
+	mgr.log.Info("Site failover", "step", step, "message", message)
+	status := &mgr.cr.Status.SiteFailover
+	status.Timeline = append(status.Timeline, enterpriseApi.SiteFailoverEvent{
+		Time:    time.Now().Unix(),
+		Step:    step,
+		Message: message,
+	})
+	if len(status.Timeline) > siteFailoverTimelineLength {
+		status.Timeline = status.Timeline[len(status.Timeline)-siteFailoverTimelineLength:]
+	}
+}
+
+// applySiteFailover fails over the failed sites to the target site, promoting the standby cluster manager if the
+// site of the cluster manager failed, and fails them back once they are removed from the failed sites. Each step is
+// tracked in the status, so that it is completed once even if the failover spans several reconciles
+func (mgr *clusterManagerPodManager) applySiteFailover(ctx context.Context, c splcommon.ControllerClient) error {
+	spec := &mgr.cr.Spec.SiteFailover
+	status := &mgr.cr.Status.SiteFailover
+
+	failedSites := append([]string{}, spec.FailedSites...)
+	sort.Strings(failedSites)
+
+	if len(failedSites) > 0 {
+		if status.Phase == enterpriseApi.SiteFailoverPhaseFailedOver && reflect.DeepEqual(status.FailedSites, failedSites) && status.TargetSite == spec.TargetSite {
+			return nil
+		}
+		if status.Phase != enterpriseApi.SiteFailoverPhaseFailingOver {
+			status.Phase = enterpriseApi.SiteFailoverPhaseFailingOver
+			mgr.addSiteFailoverEvent("FailoverStarted", fmt.Sprintf("failing over sites %v to %s", failedSites, spec.TargetSite))
+		}
+
+		// the standby cluster manager takes over once the site of the cluster manager failed
+		standby := &spec.Standby
+		if standby.Enabled && status.ActiveManager != enterpriseApi.ActiveManagerStandby && isStringInList(standby.ManagerSite, failedSites) {
+			if isStringInList(standby.Site, failedSites) {
+				return fmt.Errorf("standby cluster manager can not be promoted, as its site %s failed", standby.Site)
+			}
+			status.ActiveManager = enterpriseApi.ActiveManagerStandby
+			status.SiteMappings = ""
+			err := splctrl.ApplyService(ctx, c, getClusterManagerService(ctx, mgr.cr))
+			if err != nil {
+				return err
+			}
+			mgr.addSiteFailoverEvent("StandbyPromoted", fmt.Sprintf("standby cluster manager in %s is active", standby.Site))
+		}
+
+		siteMappings := getSiteMappings(failedSites, spec.TargetSite)
+		if status.SiteMappings != siteMappings {
+			err := mgr.getClusterManagerClient(mgr.cr).SetClusterSiteMappings(siteMappings)
+			if err != nil {
+				return err
+			}
+			status.SiteMappings = siteMappings
+			mgr.addSiteFailoverEvent("SitesMapped", fmt.Sprintf("site mappings %s applied on the cluster manager", siteMappings))
+		}
+
+		if spec.DisableSearchAffinity && !status.SearchAffinityDisabled {
+			err := mgr.disableSearchAffinity(ctx, c)
+			if err != nil {
+				return err
+			}
+			status.SearchAffinityDisabled = true
+			mgr.addSiteFailoverEvent("SearchAffinityDisabled", fmt.Sprintf("search affinity of %d search heads disabled", len(status.SearchHeadSites)))
+		}
+
+		status.FailedSites = failedSites
+		status.TargetSite = spec.TargetSite
+		status.Phase = enterpriseApi.SiteFailoverPhaseFailedOver
+		mgr.addSiteFailoverEvent("FailoverCompleted", fmt.Sprintf("sites %v failed over to %s", failedSites, spec.TargetSite))
+		return nil
+	}
+
+	if status.Phase == "" {
+		return nil
+	}
+	if status.Phase != enterpriseApi.SiteFailoverPhaseFailingBack {
+		status.Phase = enterpriseApi.SiteFailoverPhaseFailingBack
+		mgr.addSiteFailoverEvent("FailbackStarted", fmt.Sprintf("failing back sites %v", status.FailedSites))
+	}
+
+	if status.SearchAffinityDisabled {
+		err := mgr.restoreSearchAffinity(ctx, c)
+		if err != nil {
+			return err
+		}
+		status.SearchAffinityDisabled = false
+		mgr.addSiteFailoverEvent("SearchAffinityRestored", "search affinity of the search heads restored")
+	}
+
+	if status.ActiveManager == enterpriseApi.ActiveManagerStandby {
+		// the cluster manager takes over from the standby cluster manager once ready, and its site mappings are cleared
+		pod := &corev1.Pod{}
+		namespacedName := types.NamespacedName{Namespace: mgr.cr.GetNamespace(), Name: GetSplunkStatefulsetPodName(SplunkClusterManager, mgr.cr.GetName(), 0)}
+		err := c.Get(ctx, namespacedName, pod)
+		if err != nil || !isPodReady(pod) {
+			return fmt.Errorf("waiting for the cluster manager to be ready to take over from the standby cluster manager")
+		}
+		status.ActiveManager = enterpriseApi.ActiveManagerPrimary
+
+		// the cluster manager may still have the site mappings applied before its site failed
+		status.SiteMappings = getSiteMappings(status.FailedSites, status.TargetSite)
+		err = splctrl.ApplyService(ctx, c, getClusterManagerService(ctx, mgr.cr))
+		if err != nil {
+			return err
+		}
+		mgr.addSiteFailoverEvent("ManagerRestored", "cluster manager is active")
+	}
+
+	if status.SiteMappings != "" {
+		err := mgr.getClusterManagerClient(mgr.cr).SetClusterSiteMappings("")
+		if err != nil {
+			return err
+		}
+		status.SiteMappings = ""
+		mgr.addSiteFailoverEvent("SiteMappingsCleared", "site mappings cleared on the cluster manager")
+	}
+
+	mgr.addSiteFailoverEvent("FailbackCompleted", fmt.Sprintf("sites %v failed back", status.FailedSites))
+	status.FailedSites = nil
+	status.TargetSite = ""
+	status.Phase = ""
+	return nil
+}
+
+// siteFailoverSearchHead is a search head referring to the cluster manager
+type siteFailoverSearchHead struct {
+	// name of the search head pod
+	name string
+
+	// management URI of the search head
+	uri string
+}
+
+// siteFailoverSearchHeadGroup is a group of search heads restarted together, i.e. the members of a search head
+// cluster, restarted by the captain, or the pods of a standalone search head, restarted one by one
+type siteFailoverSearchHeadGroup struct {
+	members []siteFailoverSearchHead
+
+	// management URI of the captain of the search head cluster
+	captainURI string
+}
+
+// getSearchHeadURI returns the management URI of a search head pod
+func getSearchHeadURI(namespace string, instanceType InstanceType, identifier string, podName string) string {
+	fqdnName := splcommon.GetServiceFQDN(namespace, fmt.Sprintf("%s.%s", podName, GetSplunkServiceName(instanceType, identifier, true)))
+	return fmt.Sprintf("https://%s:8089", fqdnName)
+}
+
+// getSiteFailoverSearchHeads returns the ready search heads of the search head clusters and standalones referring to
+// the cluster manager. Search heads of the failed sites are usually not ready, and their affinity is left unchanged
+func (mgr *clusterManagerPodManager) getSiteFailoverSearchHeads(ctx context.Context, c splcommon.ControllerClient) ([]siteFailoverSearchHeadGroup, error) {
+	namespace := mgr.cr.GetNamespace()
+	listOpts := []client.ListOption{
+		client.InNamespace(namespace),
+	}
+	groups := []siteFailoverSearchHeadGroup{}
+
+	getReadyPods := func(instanceType InstanceType, identifier string, replicas int32) ([]siteFailoverSearchHead, error) {
+		members := []siteFailoverSearchHead{}
+		for n := int32(0); n < replicas; n++ {
+			podName := GetSplunkStatefulsetPodName(instanceType, identifier, n)
+			pod := &corev1.Pod{}
+			err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: podName}, pod)
+			if err != nil {
+				if k8serrors.IsNotFound(err) {
+					continue
+				}
+				return nil, err
+			}
+			if isPodReady(pod) {
+				members = append(members, siteFailoverSearchHead{name: podName, uri: getSearchHeadURI(namespace, instanceType, identifier, podName)})
+			}
+		}
+		return members, nil
+	}
+
+	shcList, err := getSearchHeadClusterList(ctx, c, mgr.cr, listOpts)
+	if err != nil && err.Error() != "NotFound" && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	for i := range shcList.Items {
+		shc := &shcList.Items[i]
+		if shc.Spec.ClusterManagerRef.Name != mgr.cr.GetName() {
+			continue
+		}
+		members, err := getReadyPods(SplunkSearchHead, shc.GetName(), shc.Spec.Replicas)
+		if err != nil {
+			return nil, err
+		}
+		group := siteFailoverSearchHeadGroup{members: members}
+		for _, member := range members {
+			if member.name == shc.Status.Captain {
+				group.captainURI = member.uri
+			}
+		}
+		if len(members) > 0 && group.captainURI == "" {
+			return nil, fmt.Errorf("captain of search head cluster %s is not ready", shc.GetName())
+		}
+		groups = append(groups, group)
+	}
+
+	standaloneList, err := getStandaloneList(ctx, c, mgr.cr, listOpts)
+	if err != nil && err.Error() != "NotFound" && !k8serrors.IsNotFound(err) {
+		return nil, err
+	}
+	for i := range standaloneList.Items {
+		standalone := &standaloneList.Items[i]
+		if standalone.Spec.ClusterManagerRef.Name != mgr.cr.GetName() {
+			continue
+		}
+		members, err := getReadyPods(SplunkStandalone, standalone.GetName(), standalone.Spec.Replicas)
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			groups = append(groups, siteFailoverSearchHeadGroup{members: []siteFailoverSearchHead{member}})
+		}
+	}
+	return groups, nil
+}
+
+// setSearchHeadSites sets the site of the search heads, as returned by getSite for their current site, and restarts
+// the search heads whose site changed
+func (mgr *clusterManagerPodManager) setSearchHeadSites(ctx context.Context, c splcommon.ControllerClient, getSite func(name string, site string) string) error {
+	groups, err := mgr.getSiteFailoverSearchHeads(ctx, c)
+	if err != nil {
+		return err
+	}
+
+	password := string(mgr.secrets.Data["password"])
+	for _, group := range groups {
+		changed := []siteFailoverSearchHead{}
+		for _, member := range group.members {
+			sh := mgr.newSplunkClient(member.uri, "admin", password)
+			clusterInfo, err := sh.GetClusterInfo(false)
+			if err != nil {
+				return err
+			}
+			site := getSite(member.name, clusterInfo.Site)
+			if site == clusterInfo.Site {
+				continue
+			}
+			mgr.log.Info("Setting search head site", "searchHead", member.name, "site", site)
+			err = sh.SetSearchHeadSite(site)
+			if err != nil {
+				return err
+			}
+			changed = append(changed, member)
+		}
+		if len(changed) == 0 {
+			continue
+		}
+
+		if group.captainURI != "" {
+			err = mgr.newSplunkClient(group.captainURI, "admin", password).RollingRestartSearchHeadCluster(false)
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		for _, member := range changed {
+			err = mgr.newSplunkClient(member.uri, "admin", password).RestartSplunk()
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// disableSearchAffinity disables the search affinity of the search heads referring to the cluster manager, and
+// records their sites to restore them on failback
+func (mgr *clusterManagerPodManager) disableSearchAffinity(ctx context.Context, c splcommon.ControllerClient) error {
+	status := &mgr.cr.Status.SiteFailover
+	if status.SearchHeadSites == nil {
+		status.SearchHeadSites = make(map[string]string)
+	}
+	return mgr.setSearchHeadSites(ctx, c, func(name string, site string) string {
+		if site != searchAffinityDisabledSite {
+			status.SearchHeadSites[name] = site
+		}
+		return searchAffinityDisabledSite
+	})
+}
+
+// restoreSearchAffinity restores the sites of the search heads whose search affinity was disabled
+func (mgr *clusterManagerPodManager) restoreSearchAffinity(ctx context.Context, c splcommon.ControllerClient) error {
+	status := &mgr.cr.Status.SiteFailover
+	err := mgr.setSearchHeadSites(ctx, c, func(name string, site string) string {
+		if previousSite, ok := status.SearchHeadSites[name]; ok {
+			return previousSite
+		}
+		return site
+	})
+	if err != nil {
+		return err
+	}
+	status.SearchHeadSites = nil
+	return nil
+}
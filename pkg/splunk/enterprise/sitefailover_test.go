@@ -0,0 +1,314 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const siteFailoverManagerURI = "https://splunk-stack1-cluster-manager-service.test.svc.cluster.local:8089"
+
+func getSiteFailoverPodManager(method string, mockSplunkClient *spltest.MockHTTPClient) *clusterManagerPodManager {
+	cr := &enterpriseApi.ClusterManager{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "ClusterManager",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	secrets := &corev1.Secret{
+		Data: map[string][]byte{
+			"password": []byte("123"),
+		},
+	}
+	return &clusterManagerPodManager{
+		log:     logt.WithName(method),
+		cr:      cr,
+		secrets: secrets,
+		newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+			c := splclient.NewSplunkClient(managementURI, username, password)
+			c.Client = mockSplunkClient
+			return c
+		},
+	}
+}
+
+func getSiteFailoverReadyPod(name string) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: "test",
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: true}},
+		},
+	}
+}
+
+func TestValidateSiteFailoverSpec(t *testing.T) {
+	cr := &enterpriseApi.ClusterManager{}
+	if err := validateSiteFailoverSpec(cr); err != nil {
+		t.Errorf("site failover should be valid: %v", err)
+	}
+
+	cr.Spec.SiteFailover.FailedSites = []string{"site2"}
+	if err := validateSiteFailoverSpec(cr); err == nil {
+		t.Errorf("target site should be required")
+	}
+	cr.Spec.SiteFailover.TargetSite = "site2"
+	if err := validateSiteFailoverSpec(cr); err == nil {
+		t.Errorf("target site should not be a failed site")
+	}
+	cr.Spec.SiteFailover.TargetSite = "site1"
+	if err := validateSiteFailoverSpec(cr); err != nil {
+		t.Errorf("site failover should be valid: %v", err)
+	}
+
+	cr.Spec.SiteFailover.Standby = enterpriseApi.ClusterManagerStandbySpec{Enabled: true, ManagerSite: "site1"}
+	if err := validateSiteFailoverSpec(cr); err == nil {
+		t.Errorf("site of the standby cluster manager should be required")
+	}
+	cr.Spec.SiteFailover.Standby.Site = "site1"
+	if err := validateSiteFailoverSpec(cr); err == nil {
+		t.Errorf("standby cluster manager should run in another site")
+	}
+	cr.Spec.SiteFailover.Standby.Site = "site2"
+	if err := validateSiteFailoverSpec(cr); err != nil {
+		t.Errorf("site failover should be valid: %v", err)
+	}
+
+	cr.Spec.SiteFailover.Standby.Enabled = false
+	cr.Status.SiteFailover.ActiveManager = enterpriseApi.ActiveManagerStandby
+	if err := validateSiteFailoverSpec(cr); err == nil {
+		t.Errorf("active standby cluster manager should not be disabled")
+	}
+}
+
+func TestApplyClusterManagerStandby(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	_, err := splutil.ApplyNamespaceScopedSecretObject(ctx, c, "test")
+	if err != nil {
+		t.Errorf("Failed to create namespace scoped object")
+	}
+
+	mgr := getSiteFailoverPodManager("TestApplyClusterManagerStandby", &spltest.MockHTTPClient{})
+	cr := mgr.cr
+	cr.Spec.SiteFailover.Standby = enterpriseApi.ClusterManagerStandbySpec{
+		Enabled:      true,
+		ManagerSite:  "site1",
+		Site:         "site2",
+		Zone:         "us-west-2b",
+		NodeSelector: map[string]string{"pool": "managers"},
+	}
+
+	statefulSet, err := getClusterManagerStandbyStatefulSet(ctx, c, cr)
+	if err != nil {
+		t.Errorf("getClusterManagerStandbyStatefulSet() returned error: %v", err)
+	}
+	if statefulSet.GetName() != "splunk-stack1-cluster-manager-standby" ||
+		statefulSet.Spec.Selector.MatchLabels["app.kubernetes.io/instance"] != "splunk-stack1-cluster-manager-standby" ||
+		statefulSet.Spec.Template.GetLabels()["app.kubernetes.io/instance"] != "splunk-stack1-cluster-manager-standby" {
+		t.Errorf("unexpected standby statefulset %s, selector %v", statefulSet.GetName(), statefulSet.Spec.Selector.MatchLabels)
+	}
+	terms := statefulSet.Spec.Template.Spec.Affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	if len(terms) != 1 || len(terms[0].MatchExpressions) != 2 || terms[0].MatchExpressions[0].Values[0] != "us-west-2b" {
+		t.Errorf("standby cluster manager should be scheduled in its zone: %v", terms)
+	}
+	env := make(map[string]string)
+	for _, e := range statefulSet.Spec.Template.Spec.Containers[0].Env {
+		env[e.Name] = e.Value
+	}
+	if env["SPLUNK_SITE"] != "site2" {
+		t.Errorf("unexpected standby cluster manager env %v", env)
+	}
+
+	// the cluster manager service selects the standby cluster manager once promoted
+	if getClusterManagerService(ctx, cr).Spec.Selector["app.kubernetes.io/instance"] != "splunk-stack1-cluster-manager" {
+		t.Errorf("cluster manager service should select the cluster manager")
+	}
+	cr.Status.SiteFailover.ActiveManager = enterpriseApi.ActiveManagerStandby
+	if getClusterManagerService(ctx, cr).Spec.Selector["app.kubernetes.io/instance"] != "splunk-stack1-cluster-manager-standby" {
+		t.Errorf("cluster manager service should select the standby cluster manager")
+	}
+	cr.Status.SiteFailover.ActiveManager = ""
+
+	_, err = applyClusterManagerStandby(ctx, c, cr)
+	if err != nil {
+		t.Errorf("applyClusterManagerStandby() returned error: %v", err)
+	}
+	namespacedName := types.NamespacedName{Namespace: "test", Name: "splunk-stack1-cluster-manager-standby"}
+	if err = c.Get(ctx, namespacedName, &appsv1.StatefulSet{}); err != nil {
+		t.Errorf("standby cluster manager should be created: %v", err)
+	}
+	if cr.Status.SiteFailover.ActiveManager != enterpriseApi.ActiveManagerPrimary {
+		t.Errorf("cluster manager should be active")
+	}
+
+	cr.Spec.SiteFailover.Standby.Enabled = false
+	_, err = applyClusterManagerStandby(ctx, c, cr)
+	if err != nil {
+		t.Errorf("applyClusterManagerStandby() returned error: %v", err)
+	}
+	if len(c.Calls["Delete"]) != 1 || cr.Status.SiteFailover.ActiveManager != "" {
+		t.Errorf("standby cluster manager should be removed")
+	}
+}
+
+func TestApplySiteFailover(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "POST", URL: siteFailoverManagerURI + "/services/cluster/config/config?site_mappings=site1%3Asite2", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: siteFailoverManagerURI + "/services/cluster/config/config?site_mappings=", Status: 200},
+	)
+	mgr := getSiteFailoverPodManager("TestApplySiteFailover", mockSplunkClient)
+	cr := mgr.cr
+	cr.Spec.SiteFailover.Standby = enterpriseApi.ClusterManagerStandbySpec{Enabled: true, ManagerSite: "site1", Site: "site2"}
+	cr.Status.SiteFailover.ActiveManager = enterpriseApi.ActiveManagerPrimary
+
+	// nothing to do without failed sites
+	err := mgr.applySiteFailover(ctx, c)
+	if err != nil || cr.Status.SiteFailover.Phase != "" || len(mockSplunkClient.GotRequests) != 0 {
+		t.Errorf("no site should be failed over. err: %v", err)
+	}
+
+	// failover of the site of the cluster manager promotes the standby cluster manager
+	cr.Spec.SiteFailover.FailedSites = []string{"site1"}
+	cr.Spec.SiteFailover.TargetSite = "site2"
+	err = mgr.applySiteFailover(ctx, c)
+	if err != nil {
+		t.Errorf("applySiteFailover() returned error: %v", err)
+	}
+	status := &cr.Status.SiteFailover
+	if status.Phase != enterpriseApi.SiteFailoverPhaseFailedOver || status.ActiveManager != enterpriseApi.ActiveManagerStandby ||
+		status.SiteMappings != "site1:site2" || status.TargetSite != "site2" {
+		t.Errorf("site1 should be failed over to site2. status: %v", status)
+	}
+	steps := []string{}
+	for _, event := range status.Timeline {
+		steps = append(steps, event.Step)
+	}
+	if len(steps) != 4 || steps[0] != "FailoverStarted" || steps[1] != "StandbyPromoted" || steps[2] != "SitesMapped" || steps[3] != "FailoverCompleted" {
+		t.Errorf("unexpected failover timeline %v", steps)
+	}
+	service := &corev1.Service{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-cluster-manager-service"}, service)
+	if err != nil || service.Spec.Selector["app.kubernetes.io/instance"] != "splunk-stack1-cluster-manager-standby" {
+		t.Errorf("cluster manager service should select the standby cluster manager. err: %v", err)
+	}
+
+	// completed failover is not repeated
+	err = mgr.applySiteFailover(ctx, c)
+	if err != nil || len(status.Timeline) != 4 || len(mockSplunkClient.GotRequests) != 1 {
+		t.Errorf("failover should be completed. err: %v", err)
+	}
+
+	// failback waits for the cluster manager
+	cr.Spec.SiteFailover.FailedSites = nil
+	err = mgr.applySiteFailover(ctx, c)
+	if err == nil || status.Phase != enterpriseApi.SiteFailoverPhaseFailingBack || status.ActiveManager != enterpriseApi.ActiveManagerStandby {
+		t.Errorf("failback should wait for the cluster manager. status: %v", status)
+	}
+
+	c.AddObject(getSiteFailoverReadyPod("splunk-stack1-cluster-manager-0"))
+	err = mgr.applySiteFailover(ctx, c)
+	if err != nil {
+		t.Errorf("applySiteFailover() returned error: %v", err)
+	}
+	if status.Phase != "" || status.ActiveManager != enterpriseApi.ActiveManagerPrimary || status.SiteMappings != "" || len(status.FailedSites) != 0 {
+		t.Errorf("site1 should be failed back. status: %v", status)
+	}
+	if status.Timeline[len(status.Timeline)-1].Step != "FailbackCompleted" {
+		t.Errorf("unexpected failback timeline %v", status.Timeline)
+	}
+	mockSplunkClient.CheckRequests(t, "TestApplySiteFailover")
+}
+
+func TestSiteFailoverSearchAffinity(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	c.AddObject(getSiteFailoverReadyPod("splunk-sh1-standalone-0"))
+
+	standalone := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "sh1",
+			Namespace: "test",
+		},
+	}
+	standalone.Spec.Replicas = 2
+	standalone.Spec.ClusterManagerRef.Name = "stack1"
+	c.ListObj = &enterpriseApi.StandaloneList{Items: []enterpriseApi.Standalone{standalone}}
+
+	searchHeadURI := "https://splunk-sh1-standalone-0.splunk-sh1-standalone-headless.test.svc.cluster.local:8089"
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "POST", URL: siteFailoverManagerURI + "/services/cluster/config/config?site_mappings=site1%3Asite2", Status: 200},
+		spltest.MockHTTPHandler{Method: "GET", URL: searchHeadURI + "/services/cluster/config?count=0&output_mode=json", Status: 200, Body: `{"entry":[{"content":{"multisite":"true","site":"site1"}}]}`},
+		spltest.MockHTTPHandler{Method: "POST", URL: searchHeadURI + "/services/cluster/config/config?site=site0", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: searchHeadURI + "/services/server/control/restart", Status: 200},
+	)
+	mgr := getSiteFailoverPodManager("TestSiteFailoverSearchAffinity", mockSplunkClient)
+	cr := mgr.cr
+	cr.Spec.SiteFailover.FailedSites = []string{"site1"}
+	cr.Spec.SiteFailover.TargetSite = "site2"
+	cr.Spec.SiteFailover.DisableSearchAffinity = true
+
+	// the search head that is not ready is left unchanged
+	err := mgr.applySiteFailover(ctx, c)
+	if err != nil {
+		t.Errorf("applySiteFailover() returned error: %v", err)
+	}
+	status := &cr.Status.SiteFailover
+	if !status.SearchAffinityDisabled || len(status.SearchHeadSites) != 1 || status.SearchHeadSites["splunk-sh1-standalone-0"] != "site1" {
+		t.Errorf("search affinity should be disabled. status: %v", status)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSiteFailoverSearchAffinity")
+
+	// failback restores the site of the search head
+	mockSplunkClient = &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: searchHeadURI + "/services/cluster/config?count=0&output_mode=json", Status: 200, Body: `{"entry":[{"content":{"multisite":"true","site":"site0"}}]}`},
+		spltest.MockHTTPHandler{Method: "POST", URL: searchHeadURI + "/services/cluster/config/config?site=site1", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: searchHeadURI + "/services/server/control/restart", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: siteFailoverManagerURI + "/services/cluster/config/config?site_mappings=", Status: 200},
+	)
+	mgr = getSiteFailoverPodManager("TestSiteFailoverSearchAffinity", mockSplunkClient)
+	mgr.cr = cr
+	cr.Spec.SiteFailover.FailedSites = nil
+	err = mgr.applySiteFailover(ctx, c)
+	if err != nil {
+		t.Errorf("applySiteFailover() returned error: %v", err)
+	}
+	if status.SearchAffinityDisabled || status.SearchHeadSites != nil || status.Phase != "" {
+		t.Errorf("search affinity should be restored. status: %v", status)
+	}
+	mockSplunkClient.CheckRequests(t, "TestSiteFailoverSearchAffinity")
+}
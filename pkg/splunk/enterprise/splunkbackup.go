@@ -0,0 +1,531 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// max. time in seconds to wait for the KV store backup to complete
+const backupTimeout = 3600
+
+// KV store backup and restore status when no backup or restore is running
+const kvStoreBackupRestoreReady = "Ready"
+
+// operator directory the backup archives are staged in, on their way to or from the remote storage
+var backupLocalDir = filepath.Join(splcommon.AppDownloadVolume, "backups")
+
+// copies the backup archives between the operator and the Splunk pods, can be replaced in unit tests
+var copyBackupFromPod = CopyFileFromPod
+var copyBackupToPod = CopyFileToPod
+
+// getBackupPodExecClient returns the client running commands on a pod of the backup target
+var getBackupPodExecClient = func(c splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+	return splutil.GetPodExecClient(c, cr, podName)
+}
+
+// getBackupSplunkClient returns the client of a pod of the backup target
+var getBackupSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, target *backupTarget, podName string) (*splclient.SplunkClient, error) {
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, target.cr, target.spec)
+	if err != nil {
+		return nil, err
+	}
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, podName, target.cr.GetNamespace(), "password")
+	if err != nil {
+		return nil, err
+	}
+	return newSplunkClient(getSearchHeadURI(target.cr.GetNamespace(), target.instanceType, target.cr.GetName(), podName), "admin", adminPwd), nil
+}
+
+// getBackupRemoteDataClient returns the client of the remote storage volume of the backups
+var getBackupRemoteDataClient = func(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, vol *enterpriseApi.VolumeSpec, location string) (splclient.SplunkRemoteDataClient, error) {
+	if _, ok := splclient.RemoteDataClientsMap[vol.Provider]; !ok {
+		splclient.RegisterRemoteDataClient(ctx, vol.Provider)
+	}
+	remoteDataClientWrapper := splclient.RemoteDataClientsMap[vol.Provider]
+	initFn := remoteDataClientWrapper.GetRemoteDataClientInitFuncPtr(ctx)
+	return GetRemoteStorageClient(ctx, c, cr, nil, vol, location, initFn)
+}
+
+// backupTarget is the SearchHeadCluster or the Standalone a backup is taken on, or a backup is restored into
+type backupTarget struct {
+	cr           splcommon.MetaObject
+	spec         *enterpriseApi.CommonSplunkSpec
+	instanceType InstanceType
+	phase        enterpriseApi.Phase
+
+	// pod the KV store is backed up on and restored on, the captain of a search head cluster
+	kvStorePod string
+
+	// all the pods of the target
+	pods []string
+}
+
+// isReady confirms if the backup target is ready for a backup or a restore
+func (target *backupTarget) isReady() bool {
+	return target.phase == enterpriseApi.PhaseReady && target.kvStorePod != ""
+}
+
+// getBackupTarget returns the SearchHeadCluster or the Standalone referred by ref, in the given namespace
+func getBackupTarget(ctx context.Context, c splcommon.ControllerClient, namespace string, ref *corev1.ObjectReference) (*backupTarget, error) {
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: ref.Name}
+	target := &backupTarget{}
+
+	switch ref.Kind {
+	case "SearchHeadCluster":
+		shc := &enterpriseApi.SearchHeadCluster{}
+		err := c.Get(ctx, namespacedName, shc)
+		if err != nil {
+			return nil, err
+		}
+		target.cr = shc
+		target.spec = &shc.Spec.CommonSplunkSpec
+		target.instanceType = SplunkSearchHead
+		target.phase = shc.Status.Phase
+		target.kvStorePod = shc.Status.Captain
+		for i := int32(0); i < shc.Spec.Replicas; i++ {
+			target.pods = append(target.pods, GetSplunkStatefulsetPodName(SplunkSearchHead, shc.GetName(), i))
+		}
+
+	case "Standalone":
+		standalone := &enterpriseApi.Standalone{}
+		err := c.Get(ctx, namespacedName, standalone)
+		if err != nil {
+			return nil, err
+		}
+		target.cr = standalone
+		target.spec = &standalone.Spec.CommonSplunkSpec
+		target.instanceType = SplunkStandalone
+		target.phase = standalone.Status.Phase
+		target.kvStorePod = GetSplunkStatefulsetPodName(SplunkStandalone, standalone.GetName(), 0)
+		for i := int32(0); i < standalone.Spec.Replicas; i++ {
+			target.pods = append(target.pods, GetSplunkStatefulsetPodName(SplunkStandalone, standalone.GetName(), i))
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid targetRef kind %s. Valid values are SearchHeadCluster and Standalone", ref.Kind)
+	}
+
+	return target, nil
+}
+
+// validateBackupTargetRef validates the reference to the SearchHeadCluster or the Standalone backed up or restored into
+func validateBackupTargetRef(ref *corev1.ObjectReference, namespace string) error {
+	if ref.Kind != "SearchHeadCluster" && ref.Kind != "Standalone" {
+		return fmt.Errorf("invalid targetRef kind %s. Valid values are SearchHeadCluster and Standalone", ref.Kind)
+	}
+	if ref.Name == "" {
+		return fmt.Errorf("targetRef name is missing")
+	}
+	if ref.Namespace != "" && ref.Namespace != namespace {
+		return fmt.Errorf("targetRef namespace %s should be the namespace of the custom resource", ref.Namespace)
+	}
+	return nil
+}
+
+// validateBackupStorageSpec validates the remote storage volume of the backups
+func validateBackupStorageSpec(ctx context.Context, storage *enterpriseApi.BackupStorageSpec) error {
+	err := validateRemoteVolumeSpec(ctx, storage.VolList, true)
+	if err != nil {
+		return err
+	}
+
+	_, err = getBackupVolume(storage)
+	return err
+}
+
+// getBackupVolume returns the remote storage volume the backups are stored on
+func getBackupVolume(storage *enterpriseApi.BackupStorageSpec) (*enterpriseApi.VolumeSpec, error) {
+	for i := range storage.VolList {
+		if storage.VolList[i].Name == storage.VolName {
+			return &storage.VolList[i], nil
+		}
+	}
+	return nil, fmt.Errorf("invalid volumeName %q, volume is not found in the volumes", storage.VolName)
+}
+
+// getBackupArchiveNames returns the names of the KV store and the etc archives of a backup
+func getBackupArchiveNames(backupName string) (string, string) {
+	return backupName + ".tar.gz", backupName + "-etc.tar.gz"
+}
+
+// getBackupRemoteFile returns the path of an archive of a backup, relative to the bucket
+func getBackupRemoteFile(storage *enterpriseApi.BackupStorageSpec, vol *enterpriseApi.VolumeSpec, backupName string, archive string) string {
+	return getRemoteAppSrcPrefix(vol, storage.Location) + backupName + "/" + archive
+}
+
+// parseBackupSchedule parses the schedule of the backups as a maintenance window, which opens at the time of each
+// backup. Returns nil when a single backup is taken
+func parseBackupSchedule(spec *enterpriseApi.SplunkBackupSpec) (*maintenanceWindow, error) {
+	if spec.Schedule == "" {
+		return nil, nil
+	}
+
+	window, err := parseMaintenanceWindow(&enterpriseApi.MaintenanceWindowSpec{
+		Schedule:        spec.Schedule,
+		DurationMinutes: 1,
+		TimeZone:        spec.TimeZone,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid backup schedule. %v", err)
+	}
+	return window, nil
+}
+
+// validateSplunkBackupSpec validates the spec of the SplunkBackup, and returns the parsed schedule of the backups
+func validateSplunkBackupSpec(ctx context.Context, cr *enterpriseApi.SplunkBackup) (*maintenanceWindow, error) {
+	err := validateBackupTargetRef(&cr.Spec.TargetRef, cr.GetNamespace())
+	if err != nil {
+		return nil, err
+	}
+
+	err = validateBackupStorageSpec(ctx, &cr.Spec.BackupStorageSpec)
+	if err != nil {
+		return nil, err
+	}
+
+	if cr.Spec.Retention.MaxBackups < 0 || cr.Spec.Retention.MaxAgeDays < 0 {
+		return nil, fmt.Errorf("invalid retention, maxBackups and maxAgeDays should not be negative")
+	}
+
+	return parseBackupSchedule(&cr.Spec)
+}
+
+// getNextBackupTime returns the time of the next scheduled backup, after the last backup started or the SplunkBackup
+// was created. Zero time means no backup is scheduled
+func getNextBackupTime(cr *enterpriseApi.SplunkBackup, schedule *maintenanceWindow) time.Time {
+	if schedule == nil {
+		return time.Time{}
+	}
+
+	after := cr.GetCreationTimestamp().Time
+	if cr.Status.StartTime > after.Unix() {
+		after = time.Unix(cr.Status.StartTime, 0)
+	}
+	return schedule.nextStart(after)
+}
+
+// isBackupDue confirms if a backup should be taken at the given time
+func isBackupDue(cr *enterpriseApi.SplunkBackup, schedule *maintenanceWindow, now time.Time) bool {
+	if schedule == nil {
+		// a single backup is taken, whether it completes or not
+		return cr.Status.StartTime == 0
+	}
+
+	nextBackupTime := getNextBackupTime(cr, schedule)
+	return !nextBackupTime.IsZero() && !nextBackupTime.After(now)
+}
+
+// ApplySplunkBackup takes the scheduled backups of the KV store and the etc customizations of a SearchHeadCluster or a
+// Standalone, uploads them to the remote storage, and applies the retention of the backups
+func ApplySplunkBackup(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup) (reconcile.Result, error) {
+
+	// unless modified, reconcile for this object will be requeued after 5 seconds
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: time.Second * 5,
+	}
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("ApplySplunkBackup").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(client, cr)
+	cr.Kind = "SplunkBackup"
+
+	var err error
+	if cr.Status.Phase == "" {
+		cr.Status.Phase = enterpriseApi.BackupPhasePending
+	}
+
+	// Update the CR Status
+	defer updateCRStatus(ctx, client, cr, &err)
+
+	// validate the spec and parse the schedule of the backups
+	schedule, err := validateSplunkBackupSpec(ctx, cr)
+	if err != nil {
+		eventPublisher.Warning(ctx, "validateSplunkBackupSpec", fmt.Sprintf("validate backup spec failed %s", err.Error()))
+		scopedLog.Error(err, "Failed to validate backup spec")
+		cr.Status.Message = err.Error()
+		return result, err
+	}
+
+	now := time.Now()
+	if cr.Status.Phase == enterpriseApi.BackupPhaseInProgress {
+		err = checkSplunkBackup(ctx, client, cr, now)
+	} else if isBackupDue(cr, schedule, now) {
+		err = startSplunkBackup(ctx, client, cr, now)
+	}
+	if err != nil {
+		scopedLog.Error(err, "Unable to take the backup", "backup", cr.Status.CurrentBackup)
+		return result, err
+	}
+
+	if cr.Status.Phase == enterpriseApi.BackupPhaseInProgress || (cr.Status.Phase == enterpriseApi.BackupPhasePending && isBackupDue(cr, schedule, now)) {
+		// check the backup in progress, or the backup target again
+		return result, nil
+	}
+
+	nextBackupTime := getNextBackupTime(cr, schedule)
+	if nextBackupTime.IsZero() {
+		cr.Status.NextBackupTime = 0
+		return reconcile.Result{}, nil
+	}
+	cr.Status.NextBackupTime = nextBackupTime.Unix()
+	result.RequeueAfter = getWindowRequeueTime(cr.Status.NextBackupTime, now)
+	return result, nil
+}
+
+// startSplunkBackup archives the etc customizations and starts the KV store backup on the backup target, unless the
+// target is not ready
+func startSplunkBackup(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("startSplunkBackup").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	cr.Status.Phase = enterpriseApi.BackupPhasePending
+	target, err := getBackupTarget(ctx, c, cr.GetNamespace(), &cr.Spec.TargetRef)
+	if err != nil || !target.isReady() {
+		cr.Status.Message = fmt.Sprintf("waiting for %s %s to be ready", cr.Spec.TargetRef.Kind, cr.Spec.TargetRef.Name)
+		scopedLog.Info("Backup target is not ready", "error", err)
+		return nil
+	}
+
+	backupName := fmt.Sprintf("%s-%s", target.cr.GetName(), now.UTC().Format("20060102150405"))
+	kvStoreArchive, etcArchive := getBackupArchiveNames(backupName)
+	cr.Status.CurrentBackup = backupName
+	cr.Status.CurrentPod = target.kvStorePod
+	cr.Status.StartTime = now.Unix()
+
+	// the etc customizations are archived first, as the KV store backup runs in the background
+	podExecClient := getBackupPodExecClient(c, target.cr, target.kvStorePod)
+	command := fmt.Sprintf(createEtcBackupCmdStr, kvStoreBackupDir, filepath.Join(kvStoreBackupDir, etcArchive))
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		failSplunkBackup(ctx, c, cr, target, fmt.Sprintf("unable to archive the etc customizations on pod %s. stdout: %s, stdErr: %s, err: %s", target.kvStorePod, stdOut, stdErr, err))
+		return err
+	}
+
+	splunkClient, err := getBackupSplunkClient(ctx, c, target, target.kvStorePod)
+	if err == nil {
+		err = splunkClient.CreateKVStoreBackup(backupName)
+	}
+	if err != nil {
+		failSplunkBackup(ctx, c, cr, target, fmt.Sprintf("unable to start the KV store backup on pod %s. error: %s", target.kvStorePod, err))
+		return err
+	}
+
+	scopedLog.Info("Started the backup", "backup", backupName, "pod", target.kvStorePod, "archive", kvStoreArchive)
+	cr.Status.Phase = enterpriseApi.BackupPhaseInProgress
+	cr.Status.Message = fmt.Sprintf("backup %s in progress on pod %s", backupName, target.kvStorePod)
+	return nil
+}
+
+// checkSplunkBackup checks the KV store backup in progress, and uploads the backup archives once the KV store backup
+// is complete
+func checkSplunkBackup(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkSplunkBackup").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "backup", cr.Status.CurrentBackup)
+
+	target, err := getBackupTarget(ctx, c, cr.GetNamespace(), &cr.Spec.TargetRef)
+	if err != nil {
+		failSplunkBackup(ctx, c, cr, nil, fmt.Sprintf("unable to get the backup target. error: %s", err))
+		return err
+	}
+
+	splunkClient, err := getBackupSplunkClient(ctx, c, target, cr.Status.CurrentPod)
+	var kvStoreStatus *splclient.KVStoreStatus
+	if err == nil {
+		kvStoreStatus, err = splunkClient.GetKVStoreStatus()
+	}
+	if err != nil || kvStoreStatus.Current.BackupRestoreStatus != kvStoreBackupRestoreReady {
+		if cr.Status.StartTime+backupTimeout < now.Unix() {
+			failSplunkBackup(ctx, c, cr, target, fmt.Sprintf("timed out waiting for the KV store backup on pod %s", cr.Status.CurrentPod))
+		}
+		return err
+	}
+
+	backup, err := uploadSplunkBackup(ctx, c, cr, target)
+	if err != nil {
+		failSplunkBackup(ctx, c, cr, target, fmt.Sprintf("unable to upload the backup. error: %s", err))
+		return err
+	}
+
+	scopedLog.Info("Backup complete")
+	cr.Status.Backups = append(cr.Status.Backups, *backup)
+	cr.Status.Phase = enterpriseApi.BackupPhaseCompleted
+	cr.Status.LastBackupTime = now.Unix()
+	cr.Status.CurrentBackup = ""
+	cr.Status.CurrentPod = ""
+	cr.Status.Message = fmt.Sprintf("backup %s completed", backup.Name)
+
+	return applyBackupRetention(ctx, c, cr, now)
+}
+
+// uploadSplunkBackup copies the archives of the backup in progress from the pod to the operator, uploads them to the
+// remote storage, and removes them from the pod and the operator
+func uploadSplunkBackup(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, target *backupTarget) (*enterpriseApi.BackupInfo, error) {
+	storage := &cr.Spec.BackupStorageSpec
+	vol, err := getBackupVolume(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	remoteDataClient, err := getBackupRemoteDataClient(ctx, c, cr, vol, storage.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	localDir := filepath.Join(backupLocalDir, cr.GetNamespace(), cr.GetName())
+	err = os.MkdirAll(localDir, 0755)
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(localDir)
+
+	backup := &enterpriseApi.BackupInfo{
+		Name: cr.Status.CurrentBackup,
+		Time: cr.Status.StartTime,
+	}
+	kvStoreArchive, etcArchive := getBackupArchiveNames(backup.Name)
+	for _, archive := range []string{kvStoreArchive, etcArchive} {
+		localFile := filepath.Join(localDir, archive)
+		err = copyBackupFromPod(ctx, c, cr.GetNamespace(), cr.Status.CurrentPod, filepath.Join(kvStoreBackupDir, archive), localFile)
+		if err != nil {
+			return nil, err
+		}
+
+		remoteFile := getBackupRemoteFile(storage, vol, backup.Name, archive)
+		err = remoteDataClient.Client.UploadFile(ctx, splclient.RemoteDataUploadRequest{
+			LocalFile:  localFile,
+			RemoteFile: remoteFile,
+		})
+		if err != nil {
+			return nil, err
+		}
+		os.Remove(localFile)
+
+		if archive == kvStoreArchive {
+			backup.KVStoreArchive = remoteFile
+		} else {
+			backup.EtcArchive = remoteFile
+		}
+	}
+
+	removeBackupFilesFromPod(ctx, c, target.cr, cr.Status.CurrentPod, backup.Name)
+	return backup, nil
+}
+
+// removeBackupFilesFromPod removes the archives of a backup from the pod, ignoring failures
+func removeBackupFilesFromPod(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, podName string, backupName string) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("removeBackupFilesFromPod").WithValues("podName", podName, "backup", backupName)
+
+	kvStoreArchive, etcArchive := getBackupArchiveNames(backupName)
+	command := fmt.Sprintf(removeBackupFilesCmdStr, strings.Join([]string{filepath.Join(kvStoreBackupDir, kvStoreArchive), filepath.Join(kvStoreBackupDir, etcArchive)}, " "))
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	_, stdErr, err := getBackupPodExecClient(c, cr, podName).RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		scopedLog.Error(err, "Unable to remove the backup archives", "stdErr", stdErr)
+	}
+}
+
+// failSplunkBackup marks the backup in progress failed
+func failSplunkBackup(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, target *backupTarget, message string) {
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+	eventPublisher.Warning(ctx, "SplunkBackup", fmt.Sprintf("backup %s failed: %s", cr.Status.CurrentBackup, message))
+
+	if target != nil && cr.Status.CurrentPod != "" {
+		removeBackupFilesFromPod(ctx, c, target.cr, cr.Status.CurrentPod, cr.Status.CurrentBackup)
+	}
+
+	cr.Status.Phase = enterpriseApi.BackupPhaseFailed
+	cr.Status.Message = fmt.Sprintf("backup %s failed: %s", cr.Status.CurrentBackup, message)
+	cr.Status.CurrentBackup = ""
+	cr.Status.CurrentPod = ""
+}
+
+// applyBackupRetention deletes the backups exceeding the maximum number of backups, or older than the maximum age,
+// from the remote storage. The latest backup is always kept
+func applyBackupRetention(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkBackup, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyBackupRetention").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	retention := cr.Spec.Retention
+	if retention.MaxBackups == 0 && retention.MaxAgeDays == 0 {
+		return nil
+	}
+
+	var remoteDataClient splclient.SplunkRemoteDataClient
+	var err error
+	backups := cr.Status.Backups
+	kept := []enterpriseApi.BackupInfo{}
+	for i, backup := range backups {
+		latest := i == len(backups)-1
+		tooMany := retention.MaxBackups > 0 && int32(len(backups)-i) > retention.MaxBackups
+		tooOld := retention.MaxAgeDays > 0 && backup.Time < now.Add(-time.Duration(retention.MaxAgeDays)*24*time.Hour).Unix()
+		if latest || !(tooMany || tooOld) {
+			kept = append(kept, backup)
+			continue
+		}
+
+		if remoteDataClient.Client == nil {
+			vol, err := getBackupVolume(&cr.Spec.BackupStorageSpec)
+			if err != nil {
+				return err
+			}
+			remoteDataClient, err = getBackupRemoteDataClient(ctx, c, cr, vol, cr.Spec.Location)
+			if err != nil {
+				return err
+			}
+		}
+
+		// the backup is kept, and deleted again in the next backup, if any of its archives is not deleted
+		var deleteErr error
+		for _, remoteFile := range []string{backup.KVStoreArchive, backup.EtcArchive} {
+			if remoteFile == "" {
+				continue
+			}
+			deleteErr = remoteDataClient.Client.DeleteFile(ctx, remoteFile)
+			if deleteErr != nil {
+				break
+			}
+		}
+		if deleteErr != nil {
+			scopedLog.Error(deleteErr, "Unable to delete the backup", "backup", backup.Name)
+			kept = append(kept, backup)
+			err = deleteErr
+			continue
+		}
+
+		scopedLog.Info("Deleted the backup", "backup", backup.Name)
+	}
+
+	cr.Status.Backups = kept
+	return err
+}
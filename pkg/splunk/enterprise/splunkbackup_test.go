@@ -0,0 +1,327 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// backupTestEnv replaces the pod exec, splunkd and remote storage clients of the backups and the restores
+type backupTestEnv struct {
+	podExecClient    *spltest.MockPodExecClient
+	splunkClient     *spltest.MockHTTPClient
+	remoteDataClient *testRemoteDataClient
+	copiedToPods     []string
+}
+
+func newBackupTestEnv(t *testing.T) *backupTestEnv {
+	env := &backupTestEnv{
+		podExecClient:    &spltest.MockPodExecClient{},
+		splunkClient:     &spltest.MockHTTPClient{},
+		remoteDataClient: &testRemoteDataClient{objects: map[string]string{}},
+	}
+	savedLocalDir, savedCopyFromPod, savedCopyToPod := backupLocalDir, copyBackupFromPod, copyBackupToPod
+	savedPodExecClient, savedSplunkClient, savedRemoteDataClient := getBackupPodExecClient, getBackupSplunkClient, getBackupRemoteDataClient
+	t.Cleanup(func() {
+		backupLocalDir, copyBackupFromPod, copyBackupToPod = savedLocalDir, savedCopyFromPod, savedCopyToPod
+		getBackupPodExecClient, getBackupSplunkClient, getBackupRemoteDataClient = savedPodExecClient, savedSplunkClient, savedRemoteDataClient
+	})
+
+	backupLocalDir = t.TempDir()
+	copyBackupFromPod = func(ctx context.Context, c splcommon.ControllerClient, namespace string, podName string, srcPath string, destPath string) error {
+		return os.WriteFile(destPath, []byte(podName+":"+srcPath), 0644)
+	}
+	copyBackupToPod = func(ctx context.Context, c splcommon.ControllerClient, namespace string, srcPath string, destPath string, podExecClient splutil.PodExecClientImpl) (string, string, error) {
+		env.copiedToPods = append(env.copiedToPods, podExecClient.GetTargetPodName()+":"+filepath.Base(srcPath))
+		return "", "", nil
+	}
+	getBackupPodExecClient = func(c splcommon.ControllerClient, cr splcommon.MetaObject, podName string) splutil.PodExecClientImpl {
+		env.podExecClient.SetTargetPodName(context.TODO(), podName)
+		return env.podExecClient
+	}
+	getBackupSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, target *backupTarget, podName string) (*splclient.SplunkClient, error) {
+		splunkClient := splclient.NewSplunkClient(getSearchHeadURI(target.cr.GetNamespace(), target.instanceType, target.cr.GetName(), podName), "admin", "p@ssw0rd")
+		splunkClient.Client = env.splunkClient
+		return splunkClient, nil
+	}
+	getBackupRemoteDataClient = func(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, vol *enterpriseApi.VolumeSpec, location string) (splclient.SplunkRemoteDataClient, error) {
+		return splclient.SplunkRemoteDataClient{Client: env.remoteDataClient}, nil
+	}
+	return env
+}
+
+// addPodExecCommands mocks the given pod exec commands. The mock matches a command by substring, so the commands of a
+// test must not contain one another
+func (env *backupTestEnv) addPodExecCommands(commands ...string) {
+	for _, command := range commands {
+		env.podExecClient.AddMockPodExecReturnContext(context.TODO(), command, &spltest.MockPodExecReturnContext{})
+	}
+}
+
+// getKVStoreStatusHandler returns the KV store status of a pod, with the given backup and restore status
+func getKVStoreStatusHandler(uri string, backupRestoreStatus string) spltest.MockHTTPHandler {
+	return spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    uri + "/services/kvstore/status?count=0&output_mode=json",
+		Status: 200,
+		Body:   fmt.Sprintf(`{"entry":[{"content":{"current":{"status":"ready","backupRestoreStatus":"%s"}}}]}`, backupRestoreStatus),
+	}
+}
+
+func getBackupStorageSpec() enterpriseApi.BackupStorageSpec {
+	return enterpriseApi.BackupStorageSpec{
+		VolList: []enterpriseApi.VolumeSpec{
+			{Name: "backups", Endpoint: "https://s3-us-west-2.amazonaws.com", Path: "bucket/backups", Provider: "aws", Type: "s3"},
+		},
+		VolName:  "backups",
+		Location: "stack1",
+	}
+}
+
+func TestValidateSplunkBackupSpec(t *testing.T) {
+	ctx := context.TODO()
+	cr := &enterpriseApi.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup1", Namespace: "test"},
+		Spec: enterpriseApi.SplunkBackupSpec{
+			TargetRef:         corev1.ObjectReference{Kind: "SearchHeadCluster", Name: "shc1"},
+			BackupStorageSpec: getBackupStorageSpec(),
+			Schedule:          "0 2 * * *",
+			TimeZone:          "America/Los_Angeles",
+		},
+	}
+	schedule, err := validateSplunkBackupSpec(ctx, cr)
+	if err != nil || schedule == nil {
+		t.Errorf("valid backup spec should be accepted. error: %v", err)
+	}
+
+	invalidSpecs := map[string]func(spec *enterpriseApi.SplunkBackupSpec){
+		"invalid target kind":       func(spec *enterpriseApi.SplunkBackupSpec) { spec.TargetRef.Kind = "IndexerCluster" },
+		"missing target name":       func(spec *enterpriseApi.SplunkBackupSpec) { spec.TargetRef.Name = "" },
+		"other target namespace":    func(spec *enterpriseApi.SplunkBackupSpec) { spec.TargetRef.Namespace = "other" },
+		"unknown volume":            func(spec *enterpriseApi.SplunkBackupSpec) { spec.VolName = "unknown" },
+		"invalid volume provider":   func(spec *enterpriseApi.SplunkBackupSpec) { spec.VolList[0].Provider = "gcp" },
+		"negative retention":        func(spec *enterpriseApi.SplunkBackupSpec) { spec.Retention.MaxBackups = -1 },
+		"invalid schedule":          func(spec *enterpriseApi.SplunkBackupSpec) { spec.Schedule = "0 25 * * *" },
+		"invalid schedule timezone": func(spec *enterpriseApi.SplunkBackupSpec) { spec.TimeZone = "Mars/Olympus" },
+	}
+	for name, invalidate := range invalidSpecs {
+		invalidCR := cr.DeepCopy()
+		invalidate(&invalidCR.Spec)
+		_, err = validateSplunkBackupSpec(ctx, invalidCR)
+		if err == nil {
+			t.Errorf("backup spec with %s should be rejected", name)
+		}
+	}
+}
+
+func TestIsBackupDue(t *testing.T) {
+	created := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cr := &enterpriseApi.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+	}
+
+	// single backup
+	if !isBackupDue(cr, nil, created) || !getNextBackupTime(cr, nil).IsZero() {
+		t.Errorf("single backup should be due")
+	}
+	cr.Status.StartTime = created.Unix()
+	if isBackupDue(cr, nil, created.Add(time.Hour)) {
+		t.Errorf("single backup should be taken only once")
+	}
+
+	// daily backups at 2am
+	cr.Status.StartTime = 0
+	cr.Spec.Schedule = "0 2 * * *"
+	schedule, err := parseBackupSchedule(&cr.Spec)
+	if err != nil {
+		t.Fatalf("unable to parse the backup schedule. error: %v", err)
+	}
+	if isBackupDue(cr, schedule, created.Add(time.Hour)) {
+		t.Errorf("backup should not be due before the schedule")
+	}
+	if !isBackupDue(cr, schedule, created.Add(2*time.Hour)) || !isBackupDue(cr, schedule, created.Add(30*time.Hour)) {
+		t.Errorf("backup should be due after the schedule")
+	}
+
+	cr.Status.StartTime = created.Add(2 * time.Hour).Unix()
+	nextBackupTime := getNextBackupTime(cr, schedule)
+	if !nextBackupTime.Equal(created.Add(26 * time.Hour)) {
+		t.Errorf("next backup should be taken the next day, got %v", nextBackupTime)
+	}
+	if isBackupDue(cr, schedule, created.Add(3*time.Hour)) {
+		t.Errorf("backup should not be due until the next day")
+	}
+}
+
+func TestApplySplunkBackup(t *testing.T) {
+	ctx := context.TODO()
+	env := newBackupTestEnv(t)
+	c := spltest.NewMockClient()
+
+	standalone := &enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	standalone.Spec.Replicas = 1
+	standalone.Status.Phase = enterpriseApi.PhasePending
+	c.Create(ctx, standalone)
+
+	cr := &enterpriseApi.SplunkBackup{
+		TypeMeta: metav1.TypeMeta{Kind: "SplunkBackup"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              "backup1",
+			Namespace:         "test",
+			CreationTimestamp: metav1.NewTime(time.Now().Add(-48 * time.Hour)),
+		},
+		Spec: enterpriseApi.SplunkBackupSpec{
+			TargetRef:         corev1.ObjectReference{Kind: "Standalone", Name: "stack1"},
+			BackupStorageSpec: getBackupStorageSpec(),
+			Schedule:          "0 2 * * *",
+		},
+	}
+	c.Create(ctx, cr)
+
+	// the backup waits for the target to be ready
+	result, err := ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhasePending || !strings.Contains(cr.Status.Message, "waiting for Standalone stack1") || !result.Requeue {
+		t.Errorf("backup should wait for the target. err: %v, status: %v", err, cr.Status)
+	}
+
+	podName := "splunk-stack1-standalone-0"
+	podURI := getSearchHeadURI("test", SplunkStandalone, "stack1", podName)
+	standalone.Status.Phase = enterpriseApi.PhaseReady
+	c.Update(ctx, standalone)
+	now := time.Now()
+	backupName := fmt.Sprintf("stack1-%s", now.UTC().Format("20060102150405"))
+	kvStoreArchive, etcArchive := getBackupArchiveNames(backupName)
+	createEtcBackupCmd := fmt.Sprintf(createEtcBackupCmdStr, kvStoreBackupDir, filepath.Join(kvStoreBackupDir, etcArchive))
+	removeBackupFilesCmd := fmt.Sprintf(removeBackupFilesCmdStr, filepath.Join(kvStoreBackupDir, kvStoreArchive)+" "+filepath.Join(kvStoreBackupDir, etcArchive))
+	env.addPodExecCommands(createEtcBackupCmd, removeBackupFilesCmd)
+	env.splunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "POST", URL: podURI + "/services/kvstore/backup/create?archiveName=" + backupName, Status: 200})
+	err = startSplunkBackup(ctx, c, cr, now)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseInProgress || cr.Status.CurrentPod != podName || cr.Status.StartTime == 0 {
+		t.Errorf("backup should be in progress. err: %v, status: %v", err, cr.Status)
+	}
+	if len(env.podExecClient.GotCmdList) != 1 || env.podExecClient.GotCmdList[0] != createEtcBackupCmd {
+		t.Errorf("etc customizations should be archived, got commands %v", env.podExecClient.GotCmdList)
+	}
+
+	// the backup is in progress until the KV store backup completes
+	env.splunkClient.AddHandlers(getKVStoreStatusHandler(podURI, "Running"))
+	result, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseInProgress || result.RequeueAfter != 5*time.Second {
+		t.Errorf("backup should still be in progress. err: %v, status: %v", err, cr.Status)
+	}
+
+	env.splunkClient.AddHandlers(getKVStoreStatusHandler(podURI, "Ready"))
+	result, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseCompleted || cr.Status.CurrentBackup != "" || cr.Status.LastBackupTime == 0 || len(cr.Status.Backups) != 1 {
+		t.Fatalf("backup should be complete. err: %v, status: %v", err, cr.Status)
+	}
+	backup := cr.Status.Backups[0]
+	wantKVStoreArchive := "backups/stack1/" + backupName + "/" + backupName + ".tar.gz"
+	wantEtcArchive := "backups/stack1/" + backupName + "/" + backupName + "-etc.tar.gz"
+	if backup.Name != backupName || backup.KVStoreArchive != wantKVStoreArchive || backup.EtcArchive != wantEtcArchive {
+		t.Errorf("unexpected backup %v", backup)
+	}
+	if env.remoteDataClient.objects[wantKVStoreArchive] != podName+":/opt/splunk/var/lib/splunk/kvstorebackup/"+backupName+".tar.gz" || env.remoteDataClient.objects[wantEtcArchive] == "" {
+		t.Errorf("backup archives should be uploaded, got %v", env.remoteDataClient.objects)
+	}
+	if len(env.podExecClient.GotCmdList) != 2 || env.podExecClient.GotCmdList[1] != removeBackupFilesCmd {
+		t.Errorf("backup archives should be removed from the pod, got commands %v", env.podExecClient.GotCmdList)
+	}
+
+	// the next backup is scheduled the next day
+	if cr.Status.NextBackupTime <= now.Unix() || cr.Status.NextBackupTime > now.Add(24*time.Hour).Unix() || result.RequeueAfter <= 5*time.Second {
+		t.Errorf("next backup should be scheduled. status: %v, result: %v", cr.Status, result)
+	}
+
+	// the backup fails when the KV store backup does not complete in time
+	cr.Status.Phase = enterpriseApi.BackupPhaseInProgress
+	cr.Status.CurrentBackup = backupName
+	cr.Status.CurrentPod = podName
+	cr.Status.StartTime = now.Unix() - backupTimeout - 1
+	env.splunkClient.AddHandlers(getKVStoreStatusHandler(podURI, "Running"))
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseFailed || !strings.Contains(cr.Status.Message, "timed out") || len(cr.Status.Backups) != 1 {
+		t.Errorf("backup should fail. err: %v, status: %v", err, cr.Status)
+	}
+
+	// invalid spec
+	cr.Spec.VolName = "unknown"
+	_, err = ApplySplunkBackup(ctx, c, cr)
+	if err == nil {
+		t.Errorf("ApplySplunkBackup() should return error for an invalid spec")
+	}
+}
+
+func TestApplyBackupRetention(t *testing.T) {
+	ctx := context.TODO()
+	env := newBackupTestEnv(t)
+	c := spltest.NewMockClient()
+
+	now := time.Now()
+	cr := &enterpriseApi.SplunkBackup{
+		ObjectMeta: metav1.ObjectMeta{Name: "backup1", Namespace: "test"},
+		Spec: enterpriseApi.SplunkBackupSpec{
+			BackupStorageSpec: getBackupStorageSpec(),
+		},
+	}
+	for i, age := range []time.Duration{10 * 24 * time.Hour, 5 * 24 * time.Hour, 4 * 24 * time.Hour, time.Hour} {
+		name := fmt.Sprintf("backup%d", i)
+		backup := enterpriseApi.BackupInfo{
+			Name:           name,
+			Time:           now.Add(-age).Unix(),
+			KVStoreArchive: "backups/stack1/" + name + "/" + name + ".tar.gz",
+			EtcArchive:     "backups/stack1/" + name + "/" + name + "-etc.tar.gz",
+		}
+		env.remoteDataClient.objects[backup.KVStoreArchive] = name
+		env.remoteDataClient.objects[backup.EtcArchive] = name
+		cr.Status.Backups = append(cr.Status.Backups, backup)
+	}
+
+	// all the backups are kept by default
+	err := applyBackupRetention(ctx, c, cr, now)
+	if err != nil || len(cr.Status.Backups) != 4 || len(env.remoteDataClient.objects) != 8 {
+		t.Errorf("all the backups should be kept. err: %v, backups: %v", err, cr.Status.Backups)
+	}
+
+	cr.Spec.Retention.MaxBackups = 3
+	err = applyBackupRetention(ctx, c, cr, now)
+	if err != nil || len(cr.Status.Backups) != 3 || cr.Status.Backups[0].Name != "backup1" || len(env.remoteDataClient.objects) != 6 {
+		t.Errorf("oldest backup should be deleted. err: %v, backups: %v", err, cr.Status.Backups)
+	}
+
+	// the latest backup is kept regardless of its age
+	cr.Spec.Retention.MaxAgeDays = 3
+	err = applyBackupRetention(ctx, c, cr, now.Add(10*24*time.Hour))
+	if err != nil || len(cr.Status.Backups) != 1 || cr.Status.Backups[0].Name != "backup3" || len(env.remoteDataClient.objects) != 2 {
+		t.Errorf("only the latest backup should be kept. err: %v, backups: %v", err, cr.Status.Backups)
+	}
+}
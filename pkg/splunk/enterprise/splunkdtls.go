@@ -0,0 +1,127 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// splunkdTLSSecretEnvVar is the env variable of the operator with the operator-wide Secret, as namespace/name,
+	// used to verify the certificates of the splunkd management port
+	splunkdTLSSecretEnvVar = "SPLUNKD_TLS_SECRET"
+
+	// splunkdTLSCABundleKey is the key of the PEM encoded CA bundle in the Secrets
+	splunkdTLSCABundleKey = "ca.crt"
+
+	// splunkdTLSServerNameKey is the key of the server name in the operator-wide Secret
+	splunkdTLSServerNameKey = "serverName"
+
+	// splunkdTLSInsecureSkipVerifyKey is the key used to skip the verification in the operator-wide Secret
+	splunkdTLSInsecureSkipVerifyKey = "insecureSkipVerify"
+)
+
+// getSplunkdTLSSecret returns the Secret with the CA bundle used to verify the certificates of the splunkd
+// management port of a custom resource, or nil when no Secret is configured
+func getSplunkdTLSSecret(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec) (*corev1.Secret, error) {
+	namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: spec.SplunkdTLS.CABundleSecretRef}
+	if namespacedName.Name == "" {
+		operatorSecret := os.Getenv(splunkdTLSSecretEnvVar)
+		if operatorSecret == "" {
+			return nil, nil
+		}
+		parts := strings.SplitN(operatorSecret, "/", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%s must be set to namespace/name, got %s", splunkdTLSSecretEnvVar, operatorSecret)
+		}
+		namespacedName = types.NamespacedName{Namespace: parts[0], Name: parts[1]}
+	}
+
+	secret := &corev1.Secret{}
+	err := c.Get(ctx, namespacedName, secret)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the splunkd TLS secret %s: %w", namespacedName, err)
+	}
+	return secret, nil
+}
+
+// getSplunkdTLSConfig returns the TLS configuration used to connect to the splunkd management port of a custom
+// resource. The settings of the custom resource override the ones of the operator-wide Secret. Returns nil when
+// neither is configured
+func getSplunkdTLSConfig(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec) (*tls.Config, error) {
+	if spec.SplunkdTLS.InsecureSkipVerify {
+		return splclient.NewTLSConfig(nil, "", true)
+	}
+	secret, err := getSplunkdTLSSecret(ctx, c, cr, spec)
+	if err != nil || secret == nil {
+		return nil, err
+	}
+
+	serverName := spec.SplunkdTLS.ServerName
+	if spec.SplunkdTLS.CABundleSecretRef == "" {
+		// operator-wide settings
+		if string(secret.Data[splunkdTLSInsecureSkipVerifyKey]) == "true" {
+			return splclient.NewTLSConfig(nil, "", true)
+		}
+		if serverName == "" {
+			serverName = string(secret.Data[splunkdTLSServerNameKey])
+		}
+	}
+	tlsConfig, err := splclient.NewTLSConfig(secret.Data[splunkdTLSCABundleKey], serverName, false)
+	if err != nil {
+		return nil, fmt.Errorf("invalid CA bundle in the splunkd TLS secret %s/%s: %w", secret.GetNamespace(), secret.GetName(), err)
+	}
+	return tlsConfig, nil
+}
+
+// getSplunkClientFunc returns the function used to create the clients of the splunkd management port of a custom
+// resource. The certificates are verified when a CA bundle is configured, and the verification failures are reported
+// as events of the custom resource
+func getSplunkClientFunc(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec) (NewSplunkClientFunc, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("getSplunkClientFunc").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+
+	tlsConfig, err := getSplunkdTLSConfig(ctx, c, cr, spec)
+	if err != nil {
+		eventPublisher.Warning(ctx, "TLSConfigurationFailed", err.Error())
+		return nil, err
+	}
+	if tlsConfig == nil {
+		// certificates are not verified without a CA bundle
+		return splclient.NewSplunkClient, nil
+	}
+
+	return func(managementURI, username, password string) *splclient.SplunkClient {
+		splunkClient := splclient.NewSplunkClientWithTLSConfig(managementURI, username, password, tlsConfig)
+		splunkClient.TLSVerificationFailed = func(err *splclient.TLSVerificationError) {
+			scopedLog.Error(err, "Certificate verification failed", "host", err.Host, "reason", err.Reason)
+			eventPublisher.Warning(ctx, "TLSVerificationFailed", err.Error())
+		}
+		return splunkClient
+	}, nil
+}
@@ -0,0 +1,118 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getSplunkdTLSTestConfig(newSplunkClient NewSplunkClientFunc) *tls.Config {
+	return newSplunkClient("https://splunk-stack1-standalone-0:8089", "admin", "p@ssw0rd").GetTLSConfig()
+}
+
+func TestGetSplunkClientFunc(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	caBundle := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+
+	cr := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	spec := &cr.Spec.CommonSplunkSpec
+
+	// certificates are not verified without a CA bundle
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, &cr, spec)
+	if err != nil || !getSplunkdTLSTestConfig(newSplunkClient).InsecureSkipVerify {
+		t.Errorf("certificates should not be verified. err: %v", err)
+	}
+
+	// operator-wide secret
+	c.AddObject(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunkd-tls", Namespace: "splunk-operator"},
+		Data: map[string][]byte{
+			splunkdTLSCABundleKey:   caBundle,
+			splunkdTLSServerNameKey: []byte("SplunkServerDefaultCert"),
+		},
+	})
+	t.Setenv(splunkdTLSSecretEnvVar, "splunk-operator/splunkd-tls")
+	newSplunkClient, err = getSplunkClientFunc(ctx, c, &cr, spec)
+	if err != nil {
+		t.Errorf("getSplunkClientFunc() returned error: %v", err)
+	}
+	tlsConfig := getSplunkdTLSTestConfig(newSplunkClient)
+	if tlsConfig.InsecureSkipVerify || tlsConfig.RootCAs == nil || tlsConfig.ServerName != "SplunkServerDefaultCert" {
+		t.Errorf("certificates should be verified with the operator-wide secret")
+	}
+	if newSplunkClient("https://splunk-stack1-standalone-0:8089", "admin", "p@ssw0rd").TLSVerificationFailed == nil {
+		t.Errorf("verification failures should be reported")
+	}
+
+	// custom resource overrides the operator-wide secret
+	spec.SplunkdTLS.ServerName = "splunk.example.com"
+	newSplunkClient, _ = getSplunkClientFunc(ctx, c, &cr, spec)
+	if getSplunkdTLSTestConfig(newSplunkClient).ServerName != "splunk.example.com" {
+		t.Errorf("server name of the custom resource should be used")
+	}
+	spec.SplunkdTLS.InsecureSkipVerify = true
+	newSplunkClient, _ = getSplunkClientFunc(ctx, c, &cr, spec)
+	if !getSplunkdTLSTestConfig(newSplunkClient).InsecureSkipVerify {
+		t.Errorf("certificates should not be verified when explicitly requested")
+	}
+	spec.SplunkdTLS.InsecureSkipVerify = false
+
+	// CA bundle of the custom resource
+	spec.SplunkdTLS.CABundleSecretRef = "stack1-ca"
+	_, err = getSplunkClientFunc(ctx, c, &cr, spec)
+	if err == nil {
+		t.Errorf("getSplunkClientFunc() should fail when the secret is missing")
+	}
+	c.AddObject(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1-ca", Namespace: "test"},
+		Data:       map[string][]byte{splunkdTLSCABundleKey: []byte("invalid")},
+	})
+	_, err = getSplunkClientFunc(ctx, c, &cr, spec)
+	if err == nil {
+		t.Errorf("getSplunkClientFunc() should fail with an invalid CA bundle")
+	}
+	c.AddObject(&corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1-ca", Namespace: "test"},
+		Data:       map[string][]byte{splunkdTLSCABundleKey: caBundle},
+	})
+	newSplunkClient, err = getSplunkClientFunc(ctx, c, &cr, spec)
+	if err != nil || getSplunkdTLSTestConfig(newSplunkClient).RootCAs == nil {
+		t.Errorf("certificates should be verified with the secret of the custom resource. err: %v", err)
+	}
+
+	// invalid operator-wide secret
+	spec.SplunkdTLS = enterpriseApi.SplunkdTLSSpec{}
+	t.Setenv(splunkdTLSSecretEnvVar, "splunkd-tls")
+	_, err = getSplunkClientFunc(ctx, c, &cr, spec)
+	if err == nil {
+		t.Errorf("getSplunkClientFunc() should fail when the operator-wide secret is not namespace/name")
+	}
+}
@@ -0,0 +1,319 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// max. time in seconds to wait for the KV store restore to complete
+const restoreTimeout = 3600
+
+// validateSplunkRestoreSpec validates the spec of the SplunkRestore
+func validateSplunkRestoreSpec(ctx context.Context, cr *enterpriseApi.SplunkRestore) error {
+	err := validateBackupTargetRef(&cr.Spec.TargetRef, cr.GetNamespace())
+	if err != nil {
+		return err
+	}
+
+	if strings.Contains(cr.Spec.BackupName, "/") {
+		return fmt.Errorf("invalid backupName %s", cr.Spec.BackupName)
+	}
+
+	return validateBackupStorageSpec(ctx, &cr.Spec.BackupStorageSpec)
+}
+
+// getLatestBackupName returns the name of the latest backup on the remote storage
+func getLatestBackupName(ctx context.Context, remoteDataClient splclient.SplunkRemoteDataClient, prefix string) (string, error) {
+	remoteDataListResponse, err := remoteDataClient.Client.GetAppsList(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	var latestName string
+	var latestTime time.Time
+	for _, object := range remoteDataListResponse.Objects {
+		if object.Key == nil {
+			continue
+		}
+
+		// the KV store archive of a backup is <prefix>/<backupName>/<backupName>.tar.gz
+		parts := strings.Split(strings.TrimPrefix(*object.Key, prefix), "/")
+		if len(parts) != 2 || parts[1] != parts[0]+".tar.gz" {
+			continue
+		}
+
+		modified := time.Time{}
+		if object.LastModified != nil {
+			modified = *object.LastModified
+		}
+		if latestName == "" || modified.After(latestTime) || (modified.Equal(latestTime) && parts[0] > latestName) {
+			latestName = parts[0]
+			latestTime = modified
+		}
+	}
+
+	if latestName == "" {
+		return "", fmt.Errorf("no backup found under %s", prefix)
+	}
+	return latestName, nil
+}
+
+// ApplySplunkRestore restores a backup of the KV store and the etc customizations into a SearchHeadCluster or a
+// Standalone, once the custom resource is ready
+func ApplySplunkRestore(ctx context.Context, client splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore) (reconcile.Result, error) {
+
+	// unless modified, reconcile for this object will be requeued after 5 seconds
+	result := reconcile.Result{
+		Requeue:      true,
+		RequeueAfter: time.Second * 5,
+	}
+
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("ApplySplunkRestore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(client, cr)
+	cr.Kind = "SplunkRestore"
+
+	var err error
+	if cr.Status.Phase == "" {
+		cr.Status.Phase = enterpriseApi.BackupPhasePending
+	}
+
+	// a restore is applied once
+	if cr.Status.Phase == enterpriseApi.BackupPhaseCompleted || cr.Status.Phase == enterpriseApi.BackupPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+
+	// Update the CR Status
+	defer updateCRStatus(ctx, client, cr, &err)
+
+	err = validateSplunkRestoreSpec(ctx, cr)
+	if err != nil {
+		eventPublisher.Warning(ctx, "validateSplunkRestoreSpec", fmt.Sprintf("validate restore spec failed %s", err.Error()))
+		scopedLog.Error(err, "Failed to validate restore spec")
+		cr.Status.Message = err.Error()
+		return result, err
+	}
+
+	now := time.Now()
+	if cr.Status.Phase == enterpriseApi.BackupPhaseInProgress {
+		err = checkSplunkRestore(ctx, client, cr, now)
+	} else {
+		err = startSplunkRestore(ctx, client, cr, now)
+	}
+	if err != nil {
+		scopedLog.Error(err, "Unable to restore the backup", "backup", cr.Status.BackupName)
+		return result, err
+	}
+
+	if cr.Status.Phase == enterpriseApi.BackupPhaseCompleted || cr.Status.Phase == enterpriseApi.BackupPhaseFailed {
+		return reconcile.Result{}, nil
+	}
+	return result, nil
+}
+
+// startSplunkRestore downloads the backup, extracts the etc customizations on all the pods of the target and starts
+// the KV store restore, unless the target is not ready
+func startSplunkRestore(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("startSplunkRestore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	target, err := getBackupTarget(ctx, c, cr.GetNamespace(), &cr.Spec.TargetRef)
+	if err != nil || !target.isReady() {
+		cr.Status.Message = fmt.Sprintf("waiting for %s %s to be ready", cr.Spec.TargetRef.Kind, cr.Spec.TargetRef.Name)
+		scopedLog.Info("Restore target is not ready", "error", err)
+		return nil
+	}
+
+	storage := &cr.Spec.BackupStorageSpec
+	vol, err := getBackupVolume(storage)
+	if err != nil {
+		return err
+	}
+	remoteDataClient, err := getBackupRemoteDataClient(ctx, c, cr, vol, storage.Location)
+	if err != nil {
+		return err
+	}
+
+	cr.Status.StartTime = now.Unix()
+	cr.Status.BackupName = cr.Spec.BackupName
+	if cr.Status.BackupName == "" {
+		cr.Status.BackupName, err = getLatestBackupName(ctx, remoteDataClient, getRemoteAppSrcPrefix(vol, storage.Location))
+		if err != nil {
+			failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to find the latest backup. error: %s", err))
+			return err
+		}
+	}
+
+	localDir := filepath.Join(backupLocalDir, cr.GetNamespace(), cr.GetName())
+	err = os.MkdirAll(localDir, 0755)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(localDir)
+
+	kvStoreArchive, etcArchive := getBackupArchiveNames(cr.Status.BackupName)
+	for _, archive := range []string{kvStoreArchive, etcArchive} {
+		_, err = remoteDataClient.Client.DownloadApp(ctx, splclient.RemoteDataDownloadRequest{
+			LocalFile:  filepath.Join(localDir, archive),
+			RemoteFile: getBackupRemoteFile(storage, vol, cr.Status.BackupName, archive),
+		})
+		if err != nil {
+			failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to download the backup. error: %s", err))
+			return err
+		}
+	}
+
+	// the etc customizations are restored on all the pods, and take effect once the target is restarted
+	for _, podName := range target.pods {
+		err = copyBackupArchiveToPod(ctx, c, target.cr, podName, filepath.Join(localDir, etcArchive))
+		if err != nil {
+			failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to copy the etc customizations to pod %s. error: %s", podName, err))
+			return err
+		}
+
+		etcArchivePath := filepath.Join(kvStoreBackupDir, etcArchive)
+		command := fmt.Sprintf(extractEtcBackupCmdStr, etcArchivePath, etcArchivePath)
+		streamOptions := splutil.NewStreamOptionsObject(command)
+		stdOut, stdErr, err := getBackupPodExecClient(c, target.cr, podName).RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+		if err != nil {
+			failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to extract the etc customizations on pod %s. stdout: %s, stdErr: %s, err: %s", podName, stdOut, stdErr, err))
+			return err
+		}
+	}
+
+	// the KV store of a search head cluster is restored on the captain, and replicated to the members
+	err = copyBackupArchiveToPod(ctx, c, target.cr, target.kvStorePod, filepath.Join(localDir, kvStoreArchive))
+	if err != nil {
+		failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to copy the KV store archive to pod %s. error: %s", target.kvStorePod, err))
+		return err
+	}
+
+	splunkClient, err := getBackupSplunkClient(ctx, c, target, target.kvStorePod)
+	if err == nil {
+		err = splunkClient.RestoreKVStoreBackup(kvStoreArchive)
+	}
+	if err != nil {
+		failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to start the KV store restore on pod %s. error: %s", target.kvStorePod, err))
+		return err
+	}
+
+	scopedLog.Info("Started the restore", "backup", cr.Status.BackupName, "pod", target.kvStorePod)
+	cr.Status.Phase = enterpriseApi.BackupPhaseInProgress
+	cr.Status.RestorePod = target.kvStorePod
+	cr.Status.Message = fmt.Sprintf("restore of backup %s in progress on pod %s", cr.Status.BackupName, target.kvStorePod)
+	return nil
+}
+
+// copyBackupArchiveToPod copies a backup archive from the operator to the KV store backup directory of the pod
+func copyBackupArchiveToPod(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, podName string, localFile string) error {
+	podExecClient := getBackupPodExecClient(c, cr, podName)
+	command := fmt.Sprintf("mkdir -p %s", kvStoreBackupDir)
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	stdOut, stdErr, err := podExecClient.RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		return fmt.Errorf("unable to create the directory %s. stdout: %s, stdErr: %s, err: %s", kvStoreBackupDir, stdOut, stdErr, err)
+	}
+
+	_, stdErr, err = copyBackupToPod(ctx, c, cr.GetNamespace(), localFile, kvStoreBackupDir+"/", podExecClient)
+	if err != nil {
+		return fmt.Errorf("stdErr: %s, err: %s", stdErr, err)
+	}
+	return nil
+}
+
+// checkSplunkRestore checks the KV store restore in progress, and restarts the target once the restore is complete,
+// for the etc customizations to take effect
+func checkSplunkRestore(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkSplunkRestore").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace(), "backup", cr.Status.BackupName)
+
+	target, err := getBackupTarget(ctx, c, cr.GetNamespace(), &cr.Spec.TargetRef)
+	if err != nil {
+		failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to get the restore target. error: %s", err))
+		return err
+	}
+
+	splunkClient, err := getBackupSplunkClient(ctx, c, target, cr.Status.RestorePod)
+	var kvStoreStatus *splclient.KVStoreStatus
+	if err == nil {
+		kvStoreStatus, err = splunkClient.GetKVStoreStatus()
+	}
+	if err != nil || kvStoreStatus.Current.BackupRestoreStatus != kvStoreBackupRestoreReady {
+		if cr.Status.StartTime+restoreTimeout < now.Unix() {
+			failSplunkRestore(ctx, c, cr, fmt.Sprintf("timed out waiting for the KV store restore on pod %s", cr.Status.RestorePod))
+		}
+		return err
+	}
+
+	kvStoreArchive, _ := getBackupArchiveNames(cr.Status.BackupName)
+	command := fmt.Sprintf(removeBackupFilesCmdStr, filepath.Join(kvStoreBackupDir, kvStoreArchive))
+	streamOptions := splutil.NewStreamOptionsObject(command)
+	_, stdErr, err := getBackupPodExecClient(c, target.cr, cr.Status.RestorePod).RunPodExecCommand(ctx, streamOptions, []string{"/bin/sh"})
+	if err != nil {
+		scopedLog.Error(err, "Unable to remove the KV store archive", "stdErr", stdErr)
+	}
+
+	// a search head cluster is restarted one member at a time by the captain
+	if target.instanceType == SplunkSearchHead {
+		err = splunkClient.RollingRestartSearchHeadCluster(false)
+	} else {
+		for _, podName := range target.pods {
+			podSplunkClient, podErr := getBackupSplunkClient(ctx, c, target, podName)
+			if podErr == nil {
+				podErr = podSplunkClient.RestartSplunk()
+			}
+			if podErr != nil {
+				err = podErr
+				scopedLog.Error(podErr, "Unable to restart the pod", "podName", podName)
+			}
+		}
+	}
+	if err != nil {
+		failSplunkRestore(ctx, c, cr, fmt.Sprintf("unable to restart %s %s. error: %s", cr.Spec.TargetRef.Kind, cr.Spec.TargetRef.Name, err))
+		return err
+	}
+
+	scopedLog.Info("Restore complete")
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+	eventPublisher.Normal(ctx, "SplunkRestore", fmt.Sprintf("backup %s restored into %s %s", cr.Status.BackupName, cr.Spec.TargetRef.Kind, cr.Spec.TargetRef.Name))
+	cr.Status.Phase = enterpriseApi.BackupPhaseCompleted
+	cr.Status.CompletionTime = now.Unix()
+	cr.Status.Message = fmt.Sprintf("backup %s restored", cr.Status.BackupName)
+	return nil
+}
+
+// failSplunkRestore marks the restore failed
+func failSplunkRestore(ctx context.Context, c splcommon.ControllerClient, cr *enterpriseApi.SplunkRestore, message string) {
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+	eventPublisher.Warning(ctx, "SplunkRestore", fmt.Sprintf("restore of backup %s failed: %s", cr.Status.BackupName, message))
+
+	cr.Status.Phase = enterpriseApi.BackupPhaseFailed
+	cr.Status.CompletionTime = time.Now().Unix()
+	cr.Status.Message = fmt.Sprintf("restore of backup %s failed: %s", cr.Status.BackupName, message)
+}
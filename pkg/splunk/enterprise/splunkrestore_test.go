@@ -0,0 +1,187 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGetLatestBackupName(t *testing.T) {
+	ctx := context.TODO()
+	remoteDataClient := splclient.SplunkRemoteDataClient{
+		Client: &testRemoteDataClient{objects: map[string]string{}},
+	}
+
+	_, err := getLatestBackupName(ctx, remoteDataClient, "backups/stack1/")
+	if err == nil {
+		t.Errorf("getLatestBackupName() should return error without backups")
+	}
+
+	remoteDataClient.Client = &testRemoteDataClient{
+		objects: map[string]string{
+			"backups/stack1/stack1-20230101020000/stack1-20230101020000.tar.gz":     "kvstore",
+			"backups/stack1/stack1-20230101020000/stack1-20230101020000-etc.tar.gz": "etc",
+			"backups/stack1/stack1-20230102020000/stack1-20230102020000.tar.gz":     "kvstore",
+			"backups/stack1/stack1-20230102020000/stack1-20230102020000-etc.tar.gz": "etc",
+			"backups/stack1/stack1-20230103020000/stack1-20230103020000-etc.tar.gz": "etc",
+			"backups/stack1/notes.txt": "notes",
+		},
+	}
+	backupName, err := getLatestBackupName(ctx, remoteDataClient, "backups/stack1/")
+	if err != nil || backupName != "stack1-20230102020000" {
+		t.Errorf("latest backup should be stack1-20230102020000, got %s. err: %v", backupName, err)
+	}
+}
+
+func TestValidateSplunkRestoreSpec(t *testing.T) {
+	ctx := context.TODO()
+	cr := &enterpriseApi.SplunkRestore{
+		ObjectMeta: metav1.ObjectMeta{Name: "restore1", Namespace: "test"},
+		Spec: enterpriseApi.SplunkRestoreSpec{
+			TargetRef:         corev1.ObjectReference{Kind: "Standalone", Name: "stack1"},
+			BackupStorageSpec: getBackupStorageSpec(),
+		},
+	}
+	err := validateSplunkRestoreSpec(ctx, cr)
+	if err != nil {
+		t.Errorf("valid restore spec should be accepted. error: %v", err)
+	}
+
+	cr.Spec.BackupName = "../stack1"
+	err = validateSplunkRestoreSpec(ctx, cr)
+	if err == nil {
+		t.Errorf("restore spec with an invalid backup name should be rejected")
+	}
+
+	cr.Spec.BackupName = ""
+	cr.Spec.TargetRef.Kind = "ClusterManager"
+	err = validateSplunkRestoreSpec(ctx, cr)
+	if err == nil {
+		t.Errorf("restore spec with an invalid target kind should be rejected")
+	}
+}
+
+func TestApplySplunkRestore(t *testing.T) {
+	ctx := context.TODO()
+	env := newBackupTestEnv(t)
+	c := spltest.NewMockClient()
+
+	backupName := "stack1-20230102020000"
+	kvStoreArchivePath := filepath.Join(kvStoreBackupDir, backupName+".tar.gz")
+	etcArchivePath := filepath.Join(kvStoreBackupDir, backupName+"-etc.tar.gz")
+	mkdirCmd := fmt.Sprintf("mkdir -p %s", kvStoreBackupDir)
+	extractEtcBackupCmd := fmt.Sprintf(extractEtcBackupCmdStr, etcArchivePath, etcArchivePath)
+	removeBackupFilesCmd := fmt.Sprintf(removeBackupFilesCmdStr, kvStoreArchivePath)
+	env.addPodExecCommands(mkdirCmd, extractEtcBackupCmd, removeBackupFilesCmd)
+	env.remoteDataClient.objects["backups/stack1/"+backupName+"/"+backupName+".tar.gz"] = "kvstore"
+	env.remoteDataClient.objects["backups/stack1/"+backupName+"/"+backupName+"-etc.tar.gz"] = "etc"
+
+	cr := &enterpriseApi.SplunkRestore{
+		TypeMeta:   metav1.TypeMeta{Kind: "SplunkRestore"},
+		ObjectMeta: metav1.ObjectMeta{Name: "restore1", Namespace: "test"},
+		Spec: enterpriseApi.SplunkRestoreSpec{
+			TargetRef:         corev1.ObjectReference{Kind: "SearchHeadCluster", Name: "shc1"},
+			BackupStorageSpec: getBackupStorageSpec(),
+		},
+	}
+	c.Create(ctx, cr)
+
+	// the restore waits for the target to be created
+	result, err := ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhasePending || !strings.Contains(cr.Status.Message, "waiting for SearchHeadCluster shc1") || !result.Requeue {
+		t.Errorf("restore should wait for the target. err: %v, status: %v", err, cr.Status)
+	}
+
+	shc := &enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shc1", Namespace: "test"},
+	}
+	shc.Spec.Replicas = 3
+	shc.Status.Phase = enterpriseApi.PhaseReady
+	shc.Status.Captain = "splunk-shc1-search-head-1"
+	c.Create(ctx, shc)
+
+	captainURI := getSearchHeadURI("test", SplunkSearchHead, "shc1", shc.Status.Captain)
+	env.splunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "POST", URL: captainURI + "/services/kvstore/backup/restore?archiveName=" + backupName + ".tar.gz", Status: 200},
+		getKVStoreStatusHandler(captainURI, "Running"),
+	)
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseInProgress || cr.Status.BackupName != backupName || cr.Status.RestorePod != shc.Status.Captain {
+		t.Errorf("restore should be in progress. err: %v, status: %v", err, cr.Status)
+	}
+	wantCopies := []string{
+		"splunk-shc1-search-head-0:" + backupName + "-etc.tar.gz",
+		"splunk-shc1-search-head-1:" + backupName + "-etc.tar.gz",
+		"splunk-shc1-search-head-2:" + backupName + "-etc.tar.gz",
+		"splunk-shc1-search-head-1:" + backupName + ".tar.gz",
+	}
+	if !reflect.DeepEqual(env.copiedToPods, wantCopies) {
+		t.Errorf("backup archives should be copied to the pods. got %v, want %v", env.copiedToPods, wantCopies)
+	}
+	if !reflect.DeepEqual(env.podExecClient.GotCmdList, []string{mkdirCmd, extractEtcBackupCmd}) {
+		t.Errorf("etc customizations should be extracted on the pods, got commands %v", env.podExecClient.GotCmdList)
+	}
+
+	// the restore is in progress until the KV store restore completes
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseInProgress || result.RequeueAfter != 5*time.Second {
+		t.Errorf("restore should still be in progress. err: %v, status: %v", err, cr.Status)
+	}
+
+	// the search head cluster is restarted once the restore completes
+	env.splunkClient.AddHandlers(
+		getKVStoreStatusHandler(captainURI, "Ready"),
+		spltest.MockHTTPHandler{Method: "POST", URL: captainURI + "/services/shcluster/captain/control/default/restart", Status: 200},
+	)
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || cr.Status.Phase != enterpriseApi.BackupPhaseCompleted || cr.Status.CompletionTime == 0 || result.Requeue {
+		t.Errorf("restore should be complete. err: %v, status: %v", err, cr.Status)
+	}
+	lastRequest := env.splunkClient.GotRequests[len(env.splunkClient.GotRequests)-1]
+	if lastRequest.URL.String() != captainURI+"/services/shcluster/captain/control/default/restart" {
+		t.Errorf("search head cluster should be restarted, got request %s", lastRequest.URL.String())
+	}
+	if len(env.podExecClient.GotCmdList) != 3 || env.podExecClient.GotCmdList[2] != removeBackupFilesCmd {
+		t.Errorf("KV store archive should be removed from the pod, got commands %v", env.podExecClient.GotCmdList)
+	}
+
+	// a restore is applied once
+	requests := len(env.splunkClient.GotRequests)
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err != nil || result.Requeue || len(env.splunkClient.GotRequests) != requests {
+		t.Errorf("completed restore should not be applied again. err: %v", err)
+	}
+
+	// the restore fails when the backup is not found
+	cr.Status = enterpriseApi.SplunkRestoreStatus{}
+	cr.Spec.BackupName = "stack1-20230101020000"
+	result, err = ApplySplunkRestore(ctx, c, cr)
+	if err == nil || cr.Status.Phase != enterpriseApi.BackupPhaseFailed || !strings.Contains(cr.Status.Message, "unable to download the backup") {
+		t.Errorf("restore of a missing backup should fail. err: %v, status: %v", err, cr.Status)
+	}
+}
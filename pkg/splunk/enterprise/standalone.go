@@ -53,7 +53,7 @@ func ApplyStandalone(ctx context.Context, client splcommon.ControllerClient, cr
 
 	var err error
 	// Initialize phase
-	cr.Status.Phase = enterpriseApi.PhaseError
+	lastPhase := initReconcilePhase(&cr.Status.Phase)
 
 	// Update the CR Status
 	defer updateCRStatus(ctx, client, cr, &err)
@@ -116,7 +116,7 @@ func ApplyStandalone(ctx context.Context, client splcommon.ControllerClient, cr
 	// check if deletion has been requested
 	if cr.ObjectMeta.DeletionTimestamp != nil {
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, getStandaloneExtraEnv(cr, cr.Spec.Replicas), false)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), getStandaloneExtraEnv(cr, cr.Spec.Replicas), false)
 			if err != nil {
 				eventPublisher.Warning(ctx, "ApplyMonitoringConsoleEnvConfigMap", fmt.Sprintf("create/update monitoring console config map failed %s", err.Error()))
 				return result, err
@@ -205,7 +205,34 @@ func ApplyStandalone(ctx context.Context, client splcommon.ControllerClient, cr
 		return result, err
 	}
 
-	mgr := splctrl.DefaultStatefulSetPodManager{}
+	// revert the version upgrade when the pods are not ready within the health deadline
+	continueReconcile, err := applyUpgradeRollback(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkStandalone, lastPhase, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// hold the version upgrade until the upgrade policy allows it
+	continueReconcile, err = applyUpgradePolicy(ctx, client, cr, &cr.Spec.CommonSplunkSpec, SplunkStandalone, lastPhase, &cr.Status.Upgrade)
+	if err != nil || !continueReconcile {
+		return result, err
+	}
+
+	// provision the volumes of a new standalone from a snapshot set
+	snapshotTarget := &volumeSnapshotTarget{
+		cr:           cr,
+		commonSpec:   &cr.Spec.CommonSplunkSpec,
+		spec:         &cr.Spec.Snapshots,
+		status:       &cr.Status.Snapshots,
+		instanceType: SplunkStandalone,
+		replicas:     cr.Spec.Replicas,
+	}
+	err = applyVolumeSnapshotRestore(ctx, client, snapshotTarget, statefulSet)
+	if err != nil {
+		eventPublisher.Warning(ctx, "applyVolumeSnapshotRestore", fmt.Sprintf("provision volumes from snapshot set failed %s", err.Error()))
+		return result, err
+	}
+
+	mgr := splctrl.DefaultStatefulSetPodManager{VolumeResize: &cr.Status.VolumeResize}
 	phase, err := mgr.Update(ctx, client, statefulSet, cr.Spec.Replicas)
 	cr.Status.ReadyReplicas = statefulSet.Status.ReadyReplicas
 	if err != nil {
@@ -225,7 +252,7 @@ func ApplyStandalone(ctx context.Context, client splcommon.ControllerClient, cr
 			scopedLog.Error(err, "Error in deleting automated monitoring console resource")
 		}
 		if cr.Spec.MonitoringConsoleRef.Name != "" {
-			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), cr.Spec.MonitoringConsoleRef.Name, getStandaloneExtraEnv(cr, cr.Spec.Replicas), true)
+			_, err = ApplyMonitoringConsoleEnvConfigMap(ctx, client, cr.GetNamespace(), cr.GetName(), getMonitoringConsoleRef(cr.GetNamespace(), cr.Spec.MonitoringConsoleRef), getStandaloneExtraEnv(cr, cr.Spec.Replicas), true)
 			if err != nil {
 				eventPublisher.Warning(ctx, "ApplyMonitoringConsoleEnvConfigMap", fmt.Sprintf("apply monitoring console environment config map failed %s", err.Error()))
 				return result, err
@@ -246,6 +273,14 @@ func ApplyStandalone(ctx context.Context, client splcommon.ControllerClient, cr
 			// Mark telemetry app as installed
 			cr.Status.TelAppInstalled = true
 		}
+
+		// take the scheduled snapshot sets of the volumes
+		requeueAfter, err := applyVolumeSnapshots(ctx, client, snapshotTarget, time.Now())
+		if err != nil {
+			eventPublisher.Warning(ctx, "applyVolumeSnapshots", fmt.Sprintf("apply volume snapshots failed %s", err.Error()))
+			return result, err
+		}
+		setVolumeSnapshotRequeueTime(&result, requeueAfter)
 	}
 	// RequeueAfter if greater than 0, tells the Controller to requeue the reconcile key after the Duration.
 	// Implies that Requeue is true, there is no need to set Requeue to true at the same time as RequeueAfter.
@@ -296,6 +331,11 @@ func validateStandaloneSpec(ctx context.Context, c splcommon.ControllerClient, c
 		}
 	}
 
+	err := validateVolumeSnapshotSpec(&cr.Spec.Snapshots, &cr.Spec.CommonSplunkSpec, SplunkStandalone)
+	if err != nil {
+		return err
+	}
+
 	return validateCommonSplunkSpec(ctx, c, &cr.Spec.CommonSplunkSpec, cr)
 }
 
@@ -195,6 +195,41 @@ func TestApplyStandalone(t *testing.T) {
 	ApplyStandalone(ctx, c, &current)
 }
 
+func TestApplyStandaloneUpgradeComplete(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	_, err := splutil.ApplyNamespaceScopedSecretObject(ctx, c, "test")
+	if err != nil {
+		t.Errorf("Failed to create namespace scoped object")
+	}
+	statefulSet := getUpgradePolicyTestStatefulSet("splunk-stack1-standalone", "splunk/splunk:9.1.2")
+	replicas := int32(1)
+	statefulSet.Spec.Replicas = &replicas
+	c.AddObject(statefulSet)
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta:   metav1.TypeMeta{Kind: "Standalone"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	cr.Spec.Image = "splunk/splunk:9.1.2"
+	cr.Spec.UpgradePolicy.Enabled = true
+	cr.Status.Upgrade.Phase = enterpriseApi.UpgradePhaseUpgrading
+
+	// upgrade is in progress while the last reconcile was not ready
+	cr.Status.Phase = enterpriseApi.PhaseUpdating
+	_, _ = ApplyStandalone(ctx, c, &cr)
+	if cr.Status.Upgrade.Phase != enterpriseApi.UpgradePhaseUpgrading {
+		t.Errorf("upgrade should be in progress. status: %v", cr.Status.Upgrade)
+	}
+
+	// upgrade is complete once the last reconcile is ready with the new image
+	cr.Status.Phase = enterpriseApi.PhaseReady
+	_, _ = ApplyStandalone(ctx, c, &cr)
+	if cr.Status.Upgrade.Phase != enterpriseApi.UpgradePhaseComplete || cr.Status.Upgrade.CompletionTime == 0 {
+		t.Errorf("upgrade should be complete. status: %v", cr.Status.Upgrade)
+	}
+}
+
 func TestApplyStandaloneWithSmartstore(t *testing.T) {
 	ctx := context.TODO()
 	funcCalls := []spltest.MockFuncCall{
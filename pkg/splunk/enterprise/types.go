@@ -88,6 +88,9 @@ type globalResourceTracker struct {
 	storage *storageTracker
 
 	commonResourceTracker *commonResourceTracker
+
+	// app packages downloaded on the operator pod, shared across the CRs
+	appPackageCache *appPackageCache
 }
 
 type storageTracker struct {
@@ -165,6 +168,9 @@ type AppInstallPipeline struct {
 
 	// statefulset to know replicaset details
 	sts *appsv1.StatefulSet
+
+	// app installs and the bundle push are held until a maintenance window opens
+	installHeld bool
 }
 
 // PlaybookImpl is an interface to implement individual playbooks
@@ -30,6 +30,7 @@ var GetClusterInfoCall = func(ctx context.Context, mgr *indexerClusterPodManager
 //     if any one of them not defined, ignore them and wait for the one added in ref
 //  5. Indexer Cluster - same as above also wait for search head cluster to complete before starting upgrade
 //     if its multisite then do 1 site at a time
+//     the upgrade is halted if the upgrade of a tier it waits for failed, with the UpgradeFailed condition
 //     function returns bool and error , true  - go ahead with upgrade
 //     false -  exit the reconciliation loop with error
 func UpgradePathValidation(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec enterpriseApi.CommonSplunkSpec, mgr *indexerClusterPodManager) (bool, error) {
@@ -71,6 +72,12 @@ LicenseManager:
 			return false, err
 		}
 
+		// halt the upgrade when the upgrade of the license manager failed
+		if isUpgradeFailed(licenseManager.Status.Conditions) {
+			eventPublisher.Warning(ctx, "UpgradePathValidation", fmt.Sprintf("Upgrade of License Manager %s failed, halting the upgrade", licenseManager.GetName()))
+			return false, nil
+		}
+
 		// get current image of license manager
 		lmImage, err := getCurrentImage(ctx, c, licenseManager, SplunkLicenseManager)
 		if err != nil {
@@ -116,6 +123,9 @@ ClusterManager:
 		}
 
 		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: clusterManagerRef.Name}
+		if clusterManagerRef.Namespace != "" {
+			namespacedName.Namespace = clusterManagerRef.Namespace
+		}
 		clusterManager := &enterpriseApi.ClusterManager{}
 
 		// get the cluster manager referred in custom resource
@@ -126,6 +136,12 @@ ClusterManager:
 			goto MonitoringConsole
 		}
 
+		// halt the upgrade when the upgrade of the cluster manager failed
+		if isUpgradeFailed(clusterManager.Status.Conditions) {
+			eventPublisher.Warning(ctx, "UpgradePathValidation", fmt.Sprintf("Upgrade of Cluster Manager %s failed, halting the upgrade", clusterManager.GetName()))
+			return false, nil
+		}
+
 		/// get the cluster manager image referred in custom resource
 		cmImage, err := getCurrentImage(ctx, c, clusterManager, SplunkClusterManager)
 		if err != nil {
@@ -212,6 +228,12 @@ SearchHeadCluster:
 			goto IndexerCluster
 		}
 
+		// halt the upgrade when the upgrade of the search head cluster failed
+		if isUpgradeFailed(searchHeadClusterInstance.Status.Conditions) {
+			eventPublisher.Warning(ctx, "UpgradePathValidation", fmt.Sprintf("Upgrade of Search Head Cluster %s failed, halting the upgrade", searchHeadClusterInstance.GetName()))
+			return false, nil
+		}
+
 		shcImage, err := getCurrentImage(ctx, c, &searchHeadClusterInstance, SplunkSearchHead)
 		if err != nil {
 			eventPublisher.Warning(ctx, "UpgradePathValidation", fmt.Sprintf("Could not get the Search Head Cluster Image. Reason %v", err))
@@ -241,10 +263,12 @@ IndexerCluster:
 		}
 		// check if cluster is multisite
 		if clusterInfo.MultiSite == "true" {
-			opts := []rclient.ListOption{
-				rclient.InNamespace(cr.GetNamespace()),
+			// the sites can be located in other namespaces than their cluster manager
+			peerNamespaces, err := getClusterManagerPeerNamespaces(ctx, c, mgr.cr)
+			if err != nil {
+				return false, err
 			}
-			indexerList, err := getIndexerClusterList(ctx, c, cr, opts)
+			indexerList, err := getClusterManagerIndexerClusterList(ctx, c, cr, getClusterManagerNamespace(mgr.cr), spec.ClusterManagerRef.Name, peerNamespaces)
 			if err != nil {
 				return false, err
 			}
@@ -263,8 +287,13 @@ IndexerCluster:
 				}
 			}
 			if len(preIdx.Name) != 0 {
+				// halt the upgrade when the upgrade of the previous site failed
+				if isUpgradeFailed(preIdx.Status.Conditions) {
+					eventPublisher.Warning(ctx, "UpgradePathValidation", fmt.Sprintf("Upgrade of Indexer Cluster %s failed, halting the upgrade", preIdx.GetName()))
+					return false, nil
+				}
 				// check if previous indexer have completed before starting next one
-				image, _ := getCurrentImage(ctx, c, &preIdx, SplunkIndexer)
+				image, _ := getCurrentImage(ctx, c, getIndexerClusterImageCR(&preIdx), SplunkIndexer)
 				if preIdx.Status.Phase != enterpriseApi.PhaseReady || image != spec.Image {
 					return false, nil
 				}
@@ -0,0 +1,474 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	rclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultUpgradeHops are the major.minor version hops supported by Splunk Enterprise, in <from>:<to> format
+var defaultUpgradeHops = []string{
+	"8.1:8.2", "8.1:9.0",
+	"8.2:9.0", "8.2:9.1",
+	"9.0:9.1", "9.0:9.2",
+	"9.1:9.2", "9.1:9.3",
+	"9.2:9.3", "9.2:9.4",
+	"9.3:9.4",
+}
+
+// defaultUpgradePreflightChecks are the preflight checks run before an upgrade, unless listed in the upgrade policy
+var defaultUpgradePreflightChecks = []string{
+	enterpriseApi.UpgradePreflightClusterHealth,
+	enterpriseApi.UpgradePreflightReplicationFactor,
+	enterpriseApi.UpgradePreflightBundlePush,
+	enterpriseApi.UpgradePreflightKVStore,
+}
+
+// splunkVersionRegex matches the Splunk version at the start of an image tag, e.g. 9.1.2 or 9.1.2-a1
+var splunkVersionRegex = regexp.MustCompile(`^(\d+)\.(\d+)(\.\d+)*`)
+
+// getSplunkImageVersion returns the Splunk version of an image, from its tag
+func getSplunkImageVersion(image string) (string, error) {
+	image = strings.SplitN(image, "@", 2)[0]
+	tag := ""
+	if i := strings.LastIndex(image, ":"); i > strings.LastIndex(image, "/") {
+		tag = image[i+1:]
+	}
+	version := splunkVersionRegex.FindString(tag)
+	if version == "" {
+		return "", fmt.Errorf("unable to determine the Splunk version of image %s", image)
+	}
+	return version, nil
+}
+
+// parseSplunkVersion returns the numbers of a Splunk version
+func parseSplunkVersion(version string) []int {
+	var numbers []int
+	for _, field := range strings.Split(version, ".") {
+		n, _ := strconv.Atoi(field)
+		numbers = append(numbers, n)
+	}
+	return numbers
+}
+
+// compareSplunkVersions returns -1, 0 or 1 if the version a is older, the same or newer than the version b
+func compareSplunkVersions(a, b string) int {
+	numbersA := parseSplunkVersion(a)
+	numbersB := parseSplunkVersion(b)
+	for i := 0; i < len(numbersA) || i < len(numbersB); i++ {
+		var x, y int
+		if i < len(numbersA) {
+			x = numbersA[i]
+		}
+		if i < len(numbersB) {
+			y = numbersB[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// getMajorMinorVersion returns the major.minor part of a Splunk version
+func getMajorMinorVersion(version string) string {
+	fields := strings.SplitN(version, ".", 3)
+	if len(fields) < 2 {
+		return version
+	}
+	return fields[0] + "." + fields[1]
+}
+
+// validateUpgradeHop checks an upgrade from the source to the target version is supported by the upgrade policy
+func validateUpgradeHop(policy *enterpriseApi.UpgradePolicySpec, sourceVersion, targetVersion string) error {
+	if compareSplunkVersions(targetVersion, sourceVersion) < 0 {
+		return fmt.Errorf("downgrade from %s to %s is not supported", sourceVersion, targetVersion)
+	}
+
+	from := getMajorMinorVersion(sourceVersion)
+	to := getMajorMinorVersion(targetVersion)
+	if from == to {
+		return nil
+	}
+
+	hops := policy.SupportedHops
+	if len(hops) == 0 {
+		hops = defaultUpgradeHops
+	}
+	if isStringInList(fmt.Sprintf("%s:%s", from, to), hops) {
+		return nil
+	}
+	return fmt.Errorf("upgrade from %s to %s is not a supported version hop, upgrade to an intermediate version first", sourceVersion, targetVersion)
+}
+
+// getUpgradeTier returns the tier of an instance type in the upgrade plan
+func getUpgradeTier(instanceType InstanceType) string {
+	switch instanceType {
+	case SplunkStandalone:
+		return "Standalone"
+	case SplunkLicenseManager:
+		return "LicenseManager"
+	case SplunkClusterManager:
+		return "ClusterManager"
+	case SplunkMonitoringConsole:
+		return "MonitoringConsole"
+	case SplunkSearchHead:
+		return "SearchHeadCluster"
+	case SplunkIndexer:
+		return "IndexerCluster"
+	}
+	return instanceType.ToString()
+}
+
+// getUpgradeTierStatus returns the upgrade status of a tier the instance depends on
+func getUpgradeTierStatus(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, instanceType InstanceType, phase enterpriseApi.Phase, targetImage string) enterpriseApi.UpgradeTierStatus {
+	tierStatus := enterpriseApi.UpgradeTierStatus{
+		Tier:  getUpgradeTier(instanceType),
+		Name:  cr.GetName(),
+		Phase: "Pending",
+	}
+	image, err := getCurrentImage(ctx, c, cr, instanceType)
+	if err == nil && image == targetImage {
+		tierStatus.Phase = "Upgrading"
+		if phase == enterpriseApi.PhaseReady {
+			tierStatus.Phase = "Upgraded"
+		}
+	}
+	return tierStatus
+}
+
+// getUpgradePlan returns the tiers the instance depends on, in the upgrade order: license manager, cluster manager,
+// monitoring console and search head cluster. The tiers which are not deployed are skipped
+func getUpgradePlan(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, instanceType InstanceType) ([]enterpriseApi.UpgradeTierStatus, error) {
+	var plan []enterpriseApi.UpgradeTierStatus
+	if instanceType == SplunkStandalone || instanceType == SplunkLicenseManager {
+		return plan, nil
+	}
+
+	// the tiers can be located in other namespaces
+	getTier := func(ref corev1.ObjectReference, obj rclient.Object) (bool, error) {
+		if ref.Name == "" {
+			return false, nil
+		}
+		namespace := cr.GetNamespace()
+		if ref.Namespace != "" {
+			namespace = ref.Namespace
+		}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: ref.Name}, obj)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	licenseManager := &enterpriseApi.LicenseManager{}
+	found, err := getTier(spec.LicenseManagerRef, licenseManager)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		plan = append(plan, getUpgradeTierStatus(ctx, c, licenseManager, SplunkLicenseManager, licenseManager.Status.Phase, spec.Image))
+	}
+	if instanceType == SplunkClusterManager {
+		return plan, nil
+	}
+
+	clusterManager := &enterpriseApi.ClusterManager{}
+	found, err = getTier(spec.ClusterManagerRef, clusterManager)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		plan = append(plan, getUpgradeTierStatus(ctx, c, clusterManager, SplunkClusterManager, clusterManager.Status.Phase, spec.Image))
+	}
+	if instanceType == SplunkMonitoringConsole {
+		return plan, nil
+	}
+
+	monitoringConsole := &enterpriseApi.MonitoringConsole{}
+	found, err = getTier(spec.MonitoringConsoleRef, monitoringConsole)
+	if err != nil {
+		return nil, err
+	}
+	if found {
+		plan = append(plan, getUpgradeTierStatus(ctx, c, monitoringConsole, SplunkMonitoringConsole, monitoringConsole.Status.Phase, spec.Image))
+	}
+	if instanceType != SplunkIndexer || spec.ClusterManagerRef.Name == "" {
+		return plan, nil
+	}
+
+	// search head cluster of the same cluster manager
+	searchHeadList, err := getSearchHeadClusterList(ctx, c, cr, []rclient.ListOption{rclient.InNamespace(cr.GetNamespace())})
+	if err != nil && err.Error() != "NotFound" {
+		return nil, err
+	}
+	for i := range searchHeadList.Items {
+		shc := &searchHeadList.Items[i]
+		if shc.Spec.ClusterManagerRef.Name == spec.ClusterManagerRef.Name {
+			plan = append(plan, getUpgradeTierStatus(ctx, c, shc, SplunkSearchHead, shc.Status.Phase, spec.Image))
+		}
+	}
+	return plan, nil
+}
+
+// getUpgradePreflightClient returns a SplunkClient for the first pod of an instance
+var getUpgradePreflightClient = func(ctx context.Context, c splcommon.ControllerClient, newSplunkClient NewSplunkClientFunc, namespace string, instanceType InstanceType, name string) *splclient.SplunkClient {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("getUpgradePreflightClient").WithValues("name", name, "namespace", namespace)
+
+	podName := GetSplunkStatefulsetPodName(instanceType, name, 0)
+	fqdnName := splcommon.GetServiceFQDN(namespace, fmt.Sprintf("%s.%s", podName, GetSplunkServiceName(instanceType, name, true)))
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, podName, namespace, "password")
+	if err != nil {
+		scopedLog.Error(err, "Couldn't retrieve the admin password from pod")
+	}
+	return newSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", adminPwd)
+}
+
+// runUpgradePreflightChecks runs the preflight checks of the upgrade policy which apply to the instance. The cluster
+// checks run on the cluster manager of the instance, and the KV store check on the standalone or search head
+func runUpgradePreflightChecks(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, instanceType InstanceType) []enterpriseApi.UpgradePreflightCheckStatus {
+	checks := spec.UpgradePolicy.PreflightChecks
+	if len(checks) == 0 {
+		checks = defaultUpgradePreflightChecks
+	}
+
+	newSplunkClient, clientErr := getSplunkClientFunc(ctx, c, cr, spec)
+
+	// cluster manager of the instance
+	var clusterManager *splclient.SplunkClient
+	switch {
+	case clientErr != nil:
+		// all the checks fail
+	case instanceType == SplunkClusterManager:
+		clusterManager = getUpgradePreflightClient(ctx, c, newSplunkClient, cr.GetNamespace(), SplunkClusterManager, cr.GetName())
+	case spec.ClusterManagerRef.Name != "" && spec.ClusterManagerRef.Namespace != "":
+		clusterManager = getUpgradePreflightClient(ctx, c, newSplunkClient, spec.ClusterManagerRef.Namespace, SplunkClusterManager, spec.ClusterManagerRef.Name)
+	case spec.ClusterManagerRef.Name != "":
+		clusterManager = getUpgradePreflightClient(ctx, c, newSplunkClient, cr.GetNamespace(), SplunkClusterManager, spec.ClusterManagerRef.Name)
+	case spec.ClusterMasterRef.Name != "":
+		clusterManager = getUpgradePreflightClient(ctx, c, newSplunkClient, cr.GetNamespace(), SplunkClusterMaster, spec.ClusterMasterRef.Name)
+	}
+
+	var results []enterpriseApi.UpgradePreflightCheckStatus
+	var health *splclient.ClusterManagerHealth
+	var healthErr error
+	for _, check := range checks {
+		if clientErr != nil {
+			// the checks can not run without a client
+			results = append(results, enterpriseApi.UpgradePreflightCheckStatus{Name: check, Passed: false, Message: clientErr.Error()})
+			continue
+		}
+
+		var err error
+		switch check {
+		case enterpriseApi.UpgradePreflightClusterHealth, enterpriseApi.UpgradePreflightReplicationFactor:
+			if clusterManager == nil {
+				continue
+			}
+			if health == nil && healthErr == nil {
+				health, healthErr = clusterManager.GetClusterManagerHealth()
+			}
+			err = healthErr
+			if err == nil && check == enterpriseApi.UpgradePreflightClusterHealth && health.AllPeersAreUp != "1" {
+				err = fmt.Errorf("not all the peers are up")
+			}
+			if err == nil && check == enterpriseApi.UpgradePreflightReplicationFactor {
+				if health.Multisite == "1" && (health.SiteReplicationFactorMet != "1" || health.SiteSearchFactorMet != "1") {
+					err = fmt.Errorf("site replication or search factor is not met")
+				} else if health.ReplicationFactorMet != "1" || health.SearchFactorMet != "1" {
+					err = fmt.Errorf("replication or search factor is not met")
+				}
+			}
+
+		case enterpriseApi.UpgradePreflightBundlePush:
+			if clusterManager == nil {
+				continue
+			}
+			var clusterInfo *splclient.ClusterManagerInfo
+			clusterInfo, err = clusterManager.GetClusterManagerInfo()
+			if err == nil && clusterInfo.LatestBundle.Checksum != clusterInfo.ActiveBundle.Checksum {
+				err = fmt.Errorf("bundle push is pending")
+			}
+
+		case enterpriseApi.UpgradePreflightKVStore:
+			if instanceType != SplunkStandalone && instanceType != SplunkSearchHead {
+				continue
+			}
+			var kvStoreStatus *splclient.KVStoreStatus
+			kvStoreStatus, err = getUpgradePreflightClient(ctx, c, newSplunkClient, cr.GetNamespace(), instanceType, cr.GetName()).GetKVStoreStatus()
+			if err == nil && kvStoreStatus.Current.Status != "ready" {
+				err = fmt.Errorf("KV store is %s", kvStoreStatus.Current.Status)
+			}
+
+		default:
+			err = fmt.Errorf("unknown preflight check")
+		}
+
+		result := enterpriseApi.UpgradePreflightCheckStatus{Name: check, Passed: err == nil}
+		if err != nil {
+			result.Message = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// setUpgradePhase sets the phase of an upgrade, publishing an event when the phase changes
+func setUpgradePhase(ctx context.Context, eventPublisher *K8EventPublisher, upgradeStatus *enterpriseApi.UpgradeStatus, phase string, message string) {
+	changed := upgradeStatus.Phase != phase || upgradeStatus.Message != message
+	upgradeStatus.Phase = phase
+	upgradeStatus.Message = message
+	if !changed {
+		return
+	}
+
+	switch phase {
+	case enterpriseApi.UpgradePhaseBlocked, enterpriseApi.UpgradePhasePreflightFailed:
+		eventPublisher.Warning(ctx, "UpgradePolicy", fmt.Sprintf("upgrade to %s is held: %s", upgradeStatus.TargetImage, message))
+	case enterpriseApi.UpgradePhaseAwaitingApproval:
+		eventPublisher.Normal(ctx, "UpgradePolicy", fmt.Sprintf("upgrade to %s is awaiting approval: %s", upgradeStatus.TargetImage, message))
+	case enterpriseApi.UpgradePhaseUpgrading:
+		eventPublisher.Normal(ctx, "UpgradePolicy", fmt.Sprintf("upgrading from %s to %s", upgradeStatus.SourceImage, upgradeStatus.TargetImage))
+	case enterpriseApi.UpgradePhaseComplete:
+		eventPublisher.Normal(ctx, "UpgradePolicy", fmt.Sprintf("upgrade to %s is complete", upgradeStatus.TargetImage))
+	}
+}
+
+// applyUpgradePolicy holds the upgrade of the Splunk Enterprise version of an instance until the version hop is
+// supported, the tiers the instance depends on are upgraded, the preflight checks pass and, if required, the
+// upgrade is approved. The upgrade plan and its progress are recorded in the upgrade status. Returns false while
+// the upgrade is held
+func applyUpgradePolicy(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, instanceType InstanceType, phase enterpriseApi.Phase, upgradeStatus *enterpriseApi.UpgradeStatus) (bool, error) {
+	policy := &spec.UpgradePolicy
+	if !policy.Enabled {
+		return true, nil
+	}
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyUpgradePolicy").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+
+	// the instances of a multisite indexer cluster run the same image in all the sites
+	imageCR := cr
+	if idxc, ok := cr.(*enterpriseApi.IndexerCluster); ok {
+		imageCR = getIndexerClusterImageCR(idxc)
+	}
+	currentImage, err := getCurrentImage(ctx, c, imageCR, instanceType)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			// nothing to upgrade in a new deployment
+			return true, nil
+		}
+		return false, err
+	}
+
+	selfTier := enterpriseApi.UpgradeTierStatus{Tier: getUpgradeTier(instanceType), Name: cr.GetName()}
+	if currentImage == spec.Image {
+		if upgradeStatus.Phase == enterpriseApi.UpgradePhaseUpgrading && phase == enterpriseApi.PhaseReady {
+			scopedLog.Info("Upgrade is complete", "image", spec.Image)
+			setUpgradePhase(ctx, eventPublisher, upgradeStatus, enterpriseApi.UpgradePhaseComplete, "")
+			upgradeStatus.CompletionTime = time.Now().Unix()
+			if n := len(upgradeStatus.Plan); n > 0 {
+				upgradeStatus.Plan[n-1].Phase = enterpriseApi.UpgradePhaseComplete
+			}
+		}
+		return true, nil
+	}
+
+	// new upgrade
+	if upgradeStatus.TargetImage != spec.Image || upgradeStatus.SourceImage != currentImage {
+		*upgradeStatus = enterpriseApi.UpgradeStatus{
+			SourceImage: currentImage,
+			TargetImage: spec.Image,
+		}
+	}
+
+	// validate the version hop
+	upgradeStatus.SourceVersion, err = getSplunkImageVersion(currentImage)
+	if err == nil {
+		upgradeStatus.TargetVersion, err = getSplunkImageVersion(spec.Image)
+	}
+	if err == nil {
+		err = validateUpgradeHop(policy, upgradeStatus.SourceVersion, upgradeStatus.TargetVersion)
+	}
+	if err != nil {
+		scopedLog.Info("Upgrade is blocked", "reason", err.Error())
+		upgradeStatus.Plan = []enterpriseApi.UpgradeTierStatus{selfTier}
+		upgradeStatus.Plan[0].Phase = enterpriseApi.UpgradePhaseBlocked
+		setUpgradePhase(ctx, eventPublisher, upgradeStatus, enterpriseApi.UpgradePhaseBlocked, err.Error())
+		return false, nil
+	}
+
+	// wait for the tiers the instance depends on
+	plan, err := getUpgradePlan(ctx, c, cr, spec, instanceType)
+	if err != nil {
+		return false, err
+	}
+	upgradeStatus.Plan = append(plan, selfTier)
+	for _, tierStatus := range plan {
+		if tierStatus.Phase != "Upgraded" {
+			upgradeStatus.Plan[len(plan)].Phase = enterpriseApi.UpgradePhaseWaiting
+			setUpgradePhase(ctx, eventPublisher, upgradeStatus, enterpriseApi.UpgradePhaseWaiting, fmt.Sprintf("waiting for the upgrade of %s %s", tierStatus.Tier, tierStatus.Name))
+			return false, nil
+		}
+	}
+
+	// run the preflight checks
+	upgradeStatus.PreflightChecks = runUpgradePreflightChecks(ctx, c, cr, spec, instanceType)
+	for _, check := range upgradeStatus.PreflightChecks {
+		if !check.Passed {
+			scopedLog.Info("Upgrade preflight check failed", "check", check.Name, "reason", check.Message)
+			upgradeStatus.Plan[len(plan)].Phase = enterpriseApi.UpgradePhasePreflightFailed
+			setUpgradePhase(ctx, eventPublisher, upgradeStatus, enterpriseApi.UpgradePhasePreflightFailed, fmt.Sprintf("preflight check %s failed: %s", check.Name, check.Message))
+			return false, nil
+		}
+	}
+
+	// pause for the approval
+	if policy.PauseForApproval && cr.GetAnnotations()[enterpriseApi.UpgradeApprovalAnnotation] != upgradeStatus.TargetVersion {
+		upgradeStatus.Plan[len(plan)].Phase = enterpriseApi.UpgradePhaseAwaitingApproval
+		setUpgradePhase(ctx, eventPublisher, upgradeStatus, enterpriseApi.UpgradePhaseAwaitingApproval,
+			fmt.Sprintf("set the %s annotation to %s to approve the upgrade", enterpriseApi.UpgradeApprovalAnnotation, upgradeStatus.TargetVersion))
+		return false, nil
+	}
+
+	scopedLog.Info("Starting the upgrade", "sourceImage", currentImage, "targetImage", spec.Image)
+	upgradeStatus.Plan[len(plan)].Phase = enterpriseApi.UpgradePhaseUpgrading
+	upgradeStatus.StartTime = time.Now().Unix()
+	setUpgradePhase(ctx, eventPublisher, upgradeStatus, enterpriseApi.UpgradePhaseUpgrading, "")
+	return true, nil
+}
@@ -0,0 +1,235 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func getUpgradePolicyTestStatefulSet(name string, image string) *appsv1.StatefulSet {
+	return &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Template: corev1.PodTemplateSpec{
+				Spec: corev1.PodSpec{
+					Containers: []corev1.Container{{Name: "splunk", Image: image}},
+				},
+			},
+		},
+	}
+}
+
+func TestGetSplunkImageVersion(t *testing.T) {
+	for image, want := range map[string]string{
+		"splunk/splunk:9.1.2":                       "9.1.2",
+		"splunk/splunk:9.1.2-a1":                    "9.1.2",
+		"registry:5000/splunk/splunk:9.0":           "9.0",
+		"splunk/splunk:9.1.2@sha256:0123456789abcd": "9.1.2",
+		"splunk/splunk:latest":                      "",
+		"registry:5000/splunk/splunk":               "",
+	} {
+		got, err := getSplunkImageVersion(image)
+		if got != want || (want == "") != (err != nil) {
+			t.Errorf("getSplunkImageVersion(%s) = %s, %v; want %s", image, got, err, want)
+		}
+	}
+}
+
+func TestValidateUpgradeHop(t *testing.T) {
+	policy := &enterpriseApi.UpgradePolicySpec{}
+	test := func(source, target string, want bool) {
+		err := validateUpgradeHop(policy, source, target)
+		if (err == nil) != want {
+			t.Errorf("validateUpgradeHop(%s, %s) = %v; want supported %t", source, target, err, want)
+		}
+	}
+
+	test("9.0.5", "9.0.7", true)
+	test("9.0.5", "9.1.2", true)
+	test("9.0.5", "9.2.1", true)
+	test("8.2.9", "9.2.1", false)
+	test("9.1.2", "9.0.5", false)
+	test("9.1.2", "9.1.1", false)
+
+	// hops of the upgrade policy replace the default ones
+	policy.SupportedHops = []string{"8.2:9.2"}
+	test("8.2.9", "9.2.1", true)
+	test("9.0.5", "9.1.2", false)
+}
+
+func TestApplyUpgradePolicy(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	cm := enterpriseApi.ClusterManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "test"},
+	}
+	c.AddObject(&cm)
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-cm1-cluster-manager", "splunk/splunk:9.0.5"))
+
+	cr := enterpriseApi.IndexerCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	cr.Spec.Image = "splunk/splunk:9.1.2"
+	cr.Spec.ClusterManagerRef.Name = "cm1"
+	spec := &cr.Spec.CommonSplunkSpec
+	upgradeStatus := &cr.Status.Upgrade
+
+	// policy is not enabled
+	continueReconcile, err := applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, cr.Status.Phase, upgradeStatus)
+	if err != nil || !continueReconcile || len(c.Calls["Get"]) != 0 {
+		t.Errorf("applyUpgradePolicy() should be skipped without a policy. err: %v", err)
+	}
+
+	// new deployment is not an upgrade
+	spec.UpgradePolicy = enterpriseApi.UpgradePolicySpec{
+		Enabled: true,
+		PreflightChecks: []string{
+			enterpriseApi.UpgradePreflightClusterHealth,
+			enterpriseApi.UpgradePreflightReplicationFactor,
+		},
+		PauseForApproval: true,
+	}
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, cr.Status.Phase, upgradeStatus)
+	if err != nil || !continueReconcile || upgradeStatus.Phase != "" {
+		t.Errorf("applyUpgradePolicy() should not hold a new deployment. err: %v, status: %v", err, upgradeStatus)
+	}
+
+	// unsupported version hop
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-indexer", "splunk/splunk:8.1.14"))
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, cr.Status.Phase, upgradeStatus)
+	if err != nil || continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseBlocked || upgradeStatus.SourceVersion != "8.1.14" || upgradeStatus.TargetVersion != "9.1.2" {
+		t.Errorf("upgrade should be blocked. err: %v, status: %v", err, upgradeStatus)
+	}
+
+	// cluster manager is upgraded first
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-indexer", "splunk/splunk:9.0.5"))
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, cr.Status.Phase, upgradeStatus)
+	if err != nil || continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseWaiting {
+		t.Errorf("upgrade should wait for the cluster manager. err: %v, status: %v", err, upgradeStatus)
+	}
+	if len(upgradeStatus.Plan) != 2 || upgradeStatus.Plan[0].Tier != "ClusterManager" || upgradeStatus.Plan[0].Phase != "Pending" ||
+		upgradeStatus.Plan[1].Tier != "IndexerCluster" || upgradeStatus.Plan[1].Phase != enterpriseApi.UpgradePhaseWaiting {
+		t.Errorf("unexpected upgrade plan %v", upgradeStatus.Plan)
+	}
+
+	// preflight checks run on the cluster manager
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	healthURL := "https://splunk-cm1-cluster-manager-0.splunk-cm1-cluster-manager-headless.test.svc.cluster.local:8089/services/cluster/manager/health?count=0&output_mode=json"
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    healthURL,
+		Status: 200,
+		Body:   `{"entry":[{"name":"manager","content":{"all_peers_are_up":"1","multisite":"0","replication_factor_met":"1","search_factor_met":"0"}}]}`,
+	})
+	savedGetUpgradePreflightClient := getUpgradePreflightClient
+	defer func() { getUpgradePreflightClient = savedGetUpgradePreflightClient }()
+	getUpgradePreflightClient = func(ctx context.Context, c splcommon.ControllerClient, newSplunkClient NewSplunkClientFunc, namespace string, instanceType InstanceType, name string) *splclient.SplunkClient {
+		podName := GetSplunkStatefulsetPodName(instanceType, name, 0)
+		fqdnName := splcommon.GetServiceFQDN(namespace, fmt.Sprintf("%s.%s", podName, GetSplunkServiceName(instanceType, name, true)))
+		sc := splclient.NewSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", "passw0rd")
+		sc.Client = mockSplunkClient
+		return sc
+	}
+
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-cm1-cluster-manager", "splunk/splunk:9.1.2"))
+	cm.Status.Phase = enterpriseApi.PhaseReady
+	c.AddObject(&cm)
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, cr.Status.Phase, upgradeStatus)
+	if err != nil || continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhasePreflightFailed || upgradeStatus.Plan[0].Phase != "Upgraded" {
+		t.Errorf("upgrade preflight checks should fail. err: %v, status: %v", err, upgradeStatus)
+	}
+	if len(upgradeStatus.PreflightChecks) != 2 || !upgradeStatus.PreflightChecks[0].Passed || upgradeStatus.PreflightChecks[1].Passed {
+		t.Errorf("unexpected preflight checks %v", upgradeStatus.PreflightChecks)
+	}
+	mockSplunkClient.CheckRequests(t, "TestApplyUpgradePolicy")
+
+	// upgrade waits for the approval
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    healthURL,
+		Status: 200,
+		Body:   `{"entry":[{"name":"manager","content":{"all_peers_are_up":"1","multisite":"0","replication_factor_met":"1","search_factor_met":"1"}}]}`,
+	})
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, cr.Status.Phase, upgradeStatus)
+	if err != nil || continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseAwaitingApproval {
+		t.Errorf("upgrade should wait for the approval. err: %v, status: %v", err, upgradeStatus)
+	}
+
+	// upgrade starts once approved
+	cr.Annotations = map[string]string{enterpriseApi.UpgradeApprovalAnnotation: "9.1.2"}
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, enterpriseApi.PhaseReady, upgradeStatus)
+	if err != nil || !continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseUpgrading || upgradeStatus.StartTime == 0 {
+		t.Errorf("upgrade should be started. err: %v, status: %v", err, upgradeStatus)
+	}
+
+	// upgrade is complete once the instance is ready with the new image
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-indexer", "splunk/splunk:9.1.2"))
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, enterpriseApi.PhaseUpdating, upgradeStatus)
+	if err != nil || !continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseUpgrading {
+		t.Errorf("upgrade should be in progress. err: %v, status: %v", err, upgradeStatus)
+	}
+	continueReconcile, err = applyUpgradePolicy(ctx, c, &cr, spec, SplunkIndexer, enterpriseApi.PhaseReady, upgradeStatus)
+	if err != nil || !continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseComplete || upgradeStatus.CompletionTime == 0 {
+		t.Errorf("upgrade should be complete. err: %v, status: %v", err, upgradeStatus)
+	}
+}
+
+func TestApplyUpgradePolicyKVStore(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-standalone", "splunk/splunk:9.1.2"))
+
+	cr := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	cr.Spec.Image = "splunk/splunk:9.1.3"
+	cr.Spec.UpgradePolicy.Enabled = true
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "GET",
+		URL:    "https://splunk-stack1-standalone-0.splunk-stack1-standalone-headless.test.svc.cluster.local:8089/services/kvstore/status?count=0&output_mode=json",
+		Status: 200,
+		Body:   `{"entry":[{"name":"status","content":{"current":{"status":"starting"}}}]}`,
+	})
+	savedGetUpgradePreflightClient := getUpgradePreflightClient
+	defer func() { getUpgradePreflightClient = savedGetUpgradePreflightClient }()
+	getUpgradePreflightClient = func(ctx context.Context, c splcommon.ControllerClient, newSplunkClient NewSplunkClientFunc, namespace string, instanceType InstanceType, name string) *splclient.SplunkClient {
+		podName := GetSplunkStatefulsetPodName(instanceType, name, 0)
+		fqdnName := splcommon.GetServiceFQDN(namespace, fmt.Sprintf("%s.%s", podName, GetSplunkServiceName(instanceType, name, true)))
+		sc := splclient.NewSplunkClient(fmt.Sprintf("https://%s:8089", fqdnName), "admin", "passw0rd")
+		sc.Client = mockSplunkClient
+		return sc
+	}
+
+	// cluster checks are skipped without a cluster manager
+	continueReconcile, err := applyUpgradePolicy(ctx, c, &cr, &cr.Spec.CommonSplunkSpec, SplunkStandalone, cr.Status.Phase, &cr.Status.Upgrade)
+	if err != nil || continueReconcile || cr.Status.Upgrade.Phase != enterpriseApi.UpgradePhasePreflightFailed || len(cr.Status.Upgrade.PreflightChecks) != 1 {
+		t.Errorf("KV store preflight check should fail. err: %v, status: %v", err, cr.Status.Upgrade)
+	}
+	mockSplunkClient.CheckRequests(t, "TestApplyUpgradePolicyKVStore")
+}
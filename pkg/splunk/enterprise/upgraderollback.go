@@ -0,0 +1,341 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	rclient "sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// defaultUpgradeHealthDeadline is the time allowed for the pods to be ready with a new image, in seconds
+const defaultUpgradeHealthDeadline = 1800
+
+// getUpgradeStatefulSetNames returns the names of the statefulsets of an instance, one per site for a multisite
+// indexer cluster
+func getUpgradeStatefulSetNames(cr splcommon.MetaObject, instanceType InstanceType) []string {
+	if idxc, ok := cr.(*enterpriseApi.IndexerCluster); ok && len(idxc.Spec.Sites) > 0 {
+		var names []string
+		for _, site := range idxc.Spec.Sites {
+			names = append(names, getIndexerClusterSiteName(idxc.GetName(), site.Name))
+		}
+		return names
+	}
+	return []string{cr.GetName()}
+}
+
+// getUpgradePods returns the pods of the statefulsets of an instance. Returns the images of the statefulsets, and
+// false if a statefulset or a pod is missing
+func getUpgradePods(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, instanceType InstanceType) ([]string, []corev1.Pod, bool, error) {
+	var images []string
+	var pods []corev1.Pod
+	complete := true
+	for _, name := range getUpgradeStatefulSetNames(cr, instanceType) {
+		statefulSet := &appsv1.StatefulSet{}
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: GetSplunkStatefulsetName(instanceType, name)}
+		err := c.Get(ctx, namespacedName, statefulSet)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				complete = false
+				continue
+			}
+			return nil, nil, false, err
+		}
+		if len(statefulSet.Spec.Template.Spec.Containers) > 0 {
+			images = append(images, statefulSet.Spec.Template.Spec.Containers[0].Image)
+		}
+
+		var replicas int32 = 1
+		if statefulSet.Spec.Replicas != nil {
+			replicas = *statefulSet.Spec.Replicas
+		}
+		for n := int32(0); n < replicas; n++ {
+			pod := corev1.Pod{}
+			namespacedName.Name = GetSplunkStatefulsetPodName(instanceType, name, n)
+			err = c.Get(ctx, namespacedName, &pod)
+			if err != nil {
+				if k8serrors.IsNotFound(err) {
+					complete = false
+					continue
+				}
+				return nil, nil, false, err
+			}
+			pods = append(pods, pod)
+		}
+	}
+	return images, pods, complete, nil
+}
+
+// getPodImage returns the image of the splunk container of a pod
+func getPodImage(pod *corev1.Pod) string {
+	if len(pod.Spec.Containers) > 0 {
+		return pod.Spec.Containers[0].Image
+	}
+	return ""
+}
+
+// isUpgradeFailed returns true if the last upgrade of an instance failed its health checks
+func isUpgradeFailed(conditions []metav1.Condition) bool {
+	return meta.IsStatusConditionTrue(conditions, enterpriseApi.ConditionUpgradeFailed)
+}
+
+// setLastKnownGoodImage records the last image the instances were ready with in an annotation of the custom resource.
+// Only the annotation is patched, the status of the custom resource being updated at the end of the reconcile
+func setLastKnownGoodImage(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, image string) error {
+	if cr.GetAnnotations()[enterpriseApi.LastKnownGoodImageAnnotation] == image {
+		return nil
+	}
+	patched, ok := cr.DeepCopyObject().(splcommon.MetaObject)
+	if !ok {
+		return fmt.Errorf("unable to copy the custom resource %s", cr.GetName())
+	}
+	annotations := patched.GetAnnotations()
+	if annotations == nil {
+		annotations = map[string]string{}
+	}
+	annotations[enterpriseApi.LastKnownGoodImageAnnotation] = image
+	patched.SetAnnotations(annotations)
+	err := c.Patch(ctx, patched, rclient.MergeFrom(cr))
+	if err != nil {
+		return err
+	}
+	cr.SetAnnotations(annotations)
+	if patched.GetResourceVersion() != "" {
+		cr.SetResourceVersion(patched.GetResourceVersion())
+	}
+	return nil
+}
+
+// prepareUpgradeRecycle prepares a pod with the failed image to be recycled. The peers of an indexer cluster are
+// decommissioned first, as when their pods are recycled for an update. Returns true when the pod can be deleted
+func prepareUpgradeRecycle(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, instanceType InstanceType, pod *corev1.Pod) (bool, error) {
+	idxc, ok := cr.(*enterpriseApi.IndexerCluster)
+	if !ok || instanceType != SplunkIndexer {
+		return true, nil
+	}
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("prepareUpgradeRecycle").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	// indexer cluster of the site of the pod
+	peerCR := idxc.DeepCopy()
+	for i := range idxc.Spec.Sites {
+		siteCR := getIndexerClusterSite(idxc, &idxc.Spec.Sites[i])
+		if strings.HasPrefix(pod.GetName(), GetSplunkStatefulsetName(SplunkIndexer, siteCR.GetName())+"-") {
+			peerCR = siteCR
+			break
+		}
+	}
+	n, err := strconv.Atoi(pod.GetName()[strings.LastIndex(pod.GetName(), "-")+1:])
+	if err != nil {
+		return false, fmt.Errorf("unable to get the ordinal of pod %s", pod.GetName())
+	}
+
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, peerCR, &peerCR.Spec.CommonSplunkSpec)
+	if err != nil {
+		return false, err
+	}
+	mgr := newIndexerClusterPodManager(scopedLog, peerCR, nil, newSplunkClient)
+	mgr.c = c
+	peers, err := GetClusterManagerPeersCall(ctx, &mgr)
+	if err != nil {
+		return false, err
+	}
+	peerInfo, ok := peers[pod.GetName()]
+	if !ok {
+		// not a peer of the indexer cluster, nothing to decommission
+		return true, nil
+	}
+	for int32(len(peerCR.Status.Peers)) <= int32(n) {
+		peerCR.Status.Peers = append(peerCR.Status.Peers, enterpriseApi.IndexerClusterMemberStatus{})
+	}
+	peerCR.Status.Peers[n] = enterpriseApi.IndexerClusterMemberStatus{
+		Name:   pod.GetName(),
+		ID:     peerInfo.ID,
+		Status: peerInfo.Status,
+	}
+	return mgr.PrepareRecycle(ctx, int32(n))
+}
+
+// rollbackUpgrade reverts the statefulsets of an instance to the last known good image, and recycles the pods still
+// running the failed image, one at a time once the recycled pods are ready, starting with the pods which are not
+// ready. Returns true once all the pods run the last known good image
+func rollbackUpgrade(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, instanceType InstanceType, image string) (bool, error) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("rollbackUpgrade").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+
+	for _, name := range getUpgradeStatefulSetNames(cr, instanceType) {
+		statefulSet := &appsv1.StatefulSet{}
+		namespacedName := types.NamespacedName{Namespace: cr.GetNamespace(), Name: GetSplunkStatefulsetName(instanceType, name)}
+		err := c.Get(ctx, namespacedName, statefulSet)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				continue
+			}
+			return false, err
+		}
+		if len(statefulSet.Spec.Template.Spec.Containers) == 0 || statefulSet.Spec.Template.Spec.Containers[0].Image == image {
+			continue
+		}
+		scopedLog.Info("Reverting statefulset image", "statefulSet", statefulSet.GetName(), "image", image)
+		statefulSet.Spec.Template.Spec.Containers[0].Image = image
+		err = splutil.UpdateResource(ctx, c, statefulSet)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	_, pods, _, err := getUpgradePods(ctx, c, cr, instanceType)
+	if err != nil {
+		return false, err
+	}
+	var recycle *corev1.Pod
+	for i := range pods {
+		if getPodImage(&pods[i]) == image {
+			if !isPodReady(&pods[i]) {
+				// wait for the recycled pod to be ready before recycling the next one
+				scopedLog.Info("Waiting for the pod with the last known good image to be ready", "pod", pods[i].GetName())
+				return false, nil
+			}
+			continue
+		}
+		if recycle == nil || !isPodReady(&pods[i]) {
+			recycle = &pods[i]
+		}
+	}
+	if recycle == nil {
+		return true, nil
+	}
+
+	ready, err := prepareUpgradeRecycle(ctx, c, cr, instanceType, recycle)
+	if err != nil || !ready {
+		return false, err
+	}
+
+	// deleted pod is recreated by the statefulset with the last known good image
+	scopedLog.Info("Recycling pod with the failed image", "pod", recycle.GetName())
+	err = c.Delete(ctx, recycle)
+	if err != nil && !k8serrors.IsNotFound(err) {
+		return false, err
+	}
+	return false, nil
+}
+
+// applyUpgradeRollback reverts the instances to the last known good image when their pods are not ready with a new
+// image within the health deadline of the upgrade policy, and sets the UpgradeFailed condition. The reconcile of the
+// instances is held until the image of the custom resource is changed. Returns false while the upgrade is rolled back
+func applyUpgradeRollback(ctx context.Context, c splcommon.ControllerClient, cr splcommon.MetaObject, spec *enterpriseApi.CommonSplunkSpec, instanceType InstanceType, phase enterpriseApi.Phase, upgradeStatus *enterpriseApi.UpgradeStatus, conditions *[]metav1.Condition) (bool, error) {
+	policy := &spec.UpgradePolicy
+	if !policy.AutoRollback {
+		return true, nil
+	}
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyUpgradeRollback").WithValues("name", cr.GetName(), "namespace", cr.GetNamespace())
+	eventPublisher, _ := newK8EventPublisher(c, cr)
+	lastKnownGoodImage := cr.GetAnnotations()[enterpriseApi.LastKnownGoodImageAnnotation]
+
+	// failed image is held until the image of the custom resource is changed
+	if upgradeStatus.Phase == enterpriseApi.UpgradePhaseRolledBack && upgradeStatus.TargetImage == spec.Image {
+		_, err := rollbackUpgrade(ctx, c, cr, instanceType, upgradeStatus.SourceImage)
+		return false, err
+	}
+
+	images, pods, complete, err := getUpgradePods(ctx, c, cr, instanceType)
+	if err != nil || len(images) == 0 {
+		// nothing to check in a new deployment
+		return true, err
+	}
+	for _, image := range images {
+		if image != spec.Image {
+			// statefulsets are not updated to the image yet
+			return true, nil
+		}
+	}
+
+	healthy := complete && phase == enterpriseApi.PhaseReady
+	for i := range pods {
+		if getPodImage(&pods[i]) != spec.Image || !isPodReady(&pods[i]) {
+			healthy = false
+		}
+	}
+	if healthy {
+		if meta.FindStatusCondition(*conditions, enterpriseApi.ConditionUpgradeFailed) != nil {
+			meta.SetStatusCondition(conditions, metav1.Condition{
+				Type:    enterpriseApi.ConditionUpgradeFailed,
+				Status:  metav1.ConditionFalse,
+				Reason:  "Healthy",
+				Message: fmt.Sprintf("pods are ready with image %s", spec.Image),
+			})
+		}
+		if !policy.Enabled && upgradeStatus.Phase == enterpriseApi.UpgradePhaseUpgrading {
+			upgradeStatus.Phase = enterpriseApi.UpgradePhaseComplete
+			upgradeStatus.CompletionTime = time.Now().Unix()
+		}
+		return true, setLastKnownGoodImage(ctx, c, cr, spec.Image)
+	}
+	if lastKnownGoodImage == "" || lastKnownGoodImage == spec.Image {
+		// no image to revert to
+		return true, nil
+	}
+
+	// new upgrade, the health deadline starts when the statefulsets are updated to the image
+	if upgradeStatus.TargetImage != spec.Image || upgradeStatus.StartTime == 0 {
+		*upgradeStatus = enterpriseApi.UpgradeStatus{
+			Phase:       enterpriseApi.UpgradePhaseUpgrading,
+			SourceImage: lastKnownGoodImage,
+			TargetImage: spec.Image,
+			StartTime:   time.Now().Unix(),
+		}
+		return true, nil
+	}
+
+	deadline := int64(policy.HealthDeadlineSeconds)
+	if deadline == 0 {
+		deadline = defaultUpgradeHealthDeadline
+	}
+	if time.Now().Unix()-upgradeStatus.StartTime < deadline {
+		return true, nil
+	}
+
+	message := fmt.Sprintf("pods were not ready with image %s within %d seconds, reverted to image %s", spec.Image, deadline, lastKnownGoodImage)
+	scopedLog.Info("Upgrade failed its health checks, rolling back", "image", spec.Image, "lastKnownGoodImage", lastKnownGoodImage)
+	eventPublisher.Warning(ctx, "UpgradeFailed", message)
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:    enterpriseApi.ConditionUpgradeFailed,
+		Status:  metav1.ConditionTrue,
+		Reason:  "HealthDeadlineExceeded",
+		Message: message,
+	})
+	upgradeStatus.Phase = enterpriseApi.UpgradePhaseRolledBack
+	upgradeStatus.SourceImage = lastKnownGoodImage
+	upgradeStatus.CompletionTime = time.Now().Unix()
+	upgradeStatus.Message = message
+	_, err = rollbackUpgrade(ctx, c, cr, instanceType, lastKnownGoodImage)
+	return false, err
+}
@@ -0,0 +1,275 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-logr/logr"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func getUpgradeRollbackTestPod(image string, ready bool) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack1-standalone-0", Namespace: "test"},
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{Name: "splunk", Image: image}},
+		},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+		},
+	}
+}
+
+func TestApplyUpgradeRollback(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	cr := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	cr.Spec.Image = "splunk/splunk:9.1.2"
+	cr.Spec.UpgradePolicy.AutoRollback = true
+	cr.Spec.UpgradePolicy.HealthDeadlineSeconds = 60
+	c.AddObject(&cr)
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-standalone", "splunk/splunk:9.1.2"))
+	c.AddObject(getUpgradeRollbackTestPod("splunk/splunk:9.1.2", true))
+	spec := &cr.Spec.CommonSplunkSpec
+	upgradeStatus := &cr.Status.Upgrade
+	conditions := &cr.Status.Conditions
+
+	// last known good image is recorded once the pods are ready
+	continueReconcile, err := applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseReady, upgradeStatus, conditions)
+	if err != nil || !continueReconcile || cr.GetAnnotations()[enterpriseApi.LastKnownGoodImageAnnotation] != "splunk/splunk:9.1.2" {
+		t.Errorf("last known good image should be recorded. err: %v, annotations: %v", err, cr.GetAnnotations())
+	}
+	if len(c.Calls["Patch"]) != 1 || len(c.Calls["Update"]) != 0 {
+		t.Errorf("only the annotation of the custom resource should be patched. patch: %d, update: %d", len(c.Calls["Patch"]), len(c.Calls["Update"]))
+	}
+
+	// health deadline starts once the statefulset is updated to the new image
+	cr.Spec.Image = "splunk/splunk:9.1.3"
+	continueReconcile, err = applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseReady, upgradeStatus, conditions)
+	if err != nil || !continueReconcile || upgradeStatus.StartTime != 0 {
+		t.Errorf("upgrade should not be tracked before the statefulset is updated. err: %v, status: %v", err, upgradeStatus)
+	}
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-stack1-standalone", "splunk/splunk:9.1.3"))
+	c.AddObject(getUpgradeRollbackTestPod("splunk/splunk:9.1.3", false))
+	continueReconcile, err = applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseUpdating, upgradeStatus, conditions)
+	if err != nil || !continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseUpgrading || upgradeStatus.StartTime == 0 ||
+		upgradeStatus.SourceImage != "splunk/splunk:9.1.2" || upgradeStatus.TargetImage != "splunk/splunk:9.1.3" {
+		t.Errorf("upgrade should be tracked. err: %v, status: %v", err, upgradeStatus)
+	}
+	continueReconcile, err = applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseUpdating, upgradeStatus, conditions)
+	if err != nil || !continueReconcile {
+		t.Errorf("upgrade should not be rolled back before the health deadline. err: %v", err)
+	}
+
+	// statefulset is reverted once the health deadline is exceeded
+	upgradeStatus.StartTime -= 120
+	continueReconcile, err = applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseUpdating, upgradeStatus, conditions)
+	if err != nil || continueReconcile || upgradeStatus.Phase != enterpriseApi.UpgradePhaseRolledBack || !isUpgradeFailed(*conditions) {
+		t.Errorf("upgrade should be rolled back. err: %v, status: %v, conditions: %v", err, upgradeStatus, conditions)
+	}
+	statefulSet := &appsv1.StatefulSet{}
+	_ = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-standalone"}, statefulSet)
+	if statefulSet.Spec.Template.Spec.Containers[0].Image != "splunk/splunk:9.1.2" {
+		t.Errorf("statefulset should be reverted to the last known good image, got %s", statefulSet.Spec.Template.Spec.Containers[0].Image)
+	}
+	if len(c.Calls["Delete"]) != 1 {
+		t.Errorf("pod with the failed image should be recycled")
+	}
+
+	// failed image is held until the image is changed
+	c.AddObject(getUpgradeRollbackTestPod("splunk/splunk:9.1.2", true))
+	continueReconcile, err = applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseReady, upgradeStatus, conditions)
+	if err != nil || continueReconcile || len(c.Calls["Delete"]) != 1 {
+		t.Errorf("failed image should be held. err: %v", err)
+	}
+	cr.Spec.Image = "splunk/splunk:9.1.2"
+	continueReconcile, err = applyUpgradeRollback(ctx, c, &cr, spec, SplunkStandalone, enterpriseApi.PhaseReady, upgradeStatus, conditions)
+	if err != nil || !continueReconcile || isUpgradeFailed(*conditions) || meta.FindStatusCondition(*conditions, enterpriseApi.ConditionUpgradeFailed) == nil {
+		t.Errorf("UpgradeFailed condition should be cleared. err: %v, conditions: %v", err, conditions)
+	}
+}
+
+func TestRollbackUpgradeOneAtATime(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	cr := enterpriseApi.Standalone{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test"},
+	}
+	var replicas int32 = 2
+	statefulSet := getUpgradePolicyTestStatefulSet("splunk-stack1-standalone", "splunk/splunk:9.1.2")
+	statefulSet.Spec.Replicas = &replicas
+	c.AddObject(statefulSet)
+	recycledPod := getUpgradeRollbackTestPod("splunk/splunk:9.1.2", false)
+	c.AddObject(recycledPod)
+	failedPod := getUpgradeRollbackTestPod("splunk/splunk:9.1.3", true)
+	failedPod.Name = "splunk-stack1-standalone-1"
+	c.AddObject(failedPod)
+
+	// next pod is not recycled while the recycled pod is not ready
+	rolledBack, err := rollbackUpgrade(ctx, c, &cr, SplunkStandalone, "splunk/splunk:9.1.2")
+	if err != nil || rolledBack || len(c.Calls["Delete"]) != 0 {
+		t.Errorf("rollbackUpgrade() should wait for the recycled pod to be ready. err: %v, deletes: %d", err, len(c.Calls["Delete"]))
+	}
+
+	// next pod is recycled once the recycled pod is ready
+	c.AddObject(getUpgradeRollbackTestPod("splunk/splunk:9.1.2", true))
+	rolledBack, err = rollbackUpgrade(ctx, c, &cr, SplunkStandalone, "splunk/splunk:9.1.2")
+	if err != nil || rolledBack || len(c.Calls["Delete"]) != 1 {
+		t.Errorf("rollbackUpgrade() should recycle the pod with the failed image. err: %v, deletes: %d", err, len(c.Calls["Delete"]))
+	}
+}
+
+func TestUpgradePathValidationUpgradeFailed(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	lm := enterpriseApi.LicenseManager{
+		ObjectMeta: metav1.ObjectMeta{Name: "lm1", Namespace: "test"},
+	}
+	lm.Status.Phase = enterpriseApi.PhaseReady
+	meta.SetStatusCondition(&lm.Status.Conditions, metav1.Condition{
+		Type:   enterpriseApi.ConditionUpgradeFailed,
+		Status: metav1.ConditionTrue,
+		Reason: "HealthDeadlineExceeded",
+	})
+	c.AddObject(&lm)
+	c.AddObject(getUpgradePolicyTestStatefulSet("splunk-lm1-license-manager", "splunk/splunk:9.1.2"))
+
+	cr := enterpriseApi.ClusterManager{
+		TypeMeta:   metav1.TypeMeta{Kind: "ClusterManager"},
+		ObjectMeta: metav1.ObjectMeta{Name: "cm1", Namespace: "test"},
+	}
+	cr.Spec.Image = "splunk/splunk:9.1.2"
+	cr.Spec.LicenseManagerRef.Name = "lm1"
+
+	// dependent tiers are halted when the upgrade of the license manager failed
+	continueReconcile, err := UpgradePathValidation(ctx, c, &cr, cr.Spec.CommonSplunkSpec, nil)
+	if err != nil || continueReconcile {
+		t.Errorf("UpgradePathValidation() should halt the upgrade. err: %v", err)
+	}
+
+	meta.SetStatusCondition(&lm.Status.Conditions, metav1.Condition{
+		Type:   enterpriseApi.ConditionUpgradeFailed,
+		Status: metav1.ConditionFalse,
+		Reason: "Healthy",
+	})
+	c.AddObject(&lm)
+	continueReconcile, err = UpgradePathValidation(ctx, c, &cr, cr.Spec.CommonSplunkSpec, nil)
+	if err != nil || !continueReconcile {
+		t.Errorf("UpgradePathValidation() should continue. err: %v", err)
+	}
+}
+
+func TestApplyUpgradeRollbackIndexerClusterSites(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	cr := getIndexerClusterSitesTestCR()
+	cr.Spec.Image = "splunk/splunk:9.1.3"
+	cr.Spec.UpgradePolicy.AutoRollback = true
+	cr.Spec.UpgradePolicy.HealthDeadlineSeconds = 60
+	cr.Annotations = map[string]string{enterpriseApi.LastKnownGoodImageAnnotation: "splunk/splunk:9.1.2"}
+	cr.Status.Upgrade = enterpriseApi.UpgradeStatus{
+		Phase:       enterpriseApi.UpgradePhaseUpgrading,
+		SourceImage: "splunk/splunk:9.1.2",
+		TargetImage: "splunk/splunk:9.1.3",
+		StartTime:   time.Now().Unix() - 120,
+	}
+	replicas := int32(1)
+	for _, site := range []string{"site1", "site2"} {
+		statefulSet := getUpgradePolicyTestStatefulSet("splunk-stack1-"+site+"-indexer", "splunk/splunk:9.1.3")
+		statefulSet.Spec.Replicas = &replicas
+		c.AddObject(statefulSet)
+		pod := getUpgradeRollbackTestPod("splunk/splunk:9.1.3", false)
+		pod.ObjectMeta.Name = "splunk-stack1-" + site + "-indexer-0"
+		c.AddObject(pod)
+	}
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{
+		Method: "POST",
+		URL:    "https://splunk-stack1-site2-indexer-0.splunk-stack1-site2-indexer-headless.test.svc.cluster.local:8089/services/cluster/peer/control/control/decommission?enforce_counts=0",
+		Status: 200,
+		Body:   ``,
+	})
+	savedNewIndexerClusterPodManager := newIndexerClusterPodManager
+	savedGetClusterManagerPeersCall := GetClusterManagerPeersCall
+	defer func() {
+		newIndexerClusterPodManager = savedNewIndexerClusterPodManager
+		GetClusterManagerPeersCall = savedGetClusterManagerPeersCall
+	}()
+	newIndexerClusterPodManager = func(log logr.Logger, cr *enterpriseApi.IndexerCluster, secret *corev1.Secret, newSplunkClient NewSplunkClientFunc) indexerClusterPodManager {
+		return indexerClusterPodManager{
+			log: log,
+			cr:  cr,
+			newSplunkClient: func(managementURI, username, password string) *splclient.SplunkClient {
+				sc := splclient.NewSplunkClient(managementURI, username, password)
+				sc.Client = mockSplunkClient
+				return sc
+			},
+		}
+	}
+	peerStatus := "Up"
+	GetClusterManagerPeersCall = func(ctx context.Context, mgr *indexerClusterPodManager) (map[string]splclient.ClusterManagerPeerInfo, error) {
+		return map[string]splclient.ClusterManagerPeerInfo{
+			"splunk-stack1-site1-indexer-0": {ID: "site1-peer", Status: peerStatus},
+			"splunk-stack1-site2-indexer-0": {ID: "site2-peer", Status: peerStatus},
+		}, nil
+	}
+
+	// statefulsets of the sites are reverted, and the peer is decommissioned before its pod is recycled
+	spec := &cr.Spec.CommonSplunkSpec
+	continueReconcile, err := applyUpgradeRollback(ctx, c, cr, spec, SplunkIndexer, enterpriseApi.PhaseUpdating, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || continueReconcile || cr.Status.Upgrade.Phase != enterpriseApi.UpgradePhaseRolledBack {
+		t.Errorf("upgrade should be rolled back. err: %v, status: %v", err, cr.Status.Upgrade)
+	}
+	for _, site := range []string{"site1", "site2"} {
+		statefulSet := &appsv1.StatefulSet{}
+		_ = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "splunk-stack1-" + site + "-indexer"}, statefulSet)
+		if statefulSet.Spec.Template.Spec.Containers[0].Image != "splunk/splunk:9.1.2" {
+			t.Errorf("statefulset of %s should be reverted, got %s", site, statefulSet.Spec.Template.Spec.Containers[0].Image)
+		}
+	}
+	if len(c.Calls["Delete"]) != 0 {
+		t.Errorf("pod should not be recycled before the peer is decommissioned")
+	}
+	mockSplunkClient.CheckRequests(t, "TestApplyUpgradeRollbackIndexerClusterSites")
+	if len(cr.Status.Peers) != 0 {
+		t.Errorf("peer status of the indexer cluster should not be changed. peers: %v", cr.Status.Peers)
+	}
+
+	// pod is recycled once the peer is decommissioned
+	peerStatus = "Down"
+	continueReconcile, err = applyUpgradeRollback(ctx, c, cr, spec, SplunkIndexer, enterpriseApi.PhaseUpdating, &cr.Status.Upgrade, &cr.Status.Conditions)
+	if err != nil || continueReconcile || len(c.Calls["Delete"]) != 1 {
+		t.Errorf("pod should be recycled once the peer is decommissioned. err: %v", err)
+	}
+}
@@ -25,6 +25,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
@@ -71,6 +72,9 @@ func initGlobalResourceTracker() {
 
 	// initialize the resource tracker
 	initCommonResourceTracker()
+
+	// initialize the app package cache
+	initAppPackageCache()
 }
 
 func initCommonResourceTracker() {
@@ -129,6 +133,23 @@ func updateStorageTracker(ctx context.Context) error {
 	}()
 }
 
+// getRemoteAppSrcPrefix returns the prefix of the app source location in the bucket
+func getRemoteAppSrcPrefix(vol *enterpriseApi.VolumeSpec, location string) string {
+	bucket := strings.Split(vol.Path, "/")[0]
+
+	//Get the prefix from the "path" field
+	basePrefix := strings.TrimPrefix(vol.Path, bucket+"/")
+	// if vol.Path contains just the bucket name(i.e without ending "/"), TrimPrefix returns the vol.Path
+	// So, just reset the basePrefix to null
+	if basePrefix == bucket {
+		basePrefix = ""
+	}
+
+	// Join takes care of merging two paths and returns a clean result
+	// Ex. ("a/b" + "c"),  ("a/b/" + "c"),  ("a/b/" + "/c"),  ("a/b/" + "/c"), ("a/b//", + "c/././") ("a/b/../b", + "c/../c") all are joined as "a/b/c"
+	return filepath.Join(basePrefix, location) + "/"
+}
+
 // GetRemoteStorageClient returns the corresponding RemoteDataClient
 func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClient, cr splcommon.MetaObject, appFrameworkRef *enterpriseApi.AppFrameworkSpec, vol *enterpriseApi.VolumeSpec, location string, fn splclient.GetInitFunc) (splclient.SplunkRemoteDataClient, error) {
 
@@ -178,17 +199,7 @@ func GetRemoteStorageClient(ctx context.Context, client splcommon.ControllerClie
 	// Get the bucket name form the "path" field
 	bucket := strings.Split(vol.Path, "/")[0]
 
-	//Get the prefix from the "path" field
-	basePrefix := strings.TrimPrefix(vol.Path, bucket+"/")
-	// if vol.Path contains just the bucket name(i.e without ending "/"), TrimPrefix returns the vol.Path
-	// So, just reset the basePrefix to null
-	if basePrefix == bucket {
-		basePrefix = ""
-	}
-
-	// Join takes care of merging two paths and returns a clean result
-	// Ex. ("a/b" + "c"),  ("a/b/" + "c"),  ("a/b/" + "/c"),  ("a/b/" + "/c"), ("a/b//", + "c/././") ("a/b/../b", + "c/../c") all are joined as "a/b/c"
-	prefix := filepath.Join(basePrefix, location) + "/"
+	prefix := getRemoteAppSrcPrefix(vol, location)
 
 	scopedLog.Info("Creating the client", "volume", vol.Name, "bucket", bucket, "bucket path", prefix)
 
@@ -370,6 +381,8 @@ func appPhaseStatusAsStr(status enterpriseApi.AppPhaseStatusType) string {
 		return "Download In Progress"
 	case enterpriseApi.AppPkgDownloadComplete:
 		return "Download Complete"
+	case enterpriseApi.AppPkgValidationError:
+		return "Validation Error"
 	case enterpriseApi.AppPkgDownloadError:
 		return "Download Error"
 	case enterpriseApi.AppPkgPodCopyPending:
@@ -386,6 +399,8 @@ func appPhaseStatusAsStr(status enterpriseApi.AppPhaseStatusType) string {
 		return "Install In Progress"
 	case enterpriseApi.AppPkgInstallComplete:
 		return "Install Complete"
+	case enterpriseApi.AppPkgInstallDependencyError:
+		return "Install Dependency Error"
 	case enterpriseApi.AppPkgInstallError:
 		return "Install Error"
 	default:
@@ -890,6 +905,9 @@ func GetAppListFromRemoteBucket(ctx context.Context, client splcommon.Controller
 			continue
 		}
 
+		// group the objects of the exploded app directories
+		remoteDataListResponse.Objects = getAppPackagesFromRemoteListing(getRemoteAppSrcPrefix(&vol, appSource.Location), remoteDataListResponse.Objects)
+
 		sourceToAppListMap[appSource.Name] = remoteDataListResponse
 	}
 
@@ -900,10 +918,72 @@ func GetAppListFromRemoteBucket(ctx context.Context, client splcommon.Controller
 	return sourceToAppListMap, err
 }
 
+// getAppPackagesFromRemoteListing returns the app packages from the objects listed under the app source location.
+// Objects directly under the location are the app packages, while the objects in a sub-directory with a
+// default/app.conf make an exploded app, which is listed as a single object with the directory key(ending with "/").
+// ETag of an exploded app is derived from the ETags of all of its objects, so that any change to the app is detected
+func getAppPackagesFromRemoteListing(prefix string, objects []*splclient.RemoteObject) []*splclient.RemoteObject {
+	var packages []*splclient.RemoteObject
+	var dirs []string
+	dirObjects := make(map[string][]*splclient.RemoteObject)
+	for _, obj := range objects {
+		relKey := strings.TrimPrefix(*obj.Key, prefix)
+		// skip the directory markers
+		if relKey == "" || strings.HasSuffix(relKey, "/") {
+			continue
+		}
+
+		idx := strings.Index(relKey, "/")
+		if idx < 0 {
+			packages = append(packages, obj)
+			continue
+		}
+
+		dir := relKey[:idx]
+		if _, ok := dirObjects[dir]; !ok {
+			dirs = append(dirs, dir)
+		}
+		dirObjects[dir] = append(dirObjects[dir], obj)
+	}
+
+	for _, dir := range dirs {
+		dirKey := prefix + dir + "/"
+		isApp := false
+		var size int64
+		var lastModified time.Time
+		for _, obj := range dirObjects[dir] {
+			if *obj.Key == dirKey+appConfFilePath {
+				isApp = true
+			}
+			if obj.Size != nil {
+				size += *obj.Size
+			}
+			if obj.LastModified != nil && obj.LastModified.After(lastModified) {
+				lastModified = *obj.LastModified
+			}
+		}
+
+		// nested objects, which are not part of an app, are ignored
+		if !isApp {
+			continue
+		}
+
+		etag := getExplodedAppEtag(dirKey, dirObjects[dir])
+		packages = append(packages, &splclient.RemoteObject{
+			Key:          &dirKey,
+			Etag:         &etag,
+			Size:         &size,
+			LastModified: &lastModified,
+		})
+	}
+
+	return packages
+}
+
 // checkIfAnAppIsActiveOnRemoteStore checks if the App is listed as part of the AppSrc listing
 func checkIfAnAppIsActiveOnRemoteStore(appName string, list []*splclient.RemoteObject) bool {
 	for i := range list {
-		if strings.HasSuffix(*list[i].Key, appName) {
+		if strings.HasSuffix(strings.TrimSuffix(*list[i].Key, "/"), appName) {
 			return true
 		}
 	}
@@ -976,8 +1056,13 @@ func checkCmRemainingReferences(ctx context.Context, c splcommon.ControllerClien
 		client.InNamespace(cmCr.GetNamespace()),
 	}
 
-	// Look for indexerClusters still holding references to the ClusterManager
-	idxcList, err := getIndexerClusterList(ctx, c, cmCr, listOpts)
+	// Look for indexerClusters still holding references to the ClusterManager, in its namespace and the namespaces
+	// allowed to host its peers
+	var peerNamespaces []string
+	if cm, ok := cmCr.(*enterpriseApi.ClusterManager); ok {
+		peerNamespaces = cm.Spec.AllowedPeerNamespaces
+	}
+	idxcList, err := getClusterManagerIndexerClusterList(ctx, c, cmCr, cmCr.GetNamespace(), cmCr.GetName(), peerNamespaces)
 	if err != nil {
 		if err.Error() != "NotFound" && !k8serrors.IsNotFound(err) {
 			scopedLog.Error(err, "Couldn't retrieve IndexerCluster list")
@@ -1171,7 +1256,8 @@ func handleAppRepoChanges(ctx context.Context, client splcommon.ControllerClient
 		// then set the bundle push state to Pending
 		if appsModified && scope == enterpriseApi.ScopeCluster {
 			appDeployContext.BundlePushStatus.BundlePushStage = enterpriseApi.BundlePushPending
-
+			// the modified bundle needs to be validated again
+			appDeployContext.BundlePushStatus.Validation = enterpriseApi.BundleValidationStatus{}
 		}
 
 		// Finally update the Map entry with latest info
@@ -1181,23 +1267,29 @@ func handleAppRepoChanges(ctx context.Context, client splcommon.ControllerClient
 	return appsModified, err
 }
 
-// isAppExtentionValid checks if an app extention is supported or not
-func isAppExtentionValid(receivedKey string) bool {
-	appExtIdx := strings.LastIndex(receivedKey, ".")
-	if appExtIdx < 0 {
-		return false
+// appPackageExtensions lists the extensions of the supported app package formats. Longer extensions come first, so
+// that a .tar.gz package is not taken as a .gz one
+var appPackageExtensions = []string{".tar.gz", ".tgz", ".spl", ".tar", ".zip"}
+
+// getAppPackageExtension returns the extension of the app package, or an empty string if the format is not supported
+func getAppPackageExtension(appName string) string {
+	for _, ext := range appPackageExtensions {
+		if strings.HasSuffix(appName, ext) && len(appName) > len(ext) {
+			return ext
+		}
 	}
 
-	switch appExt := receivedKey[appExtIdx+1:]; appExt {
-	case "spl":
-		return true
+	return ""
+}
 
-	case "tgz":
-		return true
+// isAppExtentionValid checks if an app extention is supported or not
+func isAppExtentionValid(receivedKey string) bool {
+	return getAppPackageExtension(receivedKey) != ""
+}
 
-	default:
-		return false
-	}
+// isExplodedApp confirms if the app is an exploded app directory on the remote storage, rather than an app package
+func isExplodedApp(appName string) bool {
+	return !isAppExtentionValid(appName)
 }
 
 // AddOrUpdateAppSrcDeploymentInfoList  modifies the App deployment status as perceived from the remote object listing
@@ -1213,13 +1305,20 @@ func AddOrUpdateAppSrcDeploymentInfoList(ctx context.Context, appSrcDeploymentIn
 
 	for _, remoteObj := range remoteS3ObjList {
 		receivedKey := *remoteObj.Key
-		if !isAppExtentionValid(receivedKey) {
+		// exploded app directories are listed with a trailing "/"
+		isDir := strings.HasSuffix(receivedKey, "/")
+		receivedKey = strings.TrimSuffix(receivedKey, "/")
+		if !isDir && !isAppExtentionValid(receivedKey) {
 			scopedLog.Error(nil, "App name Parsing: Ignoring the key with invalid extension", "receivedKey", receivedKey)
 			continue
 		}
 
 		nameAt := strings.LastIndex(receivedKey, "/")
 		appName = receivedKey[nameAt+1:]
+		if isDir && !isExplodedApp(appName) {
+			scopedLog.Error(nil, "App name Parsing: Ignoring the app directory with an app package extension", "receivedKey", receivedKey)
+			continue
+		}
 
 		// Now update App status as seen in the remote listing
 		found = false
@@ -1230,6 +1329,7 @@ func AddOrUpdateAppSrcDeploymentInfoList(ctx context.Context, appSrcDeploymentIn
 				if appList[idx].ObjectHash != *remoteObj.Etag || appList[idx].RepoState == enterpriseApi.RepoStateDeleted {
 					scopedLog.Info("App change detected.  Marking for an update.", "appName", appName)
 					appList[idx].ObjectHash = *remoteObj.Etag
+					appList[idx].PackageSize = 0
 					appList[idx].IsUpdate = true
 					appList[idx].DeployStatus = enterpriseApi.DeployStatusPending
 					appList[idx].PhaseInfo.Phase = enterpriseApi.PhaseDownload
@@ -1342,16 +1442,35 @@ func isAppAlreadyDownloaded(ctx context.Context, downloadWorker *PipelineWorker)
 		return false
 	}
 
+	// the converted app packages are compared with the size recorded after the download, as their size
+	// never matches the size on the remote storage
 	localSize := fileInfo.Size()
-	remoteSize := int64(downloadWorker.appDeployInfo.Size)
-	if localSize != remoteSize {
-		err = fmt.Errorf("local size does not match with size on remote storage. localSize=%d, remoteSize=%d", localSize, remoteSize)
+	expectedSize := int64(downloadWorker.appDeployInfo.Size)
+	if isAppPackageConverted(downloadWorker.appDeployInfo.AppName) {
+		if downloadWorker.appDeployInfo.PackageSize == 0 {
+			scopedLog.Info("App package size is not known, hence downloading it again")
+			return false
+		}
+		expectedSize = int64(downloadWorker.appDeployInfo.PackageSize)
+	}
+
+	if localSize != expectedSize {
+		err = fmt.Errorf("local size does not match with the expected app package size. localSize=%d, expectedSize=%d", localSize, expectedSize)
 		scopedLog.Error(err, "incorrect app size")
 		return false
 	}
 	return true
 }
 
+// isAppPackageConverted checks if the app package on the operator pod is converted from the remote format
+func isAppPackageConverted(appName string) bool {
+	switch getAppPackageExtension(appName) {
+	case ".zip", ".tar":
+		return true
+	}
+	return isExplodedApp(appName)
+}
+
 // SetLastAppInfoCheckTime sets the last check time to current time
 func SetLastAppInfoCheckTime(ctx context.Context, appInfoStatus *enterpriseApi.AppDeploymentContext) {
 	reqLogger := log.FromContext(ctx)
@@ -1813,6 +1932,37 @@ func CopyFileToPod(ctx context.Context, c splcommon.ControllerClient, namespace
 	return podExecClient.RunPodExecCommand(ctx, streamOptions, cmdArr)
 }
 
+// CopyFileFromPod copies a file from the Pod to the operator Pod, streaming the file instead of holding it in memory
+func CopyFileFromPod(ctx context.Context, c splcommon.ControllerClient, namespace string, podName string, srcPath string, destPath string) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("CopyFileFromPod").WithValues("podName", podName, "namespace", namespace).WithValues("srcPath", srcPath, "destPath", destPath)
+
+	// Do not accept relative paths
+	srcPath = path.Clean(srcPath)
+	if !strings.HasPrefix(srcPath, "/") {
+		return fmt.Errorf("relative paths are not supported for source path: %s", srcPath)
+	}
+	destPath = path.Clean(destPath)
+	if !strings.HasPrefix(destPath, "/") {
+		return fmt.Errorf("relative paths are not supported for dest path: %s", destPath)
+	}
+
+	file, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("unable to create the file: %s, error: %s", destPath, err)
+	}
+	defer file.Close()
+
+	stdErr, err := splutil.PodExecStream(ctx, c, podName, namespace, []string{"cat", srcPath}, file)
+	if err != nil {
+		scopedLog.Error(err, "Failed to copy the file from the Pod", "stdErr", stdErr)
+		os.Remove(destPath)
+		return fmt.Errorf("unable to copy the file from the Pod. stdErr: %s, err: %s", stdErr, err)
+	}
+
+	return nil
+}
+
 //go:linkname cpMakeTar k8s.io/kubernetes/pkg/kubectl/cmd/cp.makeTar
 //func cpMakeTar(srcPath, destPath string, writer io.Writer) error
 
@@ -1927,6 +2077,14 @@ func releaseStorage(releaseSize uint64) error {
 	}()
 }
 
+// initReconcilePhase sets the phase of a CR to Error at the start of a reconcile, till the reconcile sets it, and
+// returns the phase of the last reconcile
+func initReconcilePhase(phase *enterpriseApi.Phase) enterpriseApi.Phase {
+	lastPhase := *phase
+	*phase = enterpriseApi.PhaseError
+	return lastPhase
+}
+
 // updateReconcileRequeueTime updates the reconcile requeue result
 func updateReconcileRequeueTime(ctx context.Context, result *reconcile.Result, rqTime time.Duration, requeue bool) {
 	reqLogger := log.FromContext(ctx)
@@ -1969,7 +2127,12 @@ func handleAppFrameworkActivity(ctx context.Context, client splcommon.Controller
 			scopedLog.Error(err, "app framework returned error")
 		}
 		if requeue {
-			updateReconcileRequeueTime(ctx, finalResult, time.Second*5, true)
+			requeueAfter := time.Second * 5
+			// no need to revisit until the maintenance window opens, if all the pending work is held for it
+			if isAppFrameworkWaitingForMaintenanceWindow(appDeployContext) {
+				requeueAfter = getMaintenanceWindowRequeueTime(appDeployContext, time.Now())
+			}
+			updateReconcileRequeueTime(ctx, finalResult, requeueAfter, true)
 		}
 	}
 
@@ -2244,6 +2407,24 @@ func fetchCurrentCRWithStatusUpdate(ctx context.Context, client splcommon.Contro
 		}
 		origCR.(*enterpriseApi.MonitoringConsole).Status.DeepCopyInto(&latestMcCR.Status)
 		return latestMcCR, nil
+
+	case "SplunkBackup":
+		latestBackupCR := &enterpriseApi.SplunkBackup{}
+		err = client.Get(ctx, namespacedName, latestBackupCR)
+		if err != nil {
+			return nil, err
+		}
+		origCR.(*enterpriseApi.SplunkBackup).Status.DeepCopyInto(&latestBackupCR.Status)
+		return latestBackupCR, nil
+
+	case "SplunkRestore":
+		latestRestoreCR := &enterpriseApi.SplunkRestore{}
+		err = client.Get(ctx, namespacedName, latestRestoreCR)
+		if err != nil {
+			return nil, err
+		}
+		origCR.(*enterpriseApi.SplunkRestore).Status.DeepCopyInto(&latestRestoreCR.Status)
+		return latestRestoreCR, nil
 	}
 
 	return nil, fmt.Errorf("invalid CR Kind")
@@ -2368,3 +2549,63 @@ func changeAnnotations(ctx context.Context, c splcommon.ControllerClient, image
 	err := c.Update(ctx, cr)
 	return err
 }
+
+// getCustomResourcePodPrefixes returns the prefixes of the names of the pods of a Splunk custom resource, which are
+// named after its statefulsets
+func getCustomResourcePodPrefixes(ctx context.Context, c splcommon.ControllerClient, namespace, kind, name string) ([]string, error) {
+	var instanceType InstanceType
+	names := []string{name}
+	switch kind {
+	case "Standalone":
+		instanceType = SplunkStandalone
+	case "ClusterManager":
+		instanceType = SplunkClusterManager
+	case "SearchHeadCluster":
+		instanceType = SplunkSearchHead
+	case "LicenseManager":
+		instanceType = SplunkLicenseManager
+	case "MonitoringConsole":
+		instanceType = SplunkMonitoringConsole
+	case "IndexerCluster":
+		instanceType = SplunkIndexer
+		idxc := &enterpriseApi.IndexerCluster{}
+		err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, idxc)
+		if err == nil {
+			names = getUpgradeStatefulSetNames(idxc, SplunkIndexer)
+		} else if !k8serrors.IsNotFound(err) {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported kind %s of %s", kind, name)
+	}
+
+	var prefixes []string
+	for _, name := range names {
+		prefixes = append(prefixes, GetSplunkStatefulsetName(instanceType, name)+"-")
+	}
+	return prefixes, nil
+}
+
+// isCustomResourcePod returns true if podName, e.g. the server name of a Splunk instance, is the name of a pod with one
+// of the prefixes returned by getCustomResourcePodPrefixes
+func isCustomResourcePod(podName string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		ordinal := strings.TrimPrefix(podName, prefix)
+		if _, err := strconv.Atoi(ordinal); err == nil && ordinal != podName {
+			return true
+		}
+	}
+	return false
+}
+
+// isSameStringSet returns true if two lists have the same strings, in any order
+func isSameStringSet(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	sortedA := append([]string{}, a...)
+	sortedB := append([]string{}, b...)
+	sort.Strings(sortedA)
+	sort.Strings(sortedB)
+	return reflect.DeepEqual(sortedA, sortedB)
+}
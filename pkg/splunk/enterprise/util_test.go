@@ -1069,6 +1069,11 @@ func TestAppPhaseStatusAsStr(t *testing.T) {
 		t.Errorf("Got wrong status. Expected status=\"Download Complete\", Got = %s", status)
 	}
 
+	status = appPhaseStatusAsStr(enterpriseApi.AppPkgValidationError)
+	if status != "Validation Error" {
+		t.Errorf("Got wrong status. Expected status=\"Validation Error\", Got = %s", status)
+	}
+
 	status = appPhaseStatusAsStr(enterpriseApi.AppPkgDownloadError)
 	if status != "Download Error" {
 		t.Errorf("Got wrong status. Expected status=\"Download Error\", Got = %s", status)
@@ -1109,6 +1114,11 @@ func TestAppPhaseStatusAsStr(t *testing.T) {
 		t.Errorf("Got wrong status. Expected status=\"Install Complete\", Got = %s", status)
 	}
 
+	status = appPhaseStatusAsStr(enterpriseApi.AppPkgInstallDependencyError)
+	if status != "Install Dependency Error" {
+		t.Errorf("Got wrong status. Expected status=\"Install Dependency Error\", Got = %s", status)
+	}
+
 	status = appPhaseStatusAsStr(enterpriseApi.AppPkgInstallError)
 	if status != "Install Error" {
 		t.Errorf("Got wrong status. Expected status=\"Install Error\", Got = %s", status)
@@ -1183,6 +1193,68 @@ func TestIsAppExtentionValid(t *testing.T) {
 	if isAppExtentionValid("testapp.aspl") || isAppExtentionValid("testapp.ttgz") {
 		t.Errorf("failed to detect invalid app extension")
 	}
+
+	if !isAppExtentionValid("testapp.tar.gz") || !isAppExtentionValid("testapp.tar") || !isAppExtentionValid("testapp.zip") {
+		t.Errorf("failed to detect valid app extension")
+	}
+
+	if isAppExtentionValid("testapp.gz") || isAppExtentionValid(".zip") || isAppExtentionValid("testapp") {
+		t.Errorf("failed to detect invalid app extension")
+	}
+
+	if getAppPackageExtension("testapp.tar.gz") != ".tar.gz" || getAppPackageExtension("testapp.tar") != ".tar" {
+		t.Errorf("failed to get the app package extension")
+	}
+}
+
+func TestGetAppPackagesFromRemoteListing(t *testing.T) {
+	newObj := func(key, etag string) *splclient.RemoteObject {
+		size := int64(10)
+		return &splclient.RemoteObject{Key: &key, Etag: &etag, Size: &size}
+	}
+
+	objects := []*splclient.RemoteObject{
+		newObj("repo/apps/", "dir"),
+		newObj("repo/apps/app1.tgz", "etag1"),
+		newObj("repo/apps/app2/default/app.conf", "etag2"),
+		newObj("repo/apps/app2/bin/run.sh", "etag3"),
+		newObj("repo/apps/docs/readme.txt", "etag4"),
+		newObj("repo/apps/archive/app3.tgz", "etag5"),
+	}
+
+	packages := getAppPackagesFromRemoteListing("repo/apps/", objects)
+	if len(packages) != 2 || *packages[0].Key != "repo/apps/app1.tgz" || *packages[1].Key != "repo/apps/app2/" {
+		t.Fatalf("Got wrong app packages %v", packages)
+	}
+	if *packages[1].Size != 20 {
+		t.Errorf("Got wrong size %d for the exploded app", *packages[1].Size)
+	}
+
+	// ETag changes with any object of the exploded app
+	etag := *packages[1].Etag
+	*objects[3].Etag = "etag3-modified"
+	packages = getAppPackagesFromRemoteListing("repo/apps/", objects)
+	if *packages[1].Etag == etag {
+		t.Errorf("ETag of the exploded app should change when an object is modified")
+	}
+
+	// but not with the listing order
+	etag = *packages[1].Etag
+	objects[2], objects[3] = objects[3], objects[2]
+	packages = getAppPackagesFromRemoteListing("repo/apps/", objects)
+	if *packages[1].Etag != etag {
+		t.Errorf("ETag of the exploded app should not depend on the listing order")
+	}
+
+	// exploded app is added to the app deployment info
+	var appSrcDeploymentInfo enterpriseApi.AppSrcDeployInfo
+	AddOrUpdateAppSrcDeploymentInfoList(context.TODO(), &appSrcDeploymentInfo, packages)
+	if len(appSrcDeploymentInfo.AppDeploymentInfoList) != 2 || appSrcDeploymentInfo.AppDeploymentInfoList[1].AppName != "app2" || appSrcDeploymentInfo.AppDeploymentInfoList[1].ObjectHash != etag {
+		t.Errorf("Got wrong app deployment info %v", appSrcDeploymentInfo.AppDeploymentInfoList)
+	}
+	if !checkIfAnAppIsActiveOnRemoteStore("app2", packages) {
+		t.Errorf("exploded app should be active on the remote store")
+	}
 }
 
 func TestHasAppRepoCheckTimerExpired(t *testing.T) {
@@ -1671,6 +1743,31 @@ func TestCreateDirOnSplunkPods(t *testing.T) {
 
 }
 
+func TestCopyFileFromPod(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	dir := t.TempDir()
+
+	// relative paths are not accepted
+	err := CopyFileFromPod(ctx, c, "test", "splunk-stack1-standalone-0", "var/lib/splunk/kvstorebackup/backup.tar.gz", dir+"/backup.tar.gz")
+	if err == nil {
+		t.Errorf("CopyFileFromPod() should return error for a relative source path")
+	}
+	err = CopyFileFromPod(ctx, c, "test", "splunk-stack1-standalone-0", "/opt/splunk/var/lib/splunk/kvstorebackup/backup.tar.gz", "backup.tar.gz")
+	if err == nil {
+		t.Errorf("CopyFileFromPod() should return error for a relative dest path")
+	}
+
+	// the local file is removed when the copy fails
+	err = CopyFileFromPod(ctx, c, "test", "splunk-stack1-standalone-0", "/opt/splunk/var/lib/splunk/kvstorebackup/backup.tar.gz", dir+"/backup.tar.gz")
+	if err == nil {
+		t.Errorf("CopyFileFromPod() should return error for a missing pod")
+	}
+	if _, statErr := os.Stat(dir + "/backup.tar.gz"); !os.IsNotExist(statErr) {
+		t.Errorf("local file should be removed after a failed copy")
+	}
+}
+
 func TestCopyFileToPod(t *testing.T) {
 	ctx := context.TODO()
 	pod := &corev1.Pod{
@@ -2541,6 +2638,14 @@ func TestGetCleanObjectDigest(t *testing.T) {
 
 }
 
+func TestInitReconcilePhase(t *testing.T) {
+	phase := enterpriseApi.PhaseReady
+	lastPhase := initReconcilePhase(&phase)
+	if lastPhase != enterpriseApi.PhaseReady || phase != enterpriseApi.PhaseError {
+		t.Errorf("initReconcilePhase() returned %s, phase %s", lastPhase, phase)
+	}
+}
+
 func TestUpdateReconcileRequeueTime(t *testing.T) {
 	// this test case for code coverage, function do not return anything
 	//  to test the value
@@ -3200,3 +3305,84 @@ func TestGetCurrentImage(t *testing.T) {
 	}
 
 }
+
+func TestIsAppAlreadyDownloaded(t *testing.T) {
+	ctx := context.TODO()
+
+	defaultVol := splcommon.AppDownloadVolume
+	splcommon.AppDownloadVolume = t.TempDir()
+	defer func() {
+		splcommon.AppDownloadVolume = defaultVol
+	}()
+
+	cr := enterpriseApi.Standalone{
+		TypeMeta: metav1.TypeMeta{
+			Kind: "Standalone",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "stack1",
+			Namespace: "test",
+		},
+	}
+	appFrameworkConfig := &enterpriseApi.AppFrameworkSpec{
+		AppSources: []enterpriseApi.AppSourceSpec{
+			{Name: "appSrc1", Location: "apps"},
+		},
+	}
+
+	worker := &PipelineWorker{
+		cr:         &cr,
+		afwConfig:  appFrameworkConfig,
+		appSrcName: "appSrc1",
+	}
+
+	appPkgLocalDir := getAppPackageLocalDir(&cr, getAppSrcScope(ctx, appFrameworkConfig, "appSrc1"), "appSrc1")
+	err := os.MkdirAll(appPkgLocalDir, 0755)
+	if err != nil {
+		t.Fatalf("Unable to create the directory, error: %v", err)
+	}
+
+	// tgz app package, compared with the size on the remote storage
+	worker.appDeployInfo = &enterpriseApi.AppDeploymentInfo{AppName: "app1.tgz", ObjectHash: "abcd", Size: 4}
+	if isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("App is not present on the operator pod")
+	}
+
+	err = os.WriteFile(getLocalAppFileName(ctx, appPkgLocalDir, "app1.tgz", "abcd"), []byte("1234"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to create the app package, error: %v", err)
+	}
+	if !isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("App with the same size as on the remote storage should be treated as downloaded")
+	}
+
+	// zip app package, converted to a gzipped tarball of a different size
+	worker.appDeployInfo = &enterpriseApi.AppDeploymentInfo{AppName: "app2.zip", ObjectHash: "abcd", Size: 4}
+	err = os.WriteFile(getLocalAppFileName(ctx, appPkgLocalDir, "app2.zip", "abcd"), []byte("123456"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to create the app package, error: %v", err)
+	}
+	if isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("Converted app with an unknown package size should be downloaded again")
+	}
+
+	worker.appDeployInfo.PackageSize = 6
+	if !isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("Converted app with the recorded package size should be treated as downloaded")
+	}
+
+	worker.appDeployInfo.PackageSize = 5
+	if isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("Converted app with a different package size should be downloaded again")
+	}
+
+	// exploded app
+	worker.appDeployInfo = &enterpriseApi.AppDeploymentInfo{AppName: "app3", ObjectHash: "abcd", Size: 100, PackageSize: 3}
+	err = os.WriteFile(getLocalAppFileName(ctx, appPkgLocalDir, "app3", "abcd"), []byte("123"), 0644)
+	if err != nil {
+		t.Fatalf("Unable to create the app package, error: %v", err)
+	}
+	if !isAppAlreadyDownloaded(ctx, worker) {
+		t.Errorf("Exploded app with the recorded package size should be treated as downloaded")
+	}
+}
@@ -0,0 +1,567 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	splutil "github.com/splunk/splunk-operator/pkg/splunk/util"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+// max. time in seconds to wait for the VolumeSnapshots of a snapshot set to be ready to use
+const volumeSnapshotTimeout = 3600
+
+// getVolumeSnapshotSplunkClient returns the client of a pod whose volumes are snapshotted
+var getVolumeSnapshotSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, podName string) (*splclient.SplunkClient, error) {
+	newSplunkClient, err := getSplunkClientFunc(ctx, c, target.cr, target.commonSpec)
+	if err != nil {
+		return nil, err
+	}
+	adminPwd, err := splutil.GetSpecificSecretTokenFromPod(ctx, c, podName, target.cr.GetNamespace(), "password")
+	if err != nil {
+		return nil, err
+	}
+	return newSplunkClient(getSearchHeadURI(target.cr.GetNamespace(), target.instanceType, target.cr.GetName(), podName), "admin", adminPwd), nil
+}
+
+// volumeSnapshotTarget is the Standalone, SearchHeadCluster or IndexerCluster whose pod volumes are snapshotted
+type volumeSnapshotTarget struct {
+	cr           splcommon.MetaObject
+	commonSpec   *enterpriseApi.CommonSplunkSpec
+	spec         *enterpriseApi.VolumeSnapshotSpec
+	status       *enterpriseApi.VolumeSnapshotStatus
+	instanceType InstanceType
+	replicas     int32
+
+	// client of the cluster manager of an indexer cluster, put in maintenance mode while the snapshots are taken
+	getClusterManagerClient func(ctx context.Context) *splclient.SplunkClient
+}
+
+// getVolumeSnapshotTypes returns the volume types snapshotted on each pod, the volumes which are not ephemeral
+func getVolumeSnapshotTypes(spec *enterpriseApi.CommonSplunkSpec) []string {
+	volumeTypes := []string{}
+	if !spec.EtcVolumeStorageConfig.EphemeralStorage {
+		volumeTypes = append(volumeTypes, splcommon.EtcVolumeStorage)
+	}
+	if !spec.VarVolumeStorageConfig.EphemeralStorage {
+		volumeTypes = append(volumeTypes, splcommon.VarVolumeStorage)
+	}
+	return volumeTypes
+}
+
+// getVolumeSnapshotName returns the name of the VolumeSnapshot of a volume of the pod ordinal in a snapshot set
+func getVolumeSnapshotName(setName string, volumeType string, ordinal int32) string {
+	return fmt.Sprintf("%s-%s-%d", setName, volumeType, ordinal)
+}
+
+// getVolumeSnapshotQuiesce returns the quiescing of the pods of the target, MaintenanceMode for an indexer cluster
+// and RollHotBuckets otherwise by default
+func getVolumeSnapshotQuiesce(target *volumeSnapshotTarget) string {
+	if target.spec.Quiesce != "" {
+		return target.spec.Quiesce
+	}
+	if target.instanceType == SplunkIndexer {
+		return enterpriseApi.VolumeSnapshotQuiesceMaintenanceMode
+	}
+	return enterpriseApi.VolumeSnapshotQuiesceRollHotBuckets
+}
+
+// parseVolumeSnapshotSchedule parses the schedule of the snapshot sets as a maintenance window, which opens at the time
+// of each snapshot set. Returns nil when no snapshot is scheduled
+func parseVolumeSnapshotSchedule(spec *enterpriseApi.VolumeSnapshotSpec) (*maintenanceWindow, error) {
+	if spec.Schedule == "" {
+		return nil, nil
+	}
+
+	window, err := parseMaintenanceWindow(&enterpriseApi.MaintenanceWindowSpec{
+		Schedule:        spec.Schedule,
+		DurationMinutes: 1,
+		TimeZone:        spec.TimeZone,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid snapshot schedule. %v", err)
+	}
+	return window, nil
+}
+
+// validateVolumeSnapshotSpec validates the schedule, the quiescing and the volumes of the snapshots
+func validateVolumeSnapshotSpec(spec *enterpriseApi.VolumeSnapshotSpec, commonSpec *enterpriseApi.CommonSplunkSpec, instanceType InstanceType) error {
+	switch spec.Quiesce {
+	case "", enterpriseApi.VolumeSnapshotQuiesceRollHotBuckets, enterpriseApi.VolumeSnapshotQuiesceNone:
+	case enterpriseApi.VolumeSnapshotQuiesceMaintenanceMode:
+		if instanceType != SplunkIndexer {
+			return fmt.Errorf("invalid snapshot quiesce %s, only an indexer cluster can be put in maintenance mode", spec.Quiesce)
+		}
+	default:
+		return fmt.Errorf("invalid snapshot quiesce %s. Valid values are MaintenanceMode, RollHotBuckets and None", spec.Quiesce)
+	}
+
+	_, err := parseVolumeSnapshotSchedule(spec)
+	if err != nil {
+		return err
+	}
+
+	if (spec.Schedule != "" || spec.RestoreFrom != "") && len(getVolumeSnapshotTypes(commonSpec)) == 0 {
+		return fmt.Errorf("snapshots require the etc or the var volume to be persistent")
+	}
+	return nil
+}
+
+// getNextVolumeSnapshotTime returns the time of the next snapshot set, after the last snapshot set or after the
+// custom resource was created
+func getNextVolumeSnapshotTime(target *volumeSnapshotTarget, schedule *maintenanceWindow) time.Time {
+	after := target.cr.GetCreationTimestamp().Time
+	if target.status.StartTime > after.Unix() {
+		after = time.Unix(target.status.StartTime, 0)
+	}
+	return schedule.nextStart(after)
+}
+
+// setVolumeSnapshotRequeueTime requeues the reconcile at the latest after requeueAfter, unless it is zero
+func setVolumeSnapshotRequeueTime(result *reconcile.Result, requeueAfter time.Duration) {
+	if requeueAfter <= 0 {
+		return
+	}
+	if !result.Requeue || result.RequeueAfter == 0 || result.RequeueAfter > requeueAfter {
+		result.Requeue = true
+		result.RequeueAfter = requeueAfter
+	}
+}
+
+// applyVolumeSnapshots takes the scheduled snapshot sets of the etc and var volumes of the pods of a ready custom
+// resource, and returns the time after which the reconcile should be requeued, zero when no snapshot is scheduled.
+// Failures of a snapshot set are reported in its status, errors are returned for a retry
+func applyVolumeSnapshots(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, now time.Time) (time.Duration, error) {
+	status := target.status
+	schedule, err := parseVolumeSnapshotSchedule(target.spec)
+	if err != nil {
+		return 0, err
+	}
+
+	// the cluster manager of a failed snapshot set is released once it is reachable again
+	if status.Quiesced && status.Phase != enterpriseApi.BackupPhaseInProgress {
+		err = releaseVolumeSnapshotTarget(ctx, target)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if status.Phase == enterpriseApi.BackupPhaseInProgress {
+		err = checkVolumeSnapshotSet(ctx, c, target, now)
+	} else if schedule != nil && !getNextVolumeSnapshotTime(target, schedule).After(now) {
+		err = startVolumeSnapshotSet(ctx, c, target, now)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if status.Phase == enterpriseApi.BackupPhaseInProgress {
+		// check the snapshot set in progress
+		return time.Second * 5, nil
+	}
+
+	if schedule == nil {
+		status.NextSnapshotTime = 0
+		return 0, nil
+	}
+	nextSnapshotTime := getNextVolumeSnapshotTime(target, schedule)
+	status.NextSnapshotTime = nextSnapshotTime.Unix()
+	return getWindowRequeueTime(status.NextSnapshotTime, now), nil
+}
+
+// getVolumeSnapshot returns the VolumeSnapshot of a volume of the pod ordinal in a snapshot set
+func getVolumeSnapshot(target *volumeSnapshotTarget, setName string, volumeType string, ordinal int32) *snapshotv1.VolumeSnapshot {
+	podName := GetSplunkStatefulsetPodName(target.instanceType, target.cr.GetName(), ordinal)
+	pvcName := fmt.Sprintf("%s-%s", fmt.Sprintf(splcommon.PvcNamePrefix, volumeType), podName)
+
+	labels := getSplunkLabels(target.cr.GetName(), target.instanceType, "")
+	labels[enterpriseApi.VolumeSnapshotSetLabel] = setName
+
+	snapshot := &snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      getVolumeSnapshotName(setName, volumeType, ordinal),
+			Namespace: target.cr.GetNamespace(),
+			Labels:    labels,
+		},
+		Spec: snapshotv1.VolumeSnapshotSpec{
+			Source: snapshotv1.VolumeSnapshotSource{
+				PersistentVolumeClaimName: &pvcName,
+			},
+		},
+	}
+	if target.spec.VolumeSnapshotClassName != "" {
+		className := target.spec.VolumeSnapshotClassName
+		snapshot.Spec.VolumeSnapshotClassName = &className
+	}
+	return snapshot
+}
+
+// startVolumeSnapshotSet quiesces the pods of the target and creates the VolumeSnapshots of their volumes
+func startVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("startVolumeSnapshotSet").WithValues("name", target.cr.GetName(), "namespace", target.cr.GetNamespace())
+
+	status := target.status
+	set := &enterpriseApi.VolumeSnapshotSetInfo{
+		Name:    fmt.Sprintf("%s-%s", target.cr.GetName(), now.UTC().Format("20060102150405")),
+		Time:    now.Unix(),
+		Pods:    target.replicas,
+		Volumes: getVolumeSnapshotTypes(target.commonSpec),
+	}
+	status.CurrentSet = set
+	status.StartTime = now.Unix()
+	status.Phase = enterpriseApi.BackupPhaseInProgress
+
+	err := quiesceVolumeSnapshotTarget(ctx, c, target)
+	if err != nil {
+		failVolumeSnapshotSet(ctx, c, target, fmt.Sprintf("unable to quiesce the pods. error: %s", err))
+		return nil
+	}
+
+	for i := int32(0); i < set.Pods; i++ {
+		for _, volumeType := range set.Volumes {
+			snapshot := getVolumeSnapshot(target, set.Name, volumeType, i)
+			err = c.Create(ctx, snapshot)
+			if err != nil && !k8serrors.IsAlreadyExists(err) {
+				failVolumeSnapshotSet(ctx, c, target, fmt.Sprintf("unable to create the VolumeSnapshot %s. error: %s", snapshot.GetName(), err))
+				return nil
+			}
+		}
+	}
+
+	scopedLog.Info("Started the snapshot set", "snapshotSet", set.Name, "pods", set.Pods)
+	status.Message = fmt.Sprintf("snapshot set %s in progress", set.Name)
+	return nil
+}
+
+// quiesceVolumeSnapshotTarget puts the indexer cluster in maintenance mode, or rolls the hot buckets of the indexes of
+// the pods, before their volumes are snapshotted
+func quiesceVolumeSnapshotTarget(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget) error {
+	switch getVolumeSnapshotQuiesce(target) {
+	case enterpriseApi.VolumeSnapshotQuiesceMaintenanceMode:
+		if target.getClusterManagerClient == nil {
+			return fmt.Errorf("%s is not an indexer cluster", target.cr.GetName())
+		}
+		err := target.getClusterManagerClient(ctx).SetClusterManagerMaintenanceMode(true)
+		if err != nil {
+			return err
+		}
+		target.status.Quiesced = true
+
+	case enterpriseApi.VolumeSnapshotQuiesceRollHotBuckets:
+		for i := int32(0); i < target.replicas; i++ {
+			podName := GetSplunkStatefulsetPodName(target.instanceType, target.cr.GetName(), i)
+			splunkClient, err := getVolumeSnapshotSplunkClient(ctx, c, target, podName)
+			if err != nil {
+				return err
+			}
+			indexes, err := splunkClient.GetIndexes()
+			if err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(indexes))
+			for name, index := range indexes {
+				if !index.Disabled {
+					names = append(names, name)
+				}
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				err = splunkClient.RollHotBuckets(name)
+				if err != nil {
+					return fmt.Errorf("unable to roll the hot buckets of index %s on pod %s. error: %s", name, podName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// releaseVolumeSnapshotTarget takes the indexer cluster out of maintenance mode, once its volumes are snapshotted
+func releaseVolumeSnapshotTarget(ctx context.Context, target *volumeSnapshotTarget) error {
+	if !target.status.Quiesced {
+		return nil
+	}
+	if target.getClusterManagerClient != nil {
+		err := target.getClusterManagerClient(ctx).SetClusterManagerMaintenanceMode(false)
+		if err != nil {
+			return err
+		}
+	}
+	target.status.Quiesced = false
+	return nil
+}
+
+// checkVolumeSnapshotSet releases the pods once their volumes are snapshotted, and completes the snapshot set once all
+// the VolumeSnapshots are ready to use
+func checkVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, now time.Time) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("checkVolumeSnapshotSet").WithValues("name", target.cr.GetName(), "namespace", target.cr.GetNamespace())
+
+	status := target.status
+	set := status.CurrentSet
+	if set == nil {
+		failVolumeSnapshotSet(ctx, c, target, "the snapshot set in progress is not recorded")
+		return nil
+	}
+
+	snapshotted, ready := true, true
+	snapshotErrors := []string{}
+	for i := int32(0); i < set.Pods; i++ {
+		for _, volumeType := range set.Volumes {
+			snapshot := &snapshotv1.VolumeSnapshot{}
+			namespacedName := types.NamespacedName{Namespace: target.cr.GetNamespace(), Name: getVolumeSnapshotName(set.Name, volumeType, i)}
+			err := c.Get(ctx, namespacedName, snapshot)
+			if k8serrors.IsNotFound(err) {
+				failVolumeSnapshotSet(ctx, c, target, fmt.Sprintf("VolumeSnapshot %s is not found", namespacedName.Name))
+				return nil
+			} else if err != nil {
+				return err
+			}
+
+			if snapshot.Status == nil || snapshot.Status.CreationTime == nil {
+				snapshotted = false
+			}
+			if snapshot.Status == nil || snapshot.Status.ReadyToUse == nil || !*snapshot.Status.ReadyToUse {
+				ready = false
+			}
+			// errors of the snapshot controller may be transient, they fail the snapshot set on timeout
+			if snapshot.Status != nil && snapshot.Status.Error != nil && snapshot.Status.Error.Message != nil {
+				snapshotErrors = append(snapshotErrors, fmt.Sprintf("%s: %s", namespacedName.Name, *snapshot.Status.Error.Message))
+			}
+		}
+	}
+
+	// the pods are released as soon as the point in time of their snapshots is taken, without waiting for the CSI
+	// driver to make the snapshots ready to use
+	if snapshotted {
+		err := releaseVolumeSnapshotTarget(ctx, target)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !ready {
+		if now.Unix()-status.StartTime > volumeSnapshotTimeout {
+			failVolumeSnapshotSet(ctx, c, target, fmt.Sprintf("the VolumeSnapshots are not ready to use after %d seconds. %s", volumeSnapshotTimeout, strings.Join(snapshotErrors, ", ")))
+			return nil
+		}
+		status.Message = fmt.Sprintf("snapshot set %s in progress", set.Name)
+		if len(snapshotErrors) > 0 {
+			status.Message = fmt.Sprintf("snapshot set %s in progress. %s", set.Name, strings.Join(snapshotErrors, ", "))
+		}
+		return nil
+	}
+
+	scopedLog.Info("Completed the snapshot set", "snapshotSet", set.Name)
+	eventPublisher, _ := newK8EventPublisher(c, target.cr)
+	eventPublisher.Normal(ctx, "VolumeSnapshotSet", fmt.Sprintf("snapshot set %s completed", set.Name))
+
+	status.SnapshotSets = append(status.SnapshotSets, *set)
+	status.CurrentSet = nil
+	status.Phase = enterpriseApi.BackupPhaseCompleted
+	status.LastSnapshotTime = now.Unix()
+	status.Message = fmt.Sprintf("snapshot set %s completed", set.Name)
+
+	err := applyVolumeSnapshotRetention(ctx, c, target)
+	if err != nil {
+		scopedLog.Error(err, "Unable to apply the retention of the snapshot sets")
+	}
+	return nil
+}
+
+// failVolumeSnapshotSet releases the pods, deletes the VolumeSnapshots and marks the snapshot set in progress failed
+func failVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, message string) {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("failVolumeSnapshotSet").WithValues("name", target.cr.GetName(), "namespace", target.cr.GetNamespace())
+
+	status := target.status
+	setName := ""
+	if status.CurrentSet != nil {
+		setName = status.CurrentSet.Name
+		err := deleteVolumeSnapshotSet(ctx, c, target.cr.GetNamespace(), status.CurrentSet)
+		if err != nil {
+			scopedLog.Error(err, "Unable to delete the VolumeSnapshots of the failed snapshot set", "snapshotSet", setName)
+		}
+	}
+
+	// the release is retried in the next reconcile if the cluster manager is not reachable
+	err := releaseVolumeSnapshotTarget(ctx, target)
+	if err != nil {
+		scopedLog.Error(err, "Unable to release the pods of the failed snapshot set", "snapshotSet", setName)
+	}
+
+	eventPublisher, _ := newK8EventPublisher(c, target.cr)
+	eventPublisher.Warning(ctx, "VolumeSnapshotSet", fmt.Sprintf("snapshot set %s failed: %s", setName, message))
+
+	status.Phase = enterpriseApi.BackupPhaseFailed
+	status.Message = fmt.Sprintf("snapshot set %s failed: %s", setName, message)
+	status.CurrentSet = nil
+}
+
+// deleteVolumeSnapshotSet deletes the VolumeSnapshots of a snapshot set
+func deleteVolumeSnapshotSet(ctx context.Context, c splcommon.ControllerClient, namespace string, set *enterpriseApi.VolumeSnapshotSetInfo) error {
+	for i := int32(0); i < set.Pods; i++ {
+		for _, volumeType := range set.Volumes {
+			snapshot := &snapshotv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      getVolumeSnapshotName(set.Name, volumeType, i),
+					Namespace: namespace,
+				},
+			}
+			err := c.Delete(ctx, snapshot)
+			if err != nil && !k8serrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// applyVolumeSnapshotRetention deletes the oldest snapshot sets exceeding the maximum number of snapshot sets
+func applyVolumeSnapshotRetention(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyVolumeSnapshotRetention").WithValues("name", target.cr.GetName(), "namespace", target.cr.GetNamespace())
+
+	sets := target.status.SnapshotSets
+	maxSets := int(target.spec.MaxSnapshotSets)
+	if maxSets <= 0 || len(sets) <= maxSets {
+		return nil
+	}
+
+	var err error
+	kept := []enterpriseApi.VolumeSnapshotSetInfo{}
+	for i := range sets[:len(sets)-maxSets] {
+		// the snapshot set is kept, and deleted again after the next snapshot set, if any of its snapshots is not deleted
+		deleteErr := deleteVolumeSnapshotSet(ctx, c, target.cr.GetNamespace(), &sets[i])
+		if deleteErr != nil {
+			scopedLog.Error(deleteErr, "Unable to delete the snapshot set", "snapshotSet", sets[i].Name)
+			kept = append(kept, sets[i])
+			err = deleteErr
+			continue
+		}
+		scopedLog.Info("Deleted the snapshot set", "snapshotSet", sets[i].Name)
+	}
+
+	target.status.SnapshotSets = append(kept, sets[len(sets)-maxSets:]...)
+	return err
+}
+
+// applyVolumeSnapshotRestore provisions the volumes of the pods of a new custom resource from the snapshot set
+// restoreFrom, by creating their PVCs from the VolumeSnapshots before the StatefulSet creates the pods. The volumes of
+// an existing StatefulSet are left untouched
+func applyVolumeSnapshotRestore(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, statefulSet *appsv1.StatefulSet) error {
+	reqLogger := log.FromContext(ctx)
+	scopedLog := reqLogger.WithName("applyVolumeSnapshotRestore").WithValues("name", target.cr.GetName(), "namespace", target.cr.GetNamespace())
+
+	restoreFrom := target.spec.RestoreFrom
+	if restoreFrom == "" || target.status.RestoredFrom == restoreFrom {
+		return nil
+	}
+
+	namespace := statefulSet.GetNamespace()
+	current := &appsv1.StatefulSet{}
+	err := c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: statefulSet.GetName()}, current)
+	if err == nil {
+		return nil
+	} else if !k8serrors.IsNotFound(err) {
+		return err
+	}
+
+	snapshots := 0
+	for i := int32(0); i < *statefulSet.Spec.Replicas; i++ {
+		for _, template := range statefulSet.Spec.VolumeClaimTemplates {
+			volumeType := strings.TrimPrefix(template.GetName(), fmt.Sprintf(splcommon.PvcNamePrefix, ""))
+			snapshot := &snapshotv1.VolumeSnapshot{}
+			err = c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: getVolumeSnapshotName(restoreFrom, volumeType, i)}, snapshot)
+			if k8serrors.IsNotFound(err) {
+				// the volume is provisioned empty
+				continue
+			} else if err != nil {
+				return err
+			}
+			snapshots++
+
+			pvcName := fmt.Sprintf("%s-%s-%d", template.GetName(), statefulSet.GetName(), i)
+			pvc := &corev1.PersistentVolumeClaim{}
+			err = c.Get(ctx, types.NamespacedName{Namespace: namespace, Name: pvcName}, pvc)
+			if err == nil {
+				continue
+			} else if !k8serrors.IsNotFound(err) {
+				return err
+			}
+
+			pvc = getVolumeSnapshotRestoreClaim(&template, pvcName, namespace, snapshot)
+			err = c.Create(ctx, pvc)
+			if err != nil {
+				return err
+			}
+			scopedLog.Info("Provisioned the PVC from its snapshot", "pvc", pvcName, "snapshot", snapshot.GetName())
+		}
+	}
+
+	if snapshots == 0 {
+		return fmt.Errorf("snapshot set %s is not found", restoreFrom)
+	}
+
+	eventPublisher, _ := newK8EventPublisher(c, target.cr)
+	eventPublisher.Normal(ctx, "VolumeSnapshotSet", fmt.Sprintf("volumes provisioned from snapshot set %s", restoreFrom))
+	target.status.RestoredFrom = restoreFrom
+	return nil
+}
+
+// getVolumeSnapshotRestoreClaim returns the PVC of a pod provisioned from a VolumeSnapshot, with the capacity of the
+// volume claim template, or the restore size of the snapshot if larger
+func getVolumeSnapshotRestoreClaim(template *corev1.PersistentVolumeClaim, pvcName string, namespace string, snapshot *snapshotv1.VolumeSnapshot) *corev1.PersistentVolumeClaim {
+	apiGroup := snapshotv1.GroupName
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pvcName,
+			Namespace: namespace,
+			Labels:    template.GetLabels(),
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+	pvc.Spec.DataSource = &corev1.TypedLocalObjectReference{
+		APIGroup: &apiGroup,
+		Kind:     "VolumeSnapshot",
+		Name:     snapshot.GetName(),
+	}
+
+	if snapshot.Status != nil && snapshot.Status.RestoreSize != nil {
+		capacity := pvc.Spec.Resources.Requests[corev1.ResourceStorage]
+		if snapshot.Status.RestoreSize.Cmp(capacity) > 0 {
+			pvc.Spec.Resources.Requests[corev1.ResourceStorage] = snapshot.Status.RestoreSize.DeepCopy()
+		}
+	}
+	return pvc
+}
@@ -0,0 +1,349 @@
+// Copyright (c) 2018-2022 Splunk Inc. All rights reserved.
+
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// 	http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package enterprise
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
+	splclient "github.com/splunk/splunk-operator/pkg/splunk/client"
+	splcommon "github.com/splunk/splunk-operator/pkg/splunk/common"
+	spltest "github.com/splunk/splunk-operator/pkg/splunk/test"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newVolumeSnapshotTestTarget(cr splcommon.MetaObject, instanceType InstanceType, replicas int32) *volumeSnapshotTarget {
+	return &volumeSnapshotTarget{
+		cr:           cr,
+		commonSpec:   &enterpriseApi.CommonSplunkSpec{},
+		spec:         &enterpriseApi.VolumeSnapshotSpec{Schedule: "0 2 * * *", VolumeSnapshotClassName: "csi-hostpath-snapclass"},
+		status:       &enterpriseApi.VolumeSnapshotStatus{},
+		instanceType: instanceType,
+		replicas:     replicas,
+	}
+}
+
+// setVolumeSnapshotsReady marks the VolumeSnapshots of a snapshot set taken and ready to use
+func setVolumeSnapshotsReady(ctx context.Context, t *testing.T, c *spltest.MockClient, target *volumeSnapshotTarget, set *enterpriseApi.VolumeSnapshotSetInfo, readyToUse bool) {
+	for i := int32(0); i < set.Pods; i++ {
+		for _, volumeType := range set.Volumes {
+			snapshot := &snapshotv1.VolumeSnapshot{}
+			err := c.Get(ctx, types.NamespacedName{Namespace: target.cr.GetNamespace(), Name: getVolumeSnapshotName(set.Name, volumeType, i)}, snapshot)
+			if err != nil {
+				t.Fatalf("VolumeSnapshot of volume %s of pod %d should be created. error: %v", volumeType, i, err)
+			}
+			creationTime := metav1.Now()
+			snapshot.Status = &snapshotv1.VolumeSnapshotStatus{CreationTime: &creationTime, ReadyToUse: &readyToUse}
+			c.AddObject(snapshot)
+		}
+	}
+}
+
+func TestValidateVolumeSnapshotSpec(t *testing.T) {
+	commonSpec := &enterpriseApi.CommonSplunkSpec{}
+	spec := &enterpriseApi.VolumeSnapshotSpec{Schedule: "0 2 * * *", Quiesce: enterpriseApi.VolumeSnapshotQuiesceMaintenanceMode}
+	err := validateVolumeSnapshotSpec(spec, commonSpec, SplunkIndexer)
+	if err != nil {
+		t.Errorf("valid snapshot spec should be accepted. error: %v", err)
+	}
+
+	err = validateVolumeSnapshotSpec(spec, commonSpec, SplunkSearchHead)
+	if err == nil {
+		t.Errorf("maintenance mode should be rejected for search heads")
+	}
+
+	spec.Quiesce = "Freeze"
+	err = validateVolumeSnapshotSpec(spec, commonSpec, SplunkStandalone)
+	if err == nil {
+		t.Errorf("invalid quiesce should be rejected")
+	}
+
+	spec.Quiesce = ""
+	spec.Schedule = "0 2 * *"
+	err = validateVolumeSnapshotSpec(spec, commonSpec, SplunkStandalone)
+	if err == nil {
+		t.Errorf("invalid schedule should be rejected")
+	}
+
+	spec.Schedule = "0 2 * * *"
+	commonSpec.EtcVolumeStorageConfig.EphemeralStorage = true
+	commonSpec.VarVolumeStorageConfig.EphemeralStorage = true
+	err = validateVolumeSnapshotSpec(spec, commonSpec, SplunkStandalone)
+	if err == nil {
+		t.Errorf("snapshots of ephemeral volumes should be rejected")
+	}
+
+	commonSpec.VarVolumeStorageConfig.EphemeralStorage = false
+	if !reflect.DeepEqual(getVolumeSnapshotTypes(commonSpec), []string{"var"}) {
+		t.Errorf("only the var volume should be snapshotted, got %v", getVolumeSnapshotTypes(commonSpec))
+	}
+}
+
+func TestApplyVolumeSnapshotsMaintenanceMode(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	createTime := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	cr := &enterpriseApi.IndexerCluster{
+		TypeMeta:   metav1.TypeMeta{Kind: "IndexerCluster"},
+		ObjectMeta: metav1.ObjectMeta{Name: "idxc1", Namespace: "test", CreationTimestamp: metav1.NewTime(createTime)},
+	}
+	target := newVolumeSnapshotTestTarget(cr, SplunkIndexer, 2)
+
+	cmURI := "https://splunk-cm1-cluster-manager-service.test.svc.cluster.local:8089"
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	target.getClusterManagerClient = func(ctx context.Context) *splclient.SplunkClient {
+		splunkClient := splclient.NewSplunkClient(cmURI, "admin", "p@ssw0rd")
+		splunkClient.Client = mockSplunkClient
+		return splunkClient
+	}
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "POST", URL: cmURI + "/services/cluster/manager/control/default/maintenance", Status: 200})
+
+	// no snapshot before the scheduled time
+	now := time.Date(2023, 1, 1, 1, 0, 0, 0, time.UTC)
+	requeueAfter, err := applyVolumeSnapshots(ctx, c, target, now)
+	if err != nil || target.status.Phase != "" || requeueAfter != time.Hour || target.status.NextSnapshotTime != time.Date(2023, 1, 1, 2, 0, 0, 0, time.UTC).Unix() {
+		t.Errorf("snapshot set should be scheduled at 02:00. requeueAfter: %v, err: %v, status: %v", requeueAfter, err, target.status)
+	}
+
+	// the cluster manager is put in maintenance mode and the snapshots are created
+	now = time.Date(2023, 1, 1, 2, 0, 30, 0, time.UTC)
+	requeueAfter, err = applyVolumeSnapshots(ctx, c, target, now)
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseInProgress || !target.status.Quiesced || requeueAfter != 5*time.Second {
+		t.Fatalf("snapshot set should be in progress. err: %v, status: %v", err, target.status)
+	}
+	set := target.status.CurrentSet
+	if set.Name != "idxc1-20230101020030" || set.Pods != 2 || !reflect.DeepEqual(set.Volumes, []string{"etc", "var"}) {
+		t.Errorf("unexpected snapshot set %v", set)
+	}
+	snapshot := &snapshotv1.VolumeSnapshot{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "idxc1-20230101020030-var-1"}, snapshot)
+	if err != nil || *snapshot.Spec.Source.PersistentVolumeClaimName != "pvc-var-splunk-idxc1-indexer-1" || *snapshot.Spec.VolumeSnapshotClassName != "csi-hostpath-snapclass" || snapshot.GetLabels()[enterpriseApi.VolumeSnapshotSetLabel] != set.Name {
+		t.Errorf("unexpected VolumeSnapshot %v. err: %v", snapshot, err)
+	}
+	if len(mockSplunkClient.GotRequests) != 1 {
+		t.Errorf("cluster manager should be put in maintenance mode, got %d requests", len(mockSplunkClient.GotRequests))
+	}
+
+	// the cluster manager stays in maintenance mode until the snapshots are taken
+	_, err = applyVolumeSnapshots(ctx, c, target, now.Add(5*time.Second))
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseInProgress || !target.status.Quiesced {
+		t.Errorf("snapshot set should still be in progress. err: %v, status: %v", err, target.status)
+	}
+
+	// the cluster manager is released once the snapshots are taken, before they are ready to use
+	setVolumeSnapshotsReady(ctx, t, c, target, set, false)
+	_, err = applyVolumeSnapshots(ctx, c, target, now.Add(10*time.Second))
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseInProgress || target.status.Quiesced || len(mockSplunkClient.GotRequests) != 2 {
+		t.Errorf("cluster manager should be released. err: %v, status: %v", err, target.status)
+	}
+
+	setVolumeSnapshotsReady(ctx, t, c, target, set, true)
+	requeueAfter, err = applyVolumeSnapshots(ctx, c, target, now.Add(15*time.Second))
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseCompleted || target.status.CurrentSet != nil || len(target.status.SnapshotSets) != 1 || target.status.SnapshotSets[0].Name != set.Name {
+		t.Errorf("snapshot set should be completed. err: %v, status: %v", err, target.status)
+	}
+	nextSnapshotTime := time.Date(2023, 1, 2, 2, 0, 0, 0, time.UTC)
+	if target.status.NextSnapshotTime != nextSnapshotTime.Unix() || requeueAfter != nextSnapshotTime.Sub(now.Add(15*time.Second)) {
+		t.Errorf("next snapshot set should be scheduled the next day. requeueAfter: %v, status: %v", requeueAfter, target.status)
+	}
+
+	// a snapshot set not ready to use fails on timeout, and its snapshots are deleted
+	now = nextSnapshotTime
+	_, err = applyVolumeSnapshots(ctx, c, target, now)
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseInProgress {
+		t.Fatalf("snapshot set should be in progress. err: %v, status: %v", err, target.status)
+	}
+	set = target.status.CurrentSet
+	_, err = applyVolumeSnapshots(ctx, c, target, now.Add((volumeSnapshotTimeout+1)*time.Second))
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseFailed || target.status.Quiesced || !strings.Contains(target.status.Message, "not ready to use") {
+		t.Errorf("snapshot set should fail on timeout. err: %v, status: %v", err, target.status)
+	}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: getVolumeSnapshotName(set.Name, "etc", 0)}, snapshot)
+	if err == nil {
+		t.Errorf("VolumeSnapshots of the failed snapshot set should be deleted")
+	}
+	if len(target.status.SnapshotSets) != 1 {
+		t.Errorf("failed snapshot set should not be recorded, got %v", target.status.SnapshotSets)
+	}
+}
+
+func TestApplyVolumeSnapshotsRollHotBuckets(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := &enterpriseApi.Standalone{
+		TypeMeta:   metav1.TypeMeta{Kind: "Standalone"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1", Namespace: "test", CreationTimestamp: metav1.NewTime(time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC))},
+	}
+	target := newVolumeSnapshotTestTarget(cr, SplunkStandalone, 1)
+	target.spec.VolumeSnapshotClassName = ""
+
+	mockSplunkClient := &spltest.MockHTTPClient{}
+	savedSplunkClient := getVolumeSnapshotSplunkClient
+	defer func() { getVolumeSnapshotSplunkClient = savedSplunkClient }()
+	getVolumeSnapshotSplunkClient = func(ctx context.Context, c splcommon.ControllerClient, target *volumeSnapshotTarget, podName string) (*splclient.SplunkClient, error) {
+		splunkClient := splclient.NewSplunkClient(getSearchHeadURI(target.cr.GetNamespace(), target.instanceType, target.cr.GetName(), podName), "admin", "p@ssw0rd")
+		splunkClient.Client = mockSplunkClient
+		return splunkClient, nil
+	}
+
+	podURI := getSearchHeadURI("test", SplunkStandalone, "stack1", "splunk-stack1-standalone-0")
+	mockSplunkClient.AddHandlers(
+		spltest.MockHTTPHandler{Method: "GET", URL: podURI + "/services/data/indexes?count=0&output_mode=json", Status: 200,
+			Body: `{"entry":[{"name":"main","content":{"disabled":false}},{"name":"history","content":{"disabled":true}},{"name":"_internal","content":{"disabled":false}}]}`},
+		spltest.MockHTTPHandler{Method: "POST", URL: podURI + "/services/data/indexes/main/roll-hot-buckets", Status: 200},
+		spltest.MockHTTPHandler{Method: "POST", URL: podURI + "/services/data/indexes/_internal/roll-hot-buckets", Status: 200},
+	)
+
+	now := time.Date(2023, 1, 1, 2, 0, 0, 0, time.UTC)
+	_, err := applyVolumeSnapshots(ctx, c, target, now)
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseInProgress || target.status.Quiesced {
+		t.Fatalf("snapshot set should be in progress. err: %v, status: %v", err, target.status)
+	}
+	gotURLs := []string{}
+	for _, request := range mockSplunkClient.GotRequests {
+		gotURLs = append(gotURLs, request.URL.Path)
+	}
+	wantURLs := []string{"/services/data/indexes", "/services/data/indexes/_internal/roll-hot-buckets", "/services/data/indexes/main/roll-hot-buckets"}
+	if !reflect.DeepEqual(gotURLs, wantURLs) {
+		t.Errorf("hot buckets of the enabled indexes should be rolled. got %v, want %v", gotURLs, wantURLs)
+	}
+	snapshot := &snapshotv1.VolumeSnapshot{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "stack1-20230101020000-etc-0"}, snapshot)
+	if err != nil || snapshot.Spec.VolumeSnapshotClassName != nil {
+		t.Errorf("VolumeSnapshot should use the default VolumeSnapshotClass. err: %v", err)
+	}
+
+	// a snapshot set fails when the hot buckets can't be rolled
+	target.status.Phase = ""
+	target.status.CurrentSet = nil
+	mockSplunkClient.AddHandlers(spltest.MockHTTPHandler{Method: "POST", URL: podURI + "/services/data/indexes/main/roll-hot-buckets", Status: 500})
+	_, err = applyVolumeSnapshots(ctx, c, target, now.Add(24*time.Hour))
+	if err != nil || target.status.Phase != enterpriseApi.BackupPhaseFailed || !strings.Contains(target.status.Message, "unable to quiesce") {
+		t.Errorf("snapshot set should fail. err: %v, status: %v", err, target.status)
+	}
+}
+
+func TestApplyVolumeSnapshotRetention(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := &enterpriseApi.SearchHeadCluster{
+		ObjectMeta: metav1.ObjectMeta{Name: "shc1", Namespace: "test"},
+	}
+	target := newVolumeSnapshotTestTarget(cr, SplunkSearchHead, 3)
+	target.spec.MaxSnapshotSets = 2
+	for _, name := range []string{"shc1-20230101020000", "shc1-20230102020000", "shc1-20230103020000"} {
+		set := enterpriseApi.VolumeSnapshotSetInfo{Name: name, Pods: 3, Volumes: []string{"etc", "var"}}
+		target.status.SnapshotSets = append(target.status.SnapshotSets, set)
+		for i := int32(0); i < set.Pods; i++ {
+			for _, volumeType := range set.Volumes {
+				c.AddObject(getVolumeSnapshot(target, set.Name, volumeType, i))
+			}
+		}
+	}
+
+	err := applyVolumeSnapshotRetention(ctx, c, target)
+	if err != nil || len(target.status.SnapshotSets) != 2 || target.status.SnapshotSets[0].Name != "shc1-20230102020000" {
+		t.Errorf("oldest snapshot set should be deleted. err: %v, sets: %v", err, target.status.SnapshotSets)
+	}
+	if len(c.Calls["Delete"]) != 6 {
+		t.Errorf("VolumeSnapshots of the oldest snapshot set should be deleted, got %d deletes", len(c.Calls["Delete"]))
+	}
+	snapshot := &snapshotv1.VolumeSnapshot{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "shc1-20230101020000-var-2"}, snapshot)
+	if err == nil {
+		t.Errorf("VolumeSnapshots of the oldest snapshot set should be deleted")
+	}
+}
+
+func TestApplyVolumeSnapshotRestore(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+	cr := &enterpriseApi.Standalone{
+		TypeMeta:   metav1.TypeMeta{Kind: "Standalone"},
+		ObjectMeta: metav1.ObjectMeta{Name: "stack2", Namespace: "test"},
+	}
+	target := newVolumeSnapshotTestTarget(cr, SplunkStandalone, 1)
+	target.spec.Schedule = ""
+	target.spec.RestoreFrom = "stack1-20230101020000"
+
+	replicas := int32(1)
+	statefulSet := &appsv1.StatefulSet{
+		ObjectMeta: metav1.ObjectMeta{Name: "splunk-stack2-standalone", Namespace: "test"},
+		Spec: appsv1.StatefulSetSpec{
+			Replicas: &replicas,
+			VolumeClaimTemplates: []corev1.PersistentVolumeClaim{
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "pvc-etc"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("10Gi")}},
+					},
+				},
+				{
+					ObjectMeta: metav1.ObjectMeta{Name: "pvc-var"},
+					Spec: corev1.PersistentVolumeClaimSpec{
+						Resources: corev1.ResourceRequirements{Requests: corev1.ResourceList{corev1.ResourceStorage: resource.MustParse("100Gi")}},
+					},
+				},
+			},
+		},
+	}
+
+	// the restore fails when the snapshot set is not found
+	err := applyVolumeSnapshotRestore(ctx, c, target, statefulSet)
+	if err == nil || target.status.RestoredFrom != "" {
+		t.Errorf("restore of a missing snapshot set should fail")
+	}
+
+	restoreSize := resource.MustParse("20Gi")
+	c.AddObject(&snapshotv1.VolumeSnapshot{
+		ObjectMeta: metav1.ObjectMeta{Name: "stack1-20230101020000-etc-0", Namespace: "test"},
+		Status:     &snapshotv1.VolumeSnapshotStatus{RestoreSize: &restoreSize},
+	})
+	err = applyVolumeSnapshotRestore(ctx, c, target, statefulSet)
+	if err != nil || target.status.RestoredFrom != "stack1-20230101020000" {
+		t.Errorf("volumes should be provisioned from the snapshot set. err: %v", err)
+	}
+	pvc := &corev1.PersistentVolumeClaim{}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-etc-splunk-stack2-standalone-0"}, pvc)
+	if err != nil || pvc.Spec.DataSource == nil || pvc.Spec.DataSource.Kind != "VolumeSnapshot" || pvc.Spec.DataSource.Name != "stack1-20230101020000-etc-0" {
+		t.Errorf("etc PVC should be provisioned from its snapshot. err: %v, pvc: %v", err, pvc)
+	}
+	if capacity := pvc.Spec.Resources.Requests[corev1.ResourceStorage]; capacity.Cmp(restoreSize) != 0 {
+		t.Errorf("etc PVC should request the restore size of its snapshot, got %s", capacity.String())
+	}
+	err = c.Get(ctx, types.NamespacedName{Namespace: "test", Name: "pvc-var-splunk-stack2-standalone-0"}, pvc)
+	if err == nil {
+		t.Errorf("var PVC without snapshot should be left to the StatefulSet")
+	}
+
+	// the volumes of an existing StatefulSet are not restored
+	target.status.RestoredFrom = ""
+	c.AddObject(statefulSet)
+	creates := len(c.Calls["Create"])
+	err = applyVolumeSnapshotRestore(ctx, c, target, statefulSet)
+	if err != nil || target.status.RestoredFrom != "" || len(c.Calls["Create"]) != creates {
+		t.Errorf("volumes of an existing StatefulSet should not be restored. err: %v", err)
+	}
+}
@@ -21,10 +21,12 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"strconv"
 	"testing"
 
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/s3"
 	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 )
@@ -83,6 +85,22 @@ func (mockClient MockAWSS3Client) ListObjectsV2(options *s3.ListObjectsV2Input)
 		return nil, err
 	}
 
+	// return a page of upto MaxKeys objects, starting at the continuation token
+	start := 0
+	if options.ContinuationToken != nil {
+		start, err = strconv.Atoi(*options.ContinuationToken)
+		if err != nil {
+			return nil, err
+		}
+	}
+	end := len(output.Contents)
+	if options.MaxKeys != nil && start+int(*options.MaxKeys) < end {
+		end = start + int(*options.MaxKeys)
+		output.IsTruncated = aws.Bool(true)
+		output.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	output.Contents = output.Contents[start:end]
+
 	return output, nil
 }
 
@@ -91,6 +109,19 @@ func (mockClient MockAWSS3ClientError) ListObjectsV2(options *s3.ListObjectsV2In
 	return &s3.ListObjectsV2Output{}, errors.New("Dummy Error")
 }
 
+// DeleteObject is a mock call to DeleteObject
+func (mockClient MockAWSS3Client) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	if *input.Key == "" {
+		return nil, errors.New("empty key")
+	}
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// DeleteObject is a mock call to DeleteObject
+func (mockClient MockAWSS3ClientError) DeleteObject(input *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	return &s3.DeleteObjectOutput{}, errors.New("Dummy Error")
+}
+
 // MockAWSDownloadClient is mock aws client for download
 type MockAWSDownloadClient struct{}
 
@@ -109,3 +140,20 @@ func (mockDownloadClient MockAWSDownloadClient) Download(w io.WriterAt, input *s
 
 	return bytes, nil
 }
+
+// MockAWSUploadClient is mock aws client for upload
+type MockAWSUploadClient struct{}
+
+// Upload is a mock call for aws sdk upload api.
+// It just does some error checking.
+func (mockUploadClient MockAWSUploadClient) Upload(input *s3manager.UploadInput, options ...func(*s3manager.Uploader)) (*s3manager.UploadOutput, error) {
+	remoteFile := *input.Key
+	localFile := input.Body.(*os.File).Name()
+
+	if remoteFile == "" || localFile == "" {
+		err := fmt.Errorf("empty localFile/remoteFile. remoteFile=%s, localFile=%s", remoteFile, localFile)
+		return nil, err
+	}
+
+	return &s3manager.UploadOutput{}, nil
+}
@@ -22,11 +22,13 @@ import (
 	"reflect"
 	"testing"
 
+	snapshotv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
 	enterpriseApiV3 "github.com/splunk/splunk-operator/api/v3"
 	enterpriseApi "github.com/splunk/splunk-operator/api/v4"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	storagev1 "k8s.io/api/storage/v1"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -39,8 +41,8 @@ import (
 )
 
 func init() {
-	MockObjectCopiers = append(MockObjectCopiers, coreObjectCopier, appsObjectCopier, enterpriseObjCopier)
-	MockObjectListCopiers = append(MockObjectListCopiers, coreObjectListCopier, enterpriseObjListCopier)
+	MockObjectCopiers = append(MockObjectCopiers, coreObjectCopier, appsObjectCopier, enterpriseObjCopier, storageObjectCopier, snapshotObjectCopier)
+	MockObjectListCopiers = append(MockObjectListCopiers, coreObjectListCopier, enterpriseObjListCopier, storageObjectListCopier)
 }
 
 // MockObjectCopiers is a slice of MockObjectCopier methods that MockClient uses to copy client.Objects
@@ -168,6 +170,45 @@ func appsObjectCopier(dst, src *client.Object) bool {
 	return true
 }
 
+// storageObjectCopier is used to copy storagev1 client.Objects
+func storageObjectCopier(dst, src *client.Object) bool {
+	srcP := *src
+	dstP := *dst
+	switch srcP.(type) {
+	case *storagev1.StorageClass:
+		*dstP.(*storagev1.StorageClass) = *srcP.(*storagev1.StorageClass)
+	default:
+		return false
+	}
+	return true
+}
+
+// snapshotObjectCopier is used to copy snapshotv1 client.Objects
+func snapshotObjectCopier(dst, src *client.Object) bool {
+	srcP := *src
+	dstP := *dst
+	switch srcP.(type) {
+	case *snapshotv1.VolumeSnapshot:
+		*dstP.(*snapshotv1.VolumeSnapshot) = *srcP.(*snapshotv1.VolumeSnapshot)
+	default:
+		return false
+	}
+	return true
+}
+
+// storageObjectListCopier is used to copy storagev1 client.ObjectList
+func storageObjectListCopier(dst, src *client.ObjectList) bool {
+	srcP := *src
+	dstP := *dst
+	switch srcP.(type) {
+	case *storagev1.StorageClassList:
+		*dstP.(*storagev1.StorageClassList) = *srcP.(*storagev1.StorageClassList)
+	default:
+		return false
+	}
+	return true
+}
+
 // copyMockObject uses the global MockObjectCopiers to perform the typed copy of a client.Object from src to dst
 func copyMockObject(dst, src *client.Object) {
 	for n := range MockObjectCopiers {
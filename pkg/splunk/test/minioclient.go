@@ -101,3 +101,21 @@ func (mockClient MockMinioS3Client) FGetObject(ctx context.Context, bucketName s
 	}
 	return err
 }
+
+// FPutObject is a mock call to upload a file to minio client.
+// It just does some error checking.
+func (mockClient MockMinioS3Client) FPutObject(ctx context.Context, bucketName string, objectName string, filePath string, opts minio.PutObjectOptions) (minio.UploadInfo, error) {
+	if objectName == "" || filePath == "" {
+		return minio.UploadInfo{}, fmt.Errorf("empty objectName/filePath. objectName=%s, filePath=%s", objectName, filePath)
+	}
+	return minio.UploadInfo{Bucket: bucketName, Key: objectName}, nil
+}
+
+// RemoveObject is a mock call to delete a file from minio client.
+// It just does some error checking.
+func (mockClient MockMinioS3Client) RemoveObject(ctx context.Context, bucketName string, objectName string, opts minio.RemoveObjectOptions) error {
+	if objectName == "" {
+		return fmt.Errorf("empty objectName")
+	}
+	return nil
+}
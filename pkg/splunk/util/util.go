@@ -18,6 +18,7 @@ package util
 import (
 	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"strings"
 
@@ -148,13 +149,40 @@ var podExecNewSPDYExecutor = remotecommand.NewSPDYExecutor
 
 // PodExecCommand execute a shell command in the specified pod
 func PodExecCommand(ctx context.Context, c splcommon.ControllerClient, podName string, namespace string, cmd []string, streamOptions *remotecommand.StreamOptions, tty bool, mock bool, mockKubPath string) (string, string, error) {
+	stdout := new(bytes.Buffer)
+	stderr := new(bytes.Buffer)
+
+	streamOptions.Stdout = stdout
+	streamOptions.Stderr = stderr
+
+	err := podExecStream(ctx, c, podName, namespace, cmd, streamOptions, tty, mock, mockKubPath)
+
+	return stdout.String(), stderr.String(), err
+}
+
+// PodExecStream executes a command in the specified pod, and streams its standard output to the given writer,
+// instead of holding it in memory. Returns the standard error of the command
+func PodExecStream(ctx context.Context, c splcommon.ControllerClient, podName string, namespace string, cmd []string, stdout io.Writer) (string, error) {
+	stderr := new(bytes.Buffer)
+	streamOptions := &remotecommand.StreamOptions{
+		Stdout: stdout,
+		Stderr: stderr,
+	}
+
+	err := podExecStream(ctx, c, podName, namespace, cmd, streamOptions, false, false, "")
+
+	return stderr.String(), err
+}
+
+// podExecStream executes a command in the specified pod, with the given streams
+func podExecStream(ctx context.Context, c splcommon.ControllerClient, podName string, namespace string, cmd []string, streamOptions *remotecommand.StreamOptions, tty bool, mock bool, mockKubPath string) error {
 	var pod corev1.Pod
 
 	// Get Pod
 	namespacedName := types.NamespacedName{Namespace: namespace, Name: podName}
 	err := c.Get(ctx, namespacedName, &pod)
 	if err != nil {
-		return "", "", err
+		return err
 	}
 
 	gvk, _ := apiutil.GVKForObject(&pod, scheme.Scheme)
@@ -162,17 +190,17 @@ func PodExecCommand(ctx context.Context, c splcommon.ControllerClient, podName s
 	if !mock {
 		restConfig, err = podExecGetConfig()
 		if err != nil {
-			return "", "", err
+			return err
 		}
 	} else {
 		restConfig, err = clientcmd.BuildConfigFromFlags("", mockKubPath)
 		if err != nil {
-			return "", "", err
+			return err
 		}
 	}
 	restClient, err := podExecRESTClientForGVK(gvk, false, restConfig, serializer.NewCodecFactory(scheme.Scheme))
 	if err != nil {
-		return "", "", err
+		return err
 	}
 	execReq := restClient.Post().Resource("pods").Name(podName).Namespace(namespace).SubResource("exec")
 	option := &corev1.PodExecOptions{
@@ -192,17 +220,10 @@ func PodExecCommand(ctx context.Context, c splcommon.ControllerClient, podName s
 	)
 	exec, err := podExecNewSPDYExecutor(restConfig, http.MethodPost, execReq.URL())
 	if err != nil {
-		return "", "", err
+		return err
 	}
-	stdout := new(bytes.Buffer)
-	stderr := new(bytes.Buffer)
-
-	streamOptions.Stdout = stdout
-	streamOptions.Stderr = stderr
-
-	err = exec.Stream(*streamOptions)
 
-	return stdout.String(), stderr.String(), err
+	return exec.Stream(*streamOptions)
 }
 
 // PodExecClientImpl is an interface which is used to implement
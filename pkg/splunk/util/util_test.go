@@ -211,6 +211,17 @@ func TestDeepCopy(t *testing.T) {
 	_ = cr.DeepCopy()
 }
 
+func TestPodExecStream(t *testing.T) {
+	ctx := context.TODO()
+	c := spltest.NewMockClient()
+
+	var stdout strings.Builder
+	_, err := PodExecStream(ctx, c, "splunk-stack1-0", "test", []string{"cat", "/opt/splunk/etc/splunk.version"}, &stdout)
+	if err == nil {
+		t.Errorf("PodExecStream() should return error for a missing pod")
+	}
+}
+
 func TestPodExecCommand(t *testing.T) {
 	ctx := context.TODO()
 	// Create pod